@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PropagatorsConfig specifies which propagation steps are enabled. All of them
+// are enabled if not specified.
+type PropagatorsConfig struct {
+	// Spec enables pushing the local claims to the remote cluster.
+	// +optional
+	Spec *bool `json:"spec,omitempty"`
+
+	// Status enables propagating the status of the remote claims to the local
+	// ones.
+	// +optional
+	Status *bool `json:"status,omitempty"`
+
+	// Secret enables propagating the connection secrets of the remote claims
+	// to the local cluster.
+	// +optional
+	Secret *bool `json:"secret,omitempty"`
+
+	// LateInit enables late-initializing the spec of the local claims with the
+	// values of the remote ones.
+	// +optional
+	LateInit *bool `json:"lateInit,omitempty"`
+}
+
+// AgentConfigSpec specifies how the agent syncs the claims.
+type AgentConfigSpec struct {
+	// FieldPolicies maps the paths of claim fields, e.g. spec.compositionRef,
+	// to the cluster that owns them. The default policies are kept for the
+	// fields that are not listed.
+	// +optional
+	FieldPolicies map[string]FieldPolicy `json:"fieldPolicies,omitempty"`
+
+	// RemoteDefaultedFields are the paths of the claim fields that are
+	// defaulted by the admission webhooks of the remote cluster. Their remote
+	// values are late-initialized rather than overwritten unless they have a
+	// field policy.
+	// +optional
+	RemoteDefaultedFields []string `json:"remoteDefaultedFields,omitempty"`
+
+	// InputSecretRefs are the paths of the secret references in the claims,
+	// e.g. spec.forProvider.passwordSecretRef, whose local secrets are
+	// mirrored to the remote cluster.
+	// +optional
+	InputSecretRefs []string `json:"inputSecretRefs,omitempty"`
+
+	// ReflectedAnnotations are the keys of the annotations of the remote
+	// claims, e.g. ones with cost or usage data, that are mirrored to the local
+	// claims as read-only information.
+	// +optional
+	ReflectedAnnotations []string `json:"reflectedAnnotations,omitempty"`
+
+	// Propagators specifies which propagation steps are enabled.
+	// +optional
+	Propagators *PropagatorsConfig `json:"propagators,omitempty"`
+
+	// NamespaceMappings maps the namespaces of the local claims to the
+	// namespaces of their remote instances. The remote instances of the claims
+	// in other namespaces are in the same namespace as the local one.
+	// +optional
+	NamespaceMappings map[string]string `json:"namespaceMappings,omitempty"`
+
+	// DefaultRemoteNamespace is the namespace of the remote instances whose
+	// kind is cluster-scoped locally but namespaced remotely.
+	// +optional
+	DefaultRemoteNamespace string `json:"defaultRemoteNamespace,omitempty"`
+
+	// RemoteLabels are added to all remote claims.
+	// +optional
+	RemoteLabels map[string]string `json:"remoteLabels,omitempty"`
+
+	// RemoteAnnotations are added to all remote claims.
+	// +optional
+	RemoteAnnotations map[string]string `json:"remoteAnnotations,omitempty"`
+
+	// PostApplyVerify makes the agent read the remote claims back after
+	// writing them and report if their spec was altered, e.g. by a mutating
+	// admission webhook. Warn only reports it while Fail also retries the
+	// claim. Nothing is verified if not given.
+	// +optional
+	// +kubebuilder:validation:Enum=Warn;Fail
+	PostApplyVerify string `json:"postApplyVerify,omitempty"`
+
+	// InjectProviderConfig is the name of the provider config that the remote
+	// claims refer to if they don't refer to any.
+	// +optional
+	InjectProviderConfig string `json:"injectProviderConfig,omitempty"`
+
+	// Maintenance stops the agent from writing to the local or remote
+	// cluster, e.g. during a maintenance window of either. The claims are
+	// still reconciled to report that they're not synced.
+	// +optional
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+// A FieldPolicy determines which cluster owns a field of the claim.
+// +kubebuilder:validation:Enum=Local;Remote;LateInit;Merge
+type FieldPolicy string
+
+// +kubebuilder:object:root=true
+
+// An AgentConfig configures how the agent syncs the claims. Changes are applied
+// without restarting the agent. The settings of the remote clusters themselves
+// are given with flags since the agent has to be restarted to change them.
+// +kubebuilder:resource:scope=Cluster
+type AgentConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AgentConfigSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// AgentConfigList contains a list of AgentConfig.
+type AgentConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AgentConfig `json:"items"`
+}
+
+// ClaimCounts are the numbers of claims by their sync state.
+type ClaimCounts struct {
+	// Total is the number of claims the agent reconciled.
+	Total int `json:"total"`
+
+	// Synced is the number of claims that are synced with the remote cluster.
+	Synced int `json:"synced"`
+
+	// Failed is the number of claims that failed to sync.
+	Failed int `json:"failed"`
+
+	// Paused is the number of claims that the agent doesn't sync for now,
+	// e.g. because they are filtered out, wait for their dependencies, failed
+	// too many times, wait for their deletion grace period to be over or the
+	// agent is in maintenance mode.
+	Paused int `json:"paused"`
+
+	// Unknown is the number of claims whose sync state is not known yet.
+	Unknown int `json:"unknown"`
+}
+
+// RemoteStatus is the state of the connection to the remote cluster.
+type RemoteStatus struct {
+	// Connected is true if the remote cluster is reachable and compatible.
+	Connected bool `json:"connected"`
+
+	// Message explains why the remote cluster is not connected.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// AgentStatusStatus is the aggregated sync state of the agent.
+type AgentStatusStatus struct {
+	// Claims are the numbers of claims by their sync state.
+	Claims ClaimCounts `json:"claims"`
+
+	// Remote is the state of the connection to the remote cluster.
+	Remote RemoteStatus `json:"remote"`
+
+	// LastUpdateTime is when the agent last updated this status.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// An AgentStatus is maintained by the agent to summarize the sync state of all
+// claims and the connection to the remote cluster, e.g. as a health signal for
+// GitOps tools. There is a single AgentStatus per agent.
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="SYNCED",type="integer",JSONPath=".status.claims.synced"
+// +kubebuilder:printcolumn:name="FAILED",type="integer",JSONPath=".status.claims.failed"
+// +kubebuilder:printcolumn:name="CONNECTED",type="boolean",JSONPath=".status.remote.connected"
+type AgentStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status AgentStatusStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AgentStatusList contains a list of AgentStatus.
+type AgentStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AgentStatus `json:"items"`
+}
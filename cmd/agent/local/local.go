@@ -20,7 +20,11 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	crdsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	crds "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -28,13 +32,175 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/crossplane/apis/apiextensions"
 
+	agentv1alpha1 "github.com/crossplane/agent/apis/agent/v1alpha1"
+	"github.com/crossplane/agent/pkg/controllers/claim"
 	"github.com/crossplane/agent/pkg/controllers/xrd"
+	"github.com/crossplane/agent/pkg/startup"
 )
 
+// cacheSyncCheckWait is how long to wait for the local cache to sync whenever
+// it's checked.
+const cacheSyncCheckWait = 100 * time.Millisecond
+
 // Agent configures & starts the manager that will watch the local cluster.
 type Agent struct {
 	ClusterConfig *rest.Config
 	DefaultConfig *rest.Config
+
+	// NamespaceConfigs are the configs of the remote clusters that the claims
+	// of the local namespaces they're keyed by are synced with instead of the
+	// one of ClusterConfig. Each of them is checked, and has its namespaces
+	// created and its connection secrets watched, like the one of
+	// ClusterConfig. See claim.RemoteClientSelector.
+	NamespaceConfigs map[string]*rest.Config
+
+	// CacheSyncTimeout is how long to wait for the caches to sync before
+	// giving up on starting. Zero means no timeout.
+	CacheSyncTimeout time.Duration
+
+	// HealthProbeAddress is the address to serve the readiness probe on, which
+	// reports the agent ready once its local cache is synced. No probe is
+	// served if it's empty.
+	HealthProbeAddress string
+
+	// RemoteNamespaces restricts the remote cache, which serves the reads of
+	// claims and their connection secrets, to the given namespaces. The cache
+	// is cluster-wide if none is given.
+	RemoteNamespaces []string
+
+	// AllowedRemoteNamespaces are the only namespaces of the remote cluster
+	// that claims are written to, if any are given. See
+	// claim.WithAllowedRemoteNamespaces.
+	AllowedRemoteNamespaces []string
+
+	// RemoteDefaultNamespace is the namespace of the remote instances of the
+	// kinds that are cluster-scoped locally but namespaced remotely.
+	RemoteDefaultNamespace string
+
+	// ClusterID is the ID of the local cluster. All namespaced remote
+	// instances are placed in the namespace named after it, which is created
+	// if it doesn't exist, if it's not empty. See claim.ClusterNamespace.
+	// Name collisions are detected then, since the local instances of the
+	// same name in different namespaces share their remote instance.
+	ClusterID string
+
+	// DebugEndpoint enables the endpoints that serve the last reconcile
+	// result of each claim and the inventory of the synced claims.
+	DebugEndpoint bool
+
+	// DebugEndpointToken is the bearer token that the requests to the debug
+	// endpoints must carry. They're not authenticated if it's empty.
+	DebugEndpointToken string
+
+	// WatchRemoteSecrets makes the agent propagate the connection secrets as
+	// soon as they change in the remote cluster.
+	WatchRemoteSecrets bool
+
+	// Maintenance stops the agent from writing to the local or remote cluster
+	// while it still reports the claims as not synced. An AgentConfig can
+	// enable it too while the agent is running.
+	Maintenance bool
+
+	// RemoteEventMessages makes the agent surface the latest warning event of
+	// each remote claim that occurred within RemoteEventMaxAge, if it's not
+	// zero, in the AgentSynced condition of its local claim.
+	RemoteEventMessages bool
+	RemoteEventMaxAge   time.Duration
+
+	// SecretNamespace is the namespace all local connection secrets are
+	// written to. They're written to the namespace of their claim if it's
+	// empty.
+	SecretNamespace string
+
+	// SecretMerge makes the agent merge the keys of the remote connection
+	// secrets into the existing local ones, preserving the keys that only the
+	// local ones have, instead of replacing them. See claim.WithSecretMerge.
+	SecretMerge bool
+
+	// SecretReadyCondition makes the agent report whether the connection
+	// secrets of the claims are propagated with all of the SecretRequiredKeys
+	// in their ConnectionSecretReady condition.
+	SecretReadyCondition bool
+	SecretRequiredKeys   []string
+
+	// RemoteSecretNamePath is the field path of the remote claims that the
+	// names of their connection secrets are read from, if it's not empty. See
+	// claim.WithRemoteSecretNameFrom.
+	RemoteSecretNamePath string
+
+	// SecretKeyValidators validate the values of the keys of the connection
+	// secrets before they're written to the local cluster. See
+	// claim.WithSecretKeyValidation.
+	SecretKeyValidators map[string]claim.SecretKeyValidator
+
+	// Origin identifies the local cluster in the labels of the remote claims
+	// and of the mirrored secrets, if any of its fields is set. See
+	// claim.Origin.
+	Origin claim.Origin
+
+	// PriorityLabels select the claims of high priority, which are enqueued
+	// ahead of the others on a best-effort basis while the claim controllers
+	// are busy. The others are held back for PriorityDelay unless they're
+	// being deleted. All claims are enqueued in the order of their events if
+	// it's empty. See claim.PriorityHandler.
+	PriorityLabels map[string]string
+	PriorityDelay  time.Duration
+
+	// GVKAliases are the kinds the remote instances are served as if they
+	// differ from the kinds of the local instances.
+	GVKAliases claim.GVKAliases
+
+	// Finalizer is the finalizer the agent adds to the local claims it syncs.
+	// It's claim.DefaultFinalizer if it's empty.
+	Finalizer string
+
+	// ReconcilerOptions are passed to the reconcilers of all claim kinds.
+	ReconcilerOptions []claim.ReconcilerOption
+
+	// ConfigName is the name of the AgentConfig that overrides the
+	// ReconcilerOptions. The claim controllers are restarted whenever it
+	// changes. No AgentConfig is read if it's empty.
+	ConfigName string
+
+	// StatusName is the name of the AgentStatus the agent publishes its
+	// aggregated sync state to. No AgentStatus is published if it's empty.
+	StatusName string
+
+	// StatusInterval is how often the AgentStatus is updated.
+	StatusInterval time.Duration
+
+	// ValidateSchema makes the agent validate the claims against the schema
+	// of their CRD in the remote cluster before writing them.
+	ValidateSchema bool
+
+	// PruneUnknownFields makes the agent remove the fields of the claims that
+	// the schema of their CRD in the remote cluster does not define before
+	// writing them.
+	PruneUnknownFields bool
+
+	// NegotiateVersions makes the agent read and write the remote claims at a
+	// version the remote cluster serves if it doesn't serve the version of the
+	// local claims.
+	NegotiateVersions bool
+
+	// UnknownFieldPolicy is what the agent does with the fields of the claims
+	// that the remote cluster doesn't know, i.e. the ones that aren't in
+	// KnownFields, or that the schema of their CRD in the remote cluster does
+	// not define if no KnownFields are given. The unknown fields are written
+	// as they are if it's empty.
+	UnknownFieldPolicy claim.UnknownFieldPolicy
+	KnownFields        []string
+
+	// CrossplaneVersions is the range of versions of Crossplane the remote
+	// cluster has to run for the agent to sync claims with it. The version is
+	// read from the CrossplaneVersionKey of the CrossplaneVersionConfigMap in
+	// the remote cluster, or from the image tag of the CrossplaneDeployment if
+	// there's no such ConfigMap. The version is not checked if the range is
+	// unbounded.
+	CrossplaneVersions         claim.CrossplaneVersionRange
+	CrossplaneVersionConfigMap types.NamespacedName
+	CrossplaneVersionKey       string
+	CrossplaneDeployment       types.NamespacedName
 }
 
 // Run adds all controllers and starts the manager that will watch the local cluster.
@@ -46,22 +212,243 @@ func (a *Agent) Run(log logging.Logger, period time.Duration) error {
 		return errors.Wrap(err, "cannot create cluster remote client")
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{SyncPeriod: &period, MetricsBindAddress: "0.0.0.0:8080"})
+	// We refuse to start if the remote cluster doesn't run a supported version
+	// of Crossplane, and keep checking it periodically while running.
+	dc, err := discovery.NewDiscoveryClientForConfig(a.ClusterConfig)
+	if err != nil {
+		return errors.Wrap(err, "cannot create cluster remote discovery client")
+	}
+	capabilityOpts := func(c client.Client) []claim.CapabilityCheckerOption {
+		if a.CrossplaneVersions.Min == nil && a.CrossplaneVersions.Max == nil {
+			return nil
+		}
+		r := claim.NewConfigMapVersionReader(c, a.CrossplaneVersionConfigMap, a.CrossplaneVersionKey,
+			claim.WithVersionFallback(claim.NewDeploymentVersionReader(c, a.CrossplaneDeployment)))
+		return []claim.CapabilityCheckerOption{claim.WithVersionCheck(r, a.CrossplaneVersions)}
+	}
+	capabilities := claim.NewCapabilityChecker(dc, capabilityOpts(clusterRemoteClient)...)
+	if err := capabilities.Check(); err != nil {
+		return errors.Wrap(err, "cannot verify the remote cluster")
+	}
+
+	// The remote clusters of the mapped namespaces are checked the same way.
+	nsRemoteClients := make(map[string]client.Client, len(a.NamespaceConfigs))
+	nsCapabilities := make(map[string]*claim.CapabilityChecker, len(a.NamespaceConfigs))
+	for ns, cfg := range a.NamespaceConfigs {
+		c, err := client.New(cfg, client.Options{})
+		if err != nil {
+			return errors.Wrapf(err, "cannot create remote client for namespace %s", ns)
+		}
+		d, err := discovery.NewDiscoveryClientForConfig(cfg)
+		if err != nil {
+			return errors.Wrapf(err, "cannot create remote discovery client for namespace %s", ns)
+		}
+		nsCapabilities[ns] = claim.NewCapabilityChecker(d, capabilityOpts(c)...)
+		if err := nsCapabilities[ns].Check(); err != nil {
+			return errors.Wrapf(err, "cannot verify the remote cluster of namespace %s", ns)
+		}
+		nsRemoteClients[ns] = c
+	}
+	// Namespaces are created, and events are read, in the remote cluster of
+	// the local instance they're needed for.
+	remoteClient := claim.NewRemoteClientSelector(clusterRemoteClient, claim.WithNamespaceRemotes(nsRemoteClients))
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{SyncPeriod: &period, MetricsBindAddress: "0.0.0.0:8080", HealthProbeBindAddress: a.HealthProbeAddress})
 	if err != nil {
 		return errors.Wrap(err, "cannot start local cluster manager")
 	}
+	// The claims are not reconciled, and the agent is not ready, until the
+	// local cache is synced. The check waits only briefly since it's run by
+	// every reconciliation until then.
+	synced := startup.NewCacheSyncChecker(mgr.GetCache(), cacheSyncCheckWait)
+	if err := mgr.AddReadyzCheck("local-cache", synced.Check); err != nil {
+		return errors.Wrap(err, "cannot add readiness check")
+	}
 
 	if err := crds.AddToScheme(mgr.GetScheme()); err != nil {
 		return errors.Wrap(err, "Cannot add CustomResourceDefinition API to scheme")
 	}
+	if err := crdsv1.AddToScheme(mgr.GetScheme()); err != nil {
+		return errors.Wrap(err, "Cannot add CustomResourceDefinition API to scheme")
+	}
 
 	if err := apiextensions.AddToScheme(mgr.GetScheme()); err != nil {
 		return errors.Wrap(err, "Cannot add Crossplane apiextensions API to scheme")
 	}
+	if err := agentv1alpha1.AddToScheme(mgr.GetScheme()); err != nil {
+		return errors.Wrap(err, "Cannot add agent API to scheme")
+	}
+	finalizer := a.Finalizer
+	if finalizer == "" {
+		finalizer = claim.DefaultFinalizer
+	}
+	scopeOpts := []claim.ScopeResolverOption{claim.WithDefaultRemoteNamespace(a.RemoteDefaultNamespace)}
+	if a.ClusterID != "" {
+		scopeOpts = append(scopeOpts, claim.WithClusterNamespace(a.ClusterID), claim.WithNamespaceCreation(remoteClient))
+	}
+	if len(a.AllowedRemoteNamespaces) > 0 {
+		scopeOpts = append(scopeOpts, claim.WithAllowedRemoteNamespaces(a.AllowedRemoteNamespaces...))
+	}
+
+	opts := []claim.ReconcilerOption{
+		claim.WithCapabilityChecker(capabilities),
+		claim.WithScopeResolver(claim.NewScopeResolver(dc, scopeOpts...)),
+		claim.WithGVKAliases(a.GVKAliases),
+		claim.WithFinalizerName(finalizer),
+		claim.WithCacheSyncCheck(synced.Synced),
+	}
+	if len(nsCapabilities) > 0 {
+		opts = append(opts, claim.WithNamespaceCapabilityCheckers(nsCapabilities))
+	}
+	if a.ClusterID != "" {
+		opts = append(opts, claim.WithCollisionDetection())
+	}
+	if a.SecretNamespace != "" {
+		opts = append(opts, claim.WithCentralSecretNamespace(a.SecretNamespace))
+	}
+	// All controllers share the maintenance mode, which the AgentConfig can
+	// enable too.
+	maintenance := claim.NewMaintenanceSwitch(a.Maintenance)
+	opts = append(opts, claim.WithMaintenanceSwitch(maintenance))
+	if a.RemoteEventMessages {
+		opts = append(opts, claim.WithRemoteEventMessages(claim.NewRemoteEventReader(remoteClient, claim.WithEventMaxAge(a.RemoteEventMaxAge))))
+	}
+	// The connection secrets that change in the remote cluster are propagated
+	// the same way as the ones propagated while syncing the claims.
+	secretOpts := []claim.ConnectionSecretPropagatorOption{claim.WithSecretNamespace(a.SecretNamespace)}
+	if a.SecretMerge {
+		merge := claim.WithSecretMerge()
+		opts = append(opts, claim.WithConnectionSecretPropagatorOptions(merge))
+		secretOpts = append(secretOpts, merge)
+	}
+	if a.SecretReadyCondition {
+		ready := claim.WithSecretReadyCondition(a.SecretRequiredKeys...)
+		opts = append(opts, claim.WithConnectionSecretPropagatorOptions(ready))
+		secretOpts = append(secretOpts, ready)
+	}
+	if a.RemoteSecretNamePath != "" {
+		from := claim.WithRemoteSecretNameFrom(a.RemoteSecretNamePath)
+		opts = append(opts, claim.WithConnectionSecretPropagatorOptions(from))
+		secretOpts = append(secretOpts, from)
+	}
+	for k, v := range a.SecretKeyValidators {
+		validate := claim.WithSecretKeyValidation(k, v)
+		opts = append(opts, claim.WithConnectionSecretPropagatorOptions(validate))
+		secretOpts = append(secretOpts, validate)
+	}
+	if a.Origin != (claim.Origin{}) {
+		origin := claim.WithSecretOriginLabels(a.Origin)
+		opts = append(opts, claim.WithOriginLabels(a.Origin), claim.WithConnectionSecretPropagatorOptions(origin))
+		secretOpts = append(secretOpts, origin)
+	}
+	// The validation and the pruning share the cached remote schemas. They're
+	// read from the remote cluster of ClusterConfig only, so they cannot be
+	// used with NamespaceConfigs.
+	schemas := claim.NewSchemaValidator(dc, clusterRemoteClient)
+	if a.PruneUnknownFields {
+		opts = append(opts, claim.WithSchemaPruning(schemas))
+	}
+	if a.ValidateSchema {
+		opts = append(opts, claim.WithSchemaValidation(schemas))
+	}
+	if a.UnknownFieldPolicy != "" && a.UnknownFieldPolicy != claim.UnknownFieldPolicyPropagate {
+		var f claim.UnknownFieldFinder = schemas
+		if len(a.KnownFields) > 0 {
+			f = claim.KnownFields(a.KnownFields)
+		}
+		opts = append(opts, claim.WithUnknownFieldPolicy(a.UnknownFieldPolicy, f))
+	}
+	if a.NegotiateVersions {
+		opts = append(opts, claim.WithVersionNegotiation(claim.NewVersionNegotiator(dc)))
+	}
+	if a.StatusName != "" {
+		p := claim.NewAgentStatusPublisher(mgr.GetClient(), a.StatusName, claim.DefaultSyncTracker(), capabilities,
+			claim.WithPublishInterval(a.StatusInterval),
+			claim.WithAgentStatusPublisherLogger(log),
+			claim.WithAgentStatusPublisherMaintenance(maintenance))
+		if err := mgr.Add(p); err != nil {
+			return errors.Wrap(err, "cannot add agent status publisher to the manager")
+		}
+	}
+	managed := claim.NewManagedKinds()
+	if a.DebugEndpoint {
+		results := claim.NewLastResults(claim.DefaultLastResultsSize)
+		if err := mgr.AddMetricsExtraHandler("/debug/claims", claim.NewTokenAuthHandler(a.DebugEndpointToken, claim.NewLastResultsHandler(results))); err != nil {
+			return errors.Wrap(err, "cannot add debug handler")
+		}
+		opts = append(opts, claim.WithLastResults(results))
+		if err := mgr.AddMetricsExtraHandler("/inventory", claim.NewTokenAuthHandler(a.DebugEndpointToken, claim.NewInventoryHandler(mgr.GetAPIReader(), finalizer, managed))); err != nil {
+			return errors.Wrap(err, "cannot add inventory handler")
+		}
+	}
+
+	claimRemoteClient, remoteCache, err := startup.NewNamespacedClient(a.ClusterConfig, mgr.GetScheme(), a.RemoteNamespaces)
+	if err != nil {
+		return errors.Wrap(err, "cannot create namespaced cluster remote client")
+	}
+	if err := mgr.Add(remoteCache); err != nil {
+		return errors.Wrap(err, "cannot add remote cache to the manager")
+	}
+	secretWatchOpts := []claim.SecretReconcilerOption{
+		claim.WithSecretReconcilerGVKAliases(a.GVKAliases),
+		claim.WithSecretReconcilerMaintenance(maintenance),
+		claim.WithSecretPropagatorOptions(secretOpts...),
+	}
+	if a.WatchRemoteSecrets {
+		// The remote cluster of ClusterConfig has the connection secrets of
+		// the claims of the namespaces that aren't mapped to another one.
+		if err := claim.SetupSecretWatch(mgr, remoteCache, claimRemoteClient, log, append(secretWatchOpts, claim.WithSecretReconcilerNamespaces(func(ns string) bool {
+			_, mapped := a.NamespaceConfigs[ns]
+			return !mapped
+		}))...); err != nil {
+			return errors.Wrap(err, "cannot setup remote connection secret watch")
+		}
+	}
+	if len(a.NamespaceConfigs) > 0 {
+		remotes := make(map[string]client.Client, len(a.NamespaceConfigs))
+		for ns, cfg := range a.NamespaceConfigs {
+			c, cc, err := startup.NewNamespacedClient(cfg, mgr.GetScheme(), a.RemoteNamespaces)
+			if err != nil {
+				return errors.Wrapf(err, "cannot create namespaced remote client for namespace %s", ns)
+			}
+			if err := mgr.Add(cc); err != nil {
+				return errors.Wrapf(err, "cannot add remote cache of namespace %s to the manager", ns)
+			}
+			remotes[ns] = c
+			if !a.WatchRemoteSecrets {
+				continue
+			}
+			ns := ns
+			if err := claim.SetupNamedSecretWatch("RemoteConnectionSecrets-"+ns, mgr, cc, c, log, append(secretWatchOpts, claim.WithSecretReconcilerNamespaces(func(n string) bool {
+				return n == ns
+			}))...); err != nil {
+				return errors.Wrapf(err, "cannot setup remote connection secret watch for namespace %s", ns)
+			}
+		}
+		claimRemoteClient = claim.NewRemoteClientSelector(claimRemoteClient, claim.WithNamespaceRemotes(remotes))
+	}
+
+	xrdOpts := []xrd.ReconcilerOption{
+		xrd.WithClaimRemoteClient(claimRemoteClient),
+		xrd.WithClaimReconcilerOptions(append(opts, a.ReconcilerOptions...)...),
+		xrd.WithRemoteKindChecker(xrd.NewDiscoveryKindChecker(dc, a.GVKAliases)),
+		xrd.WithMaintenanceSwitch(maintenance),
+		xrd.WithManagedKinds(managed),
+	}
+	if len(a.PriorityLabels) > 0 {
+		xrdOpts = append(xrdOpts, xrd.WithClaimEventHandler(claim.NewPriorityHandler(labels.SelectorFromSet(a.PriorityLabels), claim.WithPriorityDelay(a.PriorityDelay))))
+	}
+	if a.ConfigName != "" {
+		xrdOpts = append(xrdOpts, xrd.WithConfigLoader(claim.NewConfigLoader(mgr.GetClient(), a.ConfigName, dc,
+			claim.WithConfigScopeOptions(scopeOpts...),
+			claim.WithConfigMaintenanceSwitch(maintenance),
+		)))
+	}
+
 	// TODO(muvaf): Need to pass in the default config.
-	if err := xrd.Setup(mgr, clusterRemoteClient, log); err != nil {
+	if err := xrd.Setup(mgr, clusterRemoteClient, log, xrdOpts...); err != nil {
 		return errors.Wrap(err, "cannot setup CompositeResourceDefinition reconciler")
 	}
 
-	return errors.Wrap(mgr.Start(ctrl.SetupSignalHandler()), "cannot start controller manager")
+	return startup.Start(mgr, ctrl.SetupSignalHandler(), a.CacheSyncTimeout)
 }
@@ -17,19 +17,27 @@ limitations under the License.
 package main
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/alecthomas/kingpin.v2"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 
 	"github.com/crossplane/agent/cmd/agent/local"
 	"github.com/crossplane/agent/cmd/agent/remote"
+	"github.com/crossplane/agent/pkg/controllers/claim"
+	"github.com/crossplane/agent/pkg/credentials"
 )
 
 func main() {
@@ -41,6 +49,93 @@ func main() {
 	s := app.Command("sync", "Start syncing to Crossplane.").Default()
 	csa := s.Flag("cluster-kubeconfig", "File path of the kubeconfig of ServiceAccount to be used to get cluster-scoped resources like CRDs.").Envar("CLUSTER_KUBECONFIG").String()
 	dsa := s.Flag("default-kubeconfig", "File path of the  kubeconfig of ServiceAccount to be used for all namespaces that do not have override annotations.").Envar("DEFAULT_KUBECONFIG").String()
+	nsa := s.Flag("namespace-kubeconfig", "File path of the kubeconfig of the remote cluster to sync the claims of the given local namespace with instead of the one of --cluster-kubeconfig, which the claims of all other namespaces are synced with. Can be repeated. The remote clusters must serve the same kinds of claims as the one of --cluster-kubeconfig, which the CRDs are read from, so this cannot be used with the flags that read the CRDs or the versions served by the remote cluster: --validate-schema, --prune-unknown-fields, --negotiate-remote-version, and an --unknown-field-policy without --known-field.").PlaceHolder("NAMESPACE=FILE").StringMap()
+	remoteServerName := s.Flag("remote-tls-server-name", "TLS server name to present and verify the certificate of the remote API server against, e.g. when it sits behind a shared ingress that routes on SNI. The host of the kubeconfig is used if not given.").String()
+	remoteHost := s.Flag("remote-host-header", "Host header to send to the remote API server, e.g. when it sits behind a shared ingress that routes on it. The host of the kubeconfig is used if not given.").PlaceHolder("HOST[:PORT]").String()
+	remoteMaxIdle := s.Flag("remote-max-idle-connections", "Maximum number of idle connections to the remote API server that are kept open for reuse, per kubeconfig. Zero keeps the default of client-go, which is 25.").Default("0").Int()
+	remoteMaxOpen := s.Flag("remote-max-open-connections", "Maximum number of connections to the remote API server that are open at a time, per kubeconfig. The requests beyond that wait for a connection to be free. Zero means no limit.").Default("0").Int()
+	remoteIdleTimeout := s.Flag("remote-idle-connection-timeout", "How long an idle connection to the remote API server is kept open before it's closed. Zero keeps the default of client-go, which is 90s.").Default("0s").Duration()
+	cacheSyncTimeout := s.Flag("cache-sync-timeout", "How long to wait for the caches to sync at startup before giving up. Zero means no timeout.").Default("2m").Duration()
+	healthProbeAddress := s.Flag("health-probe-address", "Address to serve the readiness probe of the local mode on at /readyz, e.g. :8082. The agent is ready once its local cache is synced. No probe is served if not given.").String()
+	remoteNamespaces := s.Flag("remote-namespace", "Namespace of the remote cluster whose claims and connection secrets should be cached. Can be repeated. All namespaces are cached if not given.").Strings()
+	remoteDefaultNamespace := s.Flag("remote-default-namespace", "Namespace of the remote claims whose kind is cluster-scoped in the local cluster but namespaced in the remote cluster.").String()
+	allowedRemoteNamespaces := s.Flag("allowed-remote-namespace", "Namespace of the remote cluster that claims may be written to. Can be repeated. The claims that would be written to any other namespace, e.g. because of a mistaken namespace mapping, are refused. All namespaces are allowed if not given.").Strings()
+	clusterID := s.Flag("cluster-id", "ID of the local cluster. All namespaced remote claims are written to the namespace cluster-ID of the remote cluster, which is created if it doesn't exist, e.g. when the remote cluster is a hub of many local clusters. Implies --detect-name-collisions, since the claims of the same name in different namespaces would share their remote claim.").String()
+	remoteEventMessages := s.Flag("remote-event-messages", "Surface the latest warning event of each remote claim, e.g. CannotSelectComposition, in the AgentSynced condition of its local claim.").Bool()
+	remoteEventMaxAge := s.Flag("remote-event-max-age", "How long ago a warning event of a remote claim may have last occurred to be surfaced with --remote-event-messages. Zero means any age.").Default("1h").Duration()
+	debugEndpoint := s.Flag("debug-endpoint", "Serve the last reconcile result of each claim at /debug/claims, and the inventory of the synced claims at /inventory, of the metrics server.").Bool()
+	debugEndpointTokenFile := s.Flag("debug-endpoint-token-file", "File with the bearer token that the requests to the --debug-endpoint must carry in their Authorization header. The endpoint is served to anyone who can reach the metrics server if not given.").ExistingFile()
+	maintenance := s.Flag("maintenance", "Write nothing to the local or remote cluster, e.g. during a maintenance window of either, while still reporting the claims as not synced with the Maintenance reason. The AgentConfig can enable it without a restart too.").Bool()
+	watchRemoteSecrets := s.Flag("watch-remote-secrets", "Propagate the connection secrets as soon as they change in the remote cluster, e.g. when they're rotated.").Bool()
+	propagateSpec := s.Flag("propagate-spec", "Push the local claims to the remote cluster.").Default("true").Bool()
+	propagateStatus := s.Flag("propagate-status", "Propagate the status of the remote claims to the local ones.").Default("true").Bool()
+	propagateStatusFor := s.Flag("propagate-status-for", "Whether to propagate the status of the remote claims of a local kind in Kind.version.group form, overriding --propagate-status, e.g. MySQLInstance.v1alpha1.example.org=false. Can be repeated.").PlaceHolder("KIND=BOOL").StringMap()
+	propagatorOrder := s.Flag("propagate-step", "Step of the propagation of the remote claims to the local ones, one of LateInit, Status and ConnectionSecret, in the order they should run in, e.g. LateInit then ConnectionSecret for the connection secrets to be there before the claims are reported Ready. Can be repeated. The steps that are not given run after the given ones in the order LateInit, Status, ConnectionSecret.").Strings()
+	propagateSecret := s.Flag("propagate-secret", "Propagate the connection secrets of the remote claims to the local cluster.").Default("true").Bool()
+	lateInit := s.Flag("late-init", "Late-initialize the spec of the local claims with the values of the remote ones.").Default("true").Bool()
+	lateInitAttempts := s.Flag("late-init-max-attempts", "Maximum number of attempts to update a local claim with late-initialized values when it conflicts with other writers.").Default("5").Int()
+	secretGetAttempts := s.Flag("secret-get-max-attempts", "Maximum number of attempts to get a remote connection secret when the remote cluster fails to return it.").Default("1").Int()
+	retryBudget := s.Flag("reconcile-retry-budget", "Maximum number of retries, e.g. of conflicting late-initializations or failing connection secret reads, a single reconciliation of a claim makes in total before it's requeued. Zero means no limit.").Default("0").Int()
+	remoteDefaults := s.Flag("remote-defaulted-field", "Path of a claim field, e.g. spec.parameters.storageClass, that is defaulted by the admission webhooks of the remote cluster. Its remote value is late-initialized rather than overwritten. Can be repeated.").Strings()
+	reflectedAnnotations := s.Flag("reflect-annotation", "Key of an annotation of the remote claims, e.g. one with cost or usage data, that is mirrored to the local claims as read-only information. Can be repeated.").Strings()
+	policyAnnotations := s.Flag("policy-annotation", "Key of an annotation in the crossplane.io domain, which controls how Crossplane reconciles the remote claims, that is passed through from the local claims, e.g. crossplane.io/paused to pause the remote claims along with the local ones. Can be repeated. They're removed from the remote claims once the local claims no longer have them. The other annotations of the local claims are pushed too, except the ones Crossplane records its progress with on the remote claims.").Default(claim.AnnotationKeyPaused).Strings()
+	inputSecretRefs := s.Flag("input-secret-ref", "Path of a secret reference in the claims, e.g. spec.forProvider.passwordSecretRef, whose local secret is mirrored to the remote cluster before the remote claim is written. Can be repeated.").Strings()
+	allowedInputSecretNamespaces := s.Flag("allowed-input-secret-namespace", "Local namespace whose secrets any claim may refer to with --input-secret-ref. Can be repeated. Claims may only refer to the secrets in their own namespace if not given.").Strings()
+	referenceRewrites := s.Flag("rewrite-reference", "Path of a reference of the claims to another local object and its kind in Kind.version.group form, e.g. spec.networkRef=Network.v1alpha1.example.org, that is rewritten to refer to the remote object of the referred one, e.g. in its mapped namespace. Can be repeated.").PlaceHolder("PATH=KIND").StringMap()
+	templateValues := s.Flag("template-values", "Local ConfigMap whose data the templates in the remote claims are rendered with, e.g. {{ .Values.region }}.").PlaceHolder("NAMESPACE/NAME").String()
+	templateFields := s.Flag("template-field", "Path of a string field of the claims, e.g. spec.parameters.region, that is rendered as a Go template with the --template-values before the remote claim is written. Can be repeated.").Strings()
+	maxLabels := s.Flag("max-propagated-labels", "Maximum number of labels of a local claim to propagate to its remote claim, e.g. to guard against a controller that keeps adding them. Zero means no limit.").Default("0").Int()
+	maxAnnotations := s.Flag("max-propagated-annotations", "Maximum number of annotations of a local claim to propagate to its remote claim. Zero means no limit.").Default("0").Int()
+	maxMetadataBytes := s.Flag("max-propagated-metadata-bytes", "Maximum total size of the keys and values of the labels, and separately of the annotations, of a local claim to propagate to its remote claim. Zero means no limit.").Default("0").Int()
+	metadataLimitPolicy := s.Flag("metadata-limit-policy", "What to do with the local claims whose labels or annotations exceed the limits. Truncate propagates only as many of them as the limits allow, in the order of their keys, while Error refuses to write their remote claims.").Default(string(claim.MetadataLimitPolicyTruncate)).Enum(string(claim.MetadataLimitPolicyTruncate), string(claim.MetadataLimitPolicyError))
+	remoteLabels := s.Flag("remote-label", "Label to add to all remote claims, e.g. to identify the tenant of the agent. Can be repeated.").PlaceHolder("KEY=VALUE").StringMap()
+	remoteAnnotations := s.Flag("remote-annotation", "Annotation to add to all remote claims. Can be repeated.").PlaceHolder("KEY=VALUE").StringMap()
+	injectProviderConfig := s.Flag("inject-provider-config", "Name of the provider config to refer to in the remote claims that don't refer to any. Nothing is injected if not given.").String()
+	managementPolicies := s.Flag("management-policy", "Management policy to set in the remote claims that have none, e.g. Observe for a read-only remote cluster. Can be repeated. Nothing is set if not given.").Strings()
+	forceManagementPolicies := s.Flag("force-management-policies", "Set the --management-policy in all remote claims, overriding the management policies of the local or remote claims.").Bool()
+	detectCollisions := s.Flag("detect-name-collisions", "Scan the local claims of each kind before syncing any of them and refuse to sync the ones that would be synced to the same remote claim, e.g. because of the namespace mappings, rather than letting them overwrite each other.").Bool()
+	configName := s.Flag("config-name", "Name of the AgentConfig that configures the claim syncing. Its settings override the flags and are applied without a restart. No AgentConfig is read if not given.").String()
+	postApplyVerify := s.Flag("post-apply-verify", "Read the remote claims back after writing them and report if their spec was altered, e.g. by a mutating admission webhook. Warn only reports it while Fail also retries the claim. Nothing is verified if not given.").Enum(string(claim.VerifyModeWarn), string(claim.VerifyModeFail))
+	secretNamespace := s.Flag("secret-namespace", "Namespace to write all local connection secrets to, e.g. for centralized access control. They're named after the namespace of their claim and their own name, e.g. my-ns.my-secret, and labelled with the name and namespace of their claim. They're written to the namespace of their claim if not given.").String()
+	secretMerge := s.Flag("secret-merge", "Merge the keys of the remote connection secrets into the existing local ones instead of replacing them, e.g. to preserve the keys other controllers add to the local ones. The remote value wins for a key that both have.").Bool()
+	secretReadyCondition := s.Flag("secret-ready-condition", "Report whether the connection secrets of the claims are propagated with all of the --secret-required-key keys in their ConnectionSecretReady condition, e.g. for their consumers to wait for.").Bool()
+	secretRequiredKeys := s.Flag("secret-required-key", "Key the connection secrets of the claims must have to be reported as ready, e.g. password. Can be repeated. The secrets only need to be propagated if not given.").Strings()
+	remoteSecretNamePath := s.Flag("remote-secret-name-path", "Field path of the remote claims to read the name of their connection secret from, e.g. status.connectionSecretName, when the remote cluster writes it to a generated name. The name in writeConnectionSecretToRef of the remote claim is used while it's not set.").String()
+	secretKeyFormats := s.Flag("secret-key-format", "Format the value of a key of the connection secrets must have to be propagated, e.g. endpoint=URL. The formats are URL, Port and NonEmpty. Can be repeated.").PlaceHolder("KEY=FORMAT").StringMap()
+	originCluster := s.Flag("origin-cluster", "Name of the local cluster to label the remote claims and the mirrored connection and input secrets with, e.g. for the dashboards of the remote cluster to group them by origin. The --cluster-id is used if not given.").String()
+	originRegion := s.Flag("origin-region", "Region of the local cluster to label the remote claims and the mirrored secrets with.").String()
+	originEnvironment := s.Flag("origin-environment", "Environment of the local cluster, e.g. production, to label the remote claims and the mirrored secrets with.").String()
+	kindAliases := s.Flag("kind-alias", "Kind the remote claims of a local kind are served as, both in Kind.version.group form, e.g. MySQLInstance.v1alpha1.example.org=MySQLInstanceRequirement.v1alpha1.example.org. Can be repeated.").PlaceHolder("LOCAL=REMOTE").StringMap()
+	serverSideApply := s.Flag("server-side-apply", "Write the remote claims with server-side apply so that the fields removed from the local claims are removed from the remote ones too.").Bool()
+	mergePatch := s.Flag("merge-patch", "Write the remote claims with the minimal JSON merge patch from their current state so that the remote claims that are up to date are not written, e.g. for kinds without strategic merge metadata. Cannot be used with --server-side-apply.").Bool()
+	migrateFieldManagers := s.Flag("migrate-field-manager", "Field manager whose fields of the remote claims are taken over by the agent, together with their last applied configuration annotation, before it server-side applies them, e.g. agent for the fields it wrote before server-side apply was enabled. Can be repeated. Nothing is migrated if not given.").Strings()
+	remoteDeletionPolicy := s.Flag("remote-deletion-policy", "What to do with the remote claims of the deleted local claims. Delete deletes them once the deletion grace period is over, while Abandon keeps them and stops managing them.").Default(string(claim.RemoteDeletionPolicyDelete)).Enum(string(claim.RemoteDeletionPolicyDelete), string(claim.RemoteDeletionPolicyAbandon))
+	deletionGracePeriod := s.Flag("deletion-grace-period", "How long to keep the remote claims of the deleted local claims before deleting them, e.g. to be able to undo an accidental deletion. The local claims are released once their remote claims are deleted.").Default("0s").Duration()
+	deletionConfirmation := s.Flag("deletion-confirmation-condition", "Type of the condition a remote claim must report as True while it's being deleted, e.g. to confirm that the resources backing it are gone, before the agent releases the local claim. The local claim is released as soon as the remote claim is gone if not given.").String()
+	writeCooldown := s.Flag("remote-write-cooldown", "Minimum interval between two writes of the same remote claim unless what's written changes, e.g. to keep from fighting over a claim that something in the remote cluster keeps reverting. Zero means no cooldown.").Default("0s").Duration()
+	validateSchema := s.Flag("validate-schema", "Validate the claims against the OpenAPI schema of their CRD in the remote cluster before writing them, and report the invalid fields in their AgentSynced condition. Requires get access to the CustomResourceDefinitions of the remote cluster.").Bool()
+	pruneUnknownFields := s.Flag("prune-unknown-fields", "Remove the fields of the claims that the OpenAPI schema of their CRD in the remote cluster does not define before writing them, e.g. during a version skew, rather than failing to write them. Requires get access to the CustomResourceDefinitions of the remote cluster.").Bool()
+	unknownFieldPolicy := s.Flag("unknown-field-policy", "What to do with the fields of the claims that the remote cluster doesn't know. Propagate writes them as they are, Strip removes them and Error refuses to write the claims that have them. The fields are known if they're given with --known-field, or otherwise if the OpenAPI schema of their CRD in the remote cluster defines them. Reading the schemas requires get access to the CustomResourceDefinitions of the remote cluster.").Default(string(claim.UnknownFieldPolicyPropagate)).Enum(string(claim.UnknownFieldPolicyPropagate), string(claim.UnknownFieldPolicyStrip), string(claim.UnknownFieldPolicyError))
+	knownFields := s.Flag("known-field", "Path of a field of the claims, e.g. spec.forProvider.size, that the remote cluster knows, together with the fields within it, for the --unknown-field-policy. Can be repeated.").Strings()
+	negotiateVersions := s.Flag("negotiate-remote-version", "Read and write the remote claims at the version the remote cluster prefers if it doesn't serve the version of the local claims, e.g. while their CRD is being upgraded. They're read and written at the version of the local claims otherwise so that the conversion webhook of the remote cluster converts them.").Bool()
+	dryRun := s.Flag("remote-dry-run", "Write the remote claims with server-side dry-run before writing them for real, so that the admission webhooks of the remote cluster can reject them without any of them being written. The rejections are reported in the AgentSynced condition of the local claims.").Bool()
+	auditLog := s.Flag("audit-log", "File to append a JSON line to for every change the agent applies to a remote claim, with the fields it changed, e.g. for compliance. - writes to stdout. No changes are recorded if not given.").PlaceHolder("PATH").String()
+	minCrossplaneVersion := s.Flag("remote-crossplane-version-min", "Lowest version of Crossplane, e.g. v0.13.0, the remote cluster has to run for the claims to be synced. The version is not checked if neither bound is given.").String()
+	maxCrossplaneVersion := s.Flag("remote-crossplane-version-max", "Version of Crossplane, e.g. v1.0.0, that the remote cluster has to run a lower version than for the claims to be synced.").String()
+	crossplaneVersionConfigMap := s.Flag("remote-crossplane-version-configmap", "ConfigMap of the remote cluster to read the version of Crossplane from when it's checked. The version is read from the image tag of --remote-crossplane-deployment if it doesn't exist.").Default("crossplane-system/crossplane-version").PlaceHolder("NAMESPACE/NAME").String()
+	crossplaneVersionKey := s.Flag("remote-crossplane-version-key", "Key of the --remote-crossplane-version-configmap whose value is the version of Crossplane.").Default("version").String()
+	crossplaneDeployment := s.Flag("remote-crossplane-deployment", "Deployment of the remote cluster that runs Crossplane, whose image tag is the version of Crossplane if there's no --remote-crossplane-version-configmap.").Default("crossplane-system/crossplane").PlaceHolder("NAMESPACE/NAME").String()
+	staleAfter := s.Flag("stale-after", "How long a claim may go without being synced successfully before its AgentSynced condition is marked Unknown with the Stale reason, e.g. for dashboards to flag the stuck claims. Claims are never marked stale if zero.").Default("0s").Duration()
+	priorityLabels := s.Flag("priority-label", "Label of the claims of high priority, e.g. agent.crossplane.io/priority=high, which are enqueued ahead of the others on a best-effort basis while many claims are waiting to be synced, e.g. after the agent starts. Claims that are being deleted are never held back. Can be repeated, in which case the claims need all of the labels. All claims are enqueued in the order of their events if not given.").PlaceHolder("KEY=VALUE").StringMap()
+	priorityDelay := s.Flag("priority-delay", "How long the claims that are not of high priority are held back while many claims are waiting to be synced, if --priority-label is given.").Default(claim.DefaultPriorityDelay.String()).Duration()
+	desiredSpecAnnotation := s.Flag("desired-spec-annotation", "Show the spec computed for each remote claim on its local claim with the "+claim.AnnotationKeyDesiredSpec+" annotation, for troubleshooting only.").Bool()
+	desiredSpecMaxBytes := s.Flag("desired-spec-max-bytes", "Maximum size of the JSON of the spec shown with --desired-spec-annotation. Larger specs are omitted.").Default("16384").Int()
+	adoptLegacy := s.Flag("adopt-legacy-remotes", "Adopt the remote claims that were written by the versions of the agent that didn't record their local claim, i.e. the unmanaged remote claims that have all the labels, at least one, and the spec of their local claim, without the "+claim.AnnotationKeyAdopt+" annotation. Use --no-adopt-legacy-remotes to adopt only the claims that allow it.").Default("true").Bool()
+	skipFinalizer := s.Flag("skip-finalizer", "Neither add a finalizer to the local claims nor delete their remote claims once they're deleted, e.g. when an external system garbage collects the remote claims, so that the deletion of the local claims is never blocked.").Bool()
+	previousFinalizers := s.Flag("previous-finalizer", "Finalizer that the agent added to the local claims before it was configured with --finalizer. It's replaced with the --finalizer, and removed along with it, so that the claims finalized with it can still be deleted. Can be repeated. "+claim.DefaultFinalizer+" is always considered a previous finalizer.").Strings()
+	finalizer := s.Flag("finalizer", "Finalizer to add to the local claims the agent syncs, as DOMAIN/NAME, e.g. to fit the naming conventions of an organization or to avoid colliding with other controllers.").Default(claim.DefaultFinalizer).String()
+	statusName := s.Flag("status-name", "Name of the AgentStatus to publish the number of synced, failed and paused claims and the remote connectivity to, e.g. as a health signal for GitOps tools. No AgentStatus is published if not given.").String()
+	statusInterval := s.Flag("status-interval", "How often the AgentStatus is updated.").Default("1m").Duration()
 	mode := s.Flag("mode", "The mode of operation to decide whether you would like to run the controllers that watch the local cluster or the remote cluster.").Enum("local", "remote")
 
 	kingpin.MustParse(app.Parse(os.Args[1:]))
@@ -59,17 +154,234 @@ func main() {
 	if err != nil {
 		kingpin.FatalUsage("could not parse cluster kubeconfig %s", *csa)
 	}
+	// The remote API server may sit behind a shared ingress that needs to be
+	// told which backend to route to. We validate the overrides before doing
+	// anything else so that a typo fails at startup rather than on every call.
+	defaultConfig, err = credentials.OverrideServer(defaultConfig, *remoteServerName, *remoteHost)
+	kingpin.FatalIfError(err, "cannot override server of default kubeconfig")
+	clusterConfig, err = credentials.OverrideServer(clusterConfig, *remoteServerName, *remoteHost)
+	kingpin.FatalIfError(err, "cannot override server of cluster kubeconfig")
+	// The remote credentials may be rotated while the agent is running.
+	defaultConfig, err = credentials.Rotating(defaultConfig)
+	kingpin.FatalIfError(err, "cannot configure credential rotation for default kubeconfig")
+	clusterConfig, err = credentials.Rotating(clusterConfig)
+	kingpin.FatalIfError(err, "cannot configure credential rotation for cluster kubeconfig")
+	// All clients built from a kubeconfig share a bounded pool of connections
+	// rather than each opening their own, if any of the limits is given.
+	defaultConfig, err = credentials.Pooled(defaultConfig, *remoteMaxIdle, *remoteMaxOpen, *remoteIdleTimeout)
+	kingpin.FatalIfError(err, "cannot configure connection pool for default kubeconfig")
+	clusterConfig, err = credentials.Pooled(clusterConfig, *remoteMaxIdle, *remoteMaxOpen, *remoteIdleTimeout)
+	kingpin.FatalIfError(err, "cannot configure connection pool for cluster kubeconfig")
+	namespaceConfigs := make(map[string]*rest.Config, len(*nsa))
+	for ns, path := range *nsa {
+		cfg, err := clientcmd.BuildConfigFromFlags("", path)
+		if err != nil {
+			kingpin.FatalUsage("could not parse kubeconfig %s of namespace %s", path, ns)
+		}
+		cfg, err = credentials.OverrideServer(cfg, *remoteServerName, *remoteHost)
+		kingpin.FatalIfError(err, "cannot override server of kubeconfig of namespace %s", ns)
+		cfg, err = credentials.Rotating(cfg)
+		kingpin.FatalIfError(err, "cannot configure credential rotation for kubeconfig of namespace %s", ns)
+		cfg, err = credentials.Pooled(cfg, *remoteMaxIdle, *remoteMaxOpen, *remoteIdleTimeout)
+		kingpin.FatalIfError(err, "cannot configure connection pool for kubeconfig of namespace %s", ns)
+		namespaceConfigs[ns] = cfg
+	}
 	duration, _ := time.ParseDuration("1h")
 	switch *mode {
 	case "local":
+		statusKinds, err := claim.ParseKindSwitches(*propagateStatusFor)
+		kingpin.FatalIfError(err, "cannot parse kinds to propagate the status of")
+		order, err := claim.ParsePropagatorOrder(*propagatorOrder...)
+		kingpin.FatalIfError(err, "cannot parse propagator order")
+		opts := []claim.ReconcilerOption{
+			claim.WithSpecPropagation(*propagateSpec),
+			claim.WithLegacyAdoption(*adoptLegacy),
+			claim.WithPropagatorOrder(order...),
+			claim.WithStatusPropagation(*propagateStatus),
+			claim.WithStatusPropagationFor(statusKinds),
+			claim.WithSecretPropagation(*propagateSecret),
+			claim.WithLateInitialization(*lateInit),
+			claim.WithLateInitializerOptions(claim.WithConflictRetries(*lateInitAttempts)),
+			claim.WithConnectionSecretPropagatorOptions(claim.WithSecretGetRetries(*secretGetAttempts, claim.DefaultConflictBackoff())),
+			claim.WithRetryBudget(*retryBudget),
+			claim.WithRemoteDefaultedFields(*remoteDefaults...),
+			claim.WithInputSecretRefs(*inputSecretRefs...),
+			claim.WithAllowedInputSecretNamespaces(*allowedInputSecretNamespaces...),
+			claim.WithReflectedAnnotations(*reflectedAnnotations...),
+			claim.WithPolicyAnnotationPassthrough(*policyAnnotations...),
+			claim.WithPostApplyVerify(claim.VerifyMode(*postApplyVerify)),
+			claim.WithWriteCooldown(*writeCooldown),
+			claim.WithRemoteDeletionPolicy(claim.RemoteDeletionPolicy(*remoteDeletionPolicy)),
+			claim.WithDeletionGracePeriod(*deletionGracePeriod),
+			claim.WithStaleAfter(*staleAfter),
+		}
+		if len(*remoteLabels) > 0 || len(*remoteAnnotations) > 0 {
+			opts = append(opts, claim.WithRemoteMetadata(*remoteLabels, *remoteAnnotations))
+		}
+		if len(*referenceRewrites) > 0 {
+			refs, err := claim.ParseReferenceKinds(*referenceRewrites)
+			kingpin.FatalIfError(err, "cannot parse reference rewrites")
+			opts = append(opts, claim.WithReferenceRewrites(refs))
+		}
+		if *injectProviderConfig != "" {
+			opts = append(opts, claim.WithProviderConfigInjection(*injectProviderConfig))
+		}
+		if *maxLabels > 0 || *maxAnnotations > 0 || *maxMetadataBytes > 0 {
+			limits := claim.MetadataLimits{MaxLabels: *maxLabels, MaxAnnotations: *maxAnnotations, MaxBytes: *maxMetadataBytes}
+			opts = append(opts, claim.WithMetadataLimits(limits, claim.MetadataLimitPolicy(*metadataLimitPolicy)))
+		}
+		if *detectCollisions {
+			opts = append(opts, claim.WithCollisionDetection())
+		}
+		if *desiredSpecAnnotation {
+			opts = append(opts, claim.WithDesiredSpecAnnotation(*desiredSpecMaxBytes))
+		}
+		if *skipFinalizer {
+			opts = append(opts, claim.WithoutFinalizer())
+		}
+		if len(*previousFinalizers) > 0 {
+			opts = append(opts, claim.WithPreviousFinalizers(*previousFinalizers...))
+		}
+		if len(*managementPolicies) > 0 {
+			var mpOpts []claim.ManagementPoliciesInjectorOption
+			if *forceManagementPolicies {
+				mpOpts = append(mpOpts, claim.WithForcedManagementPolicies())
+			}
+			opts = append(opts, claim.WithManagementPolicies(*managementPolicies, mpOpts...))
+		}
+		if len(*templateFields) > 0 {
+			ns, name, err := cache.SplitMetaNamespaceKey(*templateValues)
+			if err != nil || name == "" {
+				kingpin.FatalUsage("template values must be given as NAMESPACE/NAME to render template fields")
+			}
+			opts = append(opts, claim.WithValueTemplates(types.NamespacedName{Namespace: ns, Name: name}, *templateFields...))
+		}
+		if *deletionConfirmation != "" {
+			opts = append(opts, claim.WithDeletionConfirmation(v1alpha1.ConditionType(*deletionConfirmation)))
+		}
+		if *serverSideApply && *mergePatch {
+			kingpin.FatalUsage("server-side apply and merge patch cannot be used together")
+		}
+		if *mergePatch {
+			opts = append(opts, claim.WithMergePatch())
+		}
+		if *serverSideApply {
+			opts = append(opts, claim.WithServerSideApply(claim.FieldManager, claim.WithFieldManagerMigration(*migrateFieldManagers...)))
+		}
+		if *dryRun {
+			opts = append(opts, claim.WithDryRun())
+		}
+		switch *auditLog {
+		case "":
+		case "-":
+			opts = append(opts, claim.WithAuditWriter(claim.NewAuditWriter(os.Stdout)))
+		default:
+			f, err := os.OpenFile(filepath.Clean(*auditLog), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			kingpin.FatalIfError(err, "cannot open audit log")
+			defer f.Close() // nolint:errcheck
+			opts = append(opts, claim.WithAuditWriter(claim.NewAuditWriter(f)))
+		}
+		var debugToken string
+		if *debugEndpointTokenFile != "" {
+			b, err := ioutil.ReadFile(filepath.Clean(*debugEndpointTokenFile))
+			kingpin.FatalIfError(err, "cannot read debug endpoint token")
+			debugToken = strings.TrimSpace(string(b))
+		}
+		validators := make(map[string]claim.SecretKeyValidator, len(*secretKeyFormats))
+		for k, f := range *secretKeyFormats {
+			v, err := claim.ParseSecretKeyFormat(f)
+			if err != nil {
+				kingpin.FatalUsage("%s", err)
+			}
+			validators[k] = v
+		}
+		aliases, err := claim.ParseGVKAliases(*kindAliases)
+		kingpin.FatalIfError(err, "cannot parse kind aliases")
+		versions, err := claim.ParseCrossplaneVersionRange(*minCrossplaneVersion, *maxCrossplaneVersion)
+		kingpin.FatalIfError(err, "cannot parse supported versions of Crossplane")
+		vns, vname, err := cache.SplitMetaNamespaceKey(*crossplaneVersionConfigMap)
+		if err != nil || vns == "" || vname == "" {
+			kingpin.FatalUsage("the ConfigMap with the version of Crossplane must be given as NAMESPACE/NAME")
+		}
+		dns, dname, err := cache.SplitMetaNamespaceKey(*crossplaneDeployment)
+		if err != nil || dns == "" || dname == "" {
+			kingpin.FatalUsage("the Deployment of Crossplane must be given as NAMESPACE/NAME")
+		}
+		for _, f := range append([]string{*finalizer}, *previousFinalizers...) {
+			if err := claim.ValidateFinalizer(f); err != nil {
+				kingpin.FatalUsage("%s", err)
+			}
+		}
+		// The schemas and the versions of the claims are read from the remote
+		// cluster of --cluster-kubeconfig only.
+		if len(namespaceConfigs) > 0 {
+			switch {
+			case *validateSchema:
+				kingpin.FatalUsage("--validate-schema cannot be used with --namespace-kubeconfig")
+			case *pruneUnknownFields:
+				kingpin.FatalUsage("--prune-unknown-fields cannot be used with --namespace-kubeconfig")
+			case *negotiateVersions:
+				kingpin.FatalUsage("--negotiate-remote-version cannot be used with --namespace-kubeconfig")
+			case claim.UnknownFieldPolicy(*unknownFieldPolicy) != claim.UnknownFieldPolicyPropagate && len(*knownFields) == 0:
+				kingpin.FatalUsage("--unknown-field-policy cannot be used with --namespace-kubeconfig without --known-field")
+			}
+		}
+		if *clusterID != "" {
+			if err := claim.ValidateClusterID(*clusterID); err != nil {
+				kingpin.FatalUsage("%s", err)
+			}
+		}
+		origin := claim.Origin{Cluster: *originCluster, Region: *originRegion, Environment: *originEnvironment}
+		if origin.Cluster == "" {
+			origin.Cluster = *clusterID
+		}
 		agent := &local.Agent{
-			ClusterConfig: clusterConfig,
-			DefaultConfig: defaultConfig,
+			ClusterConfig:           clusterConfig,
+			DefaultConfig:           defaultConfig,
+			NamespaceConfigs:        namespaceConfigs,
+			CacheSyncTimeout:        *cacheSyncTimeout,
+			HealthProbeAddress:      *healthProbeAddress,
+			RemoteNamespaces:        *remoteNamespaces,
+			AllowedRemoteNamespaces: *allowedRemoteNamespaces,
+			RemoteDefaultNamespace:  *remoteDefaultNamespace,
+			ClusterID:               *clusterID,
+			RemoteEventMessages:     *remoteEventMessages,
+			RemoteEventMaxAge:       *remoteEventMaxAge,
+			DebugEndpoint:           *debugEndpoint,
+			DebugEndpointToken:      debugToken,
+			WatchRemoteSecrets:      *watchRemoteSecrets,
+			Maintenance:             *maintenance,
+			ReconcilerOptions:       opts,
+			ConfigName:              *configName,
+			GVKAliases:              aliases,
+			SecretNamespace:         *secretNamespace,
+			SecretMerge:             *secretMerge,
+			SecretReadyCondition:    *secretReadyCondition,
+			SecretRequiredKeys:      *secretRequiredKeys,
+			RemoteSecretNamePath:    *remoteSecretNamePath,
+			SecretKeyValidators:     validators,
+			Origin:                  origin,
+			PriorityLabels:          *priorityLabels,
+			PriorityDelay:           *priorityDelay,
+			StatusName:              *statusName,
+			StatusInterval:          *statusInterval,
+			ValidateSchema:          *validateSchema,
+			PruneUnknownFields:      *pruneUnknownFields,
+			NegotiateVersions:       *negotiateVersions,
+			Finalizer:               *finalizer,
+			UnknownFieldPolicy:      claim.UnknownFieldPolicy(*unknownFieldPolicy),
+			KnownFields:             *knownFields,
+
+			CrossplaneVersions:         versions,
+			CrossplaneVersionConfigMap: types.NamespacedName{Namespace: vns, Name: vname},
+			CrossplaneVersionKey:       *crossplaneVersionKey,
+			CrossplaneDeployment:       types.NamespacedName{Namespace: dns, Name: dname},
 		}
 		kingpin.FatalIfError(agent.Run(logging.NewLogrLogger(zl.WithName("crossplane-agent")), duration), "cannot run agent in local mode")
 	case "remote":
 		agent := &remote.Agent{
-			ClusterConfig: clusterConfig,
+			ClusterConfig:    clusterConfig,
+			CacheSyncTimeout: *cacheSyncTimeout,
 		}
 		kingpin.FatalIfError(agent.Run(logging.NewLogrLogger(zl.WithName("crossplane-agent")), duration), "cannot run agent in remote mode")
 	}
@@ -31,11 +31,15 @@ import (
 
 	"github.com/crossplane/agent/pkg/controllers/apiextensions"
 	"github.com/crossplane/agent/pkg/controllers/crd"
+	"github.com/crossplane/agent/pkg/startup"
 )
 
 // Agent configures & starts the manager that is watching the remote cluster.
 type Agent struct {
 	ClusterConfig *rest.Config
+	// CacheSyncTimeout is how long to wait for the caches to sync before
+	// giving up on starting. Zero means no timeout.
+	CacheSyncTimeout time.Duration
 }
 
 // Run adds all controllers and starts the manager that watches the remote cluster.
@@ -70,5 +74,5 @@ func (a *Agent) Run(log logging.Logger, period time.Duration) error {
 		}
 	}
 
-	return errors.Wrap(mgr.Start(ctrl.SetupSignalHandler()), "cannot start controller manager")
+	return startup.Start(mgr, ctrl.SetupSignalHandler(), a.CacheSyncTimeout)
 }
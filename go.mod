@@ -7,6 +7,7 @@ require (
 	github.com/crossplane/crossplane-runtime v0.9.1-0.20200831142237-1576699ee9ac
 	github.com/google/go-cmp v0.4.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.1.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 	k8s.io/api v0.18.6
 	k8s.io/apiextensions-apiserver v0.18.6
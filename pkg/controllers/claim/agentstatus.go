@@ -0,0 +1,161 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/agent/apis/agent/v1alpha1"
+)
+
+const (
+	errGetAgentStatus    = "cannot get agent status"
+	errCreateAgentStatus = "cannot create agent status"
+	errUpdateAgentStatus = "cannot update agent status"
+
+	defaultPublishInterval = 1 * time.Minute
+)
+
+// A RemoteChecker checks whether the remote cluster can be synced with.
+type RemoteChecker interface {
+	Check() error
+}
+
+// An AgentStatusPublisherOption configures an AgentStatusPublisher.
+type AgentStatusPublisherOption func(*AgentStatusPublisher)
+
+// WithPublishInterval specifies how often the AgentStatusPublisher should
+// update the AgentStatus.
+func WithPublishInterval(d time.Duration) AgentStatusPublisherOption {
+	return func(p *AgentStatusPublisher) {
+		p.interval = d
+	}
+}
+
+// WithAgentStatusPublisherLogger specifies how the AgentStatusPublisher should
+// log messages.
+func WithAgentStatusPublisherLogger(l logging.Logger) AgentStatusPublisherOption {
+	return func(p *AgentStatusPublisher) {
+		p.log = l
+	}
+}
+
+// WithAgentStatusPublisherMaintenance makes the AgentStatusPublisher publish
+// nothing while the supplied MaintenanceSwitch is enabled, since the agent
+// writes nothing but the sync conditions of the claims then.
+func WithAgentStatusPublisherMaintenance(s *MaintenanceSwitch) AgentStatusPublisherOption {
+	return func(p *AgentStatusPublisher) {
+		p.maintenance = s
+	}
+}
+
+// NewAgentStatusPublisher returns a new *AgentStatusPublisher that maintains
+// the AgentStatus with the given name using the counts of the supplied
+// SyncTracker and the connectivity reported by the supplied RemoteChecker.
+func NewAgentStatusPublisher(kube client.Client, name string, t *SyncTracker, rc RemoteChecker, opts ...AgentStatusPublisherOption) *AgentStatusPublisher {
+	p := &AgentStatusPublisher{
+		kube:     kube,
+		name:     name,
+		tracker:  t,
+		remote:   rc,
+		interval: defaultPublishInterval,
+		log:      logging.NewNopLogger(),
+		now:      time.Now,
+	}
+	for _, f := range opts {
+		f(p)
+	}
+	return p
+}
+
+// An AgentStatusPublisher periodically writes the aggregated sync state of the
+// agent to an AgentStatus. It's meant to be added to the manager as a
+// Runnable.
+type AgentStatusPublisher struct {
+	kube     client.Client
+	name     string
+	tracker  *SyncTracker
+	remote   RemoteChecker
+	interval time.Duration
+	log      logging.Logger
+	now      func() time.Time
+
+	maintenance *MaintenanceSwitch
+}
+
+// Start publishes the AgentStatus right away and then periodically until the
+// supplied channel is closed. Failures are only logged since the next attempt
+// will publish an up to date status anyway.
+func (p *AgentStatusPublisher) Start(stop <-chan struct{}) error {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := p.Publish(ctx); err != nil {
+			p.log.Info("Cannot publish agent status", "error", err)
+		}
+		cancel()
+		select {
+		case <-stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Publish creates or updates the AgentStatus with the current sync state, unless
+// the agent is in maintenance mode.
+func (p *AgentStatusPublisher) Publish(ctx context.Context) error {
+	if p.maintenance.Enabled() {
+		return nil
+	}
+	s := &v1alpha1.AgentStatus{}
+	err := p.kube.Get(ctx, types.NamespacedName{Name: p.name}, s)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, errGetAgentStatus)
+	}
+	exists := err == nil
+
+	s.SetName(p.name)
+	s.Status = p.status()
+	if !exists {
+		return errors.Wrap(p.kube.Create(ctx, s), errCreateAgentStatus)
+	}
+	return errors.Wrap(p.kube.Update(ctx, s), errUpdateAgentStatus)
+}
+
+func (p *AgentStatusPublisher) status() v1alpha1.AgentStatusStatus {
+	now := metav1.NewTime(p.now())
+	s := v1alpha1.AgentStatusStatus{
+		Claims:         p.tracker.Counts(),
+		Remote:         v1alpha1.RemoteStatus{Connected: true},
+		LastUpdateTime: &now,
+	}
+	if err := p.remote.Check(); err != nil {
+		s.Remote = v1alpha1.RemoteStatus{Connected: false, Message: err.Error()}
+	}
+	return s
+}
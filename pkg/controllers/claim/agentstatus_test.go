@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/apis/agent/v1alpha1"
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+type remoteCheckFn func() error
+
+func (fn remoteCheckFn) Check() error { return fn() }
+
+func TestAgentStatusPublisherPublish(t *testing.T) {
+	published := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	withStatus := func(connected bool, msg string) *v1alpha1.AgentStatus {
+		s := &v1alpha1.AgentStatus{}
+		s.SetName("cool-agent")
+		ts := metav1.NewTime(published)
+		s.Status = v1alpha1.AgentStatusStatus{
+			Claims:         v1alpha1.ClaimCounts{Total: 2, Synced: 1, Failed: 1},
+			Remote:         v1alpha1.RemoteStatus{Connected: connected, Message: msg},
+			LastUpdateTime: &ts,
+		}
+		return s
+	}
+	type args struct {
+		getErr      error
+		writeErr    error
+		remote      error
+		maintenance bool
+	}
+	type want struct {
+		created *v1alpha1.AgentStatus
+		updated *v1alpha1.AgentStatus
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Created": {
+			reason: "The agent status should be created if it does not exist",
+			args: args{
+				getErr: kerrors.NewNotFound(schema.GroupResource{}, ""),
+			},
+			want: want{
+				created: withStatus(true, ""),
+			},
+		},
+		"Updated": {
+			reason: "The agent status should be updated if it exists",
+			want: want{
+				updated: withStatus(true, ""),
+			},
+		},
+		"Disconnected": {
+			reason: "The remote cluster should be reported as disconnected if it cannot be synced with",
+			args: args{
+				remote: errBoom,
+			},
+			want: want{
+				updated: withStatus(false, errBoom.Error()),
+			},
+		},
+		"Maintenance": {
+			reason: "The agent status should not be written in maintenance mode",
+			args: args{
+				maintenance: true,
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the agent status cannot be fetched",
+			args: args{
+				getErr: errBoom,
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetAgentStatus),
+			},
+		},
+		"UpdateFailed": {
+			reason: "An error should be returned if the agent status cannot be updated",
+			args: args{
+				writeErr: errBoom,
+			},
+			want: want{
+				updated: withStatus(true, ""),
+				err:     errors.Wrap(errBoom, errUpdateAgentStatus),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created, updated *v1alpha1.AgentStatus
+			kube := &test.MockClient{
+				MockGet: test.NewMockGetFn(tc.args.getErr),
+				MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+					created = obj.(*v1alpha1.AgentStatus)
+					return tc.args.writeErr
+				},
+				MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+					updated = obj.(*v1alpha1.AgentStatus)
+					return tc.args.writeErr
+				},
+			}
+			tr := NewSyncTracker(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}))
+			tr.Observe("a", resource.AgentSyncSuccess())
+			tr.Observe("b", resource.AgentSyncError(errBoom))
+			p := NewAgentStatusPublisher(kube, "cool-agent", tr, remoteCheckFn(func() error { return tc.args.remote }),
+				WithAgentStatusPublisherMaintenance(NewMaintenanceSwitch(tc.args.maintenance)))
+			p.now = func() time.Time { return published }
+
+			err := p.Publish(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\np.Publish(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\nReason: %s\ncreated: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.updated, updated); diff != "" {
+				t.Errorf("\nReason: %s\nupdated: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	errParseKindFmt   = "cannot parse %q as Kind.version.group"
+	errParseSwitchFmt = "cannot parse %q of kind %s as true or false"
+)
+
+// GVKAliases maps the kinds of the local instances to the kinds their remote
+// instances are served as, e.g. during a rename of the kind in the remote
+// cluster. The kinds that are not mapped are the same in both clusters. The
+// remote instances keep the names of the local ones regardless.
+type GVKAliases map[schema.GroupVersionKind]schema.GroupVersionKind
+
+// ParseGVKAliases parses the supplied map of local kinds to remote kinds, both
+// in Kind.version.group form, e.g. MySQLInstance.v1alpha1.example.org.
+func ParseGVKAliases(m map[string]string) (GVKAliases, error) {
+	a := make(GVKAliases, len(m))
+	for l, r := range m {
+		lgvk, err := parseGVK(l)
+		if err != nil {
+			return nil, err
+		}
+		rgvk, err := parseGVK(r)
+		if err != nil {
+			return nil, err
+		}
+		a[lgvk] = rgvk
+	}
+	return a, nil
+}
+
+// ParseKindSwitches parses the supplied map of kinds in Kind.version.group form
+// to whether something is enabled for them, e.g. MySQLInstance.v1alpha1.example.org=false.
+func ParseKindSwitches(m map[string]string) (map[schema.GroupVersionKind]bool, error) {
+	out := make(map[schema.GroupVersionKind]bool, len(m))
+	for k, v := range m {
+		gvk, err := parseGVK(k)
+		if err != nil {
+			return nil, err
+		}
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.Errorf(errParseSwitchFmt, v, k)
+		}
+		out[gvk] = enabled
+	}
+	return out, nil
+}
+
+func parseGVK(s string) (schema.GroupVersionKind, error) {
+	gvk, _ := schema.ParseKindArg(s)
+	if gvk == nil {
+		return schema.GroupVersionKind{}, errors.Errorf(errParseKindFmt, s)
+	}
+	return *gvk, nil
+}
+
+// Remote returns the kind the remote instances of the supplied local kind are
+// served as.
+func (a GVKAliases) Remote(local schema.GroupVersionKind) schema.GroupVersionKind {
+	if r, ok := a[local]; ok {
+		return r
+	}
+	return local
+}
+
+// Local returns the kind of the local instances whose remote instances are of
+// the supplied kind.
+func (a GVKAliases) Local(remote schema.GroupVersionKind) schema.GroupVersionKind {
+	for l, r := range a {
+		if r == remote {
+			return l
+		}
+	}
+	return remote
+}
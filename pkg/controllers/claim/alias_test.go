@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var aliasedGVK = schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "DatabaseRequirement"}
+
+func TestParseGVKAliases(t *testing.T) {
+	type want struct {
+		a   GVKAliases
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		m      map[string]string
+		want   want
+	}{
+		"Valid": {
+			reason: "The local and remote kinds should be parsed",
+			m:      map[string]string{"Database.v1.example.org": "DatabaseRequirement.v1.example.org"},
+			want: want{
+				a: GVKAliases{scopedGVK: aliasedGVK},
+			},
+		},
+		"Invalid": {
+			reason: "An error should be returned if a kind is not fully qualified",
+			m:      map[string]string{"Database": "DatabaseRequirement.v1.example.org"},
+			want: want{
+				err: errors.Errorf(errParseKindFmt, "Database"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a, err := ParseGVKAliases(tc.m)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nParseGVKAliases(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.a, a); diff != "" {
+				t.Errorf("\nReason: %s\nParseGVKAliases(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseKindSwitches(t *testing.T) {
+	type want struct {
+		m   map[schema.GroupVersionKind]bool
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		m      map[string]string
+		want   want
+	}{
+		"Valid": {
+			reason: "The kinds and whether they're enabled should be parsed",
+			m:      map[string]string{"Database.v1.example.org": "false", "DatabaseRequirement.v1.example.org": "true"},
+			want: want{
+				m: map[schema.GroupVersionKind]bool{scopedGVK: false, aliasedGVK: true},
+			},
+		},
+		"InvalidKind": {
+			reason: "An error should be returned if a kind is not fully qualified",
+			m:      map[string]string{"Database": "false"},
+			want: want{
+				err: errors.Errorf(errParseKindFmt, "Database"),
+			},
+		},
+		"InvalidSwitch": {
+			reason: "An error should be returned if a value is neither true nor false",
+			m:      map[string]string{"Database.v1.example.org": "maybe"},
+			want: want{
+				err: errors.Errorf(errParseSwitchFmt, "maybe", "Database.v1.example.org"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := ParseKindSwitches(tc.m)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nParseKindSwitches(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.m, m); diff != "" {
+				t.Errorf("\nReason: %s\nParseKindSwitches(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGVKAliases(t *testing.T) {
+	other := schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Bucket"}
+	a := GVKAliases{scopedGVK: aliasedGVK}
+	cases := map[string]struct {
+		reason string
+		got    schema.GroupVersionKind
+		want   schema.GroupVersionKind
+	}{
+		"RemoteAliased": {
+			reason: "The remote kind of an aliased local kind should be its alias",
+			got:    a.Remote(scopedGVK),
+			want:   aliasedGVK,
+		},
+		"RemoteNotAliased": {
+			reason: "The remote kind of a local kind without an alias should be the same",
+			got:    a.Remote(other),
+			want:   other,
+		},
+		"LocalAliased": {
+			reason: "The local kind of an alias should be the kind it aliases",
+			got:    a.Local(aliasedGVK),
+			want:   scopedGVK,
+		},
+		"LocalNotAliased": {
+			reason: "The local kind of a remote kind that is not an alias should be the same",
+			got:    a.Local(other),
+			want:   other,
+		},
+		"NoAliases": {
+			reason: "Kinds should be the same in both clusters if there are no aliases",
+			got:    GVKAliases(nil).Remote(scopedGVK),
+			want:   scopedGVK,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.got); diff != "" {
+				t.Errorf("\nReason: %s\n-want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -17,14 +17,22 @@ limitations under the License.
 package claim
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 
 	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
@@ -36,6 +44,38 @@ import (
 	"github.com/crossplane/agent/pkg/resource"
 )
 
+// A Filter returns true if the supplied local claim should be propagated.
+type Filter func(local *claim.Unstructured) bool
+
+// NewFieldValueFilter returns a Filter that passes only the claims whose field
+// at given path is a string equal to one of the given values.
+func NewFieldValueFilter(path string, values ...string) Filter {
+	return func(local *claim.Unstructured) bool {
+		v, err := fieldpath.Pave(local.GetUnstructured().UnstructuredContent()).GetString(path)
+		if err != nil {
+			return false
+		}
+		for _, val := range values {
+			if v == val {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// defaultStoreConfig is the name of the StoreConfig that publishes connection
+// details to Kubernetes secrets.
+const defaultStoreConfig = "default"
+
+// ConfigureFn is used to construct a Configurator with a bare function.
+type ConfigureFn func(ctx context.Context, local, remote *claim.Unstructured) error
+
+// Configure calls the supplied function.
+func (c ConfigureFn) Configure(ctx context.Context, local, remote *claim.Unstructured) error {
+	return c(ctx, local, remote)
+}
+
 // PropagateFn is used to construct a Propagator with a bare function.
 type PropagateFn func(ctx context.Context, local, remote *claim.Unstructured) error
 
@@ -63,73 +103,642 @@ func (pp PropagatorChain) Propagate(ctx context.Context, local, remote *claim.Un
 }
 
 // NewDefaultConfigurator returns a new DefaultConfigurator.
-func NewDefaultConfigurator() *DefaultConfigurator {
-	return &DefaultConfigurator{}
+func NewDefaultConfigurator(p FieldPolicies, opts ...DefaultConfiguratorOption) *DefaultConfigurator {
+	c := &DefaultConfigurator{policies: p, metrics: defaultPolicyMetrics}
+	WithPolicyAnnotations(DefaultPolicyAnnotations()...)(c)
+	WithRemoteOwnedAnnotations(DefaultRemoteOwnedAnnotations()...)(c)
+	for _, f := range opts {
+		f(c)
+	}
+	return c
+}
+
+// A DefaultConfiguratorOption configures a DefaultConfigurator.
+type DefaultConfiguratorOption func(*DefaultConfigurator)
+
+// WithPolicyAnnotations specifies the policy annotations, i.e. the ones in the
+// PolicyAnnotationDomain, that are passed through from the local instance to
+// the remote one, e.g. to pause the remote instance along with the local one.
+// They're passed through even if they're remote-owned, and removed from the
+// remote instance once the local one no longer has them. The default is
+// DefaultPolicyAnnotations.
+func WithPolicyAnnotations(keys ...string) DefaultConfiguratorOption {
+	return func(c *DefaultConfigurator) {
+		c.passthrough = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			c.passthrough[k] = true
+		}
+	}
+}
+
+// WithRemoteOwnedAnnotations specifies the annotations that keep their remote
+// values. All other annotations of the local instance are pushed. The default
+// is DefaultRemoteOwnedAnnotations.
+func WithRemoteOwnedAnnotations(keys ...string) DefaultConfiguratorOption {
+	return func(c *DefaultConfigurator) {
+		c.remoteOwned = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			c.remoteOwned[k] = true
+		}
+	}
+}
+
+// WithConfiguratorPolicyMetrics specifies how the DefaultConfigurator counts
+// the pushed fields whose remote values it changes. The default is the metrics
+// registered with the controller-runtime registry.
+func WithConfiguratorPolicyMetrics(m *PolicyMetrics) DefaultConfiguratorOption {
+	return func(c *DefaultConfigurator) {
+		c.metrics = m
+	}
 }
 
 // DefaultConfigurator configures ObjectMeta and Spec of the remote instance with
 // the information from the local instance.
-type DefaultConfigurator struct{}
+type DefaultConfigurator struct {
+	policies    FieldPolicies
+	passthrough map[string]bool
+	remoteOwned map[string]bool
+	metrics     *PolicyMetrics
+}
 
-// Configure copies spec and user-defined metadata from local object to the remote one.
+// Configure copies spec and user-defined metadata from local object to the remote
+// one and marks the remote object as managed by the local one. The fields that
+// are not owned by the local object according to the field policies keep their
+// values in the remote object. The status is dropped so that writing the remote
+// object never touches its status, even if its kind has no status subresource.
 func (sp *DefaultConfigurator) Configure(_ context.Context, local, remote *claim.Unstructured) error {
+	delete(remote.Object, "status")
+	lp := fieldpath.Pave(local.GetUnstructured().UnstructuredContent())
+	rp := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	// The values are resolved before the metadata is overwritten so that the
+	// remote-owned annotations and labels keep their remote values.
+	pushed := map[string]interface{}{}
+	observed := map[string]interface{}{}
+	paths := sp.policies.Paths()
+	for _, path := range paths {
+		if v, ok := sp.policies.ForPush(path, lp, rp); ok {
+			pushed[path] = v
+		}
+		if v, ok := getValue(rp, path); ok {
+			observed[path] = v
+		}
+	}
+	// The remote instance is always named after the local one, even if the
+	// local one was created with generateName.
 	remote.SetName(local.GetName())
+	remote.SetGenerateName("")
 	remote.SetNamespace(local.GetNamespace())
-	remote.SetAnnotations(local.GetAnnotations())
-	remote.SetLabels(local.GetLabels())
-	spec, err := fieldpath.Pave(local.GetUnstructured().UnstructuredContent()).GetValue("spec")
+	remote.SetLabels(mergeLabels(local, remote))
+	remote.SetAnnotations(sp.annotations(local, remote))
+	meta.AddAnnotations(remote, localIdentity(local))
+	if keys := sortedKeys(local.GetLabels()); len(keys) > 0 {
+		meta.AddAnnotations(remote, map[string]string{AnnotationKeyManagedLabels: strings.Join(keys, ",")})
+	}
+	spec, err := lp.GetValue("spec")
 	if err != nil {
 		return err
 	}
-	err = fieldpath.Pave(remote.GetUnstructured().UnstructuredContent()).SetValue("spec", spec)
-	return err
+	if err := rp.SetValue("spec", runtime.DeepCopyJSONValue(spec)); err != nil {
+		return err
+	}
+	if managed := leafPaths("spec", spec); len(managed) > 0 {
+		b, err := json.Marshal(managed)
+		if err != nil {
+			return err
+		}
+		meta.AddAnnotations(remote, map[string]string{AnnotationKeyManagedSpec: string(b)})
+	}
+	if entries := sp.mergedEntries(lp, paths); len(entries) > 0 {
+		b, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		meta.AddAnnotations(remote, map[string]string{AnnotationKeyManagedEntries: string(b)})
+	}
+	for _, path := range paths {
+		// Only the values that differ from the observed remote ones are
+		// counted, which the pulled values never do.
+		current, exists := observed[path]
+		v, ok := pushed[path]
+		if ok != exists || (ok && !jsonEqual(current, v)) {
+			sp.metrics.Observe(path, DirectionPush)
+		}
+		if !ok {
+			if err := deleteValue(rp, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := rp.SetValue(path, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergedEntries returns the entries of the local instance that are pushed to
+// the arrays at the supplied paths with FieldPolicyMerge, by their path.
+func (sp *DefaultConfigurator) mergedEntries(local *fieldpath.Paved, paths []string) map[string][]interface{} {
+	entries := map[string][]interface{}{}
+	for _, path := range paths {
+		if sp.policies[path] != FieldPolicyMerge {
+			continue
+		}
+		v, _ := getValue(local, path)
+		if arr, ok := v.([]interface{}); ok && len(arr) > 0 {
+			entries[path] = arr
+		}
+	}
+	return entries
+}
+
+// annotations returns the annotations of the remote instance, which are the
+// ones of the local instance except for the remote-owned annotations that are
+// not passed through. Those keep their remote values instead. The desired spec
+// annotation of the local instance is never propagated.
+func (sp *DefaultConfigurator) annotations(local, remote *claim.Unstructured) map[string]string {
+	var out map[string]string
+	set := func(k, v string) {
+		if out == nil {
+			out = map[string]string{}
+		}
+		out[k] = v
+	}
+	owned := func(k string) bool { return sp.remoteOwned[k] && !sp.passthrough[k] }
+	for k, v := range remote.GetAnnotations() {
+		if owned(k) {
+			set(k, v)
+		}
+	}
+	for k, v := range local.GetAnnotations() {
+		if owned(k) || k == AnnotationKeyDesiredSpec {
+			continue
+		}
+		set(k, v)
+	}
+	return out
+}
+
+// mergeLabels returns the labels of the remote instance, which are the ones of
+// the local instance merged into the ones added in the remote cluster, e.g. by
+// Crossplane. The labels the agent pushed before according to
+// AnnotationKeyManagedLabels are removed if the local instance no longer has
+// them. The labels of a remote instance that has no record of them yet are
+// considered added in the remote cluster.
+func mergeLabels(local, remote *claim.Unstructured) map[string]string {
+	managed := managedLabels(remote)
+	var out map[string]string
+	set := func(k, v string) {
+		if out == nil {
+			out = map[string]string{}
+		}
+		out[k] = v
+	}
+	for k, v := range remote.GetLabels() {
+		if !managed[k] {
+			set(k, v)
+		}
+	}
+	for k, v := range local.GetLabels() {
+		set(k, v)
+	}
+	return out
+}
+
+// localIdentity returns the annotations that record the supplied local instance
+// on its remote instance. The namespace of a cluster scoped local instance is
+// not recorded.
+func localIdentity(local *claim.Unstructured) map[string]string {
+	a := map[string]string{AnnotationKeyLocalUID: string(local.GetUID())}
+	if local.GetName() != "" {
+		a[AnnotationKeyLocalName] = local.GetName()
+	}
+	if local.GetNamespace() != "" {
+		a[AnnotationKeyLocalNamespace] = local.GetNamespace()
+	}
+	return a
+}
+
+// managedLabels returns the keys of the labels that the agent pushed to the
+// supplied remote instance according to its AnnotationKeyManagedLabels.
+func managedLabels(remote *claim.Unstructured) map[string]bool {
+	managed := map[string]bool{}
+	if v := remote.GetAnnotations()[AnnotationKeyManagedLabels]; v != "" {
+		for _, k := range strings.Split(v, ",") {
+			managed[k] = true
+		}
+	}
+	return managed
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NewConfiguratorChain returns a new ConfiguratorChain.
+func NewConfiguratorChain(c ...Configurator) ConfiguratorChain {
+	return ConfiguratorChain(c)
+}
+
+// ConfiguratorChain calls Configure method of all of its Configurators in order.
+type ConfiguratorChain []Configurator
+
+// Configure calls all Configure functions one by one.
+func (cc ConfiguratorChain) Configure(ctx context.Context, local, remote *claim.Unstructured) error {
+	for _, c := range cc {
+		if err := c.Configure(ctx, local, remote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const fieldProviderConfigName = "spec.providerConfigRef.name"
+
+// ProviderConfigDefaulterOption configures a ProviderConfigDefaulter.
+type ProviderConfigDefaulterOption func(*ProviderConfigDefaulter)
+
+// WithProviderConfigPlaceholders makes the ProviderConfigDefaulter treat the
+// given provider config names in the local instance as if they were absent.
+func WithProviderConfigPlaceholders(names ...string) ProviderConfigDefaulterOption {
+	return func(d *ProviderConfigDefaulter) {
+		d.placeholders = append(d.placeholders, names...)
+	}
+}
+
+// WithForcedProviderConfig makes the ProviderConfigDefaulter override the
+// provider config name even if the local instance has an explicit value.
+func WithForcedProviderConfig() ProviderConfigDefaulterOption {
+	return func(d *ProviderConfigDefaulter) {
+		d.force = true
+	}
+}
+
+// NewProviderConfigDefaulter returns a new ProviderConfigDefaulter that uses
+// the given name as the provider config of the remote cluster.
+func NewProviderConfigDefaulter(name string, opts ...ProviderConfigDefaulterOption) *ProviderConfigDefaulter {
+	d := &ProviderConfigDefaulter{name: name}
+	for _, f := range opts {
+		f(d)
+	}
+	return d
+}
+
+// ProviderConfigDefaulter sets spec.providerConfigRef.name of the remote
+// instance to the provider config of the remote cluster. An explicit value in
+// the local instance wins unless the override is forced.
+type ProviderConfigDefaulter struct {
+	name         string
+	placeholders []string
+	force        bool
+}
+
+// Configure sets the provider config name of the remote instance if the local
+// instance does not have one, has a placeholder or the override is forced.
+func (d *ProviderConfigDefaulter) Configure(_ context.Context, local, remote *claim.Unstructured) error {
+	if !d.force && !d.isAbsent(local) {
+		return nil
+	}
+	return fieldpath.Pave(remote.GetUnstructured().UnstructuredContent()).SetValue(fieldProviderConfigName, d.name)
+}
+
+func (d *ProviderConfigDefaulter) isAbsent(local *claim.Unstructured) bool {
+	v, err := fieldpath.Pave(local.GetUnstructured().UnstructuredContent()).GetString(fieldProviderConfigName)
+	if err != nil || v == "" {
+		return true
+	}
+	for _, p := range d.placeholders {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMetadataInjector returns a new MetadataInjector that injects the given
+// labels and annotations.
+func NewMetadataInjector(labels, annotations map[string]string) *MetadataInjector {
+	return &MetadataInjector{labels: labels, annotations: annotations}
+}
+
+// MetadataInjector stamps a static set of labels and annotations, e.g. the
+// tenant of the agent in a multi-tenant remote cluster, onto all remote
+// instances. Unlike DefaultConfigurator, it doesn't copy them from the local
+// instance; they're known only to the agent.
+type MetadataInjector struct {
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// Configure adds the labels and annotations to the remote instance. They win
+// over the ones with the same keys copied from the local instance.
+func (m *MetadataInjector) Configure(_ context.Context, _, remote *claim.Unstructured) error {
+	meta.AddLabels(remote, m.labels)
+	meta.AddAnnotations(remote, m.annotations)
+	return nil
+}
+
+const fieldProviderConfigRef = "spec.providerConfigRef"
+
+// NewProviderConfigInjector returns a new ProviderConfigInjector that injects
+// the provider config with the given name.
+func NewProviderConfigInjector(name string) *ProviderConfigInjector {
+	return &ProviderConfigInjector{name: name}
+}
+
+// ProviderConfigInjector injects a reference to a default provider config to
+// the remote instances that don't refer to any, so that they're not rejected
+// by the remote kinds that require one. Unlike ProviderConfigDefaulter, it
+// never touches a reference that exists, even if it's incomplete.
+type ProviderConfigInjector struct {
+	name string
+}
+
+// Configure sets spec.providerConfigRef of the remote instance if it's
+// entirely absent.
+func (i *ProviderConfigInjector) Configure(_ context.Context, _, remote *claim.Unstructured) error {
+	p := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	if _, err := p.GetValue(fieldProviderConfigRef); !fieldpath.IsNotFound(err) {
+		return err
+	}
+	return p.SetValue(fieldProviderConfigRef, map[string]interface{}{"name": i.name})
+}
+
+const fieldManagementPolicies = "spec.managementPolicies"
+
+// ManagementPoliciesInjectorOption configures a ManagementPoliciesInjector.
+type ManagementPoliciesInjectorOption func(*ManagementPoliciesInjector)
+
+// WithForcedManagementPolicies makes the ManagementPoliciesInjector override
+// the management policies of the remote instance even if it already has some,
+// e.g. to keep a read-only remote cluster from ever changing the resources.
+func WithForcedManagementPolicies() ManagementPoliciesInjectorOption {
+	return func(i *ManagementPoliciesInjector) {
+		i.force = true
+	}
+}
+
+// NewManagementPoliciesInjector returns a new ManagementPoliciesInjector that
+// injects the given management policies, e.g. Observe.
+func NewManagementPoliciesInjector(policies []string, opts ...ManagementPoliciesInjectorOption) *ManagementPoliciesInjector {
+	i := &ManagementPoliciesInjector{policies: policies}
+	for _, f := range opts {
+		f(i)
+	}
+	return i
+}
+
+// ManagementPoliciesInjector sets spec.managementPolicies of the remote
+// instances, which the remote kinds of newer Crossplane versions may require.
+// It runs after the DefaultConfigurator, so the value the field policies give
+// the remote instance, i.e. the local one if it's pushed or the remote one if
+// it's pulled, wins unless the override is forced.
+type ManagementPoliciesInjector struct {
+	policies []string
+	force    bool
+}
+
+// Configure sets the management policies of the remote instance if it has
+// none or the override is forced.
+func (i *ManagementPoliciesInjector) Configure(_ context.Context, _, remote *claim.Unstructured) error {
+	p := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	if _, ok := getValue(p, fieldManagementPolicies); ok && !i.force {
+		return nil
+	}
+	v := make([]interface{}, len(i.policies))
+	for j, mp := range i.policies {
+		v[j] = mp
+	}
+	return p.SetValue(fieldManagementPolicies, v)
+}
+
+// A Rewrite replaces all matches of a regular expression with a replacement,
+// which can refer to the submatches as in regexp.Regexp.ReplaceAllString.
+type Rewrite struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// NewFieldRewriter returns a new FieldRewriter that applies the given rewrites
+// in order to the string fields at the given paths.
+func NewFieldRewriter(paths []string, rw ...Rewrite) *FieldRewriter {
+	return &FieldRewriter{paths: paths, rewrites: rw}
+}
+
+// FieldRewriter rewrites string fields of the remote instance, e.g. to replace
+// the registry host of the container images with one that is reachable from
+// the remote environment.
+type FieldRewriter struct {
+	paths    []string
+	rewrites []Rewrite
+}
+
+// Configure rewrites the fields of the remote instance. Fields that do not
+// exist or are not strings are skipped.
+func (fr *FieldRewriter) Configure(_ context.Context, _, remote *claim.Unstructured) error {
+	rp := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	for _, path := range fr.paths {
+		v, err := rp.GetString(path)
+		if err != nil {
+			continue
+		}
+		for _, rw := range fr.rewrites {
+			v = rw.Pattern.ReplaceAllString(v, rw.Replacement)
+		}
+		if err := rp.SetValue(path, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A LateInitializerOption configures a LateInitializer.
+type LateInitializerOption func(*LateInitializer)
+
+// WithConflictRetries makes the LateInitializer make up to the given number of
+// attempts in total when the update of the local object fails due to a
+// conflict. The local object is fetched again and late-initialized from
+// scratch before each retry.
+func WithConflictRetries(attempts int) LateInitializerOption {
+	return func(li *LateInitializer) {
+		li.attempts = attempts
+	}
+}
+
+// WithConflictBackoff specifies how long the LateInitializer waits before each
+// retry of a conflicting update. The default is DefaultConflictBackoff.
+func WithConflictBackoff(b wait.Backoff) LateInitializerOption {
+	return func(li *LateInitializer) {
+		li.backoff = b
+	}
+}
+
+// WithLateInitEqualityFunc specifies how the LateInitializer decides whether
+// the value of a pulled field differs from the one the local object already
+// has. The default is DefaultEqualityFunc.
+func WithLateInitEqualityFunc(eq EqualityFunc) LateInitializerOption {
+	return func(li *LateInitializer) {
+		li.equal = eq
+	}
+}
+
+// WithLateInitPolicyMetrics specifies how the LateInitializer counts the
+// pulled fields whose local values it changes. The default is the metrics
+// registered with the controller-runtime registry.
+func WithLateInitPolicyMetrics(m *PolicyMetrics) LateInitializerOption {
+	return func(li *LateInitializer) {
+		li.metrics = m
+	}
+}
+
+// DefaultConflictBackoff returns the default backoff between the retries of a
+// conflicting update, which is jittered and capped.
+func DefaultConflictBackoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: 100 * time.Millisecond,
+		Factor:   2,
+		Jitter:   0.5,
+		Steps:    5,
+		Cap:      2 * time.Second,
+	}
 }
 
 // NewLateInitializer returns a new LateInitializer.
-func NewLateInitializer(kube client.Client) *LateInitializer {
-	return &LateInitializer{localClient: kube}
+func NewLateInitializer(kube client.Client, p FieldPolicies, opts ...LateInitializerOption) *LateInitializer {
+	li := &LateInitializer{localClient: kube, policies: p, attempts: 1, backoff: DefaultConflictBackoff(), equal: DefaultEqualityFunc, metrics: defaultPolicyMetrics}
+	for _, f := range opts {
+		f(li)
+	}
+	return li
 }
 
 // LateInitializer fills up the empty fields of "desired" object with the values
 // in "observed" object.
 type LateInitializer struct {
 	localClient client.Client
+	policies    FieldPolicies
+	attempts    int
+	backoff     wait.Backoff
+	equal       EqualityFunc
+	metrics     *PolicyMetrics
 }
 
 // Propagate copies the values from observed to desired if that field is empty in
-// desired object.
+// desired object or if the field is owned by the remote object. The update of
+// the local object is retried on conflicts if the LateInitializer is configured
+// to do so, as long as the RetryBudget of the context, if any, allows.
 func (li *LateInitializer) Propagate(ctx context.Context, local, remote *claim.Unstructured) error {
+	return retry(ctx, li.attempts, li.backoff, IsConflict, func(attempt int) error {
+		if attempt > 1 {
+			if err := li.localClient.Get(ctx, types.NamespacedName{Namespace: local.GetNamespace(), Name: local.GetName()}, local); err != nil {
+				return errors.Wrap(err, localPrefix+errGetRequirement)
+			}
+		}
+		return li.propagate(ctx, local, remote)
+	})
+}
+
+func (li *LateInitializer) propagate(ctx context.Context, local, remote *claim.Unstructured) error {
 	// We fill up the missing pieces in our desired state by late initializing.
-	if local.GetCompositionSelector() == nil && remote.GetCompositionSelector() != nil {
-		local.SetCompositionSelector(remote.GetCompositionSelector())
+	lp := fieldpath.Pave(local.GetUnstructured().UnstructuredContent())
+	rp := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	var changed []string
+	for _, path := range li.policies.Paths() {
+		// The fields that are pushed in this reconciliation are never
+		// late-initialized, even if the remote instance has another value.
+		v, ok := li.policies.ForPull(path, lp, rp)
+		if !ok {
+			continue
+		}
+		// Remote-owned fields are pulled in every reconcile, so we update the
+		// local object only if they actually changed in order not to cause an
+		// update loop.
+		if current, exists := getValue(lp, path); exists && li.equal(current, v) {
+			continue
+		}
+		if err := lp.SetValue(path, v); err != nil {
+			return err
+		}
+		changed = append(changed, path)
 	}
-	if local.GetCompositionReference() == nil && remote.GetCompositionReference() != nil {
-		local.SetCompositionReference(remote.GetCompositionReference())
+	if len(changed) == 0 {
+		return nil
 	}
-	if local.GetResourceReference() == nil && remote.GetResourceReference() != nil {
-		local.SetResourceReference(remote.GetResourceReference())
+	// TODO(muvaf): We need to late-init the unknown user-defined fields as well.
+	if err := li.localClient.Update(ctx, local); err != nil {
+		return errors.Wrap(err, localPrefix+errUpdateClaim)
 	}
-	if local.GetWriteConnectionSecretToReference() == nil && remote.GetWriteConnectionSecretToReference() != nil {
-		local.SetWriteConnectionSecretToReference(remote.GetWriteConnectionSecretToReference())
+	for _, path := range changed {
+		li.metrics.Observe(path, DirectionPull)
+	}
+	return nil
+}
+
+const (
+	errGetConditions = "cannot get conditions of claim"
+	errSetConditions = "cannot set conditions of claim"
+)
+
+// A StatusPropagatorOption configures a StatusPropagator.
+type StatusPropagatorOption func(*StatusPropagator)
+
+// WithStatusPath makes the StatusPropagator write the whole status of the
+// remote object into the given path of the local object, e.g. "status.remote",
+// instead of merging the conditions into the top-level status. This is useful
+// when the status of the local object is owned by another controller.
+func WithStatusPath(path string) StatusPropagatorOption {
+	return func(sp *StatusPropagator) {
+		sp.path = path
+	}
+}
+
+// WithStatusEqualityFunc specifies how the StatusPropagator decides whether the
+// status of the remote object differs from the one the local object already
+// has at the path given with WithStatusPath. The default is
+// DefaultEqualityFunc.
+func WithStatusEqualityFunc(eq EqualityFunc) StatusPropagatorOption {
+	return func(sp *StatusPropagator) {
+		sp.equal = eq
 	}
-	// TODO(muvaf): We need to late-init the unknown user-defined fields as well.
-	return errors.Wrap(li.localClient.Update(ctx, local), localPrefix+errUpdateClaim)
 }
 
 // NewStatusPropagator returns a new StatusPropagator.
-func NewStatusPropagator() *StatusPropagator {
-	return &StatusPropagator{}
+func NewStatusPropagator(opts ...StatusPropagatorOption) *StatusPropagator {
+	sp := &StatusPropagator{equal: DefaultEqualityFunc}
+	for _, f := range opts {
+		f(sp)
+	}
+	return sp
 }
 
 // StatusPropagator propagates the status from the second object to the first one.
-type StatusPropagator struct{}
+type StatusPropagator struct {
+	path  string
+	equal EqualityFunc
+}
 
-// Propagate copies the status of remote object into local object.
+// Propagate copies the status of remote object into local object. A status
+// written to a path is left as it is if it's equal to the remote one. The
+// agent cannot convert the status of a remote object that is read at another
+// version than the local one, e.g. one negotiated by a VersionNegotiator, so
+// only its conditions, which all versions share, are copied then.
 func (sp *StatusPropagator) Propagate(ctx context.Context, local, remote *claim.Unstructured) error {
 	status, err := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent()).GetValue("status")
 	if err != nil {
 		return runtimeresource.Ignore(fieldpath.IsNotFound, err)
 	}
+	if sp.path != "" && sameVersion(local, remote) {
+		lp := fieldpath.Pave(local.GetUnstructured().UnstructuredContent())
+		if current, exists := getValue(lp, sp.path); exists && sp.equal(current, status) {
+			return nil
+		}
+		return lp.SetValue(sp.path, runtime.DeepCopyJSONValue(status))
+	}
 	statusJSON, err := json.Marshal(status)
 	if err != nil {
 		return err
@@ -138,14 +747,168 @@ func (sp *StatusPropagator) Propagate(ctx context.Context, local, remote *claim.
 	if err := json.Unmarshal(statusJSON, conditions); err != nil {
 		return err
 	}
-	local.SetConditions(conditions.Conditions...)
 	// TODO(muvaf): Need to propagate other fields as well.
-	return nil
+	return mirrorConditions(local, conditions.Conditions...)
+}
+
+// sameVersion returns true if the supplied objects are of the same version, or
+// if either of them has no version.
+func sameVersion(local, remote *claim.Unstructured) bool {
+	lv, rv := local.GroupVersionKind().Version, remote.GroupVersionKind().Version
+	return lv == "" || rv == "" || lv == rv
+}
+
+// mirrorConditions sets the supplied conditions on the local object as they
+// are. Unlike SetConditions, it doesn't keep the lastTransitionTime of an
+// existing condition that is otherwise equal, so that the local object always
+// reflects when the remote condition transitioned. An error is returned if the
+// existing conditions of the local object cannot be read, so that they're not
+// overwritten.
+func mirrorConditions(local *claim.Unstructured, conditions ...v1alpha1.Condition) error {
+	p := fieldpath.Pave(local.GetUnstructured().UnstructuredContent())
+	cs := v1alpha1.ConditionedStatus{}
+	if err := p.GetValueInto("status", &cs); err != nil && !fieldpath.IsNotFound(err) {
+		return errors.Wrap(err, localPrefix+errGetConditions)
+	}
+	for _, c := range conditions {
+		exists := false
+		for i := range cs.Conditions {
+			if cs.Conditions[i].Type == c.Type {
+				cs.Conditions[i] = c
+				exists = true
+			}
+		}
+		if !exists {
+			cs.Conditions = append(cs.Conditions, c)
+		}
+	}
+	return errors.Wrap(p.SetValue("status.conditions", cs.Conditions), localPrefix+errSetConditions)
+}
+
+// A ConnectionSecretPropagatorOption configures a ConnectionSecretPropagator.
+type ConnectionSecretPropagatorOption func(*ConnectionSecretPropagator)
+
+// WithSecretMerge makes the ConnectionSecretPropagator merge the keys of the
+// remote connection secret into the existing local secret instead of applying
+// the remote secret as is. The keys that exist only in the local secret, e.g.
+// the ones added by other controllers, are preserved. The remote value wins if
+// a key exists in both secrets.
+func WithSecretMerge() ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.merge = true
+	}
+}
+
+// WithSecretNamespace makes the ConnectionSecretPropagator write all local
+// connection secrets to the given namespace instead of the namespace of their
+// local object. The secrets are labelled with the name and namespace of their
+// local object so that consumers can find them. They have no owner reference
+// since it can't cross namespaces, so they should be cleaned up with a
+// ConnectionSecretCleaner configured with the same namespace.
+func WithSecretNamespace(ns string) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.namespace = ns
+	}
+}
+
+// WithConnectionSecretMetrics specifies how the ConnectionSecretPropagator
+// should report the shape of the propagated secrets.
+func WithConnectionSecretMetrics(m *SecretMetrics) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.metrics = m
+	}
+}
+
+// WithSecretReadyCondition makes the ConnectionSecretPropagator report in the
+// ConnectionSecretReady condition of the local objects whether their connection
+// secrets are propagated with all of the given keys, so that their consumers
+// can wait for them before they start. A key with an empty value is missing.
+// The condition only requires the secrets to be propagated if no key is given.
+func WithSecretReadyCondition(keys ...string) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.readyCondition = true
+		csp.requiredKeys = keys
+	}
+}
+
+// A SecretKeyEncryptor encrypts the value of a key of a connection secret
+// before it's written to the local cluster.
+type SecretKeyEncryptor interface {
+	Encrypt(ctx context.Context, key string, value []byte) ([]byte, error)
+}
+
+// A SecretKeyEncryptFn is a function that satisfies SecretKeyEncryptor.
+type SecretKeyEncryptFn func(ctx context.Context, key string, value []byte) ([]byte, error)
+
+// Encrypt the supplied value of the supplied key.
+func (fn SecretKeyEncryptFn) Encrypt(ctx context.Context, key string, value []byte) ([]byte, error) {
+	return fn(ctx, key, value)
+}
+
+// A SecretKeyDecryptor decrypts the value of a key of a local connection
+// secret that was encrypted by a SecretKeyEncryptor.
+type SecretKeyDecryptor interface {
+	Decrypt(ctx context.Context, key string, value []byte) ([]byte, error)
+}
+
+// WithSecretKeyEncryption makes the ConnectionSecretPropagator encrypt the
+// values of the given keys of the remote connection secrets with the given
+// SecretKeyEncryptor before it writes them to the local cluster. The other keys
+// are written as they are. The encrypted keys are listed in the
+// AnnotationKeyEncryptedKeys annotation of the local secrets. Encryption is
+// off by default; once it's on, the consumers of the local secrets can only
+// use the encrypted values if they have a matching decryptor.
+//
+// Most encryption is randomized, so the value of a key would be rewritten on
+// every propagation even if it didn't change. If the SecretKeyEncryptor is a
+// SecretKeyDecryptor too, the encrypted values of the existing local secret
+// that decrypt to the remote values are kept instead.
+func WithSecretKeyEncryption(e SecretKeyEncryptor, keys ...string) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.encryptor = e
+		csp.encryptedKeys = keys
+	}
+}
+
+// WithSecretGetRetries makes the ConnectionSecretPropagator make up to the
+// given number of attempts in total, waiting according to the given backoff
+// between them, when it fails to get a remote connection secret for any reason
+// other than the secret not existing. The retries are bounded by the
+// RetryBudget of the context, if any.
+func WithSecretGetRetries(attempts int, b wait.Backoff) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.getAttempts = attempts
+		csp.getBackoff = b
+	}
+}
+
+// WithRemoteSecretNameFrom makes the ConnectionSecretPropagator read the name
+// of the remote connection secret of writeConnectionSecretToRef from the given
+// field path of the remote objects, e.g. status.connectionSecretName or
+// metadata.annotations[example.org/secret-name], for the remote clusters that
+// write the secrets to a generated name and record it there. The name in the
+// reference of the remote object is used while the field is not set.
+func WithRemoteSecretNameFrom(path string) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.remoteNamePath = path
+	}
+}
+
+// WithSecretOriginLabels makes the ConnectionSecretPropagator add the origin
+// labels of the given Origin to the local connection secrets.
+func WithSecretOriginLabels(o Origin) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		csp.originLabels = o.Labels()
+	}
 }
 
 // NewConnectionSecretPropagator returns a new *ConnectionSecretPropagator.
-func NewConnectionSecretPropagator(local, remote runtimeresource.ClientApplicator) *ConnectionSecretPropagator {
-	return &ConnectionSecretPropagator{localClient: local, remoteClient: remote}
+func NewConnectionSecretPropagator(local, remote runtimeresource.ClientApplicator, opts ...ConnectionSecretPropagatorOption) *ConnectionSecretPropagator {
+	csp := &ConnectionSecretPropagator{localClient: local, remoteClient: remote, metrics: defaultSecretMetrics, getAttempts: 1}
+	for _, f := range opts {
+		f(csp)
+	}
+	return csp
 }
 
 // ConnectionSecretPropagator fetches the connection secret from the remote cluster
@@ -153,33 +916,334 @@ func NewConnectionSecretPropagator(local, remote runtimeresource.ClientApplicato
 type ConnectionSecretPropagator struct {
 	localClient  runtimeresource.ClientApplicator
 	remoteClient runtimeresource.ClientApplicator
+	merge        bool
+	namespace    string
+	metrics      *SecretMetrics
+
+	readyCondition bool
+	requiredKeys   []string
+
+	encryptor     SecretKeyEncryptor
+	encryptedKeys []string
+
+	getAttempts int
+	getBackoff  wait.Backoff
+
+	remoteNamePath string
+
+	validators map[string][]SecretKeyValidator
+
+	originLabels map[string]string
 }
 
-// Propagate propagates the connection secret from remote cluster to local cluster.
+// Propagate propagates the connection secrets from remote cluster to local
+// cluster. Both writeConnectionSecretToRef and publishConnectionDetailsTo are
+// supported and the secrets of both are propagated if both are present. It's a
+// no-op for the local objects that opt out of secret propagation.
 func (csp *ConnectionSecretPropagator) Propagate(ctx context.Context, local, remote *claim.Unstructured) error {
-	if local.GetWriteConnectionSecretToReference() == nil || remote.GetWriteConnectionSecretToReference() == nil {
+	if SkipsSecretPropagation(local) {
 		return nil
 	}
+	referred := false
+	var unavailable []string
+	refs := []struct {
+		local, remote func(*claim.Unstructured) string
+	}{
+		{local: writeConnectionSecretName, remote: csp.remoteWriteConnectionSecretName},
+		{local: publishConnectionDetailsName, remote: publishConnectionDetailsName},
+	}
+	for _, ref := range refs {
+		ln := ref.local(local)
+		if ln == "" {
+			continue
+		}
+		referred = true
+		rn := ref.remote(remote)
+		if rn == "" {
+			unavailable = append(unavailable, fmt.Sprintf(msgSecretNotReferredFmt, ln))
+			continue
+		}
+		why, err := csp.propagate(ctx, local, remote, ln, rn)
+		if err != nil {
+			return err
+		}
+		if why != "" {
+			unavailable = append(unavailable, why)
+		}
+	}
+	if !csp.readyCondition || !referred {
+		return nil
+	}
+	if len(unavailable) > 0 {
+		local.SetConditions(resource.ConnectionSecretUnavailable().WithMessage(strings.Join(unavailable, "; ")))
+		return nil
+	}
+	local.SetConditions(resource.ConnectionSecretAvailable())
+	return nil
+}
+
+// propagate applies the remote connection secret with the given name as the
+// local one with the given name. It returns why the local secret is not ready
+// to be consumed, if it isn't.
+func (csp *ConnectionSecretPropagator) propagate(ctx context.Context, local, remote *claim.Unstructured, localName, remoteName string) (string, error) {
 	// Update the connection secret.
 	rs := &v1.Secret{}
 	rnn := types.NamespacedName{
-		Name:      remote.GetWriteConnectionSecretToReference().Name,
+		Name:      remoteName,
 		Namespace: remote.GetNamespace(),
 	}
-	err := csp.remoteClient.Get(ctx, rnn, rs)
+	isTransient := func(err error) bool { return !kerrors.IsNotFound(err) }
+	err := retry(ctx, csp.getAttempts, csp.getBackoff, isTransient, func(_ int) error {
+		return csp.remoteClient.Get(ctx, rnn, rs)
+	})
 	if runtimeresource.IgnoreNotFound(err) != nil {
-		return errors.Wrap(err, remotePrefix+errGetSecret)
+		return "", errors.Wrap(err, remotePrefix+errGetSecret)
 	}
 	if kerrors.IsNotFound(err) {
-		// TODO(muvaf): Set condition to say waiting for secret.
+		return fmt.Sprintf(msgSecretNotFoundFmt, remoteName), nil
+	}
+	if err := csp.validate(rs); err != nil {
+		return "", err
+	}
+	ls := resource.SanitizedDeepCopyObject(rs).(*v1.Secret)
+	lnn := LocalSecretKey(local, localName, csp.namespace)
+	ls.SetName(lnn.Name)
+	ls.SetNamespace(lnn.Namespace)
+	existing := &v1.Secret{}
+	if _, ok := csp.encryptor.(SecretKeyDecryptor); ok || csp.merge {
+		if err := csp.localClient.Get(ctx, types.NamespacedName{Name: ls.GetName(), Namespace: ls.GetNamespace()}, existing); runtimeresource.IgnoreNotFound(err) != nil {
+			return "", errors.Wrap(err, localPrefix+errGetSecret)
+		}
+	}
+	// The values are encrypted before the merge since the ones of the existing
+	// local secret already are.
+	if err := csp.encrypt(ctx, ls, existing); err != nil {
+		return "", err
+	}
+	if csp.merge {
+		for k, v := range existing.Data {
+			if _, ok := ls.Data[k]; ok {
+				continue
+			}
+			if ls.Data == nil {
+				ls.Data = map[string][]byte{}
+			}
+			ls.Data[k] = v
+		}
+	}
+	meta.AddLabels(ls, map[string]string{
+		LabelKeyManagedBy: LabelValueManagedBy,
+		LabelKeyOwnerUID:  string(local.GetUID()),
+	})
+	meta.AddLabels(ls, csp.originLabels)
+	if lnn.Namespace == local.GetNamespace() {
+		meta.AddOwnerReference(ls, meta.AsController(meta.ReferenceTo(local, local.GroupVersionKind())))
+	} else {
+		meta.AddLabels(ls, map[string]string{
+			LabelKeyClaimName:      local.GetName(),
+			LabelKeyClaimNamespace: local.GetNamespace(),
+		})
+	}
+	owned := mustBeOwnedBy(local.GetUID())
+	err = csp.localClient.Apply(ctx, ls.DeepCopy(), owned, mustHaveSameType)
+	if err == errSecretTypeChanged {
+		// The type of a secret is immutable, so we recreate the local secret to
+		// converge on the type of the remote one.
+		if err := csp.localClient.Delete(ctx, ls.DeepCopy()); runtimeresource.IgnoreNotFound(err) != nil {
+			return "", errors.Wrap(err, localPrefix+errDeleteSecret)
+		}
+		err = csp.localClient.Apply(ctx, ls, owned)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, localPrefix+errApplySecret)
+	}
+	csp.metrics.Observe(local, localName, rs)
+	var missing []string
+	for _, k := range csp.requiredKeys {
+		if len(ls.Data[k]) == 0 {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf(msgSecretMissingKeysFmt, localName, strings.Join(missing, ", ")), nil
+	}
+	return "", nil
+}
+
+// remoteWriteConnectionSecretName returns the name of the remote connection
+// secret of writeConnectionSecretToRef, which is read from the configured
+// field path of the supplied remote object if it's set there.
+func (csp *ConnectionSecretPropagator) remoteWriteConnectionSecretName(remote *claim.Unstructured) string {
+	if csp.remoteNamePath != "" {
+		n, err := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent()).GetString(csp.remoteNamePath)
+		if err == nil && n != "" {
+			return n
+		}
+	}
+	return writeConnectionSecretName(remote)
+}
+
+// encrypt the values of the keys to be encrypted that the supplied secret has,
+// and records them in its AnnotationKeyEncryptedKeys annotation. The encrypted
+// values of the supplied existing secret are kept if they decrypt to the same
+// values.
+func (csp *ConnectionSecretPropagator) encrypt(ctx context.Context, s, existing *v1.Secret) error {
+	if csp.encryptor == nil {
+		return nil
+	}
+	d, _ := csp.encryptor.(SecretKeyDecryptor)
+	was := map[string]bool{}
+	for _, k := range strings.Split(existing.GetAnnotations()[AnnotationKeyEncryptedKeys], ",") {
+		was[k] = true
+	}
+	var encrypted []string
+	for _, k := range csp.encryptedKeys {
+		v, ok := s.Data[k]
+		if !ok {
+			continue
+		}
+		encrypted = append(encrypted, k)
+		if ev, ok := existing.Data[k]; ok && was[k] && d != nil {
+			// A value that cannot be decrypted, e.g. because the key was
+			// rotated, is encrypted again.
+			if dv, err := d.Decrypt(ctx, k, ev); err == nil && bytes.Equal(dv, v) {
+				s.Data[k] = ev
+				continue
+			}
+		}
+		ev, err := csp.encryptor.Encrypt(ctx, k, v)
+		if err != nil {
+			return errors.Wrapf(err, errEncryptSecretKeyFmt, k)
+		}
+		s.Data[k] = ev
+	}
+	if len(encrypted) > 0 {
+		sort.Strings(encrypted)
+		meta.AddAnnotations(s, map[string]string{AnnotationKeyEncryptedKeys: strings.Join(encrypted, ",")})
+	}
+	return nil
+}
+
+// Reasons for a local connection secret not to be ready.
+const (
+	msgSecretNotReferredFmt = "remote claim does not refer to a connection secret for %s yet"
+	msgSecretNotFoundFmt    = "connection secret %s does not exist in the remote cluster yet"
+	msgSecretMissingKeysFmt = "connection secret %s lacks the required keys %s"
+)
+
+var errSecretTypeChanged = errors.New("secret type changed")
+
+const (
+	errEncryptSecretKeyFmt = "cannot encrypt key %s of secret"
+	errSecretOwnedByOther  = "secret exists and belongs to another claim"
+)
+
+// mustBeOwnedBy returns an ApplyOption that refuses to overwrite a secret that
+// the agent created for another local object than the one with the supplied
+// UID.
+func mustBeOwnedBy(uid types.UID) runtimeresource.ApplyOption {
+	return func(_ context.Context, current, _ runtime.Object) error {
+		c, ok := current.(*v1.Secret)
+		if !ok {
+			return nil
+		}
+		if o := c.GetLabels()[LabelKeyOwnerUID]; o != "" && o != string(uid) {
+			return errors.New(errSecretOwnedByOther)
+		}
+		return nil
+	}
+}
+
+// mustHaveSameType is an ApplyOption that returns errSecretTypeChanged if the
+// existing secret has a different type than the desired one.
+func mustHaveSameType(_ context.Context, current, desired runtime.Object) error {
+	c, ok := current.(*v1.Secret)
+	if !ok {
+		return nil
+	}
+	d, ok := desired.(*v1.Secret)
+	if !ok {
 		return nil
 	}
-	ls := resource.SanitizedDeepCopyObject(rs)
-	ls.SetName(local.GetWriteConnectionSecretToReference().Name)
-	ls.SetNamespace(local.GetNamespace())
-	meta.AddOwnerReference(ls, meta.AsController(meta.ReferenceTo(local, local.GroupVersionKind())))
-	if err := csp.localClient.Apply(ctx, ls); err != nil {
-		return errors.Wrap(err, localPrefix+errApplySecret)
+	if c.Type != d.Type {
+		return errSecretTypeChanged
+	}
+	return nil
+}
+
+// A ConnectionSecretCleanerOption configures a ConnectionSecretCleaner.
+type ConnectionSecretCleanerOption func(*ConnectionSecretCleaner)
+
+// WithCleanupNamespace makes the ConnectionSecretCleaner delete the local
+// connection secrets from the given central namespace. It should match the
+// namespace the ConnectionSecretPropagator writes them to.
+func WithCleanupNamespace(ns string) ConnectionSecretCleanerOption {
+	return func(c *ConnectionSecretCleaner) {
+		c.namespace = ns
+	}
+}
+
+// NewConnectionSecretCleaner returns a new ConnectionSecretCleaner.
+func NewConnectionSecretCleaner(kube client.Client, opts ...ConnectionSecretCleanerOption) *ConnectionSecretCleaner {
+	c := &ConnectionSecretCleaner{localClient: kube}
+	for _, f := range opts {
+		f(c)
+	}
+	return c
+}
+
+// ConnectionSecretCleaner deletes the local connection secrets of a claim. It's
+// an alternative to the garbage collection via owner references for the
+// environments that can't rely on it.
+type ConnectionSecretCleaner struct {
+	localClient client.Client
+	namespace   string
+}
+
+// Cleanup deletes the local connection secrets of the supplied local object.
+// Only the secrets that were created by the agent for this object are deleted.
+func (c *ConnectionSecretCleaner) Cleanup(ctx context.Context, local *claim.Unstructured) error {
+	for _, name := range []func(*claim.Unstructured) string{writeConnectionSecretName, publishConnectionDetailsName} {
+		n := name(local)
+		if n == "" {
+			continue
+		}
+		s := &v1.Secret{}
+		err := c.localClient.Get(ctx, LocalSecretKey(local, n, c.namespace), s)
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, localPrefix+errGetSecret)
+		}
+		if !IsOwnedSecret(s, local) {
+			continue
+		}
+		if err := c.localClient.Delete(ctx, s); runtimeresource.IgnoreNotFound(err) != nil {
+			return errors.Wrap(err, localPrefix+errDeleteSecret)
+		}
 	}
 	return nil
 }
+
+// writeConnectionSecretName returns the name of the connection secret that is
+// referred to via spec.writeConnectionSecretToRef.
+func writeConnectionSecretName(c *claim.Unstructured) string {
+	if c.GetWriteConnectionSecretToReference() == nil {
+		return ""
+	}
+	return c.GetWriteConnectionSecretToReference().Name
+}
+
+// publishConnectionDetailsName returns the name of the connection secret that
+// is referred to via spec.publishConnectionDetailsTo. The connection details
+// that are published to a store other than the default one are not stored in
+// a Kubernetes secret, so an empty name is returned for them.
+func publishConnectionDetailsName(c *claim.Unstructured) string {
+	p := fieldpath.Pave(c.GetUnstructured().UnstructuredContent())
+	if store, err := p.GetString("spec.publishConnectionDetailsTo.configRef.name"); err == nil && store != defaultStoreConfig {
+		return ""
+	}
+	name, _ := p.GetString("spec.publishConnectionDetailsTo.name")
+	return name
+}
@@ -18,18 +18,30 @@ package claim
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	agentresource "github.com/crossplane/agent/pkg/resource"
 )
 
 var (
@@ -61,51 +73,52 @@ var (
 	}}
 )
 
-func TestDefaultConfigurator(t *testing.T) {
-	type args struct {
-		local  *claim.Unstructured
-		remote *claim.Unstructured
-	}
-	type want struct {
-		err error
-	}
+func TestFieldValueFilter(t *testing.T) {
 	cases := map[string]struct {
 		reason string
-		args
-		want
+		local  *claim.Unstructured
+		want   bool
 	}{
-		"Successful": {
-			reason: "Should not return error if everything goes well and matches",
-			args: args{
-				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
-				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
-			},
+		"Matching": {
+			reason: "Claims with matching field value should pass",
+			local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{"tier": "prod"},
+			}}},
+			want: true,
+		},
+		"NotMatching": {
+			reason: "Claims with a different field value should not pass",
+			local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{"tier": "dev"},
+			}}},
+			want: false,
+		},
+		"Missing": {
+			reason: "Claims without the field should not pass",
+			local:  claim.New(),
+			want:   false,
 		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			p := NewDefaultConfigurator()
-			err := p.Configure(context.Background(), tc.args.local, tc.args.remote)
-
-			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\nReason: %s\np.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
-			}
-
-			if diff := cmp.Diff(tc.args.local.Object["spec"], tc.args.remote.Object["spec"]); diff != "" {
-				t.Errorf("\nReason: %s\np.Propagate(...): -want, +got:\n%s", tc.reason, diff)
+			got := NewFieldValueFilter("spec.tier", "prod", "staging")(tc.local)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nf(...): -want, +got:\n%s", tc.reason, diff)
 			}
 		})
 	}
 }
 
-func TestLateInitializer(t *testing.T) {
+func TestDefaultConfigurator(t *testing.T) {
 	type args struct {
-		local  *claim.Unstructured
-		remote *claim.Unstructured
-		kube   client.Client
+		defaulted []string
+		local     *claim.Unstructured
+		remote    *claim.Unstructured
 	}
 	type want struct {
-		err error
+		err     error
+		spec    interface{}
+		entries string
 	}
 	cases := map[string]struct {
 		reason string
@@ -114,130 +127,1900 @@ func TestLateInitializer(t *testing.T) {
 	}{
 		"Successful": {
 			reason: "Should not return error if everything goes well and matches",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+			},
+			want: want{
+				spec: localClaim.DeepCopy().Object["spec"],
+			},
+		},
+		"EnvironmentConfigRefsMerged": {
+			reason: "Environment config references in local spec should be pushed to the remote without clobbering the ones added remotely",
 			args: args{
 				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
 					"spec": map[string]interface{}{
-						"random-field": "random-val",
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "local-env"},
+						},
 					},
 				}}},
-				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
-				kube: &test.MockClient{
-					MockUpdate: test.NewMockUpdateFn(nil),
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "remote-env"},
+						},
+					},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"environmentConfigRefs": []interface{}{
+						map[string]interface{}{"name": "local-env"},
+						map[string]interface{}{"name": "remote-env"},
+					},
 				},
+				entries: `{"spec.environmentConfigRefs":[{"name":"local-env"}]}`,
 			},
 		},
-		"UpdateFailed": {
-			reason: "Should return error if Update fails",
+		"EnvironmentConfigRefsRemoved": {
+			reason: "Environment config references removed from the local spec should be removed from the remote, while the ones added remotely are kept",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "local-env"},
+						},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]interface{}{
+							AnnotationKeyManagedEntries: `{"spec.environmentConfigRefs":[{"name":"local-env"},{"name":"removed-env"}]}`,
+						},
+					},
+					"spec": map[string]interface{}{
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "local-env"},
+							map[string]interface{}{"name": "removed-env"},
+							map[string]interface{}{"name": "remote-env"},
+						},
+					},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"environmentConfigRefs": []interface{}{
+						map[string]interface{}{"name": "local-env"},
+						map[string]interface{}{"name": "remote-env"},
+					},
+				},
+				entries: `{"spec.environmentConfigRefs":[{"name":"local-env"}]}`,
+			},
+		},
+		"ResourceRefsNotPushed": {
+			reason: "Resource references in local spec should never be pushed to the remote",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRefs": []interface{}{
+							map[string]interface{}{"name": "stale"},
+						},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRefs": []interface{}{
+							map[string]interface{}{"name": "bucket"},
+							map[string]interface{}{"name": "db"},
+						},
+					},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"resourceRefs": []interface{}{
+						map[string]interface{}{"name": "bucket"},
+						map[string]interface{}{"name": "db"},
+					},
+				},
+			},
+		},
+		"GenerateName": {
+			reason: "The remote claim should be named after a local claim that was created with generateName",
 			args: args{
-				local:  claim.New(),
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":         "req-abcde",
+						"generateName": "req-",
+						"uid":          "local-uid",
+					},
+					"spec": map[string]interface{}{},
+				}}},
 				remote: claim.New(),
-				kube: &test.MockClient{
-					MockUpdate: test.NewMockUpdateFn(errBoom),
+			},
+			want: want{
+				spec: map[string]interface{}{},
+			},
+		},
+		"StatusDropped": {
+			reason: "The status of the remote claim should not be written back to the remote cluster",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{},
+					"status": map[string]interface{}{
+						"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+					},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{},
+			},
+		},
+		"EnvironmentConfigRefsPreserved": {
+			reason: "Environment config references resolved in the remote should not be clobbered if local spec does not have them",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"random-field": "random-val",
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "remote-env"},
+						},
+					},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"random-field": "random-val",
+					"environmentConfigRefs": []interface{}{
+						map[string]interface{}{"name": "remote-env"},
+					},
 				},
 			},
+		},
+		"RemoteDefaultPreserved": {
+			reason: "A field defaulted by the remote admission webhooks should not be overwritten if local spec does not have it",
+			args: args{
+				defaulted: []string{"spec.parameters.storageClass"},
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"parameters": map[string]interface{}{"size": "10"},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"parameters": map[string]interface{}{"size": "10", "storageClass": "standard"},
+					},
+				}}},
+			},
 			want: want{
-				err: errors.Wrap(errBoom, localPrefix+errUpdateClaim),
+				spec: map[string]interface{}{
+					"parameters": map[string]interface{}{"size": "10", "storageClass": "standard"},
+				},
+			},
+		},
+		"RemoteDefaultOverridden": {
+			reason: "A field defaulted by the remote admission webhooks should be pushed if local spec has it",
+			args: args{
+				defaulted: []string{"spec.parameters.storageClass"},
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"parameters": map[string]interface{}{"size": "10", "storageClass": "fast"},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"parameters": map[string]interface{}{"size": "10", "storageClass": "standard"},
+					},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"parameters": map[string]interface{}{"size": "10", "storageClass": "fast"},
+				},
+			},
+		},
+		"CompositeDeletePolicyBackground": {
+			reason: "The compositeDeletePolicy of the local spec should be pushed to the remote",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"compositeDeletePolicy": "Background"},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"compositeDeletePolicy": "Foreground"},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{"compositeDeletePolicy": "Background"},
+			},
+		},
+		"CompositeDeletePolicyForeground": {
+			reason: "The compositeDeletePolicy of the local spec should be pushed to the remote even if it's defaulted remotely",
+			args: args{
+				defaulted: []string{"spec.compositeDeletePolicy"},
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"compositeDeletePolicy": "Foreground"},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"compositeDeletePolicy": "Background"},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{"compositeDeletePolicy": "Foreground"},
+			},
+		},
+		"CompositeDeletePolicyRemoved": {
+			reason: "The compositeDeletePolicy should not be preserved in the remote if the local spec does not have it, since it's owned by the local instance",
+			args: args{
+				defaulted: []string{"spec.compositeDeletePolicy"},
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"compositeDeletePolicy": "Foreground"},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{},
+			},
+		},
+		"ResourceSelectorPushed": {
+			reason: "The resourceSelector of the local spec should be pushed while the resourceRef it was resolved to in the remote should be kept",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "gold"}},
+						"resourceRef":      map[string]interface{}{"name": "stale"},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "silver"}},
+						"resourceRef":      map[string]interface{}{"name": "cool-xr"},
+					},
+				}}},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "gold"}},
+					"resourceRef":      map[string]interface{}{"name": "cool-xr"},
+				},
+			},
+		},
+		"ResourceRefNotPushed": {
+			reason: "The resourceRef of the local spec should never be pushed to the remote before it's resolved there",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "gold"}},
+						"resourceRef":      map[string]interface{}{"name": "stale"},
+					},
+				}}},
+				remote: claim.New(),
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "gold"}},
+				},
 			},
 		},
 	}
+
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			p := NewLateInitializer(tc.args.kube)
-			err := p.Propagate(context.Background(), tc.args.local, tc.args.remote)
+			p := NewDefaultConfigurator(DefaultFieldPolicies().WithRemoteDefaults(tc.args.defaulted...))
+			err := p.Configure(context.Background(), tc.args.local, tc.args.remote)
 
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\nReason: %s\np.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
+				t.Errorf("\nReason: %s\np.Configure(...): -want error, +got error:\n%s", tc.reason, diff)
 			}
 
-			if diff := cmp.Diff(tc.args.local.Object["spec"], tc.args.remote.Object["spec"]); diff != "" {
-				t.Errorf("\nReason: %s\np.Propagate(...): -want, +got:\n%s", tc.reason, diff)
+			if diff := cmp.Diff(tc.want.spec, tc.args.remote.Object["spec"]); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.entries, tc.args.remote.GetAnnotations()[AnnotationKeyManagedEntries]); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want pushed entries, +got pushed entries:\n%s", tc.reason, diff)
+			}
+
+			if _, ok := tc.args.remote.Object["status"]; ok {
+				t.Errorf("\nReason: %s\np.Configure(...): remote status should be dropped", tc.reason)
+			}
+
+			if diff := cmp.Diff(string(tc.args.local.GetUID()), tc.args.remote.GetAnnotations()[AnnotationKeyLocalUID]); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want local UID, +got local UID:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.args.local.GetName(), tc.args.remote.GetName()); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want name, +got name:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff("", tc.args.remote.GetGenerateName()); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want generateName, +got generateName:\n%s", tc.reason, diff)
 			}
 		})
 	}
 }
 
-func TestStatusPropagator(t *testing.T) {
+func TestReflectedAnnotations(t *testing.T) {
+	keys := []string{"cost.example.org/monthly", "usage.example.org/cpu"}
 	type args struct {
-		local  *claim.Unstructured
-		remote *claim.Unstructured
+		local  map[string]string
+		remote map[string]string
 	}
 	type want struct {
-		err error
+		remote map[string]string
+		local  map[string]string
 	}
-	remoteWithStatus := &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()}
-	remoteWithStatus.SetConditions(v1alpha1.Available())
 	cases := map[string]struct {
 		reason string
 		args
 		want
 	}{
-		"Successful": {
-			reason: "Should not return error if everything goes well and matches",
+		"Reflected": {
+			reason: "Only the configured annotations of the remote claim should be copied to the local one",
 			args: args{
-				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
-				remote: remoteWithStatus,
+				local:  map[string]string{"team": "platform"},
+				remote: map[string]string{"cost.example.org/monthly": "12.50", "usage.example.org/cpu": "3", "cost.example.org/daily": "0.40"},
+			},
+			want: want{
+				remote: map[string]string{"team": "platform", AnnotationKeyLocalUID: "luid", "cost.example.org/monthly": "12.50", "usage.example.org/cpu": "3"},
+				local:  map[string]string{"team": "platform", "cost.example.org/monthly": "12.50", "usage.example.org/cpu": "3"},
+			},
+		},
+		"LocalValueNotPushed": {
+			reason: "The local values of the configured annotations should never be pushed to the remote claim",
+			args: args{
+				local:  map[string]string{"cost.example.org/monthly": "10.00", "usage.example.org/cpu": "9"},
+				remote: map[string]string{"cost.example.org/monthly": "12.50", "usage.example.org/cpu": "3"},
+			},
+			want: want{
+				remote: map[string]string{AnnotationKeyLocalUID: "luid", "cost.example.org/monthly": "12.50", "usage.example.org/cpu": "3"},
+				local:  map[string]string{"cost.example.org/monthly": "12.50", "usage.example.org/cpu": "3"},
 			},
 		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			p := NewStatusPropagator()
-			err := p.Propagate(context.Background(), tc.args.local, tc.args.remote)
-
-			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\nReason: %s\np.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
+			policies := DefaultFieldPolicies().WithRemoteAnnotations(keys...)
+			local := claim.New()
+			local.SetUID("luid")
+			local.SetAnnotations(tc.args.local)
+			local.Object["spec"] = map[string]interface{}{}
+			remote := claim.New()
+			remote.SetAnnotations(tc.args.remote)
+			if err := NewDefaultConfigurator(policies).Configure(context.Background(), local, remote); err != nil {
+				t.Fatalf("\nReason: %s\nConfigure(...): unexpected error: %s", tc.reason, err)
 			}
-
-			if diff := cmp.Diff(tc.args.local.Object["status"], tc.args.remote.Object["status"]); diff != "" {
-				t.Errorf("\nReason: %s\np.Propagate(...): -want, +got:\n%s", tc.reason, diff)
+			if diff := cmp.Diff(tc.want.remote, remote.GetAnnotations()); diff != "" {
+				t.Errorf("\nReason: %s\nConfigure(...): -want remote annotations, +got remote annotations:\n%s", tc.reason, diff)
+			}
+			li := NewLateInitializer(&test.MockClient{MockUpdate: test.NewMockUpdateFn(nil)}, policies)
+			if err := li.Propagate(context.Background(), local, remote); err != nil {
+				t.Fatalf("\nReason: %s\nPropagate(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.local, local.GetAnnotations()); diff != "" {
+				t.Errorf("\nReason: %s\nPropagate(...): -want local annotations, +got local annotations:\n%s", tc.reason, diff)
 			}
 		})
 	}
 }
 
-func TestConnectionSecretPropagator(t *testing.T) {
-	type args struct {
-		local        *claim.Unstructured
-		remote       *claim.Unstructured
-		localClient  resource.ClientApplicator
-		remoteClient resource.ClientApplicator
+func TestPolicyAnnotations(t *testing.T) {
+	withAnnotations := func(a map[string]string) *claim.Unstructured {
+		c := claim.New()
+		c.SetUID("local-uid")
+		c.SetAnnotations(a)
+		c.Object["spec"] = map[string]interface{}{}
+		return c
 	}
-	type want struct {
-		err error
+	type args struct {
+		opts   []DefaultConfiguratorOption
+		local  *claim.Unstructured
+		remote *claim.Unstructured
 	}
 	cases := map[string]struct {
 		reason string
 		args
-		want
+		want map[string]string
 	}{
-		"Successful": {
-			reason: "Should not return error if everything goes well and matches",
+		"PausedPushed": {
+			reason: "Pausing the local instance should pause the remote one",
 			args: args{
-				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
-				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
-				remoteClient: resource.ClientApplicator{
-					Client: &test.MockClient{
-						MockGet: test.NewMockGetFn(nil),
-					},
-				},
-				localClient: resource.ClientApplicator{
-					Applicator: resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
-						return nil
-					}),
-				},
+				local:  withAnnotations(map[string]string{AnnotationKeyPaused: "true"}),
+				remote: withAnnotations(nil),
 			},
+			want: map[string]string{AnnotationKeyPaused: "true", AnnotationKeyLocalUID: "local-uid"},
 		},
-		"NoSecret": {
-			reason: "Should be no-op if no secret reference exists",
+		"PausedRemoved": {
+			reason: "Unpausing the local instance should unpause the remote one",
 			args: args{
-				local: claim.New(),
+				local:  withAnnotations(nil),
+				remote: withAnnotations(map[string]string{AnnotationKeyPaused: "true"}),
 			},
+			want: map[string]string{AnnotationKeyLocalUID: "local-uid"},
 		},
-		"RemoteGetFailed": {
-			reason: "Should return error if secret from remote cluster cannot be fetched",
+		"ExternalNamePushed": {
+			reason: "The annotations of the local instance should be pushed even if they're in the policy annotation domain",
 			args: args{
-				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
-				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				local:  withAnnotations(map[string]string{"crossplane.io/external-name": "local", "example.org/team": "cool"}),
+				remote: withAnnotations(map[string]string{"crossplane.io/external-name": "remote"}),
+			},
+			want: map[string]string{
+				"crossplane.io/external-name": "local",
+				"example.org/team":            "cool",
+				AnnotationKeyLocalUID:         "local-uid",
+			},
+		},
+		"RemoteOwnedKept": {
+			reason: "The remote-owned annotations should keep their remote values",
+			args: args{
+				local:  withAnnotations(map[string]string{AnnotationKeyExternalCreateSucceeded: "local"}),
+				remote: withAnnotations(map[string]string{AnnotationKeyExternalCreateSucceeded: "remote", AnnotationKeyExternalCreatePending: "remote"}),
+			},
+			want: map[string]string{
+				AnnotationKeyExternalCreateSucceeded: "remote",
+				AnnotationKeyExternalCreatePending:   "remote",
+				AnnotationKeyLocalUID:                "local-uid",
+			},
+		},
+		"CustomRemoteOwned": {
+			reason: "Only the configured remote-owned annotations should keep their remote values",
+			args: args{
+				opts:   []DefaultConfiguratorOption{WithRemoteOwnedAnnotations("crossplane.io/external-name")},
+				local:  withAnnotations(map[string]string{"crossplane.io/external-name": "local", AnnotationKeyExternalCreatePending: "local"}),
+				remote: withAnnotations(map[string]string{"crossplane.io/external-name": "remote"}),
+			},
+			want: map[string]string{
+				"crossplane.io/external-name":      "remote",
+				AnnotationKeyExternalCreatePending: "local",
+				AnnotationKeyLocalUID:              "local-uid",
+			},
+		},
+		"RemoteOwnedPassedThrough": {
+			reason: "A remote-owned annotation that is configured to be passed through should be pushed",
+			args: args{
+				opts:   []DefaultConfiguratorOption{WithPolicyAnnotations(AnnotationKeyExternalCreatePending)},
+				local:  withAnnotations(map[string]string{AnnotationKeyExternalCreatePending: "local"}),
+				remote: withAnnotations(map[string]string{AnnotationKeyExternalCreatePending: "remote"}),
+			},
+			want: map[string]string{AnnotationKeyExternalCreatePending: "local", AnnotationKeyLocalUID: "local-uid"},
+		},
+		"DesiredSpecNotPushed": {
+			reason: "The desired spec annotation of the local instance should never be pushed",
+			args: args{
+				local:  withAnnotations(map[string]string{AnnotationKeyDesiredSpec: "{}"}),
+				remote: withAnnotations(nil),
+			},
+			want: map[string]string{AnnotationKeyLocalUID: "local-uid"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewDefaultConfigurator(DefaultFieldPolicies(), tc.args.opts...)
+			if err := p.Configure(context.Background(), tc.args.local, tc.args.remote); err != nil {
+				t.Fatalf("\nReason: %s\np.Configure(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, tc.args.remote.GetAnnotations()); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want annotations, +got annotations:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLabelMerge(t *testing.T) {
+	withLabels := func(l map[string]string, managed string) *claim.Unstructured {
+		c := claim.New()
+		c.SetUID("local-uid")
+		c.SetLabels(l)
+		if managed != "" {
+			c.SetAnnotations(map[string]string{AnnotationKeyManagedLabels: managed})
+		}
+		c.Object["spec"] = map[string]interface{}{}
+		return c
+	}
+	type args struct {
+		local  *claim.Unstructured
+		remote *claim.Unstructured
+	}
+	type want struct {
+		labels  map[string]string
+		managed string
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"RemoteAddedKept": {
+			reason: "The labels added in the remote cluster should survive the propagation of the local labels",
+			args: args{
+				local:  withLabels(map[string]string{"team": "a"}, ""),
+				remote: withLabels(map[string]string{"team": "a", "crossplane.io/composite": "db-x7z"}, "team"),
+			},
+			want: want{
+				labels:  map[string]string{"team": "a", "crossplane.io/composite": "db-x7z"},
+				managed: "team",
+			},
+		},
+		"ManagedRemoved": {
+			reason: "The labels the agent pushed should be removed once the local instance no longer has them",
+			args: args{
+				local:  withLabels(map[string]string{"team": "a"}, ""),
+				remote: withLabels(map[string]string{"team": "a", "tier": "gold", "crossplane.io/composite": "db-x7z"}, "team,tier"),
+			},
+			want: want{
+				labels:  map[string]string{"team": "a", "crossplane.io/composite": "db-x7z"},
+				managed: "team",
+			},
+		},
+		"LocalWins": {
+			reason: "A label of the local instance should overwrite the remote one with the same key",
+			args: args{
+				local:  withLabels(map[string]string{"team": "a", "tier": "gold"}, ""),
+				remote: withLabels(map[string]string{"team": "b"}, ""),
+			},
+			want: want{
+				labels:  map[string]string{"team": "a", "tier": "gold"},
+				managed: "team,tier",
+			},
+		},
+		"NoneManaged": {
+			reason: "The remote labels should be kept and nothing should be recorded if the local instance has no labels",
+			args: args{
+				local:  withLabels(nil, ""),
+				remote: withLabels(map[string]string{"crossplane.io/composite": "db-x7z", "team": "a"}, "team"),
+			},
+			want: want{
+				labels: map[string]string{"crossplane.io/composite": "db-x7z"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewDefaultConfigurator(DefaultFieldPolicies())
+			if err := p.Configure(context.Background(), tc.args.local, tc.args.remote); err != nil {
+				t.Fatalf("\nReason: %s\np.Configure(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.labels, tc.args.remote.GetLabels()); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want labels, +got labels:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.managed, tc.args.remote.GetAnnotations()[AnnotationKeyManagedLabels]); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want managed labels, +got managed labels:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDefaultConfiguratorOverlappingPolicies(t *testing.T) {
+	// The local instance doesn't have spec.parameters, so it's late-initialized
+	// while spec.parameters.size, which is nested in it, is owned by the local
+	// instance and removed from the remote one. The outcome must not depend on
+	// the order the policies are iterated in.
+	p := FieldPolicies{"spec.parameters": FieldPolicyLateInit, "spec.parameters.size": FieldPolicyLocal}
+	want := map[string]interface{}{"parameters": map[string]interface{}{"tier": "gold"}}
+	for i := 0; i < 20; i++ {
+		local := &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{},
+		}}}
+		remote := &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"parameters": map[string]interface{}{"size": "10", "tier": "gold"}},
+		}}}
+		if err := NewDefaultConfigurator(p).Configure(context.Background(), local, remote); err != nil {
+			t.Fatalf("p.Configure(...): unexpected error: %s", err)
+		}
+		if diff := cmp.Diff(want, remote.Object["spec"]); diff != "" {
+			t.Fatalf("\nReason: %s\np.Configure(...): -want, +got:\n%s", "The policy of the nested field should take precedence", diff)
+		}
+	}
+}
+
+func TestProviderConfigDefaulter(t *testing.T) {
+	withProviderConfig := func(name string) *claim.Unstructured {
+		c := claim.New()
+		c.Object["spec"] = map[string]interface{}{}
+		if name != "" {
+			c.Object["spec"] = map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": name},
+			}
+		}
+		return c
+	}
+	type args struct {
+		opts   []ProviderConfigDefaulterOption
+		local  *claim.Unstructured
+		remote *claim.Unstructured
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want map[string]interface{}
+	}{
+		"Absent": {
+			reason: "The cluster provider config should be used if the local instance does not have one",
+			args: args{
+				local:  withProviderConfig(""),
+				remote: withProviderConfig(""),
+			},
+			want: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": "cluster-pc"},
+			},
+		},
+		"Explicit": {
+			reason: "An explicit provider config in the local instance should win",
+			args: args{
+				local:  withProviderConfig("local-pc"),
+				remote: withProviderConfig("local-pc"),
+			},
+			want: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": "local-pc"},
+			},
+		},
+		"Placeholder": {
+			reason: "A placeholder provider config in the local instance should be replaced",
+			args: args{
+				opts:   []ProviderConfigDefaulterOption{WithProviderConfigPlaceholders("placeholder")},
+				local:  withProviderConfig("placeholder"),
+				remote: withProviderConfig("placeholder"),
+			},
+			want: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": "cluster-pc"},
+			},
+		},
+		"Forced": {
+			reason: "An explicit provider config in the local instance should be overridden if forced",
+			args: args{
+				opts:   []ProviderConfigDefaulterOption{WithForcedProviderConfig()},
+				local:  withProviderConfig("local-pc"),
+				remote: withProviderConfig("local-pc"),
+			},
+			want: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": "cluster-pc"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewProviderConfigDefaulter("cluster-pc", tc.args.opts...)
+			if err := d.Configure(context.Background(), tc.args.local, tc.args.remote); err != nil {
+				t.Errorf("\nReason: %s\nd.Configure(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, tc.args.remote.Object["spec"]); diff != "" {
+				t.Errorf("\nReason: %s\nd.Configure(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMetadataInjector(t *testing.T) {
+	type args struct {
+		labels      map[string]string
+		annotations map[string]string
+		remote      *claim.Unstructured
+	}
+	type want struct {
+		labels      map[string]string
+		annotations map[string]string
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Injected": {
+			reason: "The static labels and annotations should be added to the remote instance",
+			args: args{
+				labels:      map[string]string{"tenant": "team-a"},
+				annotations: map[string]string{"billing": "cc-42"},
+				remote: func() *claim.Unstructured {
+					c := claim.New()
+					c.SetLabels(map[string]string{"app": "web"})
+					return c
+				}(),
+			},
+			want: want{
+				labels:      map[string]string{"app": "web", "tenant": "team-a"},
+				annotations: map[string]string{"billing": "cc-42"},
+			},
+		},
+		"StaticWins": {
+			reason: "The static labels and annotations should win over the ones copied from the local instance",
+			args: args{
+				labels:      map[string]string{"tenant": "team-a"},
+				annotations: map[string]string{"billing": "cc-42"},
+				remote: func() *claim.Unstructured {
+					c := claim.New()
+					c.SetLabels(map[string]string{"tenant": "team-b"})
+					c.SetAnnotations(map[string]string{"billing": "free"})
+					return c
+				}(),
+			},
+			want: want{
+				labels:      map[string]string{"tenant": "team-a"},
+				annotations: map[string]string{"billing": "cc-42"},
+			},
+		},
+		"Nothing": {
+			reason: "The remote instance should not be changed if there is nothing to inject",
+			args: args{
+				remote: func() *claim.Unstructured {
+					c := claim.New()
+					c.SetLabels(map[string]string{"app": "web"})
+					return c
+				}(),
+			},
+			want: want{
+				labels: map[string]string{"app": "web"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewMetadataInjector(tc.args.labels, tc.args.annotations)
+			if err := m.Configure(context.Background(), claim.New(), tc.args.remote); err != nil {
+				t.Errorf("\nReason: %s\nm.Configure(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.labels, tc.args.remote.GetLabels()); diff != "" {
+				t.Errorf("\nReason: %s\nm.Configure(...): -want labels, +got labels:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.annotations, tc.args.remote.GetAnnotations()); diff != "" {
+				t.Errorf("\nReason: %s\nm.Configure(...): -want annotations, +got annotations:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProviderConfigInjector(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		spec   map[string]interface{}
+		want   map[string]interface{}
+	}{
+		"Absent": {
+			reason: "The default provider config should be injected if the remote instance does not refer to any",
+			spec:   map[string]interface{}{"size": "large"},
+			want: map[string]interface{}{
+				"size":              "large",
+				"providerConfigRef": map[string]interface{}{"name": "default-pc"},
+			},
+		},
+		"NoSpec": {
+			reason: "The default provider config should be injected if the remote instance has no spec at all",
+			want: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": "default-pc"},
+			},
+		},
+		"Explicit": {
+			reason: "An existing provider config reference should not be touched",
+			spec: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": "local-pc"},
+			},
+			want: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{"name": "local-pc"},
+			},
+		},
+		"EmptyReference": {
+			reason: "An existing provider config reference should not be touched even if it has no name",
+			spec: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{},
+			},
+			want: map[string]interface{}{
+				"providerConfigRef": map[string]interface{}{},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			remote := claim.New()
+			if tc.spec != nil {
+				remote.Object["spec"] = tc.spec
+			}
+			i := NewProviderConfigInjector("default-pc")
+			if err := i.Configure(context.Background(), claim.New(), remote); err != nil {
+				t.Errorf("\nReason: %s\ni.Configure(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, remote.Object["spec"]); diff != "" {
+				t.Errorf("\nReason: %s\ni.Configure(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestManagementPoliciesInjector(t *testing.T) {
+	observe := []interface{}{"Observe"}
+	type args struct {
+		local  map[string]interface{}
+		remote map[string]interface{}
+		opts   []ManagementPoliciesInjectorOption
+	}
+	cases := map[string]struct {
+		reason   string
+		policies FieldPolicies
+		args
+		want interface{}
+	}{
+		"Absent": {
+			reason: "The configured management policies should be injected if neither instance has any",
+			args: args{
+				local:  map[string]interface{}{"size": "large"},
+				remote: map[string]interface{}{"size": "large"},
+			},
+			want: observe,
+		},
+		"LocalPushed": {
+			reason: "The management policies of the local instance should win if they're pushed",
+			args: args{
+				local:  map[string]interface{}{"managementPolicies": []interface{}{"*"}},
+				remote: map[string]interface{}{},
+			},
+			want: []interface{}{"*"},
+		},
+		"RemotePulled": {
+			reason: "The management policies of the remote instance should be kept if the field policies let the remote cluster own them",
+			policies: FieldPolicies{
+				fieldManagementPolicies: FieldPolicyRemote,
+			},
+			args: args{
+				local:  map[string]interface{}{"managementPolicies": []interface{}{"*"}},
+				remote: map[string]interface{}{"managementPolicies": []interface{}{"Observe", "LateInitialize"}},
+			},
+			want: []interface{}{"Observe", "LateInitialize"},
+		},
+		"LateInitAbsent": {
+			reason: "The configured management policies should be injected if a late-initialized field has no value in either instance",
+			policies: FieldPolicies{
+				fieldManagementPolicies: FieldPolicyLateInit,
+			},
+			args: args{
+				local:  map[string]interface{}{},
+				remote: map[string]interface{}{},
+			},
+			want: observe,
+		},
+		"Forced": {
+			reason: "The configured management policies should override the local ones if forced",
+			args: args{
+				local:  map[string]interface{}{"managementPolicies": []interface{}{"*"}},
+				remote: map[string]interface{}{"managementPolicies": []interface{}{"*"}},
+				opts:   []ManagementPoliciesInjectorOption{WithForcedManagementPolicies()},
+			},
+			want: observe,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := claim.New()
+			local.Object["spec"] = tc.args.local
+			remote := claim.New()
+			remote.Object["spec"] = tc.args.remote
+			cc := NewConfiguratorChain(
+				NewDefaultConfigurator(tc.policies),
+				NewManagementPoliciesInjector([]string{"Observe"}, tc.args.opts...),
+			)
+			if err := cc.Configure(context.Background(), local, remote); err != nil {
+				t.Fatalf("\nReason: %s\ncc.Configure(...): unexpected error: %s", tc.reason, err)
+			}
+			spec, _ := remote.Object["spec"].(map[string]interface{})
+			if diff := cmp.Diff(tc.want, spec["managementPolicies"]); diff != "" {
+				t.Errorf("\nReason: %s\ncc.Configure(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFieldRewriter(t *testing.T) {
+	registry := Rewrite{Pattern: regexp.MustCompile(`^docker\.io/`), Replacement: "registry.internal/"}
+	type args struct {
+		paths    []string
+		rewrites []Rewrite
+		remote   *claim.Unstructured
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want map[string]interface{}
+	}{
+		"NestedRegistryHost": {
+			reason: "The registry host in a nested spec field should be rewritten",
+			args: args{
+				paths:    []string{"spec.parameters.app.image"},
+				rewrites: []Rewrite{registry},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"parameters": map[string]interface{}{
+							"app": map[string]interface{}{"image": "docker.io/crossplane/agent:v0.1.0"},
+						},
+					},
+				}}},
+			},
+			want: map[string]interface{}{
+				"parameters": map[string]interface{}{
+					"app": map[string]interface{}{"image": "registry.internal/crossplane/agent:v0.1.0"},
+				},
+			},
+		},
+		"Submatches": {
+			reason: "Replacements should be able to refer to the submatches",
+			args: args{
+				paths:    []string{"spec.image"},
+				rewrites: []Rewrite{{Pattern: regexp.MustCompile(`^([^/]+)/(.*)$`), Replacement: "mirror.internal/$1/$2"}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"image": "quay.io/org/app"},
+				}}},
+			},
+			want: map[string]interface{}{"image": "mirror.internal/quay.io/org/app"},
+		},
+		"NotMatching": {
+			reason: "Fields that do not match should not be changed",
+			args: args{
+				paths:    []string{"spec.image"},
+				rewrites: []Rewrite{registry},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"image": "quay.io/org/app"},
+				}}},
+			},
+			want: map[string]interface{}{"image": "quay.io/org/app"},
+		},
+		"MissingAndNotString": {
+			reason: "Fields that do not exist or are not strings should be skipped",
+			args: args{
+				paths:    []string{"spec.missing", "spec.replicas"},
+				rewrites: []Rewrite{registry},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"replicas": int64(3)},
+				}}},
+			},
+			want: map[string]interface{}{"replicas": int64(3)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fr := NewFieldRewriter(tc.args.paths, tc.args.rewrites...)
+			if err := fr.Configure(context.Background(), claim.New(), tc.args.remote); err != nil {
+				t.Errorf("\nReason: %s\nfr.Configure(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, tc.args.remote.Object["spec"]); diff != "" {
+				t.Errorf("\nReason: %s\nfr.Configure(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLateInitializer(t *testing.T) {
+	type args struct {
+		policies FieldPolicies
+		opts     []LateInitializerOption
+		local    *claim.Unstructured
+		remote   *claim.Unstructured
+		kube     client.Client
+	}
+	type want struct {
+		err  error
+		spec interface{}
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Successful": {
+			reason: "Should not return error if everything goes well and matches",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"random-field": "random-val",
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{
+				spec: remoteClaim.DeepCopy().Object["spec"],
+			},
+		},
+		"EnvironmentConfigRefsReflected": {
+			reason: "Environment config references added in the remote should be late-initialized",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "remote-env-a"},
+							map[string]interface{}{"name": "remote-env-b"},
+						},
+					},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"environmentConfigRefs": []interface{}{
+						map[string]interface{}{"name": "remote-env-a"},
+						map[string]interface{}{"name": "remote-env-b"},
+					},
+				},
+			},
+		},
+		"EnvironmentConfigRefsNotClobbered": {
+			reason: "Environment config references added in the remote should be late-initialized without clobbering the local ones",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "local-env"},
+						},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"environmentConfigRefs": []interface{}{
+							map[string]interface{}{"name": "remote-env"},
+						},
+					},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"environmentConfigRefs": []interface{}{
+						map[string]interface{}{"name": "local-env"},
+						map[string]interface{}{"name": "remote-env"},
+					},
+				},
+			},
+		},
+		"ResourceRefsReflected": {
+			reason: "Resource references of the remote composite should be reflected to local spec",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRefs": []interface{}{
+							map[string]interface{}{"name": "stale"},
+						},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRefs": []interface{}{
+							map[string]interface{}{"apiVersion": "example.org/v1", "kind": "Bucket", "name": "bucket"},
+							map[string]interface{}{"apiVersion": "example.org/v1", "kind": "Database", "name": "db"},
+						},
+					},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"resourceRefs": []interface{}{
+						map[string]interface{}{"apiVersion": "example.org/v1", "kind": "Bucket", "name": "bucket"},
+						map[string]interface{}{"apiVersion": "example.org/v1", "kind": "Database", "name": "db"},
+					},
+				},
+			},
+		},
+		"ResourceRefsUpToDate": {
+			reason: "Local claim should not be updated if the reflected resource references did not change",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRefs": []interface{}{
+							map[string]interface{}{"name": "bucket"},
+							map[string]interface{}{"name": "db"},
+						},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRefs": []interface{}{
+							map[string]interface{}{"name": "bucket"},
+							map[string]interface{}{"name": "db"},
+						},
+					},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(errBoom),
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"resourceRefs": []interface{}{
+						map[string]interface{}{"name": "bucket"},
+						map[string]interface{}{"name": "db"},
+					},
+				},
+			},
+		},
+		"UpdateFailed": {
+			reason: "Should return error if Update fails",
+			args: args{
+				local: claim.New(),
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"compositionRef": map[string]interface{}{"name": "remote-default"},
+					},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(errBoom),
+				},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, localPrefix+errUpdateClaim),
+				spec: map[string]interface{}{
+					"compositionRef": map[string]interface{}{"name": "remote-default"},
+				},
+			},
+		},
+		"ResolvedResourceRef": {
+			reason: "The resourceRef the resourceSelector was resolved to in the remote should be late-initialized while the selector is kept",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "gold"}},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "silver"}},
+						"resourceRef":      map[string]interface{}{"name": "cool-xr"},
+					},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "gold"}},
+					"resourceRef":      map[string]interface{}{"name": "cool-xr"},
+				},
+			},
+		},
+		"ReresolvedResourceRef": {
+			reason: "The resourceRef of the local spec should follow the one of the remote",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRef": map[string]interface{}{"name": "stale"},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"resourceRef": map[string]interface{}{"name": "cool-xr"},
+					},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"resourceRef": map[string]interface{}{"name": "cool-xr"},
+				},
+			},
+		},
+		"SemanticallyEqual": {
+			reason: "A remote-owned field should not be written if the supplied EqualityFunc considers it equal to the local one",
+			args: args{
+				policies: FieldPolicies{"spec.cpu": FieldPolicyRemote},
+				opts:     []LateInitializerOption{WithLateInitEqualityFunc(quantityEqual)},
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"cpu": "100m"},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"cpu": "0.1"},
+				}}},
+				kube: &test.MockClient{
+					MockUpdate: test.NewMockUpdateFn(errBoom),
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{"cpu": "100m"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			policies := tc.args.policies
+			if policies == nil {
+				policies = DefaultFieldPolicies()
+			}
+			p := NewLateInitializer(tc.args.kube, policies, tc.args.opts...)
+			err := p.Propagate(context.Background(), tc.args.local, tc.args.remote)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.spec, tc.args.local.Object["spec"]); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLateInitializerConflictRetries(t *testing.T) {
+	conflict := kerrors.NewConflict(schema.GroupResource{}, "", errBoom)
+	remote := func() *claim.Unstructured {
+		return &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"compositionRef": map[string]interface{}{"name": "remote-comp"},
+			},
+		}}}
+	}
+	type args struct {
+		ctx       context.Context
+		opts      []LateInitializerOption
+		conflicts int
+		getErr    error
+	}
+	type want struct {
+		err     error
+		updates int
+		spec    interface{}
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"RetriedUntilSuccess": {
+			reason: "The update should be retried on conflicts with a freshly fetched and late-initialized local object",
+			args: args{
+				ctx:       context.Background(),
+				opts:      []LateInitializerOption{WithConflictRetries(3), WithConflictBackoff(wait.Backoff{})},
+				conflicts: 2,
+			},
+			want: want{
+				updates: 3,
+				spec: map[string]interface{}{
+					"fresh":          "value",
+					"compositionRef": map[string]interface{}{"name": "remote-comp"},
+				},
+			},
+		},
+		"AttemptsExhausted": {
+			reason: "The conflict should be returned once all attempts are made",
+			args: args{
+				ctx:       context.Background(),
+				opts:      []LateInitializerOption{WithConflictRetries(3), WithConflictBackoff(wait.Backoff{})},
+				conflicts: 5,
+			},
+			want: want{
+				err:     errors.Wrap(conflict, localPrefix+errUpdateClaim),
+				updates: 3,
+			},
+		},
+		"NotRetriedByDefault": {
+			reason: "A conflict should not be retried unless configured",
+			args: args{
+				ctx:       context.Background(),
+				conflicts: 1,
+			},
+			want: want{
+				err:     errors.Wrap(conflict, localPrefix+errUpdateClaim),
+				updates: 1,
+			},
+		},
+		"ContextDone": {
+			reason: "No more attempts should be made once the context is done",
+			args: args{
+				ctx: func() context.Context {
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+					return ctx
+				}(),
+				opts:      []LateInitializerOption{WithConflictRetries(3), WithConflictBackoff(wait.Backoff{Duration: time.Hour})},
+				conflicts: 5,
+			},
+			want: want{
+				err:     errors.Wrap(conflict, localPrefix+errUpdateClaim),
+				updates: 1,
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the local object cannot be fetched again",
+			args: args{
+				ctx:       context.Background(),
+				opts:      []LateInitializerOption{WithConflictRetries(3), WithConflictBackoff(wait.Backoff{})},
+				conflicts: 1,
+				getErr:    errBoom,
+			},
+			want: want{
+				err:     errors.Wrap(errBoom, localPrefix+errGetRequirement),
+				updates: 1,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			updates := 0
+			var spec interface{}
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if tc.args.getErr != nil {
+						return tc.args.getErr
+					}
+					obj.(*claim.Unstructured).Object = map[string]interface{}{
+						"spec": map[string]interface{}{"fresh": "value"},
+					}
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+					updates++
+					if updates <= tc.args.conflicts {
+						return conflict
+					}
+					spec = obj.(*claim.Unstructured).Object["spec"]
+					return nil
+				},
+			}
+			local := &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{},
+			}}}
+			li := NewLateInitializer(kube, DefaultFieldPolicies(), tc.args.opts...)
+			err := li.Propagate(tc.args.ctx, local, remote())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nli.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.updates, updates); diff != "" {
+				t.Errorf("\nReason: %s\nupdates: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.spec, spec); diff != "" {
+				t.Errorf("\nReason: %s\nli.Propagate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStatusPropagator(t *testing.T) {
+	type args struct {
+		opts   []StatusPropagatorOption
+		local  *claim.Unstructured
+		remote *claim.Unstructured
+	}
+	type want struct {
+		err    error
+		status interface{}
+	}
+	remoteWithStatus := &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()}
+	remoteWithStatus.SetConditions(v1alpha1.Available())
+	remoteWithStatus.Object["status"].(map[string]interface{})["atProvider"] = map[string]interface{}{"id": "remote-id"}
+	remoteTransitioned := &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()}
+	remoteTransitioned.SetConditions(v1alpha1.Available().WithMessage("ready"))
+	remoteTransitioned.Object["status"].(map[string]interface{})["conditions"].([]interface{})[0].(map[string]interface{})["lastTransitionTime"] = "2020-09-01T10:00:00Z"
+	localWithOldCondition := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+	localWithOldCondition.SetConditions(v1alpha1.Available().WithMessage("ready"), v1alpha1.ReconcileSuccess())
+	localWithOldCondition.Object["status"].(map[string]interface{})["conditions"].([]interface{})[0].(map[string]interface{})["lastTransitionTime"] = "2020-08-01T10:00:00Z"
+	localWithStatus := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+	localWithStatus.Object["status"] = map[string]interface{}{"phase": "local-phase"}
+	localV1 := &claim.Unstructured{Unstructured: *localWithStatus.DeepCopy()}
+	localV1.SetAPIVersion("example.org/v1")
+	remoteV2 := &claim.Unstructured{Unstructured: *remoteWithStatus.DeepCopy()}
+	remoteV2.SetAPIVersion("example.org/v2")
+	localMalformed := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+	localMalformed.Object["status"] = map[string]interface{}{"conditions": "malformed"}
+	errMalformed := fieldpath.Pave(localMalformed.DeepCopy().Object).GetValueInto("status", &v1alpha1.ConditionedStatus{})
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Successful": {
+			reason: "Should not return error if everything goes well and matches",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: remoteWithStatus,
+			},
+			want: want{
+				status: map[string]interface{}{
+					"conditions": remoteWithStatus.Object["status"].(map[string]interface{})["conditions"],
+				},
+			},
+		},
+		"LastTransitionTimePreserved": {
+			reason: "Copied conditions should retain the lastTransitionTime of the remote even if they are otherwise equal",
+			args: args{
+				local:  localWithOldCondition,
+				remote: remoteTransitioned,
+			},
+			want: want{
+				status: map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":               "Ready",
+							"status":             "True",
+							"reason":             string(v1alpha1.ReasonAvailable),
+							"message":            "ready",
+							"lastTransitionTime": "2020-09-01T10:00:00Z",
+						},
+						localWithOldCondition.Object["status"].(map[string]interface{})["conditions"].([]interface{})[1],
+					},
+				},
+			},
+		},
+		"LocalConditionsMalformed": {
+			reason: "An error should be returned instead of overwriting the local conditions if they cannot be read",
+			args: args{
+				local:  localMalformed,
+				remote: remoteWithStatus,
+			},
+			want: want{
+				status: localMalformed.Object["status"],
+				err:    errors.Wrap(errMalformed, localPrefix+errGetConditions),
+			},
+		},
+		"StatusPathRemote": {
+			reason: "The remote status should be written under the given path without overwriting the local status",
+			args: args{
+				opts:   []StatusPropagatorOption{WithStatusPath("status.remote")},
+				local:  &claim.Unstructured{Unstructured: *localWithStatus.DeepCopy()},
+				remote: remoteWithStatus,
+			},
+			want: want{
+				status: map[string]interface{}{
+					"phase":  "local-phase",
+					"remote": remoteWithStatus.Object["status"],
+				},
+			},
+		},
+		"StatusPathAtProvider": {
+			reason: "The remote status should be written under status.atProvider if it is the given path",
+			args: args{
+				opts:   []StatusPropagatorOption{WithStatusPath("status.atProvider")},
+				local:  &claim.Unstructured{Unstructured: *localWithStatus.DeepCopy()},
+				remote: remoteWithStatus,
+			},
+			want: want{
+				status: map[string]interface{}{
+					"phase":      "local-phase",
+					"atProvider": remoteWithStatus.Object["status"],
+				},
+			},
+		},
+		"StatusPathOtherVersion": {
+			reason: "Only the conditions should be copied from a remote object of another version, whose status may not be valid at the local version",
+			args: args{
+				opts:   []StatusPropagatorOption{WithStatusPath("status.remote")},
+				local:  localV1,
+				remote: remoteV2,
+			},
+			want: want{
+				status: map[string]interface{}{
+					"phase":      "local-phase",
+					"conditions": remoteWithStatus.Object["status"].(map[string]interface{})["conditions"],
+				},
+			},
+		},
+		"StatusPathSemanticallyEqual": {
+			reason: "The status at the given path should be left as it is if the supplied EqualityFunc considers it equal to the remote one",
+			args: args{
+				opts: []StatusPropagatorOption{WithStatusPath("status.remote"), WithStatusEqualityFunc(quantityEqual)},
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"status": map[string]interface{}{"remote": map[string]interface{}{"cpu": "100m"}},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"status": map[string]interface{}{"cpu": "0.1"},
+				}}},
+			},
+			want: want{
+				status: map[string]interface{}{"remote": map[string]interface{}{"cpu": "100m"}},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewStatusPropagator(tc.args.opts...)
+			err := p.Propagate(context.Background(), tc.args.local, tc.args.remote)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.status, tc.args.local.Object["status"]); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConnectionSecretPropagator(t *testing.T) {
+	type args struct {
+		local        *claim.Unstructured
+		remote       *claim.Unstructured
+		localClient  resource.ClientApplicator
+		remoteClient resource.ClientApplicator
+		opts         []ConnectionSecretPropagatorOption
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Successful": {
+			reason: "Should not return error if everything goes well and matches",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Applicator: resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+						return nil
+					}),
+				},
+			},
+		},
+		"MergeWithExistingLocalKeys": {
+			reason: "Keys that exist only in the local secret should be preserved and remote should win on conflicts if merge is enabled",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+							obj.(*corev1.Secret).Data = map[string][]byte{"password": []byte("remote")}
+							return nil
+						}),
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+							obj.(*corev1.Secret).Data = map[string][]byte{"password": []byte("local"), "ca.crt": []byte("ca")}
+							return nil
+						}),
+					},
+					Applicator: resource.ApplyFn(func(_ context.Context, obj runtime.Object, _ ...resource.ApplyOption) error {
+						want := map[string][]byte{"password": []byte("remote"), "ca.crt": []byte("ca")}
+						if diff := cmp.Diff(want, obj.(*corev1.Secret).Data); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "Keys that exist only in the local secret should be preserved", diff)
+						}
+						return nil
+					}),
+				},
+				opts: []ConnectionSecretPropagatorOption{WithSecretMerge()},
+			},
+		},
+		"NoMerge": {
+			reason: "The remote secret should be applied as is if merge is not enabled",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+							obj.(*corev1.Secret).Data = map[string][]byte{"password": []byte("remote")}
+							return nil
+						}),
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Applicator: resource.ApplyFn(func(_ context.Context, obj runtime.Object, _ ...resource.ApplyOption) error {
+						want := map[string][]byte{"password": []byte("remote")}
+						if diff := cmp.Diff(want, obj.(*corev1.Secret).Data); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote secret should be applied as is if merge is not enabled", diff)
+						}
+						return nil
+					}),
+				},
+			},
+		},
+		"CentralNamespace": {
+			reason: "The local secret should be written to the central namespace with labels identifying its claim and no owner reference",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Applicator: resource.ApplyFn(func(_ context.Context, obj runtime.Object, _ ...resource.ApplyOption) error {
+						want := &corev1.Secret{}
+						want.SetName("local-namespace.local-s-name")
+						want.SetNamespace("secrets")
+						want.SetLabels(map[string]string{
+							LabelKeyManagedBy:      LabelValueManagedBy,
+							LabelKeyOwnerUID:       "local-uid",
+							LabelKeyClaimName:      "local-name",
+							LabelKeyClaimNamespace: "local-namespace",
+						})
+						if diff := cmp.Diff(want, obj); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local secret should be written to the central namespace", diff)
+						}
+						return nil
+					}),
+				},
+				opts: []ConnectionSecretPropagatorOption{WithSecretNamespace("secrets")},
+			},
+		},
+		"PreserveSecretType": {
+			reason: "The local secret should have the type of the remote secret",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+							obj.(*corev1.Secret).Type = "connection.crossplane.io/v1alpha1"
+							return nil
+						}),
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Applicator: resource.ApplyFn(func(_ context.Context, obj runtime.Object, _ ...resource.ApplyOption) error {
+						if diff := cmp.Diff(corev1.SecretType("connection.crossplane.io/v1alpha1"), obj.(*corev1.Secret).Type); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local secret should have the type of the remote secret", diff)
+						}
+						return nil
+					}),
+				},
+			},
+		},
+		"RecreateOnTypeChange": {
+			reason: "The local secret should be recreated if its type differs from the remote secret",
+			args: func() args {
+				deleted := false
+				lc := &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						if deleted {
+							return kerrors.NewNotFound(schema.GroupResource{}, "")
+						}
+						obj.(*corev1.Secret).Type = corev1.SecretTypeOpaque
+						return nil
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+						deleted = true
+						return nil
+					},
+					MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+						if diff := cmp.Diff(corev1.SecretType("connection.crossplane.io/v1alpha1"), obj.(*corev1.Secret).Type); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local secret should be recreated with the type of the remote secret", diff)
+						}
+						return nil
+					},
+				}
+				return args{
+					local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+					remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+					remoteClient: resource.ClientApplicator{
+						Client: &test.MockClient{
+							MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+								obj.(*corev1.Secret).Type = "connection.crossplane.io/v1alpha1"
+								return nil
+							}),
+						},
+					},
+					localClient: resource.ClientApplicator{
+						Client:     lc,
+						Applicator: resource.NewAPIPatchingApplicator(lc),
+					},
+				}
+			}(),
+		},
+		"RecreateDeleteFailed": {
+			reason: "Should return error if the local secret with a different type cannot be deleted",
+			args: func() args {
+				lc := &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+						obj.(*corev1.Secret).Type = corev1.SecretTypeOpaque
+						return nil
+					}),
+					MockDelete: test.NewMockDeleteFn(errBoom),
+				}
+				return args{
+					local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+					remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+					remoteClient: resource.ClientApplicator{
+						Client: &test.MockClient{
+							MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+								obj.(*corev1.Secret).Type = "connection.crossplane.io/v1alpha1"
+								return nil
+							}),
+						},
+					},
+					localClient: resource.ClientApplicator{
+						Client:     lc,
+						Applicator: resource.NewAPIPatchingApplicator(lc),
+					},
+				}
+			}(),
+			want: want{
+				err: errors.Wrap(errBoom, localPrefix+errDeleteSecret),
+			},
+		},
+		"OwnedByOther": {
+			reason: "A local secret that the agent created for another claim should not be overwritten",
+			args: func() args {
+				lc := &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+						obj.(*corev1.Secret).SetLabels(map[string]string{LabelKeyManagedBy: LabelValueManagedBy, LabelKeyOwnerUID: "other-uid"})
+						return nil
+					}),
+				}
+				return args{
+					local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+					remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+					remoteClient: resource.ClientApplicator{
+						Client: &test.MockClient{
+							MockGet: test.NewMockGetFn(nil),
+						},
+					},
+					localClient: resource.ClientApplicator{
+						Client:     lc,
+						Applicator: resource.NewAPIPatchingApplicator(lc),
+					},
+					opts: []ConnectionSecretPropagatorOption{WithSecretNamespace("secrets")},
+				}
+			}(),
+			want: want{
+				err: errors.Wrap(errors.New(errSecretOwnedByOther), localPrefix+errApplySecret),
+			},
+		},
+		"MergeLocalGetFailed": {
+			reason: "Should return error if the existing local secret cannot be fetched for merge",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(errBoom),
+					},
+				},
+				opts: []ConnectionSecretPropagatorOption{WithSecretMerge()},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, localPrefix+errGetSecret),
+			},
+		},
+		"PublishConnectionDetailsTo": {
+			reason: "The secret referred via publishConnectionDetailsTo should be propagated",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"metadata": map[string]interface{}{"name": "local-name", "namespace": "local-namespace"},
+					"spec": map[string]interface{}{
+						"publishConnectionDetailsTo": map[string]interface{}{"name": "local-p-name"},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"metadata": map[string]interface{}{"name": "local-name", "namespace": "local-namespace"},
+					"spec": map[string]interface{}{
+						"publishConnectionDetailsTo": map[string]interface{}{
+							"name":      "remote-p-name",
+							"configRef": map[string]interface{}{"name": "default"},
+						},
+					},
+				}}},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+							if diff := cmp.Diff("remote-p-name", key.Name); diff != "" {
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote secret referred via publishConnectionDetailsTo should be fetched", diff)
+							}
+							return nil
+						},
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Applicator: resource.ApplyFn(func(_ context.Context, obj runtime.Object, _ ...resource.ApplyOption) error {
+						if diff := cmp.Diff("local-p-name", obj.(*corev1.Secret).GetName()); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local secret referred via publishConnectionDetailsTo should be applied", diff)
+						}
+						return nil
+					}),
+				},
+			},
+		},
+		"BothSecretReferences": {
+			reason: "The secrets referred via both writeConnectionSecretToRef and publishConnectionDetailsTo should be propagated",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"writeConnectionSecretToRef": map[string]interface{}{"name": "local-s-name"},
+						"publishConnectionDetailsTo": map[string]interface{}{"name": "local-p-name"},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"writeConnectionSecretToRef": map[string]interface{}{"name": "remote-s-name"},
+						"publishConnectionDetailsTo": map[string]interface{}{"name": "remote-p-name"},
+					},
+				}}},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+					},
+				},
+				localClient: resource.ClientApplicator{
+					Applicator: func() resource.ApplyFn {
+						applied := map[string]bool{}
+						return func(_ context.Context, obj runtime.Object, _ ...resource.ApplyOption) error {
+							applied[obj.(*corev1.Secret).GetName()] = true
+							if len(applied) == 2 {
+								if diff := cmp.Diff(map[string]bool{"local-s-name": true, "local-p-name": true}, applied); diff != "" {
+									t.Errorf("\nReason: %s\n-want, +got:\n%s", "The secrets referred via both references should be propagated", diff)
+								}
+							}
+							return nil
+						}
+					}(),
+				},
+			},
+		},
+		"ExternalSecretStore": {
+			reason: "Should be no-op if connection details are published to a store other than Kubernetes",
+			args: args{
+				local: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"publishConnectionDetailsTo": map[string]interface{}{"name": "local-p-name"},
+					},
+				}}},
+				remote: &claim.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"publishConnectionDetailsTo": map[string]interface{}{
+							"name":      "remote-p-name",
+							"configRef": map[string]interface{}{"name": "vault"},
+						},
+					},
+				}}},
+			},
+		},
+		"NoSecret": {
+			reason: "Should be no-op if no secret reference exists",
+			args: args{
+				local: claim.New(),
+			},
+		},
+		"SkipSecret": {
+			reason: "Should be no-op if the local claim opted out of secret propagation",
+			args: args{
+				local: func() *claim.Unstructured {
+					c := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+					c.SetAnnotations(map[string]string{AnnotationKeySkipSecret: "true"})
+					return c
+				}(),
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(errBoom),
+					},
+				},
+			},
+		},
+		"RemoteGetFailed": {
+			reason: "Should return error if secret from remote cluster cannot be fetched",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
 				remoteClient: resource.ClientApplicator{
 					Client: &test.MockClient{
 						MockGet: test.NewMockGetFn(errBoom),
@@ -271,7 +2054,7 @@ func TestConnectionSecretPropagator(t *testing.T) {
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			p := NewConnectionSecretPropagator(tc.args.localClient, tc.args.remoteClient)
+			p := NewConnectionSecretPropagator(tc.args.localClient, tc.args.remoteClient, tc.args.opts...)
 			err := p.Propagate(context.Background(), tc.args.local, tc.args.remote)
 
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
@@ -280,3 +2063,429 @@ func TestConnectionSecretPropagator(t *testing.T) {
 		})
 	}
 }
+
+func TestConnectionSecretReadyCondition(t *testing.T) {
+	remoteSecret := func(data map[string][]byte) resource.ClientApplicator {
+		return resource.ClientApplicator{Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+				obj.(*corev1.Secret).Data = data
+				return nil
+			}),
+		}}
+	}
+	applied := resource.ClientApplicator{
+		Applicator: resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+			return nil
+		}),
+	}
+	noRef := &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()}
+	noRef.SetWriteConnectionSecretToReference(nil)
+
+	type args struct {
+		local        *claim.Unstructured
+		remote       *claim.Unstructured
+		remoteClient resource.ClientApplicator
+		opts         []ConnectionSecretPropagatorOption
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want v1alpha1.Condition
+	}{
+		"Complete": {
+			reason: "The secret should be ready if it's propagated with all required keys",
+			args: args{
+				local:        &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote:       &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: remoteSecret(map[string][]byte{"username": []byte("admin"), "password": []byte("pw"), "extra": []byte("x")}),
+				opts:         []ConnectionSecretPropagatorOption{WithSecretReadyCondition("username", "password")},
+			},
+			want: agentresource.ConnectionSecretAvailable(),
+		},
+		"NoRequiredKeys": {
+			reason: "The secret should be ready once it's propagated if no key is required",
+			args: args{
+				local:        &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote:       &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: remoteSecret(nil),
+				opts:         []ConnectionSecretPropagatorOption{WithSecretReadyCondition()},
+			},
+			want: agentresource.ConnectionSecretAvailable(),
+		},
+		"MissingKeys": {
+			reason: "The secret should not be ready if it lacks required keys or has empty values for them",
+			args: args{
+				local:        &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote:       &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: remoteSecret(map[string][]byte{"username": []byte("admin"), "password": {}}),
+				opts:         []ConnectionSecretPropagatorOption{WithSecretReadyCondition("username", "password", "endpoint")},
+			},
+			want: agentresource.ConnectionSecretUnavailable().WithMessage("connection secret local-s-name lacks the required keys password, endpoint"),
+		},
+		"MissingInRemote": {
+			reason: "The secret should not be ready if it does not exist in the remote cluster yet",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: resource.ClientApplicator{Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				}},
+				opts: []ConnectionSecretPropagatorOption{WithSecretReadyCondition("password")},
+			},
+			want: agentresource.ConnectionSecretUnavailable().WithMessage("connection secret remote-s-name does not exist in the remote cluster yet"),
+		},
+		"NotReferredInRemote": {
+			reason: "The secret should not be ready if the remote claim does not refer to a secret yet",
+			args: args{
+				local:  &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote: noRef,
+				opts:   []ConnectionSecretPropagatorOption{WithSecretReadyCondition("password")},
+			},
+			want: agentresource.ConnectionSecretUnavailable().WithMessage("remote claim does not refer to a connection secret for local-s-name yet"),
+		},
+		"NotReferredInLocal": {
+			reason: "No condition should be reported if the local claim does not refer to a secret",
+			args: args{
+				local:  noRef,
+				remote: &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				opts:   []ConnectionSecretPropagatorOption{WithSecretReadyCondition("password")},
+			},
+			want: v1alpha1.Condition{},
+		},
+		"NotEnabled": {
+			reason: "No condition should be reported unless it's enabled",
+			args: args{
+				local:        &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				remote:       &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()},
+				remoteClient: remoteSecret(nil),
+			},
+			want: v1alpha1.Condition{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewConnectionSecretPropagator(applied, tc.args.remoteClient, tc.args.opts...)
+			if err := p.Propagate(context.Background(), tc.args.local, tc.args.remote); err != nil {
+				t.Fatalf("\nReason: %s\np.Propagate(...): unexpected error: %s", tc.reason, err)
+			}
+			got := tc.args.local.GetCondition(agentresource.TypeConnectionSecretReady)
+			if diff := cmp.Diff(tc.want, got, test.EquateConditions()); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want condition, +got condition:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConnectionSecretKeyEncryption(t *testing.T) {
+	remoteSecret := resource.ClientApplicator{Client: &test.MockClient{
+		MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+			obj.(*corev1.Secret).Data = map[string][]byte{"username": []byte("admin"), "password": []byte("pw")}
+			return nil
+		}),
+	}}
+	// reverse is a fake encryptor that reverses the values, prefixed with
+	// their key.
+	reverse := SecretKeyEncryptFn(func(_ context.Context, key string, value []byte) ([]byte, error) {
+		out := []byte(key + ":")
+		for i := len(value) - 1; i >= 0; i-- {
+			out = append(out, value[i])
+		}
+		return out, nil
+	})
+
+	existing := func(password string, encrypted bool) *corev1.Secret {
+		s := &corev1.Secret{Data: map[string][]byte{"username": []byte("admin"), "password": []byte(password)}}
+		if encrypted {
+			s.SetAnnotations(map[string]string{AnnotationKeyEncryptedKeys: "password"})
+		}
+		return s
+	}
+
+	type want struct {
+		data        map[string][]byte
+		annotations map[string]string
+		err         error
+	}
+	cases := map[string]struct {
+		reason   string
+		opts     []ConnectionSecretPropagatorOption
+		existing *corev1.Secret
+		want
+	}{
+		"Disabled": {
+			reason: "The values should be written as they are unless encryption is enabled",
+			want: want{
+				data: map[string][]byte{"username": []byte("admin"), "password": []byte("pw")},
+			},
+		},
+		"SelectedKeys": {
+			reason: "Only the values of the selected keys should be encrypted, ignoring the selected keys the secret lacks",
+			opts:   []ConnectionSecretPropagatorOption{WithSecretKeyEncryption(reverse, "password", "token")},
+			want: want{
+				data:        map[string][]byte{"username": []byte("admin"), "password": []byte("password:wp")},
+				annotations: map[string]string{AnnotationKeyEncryptedKeys: "password"},
+			},
+		},
+		"EncryptFailed": {
+			reason: "The local secret should not be written if a value cannot be encrypted",
+			opts: []ConnectionSecretPropagatorOption{WithSecretKeyEncryption(SecretKeyEncryptFn(func(_ context.Context, _ string, _ []byte) ([]byte, error) {
+				return nil, errBoom
+			}), "password")},
+			want: want{
+				err: errors.Wrapf(errBoom, errEncryptSecretKeyFmt, "password"),
+			},
+		},
+		"UnchangedKept": {
+			reason:   "An encrypted value of the existing local secret that decrypts to the remote value should be kept",
+			opts:     []ConnectionSecretPropagatorOption{WithSecretKeyEncryption(&countingCipher{}, "password")},
+			existing: existing("sealed-7:pw", true),
+			want: want{
+				data:        map[string][]byte{"username": []byte("admin"), "password": []byte("sealed-7:pw")},
+				annotations: map[string]string{AnnotationKeyEncryptedKeys: "password"},
+			},
+		},
+		"ChangedEncrypted": {
+			reason:   "A value that differs from the decrypted value of the existing local secret should be encrypted again",
+			opts:     []ConnectionSecretPropagatorOption{WithSecretKeyEncryption(&countingCipher{}, "password")},
+			existing: existing("sealed-7:old", true),
+			want: want{
+				data:        map[string][]byte{"username": []byte("admin"), "password": []byte("sealed-1:pw")},
+				annotations: map[string]string{AnnotationKeyEncryptedKeys: "password"},
+			},
+		},
+		"NotEncryptedBefore": {
+			reason:   "A value that the existing local secret doesn't record as encrypted should be encrypted",
+			opts:     []ConnectionSecretPropagatorOption{WithSecretKeyEncryption(&countingCipher{}, "password")},
+			existing: existing("sealed-7:pw", false),
+			want: want{
+				data:        map[string][]byte{"username": []byte("admin"), "password": []byte("sealed-1:pw")},
+				annotations: map[string]string{AnnotationKeyEncryptedKeys: "password"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *corev1.Secret
+			applied := resource.ClientApplicator{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						if tc.existing == nil {
+							return kerrors.NewNotFound(schema.GroupResource{}, "")
+						}
+						tc.existing.DeepCopyInto(obj.(*corev1.Secret))
+						return nil
+					},
+				},
+				Applicator: resource.ApplyFn(func(_ context.Context, obj runtime.Object, _ ...resource.ApplyOption) error {
+					got = obj.(*corev1.Secret)
+					return nil
+				}),
+			}
+			p := NewConnectionSecretPropagator(applied, remoteSecret, tc.opts...)
+			err := p.Propagate(context.Background(), &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}, &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				if got != nil {
+					t.Errorf("\nReason: %s\np.Propagate(...): unexpected write of local secret", tc.reason)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.data, got.Data); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want data, +got data:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.annotations, got.GetAnnotations(), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want annotations, +got annotations:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// A countingCipher is a fake randomized SecretKeyEncryptor and
+// SecretKeyDecryptor that prefixes each value with how many values it
+// encrypted so far.
+type countingCipher struct {
+	n int
+}
+
+func (c *countingCipher) Encrypt(_ context.Context, _ string, value []byte) ([]byte, error) {
+	c.n++
+	return []byte(fmt.Sprintf("sealed-%d:%s", c.n, value)), nil
+}
+
+func (c *countingCipher) Decrypt(_ context.Context, _ string, value []byte) ([]byte, error) {
+	parts := strings.SplitN(string(value), ":", 2)
+	if len(parts) != 2 {
+		return nil, errBoom
+	}
+	return []byte(parts[1]), nil
+}
+
+func TestConnectionSecretRemoteName(t *testing.T) {
+	withStatus := func(status map[string]interface{}) *claim.Unstructured {
+		c := &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()}
+		if status != nil {
+			c.Object["status"] = status
+		}
+		return c
+	}
+	cases := map[string]struct {
+		reason string
+		opts   []ConnectionSecretPropagatorOption
+		remote *claim.Unstructured
+		want   string
+	}{
+		"SpecRef": {
+			reason: "The remote secret should be the one the remote claim refers to by default",
+			remote: withStatus(map[string]interface{}{"connectionSecretName": "generated-s-name"}),
+			want:   "remote-s-name",
+		},
+		"StatusField": {
+			reason: "The remote secret should be the one named by the configured status field",
+			opts:   []ConnectionSecretPropagatorOption{WithRemoteSecretNameFrom("status.connectionSecretName")},
+			remote: withStatus(map[string]interface{}{"connectionSecretName": "generated-s-name"}),
+			want:   "generated-s-name",
+		},
+		"StatusFieldNotSet": {
+			reason: "The remote secret should be the one the remote claim refers to while the configured field is not set",
+			opts:   []ConnectionSecretPropagatorOption{WithRemoteSecretNameFrom("status.connectionSecretName")},
+			remote: withStatus(nil),
+			want:   "remote-s-name",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			remote := resource.ClientApplicator{Client: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+					got = key.Name
+					return nil
+				},
+			}}
+			local := resource.ClientApplicator{
+				Applicator: resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+					return nil
+				}),
+			}
+			p := NewConnectionSecretPropagator(local, remote, tc.opts...)
+			if err := p.Propagate(context.Background(), &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}, tc.remote); err != nil {
+				t.Fatalf("\nReason: %s\np.Propagate(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nremote secret name: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConnectionSecretCleaner(t *testing.T) {
+	owned := map[string]string{LabelKeyManagedBy: LabelValueManagedBy, LabelKeyOwnerUID: "local-uid"}
+	type args struct {
+		local *claim.Unstructured
+		kube  client.Client
+		opts  []ConnectionSecretCleanerOption
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Deleted": {
+			reason: "The secrets created by the agent for the claim should be deleted",
+			args: args{
+				local: &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						obj.(*corev1.Secret).SetLabels(owned)
+						return nil
+					},
+					MockDelete: test.NewMockDeleteFn(nil),
+				},
+			},
+		},
+		"NotOwned": {
+			reason: "The secrets that were not created by the agent for the claim should not be deleted",
+			args: args{
+				local: &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						obj.(*corev1.Secret).SetLabels(map[string]string{LabelKeyManagedBy: LabelValueManagedBy, LabelKeyOwnerUID: "other-uid"})
+						return nil
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+						t.Errorf("\nReason: %s\nDelete should not be called", "The secrets that were not created by the agent for the claim should not be deleted")
+						return nil
+					},
+				},
+			},
+		},
+		"CentralNamespace": {
+			reason: "The secrets should be deleted from the central namespace if one is given",
+			args: args{
+				local: &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+						want := client.ObjectKey{Namespace: "secrets", Name: "local-namespace.local-s-name"}
+						if diff := cmp.Diff(want, key); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The secret should be fetched from the central namespace", diff)
+						}
+						obj.(*corev1.Secret).SetLabels(owned)
+						return nil
+					},
+					MockDelete: test.NewMockDeleteFn(nil),
+				},
+				opts: []ConnectionSecretCleanerOption{WithCleanupNamespace("secrets")},
+			},
+		},
+		"NotFound": {
+			reason: "Should be no-op if the secret does not exist",
+			args: args{
+				local: &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				},
+			},
+		},
+		"GetFailed": {
+			reason: "Should return error if secret cannot be fetched",
+			args: args{
+				local: &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(errBoom),
+				},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, localPrefix+errGetSecret),
+			},
+		},
+		"DeleteFailed": {
+			reason: "Should return error if secret cannot be deleted",
+			args: args{
+				local: &claim.Unstructured{Unstructured: *localClaim.DeepCopy()},
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						obj.(*corev1.Secret).SetLabels(owned)
+						return nil
+					},
+					MockDelete: test.NewMockDeleteFn(errBoom),
+				},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, localPrefix+errDeleteSecret),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := NewConnectionSecretCleaner(tc.args.kube, tc.args.opts...)
+			err := c.Cleanup(context.Background(), tc.args.local)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nc.Cleanup(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errWriteAudit = "cannot write audit record"
+)
+
+// Audit actions.
+const (
+	// AuditActionCreate records the creation of a remote instance.
+	AuditActionCreate = "Create"
+
+	// AuditActionUpdate records the update of an existing remote instance.
+	AuditActionUpdate = "Update"
+)
+
+// An AuditObject identifies an object that is part of an audited change.
+type AuditObject struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid,omitempty"`
+}
+
+// An AuditChange is a change of a single field. Old is null for the fields that
+// are added and New for the ones that are removed.
+type AuditChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// An AuditRecord records a change that the agent applied to a remote instance
+// on behalf of a local instance.
+type AuditRecord struct {
+	Time    time.Time     `json:"time"`
+	Actor   string        `json:"actor"`
+	Action  string        `json:"action"`
+	Local   AuditObject   `json:"local"`
+	Remote  AuditObject   `json:"remote"`
+	Changes []AuditChange `json:"changes"`
+}
+
+// An AuditWriterOption configures an AuditWriter.
+type AuditWriterOption func(*AuditWriter)
+
+// WithAuditActor specifies the actor the changes are recorded to be made by.
+// The default is FieldManager.
+func WithAuditActor(actor string) AuditWriterOption {
+	return func(a *AuditWriter) {
+		a.actor = actor
+	}
+}
+
+// NewAuditWriter returns a new *AuditWriter that writes to the supplied writer.
+func NewAuditWriter(w io.Writer, opts ...AuditWriterOption) *AuditWriter {
+	a := &AuditWriter{w: w, actor: FieldManager, now: time.Now}
+	for _, f := range opts {
+		f(a)
+	}
+	return a
+}
+
+// AuditWriter writes every change the agent applies to the remote instances as
+// a JSON line, e.g. to keep them for compliance. A change covers the labels,
+// annotations and spec of the remote instance, which are what the agent
+// writes.
+type AuditWriter struct {
+	w     io.Writer
+	actor string
+	now   func() time.Time
+
+	mu sync.Mutex
+}
+
+// Record writes the change from the supplied observed remote instance to the
+// supplied written one, which was made on behalf of the supplied local
+// instance. Nothing is written if nothing changed.
+func (a *AuditWriter) Record(local, observed, written *claim.Unstructured) error {
+	action := AuditActionUpdate
+	var before map[string]interface{}
+	if !meta.WasCreated(observed) {
+		action = AuditActionCreate
+	} else {
+		before = auditedFields(observed)
+	}
+	changes := diffValues("", before, auditedFields(written))
+	if len(changes) == 0 {
+		return nil
+	}
+	rec := AuditRecord{
+		Time:    a.now().UTC(),
+		Actor:   a.actor,
+		Action:  action,
+		Local:   auditObject(local),
+		Remote:  auditObject(written),
+		Changes: changes,
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, errWriteAudit)
+	}
+	// The records of concurrent reconciliations must not interleave.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.w.Write(append(b, '\n'))
+	return errors.Wrap(err, errWriteAudit)
+}
+
+func auditObject(c *claim.Unstructured) AuditObject {
+	return AuditObject{
+		APIVersion: c.GetAPIVersion(),
+		Kind:       c.GetKind(),
+		Namespace:  c.GetNamespace(),
+		Name:       c.GetName(),
+		UID:        c.GetUID(),
+	}
+}
+
+// auditedFields returns the fields of the supplied instance that are audited.
+func auditedFields(c *claim.Unstructured) map[string]interface{} {
+	out := map[string]interface{}{}
+	if l := c.GetLabels(); len(l) > 0 {
+		out["metadata.labels"] = stringMap(l)
+	}
+	if a := c.GetAnnotations(); len(a) > 0 {
+		out["metadata.annotations"] = stringMap(a)
+	}
+	if s, ok := c.GetUnstructured().UnstructuredContent()["spec"]; ok {
+		out["spec"] = s
+	}
+	return out
+}
+
+func stringMap(in map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+var plainKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// child returns the field path of the given key of the object at given path.
+// The keys of labels and annotations and the ones that are not plain field
+// names are bracketed. The top-level keys are field paths already.
+func child(path, key string) string {
+	switch {
+	case path == "":
+		return key
+	case plainKey.MatchString(key) && path != "metadata.labels" && path != "metadata.annotations":
+		return path + "." + key
+	default:
+		return path + "[" + key + "]"
+	}
+}
+
+// diffValues returns the changes from the old value at given path to the new
+// one, in field path order. Objects and arrays are compared field by field, and
+// other values as a whole.
+func diffValues(path string, oldVal, newVal interface{}) []AuditChange {
+	om, oIsMap := oldVal.(map[string]interface{})
+	nm, nIsMap := newVal.(map[string]interface{})
+	if (oIsMap || oldVal == nil) && (nIsMap || newVal == nil) && (oIsMap || nIsMap) {
+		keys := map[string]bool{}
+		for k := range om {
+			keys[k] = true
+		}
+		for k := range nm {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		var changes []AuditChange
+		for _, k := range sorted {
+			changes = append(changes, diffValues(child(path, k), om[k], nm[k])...)
+		}
+		return changes
+	}
+	oa, oIsArr := oldVal.([]interface{})
+	na, nIsArr := newVal.([]interface{})
+	if oIsArr && nIsArr {
+		var changes []AuditChange
+		for i := 0; i < len(oa) || i < len(na); i++ {
+			var o, n interface{}
+			if i < len(oa) {
+				o = oa[i]
+			}
+			if i < len(na) {
+				n = na[i]
+			}
+			changes = append(changes, diffValues(fmt.Sprintf("%s[%d]", path, i), o, n)...)
+		}
+		return changes
+	}
+	// The numbers may be decoded as int64 or float64 depending on where they
+	// come from, so we compare their JSON encoding.
+	if jsonEqual(oldVal, newVal) {
+		return nil
+	}
+	return []AuditChange{{Path: path, Old: oldVal, New: newVal}}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestAuditWriterRecord(t *testing.T) {
+	now := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+	newClaim := func(uid types.UID, created bool, spec map[string]interface{}, labels map[string]string) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetNamespace("default")
+		c.SetName("cool-db")
+		c.SetUID(uid)
+		c.SetLabels(labels)
+		if created {
+			c.SetCreationTimestamp(metav1.NewTime(now.Add(-time.Hour)))
+		}
+		if spec != nil {
+			c.Object["spec"] = spec
+		}
+		return c
+	}
+	type args struct {
+		observed *claim.Unstructured
+		written  *claim.Unstructured
+		opts     []AuditWriterOption
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want string
+	}{
+		"Create": {
+			reason: "The creation of a remote instance should be recorded with all of its fields",
+			args: args{
+				observed: newClaim("", false, nil, nil),
+				written:  newClaim("remote-uid", true, map[string]interface{}{"size": int64(10)}, map[string]string{"team": "a"}),
+			},
+			want: `{"time":"2020-09-01T10:00:00Z","actor":"crossplane-agent","action":"Create",` +
+				`"local":{"apiVersion":"example.org/v1","kind":"Database","namespace":"default","name":"cool-db","uid":"local-uid"},` +
+				`"remote":{"apiVersion":"example.org/v1","kind":"Database","namespace":"default","name":"cool-db","uid":"remote-uid"},` +
+				`"changes":[{"path":"metadata.labels[team]","old":null,"new":"a"},{"path":"spec.size","old":null,"new":10}]}` + "\n",
+		},
+		"Update": {
+			reason: "The update of a remote instance should be recorded with the fields that changed",
+			args: args{
+				observed: newClaim("remote-uid", true, map[string]interface{}{
+					"size":  int64(10),
+					"tier":  "gold",
+					"zones": []interface{}{"a", "b"},
+				}, map[string]string{"example.org/team": "a"}),
+				written: newClaim("remote-uid", true, map[string]interface{}{
+					"size":  int64(20),
+					"zones": []interface{}{"a", "c", "d"},
+				}, map[string]string{"example.org/team": "a"}),
+				opts: []AuditWriterOption{WithAuditActor("agent-eu")},
+			},
+			want: `{"time":"2020-09-01T10:00:00Z","actor":"agent-eu","action":"Update",` +
+				`"local":{"apiVersion":"example.org/v1","kind":"Database","namespace":"default","name":"cool-db","uid":"local-uid"},` +
+				`"remote":{"apiVersion":"example.org/v1","kind":"Database","namespace":"default","name":"cool-db","uid":"remote-uid"},` +
+				`"changes":[{"path":"spec.size","old":10,"new":20},{"path":"spec.tier","old":"gold","new":null},` +
+				`{"path":"spec.zones[1]","old":"b","new":"c"},{"path":"spec.zones[2]","old":null,"new":"d"}]}` + "\n",
+		},
+		"Unchanged": {
+			reason: "Nothing should be recorded if nothing changed, regardless of how the numbers are decoded",
+			args: args{
+				observed: newClaim("remote-uid", true, map[string]interface{}{"size": int64(10)}, nil),
+				written:  newClaim("remote-uid", true, map[string]interface{}{"size": float64(10)}, nil),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			a := NewAuditWriter(buf, tc.args.opts...)
+			a.now = func() time.Time { return now }
+			if err := a.Record(newClaim("local-uid", true, nil, nil), tc.args.observed, tc.args.written); err != nil {
+				t.Fatalf("\nReason: %s\na.Record(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("\nReason: %s\na.Record(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileAuditWriter(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				local.SetName("cool-db")
+				local.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				local.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	remote := &test.MockClient{
+		MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			obj.(*unstructured.Unstructured).SetUID("remote-uid")
+			return nil
+		},
+	}
+	buf := &bytes.Buffer{}
+	a := NewAuditWriter(buf)
+	a.now = func() time.Time { return time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC) }
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithAuditWriter(a),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	want := `{"time":"2020-09-01T10:00:00Z","actor":"crossplane-agent","action":"Create",` +
+		`"local":{"apiVersion":"example.org/v1","kind":"Database","name":"cool-db"},` +
+		`"remote":{"apiVersion":"example.org/v1","kind":"Database","name":"cool-db","uid":"remote-uid"},` +
+		`"changes":[{"path":"metadata.annotations[agent.crossplane.io/local-name]","old":null,"new":"cool-db"},` +
+		`{"path":"metadata.annotations[agent.crossplane.io/local-uid]","old":null,"new":""},` +
+		`{"path":"metadata.annotations[agent.crossplane.io/managed-spec]","old":null,"new":"[\"spec.size\"]"},` +
+		`{"path":"spec.size","old":null,"new":10}]}` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", "The creation of the remote instance should be recorded", diff)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+// An ErrorClass classifies why the sync of a claim failed.
+type ErrorClass string
+
+// Error classes.
+const (
+	// ErrorClassTransient errors are expected to go away by themselves, e.g.
+	// a connection that timed out.
+	ErrorClassTransient ErrorClass = "Transient"
+
+	// ErrorClassThrottled errors are returned by an API server that asks the
+	// agent to slow down.
+	ErrorClassThrottled ErrorClass = "Throttled"
+
+	// ErrorClassConflict errors are returned when an object was changed since
+	// it was read.
+	ErrorClassConflict ErrorClass = "Conflict"
+
+	// ErrorClassPermanent errors go away only when something is changed by a
+	// user, e.g. an invalid spec or a remote instance that is not managed by
+	// the agent.
+	ErrorClassPermanent ErrorClass = "Permanent"
+)
+
+// ClassifyError returns the class of the supplied error.
+func ClassifyError(err error) ErrorClass {
+	err = errors.Cause(err)
+	switch {
+	case kerrors.IsConflict(err):
+		return ErrorClassConflict
+	case kerrors.IsTooManyRequests(err):
+		return ErrorClassThrottled
+	case kerrors.IsInvalid(err), kerrors.IsForbidden(err):
+		return ErrorClassPermanent
+	}
+	if _, ok := kerrors.SuggestsClientDelay(err); ok {
+		return ErrorClassThrottled
+	}
+	return ErrorClassTransient
+}
+
+// A BackoffStrategy decides how long to wait before retrying the sync of a
+// claim.
+type BackoffStrategy interface {
+	// Backoff returns how long to wait before retrying a claim that failed to
+	// be synced for the given number of consecutive attempts, the last one
+	// with an error of the given class.
+	Backoff(attempt int, class ErrorClass) time.Duration
+}
+
+// BackoffFn is used to construct a BackoffStrategy with a bare function.
+type BackoffFn func(attempt int, class ErrorClass) time.Duration
+
+// Backoff calls the supplied function.
+func (fn BackoffFn) Backoff(attempt int, class ErrorClass) time.Duration {
+	return fn(attempt, class)
+}
+
+// DefaultBackoffStrategy returns the BackoffStrategy that is used unless
+// another one is supplied.
+func DefaultBackoffStrategy() *ExponentialBackoff {
+	return &ExponentialBackoff{
+		Base:      shortWait,
+		Permanent: longWait,
+		Factor:    2,
+		Max:       10 * time.Minute,
+	}
+}
+
+// ExponentialBackoff waits longer after each consecutive failed attempt.
+type ExponentialBackoff struct {
+	// Base is how long to wait after the first failed attempt.
+	Base time.Duration
+
+	// Permanent is how long to wait after the first failed attempt if it
+	// failed with an ErrorClassPermanent error. Base is used if it's zero.
+	Permanent time.Duration
+
+	// Factor is what the wait is multiplied with after each failed attempt.
+	Factor float64
+
+	// Max is the longest wait. There's no limit if it's zero.
+	Max time.Duration
+}
+
+// Backoff returns the wait after the given attempt, which counts from one.
+func (b *ExponentialBackoff) Backoff(attempt int, class ErrorClass) time.Duration {
+	d := b.Base
+	if class == ErrorClassPermanent && b.Permanent > 0 {
+		d = b.Permanent
+	}
+	if attempt > 1 && b.Factor > 1 {
+		d = time.Duration(float64(d) * math.Pow(b.Factor, float64(attempt-1)))
+	}
+	// The wait overflows if there were enough attempts.
+	if b.Max > 0 && (d > b.Max || d <= 0) {
+		d = b.Max
+	}
+	return d
+}
+
+// failureCounter counts the consecutive failed sync attempts of claims in
+// memory.
+type failureCounter struct {
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+func newFailureCounter() *failureCounter {
+	return &failureCounter{failures: map[string]int{}}
+}
+
+// Attempt returns the number of the attempt that is in progress for the claim
+// with given key if it fails, counting from one.
+func (c *failureCounter) Attempt(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failures[key] + 1
+}
+
+// Observe records the outcome of a sync attempt of the claim with given key
+// according to its AgentSynced condition.
+func (c *failureCounter) Observe(key string, cond v1alpha1.Condition) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cond.Reason == resource.ReasonAgentSyncError {
+		c.failures[key]++
+		return
+	}
+	delete(c.failures, key)
+}
+
+// Forget stops tracking the claim with given key, i.e. when it's deleted.
+func (c *failureCounter) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.failures, key)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDefaultBackoffStrategy(t *testing.T) {
+	type args struct {
+		attempts int
+		class    ErrorClass
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want []time.Duration
+	}{
+		"Transient": {
+			reason: "The wait should double after each failed attempt until it reaches the maximum",
+			args:   args{attempts: 7, class: ErrorClassTransient},
+			want: []time.Duration{
+				30 * time.Second,
+				1 * time.Minute,
+				2 * time.Minute,
+				4 * time.Minute,
+				8 * time.Minute,
+				10 * time.Minute,
+				10 * time.Minute,
+			},
+		},
+		"Permanent": {
+			reason: "The wait should start longer for the errors that need a user to change something",
+			args:   args{attempts: 5, class: ErrorClassPermanent},
+			want: []time.Duration{
+				1 * time.Minute,
+				2 * time.Minute,
+				4 * time.Minute,
+				8 * time.Minute,
+				10 * time.Minute,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := DefaultBackoffStrategy()
+			got := make([]time.Duration, tc.args.attempts)
+			for i := range got {
+				got[i] = b.Backoff(i+1, tc.args.class)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nb.Backoff(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoffOverflow(t *testing.T) {
+	b := DefaultBackoffStrategy()
+	if diff := cmp.Diff(b.Max, b.Backoff(1000, ErrorClassTransient)); diff != "" {
+		t.Errorf("b.Backoff(...): the wait should not overflow after many attempts: -want, +got:\n%s", diff)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   ErrorClass
+	}{
+		"Generic": {
+			reason: "An unknown error should be transient",
+			err:    errBoom,
+			want:   ErrorClassTransient,
+		},
+		"Conflict": {
+			reason: "A wrapped conflict should be classified as such",
+			err:    errors.Wrap(kerrors.NewConflict(schema.GroupResource{}, "cool", errBoom), "cannot update"),
+			want:   ErrorClassConflict,
+		},
+		"TooManyRequests": {
+			reason: "An API server that asks the agent to slow down should be throttling",
+			err:    kerrors.NewTooManyRequests("slow down", 10),
+			want:   ErrorClassThrottled,
+		},
+		"Invalid": {
+			reason: "An invalid object should be a permanent error",
+			err:    kerrors.NewInvalid(schema.GroupKind{}, "cool", nil),
+			want:   ErrorClassPermanent,
+		},
+		"Forbidden": {
+			reason: "A forbidden request should be a permanent error",
+			err:    kerrors.NewForbidden(schema.GroupResource{}, "cool", errBoom),
+			want:   ErrorClassPermanent,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, ClassifyError(tc.err)); diff != "" {
+				t.Errorf("\nReason: %s\nClassifyError(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+// NewRemoteBuilder returns a new *RemoteBuilder that configures the remote
+// instances with the supplied Configurator.
+func NewRemoteBuilder(c Configurator) *RemoteBuilder {
+	return &RemoteBuilder{configurator: c}
+}
+
+// A RemoteBuilder builds the remote instance that should be written for a
+// local instance. It's the only place remote instances are constructed, so
+// that a remote instance that is recreated, e.g. after it was deleted in the
+// remote cluster, gets the same name, namespace and ownership annotations it
+// had, since they're derived from the local instance alone.
+type RemoteBuilder struct {
+	configurator Configurator
+}
+
+// Build returns the desired remote instance of the supplied local instance.
+// It starts from a copy of the observed remote instance so that the fields
+// owned by the remote instance are kept, or from scratch if the observed one
+// doesn't exist. The observed remote instance is not modified.
+func (b *RemoteBuilder) Build(ctx context.Context, local, observed *claim.Unstructured) (*claim.Unstructured, error) {
+	desired := claim.New(claim.WithGroupVersionKind(observed.GroupVersionKind()))
+	if meta.WasCreated(observed) {
+		desired = &claim.Unstructured{Unstructured: *observed.GetUnstructured().DeepCopy()}
+	}
+	if err := b.configurator.Configure(ctx, local, desired); err != nil {
+		return nil, err
+	}
+	return desired, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestRemoteBuilder(t *testing.T) {
+	newLocal := func() *claim.Unstructured {
+		l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		l.SetName("cool-claim")
+		l.SetGenerateName("cool-")
+		l.SetNamespace("cool-ns")
+		l.SetUID("luid")
+		l.SetAnnotations(map[string]string{"cool": "annotation"})
+		l.SetLabels(map[string]string{"cool": "label"})
+		l.Object["spec"] = map[string]interface{}{"size": "10"}
+		return l
+	}
+	// identity is what the remote instance of newLocal must have no matter
+	// whether it's updated or recreated.
+	type identity struct {
+		Name         string
+		GenerateName string
+		Namespace    string
+		Annotations  map[string]string
+		Labels       map[string]string
+		Spec         interface{}
+	}
+	want := identity{
+		Name:        "cool-claim",
+		Namespace:   "cool-ns",
+		Annotations: map[string]string{"cool": "annotation", AnnotationKeyLocalUID: "luid", AnnotationKeyLocalName: "cool-claim", AnnotationKeyLocalNamespace: "cool-ns", AnnotationKeyManagedLabels: "cool", AnnotationKeyManagedSpec: `["spec.size"]`},
+		Labels:      map[string]string{"cool": "label"},
+		Spec:        map[string]interface{}{"size": "10"},
+	}
+	type args struct {
+		c        Configurator
+		observed *claim.Unstructured
+	}
+	type wantResult struct {
+		identity identity
+		server   bool
+		err      error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want wantResult
+	}{
+		"Existing": {
+			reason: "An existing remote instance should get its identity from the local instance and keep its server fields",
+			args: args{
+				observed: func() *claim.Unstructured {
+					o := claim.New(claim.WithGroupVersionKind(scopedGVK))
+					o.SetName("cool-claim")
+					o.SetNamespace("cool-ns")
+					o.SetUID("ruid")
+					o.SetResourceVersion("42")
+					o.SetCreationTimestamp(metav1.Now())
+					o.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid", AnnotationKeyManagedLabels: "stale", "stale": "annotation"})
+					o.SetLabels(map[string]string{"stale": "label"})
+					o.Object["spec"] = map[string]interface{}{"size": "5"}
+					o.Object["status"] = map[string]interface{}{"phase": "Ready"}
+					return o
+				}(),
+			},
+			want: wantResult{identity: want, server: true},
+		},
+		"Recreated": {
+			reason: "A remote instance that is recreated should get the same identity it had",
+			args: args{
+				observed: claim.New(claim.WithGroupVersionKind(scopedGVK)),
+			},
+			want: wantResult{identity: want},
+		},
+		"RecreatedWithLeftovers": {
+			reason: "A remote instance that is recreated should be built from scratch even if leftovers of the deleted one were observed",
+			args: args{
+				observed: func() *claim.Unstructured {
+					o := claim.New(claim.WithGroupVersionKind(scopedGVK))
+					o.SetName("old-name")
+					o.SetResourceVersion("42")
+					o.SetAnnotations(map[string]string{"stale": "annotation"})
+					return o
+				}(),
+			},
+			want: wantResult{identity: want},
+		},
+		"ConfigureFailed": {
+			reason: "An error should be returned if the remote instance cannot be configured",
+			args: args{
+				c: ConfigureFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+					return errBoom
+				}),
+				observed: claim.New(claim.WithGroupVersionKind(scopedGVK)),
+			},
+			want: wantResult{err: errBoom},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := tc.args.c
+			if c == nil {
+				c = NewDefaultConfigurator(DefaultFieldPolicies())
+			}
+			observed := tc.args.observed.GetUnstructured().DeepCopy()
+			got, err := NewRemoteBuilder(c).Build(context.Background(), newLocal(), tc.args.observed)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("\nReason: %s\nb.Build(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(observed, tc.args.observed.GetUnstructured()); diff != "" {
+				t.Errorf("\nReason: %s\nb.Build(...) should not modify the observed instance: -want, +got:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			gotIdentity := identity{
+				Name:         got.GetName(),
+				GenerateName: got.GetGenerateName(),
+				Namespace:    got.GetNamespace(),
+				Annotations:  got.GetAnnotations(),
+				Labels:       got.GetLabels(),
+				Spec:         got.Object["spec"],
+			}
+			if diff := cmp.Diff(tc.want.identity, gotIdentity); diff != "" {
+				t.Errorf("\nReason: %s\nb.Build(...): -want identity, +got identity:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.server, got.GetResourceVersion() != ""); diff != "" {
+				t.Errorf("\nReason: %s\nb.Build(...): -want server fields, +got server fields:\n%s", tc.reason, diff)
+			}
+			if _, ok := got.Object["status"]; ok {
+				t.Errorf("\nReason: %s\nb.Build(...): the status should be dropped", tc.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	errDiscoverGroups     = "cannot discover API groups"
+	errMissingGroupsFmt   = "remote cluster does not serve the Crossplane API groups %s; check that the agent points to a cluster that runs a compatible version of Crossplane"
+	defaultCapabilityTTL  = 5 * time.Minute
+	crossplaneAPIExtGroup = "apiextensions.crossplane.io"
+
+	errGetVersionConfigMap  = "cannot get ConfigMap with the version of Crossplane"
+	errVersionKeyFmt        = "ConfigMap %s has no version of Crossplane at key %q"
+	errGetVersionDeployment = "cannot get Deployment of Crossplane"
+	errNoContainersFmt      = "Deployment %s has no containers"
+	errImageTagFmt          = "image %q of Deployment %s has no tag"
+	errParseVersionFmt      = "cannot parse %q as the version of Crossplane"
+	errVersionRangeFmt      = "remote cluster runs Crossplane %s, which is outside of the supported range %s"
+	errEmptyVersionRange    = "minimum version of Crossplane must be lower than the maximum one"
+
+	// crossplaneContainer is the name of the container of the Deployment
+	// that runs Crossplane.
+	crossplaneContainer = "crossplane"
+
+	// versionCheckTimeout bounds reading the version of Crossplane, which is
+	// done outside of any reconciliation.
+	versionCheckTimeout = 30 * time.Second
+)
+
+// DefaultRequiredGroups returns the API groups that a remote cluster has to
+// serve for the agent to work.
+func DefaultRequiredGroups() []string {
+	return []string{crossplaneAPIExtGroup}
+}
+
+// A CapabilityCheckerOption configures a CapabilityChecker.
+type CapabilityCheckerOption func(*CapabilityChecker)
+
+// WithRequiredGroups specifies the API groups that the remote cluster has to
+// serve. The default is DefaultRequiredGroups.
+func WithRequiredGroups(groups ...string) CapabilityCheckerOption {
+	return func(c *CapabilityChecker) {
+		c.required = groups
+	}
+}
+
+// WithCheckInterval specifies how long the result of a check is reused before
+// checking the remote cluster again.
+func WithCheckInterval(d time.Duration) CapabilityCheckerOption {
+	return func(c *CapabilityChecker) {
+		c.ttl = d
+	}
+}
+
+// WithVersionCheck makes the CapabilityChecker verify that the remote cluster
+// runs a version of Crossplane within the supplied range too, as read by the
+// supplied CrossplaneVersionReader.
+func WithVersionCheck(r CrossplaneVersionReader, vr CrossplaneVersionRange) CapabilityCheckerOption {
+	return func(c *CapabilityChecker) {
+		c.version = r
+		c.supported = vr
+	}
+}
+
+// NewCapabilityChecker returns a new *CapabilityChecker that uses the given
+// discovery client of the remote cluster.
+func NewCapabilityChecker(d discovery.ServerGroupsInterface, opts ...CapabilityCheckerOption) *CapabilityChecker {
+	c := &CapabilityChecker{
+		discovery: d,
+		required:  DefaultRequiredGroups(),
+		ttl:       defaultCapabilityTTL,
+		now:       time.Now,
+	}
+	for _, f := range opts {
+		f(c)
+	}
+	return c
+}
+
+// CapabilityChecker verifies that the remote cluster serves the Crossplane API
+// groups the agent needs, so that the agent doesn't write claims to a cluster
+// that doesn't run Crossplane or runs an incompatible version of it.
+type CapabilityChecker struct {
+	discovery discovery.ServerGroupsInterface
+	required  []string
+	version   CrossplaneVersionReader
+	supported CrossplaneVersionRange
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	checked  time.Time
+	checking bool
+	err      error
+}
+
+// Check returns an error if the remote cluster lacks any of the required API
+// groups, or runs a version of Crossplane outside of the supported range if the
+// version is checked. The result is reused until the check interval passes.
+// The remote cluster is checked by one caller at a time, without blocking the
+// others, which get the previous result meanwhile if there is one.
+func (c *CapabilityChecker) Check() error {
+	c.mu.Lock()
+	if !c.checked.IsZero() && (c.checking || c.now().Sub(c.checked) < c.ttl) {
+		defer c.mu.Unlock()
+		return c.err
+	}
+	c.checking = true
+	c.mu.Unlock()
+
+	err := c.check()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	c.checked = c.now()
+	c.checking = false
+	return err
+}
+
+func (c *CapabilityChecker) check() error {
+	l, err := c.discovery.ServerGroups()
+	if err != nil {
+		return errors.Wrap(err, errDiscoverGroups)
+	}
+	served := map[string]bool{}
+	for _, g := range l.Groups {
+		served[g.Name] = true
+	}
+	var missing []string
+	for _, g := range c.required {
+		if !served[g] {
+			missing = append(missing, g)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf(errMissingGroupsFmt, strings.Join(missing, ", "))
+	}
+	if c.version == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+	v, err := c.version.CrossplaneVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if !c.supported.Contains(v) {
+		return errors.Errorf(errVersionRangeFmt, v, c.supported)
+	}
+	return nil
+}
+
+// A CrossplaneVersionReader reads the version of Crossplane that the remote
+// cluster runs.
+type CrossplaneVersionReader interface {
+	CrossplaneVersion(ctx context.Context) (*version.Version, error)
+}
+
+// A ConfigMapVersionReaderOption configures a ConfigMapVersionReader.
+type ConfigMapVersionReaderOption func(*ConfigMapVersionReader)
+
+// WithVersionFallback makes the ConfigMapVersionReader read the version with
+// the supplied CrossplaneVersionReader if the ConfigMap doesn't exist, e.g. a
+// DeploymentVersionReader, since a standard installation of Crossplane has no
+// such ConfigMap.
+func WithVersionFallback(fb CrossplaneVersionReader) ConfigMapVersionReaderOption {
+	return func(r *ConfigMapVersionReader) {
+		r.fallback = fb
+	}
+}
+
+// NewConfigMapVersionReader returns a new *ConfigMapVersionReader that reads
+// the version from the given key of the given ConfigMap.
+func NewConfigMapVersionReader(c client.Reader, nn types.NamespacedName, key string, o ...ConfigMapVersionReaderOption) *ConfigMapVersionReader {
+	r := &ConfigMapVersionReader{client: c, name: nn, key: key}
+	for _, fn := range o {
+		fn(r)
+	}
+	return r
+}
+
+// A ConfigMapVersionReader reads the version of Crossplane from a well-known
+// ConfigMap of the remote cluster, e.g. one that is maintained along with the
+// installation of Crossplane.
+type ConfigMapVersionReader struct {
+	client   client.Reader
+	name     types.NamespacedName
+	key      string
+	fallback CrossplaneVersionReader
+}
+
+// CrossplaneVersion returns the semantic version in the ConfigMap, with or
+// without a leading v.
+func (r *ConfigMapVersionReader) CrossplaneVersion(ctx context.Context) (*version.Version, error) {
+	cm := &v1.ConfigMap{}
+	if err := r.client.Get(ctx, r.name, cm); err != nil {
+		if kerrors.IsNotFound(err) && r.fallback != nil {
+			return r.fallback.CrossplaneVersion(ctx)
+		}
+		return nil, errors.Wrap(err, errGetVersionConfigMap)
+	}
+	s, ok := cm.Data[r.key]
+	if !ok {
+		return nil, errors.Errorf(errVersionKeyFmt, r.name, r.key)
+	}
+	v, err := version.ParseSemantic(strings.TrimSpace(s))
+	return v, errors.Wrapf(err, errParseVersionFmt, s)
+}
+
+// NewDeploymentVersionReader returns a new *DeploymentVersionReader that reads
+// the version from the image of the given Deployment.
+func NewDeploymentVersionReader(c client.Reader, nn types.NamespacedName) *DeploymentVersionReader {
+	return &DeploymentVersionReader{client: c, name: nn}
+}
+
+// A DeploymentVersionReader reads the version of Crossplane from the tag of
+// the image of the Deployment that runs Crossplane in the remote cluster, e.g.
+// crossplane/crossplane:v0.14.0.
+type DeploymentVersionReader struct {
+	client client.Reader
+	name   types.NamespacedName
+}
+
+// CrossplaneVersion returns the semantic version in the image tag of the
+// container named crossplane, or of the first container if there's none.
+func (r *DeploymentVersionReader) CrossplaneVersion(ctx context.Context) (*version.Version, error) {
+	d := &appsv1.Deployment{}
+	if err := r.client.Get(ctx, r.name, d); err != nil {
+		return nil, errors.Wrap(err, errGetVersionDeployment)
+	}
+	cs := d.Spec.Template.Spec.Containers
+	if len(cs) == 0 {
+		return nil, errors.Errorf(errNoContainersFmt, r.name)
+	}
+	image := cs[0].Image
+	for _, c := range cs {
+		if c.Name == crossplaneContainer {
+			image = c.Image
+		}
+	}
+	tag := imageTag(image)
+	if tag == "" {
+		return nil, errors.Errorf(errImageTagFmt, image, r.name)
+	}
+	v, err := version.ParseSemantic(tag)
+	return v, errors.Wrapf(err, errParseVersionFmt, tag)
+}
+
+// imageTag returns the tag of the supplied image reference, if any, ignoring
+// its digest.
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
+// A CrossplaneVersionRange is a range of versions of Crossplane, from Min,
+// inclusive, to Max, exclusive, e.g. from 0.13.0 to 1.0.0 to support all patch
+// releases of 0.13 and 0.14. An unset bound doesn't limit the range.
+type CrossplaneVersionRange struct {
+	Min *version.Version
+	Max *version.Version
+}
+
+// ParseCrossplaneVersionRange parses the supplied semantic versions as a
+// CrossplaneVersionRange. An empty version leaves its bound unset.
+func ParseCrossplaneVersionRange(min, max string) (CrossplaneVersionRange, error) {
+	lo, err := parseVersionBound(min)
+	if err != nil {
+		return CrossplaneVersionRange{}, err
+	}
+	hi, err := parseVersionBound(max)
+	if err != nil {
+		return CrossplaneVersionRange{}, err
+	}
+	if lo != nil && hi != nil && !lo.LessThan(hi) {
+		return CrossplaneVersionRange{}, errors.New(errEmptyVersionRange)
+	}
+	return CrossplaneVersionRange{Min: lo, Max: hi}, nil
+}
+
+func parseVersionBound(s string) (*version.Version, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := version.ParseSemantic(s)
+	return v, errors.Wrapf(err, errParseVersionFmt, s)
+}
+
+// Contains returns true if the supplied version is within the range.
+func (vr CrossplaneVersionRange) Contains(v *version.Version) bool {
+	if vr.Min != nil && v.LessThan(vr.Min) {
+		return false
+	}
+	return vr.Max == nil || v.LessThan(vr.Max)
+}
+
+// String returns the range in [min, max) form, with * for an unset bound.
+func (vr CrossplaneVersionRange) String() string {
+	min, max := "*", "*"
+	if vr.Min != nil {
+		min = vr.Min.String()
+	}
+	if vr.Max != nil {
+		max = vr.Max.String()
+	}
+	return "[" + min + ", " + max + ")"
+}
@@ -0,0 +1,402 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func newFakeDiscovery(groupVersions ...string) *fakediscovery.FakeDiscovery {
+	f := &clienttesting.Fake{}
+	for _, gv := range groupVersions {
+		f.Resources = append(f.Resources, &metav1.APIResourceList{GroupVersion: gv})
+	}
+	return &fakediscovery.FakeDiscovery{Fake: f}
+}
+
+type serverGroupsFn func() (*metav1.APIGroupList, error)
+
+func (fn serverGroupsFn) ServerGroups() (*metav1.APIGroupList, error) { return fn() }
+
+type crossplaneVersionFn func() (*version.Version, error)
+
+func (fn crossplaneVersionFn) CrossplaneVersion(_ context.Context) (*version.Version, error) {
+	return fn()
+}
+
+func runsCrossplane(v string) crossplaneVersionFn {
+	return func() (*version.Version, error) { return version.MustParseSemantic(v), nil }
+}
+
+func TestCapabilityChecker(t *testing.T) {
+	supported := CrossplaneVersionRange{Min: version.MustParseSemantic("v0.13.0"), Max: version.MustParseSemantic("v1.0.0")}
+
+	type args struct {
+		discovery discovery.ServerGroupsInterface
+		opts      []CapabilityCheckerOption
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want error
+	}{
+		"Compatible": {
+			reason: "A remote cluster that serves the Crossplane API groups should pass the check",
+			args: args{
+				discovery: newFakeDiscovery("v1", "apiextensions.crossplane.io/v1alpha1"),
+			},
+		},
+		"NotCrossplane": {
+			reason: "A remote cluster that does not serve the Crossplane API groups should fail the check",
+			args: args{
+				discovery: newFakeDiscovery("v1", "apps/v1"),
+			},
+			want: errors.Errorf(errMissingGroupsFmt, crossplaneAPIExtGroup),
+		},
+		"CustomGroups": {
+			reason: "A remote cluster should be checked against the custom required groups",
+			args: args{
+				discovery: newFakeDiscovery("apiextensions.crossplane.io/v1alpha1", "example.org/v1"),
+				opts:      []CapabilityCheckerOption{WithRequiredGroups("example.org", "other.org")},
+			},
+			want: errors.Errorf(errMissingGroupsFmt, "other.org"),
+		},
+		"DiscoveryFailed": {
+			reason: "An error should be returned if the groups cannot be discovered",
+			args: args{
+				discovery: serverGroupsFn(func() (*metav1.APIGroupList, error) {
+					return nil, errBoom
+				}),
+			},
+			want: errors.Wrap(errBoom, errDiscoverGroups),
+		},
+		"VersionInRange": {
+			reason: "A remote cluster that runs a supported version of Crossplane should pass the check",
+			args: args{
+				discovery: newFakeDiscovery("apiextensions.crossplane.io/v1alpha1"),
+				opts:      []CapabilityCheckerOption{WithVersionCheck(runsCrossplane("v0.14.2"), supported)},
+			},
+		},
+		"VersionBelowRange": {
+			reason: "A remote cluster that runs an older version of Crossplane than supported should fail the check",
+			args: args{
+				discovery: newFakeDiscovery("apiextensions.crossplane.io/v1alpha1"),
+				opts:      []CapabilityCheckerOption{WithVersionCheck(runsCrossplane("v0.12.0"), supported)},
+			},
+			want: errors.Errorf(errVersionRangeFmt, "0.12.0", "[0.13.0, 1.0.0)"),
+		},
+		"VersionAboveRange": {
+			reason: "A remote cluster that runs the next major version of Crossplane should fail the check",
+			args: args{
+				discovery: newFakeDiscovery("apiextensions.crossplane.io/v1alpha1"),
+				opts:      []CapabilityCheckerOption{WithVersionCheck(runsCrossplane("v1.0.0"), supported)},
+			},
+			want: errors.Errorf(errVersionRangeFmt, "1.0.0", "[0.13.0, 1.0.0)"),
+		},
+		"VersionUnknown": {
+			reason: "An error should be returned if the version of Crossplane cannot be read",
+			args: args{
+				discovery: newFakeDiscovery("apiextensions.crossplane.io/v1alpha1"),
+				opts: []CapabilityCheckerOption{WithVersionCheck(crossplaneVersionFn(func() (*version.Version, error) {
+					return nil, errBoom
+				}), supported)},
+			},
+			want: errBoom,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := NewCapabilityChecker(tc.args.discovery, tc.args.opts...)
+			if diff := cmp.Diff(tc.want, c.Check(), test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nc.Check(): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCapabilityCheckerInterval(t *testing.T) {
+	d := newFakeDiscovery("v1")
+	now := time.Now()
+	c := NewCapabilityChecker(d, WithCheckInterval(time.Minute))
+	c.now = func() time.Time { return now }
+
+	if err := c.Check(); err == nil {
+		t.Errorf("c.Check(): expected error for a cluster without Crossplane")
+	}
+
+	// Crossplane is installed, but the previous result is still reused.
+	d.Resources = append(d.Resources, &metav1.APIResourceList{GroupVersion: "apiextensions.crossplane.io/v1alpha1"})
+	if err := c.Check(); err == nil {
+		t.Errorf("c.Check(): expected the result to be reused within the check interval")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := c.Check(); err != nil {
+		t.Errorf("c.Check(): expected the remote to be checked again after the interval: %s", err)
+	}
+}
+
+func TestCapabilityCheckerNotBlocking(t *testing.T) {
+	now := time.Now()
+	entered, release := make(chan struct{}), make(chan struct{})
+	slow := false
+	d := serverGroupsFn(func() (*metav1.APIGroupList, error) {
+		if slow {
+			close(entered)
+			<-release
+		}
+		return &metav1.APIGroupList{Groups: []metav1.APIGroup{{Name: crossplaneAPIExtGroup}}}, nil
+	})
+	c := NewCapabilityChecker(d, WithCheckInterval(time.Minute))
+	c.now = func() time.Time { return now }
+	if err := c.Check(); err != nil {
+		t.Fatalf("c.Check(): %s", err)
+	}
+
+	// The next check of the remote cluster hangs until it's released.
+	slow = true
+	now = now.Add(2 * time.Minute)
+	done := make(chan error)
+	go func() { done <- c.Check() }()
+	<-entered
+	if err := c.Check(); err != nil {
+		t.Errorf("c.Check(): expected the previous result while the remote cluster is checked: %s", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("c.Check(): %s", err)
+	}
+}
+
+func TestConfigMapVersionReader(t *testing.T) {
+	nn := types.NamespacedName{Namespace: "crossplane-system", Name: "crossplane-version"}
+	type want struct {
+		v   string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		data   map[string]string
+		getErr error
+		want
+	}{
+		"Version": {
+			reason: "The version in the ConfigMap should be returned with or without a leading v",
+			data:   map[string]string{"version": "v0.14.0-rc.1\n"},
+			want: want{
+				v: "0.14.0-rc.1",
+			},
+		},
+		"NoVersion": {
+			reason: "An error should be returned if the ConfigMap has no version",
+			data:   map[string]string{"other": "v0.14.0"},
+			want: want{
+				err: errors.Errorf(errVersionKeyFmt, nn, "version"),
+			},
+		},
+		"InvalidVersion": {
+			reason: "An error should be returned if the version is not a semantic version",
+			data:   map[string]string{"version": "latest"},
+			want: want{
+				err: errors.Wrapf(errors.New(`could not parse "latest" as version`), errParseVersionFmt, "latest"),
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the ConfigMap cannot be read",
+			getErr: errBoom,
+			want: want{
+				err: errors.Wrap(errBoom, errGetVersionConfigMap),
+			},
+		},
+		"NotFoundFallback": {
+			reason: "The version should be read with the fallback if the ConfigMap doesn't exist",
+			getErr: kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, nn.Name),
+			want: want{
+				v: "0.13.0",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				if tc.getErr != nil {
+					return tc.getErr
+				}
+				if diff := cmp.Diff(nn, key); diff != "" {
+					t.Errorf("\nReason: %s\nc.Get(...): -want key, +got key:\n%s", tc.reason, diff)
+				}
+				obj.(*v1.ConfigMap).Data = tc.data
+				return nil
+			}}
+			v, err := NewConfigMapVersionReader(c, nn, "version", WithVersionFallback(runsCrossplane("v0.13.0"))).CrossplaneVersion(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.CrossplaneVersion(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			got := ""
+			if v != nil {
+				got = v.String()
+			}
+			if diff := cmp.Diff(tc.want.v, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.CrossplaneVersion(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDeploymentVersionReader(t *testing.T) {
+	nn := types.NamespacedName{Namespace: "crossplane-system", Name: "crossplane"}
+	type want struct {
+		v   string
+		err error
+	}
+	cases := map[string]struct {
+		reason     string
+		containers []v1.Container
+		getErr     error
+		want
+	}{
+		"Tag": {
+			reason:     "The version should be read from the image tag of the crossplane container",
+			containers: []v1.Container{{Name: "sidecar", Image: "example.org/sidecar:v9.9.9"}, {Name: "crossplane", Image: "registry.example.org:5000/crossplane/crossplane:v0.14.0"}},
+			want: want{
+				v: "0.14.0",
+			},
+		},
+		"Digest": {
+			reason:     "The digest of the image should be ignored",
+			containers: []v1.Container{{Name: "core", Image: "crossplane/crossplane:v0.13.0@sha256:abcd"}},
+			want: want{
+				v: "0.13.0",
+			},
+		},
+		"NoTag": {
+			reason:     "An error should be returned if the image has no tag",
+			containers: []v1.Container{{Name: "crossplane", Image: "registry.example.org:5000/crossplane/crossplane"}},
+			want: want{
+				err: errors.Errorf(errImageTagFmt, "registry.example.org:5000/crossplane/crossplane", nn),
+			},
+		},
+		"NoContainers": {
+			reason: "An error should be returned if the Deployment has no containers",
+			want: want{
+				err: errors.Errorf(errNoContainersFmt, nn),
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the Deployment cannot be read",
+			getErr: errBoom,
+			want: want{
+				err: errors.Wrap(errBoom, errGetVersionDeployment),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				if tc.getErr != nil {
+					return tc.getErr
+				}
+				if diff := cmp.Diff(nn, key); diff != "" {
+					t.Errorf("\nReason: %s\nc.Get(...): -want key, +got key:\n%s", tc.reason, diff)
+				}
+				obj.(*appsv1.Deployment).Spec.Template.Spec.Containers = tc.containers
+				return nil
+			}}
+			v, err := NewDeploymentVersionReader(c, nn).CrossplaneVersion(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.CrossplaneVersion(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			got := ""
+			if v != nil {
+				got = v.String()
+			}
+			if diff := cmp.Diff(tc.want.v, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.CrossplaneVersion(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseCrossplaneVersionRange(t *testing.T) {
+	type args struct {
+		min string
+		max string
+	}
+	type want struct {
+		r   string
+		err bool
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Bounded": {
+			reason: "Both bounds should be parsed",
+			args:   args{min: "v0.13.0", max: "v1.0.0"},
+			want:   want{r: "[0.13.0, 1.0.0)"},
+		},
+		"Unbounded": {
+			reason: "An empty bound should leave the range open",
+			args:   args{min: "v0.13.0"},
+			want:   want{r: "[0.13.0, *)"},
+		},
+		"Empty": {
+			reason: "A range whose minimum is not lower than its maximum should be rejected",
+			args:   args{min: "v1.0.0", max: "v1.0.0"},
+			want:   want{err: true},
+		},
+		"Invalid": {
+			reason: "A bound that is not a semantic version should be rejected",
+			args:   args{max: "v1"},
+			want:   want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, err := ParseCrossplaneVersionRange(tc.args.min, tc.args.max)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\nReason: %s\nParseCrossplaneVersionRange(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.r, r.String()); diff != "" {
+				t.Errorf("\nReason: %s\nParseCrossplaneVersionRange(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errNameRemote   = "cannot determine remote name of claim"
+	errCollisionFmt = "claim would be synced to remote claim %s together with %s"
+)
+
+// A RemoteNamer determines the namespace and name of the remote instance of a
+// local instance.
+type RemoteNamer interface {
+	RemoteName(local *claim.Unstructured) (types.NamespacedName, error)
+}
+
+// A RemoteNamerFn is a function that satisfies the RemoteNamer interface.
+type RemoteNamerFn func(local *claim.Unstructured) (types.NamespacedName, error)
+
+// RemoteName calls the supplied function.
+func (fn RemoteNamerFn) RemoteName(local *claim.Unstructured) (types.NamespacedName, error) {
+	return fn(local)
+}
+
+// NewScopeNamer returns a RemoteNamer that names the remote instances of the
+// given remote kind the way the Reconciler does, i.e. after their local
+// instances and in the namespace the supplied ScopeResolver places them in. The
+// remote instances are in the namespaces of their local instances if the
+// ScopeResolver is nil.
+func NewScopeNamer(s *ScopeResolver, remote schema.GroupVersionKind) RemoteNamer {
+	return RemoteNamerFn(func(local *claim.Unstructured) (types.NamespacedName, error) {
+		nn := types.NamespacedName{Namespace: local.GetNamespace(), Name: local.GetName()}
+		if s == nil {
+			return nn, nil
+		}
+		ns, err := s.RemoteNamespace(remote, local.GetNamespace())
+		nn.Namespace = ns
+		return nn, err
+	})
+}
+
+// A Collision is a set of local instances that would be synced to the same
+// remote instance, overwriting each other.
+type Collision struct {
+	// Remote is the remote instance the local instances would be synced to.
+	Remote types.NamespacedName
+
+	// Locals are the colliding local instances, sorted.
+	Locals []types.NamespacedName
+}
+
+// FindCollisions returns the local instances among the supplied ones that the
+// supplied RemoteNamer gives the same remote instance, sorted by the remote
+// instance.
+func FindCollisions(locals []*claim.Unstructured, n RemoteNamer) ([]Collision, error) {
+	byRemote := map[types.NamespacedName][]types.NamespacedName{}
+	for _, l := range locals {
+		rnn, err := n.RemoteName(l)
+		if err != nil {
+			return nil, errors.Wrap(err, errNameRemote)
+		}
+		byRemote[rnn] = append(byRemote[rnn], types.NamespacedName{Namespace: l.GetNamespace(), Name: l.GetName()})
+	}
+	var out []Collision
+	for rnn, lnns := range byRemote {
+		if len(lnns) < 2 {
+			continue
+		}
+		sort.Slice(lnns, func(i, j int) bool { return lnns[i].String() < lnns[j].String() })
+		out = append(out, Collision{Remote: rnn, Locals: lnns})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Remote.String() < out[j].Remote.String() })
+	return out, nil
+}
+
+// A CollisionDetectorOption configures a CollisionDetector.
+type CollisionDetectorOption func(*CollisionDetector)
+
+// WithRemoteNamer specifies how the CollisionDetector names the remote
+// instances. The default is the naming of the Reconciler, see NewScopeNamer.
+func WithRemoteNamer(n RemoteNamer) CollisionDetectorOption {
+	return func(d *CollisionDetector) {
+		d.namer = n
+	}
+}
+
+// NewCollisionDetector returns a new *CollisionDetector that lists the local
+// instances of the given kind with the supplied client.
+func NewCollisionDetector(kube client.Reader, gvk schema.GroupVersionKind, opts ...CollisionDetectorOption) *CollisionDetector {
+	d := &CollisionDetector{kube: kube, gvk: gvk, namer: NewScopeNamer(nil, gvk)}
+	for _, f := range opts {
+		f(d)
+	}
+	return d
+}
+
+// A CollisionDetector detects the local instances of a kind that would be
+// synced to the same remote instance, e.g. because of a namespace mapping
+// that maps two namespaces to one, before any of them is synced. All local
+// instances are scanned once, the first time any of them is checked.
+type CollisionDetector struct {
+	kube  client.Reader
+	gvk   schema.GroupVersionKind
+	namer RemoteNamer
+
+	mu        sync.Mutex
+	scanned   bool
+	colliding map[types.NamespacedName]Collision
+}
+
+// Scan lists all local instances and records the ones that collide.
+func (d *CollisionDetector) Scan(ctx context.Context) ([]Collision, error) {
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(d.gvk.GroupVersion().WithKind(d.gvk.Kind + "List"))
+	if err := d.kube.List(ctx, l); err != nil {
+		return nil, errors.Wrap(err, errListClaims)
+	}
+	locals := make([]*claim.Unstructured, len(l.Items))
+	for i := range l.Items {
+		locals[i] = &claim.Unstructured{Unstructured: l.Items[i]}
+	}
+	collisions, err := FindCollisions(locals, d.namer)
+	if err != nil {
+		return nil, err
+	}
+	colliding := map[types.NamespacedName]Collision{}
+	for _, c := range collisions {
+		for _, nn := range c.Locals {
+			colliding[nn] = c
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scanned = true
+	d.colliding = colliding
+	return collisions, nil
+}
+
+// Check returns the Collision the supplied local instance is part of, if any.
+// The local instances are scanned again if it collided in the last scan, so
+// that it's released once the collision is resolved.
+func (d *CollisionDetector) Check(ctx context.Context, local *claim.Unstructured) (*Collision, error) {
+	nn := types.NamespacedName{Namespace: local.GetNamespace(), Name: local.GetName()}
+	d.mu.Lock()
+	_, colliding := d.colliding[nn]
+	scan := !d.scanned || colliding
+	d.mu.Unlock()
+	if scan {
+		if _, err := d.Scan(ctx); err != nil {
+			return nil, err
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.colliding[nn]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// Err returns the error the supplied local instance of the Collision is
+// reported with.
+func (c Collision) Err(local types.NamespacedName) error {
+	others := make([]string, 0, len(c.Locals)-1)
+	for _, o := range c.Locals {
+		if o != local {
+			others = append(others, o.String())
+		}
+	}
+	return errors.Errorf(errCollisionFmt, c.Remote, strings.Join(others, ", "))
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func newLocalClaim(ns, name string) *claim.Unstructured {
+	c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+	c.SetNamespace(ns)
+	c.SetName(name)
+	return c
+}
+
+func TestFindCollisions(t *testing.T) {
+	mapped := NewScopeNamer(NewScopeResolver(newVersionedDiscovery(map[string][]string{"v1": {"Database"}}),
+		WithNamespaceMappings(map[string]string{"team-a": "shared", "team-b": "shared"})), scopedGVK)
+	type want struct {
+		collisions []Collision
+		err        error
+	}
+	cases := map[string]struct {
+		reason string
+		locals []*claim.Unstructured
+		namer  RemoteNamer
+		want
+	}{
+		"MappedToSameNamespace": {
+			reason: "Two local instances of the same name in namespaces mapped to the same remote namespace should collide",
+			locals: []*claim.Unstructured{newLocalClaim("team-b", "db"), newLocalClaim("team-a", "db"), newLocalClaim("team-a", "cache")},
+			namer:  mapped,
+			want: want{
+				collisions: []Collision{{
+					Remote: types.NamespacedName{Namespace: "shared", Name: "db"},
+					Locals: []types.NamespacedName{{Namespace: "team-a", Name: "db"}, {Namespace: "team-b", Name: "db"}},
+				}},
+			},
+		},
+		"DistinctNames": {
+			reason: "Local instances in their own remote namespaces should not collide",
+			locals: []*claim.Unstructured{newLocalClaim("team-a", "db"), newLocalClaim("team-c", "db")},
+			namer:  mapped,
+		},
+		"NamerError": {
+			reason: "An error should be returned if a remote instance cannot be named",
+			locals: []*claim.Unstructured{newLocalClaim("team-a", "db")},
+			namer: RemoteNamerFn(func(_ *claim.Unstructured) (types.NamespacedName, error) {
+				return types.NamespacedName{}, errBoom
+			}),
+			want: want{
+				err: errors.Wrap(errBoom, errNameRemote),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := FindCollisions(tc.locals, tc.namer)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nFindCollisions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.collisions, got); diff != "" {
+				t.Errorf("\nReason: %s\nFindCollisions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCollisionDetectorCheck(t *testing.T) {
+	locals := []*claim.Unstructured{newLocalClaim("team-a", "db"), newLocalClaim("team-b", "db")}
+	lists := 0
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+			lists++
+			l := obj.(*unstructured.UnstructuredList)
+			for _, c := range locals {
+				l.Items = append(l.Items, *c.GetUnstructured())
+			}
+			return nil
+		},
+	}
+	// All remote instances are in one namespace.
+	d := NewCollisionDetector(kube, scopedGVK, WithRemoteNamer(RemoteNamerFn(func(l *claim.Unstructured) (types.NamespacedName, error) {
+		return types.NamespacedName{Namespace: "shared", Name: l.GetName()}, nil
+	})))
+
+	c, err := d.Check(context.Background(), locals[0])
+	if err != nil {
+		t.Fatalf("d.Check(...): unexpected error: %s", err)
+	}
+	want := &Collision{
+		Remote: types.NamespacedName{Namespace: "shared", Name: "db"},
+		Locals: []types.NamespacedName{{Namespace: "team-a", Name: "db"}, {Namespace: "team-b", Name: "db"}},
+	}
+	if diff := cmp.Diff(want, c); diff != "" {
+		t.Errorf("\nReason: %s\nd.Check(...): -want, +got:\n%s", "The colliding local instances should be detected", diff)
+	}
+
+	// The colliding local instance is renamed.
+	locals[1] = newLocalClaim("team-b", "other-db")
+	c, err = d.Check(context.Background(), locals[0])
+	if err != nil {
+		t.Fatalf("d.Check(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff((*Collision)(nil), c); diff != "" {
+		t.Errorf("\nReason: %s\nd.Check(...): -want, +got:\n%s", "A local instance should be released once its collision is resolved", diff)
+	}
+	if _, err := d.Check(context.Background(), locals[1]); err != nil {
+		t.Fatalf("d.Check(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(2, lists); diff != "" {
+		t.Errorf("\nReason: %s\nlists: -want, +got:\n%s", "The local instances should only be scanned again to check the colliding ones", diff)
+	}
+}
+
+func TestReconcileCollisionDetection(t *testing.T) {
+	var synced *claim.Unstructured
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				newLocalClaim("team-a", "db").DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+				l := obj.(*unstructured.UnstructuredList)
+				l.Items = append(l.Items, *newLocalClaim("team-a", "db").GetUnstructured(), *newLocalClaim("team-b", "db").GetUnstructured())
+				return nil
+			},
+			MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				synced = &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+				return nil
+			},
+		},
+	}
+	// The remote cluster is never read.
+	remote := &test.MockClient{}
+	scope := NewScopeResolver(newVersionedDiscovery(map[string][]string{"v1": {"Database"}}),
+		WithNamespaceMappings(map[string]string{"team-a": "shared", "team-b": "shared"}))
+	r := NewReconciler(m, remote, scopedGVK, WithScopeResolver(scope), WithCollisionDetection())
+	got, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "db"}})
+	if err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: longWait}, got); diff != "" {
+		t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", "A colliding claim should be checked again later", diff)
+	}
+	want := resource.AgentSyncError(errors.Errorf(errCollisionFmt, "shared/db", "team-b/db"))
+	if diff := cmp.Diff(want, synced.GetCondition(resource.TypeAgentSync), test.EquateConditions()); diff != "" {
+		t.Errorf("\nReason: %s\nAgentSynced: -want, +got:\n%s", "A colliding claim should be reported as not synced", diff)
+	}
+}
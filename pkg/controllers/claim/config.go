@@ -0,0 +1,165 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/agent/apis/agent/v1alpha1"
+)
+
+const (
+	errGetConfig             = "cannot get agent configuration"
+	errUnknownFieldPolicyFmt = "unknown policy %q for field %q"
+)
+
+// ReconcilerOptionsFor returns the ReconcilerOptions that configure the
+// Reconciler as the supplied AgentConfig spec specifies. The supplied
+// discovery client of the remote cluster is used to resolve the namespaces
+// of the remote instances. The namespace settings of the spec are applied on
+// top of the supplied ScopeResolverOptions, e.g. the ones of the flags, so that
+// the allowlist, the cluster namespace and namespace creation still apply.
+func ReconcilerOptionsFor(spec v1alpha1.AgentConfigSpec, d discovery.ServerResourcesInterface, scope ...ScopeResolverOption) ([]ReconcilerOption, error) {
+	var opts []ReconcilerOption
+	if len(spec.FieldPolicies) > 0 {
+		p := DefaultFieldPolicies()
+		for path, fp := range spec.FieldPolicies {
+			switch FieldPolicy(fp) {
+			case FieldPolicyLocal, FieldPolicyRemote, FieldPolicyLateInit, FieldPolicyMerge:
+				p[path] = FieldPolicy(fp)
+			default:
+				return nil, errors.Errorf(errUnknownFieldPolicyFmt, fp, path)
+			}
+		}
+		opts = append(opts, WithFieldPolicies(p))
+	}
+	if len(spec.RemoteDefaultedFields) > 0 {
+		opts = append(opts, WithRemoteDefaultedFields(spec.RemoteDefaultedFields...))
+	}
+	if len(spec.ReflectedAnnotations) > 0 {
+		opts = append(opts, WithReflectedAnnotations(spec.ReflectedAnnotations...))
+	}
+	if len(spec.InputSecretRefs) > 0 {
+		opts = append(opts, WithInputSecretRefs(spec.InputSecretRefs...))
+	}
+	if pc := spec.Propagators; pc != nil {
+		if pc.Spec != nil {
+			opts = append(opts, WithSpecPropagation(*pc.Spec))
+		}
+		if pc.Status != nil {
+			opts = append(opts, WithStatusPropagation(*pc.Status))
+		}
+		if pc.Secret != nil {
+			opts = append(opts, WithSecretPropagation(*pc.Secret))
+		}
+		if pc.LateInit != nil {
+			opts = append(opts, WithLateInitialization(*pc.LateInit))
+		}
+	}
+	if len(spec.NamespaceMappings) > 0 || spec.DefaultRemoteNamespace != "" {
+		so := append(append([]ScopeResolverOption{}, scope...), WithNamespaceMappings(spec.NamespaceMappings))
+		if spec.DefaultRemoteNamespace != "" {
+			so = append(so, WithDefaultRemoteNamespace(spec.DefaultRemoteNamespace))
+		}
+		opts = append(opts, WithScopeResolver(NewScopeResolver(d, so...)))
+	}
+	if len(spec.RemoteLabels) > 0 || len(spec.RemoteAnnotations) > 0 {
+		opts = append(opts, WithRemoteMetadata(spec.RemoteLabels, spec.RemoteAnnotations))
+	}
+	if spec.PostApplyVerify != "" {
+		opts = append(opts, WithPostApplyVerify(VerifyMode(spec.PostApplyVerify)))
+	}
+	if spec.InjectProviderConfig != "" {
+		opts = append(opts, WithProviderConfigInjection(spec.InjectProviderConfig))
+	}
+	if spec.Maintenance {
+		opts = append(opts, WithMaintenance(true))
+	}
+	return opts, nil
+}
+
+// A ConfigLoaderOption configures a ConfigLoader.
+type ConfigLoaderOption func(*ConfigLoader)
+
+// WithConfigScopeOptions specifies the ScopeResolverOptions that the namespace
+// settings of the AgentConfig are applied on top of, e.g. the ones of the flags.
+func WithConfigScopeOptions(opts ...ScopeResolverOption) ConfigLoaderOption {
+	return func(l *ConfigLoader) {
+		l.scope = append(l.scope, opts...)
+	}
+}
+
+// WithConfigMaintenanceSwitch makes the ConfigLoader set the supplied
+// MaintenanceSwitch as the AgentConfig specifies whenever it loads it, so that
+// the controllers that share it are put in maintenance mode too.
+func WithConfigMaintenanceSwitch(s *MaintenanceSwitch) ConfigLoaderOption {
+	return func(l *ConfigLoader) {
+		l.maintenance = s
+	}
+}
+
+// NewConfigLoader returns a new *ConfigLoader that loads the AgentConfig with
+// the given name.
+func NewConfigLoader(kube client.Reader, name string, d discovery.ServerResourcesInterface, o ...ConfigLoaderOption) *ConfigLoader {
+	l := &ConfigLoader{kube: kube, name: name, discovery: d}
+	for _, fn := range o {
+		fn(l)
+	}
+	return l
+}
+
+// ConfigLoader loads the ReconcilerOptions from an AgentConfig.
+type ConfigLoader struct {
+	kube        client.Reader
+	name        string
+	discovery   discovery.ServerResourcesInterface
+	scope       []ScopeResolverOption
+	maintenance *MaintenanceSwitch
+}
+
+// Load returns the ReconcilerOptions that the AgentConfig specifies and the
+// resource version of the AgentConfig, which changes whenever the options may
+// change. No options and an empty version are returned if the AgentConfig does
+// not exist. The MaintenanceSwitch, if any, is set as the AgentConfig specifies.
+func (l *ConfigLoader) Load(ctx context.Context) ([]ReconcilerOption, string, error) {
+	c := &v1alpha1.AgentConfig{}
+	if err := l.kube.Get(ctx, types.NamespacedName{Name: l.name}, c); err != nil {
+		if kerrors.IsNotFound(err) {
+			l.setMaintenance(false)
+			return nil, "", nil
+		}
+		return nil, "", errors.Wrap(err, errGetConfig)
+	}
+	l.setMaintenance(c.Spec.Maintenance)
+	opts, err := ReconcilerOptionsFor(c.Spec, l.discovery, l.scope...)
+	if err != nil {
+		return nil, "", err
+	}
+	return opts, c.GetResourceVersion(), nil
+}
+
+func (l *ConfigLoader) setMaintenance(enabled bool) {
+	if l.maintenance != nil {
+		l.maintenance.Set(enabled)
+	}
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/apis/agent/v1alpha1"
+)
+
+// configured is the part of a Reconciler that an AgentConfig can affect.
+type configured struct {
+	Policies        FieldPolicies
+	DisableSpec     bool
+	DisableStatus   bool
+	DisableSecret   bool
+	DisableLateInit bool
+	Maintenance     bool
+	Configurators   []string
+}
+
+func configuredBy(opts []ReconcilerOption) configured {
+	r := NewReconciler(&fake.Manager{Client: &test.MockClient{}}, &test.MockClient{}, gvk, opts...)
+	c := configured{
+		Policies:        r.policies,
+		DisableSpec:     r.disableSpec,
+		DisableStatus:   r.disableStatus,
+		DisableSecret:   r.disableSecret,
+		DisableLateInit: r.disableLateInit,
+		Maintenance:     r.maintenance,
+		Configurators:   []string{},
+	}
+	for _, cf := range r.configurators {
+		c.Configurators = append(c.Configurators, fmt.Sprintf("%T", cf))
+	}
+	return c
+}
+
+func TestReconcilerOptionsFor(t *testing.T) {
+	disabled := false
+	policies := DefaultFieldPolicies()
+	policies["spec.parameters.size"] = FieldPolicyRemote
+
+	type want struct {
+		c   configured
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		spec   v1alpha1.AgentConfigSpec
+		want   want
+	}{
+		"Empty": {
+			reason: "An empty configuration should not change the defaults",
+			want: want{
+				c: configuredBy(nil),
+			},
+		},
+		"FieldPolicies": {
+			reason: "The field policies should be merged onto the default ones",
+			spec: v1alpha1.AgentConfigSpec{
+				FieldPolicies: map[string]v1alpha1.FieldPolicy{"spec.parameters.size": v1alpha1.FieldPolicy(FieldPolicyRemote)},
+			},
+			want: want{
+				c: func() configured {
+					c := configuredBy(nil)
+					c.Policies = policies
+					return c
+				}(),
+			},
+		},
+		"RemoteDefaultedFields": {
+			reason: "The remotely defaulted fields should be late-initialized",
+			spec: v1alpha1.AgentConfigSpec{
+				RemoteDefaultedFields: []string{"spec.parameters.size"},
+			},
+			want: want{
+				c: func() configured {
+					c := configuredBy(nil)
+					c.Policies = DefaultFieldPolicies().WithRemoteDefaults("spec.parameters.size")
+					return c
+				}(),
+			},
+		},
+		"ReflectedAnnotations": {
+			reason: "The reflected annotations should be owned by the remote instance",
+			spec: v1alpha1.AgentConfigSpec{
+				ReflectedAnnotations: []string{"cost.example.org/monthly"},
+			},
+			want: want{
+				c: func() configured {
+					c := configuredBy(nil)
+					c.Policies = DefaultFieldPolicies().WithRemoteAnnotations("cost.example.org/monthly")
+					return c
+				}(),
+			},
+		},
+		"UnknownFieldPolicy": {
+			reason: "An error should be returned if a field policy is unknown",
+			spec: v1alpha1.AgentConfigSpec{
+				FieldPolicies: map[string]v1alpha1.FieldPolicy{"spec.parameters.size": "Cool"},
+			},
+			want: want{
+				err: errors.Errorf(errUnknownFieldPolicyFmt, "Cool", "spec.parameters.size"),
+			},
+		},
+		"Propagators": {
+			reason: "Only the propagators that are set should be configured",
+			spec: v1alpha1.AgentConfigSpec{
+				Propagators: &v1alpha1.PropagatorsConfig{Status: &disabled, LateInit: &disabled},
+			},
+			want: want{
+				c: func() configured {
+					c := configuredBy(nil)
+					c.DisableStatus = true
+					c.DisableLateInit = true
+					return c
+				}(),
+			},
+		},
+		"Maintenance": {
+			reason: "The maintenance mode should be enabled if it's set",
+			spec: v1alpha1.AgentConfigSpec{
+				Maintenance: true,
+			},
+			want: want{
+				c: func() configured {
+					c := configuredBy(nil)
+					c.Maintenance = true
+					return c
+				}(),
+			},
+		},
+		"Configurators": {
+			reason: "The namespace, metadata and provider config settings should add their configurators",
+			spec: v1alpha1.AgentConfigSpec{
+				NamespaceMappings:    map[string]string{"cool-ns": "remote-ns"},
+				RemoteLabels:         map[string]string{"tenant": "cool"},
+				InjectProviderConfig: "default",
+			},
+			want: want{
+				c: func() configured {
+					c := configuredBy(nil)
+					c.Configurators = append(c.Configurators, "*claim.ScopeResolver", "*claim.MetadataInjector", "*claim.ProviderConfigInjector")
+					return c
+				}(),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts, err := ReconcilerOptionsFor(tc.spec, newScopedDiscovery(true))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nReconcilerOptionsFor(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.c, configuredBy(opts)); diff != "" {
+				t.Errorf("\nReason: %s\nReconcilerOptionsFor(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcilerOptionsForScope(t *testing.T) {
+	type args struct {
+		spec  v1alpha1.AgentConfigSpec
+		scope []ScopeResolverOption
+		local string
+	}
+	type want struct {
+		ns  string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"MappingApplied": {
+			reason: "The namespace mappings of the spec should be applied",
+			args: args{
+				spec:  v1alpha1.AgentConfigSpec{NamespaceMappings: map[string]string{"cool-ns": "remote-ns"}},
+				local: "cool-ns",
+			},
+			want: want{ns: "remote-ns"},
+		},
+		"AllowlistKept": {
+			reason: "The allowlist of the supplied options should still apply to the namespace mappings of the spec",
+			args: args{
+				spec:  v1alpha1.AgentConfigSpec{NamespaceMappings: map[string]string{"cool-ns": "other-ns"}},
+				scope: []ScopeResolverOption{WithAllowedRemoteNamespaces("remote-ns")},
+				local: "cool-ns",
+			},
+			want: want{err: errors.Errorf(errNotAllowedFmt, "other-ns")},
+		},
+		"ClusterNamespaceKept": {
+			reason: "The cluster namespace of the supplied options should take precedence over the namespace mappings of the spec",
+			args: args{
+				spec:  v1alpha1.AgentConfigSpec{NamespaceMappings: map[string]string{"cool-ns": "remote-ns"}},
+				scope: []ScopeResolverOption{WithClusterNamespace("cool-cluster")},
+				local: "cool-ns",
+			},
+			want: want{ns: ClusterNamespace("cool-cluster")},
+		},
+		"DefaultNamespaceKept": {
+			reason: "The default remote namespace of the supplied options should be kept if the spec doesn't set one",
+			args: args{
+				spec:  v1alpha1.AgentConfigSpec{NamespaceMappings: map[string]string{"cool-ns": "remote-ns"}},
+				scope: []ScopeResolverOption{WithDefaultRemoteNamespace("default-ns")},
+			},
+			want: want{ns: "default-ns"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts, err := ReconcilerOptionsFor(tc.args.spec, newScopedDiscovery(true), tc.args.scope...)
+			if err != nil {
+				t.Fatalf("\nReason: %s\nReconcilerOptionsFor(...): %s", tc.reason, err)
+			}
+			base := WithScopeResolver(NewScopeResolver(newScopedDiscovery(true), tc.args.scope...))
+			r := NewReconciler(&fake.Manager{Client: &test.MockClient{}}, &test.MockClient{}, gvk, append([]ReconcilerOption{base}, opts...)...)
+			var resolvers []*ScopeResolver
+			for _, cf := range r.configurators {
+				if sr, ok := cf.(*ScopeResolver); ok {
+					resolvers = append(resolvers, sr)
+				}
+			}
+			if diff := cmp.Diff(1, len(resolvers)); diff != "" {
+				t.Fatalf("\nReason: %s\nReconcilerOptionsFor(...): -want ScopeResolvers, +got ScopeResolvers:\n%s", tc.reason, diff)
+			}
+			s := resolvers[0]
+			ns, err := s.RemoteNamespace(scopedGVK, tc.args.local)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\ns.RemoteNamespace(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ns, ns); diff != "" {
+				t.Errorf("\nReason: %s\ns.RemoteNamespace(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConfigLoaderLoad(t *testing.T) {
+	type want struct {
+		opts        int
+		version     string
+		maintenance bool
+		err         error
+	}
+	cases := map[string]struct {
+		reason string
+		kube   client.Reader
+		want   want
+	}{
+		"NotFound": {
+			reason: "No options should be returned if the AgentConfig does not exist",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the AgentConfig cannot be retrieved",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(errBoom),
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetConfig),
+			},
+		},
+		"Maintenance": {
+			reason: "The maintenance switch should be enabled if the AgentConfig enables the maintenance mode",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+					c := obj.(*v1alpha1.AgentConfig)
+					c.SetResourceVersion("4")
+					c.Spec.Maintenance = true
+					return nil
+				}),
+			},
+			want: want{
+				opts:        1,
+				version:     "4",
+				maintenance: true,
+			},
+		},
+		"Found": {
+			reason: "The options and the resource version of the AgentConfig should be returned",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+					c := obj.(*v1alpha1.AgentConfig)
+					c.SetResourceVersion("3")
+					c.Spec.InjectProviderConfig = "default"
+					return nil
+				}),
+			},
+			want: want{
+				opts:    1,
+				version: "3",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// The switch is enabled beforehand to see it disabled unless the
+			// AgentConfig enables the maintenance mode.
+			m := NewMaintenanceSwitch(false)
+			m.Set(true)
+			opts, version, err := NewConfigLoader(tc.kube, "default", newScopedDiscovery(true), WithConfigMaintenanceSwitch(m)).Load(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nl.Load(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.opts, len(opts)); diff != "" {
+				t.Errorf("\nReason: %s\nl.Load(...): -want options, +got options:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.version, version); diff != "" {
+				t.Errorf("\nReason: %s\nl.Load(...): -want version, +got version:\n%s", tc.reason, diff)
+			}
+			if err == nil {
+				if diff := cmp.Diff(tc.want.maintenance, m.Enabled()); diff != "" {
+					t.Errorf("\nReason: %s\nl.Load(...): -want maintenance, +got maintenance:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
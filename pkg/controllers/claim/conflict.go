@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+// A ConflictAction is what the Reconciler does about a write of a remote
+// instance that conflicted with a change made since it was read.
+type ConflictAction string
+
+// Conflict actions.
+const (
+	// ConflictActionForce writes the remote instance again without the
+	// precondition on its resource version, overwriting the change.
+	ConflictActionForce ConflictAction = "Force"
+
+	// ConflictActionSkip leaves the remote instance as it is until the next
+	// sync, and propagates the observed one to the local instance.
+	ConflictActionSkip ConflictAction = "Skip"
+
+	// ConflictActionRetry reports the conflict and retries the sync after the
+	// wait of the ConflictResolution.
+	ConflictActionRetry ConflictAction = "Retry"
+)
+
+// A Conflict describes a write of a remote instance that conflicted.
+type Conflict struct {
+	// Local is the local instance being synced.
+	Local *claim.Unstructured
+
+	// Observed is the remote instance as it was read before the write.
+	Observed *claim.Unstructured
+
+	// Desired is the remote instance that was written.
+	Desired *claim.Unstructured
+
+	// Err is the conflict error returned by the remote cluster.
+	Err error
+}
+
+// A ConflictResolution is what a ConflictHandler decided to do about a
+// Conflict.
+type ConflictResolution struct {
+	Action ConflictAction
+
+	// RetryAfter is how long to wait before retrying the sync if the Action
+	// is ConflictActionRetry. The backoff of the Reconciler is used if it's
+	// zero.
+	RetryAfter time.Duration
+}
+
+// A ConflictHandler decides what to do about the writes of remote instances
+// that conflict.
+type ConflictHandler interface {
+	HandleConflict(ctx context.Context, c Conflict) ConflictResolution
+}
+
+// A ConflictHandlerFn is a function that satisfies the ConflictHandler
+// interface.
+type ConflictHandlerFn func(ctx context.Context, c Conflict) ConflictResolution
+
+// HandleConflict calls the supplied function.
+func (fn ConflictHandlerFn) HandleConflict(ctx context.Context, c Conflict) ConflictResolution {
+	return fn(ctx, c)
+}
+
+// IsConflict returns true if the supplied error, or its cause, is a conflict
+// error returned by an API server.
+func IsConflict(err error) bool {
+	return kerrors.IsConflict(errors.Cause(err))
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func TestReconcileConflictHandler(t *testing.T) {
+	errConflict := kerrors.NewConflict(schema.GroupResource{}, "cool-db", errBoom)
+	type want struct {
+		result reconcile.Result
+		// versions are the resource versions each patch was conditional on.
+		versions []string
+		handled  bool
+		synced   v1alpha1.ConditionReason
+	}
+	cases := map[string]struct {
+		reason  string
+		handler ConflictHandler
+		want    want
+	}{
+		"Force": {
+			reason: "The remote instance should be written again unconditionally if the handler forces the write",
+			handler: ConflictHandlerFn(func(_ context.Context, _ Conflict) ConflictResolution {
+				return ConflictResolution{Action: ConflictActionForce}
+			}),
+			want: want{
+				result:   reconcile.Result{RequeueAfter: longWait},
+				versions: []string{"42", ""},
+				handled:  true,
+				synced:   resource.ReasonAgentSyncSuccess,
+			},
+		},
+		"Skip": {
+			reason: "The remote instance should be left as it is if the handler skips the write",
+			handler: ConflictHandlerFn(func(_ context.Context, _ Conflict) ConflictResolution {
+				return ConflictResolution{Action: ConflictActionSkip}
+			}),
+			want: want{
+				result:   reconcile.Result{RequeueAfter: longWait},
+				versions: []string{"42"},
+				handled:  true,
+				synced:   resource.ReasonAgentSyncConflictSkipped,
+			},
+		},
+		"RetryAfter": {
+			reason: "The sync should be retried after the wait the handler asks for",
+			handler: ConflictHandlerFn(func(_ context.Context, _ Conflict) ConflictResolution {
+				return ConflictResolution{Action: ConflictActionRetry, RetryAfter: 30 * time.Second}
+			}),
+			want: want{
+				result:   reconcile.Result{RequeueAfter: 30 * time.Second},
+				versions: []string{"42"},
+				handled:  true,
+				synced:   resource.ReasonAgentSyncError,
+			},
+		},
+		"NoHandler": {
+			reason: "The sync should be retried with the backoff if there is no handler",
+			want: want{
+				result:   reconcile.Result{RequeueAfter: shortWait},
+				versions: []string{"42"},
+				synced:   resource.ReasonAgentSyncError,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var synced v1alpha1.ConditionReason
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+						l.SetName("cool-db")
+						l.SetUID("local-uid")
+						l.Object["spec"] = map[string]interface{}{"size": int64(20)}
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						synced = (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync).Reason
+						return nil
+					},
+				},
+			}
+			var versions []string
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+					r.SetName("cool-db")
+					r.SetCreationTimestamp(now)
+					r.SetResourceVersion("42")
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid", AnnotationKeyManagedSpec: `["spec.size"]`})
+					r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+				// Only the writes conditional on the resource version that
+				// was read conflict.
+				MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+					b, err := p.Data(obj)
+					if err != nil {
+						return err
+					}
+					u := &unstructured.Unstructured{}
+					if err := u.UnmarshalJSON(b); err != nil {
+						return err
+					}
+					versions = append(versions, u.GetResourceVersion())
+					if u.GetResourceVersion() != "" {
+						return errConflict
+					}
+					return nil
+				},
+			}
+			handled := false
+			opts := []ReconcilerOption{
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+			}
+			if tc.handler != nil {
+				opts = append(opts, WithConflictHandler(ConflictHandlerFn(func(ctx context.Context, c Conflict) ConflictResolution {
+					handled = true
+					if diff := cmp.Diff(errConflict, c.Err, test.EquateErrors()); diff != "" {
+						t.Errorf("\nReason: %s\nConflict.Err: -want, +got:\n%s", tc.reason, diff)
+					}
+					if diff := cmp.Diff("42", c.Observed.GetResourceVersion()); diff != "" {
+						t.Errorf("\nReason: %s\nConflict.Observed: -want resource version, +got resource version:\n%s", tc.reason, diff)
+					}
+					return tc.handler.HandleConflict(ctx, c)
+				})))
+			}
+			r := NewReconciler(m, remote, scopedGVK, opts...)
+			result, err := r.Reconcile(reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.versions, versions); diff != "" {
+				t.Errorf("\nReason: %s\nwritten resource versions: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.handled, handled); diff != "" {
+				t.Errorf("\nReason: %s\nhandler called: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.synced, synced); diff != "" {
+				t.Errorf("\nReason: %s\nAgentSynced reason: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errMarshalDesiredSpec = "cannot marshal desired spec of remote claim"
+
+	msgDesiredSpecOmittedFmt = "omitted: desired spec has %d bytes, more than the limit of %d"
+)
+
+// DefaultDesiredSpecMaxBytes is the default size limit of the desired spec
+// annotation.
+const DefaultDesiredSpecMaxBytes = 16 * 1024
+
+// DesiredSpecAnnotation returns the value of the AnnotationKeyDesiredSpec
+// annotation for the supplied remote instance, which is the JSON of its spec.
+// A spec whose JSON is larger than the supplied number of bytes is not
+// included, so that the local instance never grows past the limits of the API
+// server; the value only tells how large it is then.
+func DesiredSpecAnnotation(remote *claim.Unstructured, maxBytes int) (string, error) {
+	spec, ok := remote.GetUnstructured().Object["spec"]
+	if !ok {
+		return "", nil
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", errors.Wrap(err, errMarshalDesiredSpec)
+	}
+	if len(b) > maxBytes {
+		return fmt.Sprintf(msgDesiredSpecOmittedFmt, len(b), maxBytes), nil
+	}
+	return string(b), nil
+}
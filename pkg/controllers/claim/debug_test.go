@@ -0,0 +1,124 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestDesiredSpecAnnotation(t *testing.T) {
+	withSpec := func(spec map[string]interface{}) *claim.Unstructured {
+		c := claim.New()
+		if spec != nil {
+			c.Object["spec"] = spec
+		}
+		return c
+	}
+	cases := map[string]struct {
+		reason   string
+		remote   *claim.Unstructured
+		maxBytes int
+		want     string
+	}{
+		"Fits": {
+			reason:   "The JSON of the spec should be returned if it fits in the limit",
+			remote:   withSpec(map[string]interface{}{"size": int64(10), "engine": "postgres"}),
+			maxBytes: 64,
+			want:     `{"engine":"postgres","size":10}`,
+		},
+		"TooLarge": {
+			reason:   "The spec should be omitted if its JSON doesn't fit in the limit",
+			remote:   withSpec(map[string]interface{}{"size": int64(10), "engine": "postgres"}),
+			maxBytes: 16,
+			want:     "omitted: desired spec has 31 bytes, more than the limit of 16",
+		},
+		"NoSpec": {
+			reason:   "Nothing should be returned if the remote instance has no spec",
+			remote:   withSpec(nil),
+			maxBytes: 64,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := DesiredSpecAnnotation(tc.remote, tc.maxBytes)
+			if err != nil {
+				t.Fatalf("\nReason: %s\nDesiredSpecAnnotation(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nDesiredSpecAnnotation(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileDesiredSpecAnnotation(t *testing.T) {
+	var annotated map[string]string
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				annotated = obj.(*unstructured.Unstructured).GetAnnotations()
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var pushed map[string]string
+	remote := &test.MockClient{
+		MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			pushed = obj.(*unstructured.Unstructured).GetAnnotations()
+			return nil
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithManagementPolicies([]string{"Observe"}),
+		WithDesiredSpecAnnotation(0),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	want := `{"managementPolicies":["Observe"],"size":10}`
+	if diff := cmp.Diff(want, annotated[AnnotationKeyDesiredSpec]); diff != "" {
+		t.Errorf("\nReason: %s\nlocal annotation: -want, +got:\n%s", "The spec computed for the remote instance should be shown on the local instance", diff)
+	}
+	if _, ok := pushed[AnnotationKeyDesiredSpec]; ok {
+		t.Errorf("\nReason: %s\nremote annotations: unexpected %s", "The desired spec annotation should not be pushed", AnnotationKeyDesiredSpec)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errGetDependency   = "cannot get dependency"
+	errDependencyCycle = "dependencies form a cycle"
+)
+
+// NewDependencyChecker returns a new *DependencyChecker that reads the local
+// instances created by the supplied function.
+func NewDependencyChecker(c client.Reader, ni func() *claim.Unstructured) *DependencyChecker {
+	return &DependencyChecker{client: c, newInstance: ni}
+}
+
+// A DependencyChecker checks whether the local instances a local instance
+// depends on are Ready. See AnnotationKeyDependsOn.
+type DependencyChecker struct {
+	client      client.Reader
+	newInstance func() *claim.Unstructured
+}
+
+// Unready returns the names of the dependencies of the supplied local instance
+// that are not Ready, including the ones that don't exist yet. It returns an
+// error if the instance depends on itself, directly or transitively, since it
+// would wait for them forever.
+func (d *DependencyChecker) Unready(ctx context.Context, local *claim.Unstructured) ([]string, error) {
+	cycle, err := d.cycle(ctx, local, []string{local.GetName()}, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if cycle != nil {
+		return nil, errors.Errorf("%s: %s", errDependencyCycle, strings.Join(cycle, " -> "))
+	}
+
+	var unready []string
+	for _, name := range DependsOn(local) {
+		dep, err := d.get(ctx, local.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		if dep == nil || dep.GetCondition(v1alpha1.TypeReady).Status != corev1.ConditionTrue {
+			unready = append(unready, name)
+		}
+	}
+	return unready, nil
+}
+
+// cycle returns the path of the first cycle it finds while walking the
+// dependencies of the supplied instance. The done set records the instances
+// whose dependencies were walked without finding any.
+func (d *DependencyChecker) cycle(ctx context.Context, o *claim.Unstructured, path []string, done map[string]bool) ([]string, error) {
+	for _, name := range DependsOn(o) {
+		p := append(append([]string{}, path...), name)
+		for _, n := range path {
+			if n == name {
+				return p, nil
+			}
+		}
+		if done[name] {
+			continue
+		}
+		dep, err := d.get(ctx, o.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		if dep == nil {
+			continue
+		}
+		c, err := d.cycle(ctx, dep, p, done)
+		if c != nil || err != nil {
+			return c, err
+		}
+		done[name] = true
+	}
+	return nil, nil
+}
+
+// get returns the local instance with the supplied name, or nil if it doesn't
+// exist.
+func (d *DependencyChecker) get(ctx context.Context, namespace, name string) (*claim.Unstructured, error) {
+	dep := d.newInstance()
+	err := d.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, dep)
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return dep, errors.Wrapf(err, "%s %s", errGetDependency, name)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func dependent(name, dependsOn string, ready bool) *claim.Unstructured {
+	c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+	c.SetName(name)
+	c.SetNamespace("cool-ns")
+	if dependsOn != "" {
+		c.SetAnnotations(map[string]string{AnnotationKeyDependsOn: dependsOn})
+	}
+	if ready {
+		c.SetConditions(v1alpha1.Available())
+	}
+	return c
+}
+
+func TestDependencyCheckerUnready(t *testing.T) {
+	type args struct {
+		local    *claim.Unstructured
+		existing []*claim.Unstructured
+		err      error
+	}
+	type want struct {
+		unready []string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"NoDependencies": {
+			reason: "A claim without dependencies should not wait for anything",
+			args: args{
+				local: dependent("database", "", false),
+			},
+		},
+		"Ready": {
+			reason: "A claim whose dependencies are Ready should not wait for anything",
+			args: args{
+				local:    dependent("database", "network, subnet", false),
+				existing: []*claim.Unstructured{dependent("network", "", true), dependent("subnet", "network", true)},
+			},
+		},
+		"Unready": {
+			reason: "The dependencies that are not Ready or don't exist should be returned",
+			args: args{
+				local:    dependent("database", "network,subnet,cache", false),
+				existing: []*claim.Unstructured{dependent("network", "", false), dependent("cache", "", true)},
+			},
+			want: want{
+				unready: []string{"network", "subnet"},
+			},
+		},
+		"SelfCycle": {
+			reason: "An error should be returned if a claim depends on itself",
+			args: args{
+				local: dependent("database", "database", false),
+			},
+			want: want{
+				err: errors.New(errDependencyCycle + ": database -> database"),
+			},
+		},
+		"TransitiveCycle": {
+			reason: "An error should be returned if the dependencies of a claim depend on it",
+			args: args{
+				local:    dependent("database", "network", false),
+				existing: []*claim.Unstructured{dependent("network", "subnet", true), dependent("subnet", "database", true)},
+			},
+			want: want{
+				err: errors.New(errDependencyCycle + ": database -> network -> subnet -> database"),
+			},
+		},
+		"Diamond": {
+			reason: "Dependencies that are shared by several dependencies should not be mistaken for a cycle",
+			args: args{
+				local:    dependent("database", "network,subnet", false),
+				existing: []*claim.Unstructured{dependent("network", "vpc", true), dependent("subnet", "vpc", true), dependent("vpc", "", true)},
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if a dependency cannot be fetched",
+			args: args{
+				local: dependent("database", "network", false),
+				err:   errBoom,
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetDependency+" network"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				if tc.args.err != nil {
+					return tc.args.err
+				}
+				for _, e := range tc.args.existing {
+					if e.GetName() == key.Name && e.GetNamespace() == key.Namespace {
+						e.DeepCopyInto(&obj.(*claim.Unstructured).Unstructured)
+						return nil
+					}
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}}
+			d := NewDependencyChecker(c, func() *claim.Unstructured { return claim.New(claim.WithGroupVersionKind(scopedGVK)) })
+			got, err := d.Unready(context.Background(), tc.args.local)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nd.Unready(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.unready, got); diff != "" {
+				t.Errorf("\nReason: %s\nd.Unready(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
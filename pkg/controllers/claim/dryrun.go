@@ -0,0 +1,51 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// A dryRunClient sends all of its writes with server-side dry-run, so that
+// the API server runs its admission chain, including the webhooks, on them
+// without persisting anything. Its reads are sent as they are.
+type dryRunClient struct {
+	client.Client
+}
+
+// Create the supplied object with server-side dry-run.
+func (c *dryRunClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	return c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// Update the supplied object with server-side dry-run.
+func (c *dryRunClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	return c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// Patch the supplied object with server-side dry-run.
+func (c *dryRunClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
+
+// Delete the supplied object with server-side dry-run.
+func (c *dryRunClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...)
+}
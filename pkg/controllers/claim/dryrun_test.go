@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func TestReconcileDryRun(t *testing.T) {
+	denied := kerrors.NewForbidden(schema.GroupResource{Group: "example.org", Resource: "databases"}, "cool-db", errors.New(`admission webhook "size.example.org" denied the request: size must be at most 5`))
+
+	type want struct {
+		writes    []bool
+		condition v1alpha1.Condition
+	}
+	cases := map[string]struct {
+		reason string
+		dryRun error
+		want
+	}{
+		"Accepted": {
+			reason: "The remote instance should be written for real once the dry-run is accepted",
+			want: want{
+				writes:    []bool{true, false},
+				condition: resource.AgentSyncSuccess(),
+			},
+		},
+		"Rejected": {
+			reason: "The remote instance should not be written for real if the dry-run is rejected, and the message of the webhook should be reported",
+			dryRun: denied,
+			want: want{
+				writes:    []bool{true},
+				condition: resource.AgentSyncError(errors.Wrap(errors.Wrap(denied, "cannot create object"), remotePrefix+errDryRunClaim)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got v1alpha1.Condition
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+						local.SetName("cool-db")
+						local.Object["spec"] = map[string]interface{}{"size": int64(10)}
+						local.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got = (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+						return nil
+					},
+				},
+			}
+			// writes records whether each write of the remote instance was a
+			// dry-run.
+			var writes []bool
+			remote := &test.MockClient{
+				MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				MockCreate: func(_ context.Context, _ runtime.Object, opts ...client.CreateOption) error {
+					co := &client.CreateOptions{}
+					co.ApplyOptions(opts)
+					dry := len(co.DryRun) > 0
+					writes = append(writes, dry)
+					if dry {
+						return tc.dryRun
+					}
+					return nil
+				},
+			}
+			r := NewReconciler(m, remote, scopedGVK,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+				WithDryRun(),
+			)
+			if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.writes, writes); diff != "" {
+				t.Errorf("\nReason: %s\ndry-run writes: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.condition, got, test.EquateConditions()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want condition, +got condition:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"encoding/json"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+// An EqualityFunc reports whether two values of an instance are equal. It lets
+// the kinds with semantically equal but textually different values, e.g. 100m
+// and 0.1 CPU, be compared without causing a write. The values are the ones of
+// unstructured content, i.e. maps, slices, strings, numbers and booleans.
+type EqualityFunc func(a, b interface{}) bool
+
+// DefaultEqualityFunc is the EqualityFunc that is used unless another one is
+// supplied.
+var DefaultEqualityFunc EqualityFunc = equality.Semantic.DeepEqual
+
+// IsUpToDate returns true if the observed remote instance already has the
+// metadata and spec of the desired remote instance, in which case there is no
+// need to write it. The arrays at the given unordered paths are compared as
+// sets, i.e. the order of their elements is ignored. The specs are compared
+// with the supplied EqualityFunc.
+func IsUpToDate(observed, desired *claim.Unstructured, unordered []string, eq EqualityFunc) bool {
+	if !equality.Semantic.DeepEqual(observed.GetLabels(), desired.GetLabels()) ||
+		!equality.Semantic.DeepEqual(observed.GetAnnotations(), desired.GetAnnotations()) {
+		return false
+	}
+	o := normalize(observed, unordered)
+	d := normalize(desired, unordered)
+	return eq(o["spec"], d["spec"])
+}
+
+// Diverges returns true if the spec of the observed remote instance differs
+// from the spec of the intended one that was written for the supplied local
+// instance. The fields that the field policies let the remote cluster set, i.e.
+// the remote-owned ones and the late-initialized ones that the local instance
+// doesn't have, are not compared. The merged fields diverge only if the
+// observed remote instance lacks an entry of the local one. The specs are
+// compared with the supplied EqualityFunc.
+func Diverges(local, intended, observed *claim.Unstructured, p FieldPolicies, unordered []string, eq EqualityFunc) bool {
+	lp := fieldpath.Pave(local.GetUnstructured().UnstructuredContent())
+	ip := fieldpath.Pave(normalize(intended, unordered))
+	op := fieldpath.Pave(normalize(observed, unordered))
+	for path, fp := range p {
+		lv, set := getValue(lp, path)
+		if fp == FieldPolicyMerge {
+			if ov, _ := getValue(op, path); !containsAll(ov, lv) {
+				return true
+			}
+		} else if fp != FieldPolicyRemote && (fp != FieldPolicyLateInit || set) {
+			continue
+		}
+		if deleteValue(ip, path) != nil || deleteValue(op, path) != nil {
+			return true
+		}
+	}
+	is, _ := ip.GetValue("spec")
+	os, _ := op.GetValue("spec")
+	return !eq(is, os)
+}
+
+// normalize returns a copy of the content of the supplied instance where the
+// arrays at the given paths are sorted by their JSON representation.
+func normalize(u *claim.Unstructured, unordered []string) map[string]interface{} {
+	content := runtime.DeepCopyJSON(u.GetUnstructured().UnstructuredContent())
+	p := fieldpath.Pave(content)
+	for _, path := range unordered {
+		v, err := p.GetValue(path)
+		if err != nil {
+			continue
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			continue
+		}
+		keys := make([]string, len(arr))
+		for i := range arr {
+			b, _ := json.Marshal(arr[i])
+			keys[i] = string(b)
+		}
+		sort.Sort(byKey{keys: keys, arr: arr})
+	}
+	return content
+}
+
+type byKey struct {
+	keys []string
+	arr  []interface{}
+}
+
+func (b byKey) Len() int           { return len(b.keys) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+	b.arr[i], b.arr[j] = b.arr[j], b.arr[i]
+}
+
+// containsAll returns true if the supplied array has all entries of the other
+// one. Values that are not arrays must be equal.
+func containsAll(arr, entries interface{}) bool {
+	ea, ok := entries.([]interface{})
+	if !ok {
+		return entries == nil || jsonEqual(arr, entries)
+	}
+	a, _ := arr.([]interface{})
+	for _, e := range ea {
+		if !containsValue(a, e) {
+			return false
+		}
+	}
+	return true
+}
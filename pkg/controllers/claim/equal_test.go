@@ -0,0 +1,281 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+// quantityEqual is an EqualityFunc that treats the strings that are equal
+// quantities, e.g. 100m and 0.1, as equal.
+func quantityEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k := range av {
+			if !quantityEqual(av[k], bv[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !quantityEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return false
+		}
+		aq, aerr := kresource.ParseQuantity(av)
+		bq, berr := kresource.ParseQuantity(bv)
+		if aerr != nil || berr != nil {
+			return av == bv
+		}
+		return aq.Cmp(bq) == 0
+	}
+	return DefaultEqualityFunc(a, b)
+}
+
+func TestIsUpToDate(t *testing.T) {
+	withSpec := func(spec map[string]interface{}) *claim.Unstructured {
+		c := claim.New()
+		c.Object["spec"] = spec
+		return c
+	}
+	type args struct {
+		observed  *claim.Unstructured
+		desired   *claim.Unstructured
+		unordered []string
+		eq        EqualityFunc
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want bool
+	}{
+		"Equal": {
+			reason: "Instances with the same spec should be up to date",
+			args: args{
+				observed: withSpec(map[string]interface{}{"tags": []interface{}{"a", "b"}}),
+				desired:  withSpec(map[string]interface{}{"tags": []interface{}{"a", "b"}}),
+			},
+			want: true,
+		},
+		"DifferentSpec": {
+			reason: "Instances with different spec should not be up to date",
+			args: args{
+				observed: withSpec(map[string]interface{}{"size": "small"}),
+				desired:  withSpec(map[string]interface{}{"size": "large"}),
+			},
+			want: false,
+		},
+		"DifferentLabels": {
+			reason: "Instances with different labels should not be up to date",
+			args: args{
+				observed: withSpec(map[string]interface{}{}),
+				desired: func() *claim.Unstructured {
+					c := withSpec(map[string]interface{}{})
+					c.SetLabels(map[string]string{"k": "v"})
+					return c
+				}(),
+			},
+			want: false,
+		},
+		"ReorderedOrderedArray": {
+			reason: "Reordering an array whose order is significant should be a difference",
+			args: args{
+				observed: withSpec(map[string]interface{}{"tags": []interface{}{"b", "a"}}),
+				desired:  withSpec(map[string]interface{}{"tags": []interface{}{"a", "b"}}),
+			},
+			want: false,
+		},
+		"ReorderedUnorderedArray": {
+			reason: "Reordering an array whose order is not significant should not be a difference",
+			args: args{
+				observed: withSpec(map[string]interface{}{"refs": []interface{}{
+					map[string]interface{}{"name": "b"},
+					map[string]interface{}{"name": "a"},
+				}}),
+				desired: withSpec(map[string]interface{}{"refs": []interface{}{
+					map[string]interface{}{"name": "a"},
+					map[string]interface{}{"name": "b"},
+				}}),
+				unordered: []string{"spec.refs"},
+			},
+			want: true,
+		},
+		"DifferentUnorderedArray": {
+			reason: "Arrays whose order is not significant should still be compared by their elements",
+			args: args{
+				observed:  withSpec(map[string]interface{}{"tags": []interface{}{"a", "c"}}),
+				desired:   withSpec(map[string]interface{}{"tags": []interface{}{"b", "a"}}),
+				unordered: []string{"spec.tags"},
+			},
+			want: false,
+		},
+		"TextuallyDifferentQuantity": {
+			reason: "Textually different but equal quantities should be a difference by default",
+			args: args{
+				observed: withSpec(map[string]interface{}{"cpu": "0.1"}),
+				desired:  withSpec(map[string]interface{}{"cpu": "100m"}),
+			},
+			want: false,
+		},
+		"SemanticallyEqualQuantity": {
+			reason: "Textually different values should not be a difference if the supplied EqualityFunc considers them equal",
+			args: args{
+				observed: withSpec(map[string]interface{}{"cpu": "0.1"}),
+				desired:  withSpec(map[string]interface{}{"cpu": "100m"}),
+				eq:       quantityEqual,
+			},
+			want: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			eq := tc.args.eq
+			if eq == nil {
+				eq = DefaultEqualityFunc
+			}
+			observed := tc.args.observed.GetUnstructured().DeepCopy()
+			got := IsUpToDate(tc.args.observed, tc.args.desired, tc.args.unordered, eq)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nIsUpToDate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(observed, tc.args.observed.GetUnstructured()); diff != "" {
+				t.Errorf("\nReason: %s\nIsUpToDate(...) should not modify its arguments: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDiverges(t *testing.T) {
+	withSpec := func(spec map[string]interface{}) *claim.Unstructured {
+		c := claim.New()
+		c.Object["spec"] = spec
+		return c
+	}
+	policies := FieldPolicies{"spec.ref": FieldPolicyLateInit, "spec.status": FieldPolicyRemote, "spec.envs": FieldPolicyMerge}
+	type args struct {
+		local    *claim.Unstructured
+		intended *claim.Unstructured
+		observed *claim.Unstructured
+		eq       EqualityFunc
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want bool
+	}{
+		"Matching": {
+			reason: "The observed spec should not diverge if it's the intended one",
+			args: args{
+				local:    withSpec(map[string]interface{}{"size": "10"}),
+				intended: withSpec(map[string]interface{}{"size": "10"}),
+				observed: withSpec(map[string]interface{}{"size": "10"}),
+			},
+		},
+		"LocalFieldChanged": {
+			reason: "The observed spec should diverge if a locally owned field was changed",
+			args: args{
+				local:    withSpec(map[string]interface{}{"size": "10"}),
+				intended: withSpec(map[string]interface{}{"size": "10"}),
+				observed: withSpec(map[string]interface{}{"size": "20"}),
+			},
+			want: true,
+		},
+		"RemoteFieldChanged": {
+			reason: "The observed spec should not diverge if a remotely owned field was changed",
+			args: args{
+				local:    withSpec(map[string]interface{}{"size": "10"}),
+				intended: withSpec(map[string]interface{}{"size": "10"}),
+				observed: withSpec(map[string]interface{}{"size": "10", "status": "cool"}),
+			},
+		},
+		"LateInitFieldDefaulted": {
+			reason: "The observed spec should not diverge if a late-initialized field that's not set locally was set remotely",
+			args: args{
+				local:    withSpec(map[string]interface{}{"size": "10"}),
+				intended: withSpec(map[string]interface{}{"size": "10"}),
+				observed: withSpec(map[string]interface{}{"size": "10", "ref": "remote"}),
+			},
+		},
+		"LateInitFieldOverwritten": {
+			reason: "The observed spec should diverge if a late-initialized field that's set locally was changed",
+			args: args{
+				local:    withSpec(map[string]interface{}{"size": "10", "ref": "local"}),
+				intended: withSpec(map[string]interface{}{"size": "10", "ref": "local"}),
+				observed: withSpec(map[string]interface{}{"size": "10", "ref": "remote"}),
+			},
+			want: true,
+		},
+		"MergedFieldAugmented": {
+			reason: "The observed spec should not diverge if entries were added remotely to a merged field",
+			args: args{
+				local:    withSpec(map[string]interface{}{"envs": []interface{}{"a"}}),
+				intended: withSpec(map[string]interface{}{"envs": []interface{}{"a"}}),
+				observed: withSpec(map[string]interface{}{"envs": []interface{}{"a", "b"}}),
+			},
+		},
+		"MergedFieldEntryRemoved": {
+			reason: "The observed spec should diverge if a local entry of a merged field was removed remotely",
+			args: args{
+				local:    withSpec(map[string]interface{}{"envs": []interface{}{"a"}}),
+				intended: withSpec(map[string]interface{}{"envs": []interface{}{"a"}}),
+				observed: withSpec(map[string]interface{}{"envs": []interface{}{"b"}}),
+			},
+			want: true,
+		},
+		"SemanticallyEqualQuantity": {
+			reason: "The observed spec should not diverge if the supplied EqualityFunc considers it equal to the intended one",
+			args: args{
+				local:    withSpec(map[string]interface{}{"cpu": "100m"}),
+				intended: withSpec(map[string]interface{}{"cpu": "100m"}),
+				observed: withSpec(map[string]interface{}{"cpu": "0.1"}),
+				eq:       quantityEqual,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			eq := tc.args.eq
+			if eq == nil {
+				eq = DefaultEqualityFunc
+			}
+			got := Diverges(tc.args.local, tc.args.intended, tc.args.observed, policies, nil, eq)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nDiverges(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
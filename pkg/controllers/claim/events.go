@@ -0,0 +1,112 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errListEvents = "cannot list events"
+
+	msgRemoteEventFmt = "remote: %s: %s"
+)
+
+// A RemoteEventReaderOption configures a RemoteEventReader.
+type RemoteEventReaderOption func(*RemoteEventReader)
+
+// WithEventMaxAge makes the RemoteEventReader ignore the events that last
+// occurred longer than the given time ago, so that a problem that has long
+// been resolved isn't reported. Events of any age are considered if it's zero.
+func WithEventMaxAge(d time.Duration) RemoteEventReaderOption {
+	return func(er *RemoteEventReader) {
+		er.maxAge = d
+	}
+}
+
+// NewRemoteEventReader returns a new *RemoteEventReader that lists the events
+// of the remote cluster with the supplied client.
+func NewRemoteEventReader(c client.Reader, opts ...RemoteEventReaderOption) *RemoteEventReader {
+	er := &RemoteEventReader{client: c, now: time.Now}
+	for _, f := range opts {
+		f(er)
+	}
+	return er
+}
+
+// A RemoteEventReader reads the latest warning event of a remote instance, so
+// that it can be surfaced in the local instance without mirroring every event.
+type RemoteEventReader struct {
+	client client.Reader
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// LatestWarning returns the most recent warning event of the supplied remote
+// instance, or nil if it has none.
+func (er *RemoteEventReader) LatestWarning(ctx context.Context, remote *claim.Unstructured) (*corev1.Event, error) {
+	l := &corev1.EventList{}
+	if err := er.client.List(ctx, l, client.InNamespace(remote.GetNamespace()), client.MatchingFields{
+		"involvedObject.uid": string(remote.GetUID()),
+		"type":               corev1.EventTypeWarning,
+	}); err != nil {
+		return nil, errors.Wrap(err, errListEvents)
+	}
+	var latest *corev1.Event
+	for i := range l.Items {
+		e := &l.Items[i]
+		// The selectors are checked again since not every client, e.g. the
+		// one of a cache, supports them.
+		if e.InvolvedObject.UID != remote.GetUID() || e.Type != corev1.EventTypeWarning {
+			continue
+		}
+		if er.maxAge > 0 && er.now().Sub(eventTime(e)) > er.maxAge {
+			continue
+		}
+		if latest == nil || eventTime(e).After(eventTime(latest)) {
+			latest = e
+		}
+	}
+	return latest, nil
+}
+
+// RemoteEventMessage returns the message that surfaces the supplied remote
+// event in a condition of the local instance.
+func RemoteEventMessage(e *corev1.Event) string {
+	return fmt.Sprintf(msgRemoteEventFmt, e.Reason, e.Message)
+}
+
+// eventTime returns when the supplied event last occurred. The events that are
+// recorded with the events.k8s.io API only have an event time.
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.FirstTimestamp.Time
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func newRemoteEvent(uid types.UID, eventType, reason string, last time.Time) corev1.Event {
+	return corev1.Event{
+		InvolvedObject: corev1.ObjectReference{UID: uid},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        reason + " happened",
+		LastTimestamp:  metav1.NewTime(last),
+	}
+}
+
+func newEventList(events ...corev1.Event) test.MockListFn {
+	return func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+		obj.(*corev1.EventList).Items = events
+		return nil
+	}
+}
+
+func TestRemoteEventReaderLatestWarning(t *testing.T) {
+	now := time.Now()
+	remote := claim.New()
+	remote.SetUID("remote-uid")
+	type want struct {
+		event *corev1.Event
+		err   error
+	}
+	latest := newRemoteEvent("remote-uid", corev1.EventTypeWarning, "CannotSelectComposition", now.Add(-time.Minute))
+	cases := map[string]struct {
+		reason string
+		list   test.MockListFn
+		opts   []RemoteEventReaderOption
+		want
+	}{
+		"Latest": {
+			reason: "The most recent warning event of the remote instance should be returned",
+			list: newEventList(
+				newRemoteEvent("remote-uid", corev1.EventTypeWarning, "CannotBind", now.Add(-time.Hour)),
+				latest,
+				newRemoteEvent("remote-uid", corev1.EventTypeNormal, "Bound", now),
+				newRemoteEvent("other-uid", corev1.EventTypeWarning, "CannotProvision", now),
+			),
+			want: want{event: &latest},
+		},
+		"TooOld": {
+			reason: "The warning events older than the maximum age should be ignored",
+			list:   newEventList(newRemoteEvent("remote-uid", corev1.EventTypeWarning, "CannotBind", now.Add(-2*time.Hour))),
+			opts:   []RemoteEventReaderOption{WithEventMaxAge(time.Hour)},
+		},
+		"NoWarnings": {
+			reason: "Nothing should be returned if the remote instance has no warning events",
+			list:   newEventList(newRemoteEvent("remote-uid", corev1.EventTypeNormal, "Bound", now)),
+		},
+		"ListError": {
+			reason: "An error should be returned if the events cannot be listed",
+			list:   test.NewMockListFn(errBoom),
+			want:   want{err: errors.Wrap(errBoom, errListEvents)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			er := NewRemoteEventReader(&test.MockClient{MockList: tc.list}, tc.opts...)
+			er.now = func() time.Time { return now }
+			got, err := er.LatestWarning(context.Background(), remote)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\ner.LatestWarning(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.event, got); diff != "" {
+				t.Errorf("\nReason: %s\ner.LatestWarning(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRemoteEventMessages(t *testing.T) {
+	var synced *claim.Unstructured
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				synced = &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+				return nil
+			},
+		},
+	}
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetUID("remote-uid")
+			r.SetCreationTimestamp(now)
+			r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid", AnnotationKeyManagedSpec: `["spec.size"]`})
+			r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+			r.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		},
+		MockPatch: test.NewMockPatchFn(nil),
+	}
+	events := &test.MockClient{MockList: newEventList(
+		newRemoteEvent("remote-uid", corev1.EventTypeWarning, "CannotSelectComposition", time.Now()),
+	)}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithRemoteEventMessages(NewRemoteEventReader(events)),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	want := resource.AgentSyncSuccess().WithMessage("remote: CannotSelectComposition: CannotSelectComposition happened")
+	if diff := cmp.Diff(want, synced.GetCondition(resource.TypeAgentSync), test.EquateConditions()); diff != "" {
+		t.Errorf("\nReason: %s\nAgentSynced: -want, +got:\n%s", "The latest warning event of the remote instance should be surfaced", diff)
+	}
+}
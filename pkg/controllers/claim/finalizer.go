@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+const errUpdateObject = "cannot update object"
+
+// NewMigratingFinalizer returns a new *MigratingFinalizer that manages the
+// given finalizer in place of the given previous ones.
+func NewMigratingFinalizer(kube client.Client, name string, previous ...string) *MigratingFinalizer {
+	return &MigratingFinalizer{kube: kube, name: name, previous: previous}
+}
+
+// A MigratingFinalizer manages a finalizer like an APIFinalizer, except that it
+// also takes over the finalizers that were used before it, e.g. by an agent
+// that was configured with another finalizer name. They're replaced with the
+// finalizer once it's added, and removed along with it, so that an object that
+// was finalized with a previous finalizer is not blocked from being deleted.
+type MigratingFinalizer struct {
+	kube     client.Client
+	name     string
+	previous []string
+}
+
+// AddFinalizer adds the finalizer to the supplied object and removes the
+// previous ones from it.
+func (f *MigratingFinalizer) AddFinalizer(ctx context.Context, obj runtimeresource.Object) error {
+	if meta.FinalizerExists(obj, f.name) && !f.hasPrevious(obj) {
+		return nil
+	}
+	meta.AddFinalizer(obj, f.name)
+	for _, p := range f.previous {
+		meta.RemoveFinalizer(obj, p)
+	}
+	return errors.Wrap(f.kube.Update(ctx, obj), errUpdateObject)
+}
+
+// RemoveFinalizer removes the finalizer and the previous ones from the supplied
+// object.
+func (f *MigratingFinalizer) RemoveFinalizer(ctx context.Context, obj runtimeresource.Object) error {
+	if !meta.FinalizerExists(obj, f.name) && !f.hasPrevious(obj) {
+		return nil
+	}
+	meta.RemoveFinalizer(obj, f.name)
+	for _, p := range f.previous {
+		meta.RemoveFinalizer(obj, p)
+	}
+	return errors.Wrap(runtimeresource.IgnoreNotFound(f.kube.Update(ctx, obj)), errUpdateObject)
+}
+
+func (f *MigratingFinalizer) hasPrevious(obj runtimeresource.Object) bool {
+	for _, p := range f.previous {
+		if meta.FinalizerExists(obj, p) {
+			return true
+		}
+	}
+	return false
+}
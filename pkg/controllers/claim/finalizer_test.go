@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestMigratingFinalizer(t *testing.T) {
+	type args struct {
+		finalizers []string
+		remove     bool
+		err        error
+	}
+	type want struct {
+		finalizers []string
+		updated    bool
+		err        error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Added": {
+			reason: "The finalizer should be added if the object has none",
+			args:   args{finalizers: []string{"other.io/finalizer"}},
+			want:   want{finalizers: []string{"other.io/finalizer", "cool.io/sync"}, updated: true},
+		},
+		"AlreadyAdded": {
+			reason: "The object should not be updated if it only has the finalizer already",
+			args:   args{finalizers: []string{"cool.io/sync"}},
+			want:   want{finalizers: []string{"cool.io/sync"}},
+		},
+		"Migrated": {
+			reason: "A previous finalizer should be replaced with the finalizer",
+			args:   args{finalizers: []string{DefaultFinalizer}},
+			want:   want{finalizers: []string{"cool.io/sync"}, updated: true},
+		},
+		"AddFailed": {
+			reason: "An error should be returned if the object cannot be updated",
+			args:   args{err: errBoom},
+			want:   want{finalizers: []string{"cool.io/sync"}, updated: true, err: errors.Wrap(errBoom, errUpdateObject)},
+		},
+		"Removed": {
+			reason: "The finalizer and the previous ones should be removed",
+			args:   args{finalizers: []string{DefaultFinalizer, "cool.io/sync", "other.io/finalizer"}, remove: true},
+			want:   want{finalizers: []string{"other.io/finalizer"}, updated: true},
+		},
+		"PreviousRemoved": {
+			reason: "A previous finalizer should be removed even if the object doesn't have the finalizer",
+			args:   args{finalizers: []string{DefaultFinalizer}, remove: true},
+			want:   want{finalizers: []string{}, updated: true},
+		},
+		"NothingToRemove": {
+			reason: "The object should not be updated if it has neither the finalizer nor a previous one",
+			args:   args{finalizers: []string{"other.io/finalizer"}, remove: true},
+			want:   want{finalizers: []string{"other.io/finalizer"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			updated := false
+			kube := &test.MockClient{MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+				updated = true
+				return tc.args.err
+			}}
+			obj := claim.New()
+			obj.SetFinalizers(tc.args.finalizers)
+			f := NewMigratingFinalizer(kube, "cool.io/sync", DefaultFinalizer)
+			var err error
+			if tc.args.remove {
+				err = f.RemoveFinalizer(context.Background(), obj)
+			} else {
+				err = f.AddFinalizer(context.Background(), obj)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\n-want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.finalizers, obj.GetFinalizers()); diff != "" {
+				t.Errorf("\nReason: %s\n-want finalizers, +got finalizers:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.updated, updated); diff != "" {
+				t.Errorf("\nReason: %s\n-want updated, +got updated:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
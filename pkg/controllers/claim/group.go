@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	kunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errListGroup            = "cannot list claims of the group"
+	errGroupMemberFmt       = "cannot prepare claim %q of the group"
+	errApplyGroupMemberFmt  = "cannot apply claim %q of the group, rolled back the claims created in this attempt"
+	errRollbackGroupFmt     = "cannot apply claim %q of the group, and cannot roll back the claims created in this attempt: %v"
+	errDeleteGroupMemberFmt = "cannot delete claim %q of the group"
+)
+
+// A GroupMemberFunc returns the observed and desired remote instances of the
+// supplied local member of a group, the way they're read and built when the
+// member is reconciled on its own. It returns a nil desired instance if the
+// member should not be applied, e.g. because it's filtered out.
+type GroupMemberFunc func(ctx context.Context, member *claim.Unstructured) (observed, desired *claim.Unstructured, err error)
+
+// A GroupApplicatorOption configures a GroupApplicator.
+type GroupApplicatorOption func(*GroupApplicator)
+
+// WithBeforeMemberApply makes the GroupApplicator call the supplied function
+// with the local member and its desired remote instance right before the
+// remote instance is written. The member is not written if it returns an
+// error.
+func WithBeforeMemberApply(fn func(ctx context.Context, local, desired *claim.Unstructured) error) GroupApplicatorOption {
+	return func(g *GroupApplicator) {
+		g.before = fn
+	}
+}
+
+// NewGroupApplicator returns a new *GroupApplicator.
+func NewGroupApplicator(local client.Reader, remote runtimeresource.ClientApplicator, member GroupMemberFunc, unordered []string, eq EqualityFunc, o ...GroupApplicatorOption) *GroupApplicator {
+	g := &GroupApplicator{
+		local:     local,
+		remote:    remote,
+		member:    member,
+		unordered: unordered,
+		equal:     eq,
+		before:    func(_ context.Context, _, _ *claim.Unstructured) error { return nil },
+	}
+	for _, fn := range o {
+		fn(g)
+	}
+	return g
+}
+
+// GroupApplicator applies the claims that share the same LabelKeyGroup label
+// to the remote cluster together. If any of them cannot be applied, the remote
+// instances that did not exist before that attempt are deleted on a best-effort
+// basis. Only the claims of the same kind in the same namespace can be grouped.
+type GroupApplicator struct {
+	local     client.Reader
+	remote    runtimeresource.ClientApplicator
+	member    GroupMemberFunc
+	unordered []string
+	equal     EqualityFunc
+	before    func(ctx context.Context, local, desired *claim.Unstructured) error
+}
+
+type groupMember struct {
+	local    *claim.Unstructured
+	observed *claim.Unstructured
+	desired  *claim.Unstructured
+}
+
+// Apply applies all members of the group of the supplied local instance, whose
+// observed and desired remote instances are supplied, in the order of their
+// names. The members that are already up to date are not written.
+func (g *GroupApplicator) Apply(ctx context.Context, local, observed, desired *claim.Unstructured) error {
+	l := &kunstructured.UnstructuredList{}
+	gvk := local.GroupVersionKind()
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := g.local.List(ctx, l, client.InNamespace(local.GetNamespace()), client.MatchingLabels{LabelKeyGroup: local.GetLabels()[LabelKeyGroup]}); err != nil {
+		return errors.Wrap(err, localPrefix+errListGroup)
+	}
+	sort.Slice(l.Items, func(i, j int) bool { return l.Items[i].GetName() < l.Items[j].GetName() })
+
+	members := make([]groupMember, 0, len(l.Items))
+	for i := range l.Items {
+		m := &claim.Unstructured{Unstructured: l.Items[i]}
+		if m.GetName() == local.GetName() {
+			members = append(members, groupMember{local: local, observed: observed, desired: desired})
+			continue
+		}
+		if meta.WasDeleted(m) {
+			continue
+		}
+		o, d, err := g.member(ctx, m)
+		if err != nil {
+			return errors.Wrapf(err, errGroupMemberFmt, m.GetName())
+		}
+		if d == nil {
+			continue
+		}
+		members = append(members, groupMember{local: m, observed: o, desired: d})
+	}
+
+	var created []*claim.Unstructured
+	for _, m := range members {
+		if meta.WasCreated(m.observed) && IsUpToDate(m.observed, m.desired, g.unordered, g.equal) {
+			continue
+		}
+		err := g.before(ctx, m.local, m.desired)
+		if err == nil {
+			err = g.remote.Apply(ctx, m.desired)
+		}
+		if err != nil {
+			if rerr := g.rollback(ctx, created); rerr != nil {
+				return errors.Wrapf(err, errRollbackGroupFmt, m.desired.GetName(), rerr)
+			}
+			return errors.Wrapf(err, errApplyGroupMemberFmt, m.desired.GetName())
+		}
+		if !meta.WasCreated(m.observed) {
+			created = append(created, m.desired)
+		}
+	}
+	return nil
+}
+
+// rollback deletes the supplied remote instances, which did not exist before
+// they were created in the failed attempt. The instances that cannot be deleted
+// are cleaned up when the group is reconciled again or deleted.
+func (g *GroupApplicator) rollback(ctx context.Context, created []*claim.Unstructured) error {
+	var errs []error
+	for _, c := range created {
+		if err := g.remote.Delete(ctx, c); runtimeresource.IgnoreNotFound(err) != nil {
+			errs = append(errs, errors.Wrapf(err, errDeleteGroupMemberFmt, c.GetName()))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestGroupApplicator(t *testing.T) {
+	newMember := func(name string) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(gvk))
+		c.SetName(name)
+		c.SetNamespace("ns")
+		c.SetUID(types.UID(name + "-uid"))
+		c.SetLabels(map[string]string{LabelKeyGroup: "pair"})
+		c.Object["spec"] = map[string]interface{}{}
+		return c
+	}
+	newInstance := func() *claim.Unstructured { return claim.New(claim.WithGroupVersionKind(gvk)) }
+	build := func(local, observed *claim.Unstructured) *claim.Unstructured {
+		d, err := NewRemoteBuilder(NewDefaultConfigurator(DefaultFieldPolicies())).Build(context.Background(), local, observed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	// existing is a remote instance of the local member that exists already
+	// but is out of date.
+	existing := func() *claim.Unstructured {
+		o := build(newMember("a"), newInstance())
+		o.SetCreationTimestamp(metav1.Now())
+		o.Object["spec"] = map[string]interface{}{"stale": true}
+		return o
+	}
+	list := func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+		obj.(*unstructured.UnstructuredList).Items = []unstructured.Unstructured{newMember("b").Unstructured, newMember("a").Unstructured}
+		return nil
+	}
+	newRemote := func(m *claim.Unstructured) (*claim.Unstructured, *claim.Unstructured, error) {
+		return newInstance(), build(m, newInstance()), nil
+	}
+	createFails := func(name string) test.MockCreateFn {
+		return func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			if obj.(metav1.Object).GetName() == name {
+				return errBoom
+			}
+			return nil
+		}
+	}
+
+	type args struct {
+		local    client.Reader
+		remote   client.Client
+		observed *claim.Unstructured
+		member   func(m *claim.Unstructured) (*claim.Unstructured, *claim.Unstructured, error)
+		before   func(ctx context.Context, local, desired *claim.Unstructured) error
+	}
+	type want struct {
+		err     error
+		created []string
+		deleted []string
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"SecondFails": {
+			reason: "The members created in the attempt should be deleted if the second member fails",
+			args: args{
+				local: &test.MockClient{MockList: list},
+				remote: &test.MockClient{
+					MockCreate: createFails("b"),
+					MockDelete: test.NewMockDeleteFn(nil),
+				},
+				member: newRemote,
+			},
+			want: want{
+				err:     errors.Wrapf(errors.Wrap(errBoom, "cannot create object"), errApplyGroupMemberFmt, "b"),
+				created: []string{"a", "b"},
+				deleted: []string{"a"},
+			},
+		},
+		"ExistingKept": {
+			reason: "The members whose remote instances existed before the attempt should not be deleted",
+			args: args{
+				local: &test.MockClient{MockList: list},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+						if key.Name == "a" {
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, "")
+					},
+					MockPatch:  test.NewMockPatchFn(nil),
+					MockCreate: createFails("b"),
+				},
+				observed: existing(),
+				member:   newRemote,
+			},
+			want: want{
+				err:     errors.Wrapf(errors.Wrap(errBoom, "cannot create object"), errApplyGroupMemberFmt, "b"),
+				created: []string{"b"},
+			},
+		},
+		"RollbackFailed": {
+			reason: "The errors of the rollback should be returned together with the error of the member that failed",
+			args: args{
+				local: &test.MockClient{MockList: list},
+				remote: &test.MockClient{
+					MockCreate: createFails("b"),
+					MockDelete: test.NewMockDeleteFn(errBoom),
+				},
+				member: newRemote,
+			},
+			want: want{
+				err:     errors.Wrapf(errors.Wrap(errBoom, "cannot create object"), errRollbackGroupFmt, "b", errors.Wrapf(errBoom, errDeleteGroupMemberFmt, "a")),
+				created: []string{"a", "b"},
+				deleted: []string{"a"},
+			},
+		},
+		"BeforeApplyFailed": {
+			reason: "A member should not be written, and the members created in the attempt should be deleted, if what runs before it is written fails",
+			args: args{
+				local: &test.MockClient{MockList: list},
+				remote: &test.MockClient{
+					MockCreate: test.NewMockCreateFn(nil),
+					MockDelete: test.NewMockDeleteFn(nil),
+				},
+				member: newRemote,
+				before: func(_ context.Context, local, _ *claim.Unstructured) error {
+					if local.GetName() == "b" {
+						return errBoom
+					}
+					return nil
+				},
+			},
+			want: want{
+				err:     errors.Wrapf(errBoom, errApplyGroupMemberFmt, "b"),
+				created: []string{"a"},
+				deleted: []string{"a"},
+			},
+		},
+		"AllApplied": {
+			reason: "All members should be applied in the order of their names",
+			args: args{
+				local:  &test.MockClient{MockList: list},
+				remote: &test.MockClient{MockCreate: test.NewMockCreateFn(nil)},
+				member: newRemote,
+			},
+			want: want{
+				created: []string{"a", "b"},
+			},
+		},
+		"MemberSkipped": {
+			reason: "The members that should not be applied should be left out of the group",
+			args: args{
+				local:  &test.MockClient{MockList: list},
+				remote: &test.MockClient{MockCreate: test.NewMockCreateFn(nil)},
+				member: func(_ *claim.Unstructured) (*claim.Unstructured, *claim.Unstructured, error) { return nil, nil, nil },
+			},
+			want: want{
+				created: []string{"a"},
+			},
+		},
+		"MemberFailed": {
+			reason: "Nothing should be applied if a member cannot be prepared",
+			args: args{
+				local:  &test.MockClient{MockList: list},
+				remote: &test.MockClient{},
+				member: func(_ *claim.Unstructured) (*claim.Unstructured, *claim.Unstructured, error) {
+					return nil, nil, errors.New(errNotManaged)
+				},
+			},
+			want: want{
+				err: errors.Wrapf(errors.New(errNotManaged), errGroupMemberFmt, "b"),
+			},
+		},
+		"ListFailed": {
+			reason: "An error should be returned if the members of the group cannot be listed",
+			args: args{
+				local:  &test.MockClient{MockList: test.NewMockListFn(errBoom)},
+				remote: &test.MockClient{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, localPrefix+errListGroup),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created, deleted []string
+			remote := tc.args.remote.(*test.MockClient)
+			if remote.MockGet == nil {
+				remote.MockGet = test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))
+			}
+			if create := remote.MockCreate; create != nil {
+				remote.MockCreate = func(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+					created = append(created, obj.(metav1.Object).GetName())
+					return create(ctx, obj, opts...)
+				}
+			}
+			if del := remote.MockDelete; del != nil {
+				remote.MockDelete = func(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+					deleted = append(deleted, obj.(metav1.Object).GetName())
+					return del(ctx, obj, opts...)
+				}
+			}
+			rc := runtimeresource.ClientApplicator{Client: remote, Applicator: runtimeresource.NewAPIPatchingApplicator(remote)}
+			member := func(_ context.Context, m *claim.Unstructured) (*claim.Unstructured, *claim.Unstructured, error) {
+				return tc.args.member(m)
+			}
+			var o []GroupApplicatorOption
+			if tc.args.before != nil {
+				o = append(o, WithBeforeMemberApply(tc.args.before))
+			}
+			g := NewGroupApplicator(tc.args.local, rc, member, nil, DefaultEqualityFunc, o...)
+
+			local := newMember("a")
+			observed := tc.args.observed
+			if observed == nil {
+				observed = newInstance()
+			}
+			err := g.Apply(context.Background(), local, observed, build(local, observed))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\ng.Apply(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\nReason: %s\ng.Apply(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\nReason: %s\ng.Apply(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcilerGroupMember(t *testing.T) {
+	member := claim.New(claim.WithGroupVersionKind(scopedGVK))
+	member.SetName("b")
+	member.SetNamespace("ns")
+	member.SetUID("b-uid")
+	member.Object["spec"] = map[string]interface{}{}
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+
+	type want struct {
+		key       client.ObjectKey
+		namespace string
+		finalizer bool
+		skipped   bool
+		err       error
+	}
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		scope  []ScopeResolverOption
+		opts   []ReconcilerOption
+		want
+	}{
+		"ClusterNamespace": {
+			reason: "The remote instance of a member should be read and built at the location the scope resolves, and its finalizer added",
+			get:    test.NewMockGetFn(notFound),
+			scope:  []ScopeResolverOption{WithClusterNamespace("east")},
+			want:   want{key: client.ObjectKey{Namespace: "cluster-east", Name: "b"}, namespace: "cluster-east", finalizer: true},
+		},
+		"NotAllowed": {
+			reason: "A member whose remote namespace is not allowed should fail the group",
+			get:    test.NewMockGetFn(notFound),
+			scope:  []ScopeResolverOption{WithAllowedRemoteNamespaces("other")},
+			want:   want{err: errors.Wrap(errors.Errorf(errNotAllowedFmt, "ns"), remotePrefix+errResolveScope)},
+		},
+		"NotManaged": {
+			reason: "A member whose remote instance exists but is not managed by it should fail the group",
+			get: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				o := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				o.SetName(key.Name)
+				o.SetNamespace(key.Namespace)
+				o.SetCreationTimestamp(metav1.Now())
+				o.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			want: want{key: client.ObjectKey{Namespace: "ns", Name: "b"}, err: errors.Wrap(errors.New(errNotManaged), remotePrefix+errApplyClaim)},
+		},
+		"FilteredOut": {
+			reason: "A member that is filtered out should not be applied",
+			opts:   []ReconcilerOption{WithFilter(func(_ *claim.Unstructured) bool { return false })},
+			want:   want{skipped: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var key client.ObjectKey
+			remote := &test.MockClient{MockGet: func(ctx context.Context, k client.ObjectKey, obj runtime.Object) error {
+				key = k
+				return tc.get(ctx, k, obj)
+			}}
+			finalizer := false
+			opts := append([]ReconcilerOption{
+				WithScopeResolver(NewScopeResolver(newScopedDiscovery(true), tc.scope...)),
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+					finalizer = true
+					return nil
+				}}),
+			}, tc.opts...)
+			r := NewReconciler(&fake.Manager{Client: &test.MockClient{}}, remote, scopedGVK, opts...)
+			_, desired, err := r.groupMember(context.Background(), &claim.Unstructured{Unstructured: *member.DeepCopy()})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.groupMember(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.key, key); diff != "" {
+				t.Errorf("\nReason: %s\nremote Get: -want key, +got key:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.finalizer, finalizer); diff != "" {
+				t.Errorf("\nReason: %s\nfinalizer added: -want, +got:\n%s", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.skipped, desired == nil); diff != "" {
+				t.Errorf("\nReason: %s\nr.groupMember(...): -want skipped, +got skipped:\n%s", tc.reason, diff)
+			}
+			if desired != nil {
+				if diff := cmp.Diff(tc.want.namespace, desired.GetNamespace()); diff != "" {
+					t.Errorf("\nReason: %s\ndesired namespace: -want, +got:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+const (
+	errGetInputSecret          = "cannot get input secret"
+	errApplyInputSecret        = "cannot apply input secret"
+	errListInputSecrets        = "cannot list mirrored input secrets"
+	errReleaseInputSecret      = "cannot release mirrored input secret"
+	errInputSecretNotManaged   = "secret exists but is not managed by this agent"
+	errInputSecretMissingFmt   = "input secret %s/%s does not exist yet"
+	errInputSecretNamespaceFmt = "input secret %s/%s is not in the namespace of the claim, and its namespace is not allowed"
+	errInputSecretSourceFmt    = "secret mirrors local secret %s, not %s"
+)
+
+// An inputSecretMissingError is returned when a local secret that the spec of
+// a local instance refers to doesn't exist yet.
+type inputSecretMissingError struct {
+	namespace string
+	name      string
+}
+
+func (e *inputSecretMissingError) Error() string {
+	return fmt.Sprintf(errInputSecretMissingFmt, e.namespace, e.name)
+}
+
+// IsInputSecretMissing returns true if the supplied error, or its cause, is
+// returned because an input secret doesn't exist in the local cluster.
+func IsInputSecretMissing(err error) bool {
+	_, ok := errors.Cause(err).(*inputSecretMissingError)
+	return ok
+}
+
+// An InputSecretPropagatorOption configures an InputSecretPropagator.
+type InputSecretPropagatorOption func(*InputSecretPropagator)
+
+// WithAllowedSecretNamespaces makes the InputSecretPropagator mirror the
+// secrets in the given local namespaces too. Otherwise, only the secrets in the
+// namespace of the local instance that refers to them are mirrored, so that a
+// claim cannot copy the secrets of another namespace to the remote cluster.
+func WithAllowedSecretNamespaces(ns ...string) InputSecretPropagatorOption {
+	return func(p *InputSecretPropagator) {
+		for _, n := range ns {
+			p.allowed[n] = true
+		}
+	}
+}
+
+// NewInputSecretPropagator returns a new *InputSecretPropagator that mirrors
+// the secrets referred to at the given paths of the local instances.
+func NewInputSecretPropagator(local client.Reader, remote runtimeresource.ClientApplicator, paths []string, o ...InputSecretPropagatorOption) *InputSecretPropagator {
+	p := &InputSecretPropagator{local: local, remote: remote, paths: paths, allowed: map[string]bool{}}
+	for _, fn := range o {
+		fn(p)
+	}
+	return p
+}
+
+// InputSecretPropagator mirrors the local secrets that the spec of a local
+// instance refers to, e.g. a password at spec.forProvider.passwordSecretRef,
+// to the remote cluster so that the remote instance can use them. It's the
+// reverse of ConnectionSecretPropagator. As a Configurator it only points the
+// references of the remote instance at the mirrored secrets; the Reconciler
+// calls Mirror to write them right before it writes the remote instance, and
+// Release once the local instance is gone.
+type InputSecretPropagator struct {
+	local   client.Reader
+	remote  runtimeresource.ClientApplicator
+	paths   []string
+	allowed map[string]bool
+}
+
+// Configure points the references at the configured paths of the remote
+// instance at the secrets that Mirror writes to its namespace. References that
+// are not set or have no name are skipped. An error that satisfies
+// IsInputSecretMissing is returned if a referred secret doesn't exist yet.
+func (p *InputSecretPropagator) Configure(ctx context.Context, local, remote *claim.Unstructured) error {
+	refs, err := p.secrets(ctx, local)
+	if err != nil {
+		return err
+	}
+	rp := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	for path := range refs {
+		if _, err := rp.GetString(path + ".namespace"); err != nil {
+			continue
+		}
+		if err := rp.SetValue(path+".namespace", p.namespace(refs[path], remote)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mirror writes the secrets referred to at the configured paths of the local
+// instance to the namespace of the supplied remote instance. Remote secrets
+// that were not created by the agent, or that mirror another local secret of
+// the same name, are never overwritten. All referred secrets are fetched before
+// any of them is mirrored, so nothing is written if one of them doesn't exist
+// yet. The mirrored secrets that the local instance no longer refers to are
+// released.
+func (p *InputSecretPropagator) Mirror(ctx context.Context, local, remote *claim.Unstructured) error {
+	refs, err := p.secrets(ctx, local)
+	if err != nil {
+		return err
+	}
+	mirrored := map[types.NamespacedName]bool{}
+	for _, path := range p.paths {
+		ls, ok := refs[path]
+		if !ok {
+			continue
+		}
+		rs := resource.SanitizedDeepCopyObject(ls).(*v1.Secret)
+		rs.SetNamespace(p.namespace(ls, remote))
+		source := ls.GetNamespace() + "/" + ls.GetName()
+		meta.AddAnnotations(rs, map[string]string{AnnotationKeyInputSecretSource: source})
+		// Several local instances may refer to the same secret, so the remote
+		// secret records all of them as its referrers. It shares their origin
+		// though, which the remote instance is labeled with, if at all.
+		meta.AddLabels(rs, map[string]string{
+			LabelKeyManagedBy:       LabelValueManagedBy,
+			referrerLabelKey(local): "true",
+		})
+		meta.AddLabels(rs, originLabels(remote.GetLabels()))
+		if err := p.remote.Apply(ctx, rs, mustBeManagedSecret, mustMirror(source)); err != nil {
+			return errors.Wrap(err, remotePrefix+errApplyInputSecret)
+		}
+		mirrored[types.NamespacedName{Namespace: rs.GetNamespace(), Name: rs.GetName()}] = true
+	}
+	return p.release(ctx, local, p.namespaces(local, remote.GetNamespace()), mirrored)
+}
+
+// Release releases the secrets mirrored for the supplied local instance, whose
+// remote instance is or was in the supplied namespace. A mirrored secret is
+// deleted once no local instance refers to it any more.
+func (p *InputSecretPropagator) Release(ctx context.Context, local *claim.Unstructured, remoteNamespace string) error {
+	return p.release(ctx, local, p.namespaces(local, remoteNamespace), nil)
+}
+
+// release releases the secrets in the supplied namespaces that were mirrored
+// for the supplied local instance, except the ones to keep.
+func (p *InputSecretPropagator) release(ctx context.Context, local *claim.Unstructured, namespaces []string, keep map[types.NamespacedName]bool) error {
+	key := referrerLabelKey(local)
+	for _, ns := range namespaces {
+		l := &v1.SecretList{}
+		if err := p.remote.List(ctx, l, client.InNamespace(ns), client.HasLabels{key}); err != nil {
+			return errors.Wrap(err, remotePrefix+errListInputSecrets)
+		}
+		for i := range l.Items {
+			s := &l.Items[i]
+			if keep[types.NamespacedName{Namespace: s.GetNamespace(), Name: s.GetName()}] || s.GetLabels()[LabelKeyManagedBy] != LabelValueManagedBy {
+				continue
+			}
+			meta.RemoveLabels(s, key)
+			var err error
+			if hasReferrers(s) {
+				err = p.remote.Update(ctx, s)
+			} else {
+				err = runtimeresource.IgnoreNotFound(p.remote.Delete(ctx, s))
+			}
+			if err != nil {
+				return errors.Wrap(err, remotePrefix+errReleaseInputSecret)
+			}
+		}
+	}
+	return nil
+}
+
+// secrets returns the local secrets referred to by the supplied local instance
+// by the paths they're referred to at.
+func (p *InputSecretPropagator) secrets(ctx context.Context, local *claim.Unstructured) (map[string]*v1.Secret, error) {
+	lp := fieldpath.Pave(local.GetUnstructured().UnstructuredContent())
+	refs := map[string]*v1.Secret{}
+	for _, path := range p.paths {
+		name, err := lp.GetString(path + ".name")
+		if err != nil || name == "" {
+			continue
+		}
+		ns, _ := lp.GetString(path + ".namespace")
+		if ns == "" {
+			ns = local.GetNamespace()
+		}
+		if ns != local.GetNamespace() && !p.allowed[ns] {
+			return nil, errors.Errorf(errInputSecretNamespaceFmt, ns, name)
+		}
+		ls := &v1.Secret{}
+		if err := p.local.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, ls); err != nil {
+			if kerrors.IsNotFound(err) {
+				return nil, &inputSecretMissingError{namespace: ns, name: name}
+			}
+			return nil, errors.Wrap(err, localPrefix+errGetInputSecret)
+		}
+		refs[path] = ls
+	}
+	return refs, nil
+}
+
+// namespace returns the namespace that the supplied local secret is mirrored to
+// for the supplied remote instance. A cluster scoped remote instance gets the
+// secret in the namespace of the local one.
+func (p *InputSecretPropagator) namespace(local *v1.Secret, remote *claim.Unstructured) string {
+	if remote.GetNamespace() != "" {
+		return remote.GetNamespace()
+	}
+	return local.GetNamespace()
+}
+
+// namespaces returns the namespaces that the secrets of the supplied local
+// instance may be mirrored to if its remote instance is in the supplied
+// namespace.
+func (p *InputSecretPropagator) namespaces(local *claim.Unstructured, remoteNamespace string) []string {
+	if remoteNamespace != "" {
+		return []string{remoteNamespace}
+	}
+	ns := make([]string, 0, len(p.allowed)+1)
+	if local.GetNamespace() != "" {
+		ns = append(ns, local.GetNamespace())
+	}
+	for n := range p.allowed {
+		if n != local.GetNamespace() {
+			ns = append(ns, n)
+		}
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// referrerLabelKey returns the key of the label that records that the supplied
+// local instance refers to a mirrored secret.
+func referrerLabelKey(local metav1.Object) string {
+	return LabelKeyPrefixInputSecretReferrer + string(local.GetUID())
+}
+
+// hasReferrers returns true if any local instance refers to the supplied
+// mirrored secret.
+func hasReferrers(s metav1.Object) bool {
+	for k := range s.GetLabels() {
+		if strings.HasPrefix(k, LabelKeyPrefixInputSecretReferrer) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustBeManagedSecret is an ApplyOption that refuses to overwrite a secret that
+// was not created by the agent.
+func mustBeManagedSecret(_ context.Context, current, _ runtime.Object) error {
+	c, ok := current.(*v1.Secret)
+	if !ok {
+		return nil
+	}
+	if c.GetLabels()[LabelKeyManagedBy] != LabelValueManagedBy {
+		return errors.New(errInputSecretNotManaged)
+	}
+	return nil
+}
+
+// mustMirror returns an ApplyOption that refuses to overwrite a secret that
+// mirrors another local secret than the supplied one. The secrets that were
+// mirrored before their source was recorded are taken over.
+func mustMirror(source string) runtimeresource.ApplyOption {
+	return func(_ context.Context, current, _ runtime.Object) error {
+		c, ok := current.(*v1.Secret)
+		if !ok {
+			return nil
+		}
+		if s := c.GetAnnotations()[AnnotationKeyInputSecretSource]; s != "" && s != source {
+			return errors.Errorf(errInputSecretSourceFmt, s, source)
+		}
+		return nil
+	}
+}
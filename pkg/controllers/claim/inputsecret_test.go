@@ -0,0 +1,550 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func TestInputSecretPropagator(t *testing.T) {
+	path := "spec.forProvider.passwordSecretRef"
+	withRef := func(ns string, ref map[string]interface{}) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetName("cool-claim")
+		c.SetNamespace(ns)
+		c.SetUID("local-uid")
+		if ref != nil {
+			c.Object["spec"] = map[string]interface{}{"forProvider": map[string]interface{}{"passwordSecretRef": ref}}
+		}
+		return c
+	}
+	localSecret := func() *v1.Secret {
+		s := &v1.Secret{}
+		s.SetName("db-password")
+		s.SetNamespace("local-ns")
+		s.SetUID("secret-uid")
+		s.SetResourceVersion("42")
+		s.Data = map[string][]byte{"password": []byte("hunter2")}
+		return s
+	}
+	mirrored := func(ns string) *v1.Secret {
+		s := &v1.Secret{}
+		s.SetName("db-password")
+		s.SetNamespace(ns)
+		s.SetAnnotations(map[string]string{AnnotationKeyInputSecretSource: "local-ns/db-password"})
+		s.SetLabels(map[string]string{
+			LabelKeyManagedBy: LabelValueManagedBy,
+			LabelKeyPrefixInputSecretReferrer + "local-uid": "true",
+		})
+		s.Data = map[string][]byte{"password": []byte("hunter2")}
+		return s
+	}
+	// stale is a secret mirrored for the local instance that it no longer
+	// refers to.
+	stale := func() *v1.Secret {
+		s := mirrored("remote-ns")
+		s.SetName("old-password")
+		return s
+	}
+	origin := Origin{Cluster: "cool-cluster", Environment: "production"}.Labels()
+	originated := func(c *claim.Unstructured) *claim.Unstructured {
+		c.SetLabels(origin)
+		return c
+	}
+	type args struct {
+		opts      []InputSecretPropagatorOption
+		local     *claim.Unstructured
+		remote    *claim.Unstructured
+		localErr  error
+		existing  *v1.Secret
+		remoteErr error
+		listed    []v1.Secret
+	}
+	type want struct {
+		remote   *claim.Unstructured
+		applied  *v1.Secret
+		released []string
+		err      error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Mirrored": {
+			reason: "The referred local secret should be mirrored to the namespace of the remote claim",
+			args: args{
+				local:     withRef("local-ns", map[string]interface{}{"name": "db-password"}),
+				remote:    withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				remoteErr: kerrors.NewNotFound(schema.GroupResource{}, ""),
+			},
+			want: want{
+				remote:  withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				applied: mirrored("remote-ns"),
+			},
+		},
+		"OriginLabels": {
+			reason: "The mirrored secret should have the origin labels of the remote claim",
+			args: args{
+				local:     withRef("local-ns", map[string]interface{}{"name": "db-password"}),
+				remote:    originated(withRef("remote-ns", map[string]interface{}{"name": "db-password"})),
+				remoteErr: kerrors.NewNotFound(schema.GroupResource{}, ""),
+			},
+			want: want{
+				remote: originated(withRef("remote-ns", map[string]interface{}{"name": "db-password"})),
+				applied: func() *v1.Secret {
+					s := mirrored("remote-ns")
+					s.SetLabels(map[string]string{
+						LabelKeyManagedBy: LabelValueManagedBy,
+						LabelKeyPrefixInputSecretReferrer + "local-uid": "true",
+						LabelKeyOriginCluster:                           "cool-cluster",
+						LabelKeyOriginEnvironment:                       "production",
+					})
+					return s
+				}(),
+			},
+		},
+		"NamespacedRef": {
+			reason: "The reference of the remote claim should be pointed at the mirrored secret if it has a namespace",
+			args: args{
+				opts:      []InputSecretPropagatorOption{WithAllowedSecretNamespaces("local-ns")},
+				local:     withRef("", map[string]interface{}{"name": "db-password", "namespace": "local-ns"}),
+				remote:    withRef("remote-ns", map[string]interface{}{"name": "db-password", "namespace": "local-ns"}),
+				remoteErr: kerrors.NewNotFound(schema.GroupResource{}, ""),
+			},
+			want: want{
+				remote:  withRef("remote-ns", map[string]interface{}{"name": "db-password", "namespace": "remote-ns"}),
+				applied: mirrored("remote-ns"),
+			},
+		},
+		"CrossNamespaceDenied": {
+			reason: "A secret in another namespace than the one of the claim should not be mirrored unless its namespace is allowed",
+			args: args{
+				local:  withRef("cool-ns", map[string]interface{}{"name": "db-password", "namespace": "local-ns"}),
+				remote: withRef("remote-ns", map[string]interface{}{"name": "db-password", "namespace": "local-ns"}),
+			},
+			want: want{
+				remote: withRef("remote-ns", map[string]interface{}{"name": "db-password", "namespace": "local-ns"}),
+				err:    errors.Errorf(errInputSecretNamespaceFmt, "local-ns", "db-password"),
+			},
+		},
+		"Updated": {
+			reason: "A remote secret that was mirrored before should be updated, and kept",
+			args: args{
+				local:    withRef("local-ns", map[string]interface{}{"name": "db-password"}),
+				remote:   withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				existing: mirrored("remote-ns"),
+				listed:   []v1.Secret{*mirrored("remote-ns")},
+			},
+			want: want{
+				remote:  withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				applied: mirrored("remote-ns"),
+			},
+		},
+		"SourceMismatch": {
+			reason: "A remote secret that mirrors a local secret of the same name in another namespace should not be overwritten",
+			args: args{
+				local:  withRef("local-ns", map[string]interface{}{"name": "db-password"}),
+				remote: withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				existing: func() *v1.Secret {
+					s := mirrored("remote-ns")
+					s.SetAnnotations(map[string]string{AnnotationKeyInputSecretSource: "other-ns/db-password"})
+					return s
+				}(),
+			},
+			want: want{
+				remote: withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				err:    errors.Wrap(errors.Errorf(errInputSecretSourceFmt, "other-ns/db-password", "local-ns/db-password"), remotePrefix+errApplyInputSecret),
+			},
+		},
+		"NoRef": {
+			reason: "Nothing should be mirrored if the claim does not refer to a secret",
+			args: args{
+				local:  withRef("local-ns", nil),
+				remote: withRef("remote-ns", nil),
+			},
+			want: want{
+				remote: withRef("remote-ns", nil),
+			},
+		},
+		"StaleReleased": {
+			reason: "A secret mirrored for the claim that it no longer refers to should be deleted if no other claim refers to it",
+			args: args{
+				local:  withRef("local-ns", nil),
+				remote: withRef("remote-ns", nil),
+				listed: []v1.Secret{*stale()},
+			},
+			want: want{
+				remote:   withRef("remote-ns", nil),
+				released: []string{"delete:old-password"},
+			},
+		},
+		"StaleShared": {
+			reason: "A secret mirrored for the claim that it no longer refers to should be kept for the other claims that refer to it",
+			args: args{
+				local:  withRef("local-ns", nil),
+				remote: withRef("remote-ns", nil),
+				listed: []v1.Secret{func() v1.Secret {
+					s := stale()
+					meta.AddLabels(s, map[string]string{LabelKeyPrefixInputSecretReferrer + "other-uid": "true"})
+					return *s
+				}()},
+			},
+			want: want{
+				remote:   withRef("remote-ns", nil),
+				released: []string{"update:old-password"},
+			},
+		},
+		"LocalGetFailed": {
+			reason: "An error should be returned if the referred local secret cannot be fetched",
+			args: args{
+				local:    withRef("local-ns", map[string]interface{}{"name": "db-password"}),
+				remote:   withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				localErr: errBoom,
+			},
+			want: want{
+				remote: withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				err:    errors.Wrap(errBoom, localPrefix+errGetInputSecret),
+			},
+		},
+		"LocalMissing": {
+			reason: "An error that tells the secret doesn't exist yet should be returned if the referred local secret is not found",
+			args: args{
+				local:    withRef("local-ns", map[string]interface{}{"name": "db-password"}),
+				remote:   withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				localErr: kerrors.NewNotFound(schema.GroupResource{}, ""),
+			},
+			want: want{
+				remote: withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				err:    &inputSecretMissingError{namespace: "local-ns", name: "db-password"},
+			},
+		},
+		"RemoteNotManaged": {
+			reason: "A remote secret that was not created by the agent should not be overwritten",
+			args: args{
+				local:    withRef("local-ns", map[string]interface{}{"name": "db-password"}),
+				remote:   withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				existing: &v1.Secret{},
+			},
+			want: want{
+				remote: withRef("remote-ns", map[string]interface{}{"name": "db-password"}),
+				err:    errors.Wrap(errors.New(errInputSecretNotManaged), remotePrefix+errApplyInputSecret),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				if diff := cmp.Diff(client.ObjectKey{Namespace: "local-ns", Name: "db-password"}, key); diff != "" {
+					t.Errorf("\nReason: %s\nlocal.Get(...): -want key, +got key:\n%s", tc.reason, diff)
+				}
+				if tc.args.localErr != nil {
+					return tc.args.localErr
+				}
+				localSecret().DeepCopyInto(obj.(*v1.Secret))
+				return nil
+			}}
+			var applied *v1.Secret
+			record := func(_ context.Context, obj runtime.Object) error {
+				applied = obj.(*v1.Secret).DeepCopy()
+				return nil
+			}
+			var released []string
+			remote := &test.MockClient{
+				MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+					obj.(*v1.SecretList).Items = tc.args.listed
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+					s := obj.(*v1.Secret)
+					if _, ok := s.GetLabels()[LabelKeyPrefixInputSecretReferrer+"local-uid"]; ok {
+						t.Errorf("\nReason: %s\nremote.Update(...): referrer label was not removed", tc.reason)
+					}
+					released = append(released, "update:"+s.GetName())
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj runtime.Object, _ ...client.DeleteOption) error {
+					released = append(released, "delete:"+obj.(*v1.Secret).GetName())
+					return nil
+				},
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if tc.args.existing != nil {
+						tc.args.existing.DeepCopyInto(obj.(*v1.Secret))
+						return nil
+					}
+					return tc.args.remoteErr
+				},
+				MockCreate: func(ctx context.Context, obj runtime.Object, _ ...client.CreateOption) error { return record(ctx, obj) },
+				MockPatch: func(ctx context.Context, obj runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+					return record(ctx, obj)
+				},
+			}
+			rca := runtimeresource.ClientApplicator{Client: remote, Applicator: runtimeresource.NewAPIPatchingApplicator(remote)}
+			p := NewInputSecretPropagator(local, rca, []string{path}, tc.args.opts...)
+			err := p.Configure(context.Background(), tc.args.local, tc.args.remote)
+			if err == nil {
+				err = p.Mirror(context.Background(), tc.args.local, tc.args.remote)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...) and p.Mirror(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.remote, tc.args.remote); diff != "" {
+				t.Errorf("\nReason: %s\np.Configure(...): -want remote, +got remote:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.applied, applied); diff != "" {
+				t.Errorf("\nReason: %s\napplied secret: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.released, released); diff != "" {
+				t.Errorf("\nReason: %s\nreleased secrets: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInputSecretPropagatorRelease(t *testing.T) {
+	local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+	local.SetName("cool-claim")
+	local.SetNamespace("local-ns")
+	local.SetUID("local-uid")
+	referred := func(ns string) v1.Secret {
+		s := v1.Secret{}
+		s.SetName("db-password")
+		s.SetNamespace(ns)
+		s.SetLabels(map[string]string{
+			LabelKeyManagedBy: LabelValueManagedBy,
+			LabelKeyPrefixInputSecretReferrer + "local-uid": "true",
+		})
+		return s
+	}
+	type args struct {
+		opts            []InputSecretPropagatorOption
+		remoteNamespace string
+		listErr         error
+	}
+	type want struct {
+		listed  []string
+		deleted []string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"RemoteNamespace": {
+			reason: "The secrets mirrored for the claim should be deleted from the namespace of its remote instance",
+			args: args{
+				remoteNamespace: "remote-ns",
+			},
+			want: want{
+				listed:  []string{"remote-ns"},
+				deleted: []string{"remote-ns/db-password"},
+			},
+		},
+		"ClusterScoped": {
+			reason: "The secrets mirrored for the claim should be deleted from every namespace they may be mirrored to if its remote instance is cluster scoped",
+			args: args{
+				opts: []InputSecretPropagatorOption{WithAllowedSecretNamespaces("shared-ns")},
+			},
+			want: want{
+				listed:  []string{"local-ns", "shared-ns"},
+				deleted: []string{"local-ns/db-password", "shared-ns/db-password"},
+			},
+		},
+		"ListFailed": {
+			reason: "An error should be returned if the mirrored secrets cannot be listed",
+			args: args{
+				remoteNamespace: "remote-ns",
+				listErr:         errBoom,
+			},
+			want: want{
+				listed: []string{"remote-ns"},
+				err:    errors.Wrap(errBoom, remotePrefix+errListInputSecrets),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var listed, deleted []string
+			remote := &test.MockClient{
+				MockList: func(_ context.Context, obj runtime.Object, opts ...client.ListOption) error {
+					lo := &client.ListOptions{}
+					lo.ApplyOptions(opts)
+					listed = append(listed, lo.Namespace)
+					if tc.args.listErr != nil {
+						return tc.args.listErr
+					}
+					obj.(*v1.SecretList).Items = []v1.Secret{referred(lo.Namespace)}
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj runtime.Object, _ ...client.DeleteOption) error {
+					s := obj.(*v1.Secret)
+					deleted = append(deleted, s.GetNamespace()+"/"+s.GetName())
+					return nil
+				},
+			}
+			rca := runtimeresource.ClientApplicator{Client: remote, Applicator: runtimeresource.NewAPIPatchingApplicator(remote)}
+			p := NewInputSecretPropagator(&test.MockClient{}, rca, []string{"spec.passwordSecretRef"}, tc.args.opts...)
+			err := p.Release(context.Background(), local, tc.args.remoteNamespace)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\np.Release(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.listed, listed); diff != "" {
+				t.Errorf("\nReason: %s\nlisted namespaces: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\nReason: %s\ndeleted secrets: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileInputSecrets(t *testing.T) {
+	paths := []string{"spec.passwordSecretRef", "spec.tlsSecretRef"}
+	type want struct {
+		result  reconcile.Result
+		cond    v1alpha1.Condition
+		written []string
+	}
+	cases := map[string]struct {
+		reason  string
+		secrets map[string]bool
+		dryRun  error
+		want
+	}{
+		"SecretsFirst": {
+			reason:  "The input secrets should be applied to the remote cluster before the remote instance that refers to them",
+			secrets: map[string]bool{"db-password": true, "db-tls": true},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: longWait},
+				cond:    resource.AgentSyncSuccess(),
+				written: []string{"Secret/db-password", "Secret/db-tls", "Database/cool-db"},
+			},
+		},
+		"SecretMissing": {
+			reason:  "Nothing should be written to the remote cluster while a referred input secret doesn't exist locally",
+			secrets: map[string]bool{"db-password": true},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+				cond:   resource.AgentSyncWaiting().WithMessage(localPrefix + "input secret cool-ns/db-tls does not exist yet"),
+			},
+		},
+		"DryRunRejected": {
+			reason:  "The input secrets should not be written if the dry-run of the remote instance that refers to them is rejected",
+			secrets: map[string]bool{"db-password": true, "db-tls": true},
+			dryRun:  errBoom,
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+				cond:   resource.AgentSyncError(errors.Wrap(errors.Wrap(errBoom, "cannot create object"), remotePrefix+errDryRunClaim)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var synced *claim.Unstructured
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+						if s, ok := obj.(*v1.Secret); ok {
+							if !tc.secrets[key.Name] {
+								return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+							}
+							s.SetNamespace(key.Namespace)
+							s.SetName(key.Name)
+							return nil
+						}
+						l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+						l.SetNamespace("cool-ns")
+						l.SetName("cool-db")
+						l.SetUID("local-uid")
+						l.Object["spec"] = map[string]interface{}{
+							"passwordSecretRef": map[string]interface{}{"name": "db-password"},
+							"tlsSecretRef":      map[string]interface{}{"name": "db-tls"},
+						}
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						synced = &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+						return nil
+					},
+				},
+			}
+			var written []string
+			remote := &test.MockClient{
+				MockGet:  test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				MockList: test.NewMockListFn(nil),
+				MockCreate: func(_ context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+					co := &client.CreateOptions{}
+					co.ApplyOptions(opts)
+					if len(co.DryRun) > 0 {
+						return tc.dryRun
+					}
+					switch o := obj.(type) {
+					case *v1.Secret:
+						written = append(written, "Secret/"+o.GetName())
+					case *unstructured.Unstructured:
+						written = append(written, o.GetKind()+"/"+o.GetName())
+					}
+					return nil
+				},
+			}
+			o := []ReconcilerOption{
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+				WithInputSecretRefs(paths...),
+			}
+			if tc.dryRun != nil {
+				o = append(o, WithDryRun())
+			}
+			r := NewReconciler(m, remote, scopedGVK, o...)
+			got, err := r.Reconcile(reconcile.Request{})
+			if err != nil {
+				t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cond, synced.GetCondition(resource.TypeAgentSync), test.EquateConditions()); diff != "" {
+				t.Errorf("\nReason: %s\nAgentSynced: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.written, written); diff != "" {
+				t.Errorf("\nReason: %s\nremote writes: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+const (
+	errListClaims = "cannot list claims"
+
+	defaultInventoryPageSize = 100
+)
+
+// InventoryItem is a claim that is managed by the agent together with its
+// last sync status.
+type InventoryItem struct {
+	Name               string                 `json:"name"`
+	Namespace          string                 `json:"namespace"`
+	UID                string                 `json:"uid"`
+	Synced             corev1.ConditionStatus `json:"synced"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime *metav1.Time           `json:"lastTransitionTime,omitempty"`
+}
+
+// InventoryPage is a page of the inventory. Continue is empty if this is the
+// last page.
+type InventoryPage struct {
+	Items    []InventoryItem `json:"items"`
+	Continue string          `json:"continue,omitempty"`
+}
+
+// ListInventory returns a page of at most limit claims of the given kind that
+// are managed by the agent, i.e. that have the given finalizer or, for the ones
+// synced without a finalizer, an AgentSynced condition. The continue token
+// of the previous page should be supplied to get the next one. Claims that are
+// not managed are skipped, so a page may have fewer items than the limit.
+func ListInventory(ctx context.Context, kube client.Reader, gvk schema.GroupVersionKind, finalizer string, limit int64, cont string) (*InventoryPage, error) {
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := kube.List(ctx, l, client.Limit(limit), client.Continue(cont)); err != nil {
+		return nil, errors.Wrap(err, errListClaims)
+	}
+	page := &InventoryPage{Items: []InventoryItem{}, Continue: l.GetContinue()}
+	for i := range l.Items {
+		c := &claim.Unstructured{Unstructured: l.Items[i]}
+		if !meta.FinalizerExists(c, finalizer) && c.GetCondition(resource.TypeAgentSync).Type != resource.TypeAgentSync {
+			continue
+		}
+		page.Items = append(page.Items, inventoryItem(c))
+	}
+	return page, nil
+}
+
+func inventoryItem(c *claim.Unstructured) InventoryItem {
+	item := InventoryItem{
+		Name:      c.GetName(),
+		Namespace: c.GetNamespace(),
+		UID:       string(c.GetUID()),
+		Synced:    corev1.ConditionUnknown,
+	}
+	cond := c.GetCondition(resource.TypeAgentSync)
+	if cond.Type != resource.TypeAgentSync {
+		return item
+	}
+	item.Synced = cond.Status
+	item.Reason = string(cond.Reason)
+	item.Message = cond.Message
+	t := cond.LastTransitionTime
+	item.LastTransitionTime = &t
+	return item
+}
+
+// NewManagedKinds returns an empty *ManagedKinds.
+func NewManagedKinds() *ManagedKinds {
+	return &ManagedKinds{kinds: map[string]schema.GroupVersionKind{}}
+}
+
+// ManagedKinds are the kinds of claims that the agent runs controllers for,
+// keyed by the name of their controller. It's safe for concurrent use.
+type ManagedKinds struct {
+	mu    sync.RWMutex
+	kinds map[string]schema.GroupVersionKind
+}
+
+// Add records that the controller with given name syncs the claims of the
+// given kind.
+func (k *ManagedKinds) Add(name string, gvk schema.GroupVersionKind) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.kinds[name] = gvk
+}
+
+// Remove records that the controller with given name is stopped.
+func (k *ManagedKinds) Remove(name string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.kinds, name)
+}
+
+// Has returns true if a controller syncs the claims of the given kind, at any
+// of its versions.
+func (k *ManagedKinds) Has(gvk schema.GroupVersionKind) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, m := range k.kinds {
+		if m.GroupKind() == gvk.GroupKind() {
+			return true
+		}
+	}
+	return false
+}
+
+// NewInventoryHandler returns an http.Handler that serves the inventory of the
+// claims that are managed by the agent as JSON. See ListInventory. The kind of
+// the claims is given with the group, version and kind query parameters, and
+// pagination with the limit and continue ones. Only the kinds of claims that
+// the agent syncs are served, so that the handler can't be used to read other
+// objects of the local cluster.
+func NewInventoryHandler(kube client.Reader, finalizer string, kinds *ManagedKinds) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		gvk := schema.GroupVersionKind{Group: q.Get("group"), Version: q.Get("version"), Kind: q.Get("kind")}
+		if gvk.Version == "" || gvk.Kind == "" {
+			http.Error(w, "version and kind query parameters are required", http.StatusBadRequest)
+			return
+		}
+		if !kinds.Has(gvk) {
+			http.Error(w, "kind is not a kind of claims synced by the agent", http.StatusNotFound)
+			return
+		}
+		limit := int64(defaultInventoryPageSize)
+		if s := q.Get("limit"); s != "" {
+			l, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || l <= 0 {
+				http.Error(w, "limit query parameter must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			limit = l
+		}
+		page, err := ListInventory(req.Context(), kube, gvk, finalizer, limit, q.Get("continue"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(page)
+	})
+}
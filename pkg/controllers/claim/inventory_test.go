@@ -0,0 +1,243 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func TestListInventory(t *testing.T) {
+	ltt := metav1.Unix(metav1.Now().Unix(), 0)
+	newClaim := func(name string, finalized bool, conditions ...interface{}) unstructured.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(gvk))
+		c.SetName(name)
+		c.SetNamespace("ns")
+		c.SetUID(types.UID(name + "-uid"))
+		if finalized {
+			c.SetFinalizers([]string{DefaultFinalizer})
+		}
+		if len(conditions) > 0 {
+			c.Object["status"] = map[string]interface{}{"conditions": conditions}
+		}
+		return c.Unstructured
+	}
+	synced := resource.AgentSyncSuccess()
+	failed := resource.AgentSyncError(errBoom)
+	toMap := func(v interface{}) interface{} {
+		b, _ := json.Marshal(v)
+		m := map[string]interface{}{}
+		_ = json.Unmarshal(b, &m)
+		return m
+	}
+	synced.LastTransitionTime, failed.LastTransitionTime = ltt, ltt
+
+	type args struct {
+		kube  client.Reader
+		limit int64
+		cont  string
+	}
+	type want struct {
+		page *InventoryPage
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"ListError": {
+			reason: "An error should be returned if claims cannot be listed",
+			args: args{
+				kube: &test.MockClient{MockList: test.NewMockListFn(errBoom)},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errListClaims),
+			},
+		},
+		"Assembled": {
+			reason: "Only the claims with the agent finalizer or a sync status should be reported together with their sync status",
+			args: args{
+				kube: &test.MockClient{MockList: func(_ context.Context, obj runtime.Object, opts ...client.ListOption) error {
+					lo := &client.ListOptions{}
+					lo.ApplyOptions(opts)
+					if lo.Limit != 3 || lo.Continue != "prev" {
+						t.Errorf("unexpected list options: limit %d, continue %q", lo.Limit, lo.Continue)
+					}
+					l := obj.(*unstructured.UnstructuredList)
+					l.Items = []unstructured.Unstructured{
+						newClaim("synced", true, toMap(synced)),
+						newClaim("failed", true, toMap(failed)),
+						newClaim("not-managed", false),
+						newClaim("new", true),
+						newClaim("no-finalizer", false, toMap(synced)),
+					}
+					l.SetContinue("next")
+					return nil
+				}},
+				limit: 3,
+				cont:  "prev",
+			},
+			want: want{
+				page: &InventoryPage{
+					Items: []InventoryItem{
+						{
+							Name:               "synced",
+							Namespace:          "ns",
+							UID:                "synced-uid",
+							Synced:             corev1.ConditionTrue,
+							Reason:             string(resource.ReasonAgentSyncSuccess),
+							LastTransitionTime: &ltt,
+						},
+						{
+							Name:               "failed",
+							Namespace:          "ns",
+							UID:                "failed-uid",
+							Synced:             corev1.ConditionFalse,
+							Reason:             string(resource.ReasonAgentSyncError),
+							Message:            errBoom.Error(),
+							LastTransitionTime: &ltt,
+						},
+						{
+							Name:      "new",
+							Namespace: "ns",
+							UID:       "new-uid",
+							Synced:    corev1.ConditionUnknown,
+						},
+						{
+							Name:               "no-finalizer",
+							Namespace:          "ns",
+							UID:                "no-finalizer-uid",
+							Synced:             corev1.ConditionTrue,
+							Reason:             string(resource.ReasonAgentSyncSuccess),
+							LastTransitionTime: &ltt,
+						},
+					},
+					Continue: "next",
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ListInventory(context.Background(), tc.args.kube, gvk, DefaultFinalizer, tc.args.limit, tc.args.cont)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nListInventory(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.page, got); diff != "" {
+				t.Errorf("\nReason: %s\nListInventory(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestManagedKinds(t *testing.T) {
+	k := NewManagedKinds()
+	k.Add("claim-controller", schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Claim"})
+	k.Add("other-controller", schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Other"})
+	k.Remove("other-controller")
+	cases := map[string]struct {
+		reason string
+		gvk    schema.GroupVersionKind
+		want   bool
+	}{
+		"Managed": {
+			reason: "A kind that a controller was added for should be managed",
+			gvk:    schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Claim"},
+			want:   true,
+		},
+		"OtherVersion": {
+			reason: "Any version of a managed kind should be managed",
+			gvk:    schema.GroupVersionKind{Group: "example.org", Version: "v2", Kind: "Claim"},
+			want:   true,
+		},
+		"Removed": {
+			reason: "A kind whose controller was removed should not be managed",
+			gvk:    schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Other"},
+		},
+		"Unknown": {
+			reason: "A kind that no controller was added for should not be managed",
+			gvk:    schema.GroupVersionKind{Version: "v1", Kind: "Secret"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, k.Has(tc.gvk)); diff != "" {
+				t.Errorf("\nReason: %s\nk.Has(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInventoryHandler(t *testing.T) {
+	kube := &test.MockClient{MockList: test.NewMockListFn(nil)}
+	kinds := NewManagedKinds()
+	kinds.Add("claim-controller", schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Claim"})
+	cases := map[string]struct {
+		reason string
+		url    string
+		want   int
+	}{
+		"MissingKind": {
+			reason: "Requests without the kind of the claims should be rejected",
+			url:    "/inventory?version=v1",
+			want:   http.StatusBadRequest,
+		},
+		"InvalidLimit": {
+			reason: "Requests with an invalid limit should be rejected",
+			url:    "/inventory?group=example.org&version=v1&kind=Claim&limit=-1",
+			want:   http.StatusBadRequest,
+		},
+		"UnmanagedKind": {
+			reason: "Requests for kinds that are not synced by the agent should be rejected",
+			url:    "/inventory?version=v1&kind=Secret",
+			want:   http.StatusNotFound,
+		},
+		"Success": {
+			reason: "Valid requests should be served",
+			url:    "/inventory?group=example.org&version=v1&kind=Claim&limit=10",
+			want:   http.StatusOK,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewInventoryHandler(kube, DefaultFinalizer, kinds).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			if diff := cmp.Diff(tc.want, rec.Code); diff != "" {
+				t.Errorf("\nReason: %s\nServeHTTP(...): -want status, +got status:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
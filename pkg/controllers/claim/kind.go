@@ -0,0 +1,41 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsKindMismatch returns true if the supplied error, or its cause, is returned
+// by an API server that refused to write an object because it serves another
+// kind at its location, e.g. a custom resource whose CRD was migrated to a new
+// kind under the same resource name. The API server reports it as an invalid
+// kind field.
+func IsKindMismatch(err error) bool {
+	s, ok := errors.Cause(err).(kerrors.APIStatus)
+	if !ok || s.Status().Reason != metav1.StatusReasonInvalid || s.Status().Details == nil {
+		return false
+	}
+	for _, c := range s.Status().Details.Causes {
+		if c.Field == "kind" {
+			return true
+		}
+	}
+	return false
+}
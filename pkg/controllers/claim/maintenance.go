@@ -0,0 +1,51 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import "sync/atomic"
+
+// NewMaintenanceSwitch returns a new *MaintenanceSwitch that is always enabled
+// if the given one is, e.g. by a flag.
+func NewMaintenanceSwitch(enabled bool) *MaintenanceSwitch {
+	return &MaintenanceSwitch{always: enabled}
+}
+
+// A MaintenanceSwitch puts all controllers of the agent that share it in
+// maintenance mode at once while the agent is running, e.g. when an AgentConfig
+// enables it. A nil *MaintenanceSwitch is never enabled.
+type MaintenanceSwitch struct {
+	always  bool
+	enabled int32
+}
+
+// Set enables or disables the maintenance mode. It stays enabled regardless if
+// the MaintenanceSwitch was created enabled.
+func (s *MaintenanceSwitch) Set(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&s.enabled, v)
+}
+
+// Enabled returns true if the maintenance mode is enabled.
+func (s *MaintenanceSwitch) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	return s.always || atomic.LoadInt32(&s.enabled) == 1
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMaintenanceSwitch(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		s      *MaintenanceSwitch
+		set    []bool
+		want   bool
+	}{
+		"Nil": {
+			reason: "A nil switch should never be enabled",
+		},
+		"Disabled": {
+			reason: "A switch that was created disabled should be disabled",
+			s:      NewMaintenanceSwitch(false),
+		},
+		"Set": {
+			reason: "A switch should be enabled once it's set",
+			s:      NewMaintenanceSwitch(false),
+			set:    []bool{true},
+			want:   true,
+		},
+		"Unset": {
+			reason: "A switch should be disabled once it's unset",
+			s:      NewMaintenanceSwitch(false),
+			set:    []bool{true, false},
+		},
+		"Always": {
+			reason: "A switch that was created enabled should stay enabled even if it's unset",
+			s:      NewMaintenanceSwitch(true),
+			set:    []bool{false},
+			want:   true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			for _, e := range tc.set {
+				tc.s.Set(e)
+			}
+			if diff := cmp.Diff(tc.want, tc.s.Enabled()); diff != "" {
+				t.Errorf("\nReason: %s\ns.Enabled(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+const (
+	errMergePatch = "cannot compute merge patch"
+)
+
+// NewMergePatchApplicator returns a new *MergePatchApplicator.
+func NewMergePatchApplicator(c client.Client) *MergePatchApplicator {
+	return &MergePatchApplicator{client: c}
+}
+
+// A MergePatchApplicator applies objects with the minimal JSON merge patch
+// from their current state, which works for kinds that have no strategic merge
+// metadata, e.g. the ones defined by CRDs. The patch covers the labels,
+// annotations and spec of the objects, which are what the agent writes. Like
+// any merge patch it only removes the fields that are null in the applied
+// object, e.g. the ones the Reconciler removes according to the records of
+// what it pushed, so that the fields added in the remote cluster, e.g. by
+// defaulting, are left alone.
+type MergePatchApplicator struct {
+	client client.Client
+}
+
+// Apply the supplied object. It's created if it doesn't exist, and it's not
+// written at all if it's the same as the current object.
+func (a *MergePatchApplicator) Apply(ctx context.Context, o runtime.Object, ao ...runtimeresource.ApplyOption) error {
+	u, ok := o.(runtime.Unstructured)
+	if !ok {
+		return errors.New("cannot access object content")
+	}
+	m, ok := o.(metav1.Object)
+	if !ok {
+		return errors.New("cannot access object metadata")
+	}
+
+	current := o.DeepCopyObject()
+	err := a.client.Get(ctx, types.NamespacedName{Name: m.GetName(), Namespace: m.GetNamespace()}, current)
+	if kerrors.IsNotFound(err) {
+		return errors.Wrap(a.client.Create(ctx, o), "cannot create object")
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot get object")
+	}
+	for _, fn := range ao {
+		if err := fn(ctx, current, o); err != nil {
+			return err
+		}
+	}
+
+	cu := current.(runtime.Unstructured).UnstructuredContent()
+	patch := MergePatch(mergeable(cu), mergeable(u.UnstructuredContent()))
+	if len(patch) == 0 {
+		// The supplied object reflects the current one, as if it was written.
+		u.SetUnstructuredContent(cu)
+		return nil
+	}
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return errors.Wrap(err, errMergePatch)
+	}
+	return errors.Wrap(a.client.Patch(ctx, o, client.RawPatch(types.MergePatchType, raw)), "cannot patch object")
+}
+
+// mergeable returns the labels, annotations and spec of the supplied object.
+func mergeable(o map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	if md, ok := o["metadata"].(map[string]interface{}); ok {
+		kept := map[string]interface{}{}
+		for _, k := range []string{"labels", "annotations"} {
+			if v, ok := md[k]; ok {
+				kept[k] = v
+			}
+		}
+		out["metadata"] = kept
+	}
+	if s, ok := o["spec"]; ok {
+		out["spec"] = s
+	}
+	return out
+}
+
+// MergePatch returns the JSON merge patch, as per RFC 7386, that turns the
+// supplied current object into the desired one. The fields that are null in
+// the desired object are set to null so that they're removed, the fields that
+// only the current object has are left alone, and arrays are replaced as a
+// whole. The patch is empty if the objects are the same.
+func MergePatch(current, desired map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for k, dv := range desired {
+		cv, ok := current[k]
+		if !ok {
+			// A null is the same as an absent field in a merge patch.
+			if dv != nil {
+				patch[k] = dv
+			}
+			continue
+		}
+		cm, cIsMap := cv.(map[string]interface{})
+		dm, dIsMap := dv.(map[string]interface{})
+		if cIsMap && dIsMap {
+			if p := MergePatch(cm, dm); len(p) > 0 {
+				patch[k] = p
+			}
+			continue
+		}
+		// Values that encode the same, e.g. 1 and 1.0, need no patch.
+		if !jsonEqual(cv, dv) {
+			patch[k] = dv
+		}
+	}
+	return patch
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestMergePatch(t *testing.T) {
+	type args struct {
+		current map[string]interface{}
+		desired map[string]interface{}
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want map[string]interface{}
+	}{
+		"Unchanged": {
+			reason: "The patch should be empty if the objects are the same, regardless of how their numbers are decoded",
+			args: args{
+				current: map[string]interface{}{"spec": map[string]interface{}{"size": int64(10), "zones": []interface{}{"a"}}},
+				desired: map[string]interface{}{"spec": map[string]interface{}{"size": float64(10), "zones": []interface{}{"a"}}},
+			},
+			want: map[string]interface{}{},
+		},
+		"Changed": {
+			reason: "Only the changed fields should be patched",
+			args: args{
+				current: map[string]interface{}{"spec": map[string]interface{}{"size": int64(10), "engine": "postgres"}},
+				desired: map[string]interface{}{"spec": map[string]interface{}{"size": int64(20), "engine": "postgres"}},
+			},
+			want: map[string]interface{}{"spec": map[string]interface{}{"size": int64(20)}},
+		},
+		"Added": {
+			reason: "The added fields should be patched with their whole value",
+			args: args{
+				current: map[string]interface{}{"spec": map[string]interface{}{"size": int64(10)}},
+				desired: map[string]interface{}{"spec": map[string]interface{}{"size": int64(10), "backup": map[string]interface{}{"enabled": true}}},
+			},
+			want: map[string]interface{}{"spec": map[string]interface{}{"backup": map[string]interface{}{"enabled": true}}},
+		},
+		"CurrentOnly": {
+			reason: "The fields that only the current object has, e.g. defaulted ones, should be left alone",
+			args: args{
+				current: map[string]interface{}{"spec": map[string]interface{}{"size": int64(10), "backup": map[string]interface{}{"enabled": true}}},
+				desired: map[string]interface{}{"spec": map[string]interface{}{"size": int64(10)}},
+			},
+			want: map[string]interface{}{},
+		},
+		"ExplicitNull": {
+			reason: "A field that is null in the desired object should be deleted, and not be patched if it's absent anyway",
+			args: args{
+				current: map[string]interface{}{"spec": map[string]interface{}{"size": int64(10)}},
+				desired: map[string]interface{}{"spec": map[string]interface{}{"size": nil, "engine": nil}},
+			},
+			want: map[string]interface{}{"spec": map[string]interface{}{"size": nil}},
+		},
+		"ArrayReplaced": {
+			reason: "A changed array should be replaced as a whole",
+			args: args{
+				current: map[string]interface{}{"spec": map[string]interface{}{"zones": []interface{}{"a", "b"}}},
+				desired: map[string]interface{}{"spec": map[string]interface{}{"zones": []interface{}{"a"}}},
+			},
+			want: map[string]interface{}{"spec": map[string]interface{}{"zones": []interface{}{"a"}}},
+		},
+		"TypeChanged": {
+			reason: "A field that is no longer an object should be replaced",
+			args: args{
+				current: map[string]interface{}{"spec": map[string]interface{}{"engine": map[string]interface{}{"name": "postgres"}}},
+				desired: map[string]interface{}{"spec": map[string]interface{}{"engine": "postgres"}},
+			},
+			want: map[string]interface{}{"spec": map[string]interface{}{"engine": "postgres"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := MergePatch(tc.args.current, tc.args.desired)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nMergePatch(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMergePatchApplicator(t *testing.T) {
+	newClaim := func(labels map[string]string, spec map[string]interface{}) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetName("cool-claim")
+		c.SetNamespace("cool-ns")
+		c.SetLabels(labels)
+		c.Object["spec"] = spec
+		c.Object["status"] = map[string]interface{}{"bindingPhase": "Bound"}
+		return c
+	}
+	type args struct {
+		current *claim.Unstructured
+		getErr  error
+		desired *claim.Unstructured
+	}
+	type want struct {
+		patch   string
+		created bool
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Patched": {
+			reason: "The minimal merge patch of the labels, annotations and spec should be sent, leaving the status and the fields the desired object lacks alone",
+			args: args{
+				current: newClaim(map[string]string{"team": "a"}, map[string]interface{}{"size": int64(10), "engine": "postgres"}),
+				desired: func() *claim.Unstructured {
+					c := newClaim(nil, map[string]interface{}{"size": float64(20)})
+					delete(c.Object, "status")
+					return c
+				}(),
+			},
+			want: want{
+				patch: `{"spec":{"size":20}}`,
+			},
+		},
+		"Unchanged": {
+			reason: "Nothing should be sent if the object is the same as the current one",
+			args: args{
+				current: newClaim(nil, map[string]interface{}{"size": int64(10)}),
+				desired: newClaim(nil, map[string]interface{}{"size": int64(10)}),
+			},
+		},
+		"NotFound": {
+			reason: "An object that does not exist yet should be created",
+			args: args{
+				getErr:  kerrors.NewNotFound(schema.GroupResource{}, ""),
+				desired: newClaim(nil, map[string]interface{}{"size": int64(10)}),
+			},
+			want: want{
+				created: true,
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the current object cannot be fetched",
+			args: args{
+				getErr:  errBoom,
+				desired: newClaim(nil, map[string]interface{}{"size": int64(10)}),
+			},
+			want: want{
+				err: errors.Wrap(errBoom, "cannot get object"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var patch string
+			created := false
+			c := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if tc.args.getErr != nil {
+						return tc.args.getErr
+					}
+					tc.args.current.DeepCopyInto(obj.(*kunstructured.Unstructured))
+					return nil
+				},
+				MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error {
+					created = true
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+					if diff := cmp.Diff(types.MergePatchType, p.Type()); diff != "" {
+						t.Errorf("\nReason: %s\nPatch type: -want, +got:\n%s", tc.reason, diff)
+					}
+					b, _ := p.Data(obj)
+					patch = string(b)
+					return nil
+				},
+			}
+			err := NewMergePatchApplicator(c).Apply(context.Background(), tc.args.desired)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\na.Apply(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patch, patch); diff != "" {
+				t.Errorf("\nReason: %s\nPatch: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\nReason: %s\nCreated: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileMergePatchKeepsRemoteFields(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*kunstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var written *kunstructured.Unstructured
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetCreationTimestamp(now)
+			r.SetAnnotations(map[string]string{
+				AnnotationKeyLocalUID:     "local-uid",
+				AnnotationKeyManagedSpec:  `["spec.backup.enabled","spec.size"]`,
+				"remote.example.org/note": "keep",
+			})
+			r.Object["spec"] = map[string]interface{}{
+				"size":   int64(10),
+				"backup": map[string]interface{}{"enabled": true},
+				"tier":   "standard",
+			}
+			r.DeepCopyInto(obj.(*kunstructured.Unstructured))
+			return nil
+		},
+		MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, err := p.Data(obj)
+			if err != nil {
+				return err
+			}
+			written = &kunstructured.Unstructured{}
+			return written.UnmarshalJSON(b)
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithMergePatch(),
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	if written == nil {
+		t.Fatalf("\nReason: %s\nr.Reconcile(...): remote claim was not patched", "The field removed from the local claim should be removed from the remote claim")
+	}
+	spec, _ := written.Object["spec"].(map[string]interface{})
+	if backup, ok := spec["backup"]; !ok || backup != nil {
+		t.Errorf("\nReason: %s\nspec.backup: -want, +got:\n%s", "The field removed from the local claim should be patched with null", cmp.Diff(nil, backup))
+	}
+	if tier, ok := spec["tier"]; ok {
+		t.Errorf("\nReason: %s\nspec.tier: -want, +got:\n%s", "The field added in the remote cluster should not be patched", cmp.Diff(nil, tier))
+	}
+	md, _ := written.Object["metadata"].(map[string]interface{})
+	annotations, _ := md["annotations"].(map[string]interface{})
+	if note, ok := annotations["remote.example.org/note"]; ok {
+		t.Errorf("\nReason: %s\nmetadata.annotations: -want, +got:\n%s", "The annotation added in the remote cluster should not be patched", cmp.Diff(nil, note))
+	}
+}
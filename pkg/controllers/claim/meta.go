@@ -0,0 +1,376 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+// DefaultFinalizer is the finalizer the agent adds to the local instances it
+// syncs unless another one is configured.
+const DefaultFinalizer = "agent.crossplane.io/sync"
+
+// ValidateFinalizer returns an error if the supplied finalizer is not a
+// qualified name with a domain prefix, e.g. example.org/sync, which keeps it
+// from colliding with the finalizers of other controllers.
+func ValidateFinalizer(name string) error {
+	if !strings.Contains(name, "/") {
+		return errors.Errorf("finalizer %q must have a domain prefix, e.g. example.org/sync", name)
+	}
+	if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+		return errors.Errorf("finalizer %q is invalid: %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Annotation keys used by the agent.
+const (
+	// AnnotationKeyLocalUID is stamped on the remote instance to record the UID
+	// of the local instance that manages it.
+	AnnotationKeyLocalUID = "agent.crossplane.io/local-uid"
+
+	// AnnotationKeyLocalName is stamped on the remote instance to record the
+	// name of the local instance that manages it.
+	AnnotationKeyLocalName = "agent.crossplane.io/local-name"
+
+	// AnnotationKeyLocalNamespace is stamped on the remote instance to record
+	// the namespace of the local instance that manages it, which the namespace
+	// of the remote instance may differ from, e.g. with a cluster namespace.
+	AnnotationKeyLocalNamespace = "agent.crossplane.io/local-namespace"
+
+	// AnnotationKeyAdopt can be set to "true" on the local instance to allow
+	// the agent to take over a remote instance that exists but is not managed
+	// by any local instance.
+	AnnotationKeyAdopt = "agent.crossplane.io/adopt"
+
+	// AnnotationKeySyncNow can be set to a new value on the local instance to
+	// make the agent retry syncing a claim that it gave up on.
+	AnnotationKeySyncNow = "agent.crossplane.io/sync-now"
+
+	// AnnotationKeySkipSecret can be set to "true" on the local instance to
+	// stop the agent from propagating its connection secret, e.g. when the
+	// consumer reads it from the remote cluster directly.
+	AnnotationKeySkipSecret = "agent.crossplane.io/skip-secret"
+
+	// AnnotationKeyDetach can be set to "true" on the local instance to make
+	// the agent stop managing its remote instance without deleting it. The
+	// remote instance is kept even if the local one is deleted afterwards.
+	AnnotationKeyDetach = "agent.crossplane.io/detach"
+
+	// AnnotationKeyDependsOn can be set on the local instance to a comma
+	// separated list of the names of the local instances of the same kind in
+	// the same namespace that must be Ready before its remote instance is
+	// created.
+	AnnotationKeyDependsOn = "agent.crossplane.io/depends-on"
+
+	// AnnotationKeyDeletionConfirmation is set on the local instance by the
+	// agent to record whether the remote cluster confirmed that the resources
+	// backing the remote instance are deleted. It can be set to "Confirmed" to
+	// release a local instance whose deletion will never be confirmed.
+	AnnotationKeyDeletionConfirmation = "agent.crossplane.io/deletion-confirmation"
+
+	// AnnotationKeyManagedLabels is set on the remote instance by the agent to
+	// record the comma separated keys of the labels it pushed from the local
+	// instance, so that it removes only those once the local instance no longer
+	// has them and leaves the labels added in the remote cluster alone.
+	AnnotationKeyManagedLabels = "agent.crossplane.io/managed-labels"
+
+	// AnnotationKeyManagedSpec is set on the remote instance by the agent to
+	// record the JSON array of the paths of the spec fields it pushed from the
+	// local instance, so that it removes only those once the local instance no
+	// longer has them and leaves the fields added in the remote cluster alone.
+	AnnotationKeyManagedSpec = "agent.crossplane.io/managed-spec"
+
+	// AnnotationKeyManagedEntries is set on the remote instance by the agent
+	// to record the JSON object of the entries of the merged arrays, e.g.
+	// spec.environmentConfigRefs, that it pushed from the local instance by
+	// their path, so that it removes only those once the local instance no
+	// longer has them and late-initializes only the entries added in the
+	// remote cluster.
+	AnnotationKeyManagedEntries = "agent.crossplane.io/managed-entries"
+
+	// AnnotationKeyTruncatedAnnotations is set on the remote instance by the
+	// agent to record the comma separated keys of the annotations of the local
+	// instance that it didn't propagate because they exceed the MetadataLimits,
+	// so that it removes them if they were propagated before.
+	AnnotationKeyTruncatedAnnotations = "agent.crossplane.io/truncated-annotations"
+
+	// AnnotationKeyEncryptedKeys is set on the local connection secrets by the
+	// agent to record the comma separated keys whose values it encrypted, so
+	// that their consumers know which values to decrypt.
+	AnnotationKeyEncryptedKeys = "agent.crossplane.io/encrypted-keys"
+
+	// AnnotationKeyDesiredSpec is set on the local instance by the agent, if
+	// it's configured to, to show the JSON of the spec it computed for the
+	// remote instance. It's meant for troubleshooting only and is never pushed
+	// to the remote instance.
+	AnnotationKeyDesiredSpec = "agent.crossplane.io/desired-spec"
+
+	// AnnotationKeyInputSecretSource is set on the input secrets mirrored to
+	// the remote cluster by the agent to record the namespace and name of the
+	// local secret they mirror, so that the local secrets of the same name in
+	// different namespaces don't overwrite each other.
+	AnnotationKeyInputSecretSource = "agent.crossplane.io/input-secret-source"
+)
+
+// Policy annotations.
+const (
+	// PolicyAnnotationDomain is the domain of the annotations that control and
+	// record how Crossplane reconciles the remote instance.
+	PolicyAnnotationDomain = "crossplane.io"
+
+	// AnnotationKeyPaused can be set to "true" to pause the reconciliation of
+	// the remote instance by Crossplane.
+	AnnotationKeyPaused = PolicyAnnotationDomain + "/paused"
+
+	// AnnotationKeyExternalCreatePending is set on the remote instance by
+	// Crossplane right before it creates the external resource.
+	AnnotationKeyExternalCreatePending = PolicyAnnotationDomain + "/external-create-pending"
+
+	// AnnotationKeyExternalCreateSucceeded is set on the remote instance by
+	// Crossplane once it created the external resource.
+	AnnotationKeyExternalCreateSucceeded = PolicyAnnotationDomain + "/external-create-succeeded"
+
+	// AnnotationKeyExternalCreateFailed is set on the remote instance by
+	// Crossplane if it failed to create the external resource.
+	AnnotationKeyExternalCreateFailed = PolicyAnnotationDomain + "/external-create-failed"
+)
+
+// DefaultPolicyAnnotations returns the policy annotations that are safe to pass
+// through from the local instance to the remote one.
+func DefaultPolicyAnnotations() []string {
+	return []string{AnnotationKeyPaused}
+}
+
+// DefaultRemoteOwnedAnnotations returns the annotations that Crossplane records
+// its progress with on the remote instance. They keep their remote values
+// rather than being overwritten by, or removed along with, the local ones.
+func DefaultRemoteOwnedAnnotations() []string {
+	return []string{AnnotationKeyExternalCreatePending, AnnotationKeyExternalCreateSucceeded, AnnotationKeyExternalCreateFailed}
+}
+
+// Values of AnnotationKeyDeletionConfirmation.
+const (
+	// DeletionConfirmationPending means that the deletion of the remote
+	// instance was requested but not confirmed yet.
+	DeletionConfirmationPending = "Pending"
+
+	// DeletionConfirmationConfirmed means that the remote cluster confirmed the
+	// deletion of the resources backing the remote instance.
+	DeletionConfirmationConfirmed = "Confirmed"
+)
+
+// Label keys and values used by the agent.
+const (
+	// LabelKeyManagedBy is set on the local connection secrets created by the
+	// agent.
+	LabelKeyManagedBy = "app.kubernetes.io/managed-by"
+
+	// LabelValueManagedBy is the value of LabelKeyManagedBy for the agent.
+	LabelValueManagedBy = "crossplane-agent"
+
+	// LabelKeyOwnerUID is set on the local connection secrets created by the
+	// agent to record the UID of the local instance they belong to.
+	LabelKeyOwnerUID = "agent.crossplane.io/owner-uid"
+
+	// LabelKeyClaimName is set on the local connection secrets that are
+	// written to a central namespace to record the name of the local instance
+	// they belong to.
+	LabelKeyClaimName = "agent.crossplane.io/claim-name"
+
+	// LabelKeyClaimNamespace is set on the local connection secrets that are
+	// written to a central namespace to record the namespace of the local
+	// instance they belong to.
+	LabelKeyClaimNamespace = "agent.crossplane.io/claim-namespace"
+
+	// LabelKeyGroup can be set on the local instances of the same kind in the
+	// same namespace to make the agent apply them to the remote cluster
+	// together or not at all.
+	LabelKeyGroup = "agent.crossplane.io/group"
+
+	// LabelKeyOriginCluster is set on the remote instances and the mirrored
+	// secrets to record the name of the local cluster they originate from.
+	LabelKeyOriginCluster = "agent.crossplane.io/origin-cluster"
+
+	// LabelKeyOriginRegion is set on the remote instances and the mirrored
+	// secrets to record the region of the local cluster.
+	LabelKeyOriginRegion = "agent.crossplane.io/origin-region"
+
+	// LabelKeyOriginEnvironment is set on the remote instances and the
+	// mirrored secrets to record the environment of the local cluster, e.g.
+	// staging or production.
+	LabelKeyOriginEnvironment = "agent.crossplane.io/origin-environment"
+
+	// LabelKeyPrefixInputSecretReferrer is the prefix of the labels that are
+	// set on the input secrets mirrored to the remote cluster by the agent. It
+	// is followed by the UID of each local instance that refers to the secret,
+	// so that the secret is deleted once none of them does any more.
+	LabelKeyPrefixInputSecretReferrer = "referrer.agent.crossplane.io/"
+)
+
+// IsManaged returns true if the supplied remote object is managed by a local
+// object.
+func IsManaged(remote metav1.Object) bool {
+	return remote.GetAnnotations()[AnnotationKeyLocalUID] != ""
+}
+
+// IsManagedBy returns true if the supplied remote object is managed by the
+// supplied local object.
+func IsManagedBy(remote, local metav1.Object) bool {
+	uid := remote.GetAnnotations()[AnnotationKeyLocalUID]
+	return uid != "" && uid == string(local.GetUID())
+}
+
+// AllowsAdoption returns true if the supplied local object allows the agent to
+// adopt the unmanaged remote object it corresponds to.
+func AllowsAdoption(local metav1.Object) bool {
+	return local.GetAnnotations()[AnnotationKeyAdopt] == "true"
+}
+
+// IsLegacyManaged returns true if the supplied unmanaged remote instance looks
+// like it was written for the supplied local instance by a version of the agent
+// that didn't record the local instance on the remote one, which copied the
+// labels and the spec of the local instance. The local instance must have at
+// least one label, all of which the remote instance must have, and the remote
+// spec must have all fields of the local one; the remote cluster may have
+// defaulted others. A local instance without labels gives no signal that the
+// remote instance was written for it, so it has to allow adoption explicitly.
+func IsLegacyManaged(remote, local *claim.Unstructured) bool {
+	if IsManaged(remote) || len(local.GetLabels()) == 0 {
+		return false
+	}
+	for k, v := range local.GetLabels() {
+		if rv, ok := remote.GetLabels()[k]; !ok || rv != v {
+			return false
+		}
+	}
+	return containsFields(remote.Object["spec"], local.Object["spec"])
+}
+
+// containsFields returns true if the supplied value has all fields of the other
+// one with the same values. Values that are not objects must be equal.
+func containsFields(have, want interface{}) bool {
+	wm, ok := want.(map[string]interface{})
+	if !ok {
+		return want == nil || jsonEqual(have, want)
+	}
+	hm, _ := have.(map[string]interface{})
+	for k, wv := range wm {
+		hv, ok := hm[k]
+		if !ok || !containsFields(hv, wv) {
+			return false
+		}
+	}
+	return true
+}
+
+// SkipsSecretPropagation returns true if the supplied local object opted out of
+// the propagation of its connection secret.
+func SkipsSecretPropagation(local metav1.Object) bool {
+	return local.GetAnnotations()[AnnotationKeySkipSecret] == "true"
+}
+
+// Detaches returns true if the supplied local object asked the agent to stop
+// managing its remote instance without deleting it.
+func Detaches(local metav1.Object) bool {
+	return local.GetAnnotations()[AnnotationKeyDetach] == "true"
+}
+
+// DependsOn returns the names of the local instances the supplied local object
+// depends on.
+func DependsOn(local metav1.Object) []string {
+	var names []string
+	for _, n := range strings.Split(local.GetAnnotations()[AnnotationKeyDependsOn], ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// AgentDomain is the domain of the annotations and labels that the agent
+// records its bookkeeping with.
+const AgentDomain = "agent.crossplane.io"
+
+// RemoveAgentMetadata removes the annotations and labels in the AgentDomain,
+// or in a subdomain of it, from the supplied object, so that no agent that
+// later sees it takes it for one it has written.
+func RemoveAgentMetadata(o metav1.Object) {
+	var annotations, labels []string
+	for k := range o.GetAnnotations() {
+		if isAgentKey(k) {
+			annotations = append(annotations, k)
+		}
+	}
+	for k := range o.GetLabels() {
+		if isAgentKey(k) {
+			labels = append(labels, k)
+		}
+	}
+	meta.RemoveAnnotations(o, annotations...)
+	meta.RemoveLabels(o, labels...)
+}
+
+func isAgentKey(k string) bool {
+	i := strings.Index(k, "/")
+	if i < 0 {
+		return false
+	}
+	d := k[:i]
+	return d == AgentDomain || strings.HasSuffix(d, "."+AgentDomain)
+}
+
+// IsOwnedSecret returns true if the supplied secret was created by the agent
+// for the supplied local object.
+func IsOwnedSecret(secret, local metav1.Object) bool {
+	l := secret.GetLabels()
+	return l[LabelKeyManagedBy] == LabelValueManagedBy && l[LabelKeyOwnerUID] != "" && l[LabelKeyOwnerUID] == string(local.GetUID())
+}
+
+// LocalSecretKey returns the key of the local connection secret with the given
+// name that belongs to the supplied local object. The secret is in the
+// namespace of the local object unless a central namespace is given, in which
+// case its name is prefixed with the namespace of the local object and a dot so
+// that the secrets of the objects in different namespaces don't collide. Since
+// a namespace cannot contain a dot, ns-a/b-c and ns-a-b/c don't either. Names
+// that would be too long are truncated and suffixed with a hash of the full
+// name.
+func LocalSecretKey(local metav1.Object, name, central string) types.NamespacedName {
+	if central == "" || central == local.GetNamespace() {
+		return types.NamespacedName{Namespace: local.GetNamespace(), Name: name}
+	}
+	if local.GetNamespace() == "" {
+		return types.NamespacedName{Namespace: central, Name: name}
+	}
+	n := local.GetNamespace() + "." + name
+	if len(n) > validation.DNS1123SubdomainMaxLength {
+		sum := sha256.Sum256([]byte(n))
+		suffix := hex.EncodeToString(sum[:])[:8]
+		n = strings.TrimRight(n[:validation.DNS1123SubdomainMaxLength-len(suffix)-1], ".-") + "-" + suffix
+	}
+	return types.NamespacedName{Namespace: central, Name: n}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errMetadataCountFmt = "claim has %d %s to propagate, more than the limit of %d"
+	errMetadataBytesFmt = "%s of claim to propagate have %d bytes, more than the limit of %d"
+
+	agentDomainPrefix = "agent.crossplane.io/"
+)
+
+// A MetadataLimitPolicy determines what happens to a remote instance whose
+// labels or annotations propagated from its local instance exceed the
+// MetadataLimits.
+type MetadataLimitPolicy string
+
+// Policies for the metadata that exceeds the limits.
+const (
+	// MetadataLimitPolicyTruncate propagates only as many labels or
+	// annotations as the limits allow, in the order of their keys.
+	MetadataLimitPolicyTruncate MetadataLimitPolicy = "Truncate"
+
+	// MetadataLimitPolicyError refuses to write a remote instance whose labels
+	// or annotations exceed the limits.
+	MetadataLimitPolicyError MetadataLimitPolicy = "Error"
+)
+
+// MetadataLimits bound the labels and annotations that are propagated from a
+// local instance to its remote instance. A zero limit means no limit.
+type MetadataLimits struct {
+	// MaxLabels is the maximum number of labels.
+	MaxLabels int
+
+	// MaxAnnotations is the maximum number of annotations.
+	MaxAnnotations int
+
+	// MaxBytes is the maximum total size of the keys and values of the labels,
+	// and separately of the annotations.
+	MaxBytes int
+}
+
+// NewMetadataLimiter returns a new *MetadataLimiter that applies the given
+// policy to the metadata that exceeds the given limits.
+func NewMetadataLimiter(l MetadataLimits, p MetadataLimitPolicy, log logging.Logger) *MetadataLimiter {
+	return &MetadataLimiter{limits: l, policy: p, log: log, dropped: map[string]string{}}
+}
+
+// A MetadataLimiter guards the remote instances against metadata bloat, e.g. a
+// controller of the local cluster that keeps appending to an annotation. Only
+// the labels and annotations propagated from the local instance are limited;
+// the ones that were added in the remote cluster or by the agent, including
+// the ones in the agent.crossplane.io domain, are always kept.
+type MetadataLimiter struct {
+	limits MetadataLimits
+	policy MetadataLimitPolicy
+	log    logging.Logger
+
+	// dropped records what was last dropped from each remote instance, so that
+	// the truncation is logged only when it changes.
+	mu      sync.Mutex
+	dropped map[string]string
+}
+
+// Configure applies the policy to the labels and annotations of the supplied
+// remote instance that are propagated from the supplied local instance. The
+// keys of the dropped annotations are recorded in
+// AnnotationKeyTruncatedAnnotations so that they're removed from the remote
+// instance if they were propagated before; the dropped labels are removed
+// along with the other labels recorded in AnnotationKeyManagedLabels.
+func (ml *MetadataLimiter) Configure(_ context.Context, local, remote *claim.Unstructured) error {
+	labels, droppedLabels, err := ml.limit("labels", local.GetLabels(), remote.GetLabels(), ml.limits.MaxLabels)
+	if err != nil {
+		return err
+	}
+	if len(droppedLabels) > 0 {
+		remote.SetLabels(labels)
+	}
+	annotations, droppedAnnotations, err := ml.limit("annotations", local.GetAnnotations(), remote.GetAnnotations(), ml.limits.MaxAnnotations)
+	if err != nil {
+		return err
+	}
+	if len(droppedAnnotations) > 0 {
+		remote.SetAnnotations(annotations)
+		meta.AddAnnotations(remote, map[string]string{AnnotationKeyTruncatedAnnotations: strings.Join(droppedAnnotations, ",")})
+	}
+	if ml.changed(remote, droppedLabels, droppedAnnotations) {
+		ml.log.Info("Truncated metadata of remote instance", "name", remote.GetName(), "dropped-labels", droppedLabels, "dropped-annotations", droppedAnnotations)
+	}
+	return nil
+}
+
+// changed records the supplied dropped keys of the supplied remote instance,
+// and returns true if something is dropped and it's not what was dropped the
+// last time.
+func (ml *MetadataLimiter) changed(remote *claim.Unstructured, labels, annotations []string) bool {
+	key := remote.GetObjectKind().GroupVersionKind().GroupKind().String() + "/" + remote.GetNamespace() + "/" + remote.GetName()
+	dropped := strings.Join(labels, ",") + ";" + strings.Join(annotations, ",")
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if len(labels)+len(annotations) == 0 {
+		delete(ml.dropped, key)
+		return false
+	}
+	last, ok := ml.dropped[key]
+	ml.dropped[key] = dropped
+	return !ok || last != dropped
+}
+
+// truncatedAnnotations returns the keys of the annotations that the supplied
+// remote instance records in AnnotationKeyTruncatedAnnotations.
+func truncatedAnnotations(remote *claim.Unstructured) []string {
+	v := remote.GetAnnotations()[AnnotationKeyTruncatedAnnotations]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// limit returns the supplied remote metadata without the entries propagated
+// from the supplied local metadata that exceed the limits, and the keys of the
+// dropped entries. It returns an error instead if the policy doesn't allow
+// truncation.
+func (ml *MetadataLimiter) limit(kind string, local, remote map[string]string, maxCount int) (map[string]string, []string, error) {
+	var propagated []string
+	for k, v := range remote {
+		if lv, ok := local[k]; ok && lv == v && !strings.HasPrefix(k, agentDomainPrefix) {
+			propagated = append(propagated, k)
+		}
+	}
+	sort.Strings(propagated)
+	size := 0
+	for _, k := range propagated {
+		size += len(k) + len(remote[k])
+	}
+	countOK := maxCount == 0 || len(propagated) <= maxCount
+	bytesOK := ml.limits.MaxBytes == 0 || size <= ml.limits.MaxBytes
+	if countOK && bytesOK {
+		return remote, nil, nil
+	}
+	if ml.policy == MetadataLimitPolicyError {
+		if !countOK {
+			return nil, nil, errors.Errorf(errMetadataCountFmt, len(propagated), kind, maxCount)
+		}
+		return nil, nil, errors.Errorf(errMetadataBytesFmt, kind, size, ml.limits.MaxBytes)
+	}
+
+	out := make(map[string]string, len(remote))
+	for k, v := range remote {
+		out[k] = v
+	}
+	var dropped []string
+	count, size := 0, 0
+	for _, k := range propagated {
+		s := len(k) + len(remote[k])
+		if (maxCount > 0 && count+1 > maxCount) || (ml.limits.MaxBytes > 0 && size+s > ml.limits.MaxBytes) {
+			delete(out, k)
+			dropped = append(dropped, k)
+			continue
+		}
+		count++
+		size += s
+	}
+	return out, dropped, nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestMetadataLimiter(t *testing.T) {
+	labels := map[string]string{"a": "1", "b": "2", "c": "3"}
+	type args struct {
+		limits      MetadataLimits
+		policy      MetadataLimitPolicy
+		local       map[string]string
+		remote      map[string]string
+		annotations map[string]string
+	}
+	type want struct {
+		labels      map[string]string
+		annotations map[string]string
+		err         error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"WithinLimits": {
+			reason: "Metadata within the limits should be propagated as it is",
+			args: args{
+				limits: MetadataLimits{MaxLabels: 3, MaxBytes: 6},
+				policy: MetadataLimitPolicyTruncate,
+				local:  labels,
+				remote: labels,
+			},
+			want: want{labels: labels},
+		},
+		"TooManyTruncated": {
+			reason: "Only as many labels as the limit allows should be propagated, in the order of their keys",
+			args: args{
+				limits: MetadataLimits{MaxLabels: 2},
+				policy: MetadataLimitPolicyTruncate,
+				local:  labels,
+				remote: labels,
+			},
+			want: want{labels: map[string]string{"a": "1", "b": "2"}},
+		},
+		"TooLargeTruncated": {
+			reason: "Only as many annotations as fit in the size limit should be propagated",
+			args: args{
+				limits:      MetadataLimits{MaxBytes: 10},
+				policy:      MetadataLimitPolicyTruncate,
+				annotations: map[string]string{"a": "1", "bloat": "xxxxxxxxxxxxxxxx", "c": "3"},
+			},
+			want: want{annotations: map[string]string{"a": "1", "c": "3", AnnotationKeyTruncatedAnnotations: "bloat"}},
+		},
+		"NotPropagatedKept": {
+			reason: "The labels added in the remote cluster or by the agent should neither count nor be dropped",
+			args: args{
+				limits: MetadataLimits{MaxLabels: 1},
+				policy: MetadataLimitPolicyTruncate,
+				local:  map[string]string{"a": "1", "b": "2"},
+				remote: map[string]string{"a": "1", "b": "2", "crossplane.io/claim-name": "db", "agent.crossplane.io/group": "g"},
+			},
+			want: want{labels: map[string]string{"a": "1", "crossplane.io/claim-name": "db", "agent.crossplane.io/group": "g"}},
+		},
+		"TooManyError": {
+			reason: "An error should be returned if there are too many labels and the policy doesn't allow truncation",
+			args: args{
+				limits: MetadataLimits{MaxLabels: 2},
+				policy: MetadataLimitPolicyError,
+				local:  labels,
+				remote: labels,
+			},
+			want: want{labels: labels, err: errors.Errorf(errMetadataCountFmt, 3, "labels", 2)},
+		},
+		"TooLargeError": {
+			reason: "An error should be returned if the annotations are too large and the policy doesn't allow truncation",
+			args: args{
+				limits:      MetadataLimits{MaxBytes: 4},
+				policy:      MetadataLimitPolicyError,
+				annotations: map[string]string{"a": "1", "b": "2", "c": "3"},
+			},
+			want: want{annotations: map[string]string{"a": "1", "b": "2", "c": "3"}, err: errors.Errorf(errMetadataBytesFmt, "annotations", 6, 4)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := claim.New()
+			local.SetLabels(tc.args.local)
+			local.SetAnnotations(tc.args.annotations)
+			remote := claim.New()
+			remote.SetLabels(tc.args.remote)
+			remote.SetAnnotations(tc.args.annotations)
+			err := NewMetadataLimiter(tc.args.limits, tc.args.policy, logging.NewNopLogger()).Configure(context.Background(), local, remote)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nml.Configure(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.labels, remote.GetLabels()); diff != "" {
+				t.Errorf("\nReason: %s\nLabels: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.annotations, remote.GetAnnotations()); diff != "" {
+				t.Errorf("\nReason: %s\nAnnotations: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+type countingLogger struct {
+	logging.Logger
+	infos int
+}
+
+func (l *countingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.infos++
+}
+
+func TestMetadataLimiterLogging(t *testing.T) {
+	log := &countingLogger{Logger: logging.NewNopLogger()}
+	ml := NewMetadataLimiter(MetadataLimits{MaxLabels: 1}, MetadataLimitPolicyTruncate, log)
+	configure := func(labels map[string]string) {
+		local := claim.New()
+		local.SetName("cool")
+		local.SetLabels(labels)
+		remote := claim.New()
+		remote.SetName("cool")
+		remote.SetLabels(labels)
+		if err := ml.Configure(context.Background(), local, remote); err != nil {
+			t.Fatalf("ml.Configure(...): %s", err)
+		}
+	}
+	steps := []struct {
+		reason string
+		labels map[string]string
+		infos  int
+	}{
+		{reason: "The first truncation should be logged", labels: map[string]string{"a": "1", "b": "2"}, infos: 1},
+		{reason: "The same truncation should not be logged again", labels: map[string]string{"a": "1", "b": "2"}, infos: 1},
+		{reason: "A truncation that drops other keys should be logged", labels: map[string]string{"a": "1", "c": "3"}, infos: 2},
+		{reason: "Nothing should be logged once nothing is dropped", labels: map[string]string{"a": "1"}, infos: 2},
+		{reason: "A truncation that starts again should be logged", labels: map[string]string{"a": "1", "c": "3"}, infos: 3},
+	}
+	for i, s := range steps {
+		configure(s.labels)
+		if diff := cmp.Diff(s.infos, log.infos); diff != "" {
+			t.Errorf("\nReason: %s\nStep %d: -want logged, +got logged:\n%s", s.reason, i, diff)
+		}
+	}
+}
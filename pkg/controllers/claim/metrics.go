@@ -0,0 +1,226 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+
+	agentv1alpha1 "github.com/crossplane/agent/apis/agent/v1alpha1"
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+var unsyncedClaims = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "crossplane_agent",
+	Name:      "unsynced_claims",
+	Help:      "Number of claims whose AgentSynced condition is either false or missing.",
+})
+
+var (
+	connectionSecretKeys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "crossplane_agent",
+		Name:      "connection_secret_keys",
+		Help:      "Number of keys of the last propagated connection secret of a claim.",
+	}, secretMetricLabels)
+	connectionSecretBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "crossplane_agent",
+		Name:      "connection_secret_bytes",
+		Help:      "Total size of the values of the last propagated connection secret of a claim.",
+	}, secretMetricLabels)
+)
+
+// The secret label is the name of the secret in the claim, since a claim may
+// have a connection secret for both writeConnectionSecretToRef and
+// publishConnectionDetailsTo.
+var secretMetricLabels = []string{"kind", "namespace", "name", "secret"}
+
+var fieldPolicyWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "crossplane_agent",
+	Name:      "field_policy_writes_total",
+	Help:      "Number of times the field policy of a field made the agent write a changed value in its direction.",
+}, []string{"path", "direction"})
+
+func init() {
+	metrics.Registry.MustRegister(unsyncedClaims, connectionSecretKeys, connectionSecretBytes, fieldPolicyWrites)
+}
+
+var defaultSyncTracker = NewSyncTracker(unsyncedClaims)
+
+// DefaultSyncTracker returns the SyncTracker the Reconcilers use unless they
+// are given another one.
+func DefaultSyncTracker() *SyncTracker {
+	return defaultSyncTracker
+}
+
+var defaultSecretMetrics = NewSecretMetrics(connectionSecretKeys, connectionSecretBytes)
+
+// NewSecretMetrics returns a new *SecretMetrics that reports the number of keys
+// and the total size of the connection secrets to the given gauges.
+func NewSecretMetrics(keys, bytes *prometheus.GaugeVec) *SecretMetrics {
+	return &SecretMetrics{keys: keys, bytes: bytes, observed: map[string]map[string]bool{}}
+}
+
+// SecretMetrics reports the shape of the propagated connection secrets, e.g. to
+// detect a secret that suddenly lost keys.
+type SecretMetrics struct {
+	keys  *prometheus.GaugeVec
+	bytes *prometheus.GaugeVec
+
+	// observed records the names of the secrets reported for each local
+	// object, including the ones it no longer refers to.
+	mu       sync.Mutex
+	observed map[string]map[string]bool
+}
+
+// Observe records the supplied connection secret that was propagated for the
+// secret with the given name of the supplied local object.
+func (m *SecretMetrics) Observe(local *claim.Unstructured, name string, s *corev1.Secret) {
+	size := 0
+	for _, v := range s.Data {
+		size += len(v)
+	}
+	l := prometheus.Labels{"kind": local.GetKind(), "namespace": local.GetNamespace(), "name": local.GetName(), "secret": name}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := secretMetricsKey(local)
+	if m.observed[key] == nil {
+		m.observed[key] = map[string]bool{}
+	}
+	m.observed[key][name] = true
+	m.keys.With(l).Set(float64(len(s.Data)))
+	m.bytes.With(l).Set(float64(size))
+}
+
+// Forget stops reporting all connection secrets that were reported for the
+// supplied local object, i.e. when it's deleted, including the ones it no
+// longer refers to.
+func (m *SecretMetrics) Forget(local *claim.Unstructured) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := secretMetricsKey(local)
+	for n := range m.observed[key] {
+		l := prometheus.Labels{"kind": local.GetKind(), "namespace": local.GetNamespace(), "name": local.GetName(), "secret": n}
+		m.keys.Delete(l)
+		m.bytes.Delete(l)
+	}
+	delete(m.observed, key)
+}
+
+func secretMetricsKey(local *claim.Unstructured) string {
+	return local.GetKind() + "/" + local.GetNamespace() + "/" + local.GetName()
+}
+
+var defaultPolicyMetrics = NewPolicyMetrics(fieldPolicyWrites)
+
+// NewPolicyMetrics returns a new *PolicyMetrics that counts the writes driven
+// by the field policies with the given counter.
+func NewPolicyMetrics(writes *prometheus.CounterVec) *PolicyMetrics {
+	return &PolicyMetrics{writes: writes}
+}
+
+// PolicyMetrics counts how often the FieldPolicies actually change a value,
+// by field path and Direction, e.g. to find the policies that keep overriding
+// changes made in the other cluster.
+type PolicyMetrics struct {
+	writes *prometheus.CounterVec
+}
+
+// Observe records that the field at the given path was written with a changed
+// value in the given direction.
+func (m *PolicyMetrics) Observe(path string, d Direction) {
+	m.writes.With(prometheus.Labels{"path": path, "direction": string(d)}).Inc()
+}
+
+// NewSyncTracker returns a new *SyncTracker that reports the number of unsynced
+// claims to the given gauge.
+func NewSyncTracker(g prometheus.Gauge) *SyncTracker {
+	return &SyncTracker{gauge: g, unsynced: map[string]bool{}, conditions: map[string]v1alpha1.Condition{}}
+}
+
+// SyncTracker keeps track of the claims that are not synced. It is event-driven;
+// the count is updated every time a claim is reconciled rather than by listing
+// the claims periodically. A claim that has never been reconciled is not
+// counted, but all claims are reconciled shortly after the agent starts.
+type SyncTracker struct {
+	gauge prometheus.Gauge
+
+	mu         sync.Mutex
+	unsynced   map[string]bool
+	conditions map[string]v1alpha1.Condition
+}
+
+// Observe records whether the claim with given key is synced according to its
+// AgentSynced condition. A missing condition means the claim is not synced.
+func (t *SyncTracker) Observe(key string, c v1alpha1.Condition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conditions[key] = c
+	if c.Type == resource.TypeAgentSync && c.Status == corev1.ConditionTrue {
+		delete(t.unsynced, key)
+	} else {
+		t.unsynced[key] = true
+	}
+	t.gauge.Set(float64(len(t.unsynced)))
+}
+
+// Forget stops tracking the claim with given key, i.e. when it's deleted.
+func (t *SyncTracker) Forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.unsynced, key)
+	delete(t.conditions, key)
+	t.gauge.Set(float64(len(t.unsynced)))
+}
+
+// Count returns the number of unsynced claims.
+func (t *SyncTracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.unsynced)
+}
+
+// Counts returns the numbers of claims by their last observed sync state.
+// Claims that are synced but diverged are counted as synced, and the ones the
+// agent deliberately doesn't sync for now are counted as paused.
+func (t *SyncTracker) Counts() agentv1alpha1.ClaimCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := agentv1alpha1.ClaimCounts{Total: len(t.conditions)}
+	for _, c := range t.conditions {
+		switch {
+		case c.Type != resource.TypeAgentSync:
+			counts.Unknown++
+		case c.Status == corev1.ConditionTrue:
+			counts.Synced++
+		case c.Reason == resource.ReasonAgentSyncFilteredOut,
+			c.Reason == resource.ReasonAgentSyncWaiting,
+			c.Reason == resource.ReasonAgentSyncMaxRetriesExceeded,
+			c.Reason == resource.ReasonAgentSyncDeletionPending,
+			c.Reason == resource.ReasonAgentSyncMaintenance:
+			counts.Paused++
+		default:
+			counts.Failed++
+		}
+	}
+	return counts
+}
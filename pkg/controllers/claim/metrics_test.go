@@ -0,0 +1,352 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	agentv1alpha1 "github.com/crossplane/agent/apis/agent/v1alpha1"
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func TestSyncTracker(t *testing.T) {
+	type observation struct {
+		key    string
+		c      v1alpha1.Condition
+		forget bool
+	}
+	cases := map[string]struct {
+		reason       string
+		observations []observation
+		want         int
+	}{
+		"AllSynced": {
+			reason: "Claims with successful sync condition should not be counted",
+			observations: []observation{
+				{key: "a", c: resource.AgentSyncSuccess()},
+				{key: "b", c: resource.AgentSyncSuccess()},
+			},
+			want: 0,
+		},
+		"FailedAndMissing": {
+			reason: "Claims with failed or missing sync condition should be counted",
+			observations: []observation{
+				{key: "a", c: resource.AgentSyncError(errBoom)},
+				{key: "b", c: v1alpha1.Condition{}},
+				{key: "c", c: resource.AgentSyncSuccess()},
+			},
+			want: 2,
+		},
+		"Recovered": {
+			reason: "Claims that got synced after a failure should not be counted",
+			observations: []observation{
+				{key: "a", c: resource.AgentSyncError(errBoom)},
+				{key: "a", c: resource.AgentSyncSuccess()},
+			},
+			want: 0,
+		},
+		"Forgotten": {
+			reason: "Claims that are deleted should not be counted",
+			observations: []observation{
+				{key: "a", c: resource.AgentSyncError(errBoom)},
+				{key: "b", c: resource.AgentSyncError(errBoom)},
+				{key: "a", forget: true},
+			},
+			want: 1,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"})
+			tr := NewSyncTracker(g)
+			for _, o := range tc.observations {
+				if o.forget {
+					tr.Forget(o.key)
+					continue
+				}
+				tr.Observe(o.key, o.c)
+			}
+			if diff := cmp.Diff(tc.want, tr.Count()); diff != "" {
+				t.Errorf("\nReason: %s\ntr.Count(): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(float64(tc.want), testutil.ToFloat64(g)); diff != "" {
+				t.Errorf("\nReason: %s\ngauge: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSyncTrackerCounts(t *testing.T) {
+	type observation struct {
+		key    string
+		c      v1alpha1.Condition
+		forget bool
+	}
+	cases := map[string]struct {
+		reason       string
+		observations []observation
+		want         agentv1alpha1.ClaimCounts
+	}{
+		"Empty": {
+			reason: "Nothing should be counted if no claim was observed",
+		},
+		"Aggregated": {
+			reason: "Claims should be counted by their last observed sync state",
+			observations: []observation{
+				{key: "synced", c: resource.AgentSyncSuccess()},
+				{key: "diverged", c: resource.AgentSyncDiverged()},
+				{key: "failed", c: resource.AgentSyncError(errBoom)},
+				{key: "filtered", c: resource.AgentSyncFilteredOut()},
+				{key: "waiting", c: resource.AgentSyncWaiting()},
+				{key: "exceeded", c: resource.AgentSyncMaxRetriesExceeded()},
+				{key: "maintenance", c: resource.AgentSyncMaintenance()},
+				{key: "unknown", c: v1alpha1.Condition{}},
+			},
+			want: agentv1alpha1.ClaimCounts{Total: 8, Synced: 2, Failed: 1, Paused: 4, Unknown: 1},
+		},
+		"Latest": {
+			reason: "Only the last observed sync state of a claim should be counted",
+			observations: []observation{
+				{key: "a", c: resource.AgentSyncError(errBoom)},
+				{key: "a", c: resource.AgentSyncSuccess()},
+			},
+			want: agentv1alpha1.ClaimCounts{Total: 1, Synced: 1},
+		},
+		"Forgotten": {
+			reason: "Claims that are deleted should not be counted",
+			observations: []observation{
+				{key: "a", c: resource.AgentSyncError(errBoom)},
+				{key: "b", c: resource.AgentSyncSuccess()},
+				{key: "a", forget: true},
+			},
+			want: agentv1alpha1.ClaimCounts{Total: 1, Synced: 1},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tr := NewSyncTracker(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}))
+			for _, o := range tc.observations {
+				if o.forget {
+					tr.Forget(o.key)
+					continue
+				}
+				tr.Observe(o.key, o.c)
+			}
+			if diff := cmp.Diff(tc.want, tr.Counts()); diff != "" {
+				t.Errorf("\nReason: %s\ntr.Counts(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretMetrics(t *testing.T) {
+	type want struct {
+		keys  float64
+		bytes float64
+	}
+	cases := map[string]struct {
+		reason string
+		data   []map[string][]byte
+		forget bool
+		want   want
+	}{
+		"Propagated": {
+			reason: "The number of keys and the total size of the propagated secret should be reported",
+			data:   []map[string][]byte{{"username": []byte("admin"), "password": []byte("hunter2")}},
+			want:   want{keys: 2, bytes: 12},
+		},
+		"LostKeys": {
+			reason: "The metrics should reflect the last propagated secret, e.g. one that lost keys",
+			data: []map[string][]byte{
+				{"username": []byte("admin"), "password": []byte("hunter2")},
+				{"username": []byte("admin")},
+			},
+			want: want{keys: 1, bytes: 5},
+		},
+		"Forgotten": {
+			reason: "The metrics of a deleted claim should not be reported",
+			data:   []map[string][]byte{{"username": []byte("admin")}},
+			forget: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			keys := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "keys"}, secretMetricLabels)
+			bytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "bytes"}, secretMetricLabels)
+			m := NewSecretMetrics(keys, bytes)
+			local := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+			local.SetGroupVersionKind(scopedGVK)
+			for _, data := range tc.data {
+				data := data
+				p := NewConnectionSecretPropagator(
+					runtimeresource.ClientApplicator{Applicator: runtimeresource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...runtimeresource.ApplyOption) error {
+						return nil
+					})},
+					runtimeresource.ClientApplicator{Client: &test.MockClient{MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+						obj.(*corev1.Secret).Data = data
+						return nil
+					})}},
+					WithConnectionSecretMetrics(m),
+				)
+				if err := p.Propagate(context.Background(), local, &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()}); err != nil {
+					t.Fatalf("\nReason: %s\np.Propagate(...): %s", tc.reason, err)
+				}
+			}
+			if tc.forget {
+				m.Forget(local)
+			}
+			l := prometheus.Labels{"kind": scopedGVK.Kind, "namespace": "local-namespace", "name": "local-name", "secret": "local-s-name"}
+			if diff := cmp.Diff(tc.want.keys, testutil.ToFloat64(keys.With(l))); diff != "" {
+				t.Errorf("\nReason: %s\nkeys: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.bytes, testutil.ToFloat64(bytes.With(l))); diff != "" {
+				t.Errorf("\nReason: %s\nbytes: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// countSeries returns the number of series the supplied collector reports.
+func countSeries(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 16)
+	c.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
+func TestSecretMetricsForgetUnreferenced(t *testing.T) {
+	keys := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "keys"}, secretMetricLabels)
+	bytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "bytes"}, secretMetricLabels)
+	m := NewSecretMetrics(keys, bytes)
+	local := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+	local.SetGroupVersionKind(scopedGVK)
+	other := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+	other.SetGroupVersionKind(scopedGVK)
+	other.SetName("other-name")
+	s := &corev1.Secret{Data: map[string][]byte{"username": []byte("admin")}}
+	m.Observe(local, "renamed-s-name", s)
+	m.Observe(local, "local-s-name", s)
+	m.Observe(other, "local-s-name", s)
+	m.Forget(local)
+	if diff := cmp.Diff(1, countSeries(keys)); diff != "" {
+		t.Errorf("\nReason: %s\nkeys: -want series, +got series:\n%s", "All secrets reported for a deleted claim should be forgotten, including the ones it no longer refers to", diff)
+	}
+	if diff := cmp.Diff(1, countSeries(bytes)); diff != "" {
+		t.Errorf("\nReason: %s\nbytes: -want series, +got series:\n%s", "All secrets reported for a deleted claim should be forgotten, including the ones it no longer refers to", diff)
+	}
+}
+
+func TestPolicyMetrics(t *testing.T) {
+	newClaim := func(spec map[string]interface{}) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetName("cool-claim")
+		c.Object["spec"] = spec
+		return c
+	}
+	type args struct {
+		path   string
+		local  map[string]interface{}
+		remote map[string]interface{}
+	}
+	type want struct {
+		push float64
+		pull float64
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"LocalWins": {
+			reason: "A local value that overrides a different remote one should be counted as pushed",
+			args: args{
+				path:   "spec.compositeDeletePolicy",
+				local:  map[string]interface{}{"compositeDeletePolicy": "Foreground"},
+				remote: map[string]interface{}{"compositeDeletePolicy": "Background"},
+			},
+			want: want{push: 1},
+		},
+		"LocalRemoved": {
+			reason: "A local field that is removed from the remote instance should be counted as pushed",
+			args: args{
+				path:   "spec.resourceSelector",
+				local:  map[string]interface{}{},
+				remote: map[string]interface{}{"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"a": "b"}}},
+			},
+			want: want{push: 1},
+		},
+		"LocalUnchanged": {
+			reason: "A local value that the remote instance already has should not be counted",
+			args: args{
+				path:   "spec.compositeDeletePolicy",
+				local:  map[string]interface{}{"compositeDeletePolicy": "Foreground"},
+				remote: map[string]interface{}{"compositeDeletePolicy": "Foreground"},
+			},
+		},
+		"RemoteWins": {
+			reason: "A remote value that is late-initialized to the local instance should be counted as pulled",
+			args: args{
+				path:   "spec.compositionRef",
+				local:  map[string]interface{}{},
+				remote: map[string]interface{}{"compositionRef": map[string]interface{}{"name": "cool"}},
+			},
+			want: want{pull: 1},
+		},
+		"RemoteUnchanged": {
+			reason: "A remote value that the local instance already has should not be counted",
+			args: args{
+				path:   "spec.resourceRef",
+				local:  map[string]interface{}{"resourceRef": map[string]interface{}{"name": "cool"}},
+				remote: map[string]interface{}{"resourceRef": map[string]interface{}{"name": "cool"}},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			writes := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "writes"}, []string{"path", "direction"})
+			m := NewPolicyMetrics(writes)
+			local, remote := newClaim(tc.args.local), newClaim(tc.args.remote)
+
+			desired := &claim.Unstructured{Unstructured: *remote.DeepCopy()}
+			if err := NewDefaultConfigurator(DefaultFieldPolicies(), WithConfiguratorPolicyMetrics(m)).Configure(context.Background(), local, desired); err != nil {
+				t.Fatalf("\nReason: %s\nc.Configure(...): %s", tc.reason, err)
+			}
+			li := NewLateInitializer(&test.MockClient{MockUpdate: test.NewMockUpdateFn(nil)}, DefaultFieldPolicies(), WithLateInitPolicyMetrics(m))
+			if err := li.Propagate(context.Background(), local, remote); err != nil {
+				t.Fatalf("\nReason: %s\nli.Propagate(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.push, testutil.ToFloat64(writes.With(prometheus.Labels{"path": tc.args.path, "direction": string(DirectionPush)}))); diff != "" {
+				t.Errorf("\nReason: %s\npushed writes: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pull, testutil.ToFloat64(writes.With(prometheus.Labels{"path": tc.args.path, "direction": string(DirectionPull)}))); diff != "" {
+				t.Errorf("\nReason: %s\npulled writes: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
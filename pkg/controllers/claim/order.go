@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	errUnknownStepFmt   = "unknown propagator step %q"
+	errDuplicateStepFmt = "propagator step %q is given more than once"
+)
+
+// A PropagatorStep is one of the Propagators of the default PropagatorChain.
+type PropagatorStep string
+
+// Propagator steps.
+const (
+	// PropagatorStepLateInit is the LateInitializer.
+	PropagatorStepLateInit PropagatorStep = "LateInit"
+
+	// PropagatorStepStatus is the StatusPropagator.
+	PropagatorStepStatus PropagatorStep = "Status"
+
+	// PropagatorStepConnectionSecret is the ConnectionSecretPropagator.
+	PropagatorStepConnectionSecret PropagatorStep = "ConnectionSecret"
+)
+
+// DefaultPropagatorOrder returns the order the steps of the default
+// PropagatorChain run in unless it's configured otherwise.
+//
+// The chain stops at the first step that fails, so the order determines what
+// a local instance sees when a step fails. The default order propagates the
+// status as soon as possible. SecretFirstPropagatorOrder is recommended when
+// the consumers of the local instances use the connection secret as soon as
+// they see them Ready. The LateInit step should come first in either case,
+// since it updates the local instance, which the other steps write to as well.
+func DefaultPropagatorOrder() []PropagatorStep {
+	return []PropagatorStep{PropagatorStepLateInit, PropagatorStepStatus, PropagatorStepConnectionSecret}
+}
+
+// SecretFirstPropagatorOrder returns the order in which the connection secret
+// is propagated before the status, so that a local instance is never reported
+// Ready before its connection secret is there.
+func SecretFirstPropagatorOrder() []PropagatorStep {
+	return []PropagatorStep{PropagatorStepLateInit, PropagatorStepConnectionSecret, PropagatorStepStatus}
+}
+
+// ParsePropagatorOrder parses the supplied names of propagator steps, e.g.
+// LateInit, ConnectionSecret and Status. The steps that are not given run after
+// the given ones in their default order.
+func ParsePropagatorOrder(names ...string) ([]PropagatorStep, error) {
+	known := map[PropagatorStep]bool{}
+	for _, s := range DefaultPropagatorOrder() {
+		known[s] = true
+	}
+	seen := map[PropagatorStep]bool{}
+	steps := make([]PropagatorStep, 0, len(known))
+	for _, n := range names {
+		s := PropagatorStep(n)
+		if !known[s] {
+			return nil, errors.Errorf(errUnknownStepFmt, n)
+		}
+		if seen[s] {
+			return nil, errors.Errorf(errDuplicateStepFmt, n)
+		}
+		seen[s] = true
+		steps = append(steps, s)
+	}
+	for _, s := range DefaultPropagatorOrder() {
+		if !seen[s] {
+			steps = append(steps, s)
+		}
+	}
+	return steps, nil
+}
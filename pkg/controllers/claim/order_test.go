@@ -0,0 +1,154 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParsePropagatorOrder(t *testing.T) {
+	type want struct {
+		steps []PropagatorStep
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		names  []string
+		want
+	}{
+		"Default": {
+			reason: "The default order should be used if no step is given",
+			want:   want{steps: DefaultPropagatorOrder()},
+		},
+		"Partial": {
+			reason: "The steps that are not given should run after the given ones in their default order",
+			names:  []string{"ConnectionSecret"},
+			want:   want{steps: []PropagatorStep{PropagatorStepConnectionSecret, PropagatorStepLateInit, PropagatorStepStatus}},
+		},
+		"Full": {
+			reason: "The steps should run in the given order",
+			names:  []string{"LateInit", "ConnectionSecret", "Status"},
+			want:   want{steps: SecretFirstPropagatorOrder()},
+		},
+		"Unknown": {
+			reason: "An unknown step should be rejected",
+			names:  []string{"Labels"},
+			want:   want{err: errors.Errorf(errUnknownStepFmt, "Labels")},
+		},
+		"Duplicate": {
+			reason: "A step that is given twice should be rejected",
+			names:  []string{"Status", "Status"},
+			want:   want{err: errors.Errorf(errDuplicateStepFmt, "Status")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParsePropagatorOrder(tc.names...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nParsePropagatorOrder(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.steps, got); diff != "" {
+				t.Errorf("\nReason: %s\nParsePropagatorOrder(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcilePropagatorOrder(t *testing.T) {
+	// The remote instance is Ready but its connection secret cannot be read.
+	var statusUpdated bool
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.Object["spec"] = map[string]interface{}{"writeConnectionSecretToRef": map[string]interface{}{"name": "conn"}}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				l := &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+				statusUpdated = l.GetCondition("Ready").Status == corev1.ConditionTrue
+				return nil
+			},
+		},
+	}
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return errBoom
+			}
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetCreationTimestamp(now)
+			r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+			r.Object["spec"] = map[string]interface{}{"writeConnectionSecretToRef": map[string]interface{}{"name": "conn"}}
+			r.Object["status"] = map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}}}
+			r.DeepCopyInto(u)
+			return nil
+		},
+		MockPatch: test.NewMockPatchFn(nil),
+	}
+	cases := map[string]struct {
+		reason string
+		order  []PropagatorStep
+		want   bool
+	}{
+		"Default": {
+			reason: "The status should be propagated before the connection secret fails to be",
+			order:  DefaultPropagatorOrder(),
+			want:   true,
+		},
+		"SecretFirst": {
+			reason: "The status should not be propagated if the connection secret that is propagated before it fails to be",
+			order:  SecretFirstPropagatorOrder(),
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			statusUpdated = false
+			r := NewReconciler(m, remote, scopedGVK,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithLateInitialization(false),
+				WithPropagatorOrder(tc.order...),
+			)
+			if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, statusUpdated); diff != "" {
+				t.Errorf("\nReason: %s\nReady: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
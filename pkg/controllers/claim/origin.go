@@ -0,0 +1,58 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+// An Origin identifies the local cluster of the agent, so that the objects it
+// writes can be grouped by where they come from, e.g. in the dashboards of a
+// remote cluster that is shared by many local clusters.
+type Origin struct {
+	// Cluster is the name of the local cluster.
+	Cluster string
+
+	// Region is the region of the local cluster.
+	Region string
+
+	// Environment is the environment of the local cluster, e.g. staging.
+	Environment string
+}
+
+// Labels returns the well-known origin labels for the fields of the Origin
+// that are set.
+func (o Origin) Labels() map[string]string {
+	l := map[string]string{}
+	for k, v := range map[string]string{
+		LabelKeyOriginCluster:     o.Cluster,
+		LabelKeyOriginRegion:      o.Region,
+		LabelKeyOriginEnvironment: o.Environment,
+	} {
+		if v != "" {
+			l[k] = v
+		}
+	}
+	return l
+}
+
+// originLabels returns the origin labels among the supplied ones.
+func originLabels(labels map[string]string) map[string]string {
+	l := map[string]string{}
+	for _, k := range []string{LabelKeyOriginCluster, LabelKeyOriginRegion, LabelKeyOriginEnvironment} {
+		if v, ok := labels[k]; ok {
+			l[k] = v
+		}
+	}
+	return l
+}
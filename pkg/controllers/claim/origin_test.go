@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestOriginLabels(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		origin Origin
+		want   map[string]string
+	}{
+		"Full": {
+			reason: "All fields of the origin should be labels",
+			origin: Origin{Cluster: "cool-cluster", Region: "us-east-1", Environment: "production"},
+			want: map[string]string{
+				LabelKeyOriginCluster:     "cool-cluster",
+				LabelKeyOriginRegion:      "us-east-1",
+				LabelKeyOriginEnvironment: "production",
+			},
+		},
+		"Partial": {
+			reason: "The fields of the origin that are not set should not be labels",
+			origin: Origin{Region: "us-east-1"},
+			want:   map[string]string{LabelKeyOriginRegion: "us-east-1"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.origin.Labels()); diff != "" {
+				t.Errorf("\nReason: %s\no.Labels(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileOriginLabels(t *testing.T) {
+	origin := Origin{Cluster: "cool-cluster", Region: "us-east-1"}
+	var written *claim.Unstructured
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.SetLabels(map[string]string{"team": "cool"})
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	remote := &test.MockClient{
+		MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			written = &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured).DeepCopy()}
+			return nil
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithOriginLabels(origin),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	want := map[string]string{
+		"team":                "cool",
+		LabelKeyOriginCluster: "cool-cluster",
+		LabelKeyOriginRegion:  "us-east-1",
+	}
+	if diff := cmp.Diff(want, written.GetLabels()); diff != "" {
+		t.Errorf("\nReason: %s\nremote labels: -want, +got:\n%s", "The remote claim should have the origin labels besides the propagated ones", diff)
+	}
+}
+
+func TestConnectionSecretOriginLabels(t *testing.T) {
+	remote := runtimeresource.ClientApplicator{Client: &test.MockClient{
+		MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+			obj.(*corev1.Secret).SetName("remote-s-name")
+			obj.(*corev1.Secret).Data = map[string][]byte{"password": []byte("cool")}
+			return nil
+		}),
+	}}
+	var got *corev1.Secret
+	local := runtimeresource.ClientApplicator{
+		Applicator: runtimeresource.ApplyFn(func(_ context.Context, obj runtime.Object, _ ...runtimeresource.ApplyOption) error {
+			got = obj.(*corev1.Secret)
+			return nil
+		}),
+	}
+	p := NewConnectionSecretPropagator(local, remote, WithSecretOriginLabels(Origin{Cluster: "cool-cluster", Environment: "production"}))
+	lc := &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}
+	if err := p.Propagate(context.Background(), lc, &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()}); err != nil {
+		t.Fatalf("p.Propagate(...): unexpected error: %s", err)
+	}
+	want := map[string]string{
+		LabelKeyManagedBy:         LabelValueManagedBy,
+		LabelKeyOwnerUID:          string(lc.GetUID()),
+		LabelKeyOriginCluster:     "cool-cluster",
+		LabelKeyOriginEnvironment: "production",
+	}
+	if diff := cmp.Diff(want, got.GetLabels()); diff != "" {
+		t.Errorf("\nReason: %s\nlocal secret labels: -want, +got:\n%s", "The local connection secret should have the origin labels", diff)
+	}
+}
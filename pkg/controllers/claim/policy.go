@@ -0,0 +1,317 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+)
+
+// A FieldPolicy determines which cluster owns a field of the claim and in
+// which direction its value is propagated.
+type FieldPolicy string
+
+// Field policies.
+const (
+	// FieldPolicyLocal fields are owned by the local instance. They are pushed
+	// to the remote instance and never late-initialized.
+	FieldPolicyLocal FieldPolicy = "Local"
+
+	// FieldPolicyRemote fields are owned by the remote instance. They are never
+	// pushed to the remote instance and are always reflected to the local one.
+	FieldPolicyRemote FieldPolicy = "Remote"
+
+	// FieldPolicyLateInit fields are pushed to the remote instance if they are
+	// set in the local instance. Otherwise, the value in the remote instance is
+	// preserved and late-initialized to the local instance.
+	FieldPolicyLateInit FieldPolicy = "LateInit"
+
+	// FieldPolicyMerge fields are arrays that both instances add entries to.
+	// The entries of the local instance are pushed to the remote instance, and
+	// the entries that were added in the remote cluster are preserved there
+	// and late-initialized to the local instance. The entries that the agent
+	// pushed before according to AnnotationKeyManagedEntries are removed from
+	// the remote instance once the local instance no longer has them.
+	FieldPolicyMerge FieldPolicy = "Merge"
+)
+
+// FieldPolicies maps field paths, such as "spec.compositionRef", to the policy
+// that should be applied to them. Fields that do not have a policy are treated
+// as if they had FieldPolicyLocal.
+//
+// Each field is handled by exactly one direction in a reconciliation so that
+// pushing the local instance and late-initializing it never fight over a
+// field. The merged fields are written in both directions, but with the same
+// value. See Direction for the rule. The fields are handled in the order of
+// Paths, so that the policy of a nested field always takes precedence over the
+// policy of the field that contains it.
+type FieldPolicies map[string]FieldPolicy
+
+// A Direction is the direction a field is propagated in.
+type Direction string
+
+// Directions.
+const (
+	// DirectionPush fields are written to the remote instance, or removed from
+	// it, with their local values. They are never late-initialized.
+	DirectionPush Direction = "Push"
+
+	// DirectionPull fields keep their remote values in the remote instance and
+	// are written to the local instance.
+	DirectionPull Direction = "Pull"
+
+	// DirectionMerge fields are written to both instances with the entries of
+	// both of them.
+	DirectionMerge Direction = "Merge"
+)
+
+// Direction returns the direction the field at given path is propagated in.
+// Fields with FieldPolicyLocal are pushed and fields with FieldPolicyRemote are
+// pulled. Fields with FieldPolicyLateInit are pushed if they are set in the
+// local instance, and pulled otherwise; a local value always takes precedence
+// over late-initialization. Fields with FieldPolicyMerge are merged.
+func (fp FieldPolicies) Direction(path string, local *fieldpath.Paved) Direction {
+	switch fp[path] {
+	case FieldPolicyRemote:
+		return DirectionPull
+	case FieldPolicyMerge:
+		return DirectionMerge
+	case FieldPolicyLateInit:
+		if _, ok := getValue(local, path); ok {
+			return DirectionPush
+		}
+		return DirectionPull
+	default:
+		return DirectionPush
+	}
+}
+
+// Paths returns the paths that have a policy, ordered so that the paths that
+// contain others come before them. Paths of the same depth are sorted.
+func (fp FieldPolicies) Paths() []string {
+	paths := make([]string, 0, len(fp))
+	depths := make(map[string]int, len(fp))
+	for path := range fp {
+		paths = append(paths, path)
+		segments, err := fieldpath.Parse(path)
+		if err != nil {
+			continue
+		}
+		depths[path] = len(segments)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if depths[paths[i]] != depths[paths[j]] {
+			return depths[paths[i]] < depths[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	return paths
+}
+
+// DefaultFieldPolicies returns the FieldPolicies for the fields that are known
+// to be set by Crossplane in the remote cluster. The resourceRefs of a bound
+// composite are managed solely by Crossplane, so they're only reflected to the
+// local instance as read-only information. The compositeDeletePolicy, which
+// determines how the composite is deleted along with the claim, always
+// reflects the intent of the local instance, even if it's defaulted remotely.
+// Likewise, the resourceSelector that selects the composite is pushed as is,
+// while the resourceRef it's resolved to in the remote cluster is reflected to
+// the local instance. The environmentConfigRefs may be both set locally and
+// resolved remotely, so they're merged.
+func DefaultFieldPolicies() FieldPolicies {
+	return FieldPolicies{
+		"spec.compositeDeletePolicy":      FieldPolicyLocal,
+		"spec.resourceSelector":           FieldPolicyLocal,
+		"spec.resourceRef":                FieldPolicyRemote,
+		"spec.compositionSelector":        FieldPolicyLateInit,
+		"spec.compositionRef":             FieldPolicyLateInit,
+		"spec.writeConnectionSecretToRef": FieldPolicyLateInit,
+		"spec.publishConnectionDetailsTo": FieldPolicyLateInit,
+		"spec.environmentConfigRefs":      FieldPolicyMerge,
+		"spec.resourceRefs":               FieldPolicyRemote,
+	}
+}
+
+// WithRemoteDefaults returns a copy of the FieldPolicies in which the given
+// fields, which are defaulted by the admission webhooks of the remote cluster,
+// have FieldPolicyLateInit unless they already have a policy. Their remote
+// values are then preserved and late-initialized to the local instance instead
+// of being removed from the remote instance on every push, which would make
+// them flap between the defaulted value and nothing.
+func (fp FieldPolicies) WithRemoteDefaults(paths ...string) FieldPolicies {
+	out := make(FieldPolicies, len(fp)+len(paths))
+	for path, p := range fp {
+		out[path] = p
+	}
+	for _, path := range paths {
+		if _, ok := out[path]; !ok {
+			out[path] = FieldPolicyLateInit
+		}
+	}
+	return out
+}
+
+// WithRemoteAnnotations returns a copy of the FieldPolicies in which the
+// annotations with the given keys have FieldPolicyRemote unless they already
+// have a policy. Their values are then reflected from the remote instance to
+// the local one and never pushed.
+func (fp FieldPolicies) WithRemoteAnnotations(keys ...string) FieldPolicies {
+	paths := make([]string, len(keys))
+	for i, k := range keys {
+		paths[i] = AnnotationPath(k)
+	}
+	out := make(FieldPolicies, len(fp)+len(paths))
+	for path, p := range fp {
+		out[path] = p
+	}
+	for _, path := range paths {
+		if _, ok := out[path]; !ok {
+			out[path] = FieldPolicyRemote
+		}
+	}
+	return out
+}
+
+// AnnotationPath returns the field path of the annotation with the given key.
+func AnnotationPath(key string) string {
+	return "metadata.annotations[" + key + "]"
+}
+
+// ForPush returns the value of the field at given path that should be written
+// to the remote instance and whether there is such a value at all.
+func (fp FieldPolicies) ForPush(path string, local, remote *fieldpath.Paved) (interface{}, bool) {
+	switch fp.Direction(path, local) {
+	case DirectionPull:
+		return getValue(remote, path)
+	case DirectionMerge:
+		return mergedValue(local, remote, path)
+	default:
+		return getValue(local, path)
+	}
+}
+
+// ForPull returns the value of the field at given path that should be written
+// to the local instance and whether it should be written at all.
+func (fp FieldPolicies) ForPull(path string, local, remote *fieldpath.Paved) (interface{}, bool) {
+	switch fp.Direction(path, local) {
+	case DirectionPull:
+		return getValue(remote, path)
+	case DirectionMerge:
+		return mergedValue(local, remote, path)
+	default:
+		return nil, false
+	}
+}
+
+// mergedValue returns the entries of the array at given path of the local
+// instance followed by the ones that were added in the remote instance, i.e.
+// that only the remote instance has and that the agent didn't push according
+// to AnnotationKeyManagedEntries. The local value is returned as is if either
+// of them is not an array.
+func mergedValue(local, remote *fieldpath.Paved, path string) (interface{}, bool) {
+	lv, lok := getValue(local, path)
+	rv, rok := getValue(remote, path)
+	if !rok {
+		return lv, lok
+	}
+	ra, ok := rv.([]interface{})
+	if !ok {
+		if lok {
+			return lv, true
+		}
+		return rv, true
+	}
+	la, ok := lv.([]interface{})
+	if lok && !ok {
+		return lv, true
+	}
+	pushed := managedEntries(remote)[path]
+	for _, e := range ra {
+		if !containsValue(la, e) && !containsValue(pushed, e) {
+			la = append(la, e)
+		}
+	}
+	if !lok && len(la) == 0 {
+		return nil, false
+	}
+	return la, true
+}
+
+// managedEntries returns the entries of the merged arrays that the agent pushed
+// to the supplied remote instance by their path, according to its
+// AnnotationKeyManagedEntries.
+func managedEntries(remote *fieldpath.Paved) map[string][]interface{} {
+	v, err := remote.GetString(AnnotationPath(AnnotationKeyManagedEntries))
+	if err != nil {
+		return nil
+	}
+	entries := map[string][]interface{}{}
+	if err := json.Unmarshal([]byte(v), &entries); err != nil {
+		return nil
+	}
+	return entries
+}
+
+func containsValue(arr []interface{}, v interface{}) bool {
+	for _, e := range arr {
+		if jsonEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func getValue(p *fieldpath.Paved, path string) (interface{}, bool) {
+	v, err := p.GetValue(path)
+	if err != nil || v == nil {
+		return nil, false
+	}
+	return runtime.DeepCopyJSONValue(v), true
+}
+
+// deleteValue removes the field at given path if it exists.
+func deleteValue(p *fieldpath.Paved, path string) error {
+	segments, err := fieldpath.Parse(path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse path %q", path)
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	parent := p.UnstructuredContent()
+	if len(segments) > 1 {
+		v, err := p.GetValue(segments[:len(segments)-1].String())
+		if fieldpath.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		parent = m
+	}
+	delete(parent, segments[len(segments)-1].Field)
+	return nil
+}
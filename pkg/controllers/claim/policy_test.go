@@ -0,0 +1,345 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+)
+
+func TestFieldPolicies(t *testing.T) {
+	type args struct {
+		policy FieldPolicy
+		local  map[string]interface{}
+		remote map[string]interface{}
+	}
+	type want struct {
+		push       interface{}
+		pushExists bool
+		pull       interface{}
+		pullExists bool
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Local": {
+			reason: "Local fields should be pushed and never pulled",
+			args: args{
+				policy: FieldPolicyLocal,
+				local:  map[string]interface{}{"spec": map[string]interface{}{"f": "local"}},
+				remote: map[string]interface{}{"spec": map[string]interface{}{"f": "remote"}},
+			},
+			want: want{
+				push:       "local",
+				pushExists: true,
+			},
+		},
+		"Remote": {
+			reason: "Remote fields should keep the remote value and always be pulled",
+			args: args{
+				policy: FieldPolicyRemote,
+				local:  map[string]interface{}{"spec": map[string]interface{}{"f": "local"}},
+				remote: map[string]interface{}{"spec": map[string]interface{}{"f": "remote"}},
+			},
+			want: want{
+				push:       "remote",
+				pushExists: true,
+				pull:       "remote",
+				pullExists: true,
+			},
+		},
+		"RemoteMissing": {
+			reason: "Remote fields should not be pushed if remote does not have them",
+			args: args{
+				policy: FieldPolicyRemote,
+				local:  map[string]interface{}{"spec": map[string]interface{}{"f": "local"}},
+				remote: map[string]interface{}{},
+			},
+		},
+		"LateInitSetLocally": {
+			reason: "LateInit fields should be pushed and not pulled if they are set locally",
+			args: args{
+				policy: FieldPolicyLateInit,
+				local:  map[string]interface{}{"spec": map[string]interface{}{"f": "local"}},
+				remote: map[string]interface{}{"spec": map[string]interface{}{"f": "remote"}},
+			},
+			want: want{
+				push:       "local",
+				pushExists: true,
+			},
+		},
+		"LateInitNotSetLocally": {
+			reason: "LateInit fields should keep the remote value and be pulled if they are not set locally",
+			args: args{
+				policy: FieldPolicyLateInit,
+				local:  map[string]interface{}{},
+				remote: map[string]interface{}{"spec": map[string]interface{}{"f": "remote"}},
+			},
+			want: want{
+				push:       "remote",
+				pushExists: true,
+				pull:       "remote",
+				pullExists: true,
+			},
+		},
+		"Merge": {
+			reason: "Merge fields should be pushed and pulled with the local entries followed by the remote-only ones",
+			args: args{
+				policy: FieldPolicyMerge,
+				local:  map[string]interface{}{"spec": map[string]interface{}{"f": []interface{}{"a", "b"}}},
+				remote: map[string]interface{}{"spec": map[string]interface{}{"f": []interface{}{"c", "a"}}},
+			},
+			want: want{
+				push:       []interface{}{"a", "b", "c"},
+				pushExists: true,
+				pull:       []interface{}{"a", "b", "c"},
+				pullExists: true,
+			},
+		},
+		"MergeNotSetLocally": {
+			reason: "Merge fields should keep the remote entries and be pulled if they are not set locally",
+			args: args{
+				policy: FieldPolicyMerge,
+				local:  map[string]interface{}{},
+				remote: map[string]interface{}{"spec": map[string]interface{}{"f": []interface{}{"c"}}},
+			},
+			want: want{
+				push:       []interface{}{"c"},
+				pushExists: true,
+				pull:       []interface{}{"c"},
+				pullExists: true,
+			},
+		},
+		"MergeRemovedLocally": {
+			reason: "Merge fields should drop the entries the agent pushed once they're gone locally, and keep the ones added remotely",
+			args: args{
+				policy: FieldPolicyMerge,
+				local:  map[string]interface{}{"spec": map[string]interface{}{"f": []interface{}{"a"}}},
+				remote: map[string]interface{}{
+					"metadata": map[string]interface{}{"annotations": map[string]interface{}{AnnotationKeyManagedEntries: `{"spec.f":["a","b"]}`}},
+					"spec":     map[string]interface{}{"f": []interface{}{"a", "b", "c"}},
+				},
+			},
+			want: want{
+				push:       []interface{}{"a", "c"},
+				pushExists: true,
+				pull:       []interface{}{"a", "c"},
+				pullExists: true,
+			},
+		},
+		"MergeAllRemovedLocally": {
+			reason: "Merge fields should be removed if the local instance no longer has any of them and the remote has no others",
+			args: args{
+				policy: FieldPolicyMerge,
+				local:  map[string]interface{}{},
+				remote: map[string]interface{}{
+					"metadata": map[string]interface{}{"annotations": map[string]interface{}{AnnotationKeyManagedEntries: `{"spec.f":["a"]}`}},
+					"spec":     map[string]interface{}{"f": []interface{}{"a"}},
+				},
+			},
+		},
+		"MergeNotSetRemotely": {
+			reason: "Merge fields should be pushed with the local entries if the remote does not have them",
+			args: args{
+				policy: FieldPolicyMerge,
+				local:  map[string]interface{}{"spec": map[string]interface{}{"f": []interface{}{"a"}}},
+				remote: map[string]interface{}{},
+			},
+			want: want{
+				push:       []interface{}{"a"},
+				pushExists: true,
+				pull:       []interface{}{"a"},
+				pullExists: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fp := FieldPolicies{"spec.f": tc.args.policy}
+			lp, rp := fieldpath.Pave(tc.args.local), fieldpath.Pave(tc.args.remote)
+
+			push, ok := fp.ForPush("spec.f", lp, rp)
+			if diff := cmp.Diff(tc.want.push, push); diff != "" {
+				t.Errorf("\nReason: %s\nfp.ForPush(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pushExists, ok); diff != "" {
+				t.Errorf("\nReason: %s\nfp.ForPush(...): -want exists, +got exists:\n%s", tc.reason, diff)
+			}
+
+			pull, ok := fp.ForPull("spec.f", lp, rp)
+			if diff := cmp.Diff(tc.want.pull, pull); diff != "" {
+				t.Errorf("\nReason: %s\nfp.ForPull(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pullExists, ok); diff != "" {
+				t.Errorf("\nReason: %s\nfp.ForPull(...): -want exists, +got exists:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFieldPoliciesWithRemoteDefaults(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		fp     FieldPolicies
+		paths  []string
+		want   FieldPolicies
+	}{
+		"NoDefaults": {
+			reason: "The policies should be unchanged if no field is defaulted remotely",
+			fp:     FieldPolicies{"spec.a": FieldPolicyRemote},
+			want:   FieldPolicies{"spec.a": FieldPolicyRemote},
+		},
+		"Defaulted": {
+			reason: "The remotely defaulted fields should be late-initialized",
+			fp:     FieldPolicies{"spec.a": FieldPolicyRemote},
+			paths:  []string{"spec.b"},
+			want:   FieldPolicies{"spec.a": FieldPolicyRemote, "spec.b": FieldPolicyLateInit},
+		},
+		"ExplicitPolicyWins": {
+			reason: "The policy of a remotely defaulted field should be kept if it has one",
+			fp:     FieldPolicies{"spec.a": FieldPolicyRemote},
+			paths:  []string{"spec.a"},
+			want:   FieldPolicies{"spec.a": FieldPolicyRemote},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.fp.WithRemoteDefaults(tc.paths...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nfp.WithRemoteDefaults(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFieldPoliciesWithRemoteAnnotations(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		fp     FieldPolicies
+		keys   []string
+		want   FieldPolicies
+	}{
+		"NoAnnotations": {
+			reason: "The policies should be unchanged if no annotation is reflected",
+			fp:     FieldPolicies{"spec.a": FieldPolicyRemote},
+			want:   FieldPolicies{"spec.a": FieldPolicyRemote},
+		},
+		"Reflected": {
+			reason: "The reflected annotations should be owned by the remote instance",
+			fp:     FieldPolicies{"spec.a": FieldPolicyLateInit},
+			keys:   []string{"cost.example.org/monthly"},
+			want:   FieldPolicies{"spec.a": FieldPolicyLateInit, "metadata.annotations[cost.example.org/monthly]": FieldPolicyRemote},
+		},
+		"ExplicitPolicyWins": {
+			reason: "The policy of a reflected annotation should be kept if it has one",
+			fp:     FieldPolicies{"metadata.annotations[cost.example.org/monthly]": FieldPolicyLocal},
+			keys:   []string{"cost.example.org/monthly"},
+			want:   FieldPolicies{"metadata.annotations[cost.example.org/monthly]": FieldPolicyLocal},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.fp.WithRemoteAnnotations(tc.keys...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nfp.WithRemoteAnnotations(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFieldPoliciesDirection(t *testing.T) {
+	local := map[string]interface{}{"spec": map[string]interface{}{"f": "local"}}
+	remote := map[string]interface{}{"spec": map[string]interface{}{"f": "remote"}}
+	type want struct {
+		direction Direction
+		pushed    bool
+		pulled    bool
+	}
+	cases := map[string]struct {
+		reason string
+		policy FieldPolicy
+		local  map[string]interface{}
+		want   want
+	}{
+		"Local": {
+			reason: "A local field that is present in both instances should only be pushed",
+			policy: FieldPolicyLocal,
+			local:  local,
+			want:   want{direction: DirectionPush, pushed: true},
+		},
+		"Remote": {
+			reason: "A remote field that is present in both instances should only be pulled",
+			policy: FieldPolicyRemote,
+			local:  local,
+			want:   want{direction: DirectionPull, pulled: true},
+		},
+		"LateInitSetLocally": {
+			reason: "A late-initialized field that is present in both instances should only be pushed",
+			policy: FieldPolicyLateInit,
+			local:  local,
+			want:   want{direction: DirectionPush, pushed: true},
+		},
+		"LateInitNotSetLocally": {
+			reason: "A late-initialized field that is present only remotely should only be pulled",
+			policy: FieldPolicyLateInit,
+			local:  map[string]interface{}{},
+			want:   want{direction: DirectionPull, pulled: true},
+		},
+		"Merge": {
+			reason: "A merged field that is present in both instances should be pushed and pulled",
+			policy: FieldPolicyMerge,
+			local:  local,
+			want:   want{direction: DirectionMerge, pushed: true, pulled: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fp := FieldPolicies{"spec.f": tc.policy}
+			lp, rp := fieldpath.Pave(tc.local), fieldpath.Pave(remote)
+
+			if diff := cmp.Diff(tc.want.direction, fp.Direction("spec.f", lp)); diff != "" {
+				t.Errorf("\nReason: %s\nfp.Direction(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			push, _ := fp.ForPush("spec.f", lp, rp)
+			if diff := cmp.Diff(tc.want.pushed, push == "local"); diff != "" {
+				t.Errorf("\nReason: %s\nfp.ForPush(...): -want pushed, +got pushed:\n%s", tc.reason, diff)
+			}
+			_, pulled := fp.ForPull("spec.f", lp, rp)
+			if diff := cmp.Diff(tc.want.pulled, pulled); diff != "" {
+				t.Errorf("\nReason: %s\nfp.ForPull(...): -want pulled, +got pulled:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFieldPoliciesPaths(t *testing.T) {
+	fp := FieldPolicies{
+		"spec.parameters.size": FieldPolicyLocal,
+		"spec.resourceRef":     FieldPolicyLateInit,
+		"spec.parameters":      FieldPolicyLateInit,
+		"spec.compositionRef":  FieldPolicyLateInit,
+	}
+	want := []string{"spec.compositionRef", "spec.parameters", "spec.resourceRef", "spec.parameters.size"}
+	if diff := cmp.Diff(want, fp.Paths()); diff != "" {
+		t.Errorf("\nReason: %s\nfp.Paths(): -want, +got:\n%s", "Paths should be ordered so that the containing paths come first", diff)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+)
+
+// DefaultPriorityDelay is how long a PriorityHandler holds back the local
+// instances that are not of high priority by default.
+const DefaultPriorityDelay = 5 * time.Second
+
+// A PriorityHandlerOption configures a PriorityHandler.
+type PriorityHandlerOption func(*PriorityHandler)
+
+// WithPriorityDelay specifies how long the PriorityHandler holds back the
+// local instances that are not of high priority while the queue is busy. The
+// default is DefaultPriorityDelay.
+func WithPriorityDelay(d time.Duration) PriorityHandlerOption {
+	return func(h *PriorityHandler) {
+		h.delay = d
+	}
+}
+
+// NewPriorityHandler returns a new *PriorityHandler that gives the local
+// instances whose labels match the given selector, e.g.
+// agent.crossplane.io/priority=high, a high priority.
+func NewPriorityHandler(high labels.Selector, opts ...PriorityHandlerOption) *PriorityHandler {
+	h := &PriorityHandler{high: high, delay: DefaultPriorityDelay}
+	for _, f := range opts {
+		f(h)
+	}
+	return h
+}
+
+// A PriorityHandler is a best-effort heuristic for syncing the local instances
+// of high priority first when all of them are enqueued at once, e.g. after the
+// agent starts. The queue of a controller is first in, first out, so the others
+// are added to it only after a delay while it's busy, which lets the ones of
+// high priority that arrive in the meantime overtake them. It's not a priority
+// queue: the requests already in the queue are not reordered, and the ones of
+// high priority that arrive after the delay still queue behind the others. The
+// requests are the same as the ones of handler.EnqueueRequestForObject. The
+// deletions are never delayed, whether they arrive as the update that sets the
+// deletion timestamp of a local instance with a finalizer or as its removal,
+// and the retries the Reconciler asks for are not affected.
+type PriorityHandler struct {
+	high  labels.Selector
+	delay time.Duration
+}
+
+// Create enqueues the created local instance.
+func (h *PriorityHandler) Create(evt event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(evt.Meta, q)
+}
+
+// Update enqueues the updated local instance, at once if it's being deleted.
+func (h *PriorityHandler) Update(evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(evt.MetaNew, q)
+}
+
+// Delete enqueues the deleted local instance at once, whatever its priority.
+func (h *PriorityHandler) Delete(evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	if evt.Meta == nil {
+		return
+	}
+	q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: evt.Meta.GetNamespace(), Name: evt.Meta.GetName()}})
+}
+
+// Generic enqueues the local instance of the event.
+func (h *PriorityHandler) Generic(evt event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(evt.Meta, q)
+}
+
+func (h *PriorityHandler) enqueue(o metav1.Object, q workqueue.RateLimitingInterface) {
+	if o == nil {
+		return
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}}
+	if q.Len() == 0 || meta.WasDeleted(o) || h.high.Matches(labels.Set(o.GetLabels())) {
+		q.Add(req)
+		return
+	}
+	q.AddAfter(req, h.delay)
+}
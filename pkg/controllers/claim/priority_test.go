@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+func TestPriorityHandler(t *testing.T) {
+	newClaim := func(name, priority string) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetName(name)
+		c.SetNamespace("cool-ns")
+		if priority != "" {
+			c.SetLabels(map[string]string{"agent.crossplane.io/priority": priority})
+		}
+		return c
+	}
+	cases := map[string]struct {
+		reason   string
+		claims   []*claim.Unstructured
+		deleting []*claim.Unstructured
+		deleted  []*claim.Unstructured
+		want     []string
+	}{
+		"Contention": {
+			reason: "The claims of high priority should be processed ahead of the others that are enqueued while the queue is busy",
+			claims: []*claim.Unstructured{
+				newClaim("first", ""),
+				newClaim("low-1", "low"),
+				newClaim("high-1", "high"),
+				newClaim("low-2", ""),
+				newClaim("high-2", "high"),
+			},
+			want: []string{"first", "high-1", "high-2"},
+		},
+		"DeleteNotDelayed": {
+			reason:  "A deleted claim should be enqueued at once even if it's not of high priority and the queue is busy",
+			claims:  []*claim.Unstructured{newClaim("first", "")},
+			deleted: []*claim.Unstructured{newClaim("low-1", "low")},
+			want:    []string{"first", "low-1"},
+		},
+		"DeletingNotDelayed": {
+			reason:   "A claim whose deletion timestamp is set should be enqueued at once even if it's not of high priority and the queue is busy",
+			claims:   []*claim.Unstructured{newClaim("first", "")},
+			deleting: []*claim.Unstructured{newClaim("low-1", "low")},
+			want:     []string{"first", "low-1"},
+		},
+		"Idle": {
+			reason: "A claim that is not of high priority should be enqueued at once while the queue is idle",
+			claims: []*claim.Unstructured{newClaim("low-1", "low")},
+			want:   []string{"low-1"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer q.ShutDown()
+			h := NewPriorityHandler(labels.SelectorFromSet(labels.Set{"agent.crossplane.io/priority": "high"}), WithPriorityDelay(time.Hour))
+			for _, c := range tc.claims {
+				h.Create(event.CreateEvent{Meta: c, Object: c}, q)
+			}
+			for _, c := range tc.deleting {
+				d := c.DeepCopy()
+				d.SetDeletionTimestamp(&now)
+				h.Update(event.UpdateEvent{MetaOld: c, ObjectOld: c, MetaNew: d, ObjectNew: d}, q)
+			}
+			for _, c := range tc.deleted {
+				h.Delete(event.DeleteEvent{Meta: c, Object: c}, q)
+			}
+			var got []string
+			for q.Len() > 0 {
+				item, _ := q.Get()
+				got = append(got, item.(reconcile.Request).Name)
+				q.Done(item)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nprocessed: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -18,16 +18,22 @@ package claim
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
@@ -44,8 +50,6 @@ const (
 	shortWait = 30 * time.Second
 	tinyWait  = 5 * time.Second
 
-	finalizer = "agent.crossplane.io/sync"
-
 	localPrefix  = "local cluster: "
 	remotePrefix = "remote cluster: "
 
@@ -60,6 +64,30 @@ const (
 	errAddFinalizer      = "cannot add finalizer"
 	errGetSecret         = "cannot get secret"
 	errApplySecret       = "cannot apply secret"
+	errDeleteSecret      = "cannot delete secret"
+	errNotManaged        = "claim exists but is not managed by this agent"
+	errManagedByOther    = "claim exists and is managed by another local claim"
+	errMaxRetries        = "gave up syncing claim after too many failed attempts"
+	errIncompatible      = "cluster is not compatible"
+	errResolveScope      = "cannot resolve scope of claim"
+	errDetachClaim       = "cannot detach claim"
+	errVerifyClaim       = "cannot verify claim"
+	errSpecDiverged      = "spec of claim was altered after it was written"
+	errCheckDependencies = "cannot check dependencies of claim"
+	errRecordDeletion    = "cannot record deletion confirmation of claim"
+	errNotConfirmed      = "claim is gone but the deletion of its resources was not confirmed"
+	errValidateClaim     = "cannot validate claim"
+	errInvalidClaim      = "claim is invalid according to the remote schema"
+	errNegotiateVersion  = "cannot negotiate version of claim"
+	errDryRunClaim       = "claim was rejected by a dry-run"
+	errDetectCollisions  = "cannot detect remote name collisions of claim"
+	errKindMismatch      = "claim exists as another kind in the remote cluster"
+)
+
+// Condition messages.
+const (
+	msgThrottled       = "Remote instance keeps being reverted after it's written; waiting for the write cooldown to elapse"
+	msgConflictSkipped = "Skipped conflicting write of remote instance"
 )
 
 // Event reasons.
@@ -71,6 +99,27 @@ const (
 	reasonCannotApply           event.Reason = "CannotApply"
 	reasonCannotPropagate       event.Reason = "CannotPropagate"
 	reasonCannotDelete          event.Reason = "CannotDelete"
+	reasonCannotCleanup         event.Reason = "CannotCleanup"
+	reasonCannotAdopt           event.Reason = "CannotAdopt"
+	reasonAdopted               event.Reason = "Adopted"
+	reasonMaxRetriesExceeded    event.Reason = "MaxRetriesExceeded"
+	reasonIncompatibleRemote    event.Reason = "IncompatibleRemote"
+	reasonIncompatibleScope     event.Reason = "IncompatibleScope"
+	reasonCannotDetach          event.Reason = "CannotDetach"
+	reasonDetached              event.Reason = "Detached"
+	reasonSpecDiverged          event.Reason = "SpecDiverged"
+	reasonWaitingForDependency  event.Reason = "WaitingForDependency"
+	reasonCannotCheckDependency event.Reason = "CannotCheckDependency"
+	reasonDeletionConfirmed     event.Reason = "DeletionConfirmed"
+	reasonDeletionNotConfirmed  event.Reason = "DeletionNotConfirmed"
+	reasonCannotValidate        event.Reason = "CannotValidate"
+	reasonInvalidSpec           event.Reason = "InvalidSpec"
+	reasonIncompatibleVersion   event.Reason = "IncompatibleVersion"
+	reasonRejectedByDryRun      event.Reason = "RejectedByDryRun"
+	reasonConflictSkipped       event.Reason = "ConflictSkipped"
+	reasonNameCollision         event.Reason = "NameCollision"
+	reasonWaitingForInputSecret event.Reason = "WaitingForInputSecret"
+	reasonKindMismatch          event.Reason = "KindMismatch"
 )
 
 // WithLogger specifies how the Reconciler should log messages.
@@ -94,6 +143,94 @@ func WithFinalizer(f runtimeresource.Finalizer) ReconcilerOption {
 	}
 }
 
+// WithFinalizerName specifies the finalizer the Reconciler should add to the
+// local instances, which should be valid as per ValidateFinalizer. It's
+// ignored if a Finalizer is supplied with WithFinalizer.
+func WithFinalizerName(name string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.finalizerName = name
+	}
+}
+
+// WithPreviousFinalizers specifies the finalizers the Reconciler added to the
+// local instances before it was configured with its current one, e.g. with
+// WithFinalizerName. They're replaced with the current one, and removed along
+// with it once the local instances are released. DefaultFinalizer is always
+// considered a previous finalizer if another one is configured. It's ignored if
+// a Finalizer is supplied with WithFinalizer.
+func WithPreviousFinalizers(names ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.previousFinalizers = append(r.previousFinalizers, names...)
+	}
+}
+
+// WithoutFinalizer makes the Reconciler neither add a finalizer to the local
+// instances nor delete their remote instances once they're deleted, e.g. when
+// an external system garbage collects the remote instances. The deletion of a
+// local instance is then never blocked by the agent, while its spec, status and
+// connection secret are propagated as usual. A finalizer that was added before
+// is removed once the local instance is deleted.
+func WithoutFinalizer() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.skipFinalizer = true
+	}
+}
+
+// WithDesiredSpecAnnotation makes the Reconciler show the spec it computed for
+// each remote instance on its local instance with the AnnotationKeyDesiredSpec
+// annotation, as long as the JSON of the spec fits in the given number of
+// bytes, e.g. to troubleshoot the Configurators. DefaultDesiredSpecMaxBytes is
+// used if it's not positive.
+func WithDesiredSpecAnnotation(maxBytes int) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.desiredSpecMax = maxBytes
+		if maxBytes <= 0 {
+			r.desiredSpecMax = DefaultDesiredSpecMaxBytes
+		}
+	}
+}
+
+// WithMaintenance puts the Reconciler in maintenance mode if enabled, in which
+// it writes to neither the local nor the remote cluster, e.g. during a
+// maintenance window of either, except for the sync condition of the local
+// instances. Deleted local instances are not released until it's disabled.
+func WithMaintenance(enabled bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.maintenance = enabled
+	}
+}
+
+// WithMaintenanceSwitch puts the Reconciler in maintenance mode, as with
+// WithMaintenance, whenever the supplied MaintenanceSwitch is enabled.
+func WithMaintenanceSwitch(s *MaintenanceSwitch) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.maintenanceSwitch = s
+	}
+}
+
+// WithStaleAfter makes the Reconciler report the local instances that haven't
+// been synced successfully for longer than the given window as stale, with an
+// Unknown AgentSynced condition, so that the ones that are stuck stand out even
+// if they don't fail anew. The window of an instance that has not been synced
+// successfully since the agent started starts when it's first reconciled.
+// Nothing is reported as stale if it's zero.
+func WithStaleAfter(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.stale = nil
+		if d > 0 {
+			r.stale = newStaleTracker(d)
+		}
+	}
+}
+
+// WithConfigurator specifies how the Reconciler should configure the remote
+// instance before it's applied.
+func WithConfigurator(c Configurator) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.Configurator = c
+	}
+}
+
 // WithPropagator specifies how the Reconciler should propagate values and objects
 // between clusters.
 func WithPropagator(p Propagator) ReconcilerOption {
@@ -102,6 +239,657 @@ func WithPropagator(p Propagator) ReconcilerOption {
 	}
 }
 
+// WithFieldPolicies specifies which cluster owns the given fields of the claim.
+// The supplied policies replace the defaults, so callers that would like to
+// extend them should start with DefaultFieldPolicies. The policies are used
+// only if the default Configurator and Propagator are used.
+func WithFieldPolicies(p FieldPolicies) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.policies = p
+	}
+}
+
+// WithReflectedAnnotations specifies the keys of the annotations of the remote
+// instance, e.g. ones with cost or usage data set by the remote cluster, that
+// are mirrored to the local instance. They're owned by the remote instance, so
+// the local values are never pushed. They have no effect if late
+// initialization is disabled.
+func WithReflectedAnnotations(keys ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.reflected = append(r.reflected, keys...)
+	}
+}
+
+// WithPolicyAnnotationPassthrough specifies the policy annotations that are
+// passed through from the local instances to the remote ones, e.g.
+// crossplane.io/paused to pause the remote instances along with the local
+// ones. The default is DefaultPolicyAnnotations.
+func WithPolicyAnnotationPassthrough(keys ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.passthrough = keys
+		r.configureOpts = append(r.configureOpts, WithPolicyAnnotations(keys...))
+	}
+}
+
+// WithRemoteDefaultedFields specifies the fields of the claim that are
+// defaulted by the admission webhooks of the remote cluster. Their remote
+// values are late-initialized to the local instance rather than overwritten,
+// unless the field policies say otherwise.
+func WithRemoteDefaultedFields(paths ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.remoteDefaults = append(r.remoteDefaults, paths...)
+	}
+}
+
+// WithSyncTracker specifies how the Reconciler should keep track of the claims
+// that are not synced.
+func WithSyncTracker(t *SyncTracker) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.tracker = t
+	}
+}
+
+// WithFilter specifies which claims the Reconciler should propagate. The claims
+// that do not pass the filter are left untouched except for their sync
+// condition. Claims that are being deleted are always processed so that their
+// remote instances can be cleaned up.
+func WithFilter(f Filter) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.filter = f
+	}
+}
+
+// WithConnectionSecretPropagatorOptions specifies the options of the default
+// ConnectionSecretPropagator. They have no effect if a custom Propagator is used.
+func WithConnectionSecretPropagatorOptions(opts ...ConnectionSecretPropagatorOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.secretOpts = append(r.secretOpts, opts...)
+	}
+}
+
+// WithBackoffStrategy specifies how long the Reconciler waits before retrying a
+// claim that failed to be synced. The default is DefaultBackoffStrategy.
+func WithBackoffStrategy(s BackoffStrategy) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.backoff = s
+	}
+}
+
+// WithMaxRetries specifies the number of consecutive failed attempts after which
+// the Reconciler gives up syncing a claim until either its spec or the value of
+// its sync-now annotation changes. Zero means retrying forever.
+func WithMaxRetries(n int) ReconcilerOption {
+	return func(r *Reconciler) {
+		if n > 0 {
+			r.retries = NewRetryLimiter(n)
+		}
+	}
+}
+
+// WithWriteCooldown specifies the minimum interval between two writes of the
+// same remote instance. A remote instance that was written recently is not
+// written again until the cooldown elapses unless what's written changes, e.g.
+// because the local spec changed. Zero means no cooldown.
+func WithWriteCooldown(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		if d > 0 {
+			r.throttle = NewWriteThrottle(d)
+		}
+	}
+}
+
+// WithoutLateInitialization disables the LateInitializer of the default
+// Propagator so that the local instance is never updated by the Reconciler
+// except for its status and finalizer. This is useful when the local instance
+// is managed by GitOps tools that would see any write-back as drift. As a
+// consequence, the defaults and references that are filled in the remote
+// instance do not appear in the local instance.
+func WithoutLateInitialization() ReconcilerOption {
+	return WithLateInitialization(false)
+}
+
+// WithLateInitialization specifies whether the LateInitializer of the default
+// Propagator is enabled. It's enabled by default.
+func WithLateInitialization(enabled bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.disableLateInit = !enabled
+	}
+}
+
+// WithSpecPropagation specifies whether the local instance is pushed to the
+// remote cluster. If disabled, the remote instance is never created or updated
+// but the information of an existing one is still propagated to the local
+// instance. It's enabled by default.
+func WithSpecPropagation(enabled bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.disableSpec = !enabled
+	}
+}
+
+// WithStatusPropagation specifies whether the StatusPropagator of the default
+// Propagator is enabled. Disabling it is useful when the status of the local
+// instance is owned by another controller. It's enabled by default.
+func WithStatusPropagation(enabled bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.disableStatus = !enabled
+	}
+}
+
+// WithStatusPropagationFor specifies whether the StatusPropagator of the
+// default Propagator is enabled for each of the given kinds of local instances,
+// e.g. to keep the status written by a local controller for some of them. It
+// overrides WithStatusPropagation for the given kinds.
+func WithStatusPropagationFor(kinds map[schema.GroupVersionKind]bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.statusKinds = kinds
+	}
+}
+
+// WithSecretPropagation specifies whether the ConnectionSecretPropagator of the
+// default Propagator is enabled. It's enabled by default.
+func WithSecretPropagation(enabled bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.disableSecret = !enabled
+	}
+}
+
+// WithUnorderedArrays specifies the paths of the arrays in the claim whose order
+// of elements is not significant, e.g. because the remote cluster reorders them.
+// Reordering of these arrays does not cause the remote instance to be written.
+func WithUnorderedArrays(paths []string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.unordered = paths
+	}
+}
+
+// WithLateInitializerOptions specifies the options of the default
+// LateInitializer. They have no effect if a custom Propagator is supplied.
+func WithLateInitializerOptions(opts ...LateInitializerOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.lateInitOpts = append(r.lateInitOpts, opts...)
+	}
+}
+
+// WithEqualityFunc specifies how the Reconciler decides whether the remote
+// instance and the pulled fields of the local instance need to be written. The
+// default is DefaultEqualityFunc. The option has no effect on the pull side if
+// a custom Propagator is supplied.
+func WithEqualityFunc(eq EqualityFunc) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.equal = eq
+		r.lateInitOpts = append(r.lateInitOpts, WithLateInitEqualityFunc(eq))
+		r.statusOpts = append(r.statusOpts, WithStatusEqualityFunc(eq))
+	}
+}
+
+// WithPropagatorOrder specifies the order the steps of the default Propagator
+// run in, e.g. SecretFirstPropagatorOrder. The steps that are not given don't
+// run at all. It has no effect if a custom Propagator is supplied. The default
+// is DefaultPropagatorOrder.
+func WithPropagatorOrder(steps ...PropagatorStep) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.propagatorOrder = steps
+	}
+}
+
+// WithStatusPropagatorOptions specifies the options of the default
+// StatusPropagator. They have no effect if a custom Propagator is supplied.
+func WithStatusPropagatorOptions(opts ...StatusPropagatorOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.statusOpts = append(r.statusOpts, opts...)
+	}
+}
+
+// WithProviderConfigDefaulting makes the Reconciler set the provider config of
+// the remote instances to the given one, which is usually specific to the
+// remote cluster. See ProviderConfigDefaulter for the precedence rules.
+func WithProviderConfigDefaulting(name string, opts ...ProviderConfigDefaulterOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.configurators = append(r.configurators, NewProviderConfigDefaulter(name, opts...))
+	}
+}
+
+// WithProviderConfigInjection makes the Reconciler add a reference to the
+// provider config with the given name to the remote instances that don't
+// refer to any. See ProviderConfigInjector.
+func WithProviderConfigInjection(name string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.configurators = append(r.configurators, NewProviderConfigInjector(name))
+	}
+}
+
+// WithManagementPolicies makes the Reconciler set the management policies of
+// the remote instances to the given ones unless the field policies give them
+// other ones. See ManagementPoliciesInjector.
+func WithManagementPolicies(policies []string, opts ...ManagementPoliciesInjectorOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.configurators = append(r.configurators, NewManagementPoliciesInjector(policies, opts...))
+	}
+}
+
+// WithRemoteMetadata makes the Reconciler add the given labels and
+// annotations to all remote instances. See MetadataInjector.
+func WithRemoteMetadata(labels, annotations map[string]string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.configurators = append(r.configurators, NewMetadataInjector(labels, annotations))
+	}
+}
+
+// WithOriginLabels makes the Reconciler add the origin labels of the given
+// Origin to all remote instances. The secrets mirrored for them by
+// WithInputSecretRefs get the same labels. See Origin.
+func WithOriginLabels(o Origin) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.configurators = append(r.configurators, NewMetadataInjector(o.Labels(), nil))
+	}
+}
+
+// WithFieldRewrites makes the Reconciler rewrite the string fields at the given
+// paths of the remote instances, e.g. to replace the registry host of images
+// for an air-gapped remote cluster. See FieldRewriter.
+func WithFieldRewrites(paths []string, rw ...Rewrite) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.configurators = append(r.configurators, NewFieldRewriter(paths, rw...))
+	}
+}
+
+// WithReferenceRewrites makes the Reconciler rewrite the references at the
+// given paths of the remote instances to the local objects of the given kinds,
+// so that they refer to the remote objects of those, which may be in another
+// namespace, e.g. because of a namespace mapping. The referred objects are
+// named the way the Reconciler names the remote instances of their kind. See
+// ReferenceRewriter.
+func WithReferenceRewrites(refs map[string]schema.GroupVersionKind) ReconcilerOption {
+	return func(r *Reconciler) {
+		if r.referenceKinds == nil {
+			r.referenceKinds = map[string]schema.GroupVersionKind{}
+		}
+		for path, gvk := range refs {
+			r.referenceKinds[path] = gvk
+		}
+	}
+}
+
+// WithValueTemplates makes the Reconciler render the templates at the given
+// paths of the remote instances with the values of the local ConfigMap with the
+// given key, e.g. to inject the region of the environment. See ValueTemplater.
+func WithValueTemplates(values types.NamespacedName, paths ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.templateValues = values
+		r.templatePaths = append(r.templatePaths, paths...)
+	}
+}
+
+// WithRemoteWarmup makes the Reconciler list all remote claims of its kind, and
+// the remote secrets in their namespaces, once and serve the first read of
+// each of them from that list for a while. This drastically cuts the number of remote reads when all claims are
+// reconciled after the agent starts.
+func WithRemoteWarmup() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.warmup = true
+	}
+}
+
+// WithRemoteRetryAfter makes the Reconciler requeue after the delay suggested
+// by the remote API server, e.g. with a Retry-After header of a 429 response,
+// instead of its own schedule when a remote call fails.
+func WithRemoteRetryAfter() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.retryAfter = true
+	}
+}
+
+// WithLocalSecretCleanup makes the Reconciler delete the local connection
+// secrets of a claim before releasing it, instead of relying on the garbage
+// collection via owner references. See ConnectionSecretCleaner.
+func WithLocalSecretCleanup() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.cleanupSecrets = true
+	}
+}
+
+// WithCentralSecretNamespace makes the Reconciler write all local connection
+// secrets to the given namespace instead of the namespace of their claim, e.g.
+// for centralized access control. Since these secrets can't be garbage
+// collected via owner references, the Reconciler deletes them itself when
+// their claim is deleted.
+func WithCentralSecretNamespace(ns string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.secretNamespace = ns
+		r.secretOpts = append(r.secretOpts, WithSecretNamespace(ns))
+	}
+}
+
+// WithCapabilityChecker makes the Reconciler refuse to propagate claims to a
+// remote cluster that does not pass the supplied check.
+func WithCapabilityChecker(c *CapabilityChecker) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.capabilities = c
+	}
+}
+
+// WithNamespaceCapabilityCheckers makes the Reconciler check the remote
+// clusters of the local instances in the keys of the supplied map with the
+// checkers they map to instead of the one of WithCapabilityChecker, e.g. when
+// a RemoteClientSelector routes them to other remote clusters.
+func WithNamespaceCapabilityCheckers(c map[string]*CapabilityChecker) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.nsCapabilities = c
+	}
+}
+
+// WithSchemaValidation makes the Reconciler validate the spec of the remote
+// instances against the schema of their kind in the remote cluster before
+// writing them, and report the invalid fields instead of writing them. See
+// SchemaValidator.
+func WithSchemaValidation(v *SchemaValidator) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.schemas = v
+	}
+}
+
+// WithSchemaPruning makes the Reconciler remove the fields of the remote
+// instances that the schema of their kind in the remote cluster does not
+// define before writing them, rather than failing to write them. See
+// SchemaPruner.
+func WithSchemaPruning(v *SchemaValidator) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.pruner = v
+	}
+}
+
+// WithTransactionalGroups makes the Reconciler apply the claims that share the
+// same group label together, rolling back the ones it created if any of them
+// fails. See GroupApplicator.
+func WithTransactionalGroups() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.transactional = true
+	}
+}
+
+// WithScopeResolver makes the Reconciler place the remote instances according
+// to the scope of their kind in the remote cluster, which may differ from the
+// one in the local cluster. See ScopeResolver. It takes the place of the
+// ScopeResolver of a previous WithScopeResolver, e.g. the one of the flags once
+// an AgentConfig sets the namespace mappings.
+func WithScopeResolver(s *ScopeResolver) ReconcilerOption {
+	return func(r *Reconciler) {
+		for i, c := range r.configurators {
+			if r.scope != nil && c == Configurator(r.scope) {
+				r.configurators[i] = s
+				r.scope = s
+				return
+			}
+		}
+		r.scope = s
+		r.configurators = append(r.configurators, s)
+	}
+}
+
+// WithVersionNegotiation makes the Reconciler read and write the remote
+// instances at the version the supplied VersionNegotiator negotiates with the
+// remote cluster rather than at the version of the local instances.
+func WithVersionNegotiation(n *VersionNegotiator) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.versions = n
+	}
+}
+
+// WithLastResults makes the Reconciler record the outcome of the last
+// reconciliation of each claim, including the outcome of each Propagator if
+// the Propagator is a PropagatorChain.
+func WithLastResults(l *LastResults) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.results = l
+	}
+}
+
+// WithGVKAliases specifies the kinds the remote instances are served as if
+// they differ from the kinds of the local instances.
+func WithGVKAliases(a GVKAliases) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.aliases = a
+	}
+}
+
+// A VerifyMode determines what happens when the spec of a remote instance that
+// is read back after it's written differs from the written one.
+type VerifyMode string
+
+// Verify modes.
+const (
+	// VerifyModeWarn reports the divergence but considers the claim synced.
+	VerifyModeWarn VerifyMode = "Warn"
+
+	// VerifyModeFail considers the claim not synced and retries it.
+	VerifyModeFail VerifyMode = "Fail"
+)
+
+// WithPostApplyVerify makes the Reconciler read every remote instance back
+// after writing it and report if its spec differs from the written one, e.g.
+// because a mutating admission webhook altered it. The fields that the remote
+// cluster is allowed to set according to the field policies are not compared.
+// Remote instances written as part of a group are not verified.
+func WithPostApplyVerify(m VerifyMode) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.verify = m
+	}
+}
+
+// WithDeletionConfirmation makes the Reconciler release a deleted local
+// instance only after its remote instance reported the condition of the given
+// type as True while it was being deleted, e.g. to confirm that the resources
+// backing it are gone too rather than only the remote instance itself. The
+// confirmation is recorded on the local instance since the remote instance may
+// disappear right after reporting it. See AnnotationKeyDeletionConfirmation.
+func WithDeletionConfirmation(t v1alpha1.ConditionType) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.confirmDeletion = t
+	}
+}
+
+// A RemoteDeletionPolicy determines what happens to the remote instance of a
+// local instance that is deleted.
+type RemoteDeletionPolicy string
+
+// Remote deletion policies.
+const (
+	// RemoteDeletionPolicyDelete deletes the remote instance along with the
+	// local one, once the deletion grace period is over.
+	RemoteDeletionPolicyDelete RemoteDeletionPolicy = "Delete"
+
+	// RemoteDeletionPolicyAbandon keeps the remote instance and stops
+	// managing it, as if the local instance was detached before it was
+	// deleted.
+	RemoteDeletionPolicyAbandon RemoteDeletionPolicy = "Abandon"
+)
+
+// WithRemoteDeletionPolicy specifies what the Reconciler should do with the
+// remote instances of the deleted local instances. The default is
+// RemoteDeletionPolicyDelete.
+func WithRemoteDeletionPolicy(p RemoteDeletionPolicy) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.deletionPolicy = p
+	}
+}
+
+// WithDeletionGracePeriod makes the Reconciler keep the remote instance of a
+// deleted local instance for the given period after the deletion was requested
+// before it deletes it, so that an accidental deletion can be undone in the
+// remote cluster. The local instance is released only once the remote one is
+// deleted.
+func WithDeletionGracePeriod(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.deletionGrace = d
+	}
+}
+
+// WithSecretMetrics specifies how the Reconciler should report the shape of the
+// propagated connection secrets.
+func WithSecretMetrics(m *SecretMetrics) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.secretMetrics = m
+		r.secretOpts = append(r.secretOpts, WithConnectionSecretMetrics(m))
+	}
+}
+
+// WithLegacyAdoption specifies whether the Reconciler adopts the unmanaged
+// remote instances that look like they were written for their local instances
+// by the versions of the agent that didn't record the local instance, even if
+// their local instances don't allow adoption. See IsLegacyManaged. It's
+// enabled by default so that the remote instances written before an upgrade
+// are still managed after it.
+func WithLegacyAdoption(enabled bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.disableLegacy = !enabled
+	}
+}
+
+// WithInputSecretRefs makes the Reconciler mirror the local secrets referred to
+// at the given paths of the local instances, e.g.
+// spec.forProvider.passwordSecretRef, to the remote cluster. The secrets are
+// applied right before the remote instance is, once it passed its dry-run and
+// validation. A local instance whose secrets don't exist yet waits for them. See
+// InputSecretPropagator.
+func WithInputSecretRefs(paths ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inputSecretRefs = append(r.inputSecretRefs, paths...)
+	}
+}
+
+// WithAllowedInputSecretNamespaces makes the Reconciler mirror the input
+// secrets in the given local namespaces too, rather than only those in the
+// namespace of the local instance that refers to them.
+func WithAllowedInputSecretNamespaces(ns ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inputSecretOpts = append(r.inputSecretOpts, WithAllowedSecretNamespaces(ns...))
+	}
+}
+
+// WithServerSideApply makes the Reconciler write the remote instances with
+// server-side apply as the supplied field manager instead of patching them.
+// See ServerSideApplicator.
+func WithServerSideApply(manager string, opts ...ServerSideApplicatorOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.fieldManager = manager
+		r.ssaOpts = append(r.ssaOpts, opts...)
+	}
+}
+
+// WithAuditWriter makes the Reconciler record every change it applies to a
+// remote instance with the supplied AuditWriter. Failing to record a change
+// does not fail the reconciliation. Remote instances written as part of a group
+// are not recorded.
+func WithAuditWriter(a *AuditWriter) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.audit = a
+	}
+}
+
+// WithMergePatch makes the Reconciler write the remote instances with the
+// minimal JSON merge patch from their current state instead of patching them
+// with their whole content, so that the remote instances that are up to date
+// aren't written. It's ignored if the remote instances are written with
+// server-side apply. See MergePatchApplicator.
+func WithMergePatch() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.mergePatch = true
+	}
+}
+
+// WithDryRun makes the Reconciler write each remote instance with server-side
+// dry-run before it writes it for real, and only write it if the dry-run is
+// accepted. This lets the admission webhooks of the remote cluster reject a
+// remote instance without any of it being written. The message of the
+// rejection is reported in the AgentSynced condition of the local instance.
+func WithDryRun() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.dryRunEnabled = true
+	}
+}
+
+// WithMetadataLimits makes the Reconciler apply the given policy to the labels
+// and annotations propagated to the remote instances that exceed the given
+// limits. See MetadataLimiter.
+func WithMetadataLimits(l MetadataLimits, p MetadataLimitPolicy) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.metadataLimits = &l
+		r.metadataPolicy = p
+	}
+}
+
+// WithUnknownFieldPolicy specifies what the Reconciler should do with the
+// fields of the remote instances that the given UnknownFieldFinder finds
+// unknown to the remote cluster, e.g. a SchemaValidator or KnownFields. The
+// policy is applied once the remote instances are otherwise fully configured.
+func WithUnknownFieldPolicy(p UnknownFieldPolicy, f UnknownFieldFinder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.unknownPolicy = p
+		r.unknownFinder = f
+	}
+}
+
+// WithCacheSyncCheck makes the Reconciler requeue the local instances without
+// acting on them while the supplied function reports that the cache of the
+// local cluster is not synced.
+func WithCacheSyncCheck(synced func() bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.cacheSynced = synced
+	}
+}
+
+// WithRemoteEventMessages makes the Reconciler surface the latest warning
+// event of each remote instance, e.g. one about a composition that cannot be
+// selected, in the message of the AgentSynced condition of its local instance
+// once it's synced. See RemoteEventReader.
+func WithRemoteEventMessages(er *RemoteEventReader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.events = er
+	}
+}
+
+// WithRetryBudget bounds the number of retries the steps of a reconciliation,
+// e.g. the late-initialization of a conflicting local instance or the fetching
+// of a remote connection secret, make together. A reconciliation that spends
+// its budget fails and is requeued with the backoff of the Reconciler. The
+// retries are bounded only by the steps themselves if the budget is zero.
+func WithRetryBudget(retries int) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.retryBudget = retries
+	}
+}
+
+// WithCollisionDetection makes the Reconciler scan all local instances before
+// syncing any of them and refuse to sync the ones that would be synced to the
+// same remote instance, which would otherwise silently overwrite each other.
+// See CollisionDetector.
+func WithCollisionDetection(opts ...CollisionDetectorOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.detectCollision = true
+		r.collisionOpts = append(r.collisionOpts, opts...)
+	}
+}
+
+// WithConflictHandler makes the Reconciler ask the supplied ConflictHandler
+// what to do whenever a write of a remote instance conflicts with a change made
+// since it was read. The conflict is retried with the backoff of the
+// Reconciler if no ConflictHandler is supplied.
+func WithConflictHandler(h ConflictHandler) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.conflicts = h
+	}
+}
+
+// WithPolicyMetrics specifies how the Reconciler should count the values the
+// field policies change in either direction. They're counted with the metrics
+// registered with the controller-runtime registry by default.
+func WithPolicyMetrics(m *PolicyMetrics) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.configureOpts = append(r.configureOpts, WithConfiguratorPolicyMetrics(m))
+		r.lateInitOpts = append(r.lateInitOpts, WithLateInitPolicyMetrics(m))
+	}
+}
+
 // ReconcilerOption is used to configure *Reconciler.
 type ReconcilerOption func(*Reconciler)
 
@@ -119,28 +907,184 @@ func NewReconciler(mgr manager.Manager, remoteClient client.Client, gvk schema.G
 		Applicator: runtimeresource.NewAPIPatchingApplicator(rc),
 	}
 	r := &Reconciler{
-		mgr:          mgr,
-		local:        lca,
-		remote:       rca,
-		newInstance:  ni,
-		log:          logging.NewNopLogger(),
-		finalizer:    runtimeresource.NewAPIFinalizer(lc, finalizer),
-		Configurator: NewDefaultConfigurator(),
-		Propagator: NewPropagatorChain(
-			NewLateInitializer(lc),
-			NewStatusPropagator(),
-			NewConnectionSecretPropagator(lca, rca),
-		),
-		record: event.NewNopRecorder(),
+		mgr:           mgr,
+		local:         lca,
+		remote:        rca,
+		newInstance:   ni,
+		log:           logging.NewNopLogger(),
+		finalizerName: DefaultFinalizer,
+		policies:      DefaultFieldPolicies(),
+		filter:        func(_ *claim.Unstructured) bool { return true },
+		tracker:       defaultSyncTracker,
+		secretMetrics: defaultSecretMetrics,
+		equal:         DefaultEqualityFunc,
+		backoff:       DefaultBackoffStrategy(),
+		failures:      newFailureCounter(),
+		record:        event.NewNopRecorder(),
+		passthrough:   DefaultPolicyAnnotations(),
 	}
+	r.dependencies = NewDependencyChecker(lc, ni)
+	r.propagatorOrder = DefaultPropagatorOrder()
 
 	for _, f := range opts {
 		f(r)
 	}
+	if r.finalizer == nil {
+		r.finalizer = NewMigratingFinalizer(lc, r.finalizerName, r.oldFinalizers()...)
+	}
+
+	// The remote instances are of the kind the local kind is aliased to, if
+	// any.
+	rgvk := r.aliases.Remote(gvk)
+	r.newRemoteInstance = func() *claim.Unstructured { return claim.New(claim.WithGroupVersionKind(rgvk)) }
+
+	if len(r.remoteDefaults) > 0 {
+		r.policies = r.policies.WithRemoteDefaults(r.remoteDefaults...)
+	}
+	if len(r.reflected) > 0 {
+		r.policies = r.policies.WithRemoteAnnotations(r.reflected...)
+	}
+	if r.cleanupSecrets || r.secretNamespace != "" {
+		r.cleaner = NewConnectionSecretCleaner(lc, WithCleanupNamespace(r.secretNamespace))
+	}
+	if r.warmup {
+		l := &kunstructured.UnstructuredList{}
+		l.SetGroupVersionKind(rgvk.GroupVersion().WithKind(rgvk.Kind + "List"))
+		warm := func(c client.Client) client.Client {
+			return NewWarmupClient(c, l, WithWarmupDependents(&v1.SecretList{}))
+		}
+		// Each remote cluster is warmed up on its own, so that a claim is
+		// never read from the list of another cluster.
+		if s, ok := remoteClient.(*RemoteClientSelector); ok {
+			rc = unstructured.NewClient(s.Wrap(warm))
+		} else {
+			rc = unstructured.NewClient(warm(remoteClient))
+		}
+		rca = runtimeresource.ClientApplicator{
+			Client:     rc,
+			Applicator: runtimeresource.NewAPIPatchingApplicator(rc),
+		}
+		r.remote = rca
+	}
+	switch {
+	case r.fieldManager != "":
+		r.remote.Applicator = NewServerSideApplicator(rc, r.fieldManager, r.ssaOpts...)
+	case r.mergePatch:
+		r.remote.Applicator = NewMergePatchApplicator(rc)
+	}
+	// The dry-run writes the same way as the real write so that it sees what
+	// the real write would.
+	if r.dryRunEnabled {
+		dc := &dryRunClient{Client: rc}
+		switch {
+		case r.fieldManager != "":
+			r.dryRun = NewServerSideApplicator(dc, r.fieldManager, r.ssaOpts...)
+		case r.mergePatch:
+			r.dryRun = NewMergePatchApplicator(dc)
+		default:
+			r.dryRun = runtimeresource.NewAPIPatchingApplicator(dc)
+		}
+	}
+
+	// The references are named after the scope is resolved, which may be
+	// configured by any option.
+	if len(r.referenceKinds) > 0 {
+		refs := make(map[string]RemoteNamer, len(r.referenceKinds))
+		for path, k := range r.referenceKinds {
+			refs[path] = NewScopeNamer(r.scope, r.aliases.Remote(k))
+		}
+		r.configurators = append(r.configurators, NewReferenceRewriter(refs))
+	}
+	if len(r.templatePaths) > 0 {
+		r.configurators = append(r.configurators, NewValueTemplater(lc, r.templateValues, r.templatePaths...))
+	}
+	// The remote instances are pruned once they're otherwise fully configured.
+	if r.pruner != nil {
+		r.configurators = append(r.configurators, NewSchemaPruner(r.pruner, r.log))
+	}
+	if r.unknownFinder != nil {
+		r.configurators = append(r.configurators, NewUnknownFieldChecker(r.unknownPolicy, r.unknownFinder, r.log))
+	}
+	if r.metadataLimits != nil {
+		r.configurators = append(r.configurators, NewMetadataLimiter(*r.metadataLimits, r.metadataPolicy, r.log))
+	}
+	// The references to the input secrets are rewritten last. The secrets are
+	// written only right before the remote instance that refers to them.
+	if len(r.inputSecretRefs) > 0 {
+		r.inputSecrets = NewInputSecretPropagator(lc, rca, r.inputSecretRefs, r.inputSecretOpts...)
+		r.configurators = append(r.configurators, r.inputSecrets)
+	}
+
+	// The colliding instances are found with the same naming as the one the
+	// remote instances are written with unless it's overridden.
+	if r.detectCollision {
+		r.collisions = NewCollisionDetector(lc, gvk, append([]CollisionDetectorOption{WithRemoteNamer(NewScopeNamer(r.scope, rgvk))}, r.collisionOpts...)...)
+	}
+
+	if enabled, ok := r.statusKinds[gvk]; ok {
+		r.disableStatus = !enabled
+	}
+
+	// The default Configurator and Propagator are constructed only after all
+	// options are applied so that they can be configured by these options.
+	if r.Configurator == nil {
+		r.Configurator = append(NewConfiguratorChain(NewDefaultConfigurator(r.policies, r.configureOpts...)), r.configurators...)
+	}
+	r.builder = NewRemoteBuilder(r.Configurator)
+	if r.Propagator == nil {
+		var chain PropagatorChain
+		for _, step := range r.propagatorOrder {
+			switch {
+			case step == PropagatorStepLateInit && !r.disableLateInit:
+				chain = append(chain, NewLateInitializer(lc, r.policies, r.lateInitOpts...))
+			case step == PropagatorStepStatus && !r.disableStatus:
+				chain = append(chain, NewStatusPropagator(r.statusOpts...))
+			case step == PropagatorStepConnectionSecret && !r.disableSecret:
+				chain = append(chain, NewConnectionSecretPropagator(lca, rca, r.secretOpts...))
+			}
+		}
+		r.Propagator = chain
+	}
+	if r.transactional {
+		r.groups = NewGroupApplicator(lc, r.remote, r.groupMember, r.unordered, r.equal, WithBeforeMemberApply(r.mirrorInputSecrets))
+	}
 	return r
 }
 
-// Configurator configures the supplied remote instance.
+// inMaintenance returns true if the Reconciler is in maintenance mode.
+func (r *Reconciler) inMaintenance() bool {
+	return r.maintenance || r.maintenanceSwitch.Enabled()
+}
+
+// adoptable returns an error unless the supplied remote instance, which is not
+// managed by the supplied local instance, may be adopted by it.
+func (r *Reconciler) adoptable(local, remote *claim.Unstructured) error {
+	switch {
+	case IsManaged(remote):
+		return errors.New(errManagedByOther)
+	case AllowsAdoption(local), !r.disableLegacy && IsLegacyManaged(remote, local):
+		return nil
+	default:
+		return errors.New(errNotManaged)
+	}
+}
+
+// mirrorInputSecrets writes the input secrets of the supplied local instance for
+// the supplied remote instance, if any are configured.
+func (r *Reconciler) mirrorInputSecrets(ctx context.Context, local, remote *claim.Unstructured) error {
+	if r.inputSecrets == nil {
+		return nil
+	}
+	return r.inputSecrets.Mirror(ctx, local, remote)
+}
+
+// Configurator configures the supplied remote instance. The Reconciler writes
+// the remote instance once per reconciliation, with the changes of all of its
+// Configurators, after the last of them ran. A Configurator may make sure that
+// what the remote instance cannot be written without exists, e.g. its
+// namespace, but anything that should be written only along with the remote
+// instance, e.g. the input secrets, is written by the Reconciler right before
+// the remote instance.
 type Configurator interface {
 	Configure(ctx context.Context, local, remote *claim.Unstructured) error
 }
@@ -157,14 +1101,267 @@ type Reconciler struct {
 	local  runtimeresource.ClientApplicator
 	remote runtimeresource.ClientApplicator
 
-	newInstance func() *claim.Unstructured
+	newInstance       func() *claim.Unstructured
+	newRemoteInstance func() *claim.Unstructured
+	aliases           GVKAliases
+
+	finalizer          runtimeresource.Finalizer
+	finalizerName      string
+	previousFinalizers []string
+	skipFinalizer      bool
+	policies           FieldPolicies
+	remoteDefaults     []string
+	reflected          []string
+	filter             Filter
+	unordered          []string
+	equal              EqualityFunc
+
+	configureOpts     []DefaultConfiguratorOption
+	passthrough       []string
+	disableLegacy     bool
+	lateInitOpts      []LateInitializerOption
+	statusOpts        []StatusPropagatorOption
+	secretOpts        []ConnectionSecretPropagatorOption
+	secretMetrics     *SecretMetrics
+	disableLateInit   bool
+	disableSpec       bool
+	disableStatus     bool
+	statusKinds       map[schema.GroupVersionKind]bool
+	disableSecret     bool
+	configurators     []Configurator
+	warmup            bool
+	retryAfter        bool
+	cleanupSecrets    bool
+	secretNamespace   string
+	cleaner           *ConnectionSecretCleaner
+	capabilities      *CapabilityChecker
+	nsCapabilities    map[string]*CapabilityChecker
+	schemas           *SchemaValidator
+	pruner            *SchemaValidator
+	transactional     bool
+	groups            *GroupApplicator
+	scope             *ScopeResolver
+	versions          *VersionNegotiator
+	results           *LastResults
+	verify            VerifyMode
+	dependencies      *DependencyChecker
+	fieldManager      string
+	inputSecretRefs   []string
+	inputSecretOpts   []InputSecretPropagatorOption
+	inputSecrets      *InputSecretPropagator
+	referenceKinds    map[string]schema.GroupVersionKind
+	templateValues    types.NamespacedName
+	templatePaths     []string
+	confirmDeletion   v1alpha1.ConditionType
+	ssaOpts           []ServerSideApplicatorOption
+	mergePatch        bool
+	audit             *AuditWriter
+	dryRunEnabled     bool
+	unknownPolicy     UnknownFieldPolicy
+	unknownFinder     UnknownFieldFinder
+	cacheSynced       func() bool
+	conflicts         ConflictHandler
+	deletionPolicy    RemoteDeletionPolicy
+	deletionGrace     time.Duration
+	detectCollision   bool
+	collisionOpts     []CollisionDetectorOption
+	collisions        *CollisionDetector
+	retryBudget       int
+	events            *RemoteEventReader
+	propagatorOrder   []PropagatorStep
+	metadataLimits    *MetadataLimits
+	metadataPolicy    MetadataLimitPolicy
+	desiredSpecMax    int
+	maintenance       bool
+	maintenanceSwitch *MaintenanceSwitch
+	dryRun            runtimeresource.Applicator
 
-	finalizer runtimeresource.Finalizer
 	Configurator
 	Propagator
+	builder *RemoteBuilder
+
+	tracker  *SyncTracker
+	stale    *staleTracker
+	retries  *RetryLimiter
+	backoff  BackoffStrategy
+	failures *failureCounter
+	throttle *WriteThrottle
+	log      logging.Logger
+	record   event.Recorder
+}
+
+// requeueAfter returns how long to wait before retrying the claim with given
+// key after it failed to be synced with the supplied error.
+func (r *Reconciler) requeueAfter(key string, err error) time.Duration {
+	return r.requeueAfterClass(key, ClassifyError(err), err)
+}
+
+// requeueAfterClass is like requeueAfter, but for an error whose class is known
+// by the Reconciler, e.g. a remote instance that is not managed by the agent.
+// The delay suggested by the remote API server in the supplied error wins if
+// the Reconciler respects it, and the BackoffStrategy decides otherwise.
+func (r *Reconciler) requeueAfterClass(key string, class ErrorClass, err error) time.Duration {
+	if r.retryAfter {
+		if s, ok := kerrors.SuggestsClientDelay(errors.Cause(err)); ok && s > 0 {
+			return time.Duration(s) * time.Second
+		}
+	}
+	return r.backoff.Backoff(r.failures.Attempt(key), class)
+}
+
+// cleanup deletes the local connection secrets of the supplied local object if
+// the Reconciler is configured to do so, and stops reporting their metrics. It
+// also releases the input secrets mirrored for the local object to the supplied
+// remote namespace.
+func (r *Reconciler) cleanup(ctx context.Context, local *claim.Unstructured, remoteNamespace string) error {
+	r.secretMetrics.Forget(local)
+	if r.inputSecrets != nil {
+		if err := r.inputSecrets.Release(ctx, local, remoteNamespace); err != nil {
+			return err
+		}
+	}
+	if r.cleaner == nil {
+		return nil
+	}
+	return r.cleaner.Cleanup(ctx, local)
+}
+
+// recordDeletion records on the supplied local object whether the remote
+// cluster confirmed the deletion of the supplied remote object, and updates the
+// local object if the record changed. A confirmation is never revoked.
+func (r *Reconciler) recordDeletion(ctx context.Context, local, remote *claim.Unstructured) error {
+	current := local.GetAnnotations()[AnnotationKeyDeletionConfirmation]
+	desired := current
+	switch {
+	case current == DeletionConfirmationConfirmed:
+		return nil
+	case meta.WasDeleted(remote) && remote.GetCondition(r.confirmDeletion).Status == v1.ConditionTrue:
+		desired = DeletionConfirmationConfirmed
+	case current == "":
+		desired = DeletionConfirmationPending
+	}
+	if desired == current {
+		return nil
+	}
+	meta.AddAnnotations(local, map[string]string{AnnotationKeyDeletionConfirmation: desired})
+	if err := r.local.Update(ctx, local); err != nil {
+		return err
+	}
+	if desired == DeletionConfirmationConfirmed {
+		r.record.Event(local, event.Normal(reasonDeletionConfirmed, "Remote cluster confirmed the deletion"))
+	}
+	return nil
+}
+
+// annotateDesired sets the desired spec annotation of the supplied local
+// instance to the spec of the supplied remote instance. The local instance is
+// only updated if the annotation changes.
+func (r *Reconciler) annotateDesired(ctx context.Context, local, remote *claim.Unstructured) error {
+	v, err := DesiredSpecAnnotation(remote, r.desiredSpecMax)
+	if err != nil {
+		return err
+	}
+	if current, ok := local.GetAnnotations()[AnnotationKeyDesiredSpec]; ok && current == v {
+		return nil
+	}
+	meta.AddAnnotations(local, map[string]string{AnnotationKeyDesiredSpec: v})
+	return r.local.Update(ctx, local)
+}
+
+// desired returns the remote instance that should be written for the supplied
+// local instance, which is the observed one as is if the Reconciler is
+// configured not to push the local instance at all.
+func (r *Reconciler) desired(ctx context.Context, local, observed *claim.Unstructured) (*claim.Unstructured, error) {
+	if r.disableSpec {
+		return &claim.Unstructured{Unstructured: *observed.GetUnstructured().DeepCopy()}, nil
+	}
+	return r.builder.Build(ctx, local, observed)
+}
+
+// oldFinalizers returns the previous finalizers of the Reconciler other than its
+// current one.
+func (r *Reconciler) oldFinalizers() []string {
+	prev := r.previousFinalizers
+	if r.finalizerName != DefaultFinalizer {
+		prev = append([]string{DefaultFinalizer}, prev...)
+	}
+	old := make([]string, 0, len(prev))
+	for _, f := range prev {
+		if f != r.finalizerName {
+			old = append(old, f)
+		}
+	}
+	return old
+}
+
+// addFinalizer adds the finalizer to the supplied local instance unless the
+// Reconciler doesn't manage finalizers.
+func (r *Reconciler) addFinalizer(ctx context.Context, local *claim.Unstructured) error {
+	if r.skipFinalizer {
+		return nil
+	}
+	return r.finalizer.AddFinalizer(ctx, local)
+}
 
-	log    logging.Logger
-	record event.Recorder
+// propagate runs the Propagator and returns the outcome of each Propagator of
+// it if the Reconciler records the last results.
+func (r *Reconciler) propagate(ctx context.Context, local, remote *claim.Unstructured) ([]PropagatorResult, error) {
+	pp, ok := r.Propagator.(PropagatorChain)
+	if !ok || r.results == nil {
+		return nil, r.Propagate(ctx, local, remote)
+	}
+	return propagateEach(ctx, pp, local, remote)
+}
+
+// groupMember returns the observed and desired remote instances of the supplied
+// member of the group of a local instance. The member is held to the same
+// rules as when it's reconciled on its own: its remote instance is located the
+// same way, it must not collide with others, an existing remote instance must
+// be managed by it or adoptable, and its finalizer is added before its remote
+// instance is written. Members that are filtered out are not applied.
+func (r *Reconciler) groupMember(ctx context.Context, m *claim.Unstructured) (*claim.Unstructured, *claim.Unstructured, error) {
+	if !r.filter(m) {
+		return nil, nil, nil
+	}
+	rgvk := r.aliases.Remote(m.GroupVersionKind())
+	if r.versions != nil {
+		v, err := r.versions.Negotiate(rgvk)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, remotePrefix+errNegotiateVersion)
+		}
+		rgvk = v
+	}
+	rnn, err := NewScopeNamer(r.scope, rgvk).RemoteName(m)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, remotePrefix+errResolveScope)
+	}
+	if r.collisions != nil {
+		c, err := r.collisions.Check(ctx, m)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, localPrefix+errDetectCollisions)
+		}
+		if c != nil {
+			return nil, nil, c.Err(types.NamespacedName{Namespace: m.GetNamespace(), Name: m.GetName()})
+		}
+	}
+	observed := r.newRemoteInstance()
+	observed.SetGroupVersionKind(rgvk)
+	if err := r.remote.Get(ctx, rnn, observed); runtimeresource.IgnoreNotFound(err) != nil {
+		return nil, nil, errors.Wrap(err, remotePrefix+errGetRequirement)
+	}
+	if meta.WasCreated(observed) && !IsManagedBy(observed, m) {
+		if err := r.adoptable(m, observed); err != nil {
+			return nil, nil, errors.Wrap(err, remotePrefix+errApplyClaim)
+		}
+	}
+	if err := r.addFinalizer(ctx, m); err != nil {
+		return nil, nil, errors.Wrap(err, localPrefix+errAddFinalizer)
+	}
+	desired, err := r.desired(ctx, m, observed)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errPush)
+	}
+	return observed, desired, nil
 }
 
 // Reconcile watches the given type and does necessary sync operations.
@@ -172,29 +1369,232 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 	log := r.log.WithValues("request", req)
 	log.Debug("Reconciling")
 
+	// We don't act on what may be a stale view of the local cluster, and try
+	// again once the cache had a chance to sync.
+	if r.cacheSynced != nil && !r.cacheSynced() {
+		log.Debug("Local cache is not synced", "requeue-after", time.Now().Add(tinyWait))
+		return reconcile.Result{RequeueAfter: tinyWait}, nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	// The remote requests are made for the namespace of the local instance,
+	// which a RemoteClientSelector routes them by.
+	ctx = WithLocalNamespace(ctx, req.Namespace)
+	if r.retryBudget > 0 {
+		ctx = WithRetryBudgetContext(ctx, NewRetryBudget(r.retryBudget))
+	}
 
 	// The reconciliation is triggered for the local claim instance, so, if it
 	// cannot be fetched for any reason, then that's a problem.
 	localClaim := r.newInstance()
+	key := localClaim.GroupVersionKind().String() + "/" + req.String()
 	if err := r.local.Get(ctx, req.NamespacedName, localClaim); err != nil {
 		if kerrors.IsNotFound(err) {
+			r.tracker.Forget(key)
+			r.failures.Forget(key)
+			if r.stale != nil {
+				r.stale.Forget(key)
+			}
+			if r.retries != nil {
+				r.retries.Forget(key)
+			}
+			if r.throttle != nil {
+				r.throttle.Forget(key)
+			}
 			return reconcile.Result{Requeue: false}, nil
 		}
 		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, localPrefix+errGetRequirement)
 	}
 
+	// Whatever the outcome of this reconciliation is, it's reflected in the
+	// sync condition of the local claim instance by the time we return. The
+	// condition is written once, replaced by the Stale one if the claim hasn't
+	// been synced successfully for too long. Claims are not reported as stale
+	// in maintenance mode.
+	var outcome *v1alpha1.Condition
+	updateStatus := func(ctx context.Context) error {
+		c := localClaim.GetCondition(resource.TypeAgentSync)
+		outcome = &c
+		if r.stale != nil && !r.inMaintenance() {
+			if since, stale := r.stale.Observe(key, c); stale && c.Reason != resource.ReasonAgentSyncStale {
+				localClaim.SetConditions(resource.AgentSyncStale().WithMessage(fmt.Sprintf(msgStaleFmt, since.UTC().Format(time.RFC3339), c.Message)))
+			}
+		}
+		return r.local.Status().Update(ctx, localClaim)
+	}
+	var propagated []PropagatorResult
+	defer func() {
+		// The outcome is observed as it was before the claim was reported as
+		// stale, so e.g. the backoff of a stale claim keeps growing with its
+		// failures.
+		c := localClaim.GetCondition(resource.TypeAgentSync)
+		if outcome != nil {
+			c = *outcome
+		}
+		r.tracker.Observe(key, c)
+		r.failures.Observe(key, c)
+		if r.retries != nil {
+			r.retries.Observe(key, localClaim, c)
+		}
+		if r.results != nil {
+			r.results.Record(ResultKey{GroupVersionKind: localClaim.GroupVersionKind(), NamespacedName: req.NamespacedName}, lastResult(c, propagated))
+		}
+	}()
+
+	// Nothing but the sync condition is written while in maintenance mode, not
+	// even the events or the finalizer of a deleted claim.
+	if r.inMaintenance() {
+		log.Debug("Skipping claim in maintenance mode", "requeue-after", time.Now().Add(longWait))
+		localClaim.SetConditions(resource.AgentSyncMaintenance().WithMessage("Agent is in maintenance mode"))
+		return reconcile.Result{RequeueAfter: longWait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+
+	// The claims that are not selected by the filter are not propagated at all.
+	// We still let deleted claims through so that the remote instances they
+	// may have created before being filtered out are cleaned up.
+	if !meta.WasDeleted(localClaim) && !r.filter(localClaim) {
+		log.Debug("Claim is filtered out", "requeue-after", time.Now().Add(longWait))
+		localClaim.SetConditions(resource.AgentSyncFilteredOut())
+		return reconcile.Result{RequeueAfter: longWait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+
+	// We stop retrying the claims that failed too many times in a row. They are
+	// retried once their spec or sync-now annotation changes.
+	if !meta.WasDeleted(localClaim) && r.retries != nil && r.retries.Exceeded(key, localClaim) {
+		err := errors.New(errMaxRetries)
+		log.Debug("Giving up on claim", "error", err)
+		r.record.Event(localClaim, event.Warning(reasonMaxRetriesExceeded, err))
+		localClaim.SetConditions(resource.AgentSyncMaxRetriesExceeded().WithMessage(err.Error()))
+		return reconcile.Result{}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+
+	// We don't touch a remote cluster that doesn't run a compatible version of
+	// Crossplane at all.
+	capabilities := r.capabilities
+	if c, ok := r.nsCapabilities[localClaim.GetNamespace()]; ok {
+		capabilities = c
+	}
+	if capabilities != nil {
+		if err := capabilities.Check(); err != nil {
+			wait := r.requeueAfterClass(key, ErrorClassPermanent, err)
+			log.Info("Remote cluster is not compatible", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonIncompatibleRemote, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errIncompatible)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+	}
+
+	// The remote instance is of the kind the local kind is aliased to, at the
+	// version negotiated with the remote cluster if any.
+	rgvk := r.aliases.Remote(localClaim.GroupVersionKind())
+	if r.versions != nil {
+		v, err := r.versions.Negotiate(rgvk)
+		if err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot negotiate remote version", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonIncompatibleVersion, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errNegotiateVersion)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		rgvk = v
+	}
+
+	// The remote instance is in the same namespace as the local one unless its
+	// kind has a different scope in the remote cluster.
+	rnn := req.NamespacedName
+	if r.scope != nil {
+		ns, err := r.scope.RemoteNamespace(rgvk, localClaim.GetNamespace())
+		if err != nil {
+			wait := r.requeueAfterClass(key, ErrorClassPermanent, err)
+			log.Debug("Cannot resolve remote scope", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonIncompatibleScope, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errResolveScope)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		rnn.Namespace = ns
+	}
+
+	// We don't sync the local instances that would be synced to the same
+	// remote instance as others. The deleted ones are let through since they
+	// only ever touch a remote instance they manage.
+	if r.collisions != nil && !meta.WasDeleted(localClaim) {
+		c, err := r.collisions.Check(ctx, localClaim)
+		if err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot detect remote name collisions", "error", err, "requeue-after", time.Now().Add(wait))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errDetectCollisions)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		if c != nil {
+			err := c.Err(req.NamespacedName)
+			log.Debug("Claim collides with others", "error", err, "requeue-after", time.Now().Add(longWait))
+			r.record.Event(localClaim, event.Warning(reasonNameCollision, err))
+			localClaim.SetConditions(resource.AgentSyncError(err))
+			return reconcile.Result{RequeueAfter: longWait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+	}
+
 	// We fetch the remote claim instance that corresponds to this one and ignore
 	// the NotFound error since this pass could be the first one where the remote
 	// instance will be created.
-	remoteClaim := r.newInstance()
-	err := r.remote.Get(ctx, req.NamespacedName, remoteClaim)
+	remoteClaim := r.newRemoteInstance()
+	remoteClaim.SetGroupVersionKind(rgvk)
+	err := r.remote.Get(ctx, rnn, remoteClaim)
 	if runtimeresource.IgnoreNotFound(err) != nil {
-		log.Debug("Cannot get resource from remote", "error", err, "requeue-after", time.Now().Add(shortWait))
+		wait := r.requeueAfter(key, err)
+		log.Debug("Cannot get resource from remote", "error", err, "requeue-after", time.Now().Add(wait))
 		r.record.Event(localClaim, event.Warning(reasonCannotGetFromRemote, err))
 		localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errGetRequirement)))
-		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+		return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+
+	// The local instance asked us to stop managing the remote instance while
+	// keeping it alive. We remove all our marks from the remote instance so
+	// that it is no longer considered managed by anyone, nor mistaken for one
+	// we wrote by an agent that adopts it later, and release the local instance
+	// whether or not it's being deleted. Connection secrets are left in place.
+	// Abandoning the remote instance of a deleted local instance is the same.
+	if Detaches(localClaim) || (meta.WasDeleted(localClaim) && r.deletionPolicy == RemoteDeletionPolicyAbandon) {
+		if meta.WasCreated(remoteClaim) && IsManagedBy(remoteClaim, localClaim) {
+			RemoveAgentMetadata(remoteClaim)
+			if err := r.remote.Update(ctx, remoteClaim); err != nil {
+				wait := r.requeueAfter(key, err)
+				log.Debug("Cannot detach remote instance", "error", err, "requeue-after", time.Now().Add(wait))
+				r.record.Event(localClaim, event.Warning(reasonCannotDetach, err))
+				localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errDetachClaim)))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+			r.record.Event(localClaim, event.Normal(reasonDetached, "Detached from remote instance"))
+		}
+		if err := r.finalizer.RemoveFinalizer(ctx, localClaim); err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot remove finalizer", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotRemoveFinalizer, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errRemoveFinalizer)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		if meta.WasDeleted(localClaim) {
+			return reconcile.Result{}, nil
+		}
+		localClaim.SetConditions(resource.AgentSyncSuccess().WithMessage("Detached from remote instance"))
+		return reconcile.Result{}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+
+	// The remote instance of a deleted local instance is left to whatever
+	// cleans up the remote cluster if we don't manage the finalizer. We only
+	// release the local instance if it still has a finalizer we added before,
+	// which the Finalizer doesn't update it for otherwise.
+	if meta.WasDeleted(localClaim) && r.skipFinalizer {
+		if err := r.finalizer.RemoveFinalizer(ctx, localClaim); err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot remove finalizer", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotRemoveFinalizer, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errRemoveFinalizer)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		log.Debug("Local instance is deleted and its remote instance is left in place since finalizers are not managed")
+		return reconcile.Result{}, nil
 	}
 
 	// If local claim instance is deleted, we need to clean up the remote instance
@@ -206,68 +1606,401 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 		// api-server once local instance is gone since we added our owner ref
 		// to it.
 		if kerrors.IsNotFound(err) {
+			if r.confirmDeletion != "" && localClaim.GetAnnotations()[AnnotationKeyDeletionConfirmation] == DeletionConfirmationPending {
+				err := errors.New(errNotConfirmed)
+				wait := r.requeueAfterClass(key, ErrorClassPermanent, err)
+				log.Debug("Deletion of remote resources is not confirmed", "requeue-after", time.Now().Add(wait))
+				r.record.Event(localClaim, event.Warning(reasonDeletionNotConfirmed, err))
+				localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errDeleteClaim)))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+			if err := r.cleanup(ctx, localClaim, rnn.Namespace); err != nil {
+				wait := r.requeueAfter(key, err)
+				log.Debug("Cannot clean up secrets", "error", err, "requeue-after", time.Now().Add(wait))
+				r.record.Event(localClaim, event.Warning(reasonCannotCleanup, err))
+				localClaim.SetConditions(resource.AgentSyncError(err))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+			if err := r.finalizer.RemoveFinalizer(ctx, localClaim); err != nil {
+				wait := r.requeueAfter(key, err)
+				log.Debug("Cannot remove finalizer", "error", err, "requeue-after", time.Now().Add(wait))
+				r.record.Event(localClaim, event.Warning(reasonCannotRemoveFinalizer, err))
+				localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errRemoveFinalizer)))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+			return reconcile.Result{}, nil
+		}
+
+		// The remote instance exists but we don't manage it, so it's not ours
+		// to delete. We only release the local instance. A remote instance
+		// that we would adopt, e.g. one written by a version of the agent that
+		// didn't record its local instance, is deleted as if we managed it.
+		if meta.WasCreated(remoteClaim) && !IsManagedBy(remoteClaim, localClaim) && r.adoptable(localClaim, remoteClaim) != nil {
+			if err := r.cleanup(ctx, localClaim, rnn.Namespace); err != nil {
+				wait := r.requeueAfter(key, err)
+				log.Debug("Cannot clean up secrets", "error", err, "requeue-after", time.Now().Add(wait))
+				r.record.Event(localClaim, event.Warning(reasonCannotCleanup, err))
+				localClaim.SetConditions(resource.AgentSyncError(err))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
 			if err := r.finalizer.RemoveFinalizer(ctx, localClaim); err != nil {
-				log.Debug("Cannot remove finalizer", "error", err, "requeue-after", time.Now().Add(shortWait))
+				wait := r.requeueAfter(key, err)
+				log.Debug("Cannot remove finalizer", "error", err, "requeue-after", time.Now().Add(wait))
 				r.record.Event(localClaim, event.Warning(reasonCannotRemoveFinalizer, err))
 				localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errRemoveFinalizer)))
-				return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
 			}
 			return reconcile.Result{}, nil
 		}
 
+		// The remote instance is kept until the grace period is over, so that
+		// its deletion can still be prevented in the remote cluster.
+		if deadline := localClaim.GetDeletionTimestamp().Add(r.deletionGrace); r.deletionGrace > 0 && time.Now().Before(deadline) {
+			remaining := time.Until(deadline)
+			log.Debug("Remote instance is kept for the deletion grace period", "requeue-after", deadline)
+			localClaim.SetConditions(resource.AgentSyncDeletionPending().WithMessage("Remote instance is kept until " + deadline.UTC().Format(time.RFC3339)))
+			return reconcile.Result{RequeueAfter: remaining}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+
+		// The remote instance may be gone long before the resources backing it
+		// are, so we record that we wait for their deletion to be confirmed
+		// before we request it, and that it's confirmed once the remote
+		// instance reports it while it's being deleted.
+		if r.confirmDeletion != "" {
+			if err := r.recordDeletion(ctx, localClaim, remoteClaim); err != nil {
+				wait := r.requeueAfter(key, err)
+				log.Debug("Cannot record deletion confirmation", "error", err, "requeue-after", time.Now().Add(wait))
+				r.record.Event(localClaim, event.Warning(reasonCannotDelete, err))
+				localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errRecordDeletion)))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+		}
+
 		// Start the deletion of remote instance and if it's already gone, that's
 		// not an error since that's what we'd like to achieve.
 		if err := r.remote.Delete(ctx, remoteClaim); runtimeresource.IgnoreNotFound(err) != nil {
-			log.Debug("Cannot delete local object", "error", err, "requeue-after", time.Now().Add(shortWait))
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot delete local object", "error", err, "requeue-after", time.Now().Add(wait))
 			r.record.Event(localClaim, event.Warning(reasonCannotDelete, err))
 			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errDeleteClaim)))
-			return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
 		}
 
 		// We have requested the deletion of the remote instance but that doesn't
 		// meant it's gone. So, we'll requeue and remove the finalizer only if we
 		// confirm that remote instance no longer exists.
 		localClaim.SetConditions(resource.AgentSyncSuccess().WithMessage("Deletion is successfully requested"))
-		return reconcile.Result{RequeueAfter: tinyWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+		return reconcile.Result{RequeueAfter: tinyWait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+
+	// The local instance may depend on other local instances of its kind that
+	// must be Ready before its remote instance is created, e.g. a database that
+	// needs its network. We only hold back the creation; a remote instance that
+	// exists is kept in sync even if its dependencies become unready.
+	if !meta.WasCreated(remoteClaim) && len(DependsOn(localClaim)) > 0 {
+		unready, err := r.dependencies.Unready(ctx, localClaim)
+		if err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot check dependencies", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotCheckDependency, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errCheckDependencies)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		if len(unready) > 0 {
+			msg := "Waiting for dependencies to be ready: " + strings.Join(unready, ", ")
+			log.Debug("Waiting for dependencies", "dependencies", unready, "requeue-after", time.Now().Add(shortWait))
+			r.record.Event(localClaim, event.Normal(reasonWaitingForDependency, msg))
+			localClaim.SetConditions(resource.AgentSyncWaiting().WithMessage(msg))
+			return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
 	}
 
 	// At this point, we will begin the operations that will need some cleanup in
 	// case of deletion, such as creation of remote correspondent. So, we add to a
 	// finalizer to local claim instance to block its deletion until this controller
 	// takes care of the cleanup.
-	if err := r.finalizer.AddFinalizer(ctx, localClaim); err != nil {
-		log.Debug("Cannot add finalizer", "error", err, "requeue-after", time.Now().Add(shortWait))
+	if err := r.addFinalizer(ctx, localClaim); err != nil {
+		wait := r.requeueAfter(key, err)
+		log.Debug("Cannot add finalizer", "error", err, "requeue-after", time.Now().Add(wait))
 		r.record.Event(localClaim, event.Warning(reasonCannotAddFinalizer, err))
 		localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, localPrefix+errAddFinalizer)))
-		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+		return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+
+	// The remote instance may exist without being created by this agent. We
+	// refuse to overwrite it unless the user explicitly allowed adoption of
+	// an unmanaged remote instance, or it was left behind by a version of the
+	// agent that didn't record its local instance. An instance that is
+	// managed by another local instance is never adopted.
+	adopting := false
+	if meta.WasCreated(remoteClaim) && !IsManagedBy(remoteClaim, localClaim) {
+		if err := r.adoptable(localClaim, remoteClaim); err != nil {
+			wait := r.requeueAfterClass(key, ErrorClassPermanent, err)
+			log.Debug("Cannot adopt remote instance", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotAdopt, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errApplyClaim)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		adopting = true
+		r.record.Event(localClaim, event.Normal(reasonAdopted, "Adopting unmanaged remote instance"))
+	}
+
+	// There is nothing to propagate back if we don't push the local instance
+	// and the remote one doesn't exist.
+	if r.disableSpec && !meta.WasCreated(remoteClaim) {
+		log.Debug("Spec propagation is disabled and remote instance does not exist", "requeue-after", time.Now().Add(longWait))
+		localClaim.SetConditions(resource.AgentSyncSuccess().WithMessage("Remote instance does not exist and spec propagation is disabled"))
+		return reconcile.Result{RequeueAfter: longWait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
 	}
 
 	// At this point, we are getting remote instance ready for Apply operation
 	// by configuring its fields.
-	if err := r.Configure(ctx, localClaim, remoteClaim); err != nil {
-		log.Debug("Cannot run configurator", "error", err, "requeue-after", time.Now().Add(shortWait))
+	observedClaim := remoteClaim
+	remoteClaim, err = r.desired(ctx, localClaim, observedClaim)
+	if IsInputSecretMissing(err) {
+		msg := localPrefix + err.Error()
+		log.Debug("Waiting for input secret", "error", err, "requeue-after", time.Now().Add(shortWait))
+		r.record.Event(localClaim, event.Normal(reasonWaitingForInputSecret, msg))
+		localClaim.SetConditions(resource.AgentSyncWaiting().WithMessage(msg))
+		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+	if err != nil {
+		wait := r.requeueAfter(key, err)
+		log.Debug("Cannot run configurator", "error", err, "requeue-after", time.Now().Add(wait))
 		r.record.Event(localClaim, event.Warning(reasonCannotConfigure, err))
 		localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, errPush)))
-		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+		return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
 	}
 
-	// We create/update the final form of the instance in the remote cluster.
-	if err := r.remote.Apply(ctx, remoteClaim); err != nil {
-		log.Debug("Cannot call Apply", "error", err, "requeue-after", time.Now().Add(shortWait))
-		r.record.Event(localClaim, event.Warning(reasonCannotApply, err))
-		localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, errApplyClaim)))
-		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+	// The desired spec is only a troubleshooting aid, so failing to show it
+	// doesn't hold back the sync.
+	if r.desiredSpecMax > 0 {
+		if err := r.annotateDesired(ctx, localClaim, remoteClaim); err != nil {
+			log.Debug("Cannot annotate desired spec", "error", err)
+		}
+	}
+
+	// We don't write a remote instance that its schema doesn't allow, since the
+	// errors of the fields are more actionable than a rejected write.
+	if r.schemas != nil && !r.disableSpec {
+		invalid, err := r.schemas.Validate(ctx, remoteClaim)
+		if err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot validate remote instance", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotValidate, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errValidateClaim)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		if len(invalid) > 0 {
+			err := errors.Wrap(invalid.ToAggregate(), errInvalidClaim)
+			wait := r.requeueAfterClass(key, ErrorClassPermanent, err)
+			log.Debug("Remote instance is invalid", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonInvalidSpec, err))
+			localClaim.SetConditions(resource.AgentSyncError(err))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+	}
+
+	// We create/update the final form of the instance in the remote cluster
+	// unless it's already in that form.
+	diverged := false
+	// The remote instance that wasn't written, e.g. while throttled, is not
+	// in sync even if everything else succeeds.
+	var skipped *v1alpha1.Condition
+	requeue := longWait
+	var cooldown time.Duration
+	if r.throttle != nil && meta.WasCreated(observedClaim) {
+		cooldown = r.throttle.Remaining(key, remoteClaim)
+	}
+	switch {
+	case r.disableSpec:
+		remoteClaim = observedClaim
+	case r.groups != nil && localClaim.GetLabels()[LabelKeyGroup] != "":
+		if err := r.groups.Apply(ctx, localClaim, observedClaim, remoteClaim); err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot apply group", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotApply, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, errApplyClaim)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+	case meta.WasCreated(observedClaim) && IsUpToDate(observedClaim, remoteClaim, r.unordered, r.equal):
+		// The input secrets may have changed even if the remote instance
+		// that refers to them didn't.
+		if err := r.mirrorInputSecrets(ctx, localClaim, remoteClaim); err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot mirror input secrets", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotApply, err))
+			localClaim.SetConditions(resource.AgentSyncError(err))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		remoteClaim = observedClaim
+	case cooldown > 0:
+		// The same remote instance was written moments ago, so something
+		// keeps reverting it. We wait for the cooldown rather than fighting.
+		requeue = cooldown
+		log.Debug("Remote instance was written recently", "requeue-after", time.Now().Add(requeue))
+		remoteClaim = observedClaim
+		c := resource.AgentSyncThrottled().WithMessage(msgThrottled)
+		skipped = &c
+	default:
+		intended := &claim.Unstructured{Unstructured: *remoteClaim.GetUnstructured().DeepCopy()}
+		if r.dryRun != nil {
+			// The dry-run writes into a copy so that the response of the
+			// remote cluster doesn't end up in what's written for real. A
+			// kind mismatch is left to the real write, which is refused the
+			// same way and reports it.
+			dry := &claim.Unstructured{Unstructured: *remoteClaim.GetUnstructured().DeepCopy()}
+			if err := r.dryRun.Apply(ctx, dry); err != nil && !IsKindMismatch(err) {
+				err = errors.Wrap(err, remotePrefix+errDryRunClaim)
+				wait := r.requeueAfter(key, err)
+				log.Debug("Remote instance was rejected by dry-run", "error", err, "requeue-after", time.Now().Add(wait))
+				r.record.Event(localClaim, event.Warning(reasonRejectedByDryRun, err))
+				localClaim.SetConditions(resource.AgentSyncError(err))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+		}
+		// The input secrets are written only once the remote instance that
+		// refers to them passed everything that may refuse it.
+		if err := r.mirrorInputSecrets(ctx, localClaim, remoteClaim); err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot mirror input secrets", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotApply, err))
+			localClaim.SetConditions(resource.AgentSyncError(err))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		// A patch, unlike server-side apply, doesn't remove the fields that
+		// are gone from the local instance unless it says so.
+		var ao []runtimeresource.ApplyOption
+		removed := RemovedSpecFields(observedClaim, remoteClaim)
+		removed = append(removed, RemovedLabels(observedClaim, remoteClaim)...)
+		// The records of the pushed labels and entries are removed along with
+		// the last of them, and the annotations that were propagated before
+		// they were truncated are removed too.
+		keys := append([]string{AnnotationKeyManagedLabels, AnnotationKeyManagedEntries, AnnotationKeyTruncatedAnnotations}, r.passthrough...)
+		keys = append(keys, truncatedAnnotations(remoteClaim)...)
+		removed = append(removed, RemovedAnnotations(observedClaim, remoteClaim, keys)...)
+		if r.fieldManager == "" && len(removed) > 0 {
+			ao = append(ao, removingFields(removed))
+		}
+		err = r.remote.Apply(ctx, remoteClaim, ao...)
+		if IsConflict(err) && r.conflicts != nil {
+			resolution := r.conflicts.HandleConflict(ctx, Conflict{Local: localClaim, Observed: observedClaim, Desired: intended, Err: errors.Cause(err)})
+			log.Debug("Remote instance conflicted", "error", err, "action", resolution.Action)
+			if resolution.Action == ConflictActionSkip {
+				r.record.Event(localClaim, event.Normal(reasonConflictSkipped, msgConflictSkipped))
+				remoteClaim = observedClaim
+				c := resource.AgentSyncConflictSkipped().WithMessage(msgConflictSkipped)
+				skipped = &c
+				break
+			}
+			if resolution.Action == ConflictActionRetry && resolution.RetryAfter > 0 {
+				r.record.Event(localClaim, event.Warning(reasonCannotApply, err))
+				localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, errApplyClaim)))
+				return reconcile.Result{RequeueAfter: resolution.RetryAfter}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+			if resolution.Action == ConflictActionForce {
+				// The write is unconditional without a resource version.
+				remoteClaim = &claim.Unstructured{Unstructured: *intended.GetUnstructured().DeepCopy()}
+				remoteClaim.SetResourceVersion("")
+				err = r.remote.Apply(ctx, remoteClaim, ao...)
+			}
+		}
+		// The object at the location of the remote instance may be of another
+		// kind, e.g. one left behind by a migration that the remote cluster
+		// serves under the same name. It's never overwritten, and only a user
+		// can resolve it.
+		if IsKindMismatch(err) {
+			err = errors.Wrap(err, remotePrefix+errKindMismatch)
+			wait := r.requeueAfterClass(key, ErrorClassPermanent, err)
+			log.Debug("Remote object is of another kind", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonKindMismatch, err))
+			localClaim.SetConditions(resource.AgentSyncKindMismatch().WithMessage(err.Error()))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		if err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot call Apply", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotApply, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, errApplyClaim)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		if r.throttle != nil {
+			r.throttle.Record(key, intended)
+		}
+		// The remote instance records its local instance once it's adopted,
+		// so the permission to adopt is withdrawn lest it's used again later.
+		if adopting && AllowsAdoption(localClaim) {
+			meta.RemoveAnnotations(localClaim, AnnotationKeyAdopt)
+			if err := r.local.Update(ctx, localClaim); err != nil {
+				err = errors.Wrap(err, localPrefix+errUpdateClaim)
+				wait := r.requeueAfter(key, err)
+				log.Debug("Cannot withdraw adoption", "error", err, "requeue-after", time.Now().Add(wait))
+				localClaim.SetConditions(resource.AgentSyncError(err))
+				return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+			}
+		}
+		if r.audit != nil {
+			if err := r.audit.Record(localClaim, observedClaim, remoteClaim); err != nil {
+				log.Info("Cannot record change of remote instance", "error", err)
+			}
+		}
+		if r.verify == "" {
+			break
+		}
+
+		// We read the remote instance back rather than trusting the response
+		// of the write, so that we see what's actually stored.
+		if err := r.remote.Get(ctx, rnn, remoteClaim); err != nil {
+			wait := r.requeueAfter(key, err)
+			log.Debug("Cannot read back remote instance", "error", err, "requeue-after", time.Now().Add(wait))
+			r.record.Event(localClaim, event.Warning(reasonCannotGetFromRemote, err))
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errVerifyClaim)))
+			return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		if !Diverges(localClaim, intended, remoteClaim, r.policies, r.unordered, r.equal) {
+			break
+		}
+		err := errors.New(errSpecDiverged)
+		log.Debug("Remote instance diverged after it was written", "mode", r.verify)
+		r.record.Event(localClaim, event.Warning(reasonSpecDiverged, err))
+		if r.verify == VerifyModeFail {
+			localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, remotePrefix+errVerifyClaim)))
+			return reconcile.Result{RequeueAfter: r.requeueAfter(key, err)}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+		}
+		diverged = true
 	}
 
 	// At this point, we have the remote instance in the remote cluster and the
 	// variable "remote" is updated. So, we will propagate new information from
 	// "remote" to "local"
-	if err := r.Propagate(ctx, localClaim, remoteClaim); err != nil {
-		log.Debug("Cannot run propagator", "error", err, "requeue-after", time.Now().Add(shortWait))
+	propagated, err = r.propagate(ctx, localClaim, remoteClaim)
+	if err != nil {
+		wait := r.requeueAfter(key, err)
+		log.Debug("Cannot run propagator", "error", err, "requeue-after", time.Now().Add(wait))
 		r.record.Event(localClaim, event.Warning(reasonCannotPropagate, err))
 		localClaim.SetConditions(resource.AgentSyncError(errors.Wrap(err, errPull)))
-		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), errStatusUpdateClaim)
+		return reconcile.Result{RequeueAfter: wait}, errors.Wrap(updateStatus(ctx), errStatusUpdateClaim)
+	}
+	if diverged {
+		localClaim.SetConditions(resource.AgentSyncDiverged().WithMessage(errSpecDiverged))
+		return reconcile.Result{RequeueAfter: longWait}, errors.Wrap(updateStatus(ctx), localPrefix+errStatusUpdateClaim)
+	}
+	if skipped != nil {
+		localClaim.SetConditions(*skipped)
+		return reconcile.Result{RequeueAfter: requeue}, errors.Wrap(updateStatus(ctx), localPrefix+errStatusUpdateClaim)
+	}
+	synced := resource.AgentSyncSuccess()
+	if r.events != nil {
+		// The remote events only add to the message, so failing to read them
+		// doesn't fail the sync.
+		e, err := r.events.LatestWarning(ctx, remoteClaim)
+		if err != nil {
+			log.Info("Cannot read events of remote instance", "error", err)
+		}
+		if e != nil {
+			synced = synced.WithMessage(RemoteEventMessage(e))
+		}
 	}
-	localClaim.SetConditions(resource.AgentSyncSuccess())
-	return reconcile.Result{RequeueAfter: longWait}, errors.Wrap(r.local.Status().Update(ctx, localClaim), localPrefix+errStatusUpdateClaim)
+	localClaim.SetConditions(synced)
+	return reconcile.Result{RequeueAfter: requeue}, errors.Wrap(updateStatus(ctx), localPrefix+errStatusUpdateClaim)
 }
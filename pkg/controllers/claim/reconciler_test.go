@@ -18,19 +18,26 @@ package claim
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
 	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
@@ -49,6 +56,8 @@ func TestReconcile(t *testing.T) {
 	type args struct {
 		m      manager.Manager
 		remote client.Client
+		gvk    schema.GroupVersionKind
+		req    reconcile.Request
 		opts   []ReconcilerOption
 	}
 	type want struct {
@@ -80,6 +89,89 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 		},
+		"FilteredOut": {
+			reason: "Claims that do not pass the filter should not be propagated",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetConditions(resource.AgentSyncFilteredOut())
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "Claims that do not pass the filter should not be propagated"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+					t.Errorf("\nReason: %s\nRemote should not be called", "Claims that do not pass the filter should not be propagated")
+					return nil
+				}},
+				opts: []ReconcilerOption{
+					WithFilter(func(_ *claim.Unstructured) bool { return false }),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"RemoteIncompatible": {
+			reason: "Claims should not be propagated to a remote cluster that does not run Crossplane",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errors.Errorf(errMissingGroupsFmt, crossplaneAPIExtGroup), remotePrefix+errIncompatible)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "Claims should not be propagated to a remote cluster that does not run Crossplane"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{},
+				opts:   []ReconcilerOption{WithCapabilityChecker(NewCapabilityChecker(newFakeDiscovery("v1")))},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"RemoteIncompatibleInNamespace": {
+			reason: "Claims should not be propagated to the remote cluster of their namespace if it does not run Crossplane",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							obj.(*unstructured.Unstructured).SetNamespace("team-a")
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetNamespace("team-a")
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errors.Errorf(errMissingGroupsFmt, crossplaneAPIExtGroup), remotePrefix+errIncompatible)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "Claims should not be propagated to the remote cluster of their namespace if it does not run Crossplane"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{},
+				opts: []ReconcilerOption{WithNamespaceCapabilityCheckers(map[string]*CapabilityChecker{
+					"team-a": NewCapabilityChecker(newFakeDiscovery("v1")),
+				})},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
 		"RemoteGetFailed": {
 			reason: "An error should be returned if remote claim cannot be retrieved",
 			args: args{
@@ -103,6 +195,86 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{RequeueAfter: shortWait},
 			},
 		},
+		"KindAliased": {
+			reason: "The remote claim should be fetched as the kind the local kind is aliased to",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet:          test.NewMockGetFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if diff := cmp.Diff(aliasedGVK, obj.GetObjectKind().GroupVersionKind()); diff != "" {
+						t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote claim should be fetched as the aliased kind", diff)
+					}
+					return errBoom
+				}},
+				gvk:  scopedGVK,
+				opts: []ReconcilerOption{WithGVKAliases(GVKAliases{scopedGVK: aliasedGVK})},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"RemoteGetThrottled": {
+			reason: "The delay suggested by the remote should be respected if configured",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet:          test.NewMockGetFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewTooManyRequests("slow down", 42))},
+				opts:   []ReconcilerOption{WithRemoteRetryAfter()},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: 42 * time.Second},
+			},
+		},
+		"RemoteGetThrottledNotRespected": {
+			reason: "The delay suggested by the remote should be ignored if not configured",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet:          test.NewMockGetFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewTooManyRequests("slow down", 42))},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"ApplyThrottled": {
+			reason: "The delay suggested by the remote for a failed Apply should be respected if configured",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet:          test.NewMockGetFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet:   test.NewMockGetFn(nil),
+					MockPatch: test.NewMockPatchFn(kerrors.NewTooManyRequests("slow down", 7)),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithConfigurator(ConfigureFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+					WithRemoteRetryAfter(),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: 7 * time.Second},
+			},
+		},
 		"RemoteNotFoundAndDeleted": {
 			reason: "No error should be returned if deletion is requested and the remote claim is gone",
 			args: args{
@@ -124,6 +296,48 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 		},
+		"LocalSecretsCleanedUp": {
+			reason: "The local connection secret should be deleted before the finalizer is removed if configured",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							switch o := obj.(type) {
+							case *unstructured.Unstructured:
+								l := claim.New(claim.WithGroupVersionKind(gvk))
+								l.SetUID("local-uid")
+								l.SetDeletionTimestamp(&now)
+								l.SetWriteConnectionSecretToReference(&v1alpha1.LocalSecretReference{Name: "conn"})
+								l.DeepCopyInto(o)
+							case *corev1.Secret:
+								o.SetLabels(map[string]string{LabelKeyManagedBy: LabelValueManagedBy, LabelKeyOwnerUID: "local-uid"})
+							}
+							return nil
+						},
+						MockDelete: test.NewMockDeleteFn(errBoom),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+							want := resource.AgentSyncError(errors.Wrap(errBoom, localPrefix+errDeleteSecret))
+							if diff := cmp.Diff(want, got.GetCondition(resource.TypeAgentSync), test.EquateConditions()); diff != "" {
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local connection secret should be deleted before the finalizer is removed if configured", diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						t.Errorf("\nReason: %s\nFinalizer should not be removed", "The finalizer should not be removed if the local connection secret cannot be deleted")
+						return nil
+					}}),
+					WithLocalSecretCleanup(),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
 		"RemoveFinalizerFailed": {
 			reason: "Error during finalizer removal should be propagated",
 			args: args{
@@ -160,6 +374,84 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{RequeueAfter: shortWait},
 			},
 		},
+		"RemoteNotManagedAndDeleted": {
+			reason: "The remote claim should not be deleted if it is not managed by the local claim",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetDeletionTimestamp(&now)
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+						t.Errorf("\nReason: %s\nDelete should not be called", "The remote claim should not be deleted if it is not managed by the local claim")
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+				},
+			},
+		},
+		"LegacyRemoteDeleted": {
+			reason: "The remote claim should be deleted if it was written for the local claim by a version of the agent that didn't record the local claim",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetDeletionTimestamp(&now)
+							l.SetLabels(map[string]string{"app": "db"})
+							l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+							want := resource.AgentSyncSuccess().WithMessage("Deletion is successfully requested")
+							if diff := cmp.Diff(want, got, test.EquateConditions()); diff != "" {
+								reason := "The remote claim should be deleted if it was written for the local claim by a version of the agent that didn't record the local claim"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.SetLabels(map[string]string{"app": "db"})
+						r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockDelete: test.NewMockDeleteFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						t.Errorf("\nReason: %s\nRemoveFinalizer should not be called before the remote claim is gone", "The remote claim should be deleted if it was written for the local claim by a version of the agent that didn't record the local claim")
+						return nil
+					}}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: tinyWait},
+			},
+		},
 		"RemoteFoundAndDeletionFailed": {
 			reason: "The error should be returned if deletion call fails",
 			args: args{
@@ -228,109 +520,2443 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{RequeueAfter: tinyWait},
 			},
 		},
-		"AddFinalizerFailed": {
-			reason: "An error should be returned if finalizer cannot be added",
+		"Detached": {
+			reason: "The remote claim should be left intact but stripped of all agent annotations and labels, and the finalizer removed, if the local claim detaches",
 			args: args{
 				m: &fake.Manager{
 					Client: &test.MockClient{
-						MockGet: test.NewMockGetFn(nil),
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("luid")
+							l.SetAnnotations(map[string]string{AnnotationKeyDetach: "true"})
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
 						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
 							want := claim.New(claim.WithGroupVersionKind(gvk))
-							want.SetConditions(resource.AgentSyncError(errors.Wrap(errBoom, localPrefix+errAddFinalizer)))
+							want.SetUID("luid")
+							want.SetAnnotations(map[string]string{AnnotationKeyDetach: "true"})
+							want.SetConditions(resource.AgentSyncSuccess().WithMessage("Detached from remote instance"))
 							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
-								reason := "An error should be returned if finalizer cannot be added"
-								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local claim should report that it is detached", diff)
 							}
 							return nil
 						},
 					},
 				},
-				remote: &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.SetAnnotations(map[string]string{
+							AnnotationKeyLocalUID:      "luid",
+							AnnotationKeyLocalName:     "cool-db",
+							AnnotationKeyManagedLabels: "cool",
+							AnnotationKeyManagedSpec:   `["spec.size"]`,
+							"cool":                     "annotation",
+						})
+						r.SetLabels(map[string]string{LabelKeyOriginCluster: "local", "cool": "label"})
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						want := claim.New(claim.WithGroupVersionKind(gvk))
+						want.SetCreationTimestamp(now)
+						want.SetAnnotations(map[string]string{"cool": "annotation"})
+						want.SetLabels(map[string]string{"cool": "label"})
+						if diff := cmp.Diff(want.GetUnstructured(), obj); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote claim should only lose the bookkeeping of the agent", diff)
+						}
+						return nil
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+						t.Errorf("\nReason: %s\nDelete should not be called", "A detached remote claim should not be deleted")
+						return nil
+					},
+				},
 				opts: []ReconcilerOption{
-					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
-						return errBoom
+					WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
 					}}),
 				},
 			},
-			want: want{
-				result: reconcile.Result{RequeueAfter: shortWait},
-			},
 		},
-		"PropagatorFailed": {
-			reason: "An error should be returned if propagator fails",
+		"DetachedAndDeleted": {
+			reason: "The remote claim should not be deleted if the local claim is deleted after it detaches",
 			args: args{
 				m: &fake.Manager{
 					Client: &test.MockClient{
-						MockGet: test.NewMockGetFn(nil),
-						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
-							want := claim.New(claim.WithGroupVersionKind(gvk))
-							want.SetConditions(resource.AgentSyncError(errors.Wrap(errBoom, errPush)))
-							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
-								reason := "An error should be returned if propagator fails"
-								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
-							}
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("luid")
+							l.SetDeletionTimestamp(&now)
+							l.SetAnnotations(map[string]string{AnnotationKeyDetach: "true"})
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
 							return nil
 						},
 					},
 				},
-				remote: &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+						t.Errorf("\nReason: %s\nDelete should not be called", "A detached remote claim should not be deleted")
+						return nil
+					},
+				},
 				opts: []ReconcilerOption{
-					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+					WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
 						return nil
 					}}),
-					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
-						return errBoom
-					})),
 				},
 			},
-			want: want{
-				result: reconcile.Result{RequeueAfter: shortWait},
-			},
 		},
-		"Successful": {
-			reason: "No error should be returned if everything goes well.",
+		"DetachFailed": {
+			reason: "The finalizer should be kept if the remote claim cannot be unstamped",
 			args: args{
 				m: &fake.Manager{
 					Client: &test.MockClient{
-						MockGet: test.NewMockGetFn(nil),
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("luid")
+							l.SetAnnotations(map[string]string{AnnotationKeyDetach: "true"})
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
 						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
 							want := claim.New(claim.WithGroupVersionKind(gvk))
-							want.SetConditions(resource.AgentSyncSuccess())
+							want.SetUID("luid")
+							want.SetAnnotations(map[string]string{AnnotationKeyDetach: "true"})
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errBoom, remotePrefix+errDetachClaim)))
 							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
-								reason := "No error should be returned if everything goes well."
-								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local claim should report the detach error", diff)
 							}
 							return nil
 						},
 					},
 				},
-				remote: &test.MockClient{MockGet: test.NewMockGetFn(nil)},
-				opts: []ReconcilerOption{
-					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid"})
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
 						return nil
-					}}),
+					},
+					MockUpdate: test.NewMockUpdateFn(errBoom),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						t.Errorf("\nReason: %s\nRemoveFinalizer should not be called", "The finalizer should be kept if the remote claim cannot be unstamped")
+						return nil
+					}}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"WaitingForDependency": {
+			reason: "The remote claim should not be created until the local claims it depends on are ready",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+							if key.Name == "network" {
+								return kerrors.NewNotFound(schema.GroupResource{}, "")
+							}
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetAnnotations(map[string]string{AnnotationKeyDependsOn: "network"})
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetAnnotations(map[string]string{AnnotationKeyDependsOn: "network"})
+							want.SetConditions(resource.AgentSyncWaiting().WithMessage("Waiting for dependencies to be ready: network"))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "The remote claim should not be created until the local claims it depends on are ready"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"AddFinalizerFailed": {
+			reason: "An error should be returned if finalizer cannot be added",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errBoom, localPrefix+errAddFinalizer)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if finalizer cannot be added"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return errBoom
+					}}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"UnmanagedRemoteNotAdopted": {
+			reason: "An error should be returned if remote claim exists, is not managed and adoption is not allowed",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errors.New(errNotManaged), remotePrefix+errApplyClaim)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if remote claim exists, is not managed and adoption is not allowed"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				}},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"RemoteManagedByOther": {
+			reason: "An error should be returned if remote claim is managed by another local claim even if adoption is allowed",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetAnnotations(map[string]string{AnnotationKeyAdopt: "true"})
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetAnnotations(map[string]string{AnnotationKeyAdopt: "true"})
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errors.New(errManagedByOther), remotePrefix+errApplyClaim)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if remote claim is managed by another local claim even if adoption is allowed"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "other-uid"})
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				}},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"UnmanagedRemoteAdopted": {
+			reason: "An unmanaged remote claim should be adopted and stamped if adoption is allowed, and the permission to adopt withdrawn",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.SetAnnotations(map[string]string{AnnotationKeyAdopt: "true"})
+							l.Object["spec"] = map[string]interface{}{}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							if _, ok := obj.(*unstructured.Unstructured).GetAnnotations()[AnnotationKeyAdopt]; ok {
+								reason := "The permission to adopt should be withdrawn once the remote claim is adopted"
+								t.Errorf("\nReason: %s\nannotation %s was not removed", reason, AnnotationKeyAdopt)
+							}
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+						data, _ := p.Data(obj)
+						u := &unstructured.Unstructured{}
+						_ = u.UnmarshalJSON(data)
+						if diff := cmp.Diff("local-uid", u.GetAnnotations()[AnnotationKeyLocalUID]); diff != "" {
+							reason := "An unmanaged remote claim should be adopted and stamped if adoption is allowed"
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+						}
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"AdoptionWithdrawalFailed": {
+			reason: "An error should be returned if the permission to adopt cannot be withdrawn once the remote claim is adopted",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetAnnotations(map[string]string{AnnotationKeyAdopt: "true"})
+							l.Object["spec"] = map[string]interface{}{}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockUpdate: test.NewMockUpdateFn(errBoom),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+							want := resource.AgentSyncError(errors.Wrap(errBoom, localPrefix+errUpdateClaim))
+							if diff := cmp.Diff(want, got, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if the permission to adopt cannot be withdrawn once the remote claim is adopted"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: test.NewMockPatchFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"LegacyRemoteAdopted": {
+			reason: "An unmanaged remote claim with all labels of the local claim should be adopted if legacy adoption is enabled",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.SetLabels(map[string]string{"app": "db"})
+							l.Object["spec"] = map[string]interface{}{}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.SetLabels(map[string]string{"app": "db"})
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+						data, _ := p.Data(obj)
+						u := &unstructured.Unstructured{}
+						_ = u.UnmarshalJSON(data)
+						if diff := cmp.Diff("local-uid", u.GetAnnotations()[AnnotationKeyLocalUID]); diff != "" {
+							reason := "An unmanaged remote claim with all labels of the local claim should be adopted if legacy adoption is enabled"
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+						}
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+					WithLegacyAdoption(true),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"LegacyRemoteLabelsMismatch": {
+			reason: "An error should be returned if an unmanaged remote claim lacks labels of the local claim even if legacy adoption is enabled",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetLabels(map[string]string{"app": "db"})
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+							want := resource.AgentSyncError(errors.Wrap(errors.New(errNotManaged), remotePrefix+errApplyClaim))
+							if diff := cmp.Diff(want, got, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if an unmanaged remote claim lacks labels of the local claim even if legacy adoption is enabled"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetLabels(map[string]string{"app": "web"})
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				}},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithLegacyAdoption(true),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"LegacyRemoteAdoptedByDefault": {
+			reason: "A remote claim written by a version of the agent that didn't record the local claim should be adopted with the default options",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.SetLabels(map[string]string{"app": "db"})
+							l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(now)
+						r.SetLabels(map[string]string{"app": "db"})
+						r.Object["spec"] = map[string]interface{}{"size": int64(10), "tier": "standard"}
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+						data, _ := p.Data(obj)
+						u := &unstructured.Unstructured{}
+						_ = u.UnmarshalJSON(data)
+						if diff := cmp.Diff("local-uid", u.GetAnnotations()[AnnotationKeyLocalUID]); diff != "" {
+							reason := "A remote claim written by a version of the agent that didn't record the local claim should be adopted with the default options"
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+						}
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"LegacyRemoteWithoutLabels": {
+			reason: "An error should be returned if the local claim of an unmanaged remote claim has no labels even if legacy adoption is enabled",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+							want := resource.AgentSyncError(errors.Wrap(errors.New(errNotManaged), remotePrefix+errApplyClaim))
+							if diff := cmp.Diff(want, got, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if the local claim of an unmanaged remote claim has no labels even if legacy adoption is enabled"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetLabels(map[string]string{"app": "web"})
+					r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				}},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithLegacyAdoption(true),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"LegacyRemoteSpecMismatch": {
+			reason: "An error should be returned if an unmanaged remote claim lacks the spec of the local claim even if legacy adoption is enabled",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetLabels(map[string]string{"app": "db"})
+							l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+							want := resource.AgentSyncError(errors.Wrap(errors.New(errNotManaged), remotePrefix+errApplyClaim))
+							if diff := cmp.Diff(want, got, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if an unmanaged remote claim lacks the spec of the local claim even if legacy adoption is enabled"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetLabels(map[string]string{"app": "db"})
+					r.Object["spec"] = map[string]interface{}{"size": int64(20)}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				}},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithLegacyAdoption(true),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"ConfiguratorFailed": {
+			reason: "An error should be returned if configurator fails",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errBoom, errPush)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if configurator fails"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithConfigurator(ConfigureFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return errBoom
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"PropagatorFailed": {
+			reason: "An error should be returned if propagator fails",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errBoom, errPull)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "An error should be returned if propagator fails"
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{
+					MockGet:   test.NewMockGetFn(nil),
+					MockPatch: test.NewMockPatchFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithConfigurator(ConfigureFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return errBoom
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+		"LateInitializationDisabled": {
+			reason: "The local claim should not be updated if late initialization is disabled",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.Object["spec"] = map[string]interface{}{}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+							t.Errorf("\nReason: %s\nUpdate should not be called", "The local claim should not be updated if late initialization is disabled")
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.Object["spec"] = map[string]interface{}{
+							"compositionRef": map[string]interface{}{"name": "remote-default"},
+						}
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: test.NewMockPatchFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithoutLateInitialization(),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"SpecPropagationDisabled": {
+			reason: "The remote claim should not be written but still be propagated if spec propagation is disabled",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.Object["spec"] = map[string]interface{}{"size": "large"}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(metav1.Now())
+						r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+						r.Object["spec"] = map[string]interface{}{"size": "small"}
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: func(_ context.Context, _ runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+						t.Errorf("\nReason: %s\nPatch should not be called", "The remote claim should not be written if spec propagation is disabled")
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithConfigurator(ConfigureFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						t.Errorf("\nReason: %s\nConfigure should not be called", "The remote claim should not be configured if spec propagation is disabled")
+						return nil
+					})),
+					WithPropagator(PropagateFn(func(_ context.Context, _, remote *claim.Unstructured) error {
+						if diff := cmp.Diff(map[string]interface{}{"size": "small"}, remote.Object["spec"]); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The observed remote claim should be propagated if spec propagation is disabled", diff)
+						}
+						return nil
+					})),
+					WithSpecPropagation(false),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"SpecPropagationDisabledRemoteNotFound": {
+			reason: "Nothing should be propagated if spec propagation is disabled and the remote claim does not exist",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet:          test.NewMockGetFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+					MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error {
+						t.Errorf("\nReason: %s\nCreate should not be called", "The remote claim should not be created if spec propagation is disabled")
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						t.Errorf("\nReason: %s\nPropagate should not be called", "Nothing should be propagated if the remote claim does not exist")
+						return nil
+					})),
+					WithSpecPropagation(false),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"RemoteScopeIncompatible": {
+			reason: "Claims should not be propagated if their kind is cluster-scoped locally but namespaced remotely without a remote namespace",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(scopedGVK))
+							want.SetConditions(resource.AgentSyncError(errors.Wrap(errors.Errorf(errScopeMismatchFmt, scopedGVK.Kind), remotePrefix+errResolveScope)))
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", "The scope mismatch should be reported in the sync condition", diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+					t.Errorf("\nReason: %s\nRemote should not be called", "Claims with incompatible scope should not be propagated")
+					return nil
+				}},
+				gvk: scopedGVK,
+				opts: []ReconcilerOption{
+					WithScopeResolver(NewScopeResolver(newScopedDiscovery(true))),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"RemoteClusterScoped": {
+			reason: "The remote claim should be fetched and applied without a namespace if its kind is cluster-scoped remotely",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+							l.SetName("cool-claim")
+							l.SetNamespace("cool-ns")
+							l.Object["spec"] = map[string]interface{}{}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+						if diff := cmp.Diff(client.ObjectKey{Name: "cool-claim"}, key); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote claim should be fetched without a namespace", diff)
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, "")
+					},
+					MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+						if diff := cmp.Diff("", obj.(*unstructured.Unstructured).GetNamespace()); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote claim should be created without a namespace", diff)
+						}
+						return nil
+					},
+				},
+				gvk: scopedGVK,
+				req: reconcile.Request{NamespacedName: client.ObjectKey{Name: "cool-claim", Namespace: "cool-ns"}},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+					WithScopeResolver(NewScopeResolver(newScopedDiscovery(false))),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"StatusSubresource": {
+			reason: "The remote claim should be written without status and the local status should be written via the status subresource",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.Object["spec"] = map[string]interface{}{"size": "large"}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+							t.Errorf("\nReason: %s\nUpdate should not be called", "The local status should be written only via the status subresource")
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+							if diff := cmp.Diff(v1alpha1.Available(), got.GetCondition(v1alpha1.TypeReady), test.EquateConditions()); diff != "" {
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote status should be written via the local status subresource", diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(metav1.Now())
+						r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+						r.Object["spec"] = map[string]interface{}{"size": "small"}
+						r.SetConditions(v1alpha1.Available())
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+						data, err := p.Data(obj)
+						if err != nil {
+							return err
+						}
+						body := map[string]interface{}{}
+						if err := json.Unmarshal(data, &body); err != nil {
+							return err
+						}
+						if _, ok := body["status"]; ok {
+							t.Errorf("\nReason: %s\nPatch should not have status", "The status of the remote claim should not be written by Apply")
+						}
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"UnorderedArrayReordered": {
+			reason: "A reordered remote array should not cause an Apply if its order is not significant",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.Object["spec"] = map[string]interface{}{"tags": []interface{}{"a", "b"}}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(metav1.Now())
+						r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid", AnnotationKeyManagedSpec: `["spec.tags"]`})
+						r.Object["spec"] = map[string]interface{}{"tags": []interface{}{"b", "a"}}
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: func(_ context.Context, _ runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+						t.Errorf("\nReason: %s\nPatch should not be called", "A reordered remote array should not cause an Apply if its order is not significant")
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+					WithUnorderedArrays([]string{"spec.tags"}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+				err:    nil,
+			},
+		},
+		"OrderedArrayReordered": {
+			reason: "A reordered remote array should cause an Apply if its order is significant",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.Object["spec"] = map[string]interface{}{"tags": []interface{}{"a", "b"}}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.SetCreationTimestamp(metav1.Now())
+						r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+						r.Object["spec"] = map[string]interface{}{"tags": []interface{}{"b", "a"}}
+						r.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockPatch: test.NewMockPatchFn(errBoom),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+				err:    nil,
+			},
+		},
+		"SecretPropagationSkipped": {
+			reason: "Spec and status should still be propagated if secret propagation is skipped",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+							l := claim.New(claim.WithGroupVersionKind(gvk))
+							l.SetUID("local-uid")
+							l.SetAnnotations(map[string]string{AnnotationKeySkipSecret: "true"})
+							l.Object["spec"] = map[string]interface{}{
+								"writeConnectionSecretToRef": map[string]interface{}{"name": "conn"},
+							}
+							l.DeepCopyInto(obj.(*unstructured.Unstructured))
+							return nil
+						},
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							got := &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+							if diff := cmp.Diff(v1alpha1.Available(), got.GetCondition(v1alpha1.TypeReady), test.EquateConditions()); diff != "" {
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", "Status should be propagated if secret propagation is skipped", diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						u, ok := obj.(*unstructured.Unstructured)
+						if !ok {
+							t.Errorf("\nReason: %s\nOnly the claim should be read from remote", "Secret should not be propagated if skipped")
+							return nil
+						}
+						r := claim.New(claim.WithGroupVersionKind(gvk))
+						r.Object["spec"] = map[string]interface{}{
+							"writeConnectionSecretToRef": map[string]interface{}{"name": "conn"},
+						}
+						r.SetConditions(v1alpha1.Available())
+						r.DeepCopyInto(u)
+						return nil
+					},
+					MockPatch: test.NewMockPatchFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+		"Successful": {
+			reason: "No error should be returned if everything goes well.",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+							want := claim.New(claim.WithGroupVersionKind(gvk))
+							want.SetConditions(resource.AgentSyncSuccess())
+							if diff := cmp.Diff(want.GetUnstructured(), obj, test.EquateConditions()); diff != "" {
+								reason := "No error should be returned if everything goes well."
+								t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+							}
+							return nil
+						},
+					},
+				},
+				remote: &test.MockClient{
+					MockGet:   test.NewMockGetFn(nil),
+					MockPatch: test.NewMockPatchFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+						return nil
+					}}),
+					WithConfigurator(ConfigureFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+						return nil
+					})),
 					WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
 						return nil
-					})),
+					})),
+					WithFilter(func(_ *claim.Unstructured) bool { return true }),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := NewReconciler(tc.args.m, tc.args.remote, tc.args.gvk, tc.args.opts...)
+			got, err := r.Reconcile(tc.args.req)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcilePostApplyVerify(t *testing.T) {
+	type args struct {
+		mode  VerifyMode
+		after map[string]interface{}
+		err   error
+	}
+	type want struct {
+		result    reconcile.Result
+		condition v1alpha1.Condition
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Matching": {
+			reason: "The claim should be synced if the spec read back matches the written one",
+			args: args{
+				mode:  VerifyModeFail,
+				after: map[string]interface{}{"size": "10"},
+			},
+			want: want{
+				result:    reconcile.Result{RequeueAfter: longWait},
+				condition: resource.AgentSyncSuccess(),
+			},
+		},
+		"RemoteOwnedFieldChanged": {
+			reason: "The fields that are owned by the remote cluster should not be compared",
+			args: args{
+				mode:  VerifyModeFail,
+				after: map[string]interface{}{"size": "10", "resourceRefs": []interface{}{"cool"}},
+			},
+			want: want{
+				result:    reconcile.Result{RequeueAfter: longWait},
+				condition: resource.AgentSyncSuccess(),
+			},
+		},
+		"DivergedWarn": {
+			reason: "The claim should be synced with a divergence warning if the spec read back differs in warn mode",
+			args: args{
+				mode:  VerifyModeWarn,
+				after: map[string]interface{}{"size": "20"},
+			},
+			want: want{
+				result:    reconcile.Result{RequeueAfter: longWait},
+				condition: resource.AgentSyncDiverged().WithMessage(errSpecDiverged),
+			},
+		},
+		"DivergedFail": {
+			reason: "The claim should not be synced if the spec read back differs in fail mode",
+			args: args{
+				mode:  VerifyModeFail,
+				after: map[string]interface{}{"size": "20"},
+			},
+			want: want{
+				result:    reconcile.Result{RequeueAfter: shortWait},
+				condition: resource.AgentSyncError(errors.Wrap(errors.New(errSpecDiverged), remotePrefix+errVerifyClaim)),
+			},
+		},
+		"ReadBackFailed": {
+			reason: "The claim should not be synced if it cannot be read back",
+			args: args{
+				mode: VerifyModeWarn,
+				err:  errBoom,
+			},
+			want: want{
+				result:    reconcile.Result{RequeueAfter: shortWait},
+				condition: resource.AgentSyncError(errors.Wrap(errBoom, remotePrefix+errVerifyClaim)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got v1alpha1.Condition
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						l := claim.New(claim.WithGroupVersionKind(gvk))
+						l.SetUID("luid")
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got = (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+						return nil
+					},
+				},
+			}
+			patched := false
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid"})
+					r.Object["spec"] = map[string]interface{}{"size": "5"}
+					if patched {
+						if tc.args.err != nil {
+							return tc.args.err
+						}
+						r.Object["spec"] = tc.args.after
+					}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+				MockPatch: func(_ context.Context, _ runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+					patched = true
+					return nil
+				},
+			}
+			r := NewReconciler(m, remote, gvk,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+					return nil
+				}}),
+				WithConfigurator(ConfigureFn(func(_ context.Context, _, remote *claim.Unstructured) error {
+					remote.Object["spec"] = map[string]interface{}{"size": "10"}
+					return nil
+				})),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+					return nil
+				})),
+				WithPostApplyVerify(tc.args.mode),
+			)
+			result, err := r.Reconcile(reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.condition, got, test.EquateConditions()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want condition, +got condition:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileMaxRetries(t *testing.T) {
+	remoteCalls := 0
+	var got runtime.Object
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil),
+			MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				got = obj.DeepCopyObject()
+				return nil
+			},
+		},
+	}
+	remote := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+		remoteCalls++
+		return errBoom
+	}}
+	r := NewReconciler(m, remote, gvk, WithMaxRetries(2))
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+			t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+		}
+	}
+	result, err := r.Reconcile(reconcile.Request{})
+	if err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+
+	reason := "A claim that failed max times in a row should be given up on without requeue"
+	if diff := cmp.Diff(reconcile.Result{}, result); diff != "" {
+		t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", reason, diff)
+	}
+	if diff := cmp.Diff(2, remoteCalls); diff != "" {
+		t.Errorf("\nReason: %s\nremote calls: -want, +got:\n%s", reason, diff)
+	}
+	want := claim.New(claim.WithGroupVersionKind(gvk))
+	want.SetConditions(resource.AgentSyncMaxRetriesExceeded().WithMessage(errMaxRetries))
+	if diff := cmp.Diff(want.GetUnstructured(), got, test.EquateConditions()); diff != "" {
+		t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+	}
+}
+
+func TestNewReconcilerPropagators(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		opts   []ReconcilerOption
+		want   []string
+	}{
+		"AllEnabled": {
+			reason: "All propagators should be enabled by default",
+			want:   []string{"*claim.LateInitializer", "*claim.StatusPropagator", "*claim.ConnectionSecretPropagator"},
+		},
+		"LateInitDisabled": {
+			reason: "The LateInitializer should be skipped if it's disabled",
+			opts:   []ReconcilerOption{WithLateInitialization(false)},
+			want:   []string{"*claim.StatusPropagator", "*claim.ConnectionSecretPropagator"},
+		},
+		"StatusDisabled": {
+			reason: "The StatusPropagator should be skipped if it's disabled",
+			opts:   []ReconcilerOption{WithStatusPropagation(false)},
+			want:   []string{"*claim.LateInitializer", "*claim.ConnectionSecretPropagator"},
+		},
+		"StatusDisabledForKind": {
+			reason: "The StatusPropagator should be skipped if it's disabled for the kind",
+			opts:   []ReconcilerOption{WithStatusPropagationFor(map[schema.GroupVersionKind]bool{gvk: false})},
+			want:   []string{"*claim.LateInitializer", "*claim.ConnectionSecretPropagator"},
+		},
+		"StatusDisabledForOtherKind": {
+			reason: "The StatusPropagator should not be skipped if it's disabled only for another kind",
+			opts:   []ReconcilerOption{WithStatusPropagationFor(map[schema.GroupVersionKind]bool{scopedGVK: false})},
+			want:   []string{"*claim.LateInitializer", "*claim.StatusPropagator", "*claim.ConnectionSecretPropagator"},
+		},
+		"StatusEnabledForKind": {
+			reason: "The StatusPropagator should be run if it's enabled for the kind, even if it's disabled for all kinds",
+			opts:   []ReconcilerOption{WithStatusPropagation(false), WithStatusPropagationFor(map[schema.GroupVersionKind]bool{gvk: true})},
+			want:   []string{"*claim.LateInitializer", "*claim.StatusPropagator", "*claim.ConnectionSecretPropagator"},
+		},
+		"SecretDisabled": {
+			reason: "The ConnectionSecretPropagator should be skipped if it's disabled",
+			opts:   []ReconcilerOption{WithSecretPropagation(false)},
+			want:   []string{"*claim.LateInitializer", "*claim.StatusPropagator"},
+		},
+		"OnlyStatusEnabled": {
+			reason: "Only the enabled propagators should be run",
+			opts:   []ReconcilerOption{WithLateInitialization(false), WithSecretPropagation(false)},
+			want:   []string{"*claim.StatusPropagator"},
+		},
+		"AllDisabled": {
+			reason: "No propagator should be run if all of them are disabled",
+			opts:   []ReconcilerOption{WithLateInitialization(false), WithStatusPropagation(false), WithSecretPropagation(false)},
+			want:   []string{},
+		},
+		"ReEnabled": {
+			reason: "The last option should win if a propagator is disabled and enabled again",
+			opts:   []ReconcilerOption{WithoutLateInitialization(), WithLateInitialization(true)},
+			want:   []string{"*claim.LateInitializer", "*claim.StatusPropagator", "*claim.ConnectionSecretPropagator"},
+		},
+		"SecretFirst": {
+			reason: "The propagators should run in the configured order",
+			opts:   []ReconcilerOption{WithPropagatorOrder(SecretFirstPropagatorOrder()...)},
+			want:   []string{"*claim.LateInitializer", "*claim.ConnectionSecretPropagator", "*claim.StatusPropagator"},
+		},
+		"OrderWithoutStep": {
+			reason: "The propagators that are not in the configured order should not be run",
+			opts:   []ReconcilerOption{WithPropagatorOrder(PropagatorStepConnectionSecret, PropagatorStepLateInit)},
+			want:   []string{"*claim.ConnectionSecretPropagator", "*claim.LateInitializer"},
+		},
+		"OrderWithDisabledStep": {
+			reason: "A disabled propagator should be skipped even if it's in the configured order",
+			opts:   []ReconcilerOption{WithPropagatorOrder(SecretFirstPropagatorOrder()...), WithSecretPropagation(false)},
+			want:   []string{"*claim.LateInitializer", "*claim.StatusPropagator"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := NewReconciler(&fake.Manager{Client: &test.MockClient{}}, &test.MockClient{}, gvk, tc.opts...)
+			got := []string{}
+			for _, p := range r.Propagator.(PropagatorChain) {
+				got = append(got, fmt.Sprintf("%T", p))
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nNewReconciler(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileStatusPropagationFor(t *testing.T) {
+	bucketGVK := scopedGVK.GroupVersion().WithKind("Bucket")
+	kinds := map[schema.GroupVersionKind]bool{bucketGVK: false}
+	cases := map[string]struct {
+		reason string
+		gvk    schema.GroupVersionKind
+		want   interface{}
+	}{
+		"Disabled": {
+			reason: "The status of the remote instance should not be propagated for a kind it's disabled for",
+			gvk:    bucketGVK,
+		},
+		"Enabled": {
+			reason: "The status of the remote instance should be propagated for the other kinds",
+			gvk:    scopedGVK,
+			want:   map[string]interface{}{"phase": "Ready"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got interface{}
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						l := claim.New(claim.WithGroupVersionKind(tc.gvk))
+						l.SetName("cool-claim")
+						l.SetUID("local-uid")
+						l.Object["spec"] = map[string]interface{}{"size": "10"}
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got, _ = fieldpath.Pave(obj.(*unstructured.Unstructured).Object).GetValue("status.remote")
+						return nil
+					},
+				},
+			}
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(tc.gvk))
+					r.SetName("cool-claim")
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid", AnnotationKeyLocalName: "cool-claim", AnnotationKeyManagedSpec: `["spec.size"]`})
+					r.Object["spec"] = map[string]interface{}{"size": "10"}
+					r.Object["status"] = map[string]interface{}{"phase": "Ready"}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+			}
+			r := NewReconciler(m, remote, tc.gvk,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithLateInitialization(false),
+				WithSecretPropagation(false),
+				WithStatusPropagatorOptions(WithStatusPath("status.remote")),
+				WithStatusPropagationFor(kinds),
+			)
+			if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nstatus.remote: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileDeletionConfirmation(t *testing.T) {
+	type remoteState int
+	const (
+		remoteExists remoteState = iota
+		remoteDeleting
+		remoteConfirmed
+		remoteGone
+	)
+	type step struct {
+		remote       remoteState
+		result       reconcile.Result
+		confirmation string
+		released     bool
+	}
+	cases := map[string]struct {
+		reason string
+		steps  []step
+	}{
+		"ConfirmedOverMultipleReconciles": {
+			reason: "The local claim should be released only after the remote claim confirmed its deletion and is gone",
+			steps: []step{
+				{remote: remoteExists, result: reconcile.Result{RequeueAfter: tinyWait}, confirmation: DeletionConfirmationPending},
+				{remote: remoteDeleting, result: reconcile.Result{RequeueAfter: tinyWait}, confirmation: DeletionConfirmationPending},
+				{remote: remoteConfirmed, result: reconcile.Result{RequeueAfter: tinyWait}, confirmation: DeletionConfirmationConfirmed},
+				{remote: remoteGone, result: reconcile.Result{}, confirmation: DeletionConfirmationConfirmed, released: true},
+			},
+		},
+		"GoneWithoutConfirmation": {
+			reason: "The local claim should not be released if the remote claim disappeared without confirming its deletion",
+			steps: []step{
+				{remote: remoteExists, result: reconcile.Result{RequeueAfter: tinyWait}, confirmation: DeletionConfirmationPending},
+				{remote: remoteGone, result: reconcile.Result{RequeueAfter: longWait}, confirmation: DeletionConfirmationPending},
+			},
+		},
+		"NeverCreated": {
+			reason: "The local claim should be released right away if its remote claim was never created",
+			steps: []step{
+				{remote: remoteGone, result: reconcile.Result{}, released: true},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := claim.New(claim.WithGroupVersionKind(gvk))
+			local.SetUID("luid")
+			local.SetDeletionTimestamp(&now)
+			var current step
+			released := false
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						local.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						obj.(*unstructured.Unstructured).DeepCopyInto(&local.Unstructured)
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if current.remote == remoteGone {
+						return kerrors.NewNotFound(schema.GroupResource{}, "")
+					}
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid"})
+					if current.remote != remoteExists {
+						r.SetDeletionTimestamp(&now)
+					}
+					if current.remote == remoteConfirmed {
+						r.SetConditions(v1alpha1.Condition{Type: "ResourcesDeleted", Status: corev1.ConditionTrue})
+					}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+				MockDelete: test.NewMockDeleteFn(nil),
+			}
+			r := NewReconciler(m, remote, gvk,
+				WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+					released = true
+					return nil
+				}}),
+				WithDeletionConfirmation("ResourcesDeleted"),
+			)
+			for i, s := range tc.steps {
+				current = s
+				result, err := r.Reconcile(reconcile.Request{})
+				if err != nil {
+					t.Fatalf("\nReason: %s\nstep %d: r.Reconcile(...): unexpected error: %s", tc.reason, i, err)
+				}
+				if diff := cmp.Diff(s.result, result); diff != "" {
+					t.Errorf("\nReason: %s\nstep %d: r.Reconcile(...): -want, +got:\n%s", tc.reason, i, diff)
+				}
+				if diff := cmp.Diff(s.confirmation, local.GetAnnotations()[AnnotationKeyDeletionConfirmation]); diff != "" {
+					t.Errorf("\nReason: %s\nstep %d: confirmation: -want, +got:\n%s", tc.reason, i, diff)
+				}
+				if diff := cmp.Diff(s.released, released); diff != "" {
+					t.Errorf("\nReason: %s\nstep %d: released: -want, +got:\n%s", tc.reason, i, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileRemoteDeletionPolicy(t *testing.T) {
+	type args struct {
+		opts       []ReconcilerOption
+		deletedAgo time.Duration
+	}
+	type want struct {
+		// pending is whether the sync should be retried once the grace period
+		// is over rather than after the given result.
+		pending  bool
+		result   reconcile.Result
+		deleted  bool
+		detached bool
+		released bool
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Delete": {
+			reason: "The remote instance should be deleted right away by default",
+			want: want{
+				result:  reconcile.Result{RequeueAfter: tinyWait},
+				deleted: true,
+			},
+		},
+		"GracePeriodNotOver": {
+			reason: "The remote instance should be kept while the grace period is not over",
+			args: args{
+				opts:       []ReconcilerOption{WithDeletionGracePeriod(time.Hour)},
+				deletedAgo: time.Minute,
+			},
+			want: want{
+				pending: true,
+			},
+		},
+		"GracePeriodOver": {
+			reason: "The remote instance should be deleted once the grace period is over",
+			args: args{
+				opts:       []ReconcilerOption{WithDeletionGracePeriod(time.Minute)},
+				deletedAgo: 2 * time.Minute,
+			},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: tinyWait},
+				deleted: true,
+			},
+		},
+		"Abandon": {
+			reason: "The remote instance should be kept and no longer managed if it's abandoned, and the local instance released",
+			args: args{
+				opts: []ReconcilerOption{WithRemoteDeletionPolicy(RemoteDeletionPolicyAbandon), WithDeletionGracePeriod(time.Hour)},
+			},
+			want: want{
+				result:   reconcile.Result{},
+				detached: true,
+				released: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			deletedAt := metav1.NewTime(time.Now().Add(-tc.args.deletedAgo))
+			local := claim.New(claim.WithGroupVersionKind(gvk))
+			local.SetUID("luid")
+			local.SetDeletionTimestamp(&deletedAt)
+			var condition v1alpha1.Condition
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						local.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got := &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+						condition = got.GetCondition(resource.TypeAgentSync)
+						return nil
+					},
+				},
+			}
+			deleted, detached, released := false, false, false
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid"})
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+					_, managed := obj.(*unstructured.Unstructured).GetAnnotations()[AnnotationKeyLocalUID]
+					detached = !managed
+					return nil
+				},
+				MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+					deleted = true
+					return nil
+				},
+			}
+			opts := append([]ReconcilerOption{
+				WithFinalizer(runtimeresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+					released = true
+					return nil
+				}}),
+			}, tc.args.opts...)
+			r := NewReconciler(m, remote, gvk, opts...)
+			result, err := r.Reconcile(reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if tc.want.pending {
+				if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour {
+					t.Errorf("\nReason: %s\nr.Reconcile(...): want a requeue within the grace period, got %s", tc.reason, result.RequeueAfter)
+				}
+				if diff := cmp.Diff(resource.ReasonAgentSyncDeletionPending, condition.Reason); diff != "" {
+					t.Errorf("\nReason: %s\nAgentSynced reason: -want, +got:\n%s", tc.reason, diff)
+				}
+			} else if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\nReason: %s\ndeleted: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.detached, detached); diff != "" {
+				t.Errorf("\nReason: %s\ndetached: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.released, released); diff != "" {
+				t.Errorf("\nReason: %s\nreleased: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileWriteCooldown(t *testing.T) {
+	type step struct {
+		size    string
+		applied bool
+		synced  v1alpha1.ConditionReason
+	}
+	cases := map[string]struct {
+		reason string
+		steps  []step
+	}{
+		"RevertedWithinCooldown": {
+			reason: "A remote claim that is reverted right after it's written should not be written again within the cooldown",
+			steps: []step{
+				{size: "10", applied: true, synced: resource.ReasonAgentSyncSuccess},
+				{size: "10", synced: resource.ReasonAgentSyncThrottled},
+				{size: "10", synced: resource.ReasonAgentSyncThrottled},
+			},
+		},
+		"SpecChangedWithinCooldown": {
+			reason: "A remote claim should be written again within the cooldown if what's written changes",
+			steps: []step{
+				{size: "10", applied: true, synced: resource.ReasonAgentSyncSuccess},
+				{size: "30", applied: true, synced: resource.ReasonAgentSyncSuccess},
+				{size: "30", synced: resource.ReasonAgentSyncThrottled},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := claim.New(claim.WithGroupVersionKind(gvk))
+			local.SetUID("luid")
+			var current step
+			applied := false
+			var synced v1alpha1.ConditionReason
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						local.Object["spec"] = map[string]interface{}{"size": current.size}
+						local.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						synced = (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync).Reason
+						return nil
+					},
+				},
+			}
+			// Something keeps reverting the spec of the remote claim.
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid"})
+					r.Object["spec"] = map[string]interface{}{"size": "20"}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+				MockPatch: func(_ context.Context, _ runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+					applied = true
+					return nil
+				},
+			}
+			r := NewReconciler(m, remote, gvk,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithSecretPropagation(false),
+				WithWriteCooldown(time.Hour),
+			)
+			for i, s := range tc.steps {
+				current = s
+				applied = false
+				result, err := r.Reconcile(reconcile.Request{})
+				if err != nil {
+					t.Fatalf("\nReason: %s\nstep %d: r.Reconcile(...): unexpected error: %s", tc.reason, i, err)
+				}
+				if diff := cmp.Diff(s.applied, applied); diff != "" {
+					t.Errorf("\nReason: %s\nstep %d: applied: -want, +got:\n%s", tc.reason, i, diff)
+				}
+				if diff := cmp.Diff(s.synced, synced); diff != "" {
+					t.Errorf("\nReason: %s\nstep %d: AgentSynced reason: -want, +got:\n%s", tc.reason, i, diff)
+				}
+				if !s.applied && (result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour) {
+					t.Errorf("\nReason: %s\nstep %d: r.Reconcile(...): should requeue when the cooldown elapses, got %s", tc.reason, i, result.RequeueAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileBackoffStrategy(t *testing.T) {
+	type call struct {
+		Attempt int
+		Class   ErrorClass
+	}
+	type step struct {
+		remoteErr error
+		result    reconcile.Result
+	}
+	cases := map[string]struct {
+		reason string
+		steps  []step
+		want   []call
+	}{
+		"ConsecutiveFailures": {
+			reason: "The BackoffStrategy should be consulted with the number of consecutive failed attempts",
+			steps: []step{
+				{remoteErr: errBoom, result: reconcile.Result{RequeueAfter: 1 * time.Second}},
+				{remoteErr: errBoom, result: reconcile.Result{RequeueAfter: 2 * time.Second}},
+				{remoteErr: kerrors.NewTooManyRequests("slow down", 0), result: reconcile.Result{RequeueAfter: 3 * time.Second}},
+			},
+			want: []call{
+				{Attempt: 1, Class: ErrorClassTransient},
+				{Attempt: 2, Class: ErrorClassTransient},
+				{Attempt: 3, Class: ErrorClassThrottled},
+			},
+		},
+		"ResetBySuccess": {
+			reason: "The count of failed attempts should be reset when the claim is synced",
+			steps: []step{
+				{remoteErr: errBoom, result: reconcile.Result{RequeueAfter: 1 * time.Second}},
+				{result: reconcile.Result{RequeueAfter: longWait}},
+				{remoteErr: errBoom, result: reconcile.Result{RequeueAfter: 1 * time.Second}},
+			},
+			want: []call{
+				{Attempt: 1, Class: ErrorClassTransient},
+				{Attempt: 1, Class: ErrorClassTransient},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := claim.New(claim.WithGroupVersionKind(gvk))
+			local.SetUID("luid")
+			local.Object["spec"] = map[string]interface{}{"size": "10"}
+			var current step
+			var calls []call
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						local.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						obj.(*unstructured.Unstructured).DeepCopyInto(&local.Unstructured)
+						return nil
+					},
+				},
+			}
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if current.remoteErr != nil {
+						return current.remoteErr
+					}
+					r := claim.New(claim.WithGroupVersionKind(gvk))
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid", AnnotationKeyManagedSpec: `["spec.size"]`})
+					r.Object["spec"] = map[string]interface{}{"size": "10"}
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
 				},
+			}
+			r := NewReconciler(m, remote, gvk,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithSecretPropagation(false),
+				WithBackoffStrategy(BackoffFn(func(attempt int, class ErrorClass) time.Duration {
+					calls = append(calls, call{Attempt: attempt, Class: class})
+					return time.Duration(attempt) * time.Second
+				})),
+			)
+			for i, s := range tc.steps {
+				current = s
+				result, err := r.Reconcile(reconcile.Request{})
+				if err != nil {
+					t.Fatalf("\nReason: %s\nstep %d: r.Reconcile(...): unexpected error: %s", tc.reason, i, err)
+				}
+				if diff := cmp.Diff(s.result, result); diff != "" {
+					t.Errorf("\nReason: %s\nstep %d: r.Reconcile(...): -want, +got:\n%s", tc.reason, i, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want, calls); diff != "" {
+				t.Errorf("\nReason: %s\nBackoff(...) calls: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileSchemaValidation(t *testing.T) {
+	type want struct {
+		applied bool
+		cond    v1alpha1.Condition
+	}
+	cases := map[string]struct {
+		reason string
+		spec   map[string]interface{}
+		want
+	}{
+		"Valid": {
+			reason: "A claim that the remote schema allows should be written",
+			spec:   map[string]interface{}{"size": int64(10)},
+			want: want{
+				applied: true,
+				cond:    resource.AgentSyncSuccess(),
 			},
+		},
+		"Invalid": {
+			reason: "A claim that the remote schema does not allow should not be written and its invalid fields should be reported",
+			spec:   map[string]interface{}{"size": int64(200), "engine": "oracle"},
 			want: want{
-				result: reconcile.Result{RequeueAfter: longWait},
+				cond: resource.AgentSyncError(errors.New(errInvalidClaim + `: [spec.engine: Unsupported value: "oracle": supported values: "\"postgres\"", "\"mysql\"", spec.size: Invalid value: 200: must be less than or equal to 100]`)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got v1alpha1.Condition
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+						local.Object["spec"] = tc.spec
+						local.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got = (&claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}).GetCondition(resource.TypeAgentSync)
+						return nil
+					},
+				},
+			}
+			applied := false
+			remote := &test.MockClient{
+				MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error {
+					applied = true
+					return nil
+				},
+			}
+			v := NewSchemaValidator(newScopedDiscovery(true), &test.MockClient{MockGet: newMockCRDGetFn(newSchemaCRD(), nil)})
+			r := NewReconciler(m, remote, scopedGVK,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+				WithSchemaValidation(v),
+			)
+			if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.applied, applied); diff != "" {
+				t.Errorf("\nReason: %s\napplied: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cond, got, test.EquateConditions()); diff != "" {
+				t.Errorf("\nReason: %s\ncondition: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileSchemaPruning(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				local.Object["spec"] = map[string]interface{}{"size": int64(10), "storageTier": "gold"}
+				local.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var created map[string]interface{}
+	remote := &test.MockClient{
+		MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			created, _ = obj.(*unstructured.Unstructured).Object["spec"].(map[string]interface{})
+			return nil
+		},
+	}
+	v := NewSchemaValidator(newScopedDiscovery(true), &test.MockClient{MockGet: newMockCRDGetFn(newPruningCRD(), nil)})
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithSchemaPruning(v),
+		WithSchemaValidation(v),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	if created == nil {
+		t.Fatalf("r.Reconcile(...): remote claim should have been created")
+	}
+	if _, ok := created["storageTier"]; ok {
+		t.Errorf("\nReason: %s\ncreated spec: %v", "The field the remote schema does not define should be pruned before the remote claim is created", created)
+	}
+	if _, ok := created["size"]; !ok {
+		t.Errorf("\nReason: %s\ncreated spec: %v", "The fields the remote schema defines should be kept", created)
+	}
+}
+
+func TestReconcileFinalizerName(t *testing.T) {
+	cases := map[string]struct {
+		reason     string
+		deleted    bool
+		finalizers []string
+		want       []string
+	}{
+		"Added": {
+			reason:     "The configured finalizer should be added to the local claim",
+			finalizers: []string{"other.org/keep"},
+			want:       []string{"other.org/keep", "example.org/sync"},
+		},
+		"Removed": {
+			reason:     "The configured finalizer should be removed from the local claim once its remote claim is gone",
+			deleted:    true,
+			finalizers: []string{"other.org/keep", "example.org/sync"},
+			want:       []string{"other.org/keep"},
+		},
+		"DefaultMigrated": {
+			reason:     "The default finalizer should be replaced with the configured one",
+			finalizers: []string{"other.org/keep", DefaultFinalizer},
+			want:       []string{"other.org/keep", "example.org/sync"},
+		},
+		"PreviousRemoved": {
+			reason:     "A previous finalizer should be removed from the local claim once its remote claim is gone",
+			deleted:    true,
+			finalizers: []string{"other.org/keep", "example.org/old"},
+			want:       []string{"other.org/keep"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+						l.SetName("cool-db")
+						l.SetFinalizers(tc.finalizers)
+						if tc.deleted {
+							l.SetDeletionTimestamp(&now)
+						}
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got = obj.(*unstructured.Unstructured).GetFinalizers()
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			remote := &test.MockClient{
+				MockGet:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				MockCreate: test.NewMockCreateFn(nil),
+			}
+			r := NewReconciler(m, remote, scopedGVK,
+				WithFinalizerName("example.org/sync"),
+				WithPreviousFinalizers("example.org/old"),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+			)
+			if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nfinalizers: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileWithoutFinalizer(t *testing.T) {
+	type want struct {
+		finalizers []string
+		created    bool
+		deleted    bool
+	}
+	cases := map[string]struct {
+		reason     string
+		deleted    bool
+		finalizers []string
+		want
+	}{
+		"NotAdded": {
+			reason:     "No finalizer should be added to the local claim while its remote claim is still created",
+			finalizers: []string{"other.org/keep"},
+			want: want{
+				created: true,
+			},
+		},
+		"DeletedWithoutRemoteDelete": {
+			reason:     "The remote claim of a deleted local claim should not be deleted",
+			deleted:    true,
+			finalizers: []string{"other.org/keep"},
+		},
+		"LeftoverRemoved": {
+			reason:     "A finalizer that was added before should be removed from a deleted local claim without deleting its remote claim",
+			deleted:    true,
+			finalizers: []string{"other.org/keep", "example.org/sync"},
+			want: want{
+				finalizers: []string{"other.org/keep"},
 			},
 		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			r := NewReconciler(tc.args.m, tc.args.remote, gvk, tc.args.opts...)
+			var got []string
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+						l.SetName("cool-db")
+						l.SetUID("luid")
+						l.SetFinalizers(tc.finalizers)
+						l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+						if tc.deleted {
+							l.SetDeletionTimestamp(&now)
+						}
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got = obj.(*unstructured.Unstructured).GetFinalizers()
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			created, deleted := false, false
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if !tc.deleted {
+						return kerrors.NewNotFound(schema.GroupResource{}, "")
+					}
+					r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "luid"})
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+				MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error {
+					created = true
+					return nil
+				},
+				MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+					deleted = true
+					return nil
+				},
+			}
+			r := NewReconciler(m, remote, scopedGVK,
+				WithFinalizerName("example.org/sync"),
+				WithoutFinalizer(),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+			)
+			if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.finalizers, got); diff != "" {
+				t.Errorf("\nReason: %s\nfinalizers: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\nReason: %s\ncreated: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\nReason: %s\ndeleted: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileMaintenance(t *testing.T) {
+	switched := NewMaintenanceSwitch(false)
+	switched.Set(true)
+	cases := map[string]struct {
+		reason  string
+		deleted bool
+		opt     ReconcilerOption
+	}{
+		"Synced": {
+			reason: "Nothing should be written to either cluster but the sync condition of a claim in maintenance mode",
+			opt:    WithMaintenance(true),
+		},
+		"Deleted": {
+			reason:  "Neither the remote claim should be deleted nor the local claim released in maintenance mode",
+			deleted: true,
+			opt:     WithMaintenance(true),
+		},
+		"Switched": {
+			reason: "Nothing should be written to either cluster but the sync condition of a claim while the maintenance switch is enabled",
+			opt:    WithMaintenanceSwitch(switched),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var written []string
+			writes := func(c string) *test.MockClient {
+				record := func() error {
+					written = append(written, c)
+					return nil
+				}
+				return &test.MockClient{
+					MockGet:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error { return record() },
+					MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error { return record() },
+					MockPatch: func(_ context.Context, _ runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+						return record()
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error { return record() },
+				}
+			}
+			var synced *claim.Unstructured
+			local := writes("local")
+			local.MockGet = func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetFinalizers([]string{DefaultFinalizer})
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				if tc.deleted {
+					l.SetDeletionTimestamp(&now)
+				}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			}
+			local.MockStatusUpdate = func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				synced = &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+				return nil
+			}
+			r := NewReconciler(&fake.Manager{Client: local}, writes("remote"), scopedGVK, tc.opt)
 			got, err := r.Reconcile(reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(reconcile.Result{RequeueAfter: longWait}, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			want := resource.AgentSyncMaintenance().WithMessage("Agent is in maintenance mode")
+			if diff := cmp.Diff(want, synced.GetCondition(resource.TypeAgentSync), test.EquateConditions()); diff != "" {
+				t.Errorf("\nReason: %s\nAgentSynced: -want, +got:\n%s", tc.reason, diff)
+			}
+			if len(written) > 0 {
+				t.Errorf("\nReason: %s\nunexpected writes to clusters: %v", tc.reason, written)
+			}
+		})
+	}
+}
 
-			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+func TestValidateFinalizer(t *testing.T) {
+	cases := map[string]struct {
+		reason    string
+		finalizer string
+		err       bool
+	}{
+		"Default": {
+			reason:    "The default finalizer should be valid",
+			finalizer: DefaultFinalizer,
+		},
+		"Custom": {
+			reason:    "A finalizer with a domain prefix should be valid",
+			finalizer: "platform.example.org/claim-sync",
+		},
+		"NoDomain": {
+			reason:    "A finalizer without a domain prefix should be rejected",
+			finalizer: "sync",
+			err:       true,
+		},
+		"InvalidDomain": {
+			reason:    "A finalizer whose prefix is not a DNS subdomain should be rejected",
+			finalizer: "Example_Org/sync",
+			err:       true,
+		},
+		"InvalidName": {
+			reason:    "A finalizer whose name is not a qualified name should be rejected",
+			finalizer: "example.org/-sync",
+			err:       true,
+		},
+		"Empty": {
+			reason: "An empty finalizer should be rejected",
+			err:    true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateFinalizer(tc.finalizer)
+			if diff := cmp.Diff(tc.err, err != nil); diff != "" {
+				t.Errorf("\nReason: %s\nValidateFinalizer(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+		})
+	}
+}
+
+func TestReconcileSingleApply(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.SetLabels(map[string]string{"team": "cool"})
+				l.Object["spec"] = map[string]interface{}{"size": int64(20)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var writes []*unstructured.Unstructured
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetCreationTimestamp(now)
+			r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+			r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+			r.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		},
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			writes = append(writes, obj.(*unstructured.Unstructured).DeepCopy())
+			return nil
+		},
+		MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+			writes = append(writes, obj.(*unstructured.Unstructured).DeepCopy())
+			return nil
+		},
+		// The patch is what's written, not the object being patched.
+		MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, err := p.Data(obj)
+			if err != nil {
+				return err
+			}
+			u := &unstructured.Unstructured{}
+			if err := u.UnmarshalJSON(b); err != nil {
+				return err
 			}
+			writes = append(writes, u)
+			return nil
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithRemoteMetadata(map[string]string{"tenant": "cool"}, map[string]string{"owner": "platform"}),
+		WithProviderConfigInjection("default"),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(1, len(writes)); diff != "" {
+		t.Fatalf("\nReason: %s\nremote writes: -want, +got:\n%s", "The remote instance should be written once with the changes of all configurators", diff)
+	}
+	got := &claim.Unstructured{Unstructured: *writes[0]}
+	if diff := cmp.Diff(map[string]string{"team": "cool", "tenant": "cool"}, got.GetLabels()); diff != "" {
+		t.Errorf("\nReason: %s\nlabels: -want, +got:\n%s", "The labels of the local instance and the injected ones should be written", diff)
+	}
+	if diff := cmp.Diff("platform", got.GetAnnotations()["owner"]); diff != "" {
+		t.Errorf("\nReason: %s\nannotations: -want, +got:\n%s", "The injected annotations should be written", diff)
+	}
+	spec, _ := got.Object["spec"].(map[string]interface{})
+	if !jsonEqual(int64(20), spec["size"]) {
+		t.Errorf("\nReason: %s\nspec.size: -want, +got:\n%s", "The spec of the local instance should be written", cmp.Diff(int64(20), spec["size"]))
+	}
+	if diff := cmp.Diff(map[string]interface{}{"name": "default"}, spec["providerConfigRef"]); diff != "" {
+		t.Errorf("\nReason: %s\nspec.providerConfigRef: -want, +got:\n%s", "The injected provider config should be written", diff)
+	}
+}
+
+func TestReconcileCacheNotSynced(t *testing.T) {
+	// The mock clients panic if they're called at all.
+	r := NewReconciler(&fake.Manager{Client: &test.MockClient{}}, &test.MockClient{}, scopedGVK,
+		WithCacheSyncCheck(func() bool { return false }),
+	)
+	got, err := r.Reconcile(reconcile.Request{})
+	if err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: tinyWait}, got); diff != "" {
+		t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", "The local instance should be requeued without acting on it while the local cache is not synced", diff)
+	}
+}
 
+func TestReconcileKindMismatch(t *testing.T) {
+	gk := schema.GroupKind{Group: "example.org", Kind: "Cache"}
+	kindErr := kerrors.NewInvalid(gk, "cool-db", field.ErrorList{field.Invalid(field.NewPath("kind"), "Database", "must be Cache")})
+	specErr := kerrors.NewInvalid(gk, "cool-db", field.ErrorList{field.Invalid(field.NewPath("spec", "size"), int64(10), "must be at most 5")})
+	type want struct {
+		result     reconcile.Result
+		cond       v1alpha1.Condition
+		propagated bool
+	}
+	cases := map[string]struct {
+		reason string
+		err    error
+		want
+	}{
+		"OtherKind": {
+			reason: "A remote object of another kind should not be overwritten, and propagation should be aborted",
+			err:    kindErr,
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+				cond:   resource.AgentSyncKindMismatch().WithMessage(errors.Wrap(errors.Wrap(kindErr, "cannot patch object"), remotePrefix+errKindMismatch).Error()),
+			},
+		},
+		"OtherInvalidField": {
+			reason: "An invalid remote claim should be reported as an error rather than a kind mismatch",
+			err:    specErr,
+			want: want{
+				result: reconcile.Result{RequeueAfter: longWait},
+				cond:   resource.AgentSyncError(errors.Wrap(errors.Wrap(specErr, "cannot patch object"), errApplyClaim)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var synced *claim.Unstructured
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+						l.SetName("cool-db")
+						l.SetUID("local-uid")
+						l.SetFinalizers([]string{DefaultFinalizer})
+						l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						synced = &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured)}
+						return nil
+					},
+				},
+			}
+			remote := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+					r.SetName("cool-db")
+					r.SetCreationTimestamp(now)
+					r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+					r.DeepCopyInto(obj.(*unstructured.Unstructured))
+					return nil
+				},
+				MockPatch: test.NewMockPatchFn(tc.err),
+			}
+			propagated := false
+			r := NewReconciler(m, remote, scopedGVK,
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+					propagated = true
+					return nil
+				})),
+			)
+			got, err := r.Reconcile(reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
 			if diff := cmp.Diff(tc.want.result, got); diff != "" {
 				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
 			}
+			if diff := cmp.Diff(tc.want.cond, synced.GetCondition(resource.TypeAgentSync), test.EquateConditions()); diff != "" {
+				t.Errorf("\nReason: %s\nAgentSynced: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.propagated, propagated); diff != "" {
+				t.Errorf("\nReason: %s\npropagated: -want, +got:\n%s", tc.reason, diff)
+			}
 		})
 	}
 }
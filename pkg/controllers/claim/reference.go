@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const errRewriteReferenceFmt = "cannot rewrite reference at %s"
+
+// ParseReferenceKinds parses the supplied map of field paths of references to
+// the local kinds they refer to, in Kind.version.group form, e.g.
+// spec.networkRef=Network.v1alpha1.example.org.
+func ParseReferenceKinds(m map[string]string) (map[string]schema.GroupVersionKind, error) {
+	out := make(map[string]schema.GroupVersionKind, len(m))
+	for path, k := range m {
+		gvk, err := parseGVK(k)
+		if err != nil {
+			return nil, err
+		}
+		out[path] = gvk
+	}
+	return out, nil
+}
+
+// NewReferenceRewriter returns a new *ReferenceRewriter that names the objects
+// referred to at the keys of the supplied map with the RemoteNamer of each.
+func NewReferenceRewriter(refs map[string]RemoteNamer) *ReferenceRewriter {
+	return &ReferenceRewriter{refs: refs}
+}
+
+// ReferenceRewriter rewrites the references of a remote instance to other
+// local objects, e.g. spec.networkRef of a database that refers to the claim
+// of its network, so that they refer to the remote objects of the referred
+// ones. A reference is an object with a name and optionally a namespace, which
+// defaults to the namespace of the referring local instance.
+type ReferenceRewriter struct {
+	refs map[string]RemoteNamer
+}
+
+// Configure rewrites the references of the supplied remote instance after the
+// ones of the supplied local instance. References that are not set or have no
+// name are skipped. The namespace of a reference is rewritten only if the
+// reference of the remote instance has one.
+func (rr *ReferenceRewriter) Configure(_ context.Context, local, remote *claim.Unstructured) error {
+	lp := fieldpath.Pave(local.GetUnstructured().UnstructuredContent())
+	rp := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	paths := make([]string, 0, len(rr.refs))
+	for path := range rr.refs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		name, err := lp.GetString(path + ".name")
+		if err != nil || name == "" {
+			continue
+		}
+		ref := claim.New()
+		ref.SetName(name)
+		ref.SetNamespace(local.GetNamespace())
+		if ns, err := lp.GetString(path + ".namespace"); err == nil && ns != "" {
+			ref.SetNamespace(ns)
+		}
+		rnn, err := rr.refs[path].RemoteName(ref)
+		if err != nil {
+			return errors.Wrapf(err, errRewriteReferenceFmt, path)
+		}
+		if err := rp.SetValue(path+".name", rnn.Name); err != nil {
+			return errors.Wrapf(err, errRewriteReferenceFmt, path)
+		}
+		if _, err := rp.GetString(path + ".namespace"); err == nil && rnn.Namespace != "" {
+			if err := rp.SetValue(path+".namespace", rnn.Namespace); err != nil {
+				return errors.Wrapf(err, errRewriteReferenceFmt, path)
+			}
+		}
+	}
+	return nil
+}
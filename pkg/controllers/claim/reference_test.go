@@ -0,0 +1,170 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestReferenceRewriter(t *testing.T) {
+	path := "spec.forProvider.networkRef"
+	prefixed := RemoteNamerFn(func(l *claim.Unstructured) (types.NamespacedName, error) {
+		return types.NamespacedName{Namespace: l.GetNamespace(), Name: "remote-" + l.GetName()}, nil
+	})
+	withRef := func(ns string, ref map[string]interface{}) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetName("cool-db")
+		c.SetNamespace(ns)
+		c.Object["spec"] = map[string]interface{}{"forProvider": map[string]interface{}{"size": int64(10)}}
+		if ref != nil {
+			c.Object["spec"].(map[string]interface{})["forProvider"].(map[string]interface{})["networkRef"] = ref
+		}
+		return c
+	}
+	type args struct {
+		namer  RemoteNamer
+		local  *claim.Unstructured
+		remote *claim.Unstructured
+	}
+	type want struct {
+		remote *claim.Unstructured
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"NestedName": {
+			reason: "The name of a nested reference should be rewritten to the remote name of the referred object",
+			args: args{
+				namer:  prefixed,
+				local:  withRef("cool-ns", map[string]interface{}{"name": "cool-net"}),
+				remote: withRef("cool-ns", map[string]interface{}{"name": "cool-net"}),
+			},
+			want: want{
+				remote: withRef("cool-ns", map[string]interface{}{"name": "remote-cool-net"}),
+			},
+		},
+		"NamespaceMapped": {
+			reason: "The namespace of a reference should be rewritten to the remote namespace of the referred object",
+			args: args{
+				namer:  NewScopeNamer(NewScopeResolver(newScopedDiscovery(true), WithNamespaceMappings(map[string]string{"net-ns": "remote-net-ns"})), scopedGVK),
+				local:  withRef("cool-ns", map[string]interface{}{"name": "cool-net", "namespace": "net-ns"}),
+				remote: withRef("remote-ns", map[string]interface{}{"name": "cool-net", "namespace": "net-ns"}),
+			},
+			want: want{
+				remote: withRef("remote-ns", map[string]interface{}{"name": "cool-net", "namespace": "remote-net-ns"}),
+			},
+		},
+		"NamespaceNotAdded": {
+			reason: "A namespace should not be added to a reference that has none",
+			args: args{
+				namer:  NewScopeNamer(NewScopeResolver(newScopedDiscovery(true), WithNamespaceMappings(map[string]string{"cool-ns": "remote-ns"})), scopedGVK),
+				local:  withRef("cool-ns", map[string]interface{}{"name": "cool-net"}),
+				remote: withRef("remote-ns", map[string]interface{}{"name": "cool-net"}),
+			},
+			want: want{
+				remote: withRef("remote-ns", map[string]interface{}{"name": "cool-net"}),
+			},
+		},
+		"NotSet": {
+			reason: "A reference that is not set should be skipped",
+			args: args{
+				namer:  prefixed,
+				local:  withRef("cool-ns", nil),
+				remote: withRef("cool-ns", nil),
+			},
+			want: want{
+				remote: withRef("cool-ns", nil),
+			},
+		},
+		"NamerError": {
+			reason: "An error should be returned if the referred object cannot be named",
+			args: args{
+				namer: RemoteNamerFn(func(_ *claim.Unstructured) (types.NamespacedName, error) {
+					return types.NamespacedName{}, errBoom
+				}),
+				local:  withRef("cool-ns", map[string]interface{}{"name": "cool-net"}),
+				remote: withRef("cool-ns", map[string]interface{}{"name": "cool-net"}),
+			},
+			want: want{
+				remote: withRef("cool-ns", map[string]interface{}{"name": "cool-net"}),
+				err:    errors.Wrapf(errBoom, errRewriteReferenceFmt, path),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := NewReferenceRewriter(map[string]RemoteNamer{path: tc.args.namer})
+			err := rr.Configure(context.Background(), tc.args.local, tc.args.remote)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nrr.Configure(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.remote, tc.args.remote); diff != "" {
+				t.Errorf("\nReason: %s\nrr.Configure(...): -want remote, +got remote:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseReferenceKinds(t *testing.T) {
+	type want struct {
+		kinds map[string]schema.GroupVersionKind
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		refs   map[string]string
+		want
+	}{
+		"Parsed": {
+			reason: "The kinds of the references should be parsed",
+			refs:   map[string]string{"spec.networkRef": "Network.v1alpha1.example.org"},
+			want: want{
+				kinds: map[string]schema.GroupVersionKind{"spec.networkRef": {Group: "example.org", Version: "v1alpha1", Kind: "Network"}},
+			},
+		},
+		"Invalid": {
+			reason: "An error should be returned if a kind is not in Kind.version.group form",
+			refs:   map[string]string{"spec.networkRef": "Network"},
+			want: want{
+				err: errors.Errorf(errParseKindFmt, "Network"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReferenceKinds(tc.refs)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nParseReferenceKinds(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.kinds, got); diff != "" {
+				t.Errorf("\nReason: %s\nParseReferenceKinds(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
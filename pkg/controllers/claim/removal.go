@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+// leafPaths returns the paths of the fields of the supplied value at the given
+// path that are not objects, in a stable order. Arrays are leaves since they're
+// written as a whole, and empty objects have no leaves.
+func leafPaths(path string, val interface{}) []string {
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return []string{path}
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var paths []string
+	for _, k := range keys {
+		paths = append(paths, leafPaths(child(path, k), m[k])...)
+	}
+	return paths
+}
+
+// RemovedSpecFields returns the paths of the fields that the agent pushed to
+// the observed remote instance according to its AnnotationKeyManagedSpec, and
+// that the desired remote instance no longer has, e.g. because they were
+// removed from the local instance. The outermost field that's gone is returned
+// for each of them, so that a removed object is removed as a whole. The fields
+// added in the remote cluster are never returned.
+func RemovedSpecFields(observed, desired *claim.Unstructured) []string {
+	var managed []string
+	if err := json.Unmarshal([]byte(observed.GetAnnotations()[AnnotationKeyManagedSpec]), &managed); err != nil {
+		return nil
+	}
+	op := fieldpath.Pave(observed.GetUnstructured().UnstructuredContent())
+	dp := fieldpath.Pave(desired.GetUnstructured().UnstructuredContent())
+	seen := map[string]bool{}
+	var removed []string
+	for _, path := range managed {
+		p, ok := removedPrefix(op, dp, path)
+		if !ok || seen[p] {
+			continue
+		}
+		seen[p] = true
+		removed = append(removed, p)
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// RemovedAnnotations returns the paths of the annotations with the supplied
+// keys that the observed remote instance has and the desired one no longer
+// has, e.g. a passed through crossplane.io/paused that was removed from the
+// local instance.
+func RemovedAnnotations(observed, desired *claim.Unstructured, keys []string) []string {
+	var removed []string
+	for _, k := range keys {
+		if _, ok := observed.GetAnnotations()[k]; !ok {
+			continue
+		}
+		if _, ok := desired.GetAnnotations()[k]; ok {
+			continue
+		}
+		removed = append(removed, "metadata.annotations["+k+"]")
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// RemovedLabels returns the paths of the labels that the agent pushed to the
+// observed remote instance according to its AnnotationKeyManagedLabels, and
+// that the desired remote instance no longer has. The labels added in the
+// remote cluster are never returned.
+func RemovedLabels(observed, desired *claim.Unstructured) []string {
+	var removed []string
+	for k := range managedLabels(observed) {
+		if _, ok := observed.GetLabels()[k]; !ok {
+			continue
+		}
+		if _, ok := desired.GetLabels()[k]; ok {
+			continue
+		}
+		removed = append(removed, "metadata.labels["+k+"]")
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// removedPrefix returns the shortest prefix of the supplied path that the
+// observed object has and the desired one doesn't. Nothing is returned if the
+// desired object has the path, or replaces one of its objects with another
+// kind of value.
+func removedPrefix(observed, desired *fieldpath.Paved, path string) (string, bool) {
+	segments, err := fieldpath.Parse(path)
+	if err != nil {
+		return "", false
+	}
+	for i := 1; i <= len(segments); i++ {
+		p := segments[:i].String()
+		if _, err := observed.GetValue(p); err != nil {
+			return "", false
+		}
+		dv, err := desired.GetValue(p)
+		if fieldpath.IsNotFound(err) {
+			return p, true
+		}
+		if err != nil {
+			return "", false
+		}
+		if _, ok := dv.(map[string]interface{}); !ok && i < len(segments) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// removingFields returns an ApplyOption that sets the fields at the supplied
+// paths to null in what's written, which removes them when it's written as a
+// JSON merge patch.
+func removingFields(paths []string) runtimeresource.ApplyOption {
+	return func(_ context.Context, _, desired runtime.Object) error {
+		u, ok := desired.(runtime.Unstructured)
+		if !ok {
+			return errors.New("cannot access object content")
+		}
+		p := fieldpath.Pave(u.UnstructuredContent())
+		for _, path := range paths {
+			if err := p.SetValue(path, nil); err != nil {
+				return errors.Wrapf(err, "cannot remove field %s", path)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,442 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestRemovedSpecFields(t *testing.T) {
+	newClaim := func(managed string, spec map[string]interface{}) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		if managed != "" {
+			c.SetAnnotations(map[string]string{AnnotationKeyManagedSpec: managed})
+		}
+		c.Object["spec"] = spec
+		return c
+	}
+	type args struct {
+		observed *claim.Unstructured
+		desired  *claim.Unstructured
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want []string
+	}{
+		"LeafRemoved": {
+			reason: "A pushed field that the desired instance no longer has should be removed",
+			args: args{
+				observed: newClaim(`["spec.engine","spec.size"]`, map[string]interface{}{"size": int64(10), "engine": "postgres"}),
+				desired:  newClaim("", map[string]interface{}{"size": int64(10)}),
+			},
+			want: []string{"spec.engine"},
+		},
+		"ObjectRemoved": {
+			reason: "A removed object should be removed as a whole",
+			args: args{
+				observed: newClaim(`["spec.backup.enabled","spec.backup.window","spec.size"]`, map[string]interface{}{
+					"size":   int64(10),
+					"backup": map[string]interface{}{"enabled": true, "window": "02:00"},
+				}),
+				desired: newClaim("", map[string]interface{}{"size": int64(10)}),
+			},
+			want: []string{"spec.backup"},
+		},
+		"RemoteField": {
+			reason: "A field that was added in the remote cluster should never be removed",
+			args: args{
+				observed: newClaim(`["spec.size"]`, map[string]interface{}{"size": int64(10), "resourceRef": map[string]interface{}{"name": "db"}}),
+				desired:  newClaim("", map[string]interface{}{"size": int64(10)}),
+			},
+		},
+		"TypeChanged": {
+			reason: "A field whose parent is no longer an object should be left to the write of its parent",
+			args: args{
+				observed: newClaim(`["spec.engine.name"]`, map[string]interface{}{"engine": map[string]interface{}{"name": "postgres"}}),
+				desired:  newClaim("", map[string]interface{}{"engine": "postgres"}),
+			},
+		},
+		"NotManaged": {
+			reason: "Nothing should be removed from a remote instance that has no record of the pushed fields",
+			args: args{
+				observed: newClaim("", map[string]interface{}{"size": int64(10), "engine": "postgres"}),
+				desired:  newClaim("", map[string]interface{}{"size": int64(10)}),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := RemovedSpecFields(tc.args.observed, tc.args.desired)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nRemovedSpecFields(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRemovesDeletedFields(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var written map[string]interface{}
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetCreationTimestamp(now)
+			r.SetAnnotations(map[string]string{
+				AnnotationKeyLocalUID:    "local-uid",
+				AnnotationKeyManagedSpec: `["spec.backup.enabled","spec.size"]`,
+			})
+			r.Object["spec"] = map[string]interface{}{
+				"size":        int64(10),
+				"backup":      map[string]interface{}{"enabled": true},
+				"resourceRef": map[string]interface{}{"name": "db"},
+			}
+			r.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		},
+		// The patch is what's written, not the object being patched.
+		MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, err := p.Data(obj)
+			if err != nil {
+				return err
+			}
+			u := &unstructured.Unstructured{}
+			if err := u.UnmarshalJSON(b); err != nil {
+				return err
+			}
+			written, _ = u.Object["spec"].(map[string]interface{})
+			return nil
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	backup, ok := written["backup"]
+	if !ok || backup != nil {
+		t.Errorf("\nReason: %s\nspec.backup: -want, +got:\n%s", "The field removed from the local instance should be written as null", cmp.Diff(nil, backup))
+	}
+	if diff := cmp.Diff(map[string]interface{}{"name": "db"}, written["resourceRef"]); diff != "" {
+		t.Errorf("\nReason: %s\nspec.resourceRef: -want, +got:\n%s", "The field added in the remote cluster should be kept", diff)
+	}
+}
+
+func TestRemovedAnnotations(t *testing.T) {
+	withAnnotations := func(a map[string]string) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetAnnotations(a)
+		return c
+	}
+	type args struct {
+		observed *claim.Unstructured
+		desired  *claim.Unstructured
+		keys     []string
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want []string
+	}{
+		"Removed": {
+			reason: "A passed through annotation that the desired instance no longer has should be removed",
+			args: args{
+				observed: withAnnotations(map[string]string{AnnotationKeyPaused: "true"}),
+				desired:  withAnnotations(nil),
+				keys:     []string{AnnotationKeyPaused},
+			},
+			want: []string{"metadata.annotations[crossplane.io/paused]"},
+		},
+		"Kept": {
+			reason: "A passed through annotation that the desired instance still has should not be removed",
+			args: args{
+				observed: withAnnotations(map[string]string{AnnotationKeyPaused: "true"}),
+				desired:  withAnnotations(map[string]string{AnnotationKeyPaused: "false"}),
+				keys:     []string{AnnotationKeyPaused},
+			},
+		},
+		"NotPassedThrough": {
+			reason: "An annotation that is not passed through should never be removed",
+			args: args{
+				observed: withAnnotations(map[string]string{"example.org/team": "cool"}),
+				desired:  withAnnotations(nil),
+				keys:     []string{AnnotationKeyPaused},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := RemovedAnnotations(tc.args.observed, tc.args.desired, tc.args.keys)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nRemovedAnnotations(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRemovesPassthroughAnnotations(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var written map[string]interface{}
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetCreationTimestamp(now)
+			r.SetAnnotations(map[string]string{
+				AnnotationKeyLocalUID: "local-uid",
+				AnnotationKeyPaused:   "true",
+			})
+			r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+			r.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		},
+		MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, err := p.Data(obj)
+			if err != nil {
+				return err
+			}
+			u := &unstructured.Unstructured{}
+			if err := u.UnmarshalJSON(b); err != nil {
+				return err
+			}
+			md, _ := u.Object["metadata"].(map[string]interface{})
+			written, _ = md["annotations"].(map[string]interface{})
+			return nil
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	paused, ok := written[AnnotationKeyPaused]
+	if !ok || paused != nil {
+		t.Errorf("\nReason: %s\n%s: -want, +got:\n%s", "The passed through annotation removed from the local instance should be written as null", AnnotationKeyPaused, cmp.Diff(nil, paused))
+	}
+}
+
+func TestReconcileRemovesTruncatedAnnotations(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.SetAnnotations(map[string]string{"a": "1", "bloat": "xxxxxxxxxxxxxxxx"})
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var written map[string]interface{}
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetCreationTimestamp(now)
+			r.SetAnnotations(map[string]string{
+				AnnotationKeyLocalUID: "local-uid",
+				"a":                   "1",
+				"bloat":               "xxxxxxxxxxxxxxxx",
+			})
+			r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+			r.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		},
+		MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, err := p.Data(obj)
+			if err != nil {
+				return err
+			}
+			u := &unstructured.Unstructured{}
+			if err := u.UnmarshalJSON(b); err != nil {
+				return err
+			}
+			md, _ := u.Object["metadata"].(map[string]interface{})
+			written, _ = md["annotations"].(map[string]interface{})
+			return nil
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithMetadataLimits(MetadataLimits{MaxBytes: 10}, MetadataLimitPolicyTruncate),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	bloat, ok := written["bloat"]
+	if !ok || bloat != nil {
+		t.Errorf("\nReason: %s\n%s: -want, +got:\n%s", "The propagated annotation that is truncated should be written as null", "bloat", cmp.Diff(nil, bloat))
+	}
+	if diff := cmp.Diff("bloat", written[AnnotationKeyTruncatedAnnotations]); diff != "" {
+		t.Errorf("\nReason: %s\n%s: -want, +got:\n%s", "The truncated annotation should be recorded", AnnotationKeyTruncatedAnnotations, diff)
+	}
+}
+
+func TestRemovedLabels(t *testing.T) {
+	withLabels := func(l map[string]string, managed string) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.SetLabels(l)
+		if managed != "" {
+			c.SetAnnotations(map[string]string{AnnotationKeyManagedLabels: managed})
+		}
+		return c
+	}
+	type args struct {
+		observed *claim.Unstructured
+		desired  *claim.Unstructured
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want []string
+	}{
+		"Removed": {
+			reason: "A pushed label that the desired instance no longer has should be removed",
+			args: args{
+				observed: withLabels(map[string]string{"team": "cool", "tier": "gold"}, "team,tier"),
+				desired:  withLabels(map[string]string{"tier": "gold"}, "tier"),
+			},
+			want: []string{"metadata.labels[team]"},
+		},
+		"RemoteLabel": {
+			reason: "A label that was added in the remote cluster should never be removed",
+			args: args{
+				observed: withLabels(map[string]string{"team": "cool", "crossplane.io/claim-name": "db"}, "team"),
+				desired:  withLabels(map[string]string{"team": "cool"}, "team"),
+			},
+		},
+		"NotManaged": {
+			reason: "Nothing should be removed from a remote instance that has no record of the pushed labels",
+			args: args{
+				observed: withLabels(map[string]string{"team": "cool"}, ""),
+				desired:  withLabels(nil, ""),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := RemovedLabels(tc.args.observed, tc.args.desired)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nRemovedLabels(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRemovesManagedLabels(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetUID("local-uid")
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var written *unstructured.Unstructured
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			r.SetName("cool-db")
+			r.SetCreationTimestamp(now)
+			r.SetLabels(map[string]string{"team": "cool", "crossplane.io/claim-name": "db"})
+			r.SetAnnotations(map[string]string{
+				AnnotationKeyLocalUID:      "local-uid",
+				AnnotationKeyManagedLabels: "team",
+			})
+			r.Object["spec"] = map[string]interface{}{"size": int64(10)}
+			r.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		},
+		MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, err := p.Data(obj)
+			if err != nil {
+				return err
+			}
+			written = &unstructured.Unstructured{}
+			return written.UnmarshalJSON(b)
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	md, _ := written.Object["metadata"].(map[string]interface{})
+	labels, _ := md["labels"].(map[string]interface{})
+	annotations, _ := md["annotations"].(map[string]interface{})
+	if team, ok := labels["team"]; !ok || team != nil {
+		t.Errorf("\nReason: %s\nlabel team: -want, +got:\n%s", "The label removed from the local instance should be written as null", cmp.Diff(nil, team))
+	}
+	if diff := cmp.Diff("db", labels["crossplane.io/claim-name"]); diff != "" {
+		t.Errorf("\nReason: %s\nlabel crossplane.io/claim-name: -want, +got:\n%s", "The label added in the remote cluster should be kept", diff)
+	}
+	if managed, ok := annotations[AnnotationKeyManagedLabels]; !ok || managed != nil {
+		t.Errorf("\nReason: %s\n%s: -want, +got:\n%s", "The record of the pushed labels should be written as null once none is left", AnnotationKeyManagedLabels, cmp.Diff(nil, managed))
+	}
+}
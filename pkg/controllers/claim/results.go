@@ -0,0 +1,192 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+// DefaultLastResultsSize is the default number of claims whose last result is
+// kept.
+const DefaultLastResultsSize = 1000
+
+// Outcomes of a single Propagator.
+const (
+	PropagatorSucceeded = "Succeeded"
+	PropagatorFailed    = "Failed"
+	PropagatorSkipped   = "Skipped"
+)
+
+// A ResultKey identifies a claim whose result is recorded.
+type ResultKey struct {
+	schema.GroupVersionKind
+	types.NamespacedName
+}
+
+// PropagatorResult is the outcome of a single Propagator of a PropagatorChain.
+type PropagatorResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// LastResult is the outcome of the last reconciliation of a claim.
+type LastResult struct {
+	Time        metav1.Time            `json:"time"`
+	Synced      corev1.ConditionStatus `json:"synced"`
+	Reason      string                 `json:"reason,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Propagators []PropagatorResult     `json:"propagators,omitempty"`
+}
+
+// NewLastResults returns a new *LastResults that keeps the last result of at
+// most the given number of claims. DefaultLastResultsSize is used if size is
+// not positive.
+func NewLastResults(size int) *LastResults {
+	if size <= 0 {
+		size = DefaultLastResultsSize
+	}
+	return &LastResults{size: size, results: map[ResultKey]LastResult{}}
+}
+
+// LastResults keeps the last result of the most recently seen claims. The
+// result of the claim that was seen first is dropped once the limit is reached.
+type LastResults struct {
+	mu      sync.Mutex
+	size    int
+	keys    []ResultKey
+	next    int
+	results map[ResultKey]LastResult
+}
+
+// Record records the last result of the claim with the given key.
+func (l *LastResults) Record(k ResultKey, r LastResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.results[k]; !ok {
+		if len(l.keys) < l.size {
+			l.keys = append(l.keys, k)
+		} else {
+			delete(l.results, l.keys[l.next])
+			l.keys[l.next] = k
+			l.next = (l.next + 1) % l.size
+		}
+	}
+	l.results[k] = r
+}
+
+// Get returns the last result of the claim with the given key and whether
+// there is one.
+func (l *LastResults) Get(k ResultKey) (LastResult, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.results[k]
+	return r, ok
+}
+
+// lastResult returns the LastResult for the supplied sync condition and the
+// results of the Propagators.
+func lastResult(c v1alpha1.Condition, p []PropagatorResult) LastResult {
+	r := LastResult{
+		Time:        metav1.Now(),
+		Synced:      corev1.ConditionUnknown,
+		Propagators: p,
+	}
+	if c.Type == "" {
+		return r
+	}
+	r.Synced = c.Status
+	r.Reason = string(c.Reason)
+	if c.Status != corev1.ConditionTrue {
+		r.Error = c.Message
+	}
+	return r
+}
+
+// propagateEach calls the Propagators of the supplied chain one by one just
+// like PropagatorChain does, and reports the outcome of each of them.
+func propagateEach(ctx context.Context, pp PropagatorChain, local, remote *claim.Unstructured) ([]PropagatorResult, error) {
+	results := make([]PropagatorResult, len(pp))
+	var err error
+	for i, p := range pp {
+		results[i] = PropagatorResult{Name: fmt.Sprintf("%T", p), Status: PropagatorSkipped}
+		if err != nil {
+			continue
+		}
+		if err = p.Propagate(ctx, local, remote); err != nil {
+			results[i].Status = PropagatorFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Status = PropagatorSucceeded
+	}
+	return results, err
+}
+
+// NewLastResultsHandler returns an http.Handler that serves the last result of
+// a claim as JSON. The claim is given with the group, version, kind, namespace
+// and name query parameters.
+func NewLastResultsHandler(l *LastResults) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		k := ResultKey{
+			GroupVersionKind: schema.GroupVersionKind{Group: q.Get("group"), Version: q.Get("version"), Kind: q.Get("kind")},
+			NamespacedName:   types.NamespacedName{Namespace: q.Get("namespace"), Name: q.Get("name")},
+		}
+		if k.Version == "" || k.Kind == "" || k.Name == "" {
+			http.Error(w, "version, kind and name query parameters are required", http.StatusBadRequest)
+			return
+		}
+		r, ok := l.Get(k)
+		if !ok {
+			http.Error(w, "no result is recorded for the claim", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r)
+	})
+}
+
+// NewTokenAuthHandler returns an http.Handler that serves the requests with
+// the supplied handler only if they carry the given bearer token in their
+// Authorization header. All requests are served if the token is empty.
+func NewTokenAuthHandler(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
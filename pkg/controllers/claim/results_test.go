@@ -0,0 +1,306 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func resultKey(name string) ResultKey {
+	return ResultKey{GroupVersionKind: scopedGVK, NamespacedName: types.NamespacedName{Namespace: "cool-ns", Name: name}}
+}
+
+func TestLastResults(t *testing.T) {
+	type want struct {
+		present map[string]bool
+		reasons map[string]string
+	}
+	cases := map[string]struct {
+		reason string
+		size   int
+		record []string
+		want   want
+	}{
+		"WithinLimit": {
+			reason: "The results of all claims should be kept if they do not exceed the limit",
+			size:   2,
+			record: []string{"a", "b"},
+			want: want{
+				present: map[string]bool{"a": true, "b": true},
+				reasons: map[string]string{"a": "0", "b": "1"},
+			},
+		},
+		"Overwrite": {
+			reason: "Recording the result of a known claim should overwrite its result without evicting others",
+			size:   2,
+			record: []string{"a", "b", "a"},
+			want: want{
+				present: map[string]bool{"a": true, "b": true},
+				reasons: map[string]string{"a": "2", "b": "1"},
+			},
+		},
+		"EvictOldest": {
+			reason: "The result of the claim that was seen first should be dropped once the limit is reached",
+			size:   2,
+			record: []string{"a", "b", "c", "d"},
+			want: want{
+				present: map[string]bool{"a": false, "b": false, "c": true, "d": true},
+				reasons: map[string]string{"c": "2", "d": "3"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := NewLastResults(tc.size)
+			for i, n := range tc.record {
+				l.Record(resultKey(n), LastResult{Reason: string(rune('0' + i))})
+			}
+			present := map[string]bool{}
+			reasons := map[string]string{}
+			for n := range tc.want.present {
+				r, ok := l.Get(resultKey(n))
+				present[n] = ok
+				if ok {
+					reasons[n] = r.Reason
+				}
+			}
+			if diff := cmp.Diff(tc.want.present, present); diff != "" {
+				t.Errorf("\nReason: %s\nl.Get(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reasons, reasons); diff != "" {
+				t.Errorf("\nReason: %s\nl.Get(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPropagateEach(t *testing.T) {
+	ok := PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })
+	fail := PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return errBoom })
+	type want struct {
+		results []PropagatorResult
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		chain  PropagatorChain
+		want   want
+	}{
+		"AllSucceeded": {
+			reason: "All Propagators should be reported as succeeded if none of them fails",
+			chain:  PropagatorChain{ok, ok},
+			want: want{
+				results: []PropagatorResult{
+					{Name: "claim.PropagateFn", Status: PropagatorSucceeded},
+					{Name: "claim.PropagateFn", Status: PropagatorSucceeded},
+				},
+			},
+		},
+		"Failed": {
+			reason: "The Propagators after a failed one should be reported as skipped",
+			chain:  PropagatorChain{ok, fail, ok},
+			want: want{
+				results: []PropagatorResult{
+					{Name: "claim.PropagateFn", Status: PropagatorSucceeded},
+					{Name: "claim.PropagateFn", Status: PropagatorFailed, Error: errBoom.Error()},
+					{Name: "claim.PropagateFn", Status: PropagatorSkipped},
+				},
+				err: errBoom,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := propagateEach(context.Background(), tc.chain, nil, nil)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\npropagateEach(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.results, got); diff != "" {
+				t.Errorf("\nReason: %s\npropagateEach(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLastResultsHandler(t *testing.T) {
+	l := NewLastResults(0)
+	l.Record(resultKey("cool-claim"), LastResult{Synced: corev1.ConditionFalse, Error: "boom"})
+	type want struct {
+		code   int
+		result *LastResult
+	}
+	cases := map[string]struct {
+		reason string
+		url    string
+		want   want
+	}{
+		"Found": {
+			reason: "The last result of a known claim should be served",
+			url:    "/debug/claims?group=example.org&version=v1&kind=Database&namespace=cool-ns&name=cool-claim",
+			want: want{
+				code:   http.StatusOK,
+				result: &LastResult{Synced: corev1.ConditionFalse, Error: "boom"},
+			},
+		},
+		"NotFound": {
+			reason: "Not found should be returned for a claim without a result",
+			url:    "/debug/claims?group=example.org&version=v1&kind=Database&namespace=cool-ns&name=other",
+			want: want{
+				code: http.StatusNotFound,
+			},
+		},
+		"MissingName": {
+			reason: "Bad request should be returned if the claim is not identified",
+			url:    "/debug/claims?group=example.org&version=v1&kind=Database",
+			want: want{
+				code: http.StatusBadRequest,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewLastResultsHandler(l).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			if diff := cmp.Diff(tc.want.code, rec.Code); diff != "" {
+				t.Errorf("\nReason: %s\nServeHTTP(...): -want code, +got code:\n%s", tc.reason, diff)
+			}
+			if tc.want.result == nil {
+				return
+			}
+			got := &LastResult{}
+			if err := json.NewDecoder(rec.Body).Decode(got); err != nil {
+				t.Fatalf("cannot decode response: %s", err)
+			}
+			if diff := cmp.Diff(tc.want.result, got, cmpopts.IgnoreFields(LastResult{}, "Time")); diff != "" {
+				t.Errorf("\nReason: %s\nServeHTTP(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRecordsLastResult(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet:          test.NewMockGetFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	remote := &test.MockClient{
+		MockGet:    test.NewMockGetFn(nil),
+		MockPatch:  test.NewMockPatchFn(nil),
+		MockCreate: test.NewMockCreateFn(nil),
+	}
+	l := NewLastResults(0)
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error {
+			return nil
+		}}),
+		WithConfigurator(ConfigureFn(func(_ context.Context, _, _ *claim.Unstructured) error {
+			return nil
+		})),
+		WithPropagator(NewPropagatorChain(
+			PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil }),
+			PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return errBoom }),
+		)),
+		WithLastResults(l),
+	)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cool-ns", Name: "cool-claim"}}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+
+	reason := "The outcome of the reconciliation and of each Propagator should be recorded"
+	got, ok := l.Get(resultKey("cool-claim"))
+	if !ok {
+		t.Fatalf("\nReason: %s\nl.Get(...): no result is recorded", reason)
+	}
+	want := LastResult{
+		Synced: corev1.ConditionFalse,
+		Reason: string(resource.ReasonAgentSyncError),
+		Error:  errors.Wrap(errBoom, errPull).Error(),
+		Propagators: []PropagatorResult{
+			{Name: "claim.PropagateFn", Status: PropagatorSucceeded},
+			{Name: "claim.PropagateFn", Status: PropagatorFailed, Error: errBoom.Error()},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(LastResult{}, "Time")); diff != "" {
+		t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+	}
+}
+
+func TestTokenAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	cases := map[string]struct {
+		reason string
+		token  string
+		header string
+		want   int
+	}{
+		"NoToken": {
+			reason: "All requests should be served if no token is configured",
+			want:   http.StatusOK,
+		},
+		"ValidToken": {
+			reason: "Requests with the configured bearer token should be served",
+			token:  "s3cr3t",
+			header: "Bearer s3cr3t",
+			want:   http.StatusOK,
+		},
+		"InvalidToken": {
+			reason: "Requests with another bearer token should be rejected",
+			token:  "s3cr3t",
+			header: "Bearer other",
+			want:   http.StatusUnauthorized,
+		},
+		"MissingToken": {
+			reason: "Requests without a bearer token should be rejected if a token is configured",
+			token:  "s3cr3t",
+			want:   http.StatusUnauthorized,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/claims", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			NewTokenAuthHandler(tc.token, ok).ServeHTTP(rec, req)
+			if diff := cmp.Diff(tc.want, rec.Code); diff != "" {
+				t.Errorf("\nReason: %s\nServeHTTP(...): -want code, +got code:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+type attempts struct {
+	count      int
+	generation int64
+	syncNow    string
+}
+
+// NewRetryLimiter returns a new *RetryLimiter that gives up on a claim after it
+// fails to be synced for the given number of consecutive attempts.
+func NewRetryLimiter(max int) *RetryLimiter {
+	return &RetryLimiter{max: max, attempts: map[string]*attempts{}}
+}
+
+// RetryLimiter counts the consecutive failed sync attempts of claims in memory.
+// The count of a claim is reset when the claim is synced successfully, when its
+// generation changes or when the value of its sync-now annotation changes.
+type RetryLimiter struct {
+	max int
+
+	mu       sync.Mutex
+	attempts map[string]*attempts
+}
+
+// Exceeded returns true if the claim with given key has failed to be synced for
+// the maximum number of consecutive attempts.
+func (l *RetryLimiter) Exceeded(key string, o metav1.Object) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.attempts[key]
+	if !ok {
+		return false
+	}
+	if a.generation != o.GetGeneration() || a.syncNow != o.GetAnnotations()[AnnotationKeySyncNow] {
+		delete(l.attempts, key)
+		return false
+	}
+	return a.count >= l.max
+}
+
+// Observe records the outcome of a sync attempt of the claim with given key
+// according to its AgentSynced condition.
+func (l *RetryLimiter) Observe(key string, o metav1.Object, c v1alpha1.Condition) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch c.Reason {
+	case resource.ReasonAgentSyncError:
+		a, ok := l.attempts[key]
+		if !ok || a.generation != o.GetGeneration() || a.syncNow != o.GetAnnotations()[AnnotationKeySyncNow] {
+			a = &attempts{generation: o.GetGeneration(), syncNow: o.GetAnnotations()[AnnotationKeySyncNow]}
+			l.attempts[key] = a
+		}
+		a.count++
+	case resource.ReasonAgentSyncMaxRetriesExceeded:
+		// The claim is still given up on, so we keep the count as is.
+	default:
+		delete(l.attempts, key)
+	}
+}
+
+// Forget stops tracking the claim with given key, i.e. when it's deleted.
+func (l *RetryLimiter) Forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, key)
+}
+
+// NewRetryBudget returns a new *RetryBudget that allows the given number of
+// retries.
+func NewRetryBudget(retries int) *RetryBudget {
+	return &RetryBudget{remaining: retries}
+}
+
+// A RetryBudget bounds the number of retries all the steps of a reconciliation
+// make together, so that no reconciliation keeps retrying for too long. The
+// steps give up once it's spent and the reconciliation is requeued with the
+// backoff of the Reconciler.
+type RetryBudget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+// Spend takes a retry from the budget. It returns false if none is left.
+func (b *RetryBudget) Spend() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+type retryBudgetKey struct{}
+
+// WithRetryBudgetContext returns a copy of the supplied context that carries
+// the supplied RetryBudget to the steps of a reconciliation.
+func WithRetryBudgetContext(ctx context.Context, b *RetryBudget) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, b)
+}
+
+// spendRetry takes a retry from the RetryBudget of the supplied context. The
+// retries are unbounded if the context has no RetryBudget.
+func spendRetry(ctx context.Context) bool {
+	b, ok := ctx.Value(retryBudgetKey{}).(*RetryBudget)
+	return !ok || b.Spend()
+}
+
+// retry calls fn, with the number of the attempt, until it succeeds, returns
+// an error that is not retriable, has been called the given number of times or
+// the RetryBudget of the context is spent. It waits according to the supplied
+// backoff before each retry.
+func retry(ctx context.Context, attempts int, b wait.Backoff, retriable func(error) bool, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || attempt >= attempts || !retriable(err) || !spendRetry(ctx) {
+			return err
+		}
+		t := time.NewTimer(b.Step())
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func TestRetryLimiter(t *testing.T) {
+	synced := resource.AgentSyncSuccess()
+	failed := resource.AgentSyncError(errBoom)
+	gaveUp := resource.AgentSyncMaxRetriesExceeded()
+
+	type observation struct {
+		generation int64
+		syncNow    string
+		exceeded   bool
+		outcome    *bool
+	}
+	yes, no := true, false
+	cases := map[string]struct {
+		reason string
+		steps  []observation
+	}{
+		"ReachesTerminalState": {
+			reason: "The claim should be given up on after max consecutive failures",
+			steps: []observation{
+				{outcome: &no},
+				{outcome: &no},
+				{exceeded: true},
+				{exceeded: true},
+			},
+		},
+		"SuccessResets": {
+			reason: "A successful sync should reset the count",
+			steps: []observation{
+				{outcome: &no},
+				{outcome: &yes},
+				{outcome: &no},
+			},
+		},
+		"SyncNowResets": {
+			reason: "Changing the sync-now annotation should reset the count",
+			steps: []observation{
+				{outcome: &no},
+				{outcome: &no},
+				{exceeded: true},
+				{syncNow: "1"},
+				{syncNow: "1", outcome: &no},
+			},
+		},
+		"GenerationResets": {
+			reason: "Changing the spec of the claim should reset the count",
+			steps: []observation{
+				{outcome: &no},
+				{outcome: &no},
+				{generation: 2},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := NewRetryLimiter(2)
+			for i, s := range tc.steps {
+				o := claim.New()
+				o.SetGeneration(s.generation)
+				o.SetAnnotations(map[string]string{AnnotationKeySyncNow: s.syncNow})
+				if diff := cmp.Diff(s.exceeded, l.Exceeded("key", o)); diff != "" {
+					t.Errorf("\nReason: %s\nstep %d: l.Exceeded(...): -want, +got:\n%s", tc.reason, i, diff)
+				}
+				switch {
+				case s.exceeded:
+					l.Observe("key", o, gaveUp)
+				case s.outcome == nil:
+				case *s.outcome:
+					l.Observe("key", o, synced)
+				default:
+					l.Observe("key", o, failed)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	type want struct {
+		updates int
+		gets    int
+	}
+	cases := map[string]struct {
+		reason string
+		budget *RetryBudget
+		want   want
+	}{
+		"Unbounded": {
+			reason: "Every step should make all of its attempts if there is no budget",
+			want:   want{updates: 3, gets: 3},
+		},
+		"Capped": {
+			reason: "The steps should stop retrying once the budget they share is spent",
+			budget: NewRetryBudget(3),
+			want:   want{updates: 3, gets: 2},
+		},
+		"Spent": {
+			reason: "No step should retry if the budget is spent",
+			budget: NewRetryBudget(0),
+			want:   want{updates: 1, gets: 1},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.budget != nil {
+				ctx = WithRetryBudgetContext(ctx, tc.budget)
+			}
+			local := claim.New()
+			local.Object["spec"] = map[string]interface{}{"writeConnectionSecretToRef": map[string]interface{}{"name": "conn"}}
+			remote := claim.New()
+			remote.Object["spec"] = map[string]interface{}{
+				"writeConnectionSecretToRef": map[string]interface{}{"name": "conn"},
+				"resourceRef":                map[string]interface{}{"name": "composite"},
+			}
+			updates, gets := 0, 0
+			// The local instance is read again as it was before the
+			// conflicting update.
+			original := local.DeepCopy()
+			lc := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					obj.(*claim.Unstructured).Unstructured = *original.DeepCopy()
+					return nil
+				},
+				MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+					updates++
+					return kerrors.NewConflict(schema.GroupResource{}, "cool-claim", errBoom)
+				},
+			}
+			rc := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+					gets++
+					return errBoom
+				},
+			}
+			// Both steps fail, so they're called one by one rather than as
+			// a chain that would stop at the first one.
+			li := NewLateInitializer(lc, DefaultFieldPolicies(), WithConflictRetries(3), WithConflictBackoff(wait.Backoff{}))
+			_ = li.Propagate(ctx, local, remote)
+			csp := NewConnectionSecretPropagator(runtimeresource.ClientApplicator{Client: lc}, runtimeresource.ClientApplicator{Client: rc}, WithSecretGetRetries(3, wait.Backoff{}))
+			_ = csp.Propagate(ctx, local, remote)
+			if diff := cmp.Diff(tc.want, want{updates: updates, gets: gets}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\nReason: %s\nattempts: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
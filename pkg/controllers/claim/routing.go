@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type localNamespaceKey struct{}
+
+// WithLocalNamespace returns a copy of the supplied context that records the
+// namespace of the local instance that the requests made with it are made for.
+// The Reconciler records it for every local instance it reconciles.
+func WithLocalNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, localNamespaceKey{}, namespace)
+}
+
+// LocalNamespace returns the namespace of the local instance recorded in the
+// supplied context, if any.
+func LocalNamespace(ctx context.Context) (string, bool) {
+	ns, ok := ctx.Value(localNamespaceKey{}).(string)
+	return ns, ok
+}
+
+// A RemoteClientSelectorOption configures a RemoteClientSelector.
+type RemoteClientSelectorOption func(*RemoteClientSelector)
+
+// WithNamespaceRemotes routes the requests made for the local instances in the
+// keys of the supplied map to the remote clients they map to.
+func WithNamespaceRemotes(remotes map[string]client.Client) RemoteClientSelectorOption {
+	return func(s *RemoteClientSelector) {
+		for ns, c := range remotes {
+			s.remotes[ns] = c
+		}
+	}
+}
+
+// NewRemoteClientSelector returns a new *RemoteClientSelector that routes the
+// requests made for the local instances in namespaces that are not mapped to
+// another remote client to the supplied default one.
+func NewRemoteClientSelector(def client.Client, o ...RemoteClientSelectorOption) *RemoteClientSelector {
+	s := &RemoteClientSelector{def: def, remotes: map[string]client.Client{}}
+	for _, fn := range o {
+		fn(s)
+	}
+	return s
+}
+
+// A RemoteClientSelector is a client.Client that routes the requests made for
+// the local instances of some namespaces to other remote clusters than the
+// default one, according to the namespace recorded by WithLocalNamespace in
+// their context. The requests without a recorded namespace, e.g. the ones of
+// the CRD sync, are always made to the default remote cluster, so all remote
+// clusters are expected to serve the same kinds of claims.
+type RemoteClientSelector struct {
+	def     client.Client
+	remotes map[string]client.Client
+}
+
+// Wrap returns a new *RemoteClientSelector that routes the requests the same
+// way to the remote clients wrapped by the supplied function, e.g. so that each
+// remote cluster gets its own WarmupClient.
+func (s *RemoteClientSelector) Wrap(fn func(client.Client) client.Client) *RemoteClientSelector {
+	w := &RemoteClientSelector{def: fn(s.def), remotes: make(map[string]client.Client, len(s.remotes))}
+	for ns, c := range s.remotes {
+		w.remotes[ns] = fn(c)
+	}
+	return w
+}
+
+// Select returns the remote client that the requests made with the supplied
+// context are routed to.
+func (s *RemoteClientSelector) Select(ctx context.Context) client.Client {
+	ns, ok := LocalNamespace(ctx)
+	if !ok {
+		return s.def
+	}
+	if c, ok := s.remotes[ns]; ok {
+		return c
+	}
+	return s.def
+}
+
+// Cluster returns the mapped namespace whose remote cluster the requests made
+// with the supplied context are routed to, or an empty string for the default
+// remote cluster. It tells the remote clusters apart, e.g. to cache what has
+// been done in each of them.
+func (s *RemoteClientSelector) Cluster(ctx context.Context) string {
+	ns, ok := LocalNamespace(ctx)
+	if !ok {
+		return ""
+	}
+	if _, ok := s.remotes[ns]; ok {
+		return ns
+	}
+	return ""
+}
+
+// Get the supplied object from the selected remote cluster.
+func (s *RemoteClientSelector) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	return s.Select(ctx).Get(ctx, key, obj)
+}
+
+// List the supplied objects of the selected remote cluster.
+func (s *RemoteClientSelector) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	return s.Select(ctx).List(ctx, list, opts...)
+}
+
+// Create the supplied object in the selected remote cluster.
+func (s *RemoteClientSelector) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	return s.Select(ctx).Create(ctx, obj, opts...)
+}
+
+// Delete the supplied object from the selected remote cluster.
+func (s *RemoteClientSelector) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return s.Select(ctx).Delete(ctx, obj, opts...)
+}
+
+// Update the supplied object in the selected remote cluster.
+func (s *RemoteClientSelector) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	return s.Select(ctx).Update(ctx, obj, opts...)
+}
+
+// Patch the supplied object in the selected remote cluster.
+func (s *RemoteClientSelector) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return s.Select(ctx).Patch(ctx, obj, patch, opts...)
+}
+
+// DeleteAllOf the objects of the supplied type from the selected remote
+// cluster.
+func (s *RemoteClientSelector) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	return s.Select(ctx).DeleteAllOf(ctx, obj, opts...)
+}
+
+// Status returns a client.StatusWriter that writes the status of the objects
+// of the selected remote cluster.
+func (s *RemoteClientSelector) Status() client.StatusWriter {
+	return &selectedStatusWriter{selector: s}
+}
+
+type selectedStatusWriter struct {
+	selector *RemoteClientSelector
+}
+
+func (w *selectedStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	return w.selector.Select(ctx).Status().Update(ctx, obj, opts...)
+}
+
+func (w *selectedStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return w.selector.Select(ctx).Status().Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestRemoteClientSelector(t *testing.T) {
+	var got string
+	remote := func(name string) client.Client {
+		return &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+				got = name
+				return nil
+			},
+			MockStatusUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+				got = name
+				return nil
+			},
+		}
+	}
+	s := NewRemoteClientSelector(remote("default"), WithNamespaceRemotes(map[string]client.Client{"team-a": remote("a")}))
+	cases := map[string]struct {
+		reason string
+		ctx    context.Context
+		want   string
+	}{
+		"Mapped": {
+			reason: "The requests made for a local instance in a mapped namespace should be made to its remote cluster",
+			ctx:    WithLocalNamespace(context.Background(), "team-a"),
+			want:   "a",
+		},
+		"Unmapped": {
+			reason: "The requests made for a local instance in an unmapped namespace should be made to the default remote cluster",
+			ctx:    WithLocalNamespace(context.Background(), "team-b"),
+			want:   "default",
+		},
+		"NoNamespace": {
+			reason: "The requests that are not made for a local instance should be made to the default remote cluster",
+			ctx:    context.Background(),
+			want:   "default",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got = ""
+			_ = s.Get(tc.ctx, types.NamespacedName{Name: "cool"}, &unstructured.Unstructured{})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\ns.Get(...): -want remote, +got remote:\n%s", tc.reason, diff)
+			}
+			got = ""
+			_ = s.Status().Update(tc.ctx, &unstructured.Unstructured{})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\ns.Status().Update(...): -want remote, +got remote:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRemoteClientSelector(t *testing.T) {
+	cases := map[string]struct {
+		reason    string
+		namespace string
+		want      string
+	}{
+		"Mapped": {
+			reason:    "The remote instance of a local instance in a mapped namespace should be read from its remote cluster",
+			namespace: "team-a",
+			want:      "a",
+		},
+		"Unmapped": {
+			reason:    "The remote instance of a local instance in an unmapped namespace should be read from the default remote cluster",
+			namespace: "team-b",
+			want:      "default",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+						l := claim.New(claim.WithGroupVersionKind(gvk))
+						l.SetName(key.Name)
+						l.SetNamespace(key.Namespace)
+						l.SetUID("local-uid")
+						l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+						l.DeepCopyInto(obj.(*unstructured.Unstructured))
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			var read []string
+			remote := func(name string) client.Client {
+				return &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+						read = append(read, name)
+						return kerrors.NewNotFound(schema.GroupResource{}, "")
+					},
+					MockCreate: test.NewMockCreateFn(nil),
+				}
+			}
+			s := NewRemoteClientSelector(remote("default"), WithNamespaceRemotes(map[string]client.Client{"team-a": remote("a")}))
+			r := NewReconciler(m, s, gvk,
+				WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+				WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+			)
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: tc.namespace, Name: "cool-db"}}); err != nil {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", tc.reason, err)
+			}
+			if len(read) == 0 {
+				t.Fatalf("\nReason: %s\nr.Reconcile(...): no remote reads", tc.reason)
+			}
+			for _, got := range read {
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Errorf("\nReason: %s\nr.Reconcile(...): -want remote, +got remote:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
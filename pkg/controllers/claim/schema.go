@@ -0,0 +1,486 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errGetCRD           = "cannot get CustomResourceDefinition"
+	errResourceNotFound = "remote cluster does not serve a resource of kind %s"
+	errPruneClaim       = "cannot prune claim"
+	defaultSchemaTTL    = 5 * time.Minute
+)
+
+// A SchemaValidatorOption configures a SchemaValidator.
+type SchemaValidatorOption func(*SchemaValidator)
+
+// WithSchemaTTL specifies how long a schema is reused before it's fetched from
+// the remote cluster again.
+func WithSchemaTTL(d time.Duration) SchemaValidatorOption {
+	return func(v *SchemaValidator) {
+		v.ttl = d
+	}
+}
+
+// NewSchemaValidator returns a new *SchemaValidator that uses the given
+// discovery client of the remote cluster to find the CRDs of the remote kinds
+// and the given client to read them.
+func NewSchemaValidator(d discovery.ServerResourcesInterface, c client.Reader, opts ...SchemaValidatorOption) *SchemaValidator {
+	v := &SchemaValidator{
+		discovery: d,
+		client:    c,
+		ttl:       defaultSchemaTTL,
+		now:       time.Now,
+		schemas:   map[schema.GroupVersionKind]cachedSchema{},
+	}
+	for _, f := range opts {
+		f(v)
+	}
+	return v
+}
+
+type cachedSchema struct {
+	schema *v1beta1.JSONSchemaProps
+
+	// prunes is whether the remote API server drops the unknown fields
+	// rather than storing them.
+	prunes  bool
+	fetched time.Time
+}
+
+// SchemaValidator validates the spec of the remote instances against the
+// OpenAPI schema of their CRD in the remote cluster, so that an invalid claim
+// fails with errors that point to its fields before it's written rather than
+// with whatever the remote API server rejects it with. The agent needs to be
+// allowed to get the cluster scoped CustomResourceDefinitions of the remote
+// cluster for that.
+type SchemaValidator struct {
+	discovery discovery.ServerResourcesInterface
+	client    client.Reader
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	schemas map[schema.GroupVersionKind]cachedSchema
+}
+
+// Validate returns the errors of the fields of the spec of the supplied remote
+// instance that its schema does not allow. Kinds whose CRD has no schema are
+// never invalid. An error is returned only if the schema cannot be fetched.
+func (v *SchemaValidator) Validate(ctx context.Context, remote *claim.Unstructured) (field.ErrorList, error) {
+	c, err := v.schema(ctx, remote.GroupVersionKind())
+	if err != nil || c.schema == nil {
+		return nil, err
+	}
+	s := c.schema
+	spec, ok := s.Properties["spec"]
+	if !ok {
+		return nil, nil
+	}
+	fp := field.NewPath("spec")
+	val, ok := remote.GetUnstructured().UnstructuredContent()["spec"]
+	if !ok {
+		if contains(s.Required, "spec") {
+			return field.ErrorList{field.Required(fp, "")}, nil
+		}
+		return nil, nil
+	}
+	return validateValue(fp, val, &spec), nil
+}
+
+// Prune removes the fields of the spec of the supplied remote instance that
+// its schema does not define, e.g. the ones that a newer version of the local
+// kind has but the remote kind doesn't, and returns their paths. Nothing is
+// removed if the remote API server stores unknown fields as they are.
+func (v *SchemaValidator) Prune(ctx context.Context, remote *claim.Unstructured) ([]string, error) {
+	c, err := v.schema(ctx, remote.GroupVersionKind())
+	if err != nil || c.schema == nil || !c.prunes {
+		return nil, err
+	}
+	spec, ok := c.schema.Properties["spec"]
+	if !ok {
+		return nil, nil
+	}
+	val, ok := remote.GetUnstructured().UnstructuredContent()["spec"]
+	if !ok {
+		return nil, nil
+	}
+	return pruneValue(field.NewPath("spec"), val, &spec), nil
+}
+
+// UnknownFields returns the paths of the fields of the spec of the supplied
+// remote instance that its schema does not define, and removes them if asked
+// to. Unlike Prune, it does so regardless of whether the remote API server
+// stores unknown fields as they are. Kinds whose CRD has no schema have no
+// unknown fields.
+func (v *SchemaValidator) UnknownFields(ctx context.Context, remote *claim.Unstructured, remove bool) ([]string, error) {
+	c, err := v.schema(ctx, remote.GroupVersionKind())
+	if err != nil || c.schema == nil {
+		return nil, err
+	}
+	spec, ok := c.schema.Properties["spec"]
+	if !ok {
+		return nil, nil
+	}
+	val, ok := remote.GetUnstructured().UnstructuredContent()["spec"]
+	if !ok {
+		return nil, nil
+	}
+	if !remove {
+		val = runtime.DeepCopyJSONValue(val)
+	}
+	return pruneValue(field.NewPath("spec"), val, &spec), nil
+}
+
+// NewSchemaPruner returns a new *SchemaPruner that prunes the remote instances
+// according to the schemas of the supplied SchemaValidator.
+func NewSchemaPruner(v *SchemaValidator, log logging.Logger) *SchemaPruner {
+	return &SchemaPruner{schemas: v, log: log}
+}
+
+// SchemaPruner removes the fields of the remote instances that their kind does
+// not define in the remote cluster, e.g. during a version skew, so that they
+// are written without them rather than rejected.
+type SchemaPruner struct {
+	schemas *SchemaValidator
+	log     logging.Logger
+}
+
+// Configure prunes the unknown fields of the supplied remote instance.
+func (p *SchemaPruner) Configure(ctx context.Context, _, remote *claim.Unstructured) error {
+	pruned, err := p.schemas.Prune(ctx, remote)
+	if err != nil {
+		return errors.Wrap(err, remotePrefix+errPruneClaim)
+	}
+	if len(pruned) > 0 {
+		p.log.Debug("Pruned fields unknown to the remote cluster", "name", remote.GetName(), "fields", pruned)
+	}
+	return nil
+}
+
+// schema returns the OpenAPI schema of the given kind. The schema of a kind is
+// reused until the TTL passes so that its CRD isn't read on every
+// reconciliation, yet its updates are eventually taken into account.
+func (v *SchemaValidator) schema(ctx context.Context, gvk schema.GroupVersionKind) (cachedSchema, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok := v.schemas[gvk]; ok && v.now().Sub(c.fetched) < v.ttl {
+		return c, nil
+	}
+	l, err := v.discovery.ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if err != nil {
+		return cachedSchema{}, errors.Wrap(err, errDiscoverResources)
+	}
+	plural := ""
+	for _, r := range l.APIResources {
+		// Subresources, e.g. status, share the kind of their resource.
+		if r.Kind == gvk.Kind && !strings.Contains(r.Name, "/") {
+			plural = r.Name
+			break
+		}
+	}
+	if plural == "" {
+		return cachedSchema{}, errors.Errorf(errResourceNotFound, gvk.Kind)
+	}
+	c, err := v.fetch(ctx, types.NamespacedName{Name: plural + "." + gvk.Group}, gvk.Version)
+	if err != nil {
+		return cachedSchema{}, err
+	}
+	c.fetched = v.now()
+	v.schemas[gvk] = c
+	return c, nil
+}
+
+// fetch returns the schema of the given version of the CRD with the supplied
+// name. The CRD is read at apiextensions.k8s.io/v1, which Kubernetes 1.22 and
+// later serve exclusively, and at v1beta1 if the remote cluster doesn't serve
+// v1 yet.
+func (v *SchemaValidator) fetch(ctx context.Context, nn types.NamespacedName, version string) (cachedSchema, error) {
+	crd := &extv1.CustomResourceDefinition{}
+	err := v.client.Get(ctx, nn, crd)
+	if err == nil {
+		s, err := crdSchemaV1(crd, version)
+		if err != nil {
+			return cachedSchema{}, errors.Wrap(err, errGetCRD)
+		}
+		// The unknown fields of v1 CRDs are pruned unless their schema
+		// preserves them.
+		return cachedSchema{schema: s, prunes: !crd.Spec.PreserveUnknownFields}, nil
+	}
+	if !kmeta.IsNoMatchError(err) && !kerrors.IsNotFound(err) {
+		return cachedSchema{}, errors.Wrap(err, errGetCRD)
+	}
+	legacy := &v1beta1.CustomResourceDefinition{}
+	if err := v.client.Get(ctx, nn, legacy); err != nil {
+		return cachedSchema{}, errors.Wrap(err, errGetCRD)
+	}
+	// The unknown fields are preserved unless the CRD says otherwise.
+	return cachedSchema{
+		schema: crdSchema(legacy, version),
+		prunes: legacy.Spec.PreserveUnknownFields != nil && !*legacy.Spec.PreserveUnknownFields,
+	}, nil
+}
+
+// crdSchemaV1 returns the schema of the given version of the supplied v1 CRD
+// in its v1beta1 form, which has the same fields, so that the schemas of both
+// versions are validated alike.
+func crdSchemaV1(crd *extv1.CustomResourceDefinition, version string) (*v1beta1.JSONSchemaProps, error) {
+	for _, ver := range crd.Spec.Versions {
+		if ver.Name != version || ver.Schema == nil || ver.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		b, err := json.Marshal(ver.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return nil, err
+		}
+		s := &v1beta1.JSONSchemaProps{}
+		return s, json.Unmarshal(b, s)
+	}
+	return nil, nil
+}
+
+// crdSchema returns the schema of the given version of the supplied CRD, which
+// is either specific to the version or shared by all versions.
+func crdSchema(crd *v1beta1.CustomResourceDefinition, version string) *v1beta1.JSONSchemaProps {
+	for _, ver := range crd.Spec.Versions {
+		if ver.Name == version && ver.Schema != nil && ver.Schema.OpenAPIV3Schema != nil {
+			return ver.Schema.OpenAPIV3Schema
+		}
+	}
+	if crd.Spec.Validation != nil {
+		return crd.Spec.Validation.OpenAPIV3Schema
+	}
+	return nil
+}
+
+// validateValue returns the errors of the supplied value at given path against
+// the supplied schema. It covers the constraints that structural schemas of
+// claims use in practice; the remote API server remains the last word.
+func validateValue(fp *field.Path, val interface{}, s *v1beta1.JSONSchemaProps) field.ErrorList { // nolint:gocyclo
+	if val == nil {
+		if s.Nullable {
+			return nil
+		}
+		return field.ErrorList{field.Invalid(fp, val, "must not be null")}
+	}
+	if s.XIntOrString {
+		switch val.(type) {
+		case string, int64, float64:
+			return nil
+		}
+		return field.ErrorList{field.Invalid(fp, val, "must be an integer or a string")}
+	}
+	if s.Type != "" && !hasType(val, s.Type) {
+		return field.ErrorList{field.Invalid(fp, val, fmt.Sprintf("must be of type %s", s.Type))}
+	}
+
+	var errs field.ErrorList
+	if len(s.Enum) > 0 {
+		allowed := make([]string, 0, len(s.Enum))
+		found := false
+		for _, e := range s.Enum {
+			var ev interface{}
+			if err := json.Unmarshal(e.Raw, &ev); err != nil {
+				continue
+			}
+			if jsonEqual(ev, val) {
+				found = true
+				break
+			}
+			allowed = append(allowed, string(e.Raw))
+		}
+		if !found {
+			errs = append(errs, field.NotSupported(fp, val, allowed))
+		}
+	}
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for _, r := range s.Required {
+			if _, ok := v[r]; !ok {
+				errs = append(errs, field.Required(fp.Child(r), ""))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// The errors are reported in a stable order.
+		sort.Strings(keys)
+		for _, k := range keys {
+			fv := v[k]
+			if ps, ok := s.Properties[k]; ok {
+				p := ps
+				errs = append(errs, validateValue(fp.Child(k), fv, &p)...)
+				continue
+			}
+			if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				errs = append(errs, validateValue(fp.Key(k), fv, s.AdditionalProperties.Schema)...)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && int64(len(v)) < *s.MinItems {
+			errs = append(errs, field.Invalid(fp, val, fmt.Sprintf("must have at least %d items", *s.MinItems)))
+		}
+		if s.MaxItems != nil && int64(len(v)) > *s.MaxItems {
+			errs = append(errs, field.TooMany(fp, len(v), int(*s.MaxItems)))
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, iv := range v {
+				errs = append(errs, validateValue(fp.Index(i), iv, s.Items.Schema)...)
+			}
+		}
+	case string:
+		if s.MinLength != nil && int64(len(v)) < *s.MinLength {
+			errs = append(errs, field.Invalid(fp, val, fmt.Sprintf("must be at least %d characters long", *s.MinLength)))
+		}
+		if s.MaxLength != nil && int64(len(v)) > *s.MaxLength {
+			errs = append(errs, field.TooLong(fp, val, int(*s.MaxLength)))
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				errs = append(errs, field.Invalid(fp, val, fmt.Sprintf("must match %q", s.Pattern)))
+			}
+		}
+	case int64, float64:
+		n := toFloat(v)
+		if s.Minimum != nil && (n < *s.Minimum || (s.ExclusiveMinimum && n == *s.Minimum)) {
+			errs = append(errs, field.Invalid(fp, val, fmt.Sprintf("must be greater than or equal to %v", *s.Minimum)))
+		}
+		if s.Maximum != nil && (n > *s.Maximum || (s.ExclusiveMaximum && n == *s.Maximum)) {
+			errs = append(errs, field.Invalid(fp, val, fmt.Sprintf("must be less than or equal to %v", *s.Maximum)))
+		}
+	}
+	return errs
+}
+
+// pruneValue removes the fields of the supplied value at given path that the
+// supplied schema does not define, the way the API server prunes structural
+// schemas, and returns their paths in a stable order.
+func pruneValue(fp *field.Path, val interface{}, s *v1beta1.JSONSchemaProps) []string {
+	var pruned []string
+	switch v := val.(type) {
+	case map[string]interface{}:
+		preserve := s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ps, ok := s.Properties[k]; ok {
+				p := ps
+				pruned = append(pruned, pruneValue(fp.Child(k), v[k], &p)...)
+				continue
+			}
+			switch {
+			case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+				pruned = append(pruned, pruneValue(fp.Key(k), v[k], s.AdditionalProperties.Schema)...)
+			case s.AdditionalProperties != nil && s.AdditionalProperties.Allows, preserve:
+			default:
+				delete(v, k)
+				pruned = append(pruned, fp.Child(k).String())
+			}
+		}
+	case []interface{}:
+		if s.Items == nil || s.Items.Schema == nil {
+			return nil
+		}
+		for i, iv := range v {
+			pruned = append(pruned, pruneValue(fp.Index(i), iv, s.Items.Schema)...)
+		}
+	}
+	return pruned
+}
+
+// hasType returns whether the supplied value, as decoded from JSON by the
+// unstructured client, is of the given OpenAPI type.
+func hasType(val interface{}, t string) bool {
+	switch val.(type) {
+	case map[string]interface{}:
+		return t == "object"
+	case []interface{}:
+		return t == "array"
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case int64:
+		return t == "integer" || t == "number"
+	case float64:
+		f := val.(float64)
+		return t == "number" || (t == "integer" && f == float64(int64(f)))
+	}
+	return false
+}
+
+func toFloat(val interface{}) float64 {
+	switch v := val.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// jsonEqual compares values decoded from JSON, treating numbers of different
+// Go types as equal if they have the same value.
+func jsonEqual(a, b interface{}) bool {
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ab) == string(bb)
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,405 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func int64Ptr(i int64) *int64       { return &i }
+func float64Ptr(f float64) *float64 { return &f }
+
+// newSchemaCRD returns the CRD of scopedGVK with a schema that requires a size
+// between 1 and 100, and allows only some engines.
+func newSchemaCRD() *v1beta1.CustomResourceDefinition {
+	return &v1beta1.CustomResourceDefinition{
+		Spec: v1beta1.CustomResourceDefinitionSpec{
+			Versions: []v1beta1.CustomResourceDefinitionVersion{{
+				Name: scopedGVK.Version,
+				Schema: &v1beta1.CustomResourceValidation{OpenAPIV3Schema: &v1beta1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]v1beta1.JSONSchemaProps{
+						"spec": {
+							Type:     "object",
+							Required: []string{"size"},
+							Properties: map[string]v1beta1.JSONSchemaProps{
+								"size":   {Type: "integer", Minimum: float64Ptr(1), Maximum: float64Ptr(100)},
+								"engine": {Type: "string", Enum: []v1beta1.JSON{{Raw: []byte(`"postgres"`)}, {Raw: []byte(`"mysql"`)}}},
+								"name":   {Type: "string", MaxLength: int64Ptr(8)},
+								"zones": {Type: "array", MaxItems: int64Ptr(2), Items: &v1beta1.JSONSchemaPropsOrArray{
+									Schema: &v1beta1.JSONSchemaProps{Type: "string"},
+								}},
+								"tags": {Type: "object", AdditionalProperties: &v1beta1.JSONSchemaPropsOrBool{
+									Allows: true,
+									Schema: &v1beta1.JSONSchemaProps{Type: "string"},
+								}},
+							},
+						},
+					},
+				}},
+			}},
+		},
+	}
+}
+
+// newMockCRDGetFn returns a MockGetFn of a remote cluster that serves the
+// supplied CRD only at apiextensions.k8s.io/v1beta1.
+func newMockCRDGetFn(crd *v1beta1.CustomResourceDefinition, err error) test.MockGetFn {
+	return func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := obj.(*extv1.CustomResourceDefinition); ok {
+			return &kmeta.NoKindMatchError{GroupKind: extv1.Kind("CustomResourceDefinition"), SearchedVersions: []string{"v1"}}
+		}
+		if key.Name != "databases."+scopedGVK.Group {
+			return errors.Errorf("unexpected CRD %s", key.Name)
+		}
+		crd.DeepCopyInto(obj.(*v1beta1.CustomResourceDefinition))
+		return nil
+	}
+}
+
+func TestSchemaValidatorValidate(t *testing.T) {
+	specPath := field.NewPath("spec")
+
+	type args struct {
+		crd  *v1beta1.CustomResourceDefinition
+		err  error
+		spec map[string]interface{}
+	}
+	type want struct {
+		invalid field.ErrorList
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Valid": {
+			reason: "A spec that the schema allows should not be invalid",
+			args: args{
+				crd: newSchemaCRD(),
+				spec: map[string]interface{}{
+					"size":   int64(10),
+					"engine": "postgres",
+					"zones":  []interface{}{"a", "b"},
+					"tags":   map[string]interface{}{"team": "cool"},
+					"extra":  "preserved",
+				},
+			},
+		},
+		"MissingRequired": {
+			reason: "A spec that lacks a required field should be invalid",
+			args: args{
+				crd:  newSchemaCRD(),
+				spec: map[string]interface{}{"engine": "mysql"},
+			},
+			want: want{
+				invalid: field.ErrorList{field.Required(specPath.Child("size"), "")},
+			},
+		},
+		"WrongType": {
+			reason: "A field of the wrong type should be invalid",
+			args: args{
+				crd:  newSchemaCRD(),
+				spec: map[string]interface{}{"size": "ten"},
+			},
+			want: want{
+				invalid: field.ErrorList{field.Invalid(specPath.Child("size"), "ten", "must be of type integer")},
+			},
+		},
+		"OutOfRange": {
+			reason: "A number out of the range of the schema should be invalid",
+			args: args{
+				crd:  newSchemaCRD(),
+				spec: map[string]interface{}{"size": int64(200)},
+			},
+			want: want{
+				invalid: field.ErrorList{field.Invalid(specPath.Child("size"), int64(200), "must be less than or equal to 100")},
+			},
+		},
+		"NotSupported": {
+			reason: "A value that is not in the enum of the schema should be invalid",
+			args: args{
+				crd:  newSchemaCRD(),
+				spec: map[string]interface{}{"size": int64(10), "engine": "oracle"},
+			},
+			want: want{
+				invalid: field.ErrorList{field.NotSupported(specPath.Child("engine"), "oracle", []string{`"postgres"`, `"mysql"`})},
+			},
+		},
+		"NestedErrors": {
+			reason: "The errors of nested fields should point to them",
+			args: args{
+				crd: newSchemaCRD(),
+				spec: map[string]interface{}{
+					"size":  int64(10),
+					"name":  "way-too-long",
+					"zones": []interface{}{"a", int64(1), "c"},
+					"tags":  map[string]interface{}{"team": true},
+				},
+			},
+			want: want{
+				invalid: field.ErrorList{
+					field.TooLong(specPath.Child("name"), "way-too-long", 8),
+					field.Invalid(specPath.Child("tags").Key("team"), true, "must be of type string"),
+					field.TooMany(specPath.Child("zones"), 3, 2),
+					field.Invalid(specPath.Child("zones").Index(1), int64(1), "must be of type string"),
+				},
+			},
+		},
+		"NoSchema": {
+			reason: "A spec should never be invalid if the CRD has no schema",
+			args: args{
+				crd:  &v1beta1.CustomResourceDefinition{},
+				spec: map[string]interface{}{"size": "ten"},
+			},
+		},
+		"GetCRDFailed": {
+			reason: "An error should be returned if the CRD cannot be read",
+			args: args{
+				err:  errBoom,
+				spec: map[string]interface{}{"size": int64(10)},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetCRD),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := NewSchemaValidator(newScopedDiscovery(true), &test.MockClient{MockGet: newMockCRDGetFn(tc.args.crd, tc.args.err)})
+			remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			remote.Object["spec"] = tc.args.spec
+
+			invalid, err := v.Validate(context.Background(), remote)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nv.Validate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.invalid, invalid); diff != "" {
+				t.Errorf("\nReason: %s\nv.Validate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSchemaValidatorCache(t *testing.T) {
+	reads := 0
+	c := &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+		if _, ok := obj.(*v1beta1.CustomResourceDefinition); ok {
+			reads++
+		}
+		return newMockCRDGetFn(newSchemaCRD(), nil)(ctx, key, obj)
+	}}
+	v := NewSchemaValidator(newScopedDiscovery(true), c, WithSchemaTTL(time.Minute))
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+	remote.Object["spec"] = map[string]interface{}{"size": int64(10)}
+	for i := 0; i < 3; i++ {
+		if _, err := v.Validate(context.Background(), remote); err != nil {
+			t.Fatalf("v.Validate(...): unexpected error: %s", err)
+		}
+	}
+	if diff := cmp.Diff(1, reads); diff != "" {
+		t.Errorf("\nReason: %s\nreads: -want, +got:\n%s", "The CRD should be read only once within the TTL", diff)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := v.Validate(context.Background(), remote); err != nil {
+		t.Fatalf("v.Validate(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(2, reads); diff != "" {
+		t.Errorf("\nReason: %s\nreads: -want, +got:\n%s", "The CRD should be read again once the TTL passes", diff)
+	}
+}
+
+// newPruningCRD returns the CRD of scopedGVK whose API server prunes the
+// fields its schema does not define.
+func newPruningCRD() *v1beta1.CustomResourceDefinition {
+	crd := newSchemaCRD()
+	preserve := false
+	crd.Spec.PreserveUnknownFields = &preserve
+	spec := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+	keep := true
+	spec.Properties["disks"] = v1beta1.JSONSchemaProps{Type: "array", Items: &v1beta1.JSONSchemaPropsOrArray{
+		Schema: &v1beta1.JSONSchemaProps{Type: "object", Properties: map[string]v1beta1.JSONSchemaProps{
+			"size": {Type: "integer"},
+		}},
+	}}
+	spec.Properties["parameters"] = v1beta1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: &keep}
+	crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
+	return crd
+}
+
+func TestSchemaValidatorPrune(t *testing.T) {
+	type args struct {
+		crd  *v1beta1.CustomResourceDefinition
+		spec map[string]interface{}
+	}
+	type want struct {
+		pruned []string
+		spec   map[string]interface{}
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"UnknownField": {
+			reason: "A field that the schema does not define should be pruned",
+			args: args{
+				crd:  newPruningCRD(),
+				spec: map[string]interface{}{"size": int64(10), "storageTier": "gold"},
+			},
+			want: want{
+				pruned: []string{"spec.storageTier"},
+				spec:   map[string]interface{}{"size": int64(10)},
+			},
+		},
+		"NestedUnknownFields": {
+			reason: "The unknown fields of objects in arrays should be pruned",
+			args: args{
+				crd: newPruningCRD(),
+				spec: map[string]interface{}{
+					"size":  int64(10),
+					"disks": []interface{}{map[string]interface{}{"size": int64(1), "iops": int64(3000)}},
+				},
+			},
+			want: want{
+				pruned: []string{"spec.disks[0].iops"},
+				spec: map[string]interface{}{
+					"size":  int64(10),
+					"disks": []interface{}{map[string]interface{}{"size": int64(1)}},
+				},
+			},
+		},
+		"Preserved": {
+			reason: "The fields of objects that preserve unknown fields or allow additional properties should not be pruned",
+			args: args{
+				crd: newPruningCRD(),
+				spec: map[string]interface{}{
+					"size":       int64(10),
+					"parameters": map[string]interface{}{"anything": "goes"},
+					"tags":       map[string]interface{}{"team": "cool"},
+				},
+			},
+			want: want{
+				spec: map[string]interface{}{
+					"size":       int64(10),
+					"parameters": map[string]interface{}{"anything": "goes"},
+					"tags":       map[string]interface{}{"team": "cool"},
+				},
+			},
+		},
+		"CRDPreservesUnknownFields": {
+			reason: "Nothing should be pruned if the remote API server stores unknown fields as they are",
+			args: args{
+				crd:  newSchemaCRD(),
+				spec: map[string]interface{}{"size": int64(10), "storageTier": "gold"},
+			},
+			want: want{
+				spec: map[string]interface{}{"size": int64(10), "storageTier": "gold"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := NewSchemaValidator(newScopedDiscovery(true), &test.MockClient{MockGet: newMockCRDGetFn(tc.args.crd, nil)})
+			remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			remote.Object["spec"] = tc.args.spec
+
+			pruned, err := v.Prune(context.Background(), remote)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nv.Prune(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pruned, pruned); diff != "" {
+				t.Errorf("\nReason: %s\nv.Prune(...): -want pruned, +got pruned:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.spec, remote.Object["spec"]); diff != "" {
+				t.Errorf("\nReason: %s\nv.Prune(...): -want spec, +got spec:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSchemaValidatorV1(t *testing.T) {
+	crd := &extv1.CustomResourceDefinition{
+		Spec: extv1.CustomResourceDefinitionSpec{
+			Versions: []extv1.CustomResourceDefinitionVersion{{
+				Name: scopedGVK.Version,
+				Schema: &extv1.CustomResourceValidation{OpenAPIV3Schema: &extv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]extv1.JSONSchemaProps{
+						"spec": {
+							Type:     "object",
+							Required: []string{"size"},
+							Properties: map[string]extv1.JSONSchemaProps{
+								"size": {Type: "integer", Maximum: float64Ptr(100)},
+							},
+						},
+					},
+				}},
+			}},
+		},
+	}
+	kube := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		c, ok := obj.(*extv1.CustomResourceDefinition)
+		if !ok {
+			return errors.New("CRD should be read at apiextensions.k8s.io/v1")
+		}
+		crd.DeepCopyInto(c)
+		return nil
+	}}
+	v := NewSchemaValidator(newScopedDiscovery(true), kube)
+	remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+	remote.Object["spec"] = map[string]interface{}{"size": int64(200), "storageTier": "gold"}
+
+	invalid, err := v.Validate(context.Background(), remote)
+	if err != nil {
+		t.Fatalf("v.Validate(...): %s", err)
+	}
+	want := field.ErrorList{field.Invalid(field.NewPath("spec", "size"), int64(200), "must be less than or equal to 100")}
+	if diff := cmp.Diff(want, invalid); diff != "" {
+		t.Errorf("\nReason: %s\nv.Validate(...): -want, +got:\n%s", "The schema of a v1 CRD should be validated against", diff)
+	}
+	pruned, err := v.Prune(context.Background(), remote)
+	if err != nil {
+		t.Fatalf("v.Prune(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"spec.storageTier"}, pruned); diff != "" {
+		t.Errorf("\nReason: %s\nv.Prune(...): -want pruned, +got pruned:\n%s", "The unknown fields should be pruned since v1 CRDs don't preserve them", diff)
+	}
+}
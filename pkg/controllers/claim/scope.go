@@ -0,0 +1,260 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errDiscoverResources = "cannot discover API resources"
+	errKindNotServedFmt  = "remote cluster does not serve kind %s"
+	errScopeMismatchFmt  = "kind %s is cluster-scoped in the local cluster but namespaced in the remote cluster and no remote namespace is configured"
+	errGetNamespace      = "cannot get remote namespace"
+	errCreateNamespace   = "cannot create remote namespace"
+	errNotAllowedFmt     = "remote namespace %s is not in the allowlist"
+)
+
+// ClusterNamespace returns the remote namespace of the instances synced by the
+// local cluster with the supplied ID.
+func ClusterNamespace(id string) string {
+	return "cluster-" + id
+}
+
+// ValidateClusterID returns an error if the namespace of the local cluster with
+// the supplied ID is not a valid namespace name.
+func ValidateClusterID(id string) error {
+	if errs := validation.IsDNS1123Label(ClusterNamespace(id)); len(errs) > 0 {
+		return errors.Errorf("cluster ID %q is invalid: %s", id, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// A ScopeResolverOption configures a ScopeResolver.
+type ScopeResolverOption func(*ScopeResolver)
+
+// WithDefaultRemoteNamespace specifies the namespace of the remote instances
+// of the kinds that are cluster-scoped in the local cluster but namespaced in
+// the remote cluster.
+func WithDefaultRemoteNamespace(ns string) ScopeResolverOption {
+	return func(s *ScopeResolver) {
+		s.namespace = ns
+	}
+}
+
+// WithNamespaceMappings specifies the namespaces of the remote instances whose
+// local instances are in the given namespaces. The remote instances of the local
+// instances in other namespaces are in the same namespace.
+func WithNamespaceMappings(m map[string]string) ScopeResolverOption {
+	return func(s *ScopeResolver) {
+		s.mappings = m
+	}
+}
+
+// WithClusterNamespace places all namespaced remote instances in the namespace
+// of the local cluster with the given ID, regardless of the namespaces of their
+// local instances, so that every local cluster connected to the same remote
+// cluster writes to its own namespace. See ClusterNamespace.
+func WithClusterNamespace(id string) ScopeResolverOption {
+	return func(s *ScopeResolver) {
+		s.cluster = id
+	}
+}
+
+// WithAllowedRemoteNamespaces restricts the namespaces the ScopeResolver may
+// resolve to the given ones, as a safety net against a mapping or a cluster ID
+// that points the remote instances elsewhere. The remote instances of the kinds
+// that are cluster-scoped in the remote cluster are not restricted. Any
+// namespace is allowed if none is given.
+func WithAllowedRemoteNamespaces(ns ...string) ScopeResolverOption {
+	return func(s *ScopeResolver) {
+		s.allowed = map[string]bool{}
+		for _, n := range ns {
+			s.allowed[n] = true
+		}
+	}
+}
+
+// WithNamespaceCreation makes the ScopeResolver create the namespaces of the
+// remote instances it configures with the given client of the remote cluster
+// if they don't exist. A RemoteClientSelector creates them in the remote
+// cluster of each local instance.
+func WithNamespaceCreation(c client.Client) ScopeResolverOption {
+	return func(s *ScopeResolver) {
+		s.client = c
+	}
+}
+
+// NewScopeResolver returns a new *ScopeResolver that uses the given discovery
+// client of the remote cluster.
+func NewScopeResolver(d discovery.ServerResourcesInterface, opts ...ScopeResolverOption) *ScopeResolver {
+	s := &ScopeResolver{
+		discovery:  d,
+		namespaced: map[schema.GroupVersionKind]bool{},
+		created:    map[string]bool{},
+	}
+	for _, f := range opts {
+		f(s)
+	}
+	return s
+}
+
+// ScopeResolver determines the namespace of the remote instances according to
+// the scope of their kind in the remote cluster, which may differ from the one
+// in the local cluster, e.g. during a migration.
+type ScopeResolver struct {
+	discovery discovery.ServerResourcesInterface
+	namespace string
+	mappings  map[string]string
+	cluster   string
+	allowed   map[string]bool
+	client    client.Client
+
+	mu         sync.Mutex
+	namespaced map[schema.GroupVersionKind]bool
+	created    map[string]bool
+}
+
+// RemoteNamespace returns the namespace of the remote instance of the given
+// kind whose local instance is in the given namespace. An empty namespace
+// means that the kind is cluster-scoped. An error is returned if the namespace
+// is not allowed.
+func (s *ScopeResolver) RemoteNamespace(gvk schema.GroupVersionKind, local string) (string, error) {
+	namespaced, err := s.isNamespaced(gvk)
+	if err != nil || !namespaced {
+		return "", err
+	}
+	ns, err := s.resolve(gvk, local)
+	if err != nil {
+		return "", err
+	}
+	if len(s.allowed) > 0 && !s.allowed[ns] {
+		return "", errors.Errorf(errNotAllowedFmt, ns)
+	}
+	return ns, nil
+}
+
+// resolve returns the namespace of the remote instance of the given namespaced
+// kind whose local instance is in the given namespace.
+func (s *ScopeResolver) resolve(gvk schema.GroupVersionKind, local string) (string, error) {
+	switch {
+	case s.cluster != "":
+		return ClusterNamespace(s.cluster), nil
+	case s.mappings[local] != "":
+		return s.mappings[local], nil
+	case local != "":
+		return local, nil
+	case s.namespace != "":
+		return s.namespace, nil
+	default:
+		return "", errors.Errorf(errScopeMismatchFmt, gvk.Kind)
+	}
+}
+
+// Configure sets the namespace of the supplied remote instance according to
+// the scope of its kind in the remote cluster, and creates the namespace if
+// namespace creation is enabled.
+func (s *ScopeResolver) Configure(ctx context.Context, local, remote *claim.Unstructured) error {
+	ns, err := s.RemoteNamespace(remote.GroupVersionKind(), local.GetNamespace())
+	if err != nil {
+		return err
+	}
+	if err := s.ensureNamespace(ctx, ns); err != nil {
+		return err
+	}
+	remote.SetNamespace(ns)
+	return nil
+}
+
+// ensureNamespace creates the supplied namespace in the remote cluster if it
+// doesn't exist. Each namespace is checked until it exists, and is assumed to
+// stay around once it does. The remote cluster is called without holding the
+// lock, so that a slow call doesn't hold up the other namespaces; concurrent
+// calls for the same namespace at worst both create it.
+func (s *ScopeResolver) ensureNamespace(ctx context.Context, name string) error {
+	if s.client == nil || name == "" {
+		return nil
+	}
+	// The same namespace is created in each remote cluster it's used in.
+	key := name
+	if rs, ok := s.client.(*RemoteClientSelector); ok {
+		key = rs.Cluster(ctx) + "/" + name
+	}
+	s.mu.Lock()
+	created := s.created[key]
+	s.mu.Unlock()
+	if created {
+		return nil
+	}
+	err := s.client.Get(ctx, types.NamespacedName{Name: name}, &corev1.Namespace{})
+	if kerrors.IsNotFound(err) {
+		err = s.client.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		if kerrors.IsAlreadyExists(err) {
+			err = nil
+		}
+		if err != nil {
+			return errors.Wrap(err, errCreateNamespace)
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, errGetNamespace)
+	}
+	s.mu.Lock()
+	s.created[key] = true
+	s.mu.Unlock()
+	return nil
+}
+
+// isNamespaced returns whether the given kind is namespaced in the remote
+// cluster. The scope of a kind is discovered until it's known since it doesn't
+// change without the kind being reinstalled. Like ensureNamespace, the remote
+// cluster is called without holding the lock.
+func (s *ScopeResolver) isNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	s.mu.Lock()
+	n, ok := s.namespaced[gvk]
+	s.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+	l, err := s.discovery.ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if err != nil {
+		return false, errors.Wrap(err, errDiscoverResources)
+	}
+	for _, r := range l.APIResources {
+		if r.Kind == gvk.Kind {
+			s.mu.Lock()
+			s.namespaced[gvk] = r.Namespaced
+			s.mu.Unlock()
+			return r.Namespaced, nil
+		}
+	}
+	return false, errors.Errorf(errKindNotServedFmt, gvk.Kind)
+}
@@ -0,0 +1,453 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var scopedGVK = schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Database"}
+
+func newScopedDiscovery(namespaced bool) *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{
+		Resources: []*metav1.APIResourceList{{
+			GroupVersion: scopedGVK.GroupVersion().String(),
+			APIResources: []metav1.APIResource{{Name: "databases", Kind: scopedGVK.Kind, Namespaced: namespaced}},
+		}},
+	}}
+}
+
+func TestScopeResolverRemoteNamespace(t *testing.T) {
+	type args struct {
+		discovery *fakediscovery.FakeDiscovery
+		opts      []ScopeResolverOption
+		gvk       schema.GroupVersionKind
+		local     string
+	}
+	type want struct {
+		ns  string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"SameScope": {
+			reason: "The remote instance should be in the namespace of the local one if the kind is namespaced in both clusters",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				gvk:       scopedGVK,
+				local:     "cool-ns",
+			},
+			want: want{
+				ns: "cool-ns",
+			},
+		},
+		"NamespaceMapped": {
+			reason: "The remote instance should be in the mapped namespace if there is a mapping for the local one",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				opts:      []ScopeResolverOption{WithNamespaceMappings(map[string]string{"cool-ns": "remote-ns"})},
+				gvk:       scopedGVK,
+				local:     "cool-ns",
+			},
+			want: want{
+				ns: "remote-ns",
+			},
+		},
+		"ClusterNamespace": {
+			reason: "The remote instance should be in the namespace of the local cluster regardless of the namespace of the local instance",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				opts: []ScopeResolverOption{
+					WithClusterNamespace("cool-cluster"),
+					WithNamespaceMappings(map[string]string{"cool-ns": "remote-ns"}),
+				},
+				gvk:   scopedGVK,
+				local: "cool-ns",
+			},
+			want: want{
+				ns: "cluster-cool-cluster",
+			},
+		},
+		"MappedAllowed": {
+			reason: "The remote instance should be in the mapped namespace if it's in the allowlist",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				opts: []ScopeResolverOption{
+					WithNamespaceMappings(map[string]string{"cool-ns": "remote-ns"}),
+					WithAllowedRemoteNamespaces("remote-ns", "other-ns"),
+				},
+				gvk:   scopedGVK,
+				local: "cool-ns",
+			},
+			want: want{
+				ns: "remote-ns",
+			},
+		},
+		"MappedNotAllowed": {
+			reason: "An error should be returned if the mapped namespace is not in the allowlist",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				opts: []ScopeResolverOption{
+					WithNamespaceMappings(map[string]string{"cool-ns": "kube-system"}),
+					WithAllowedRemoteNamespaces("remote-ns"),
+				},
+				gvk:   scopedGVK,
+				local: "cool-ns",
+			},
+			want: want{
+				err: errors.Errorf(errNotAllowedFmt, "kube-system"),
+			},
+		},
+		"SameNamespaceNotAllowed": {
+			reason: "An error should be returned if the namespace of the local instance is not in the allowlist",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				opts:      []ScopeResolverOption{WithAllowedRemoteNamespaces("remote-ns")},
+				gvk:       scopedGVK,
+				local:     "cool-ns",
+			},
+			want: want{
+				err: errors.Errorf(errNotAllowedFmt, "cool-ns"),
+			},
+		},
+		"ClusterScopedNotRestricted": {
+			reason: "The remote instance of a kind that is cluster-scoped in the remote cluster should not be restricted",
+			args: args{
+				discovery: newScopedDiscovery(false),
+				opts:      []ScopeResolverOption{WithAllowedRemoteNamespaces("remote-ns")},
+				gvk:       scopedGVK,
+				local:     "cool-ns",
+			},
+			want: want{
+				ns: "",
+			},
+		},
+		"ClusterNamespaceOfClusterScoped": {
+			reason: "The remote instance should be in the namespace of the local cluster if its kind is cluster-scoped only in the local cluster",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				opts:      []ScopeResolverOption{WithClusterNamespace("cool-cluster")},
+				gvk:       scopedGVK,
+			},
+			want: want{
+				ns: "cluster-cool-cluster",
+			},
+		},
+		"ClusterNamespaceRemoteClusterScoped": {
+			reason: "The namespace should be cleared if the kind is cluster-scoped in the remote cluster, even if there is a namespace for the local cluster",
+			args: args{
+				discovery: newScopedDiscovery(false),
+				opts:      []ScopeResolverOption{WithClusterNamespace("cool-cluster")},
+				gvk:       scopedGVK,
+				local:     "cool-ns",
+			},
+			want: want{
+				ns: "",
+			},
+		},
+		"RemoteClusterScoped": {
+			reason: "The namespace should be cleared if the kind is cluster-scoped in the remote cluster",
+			args: args{
+				discovery: newScopedDiscovery(false),
+				gvk:       scopedGVK,
+				local:     "cool-ns",
+			},
+			want: want{
+				ns: "",
+			},
+		},
+		"RemoteNamespacedWithDefault": {
+			reason: "The default remote namespace should be set if the kind is cluster-scoped only in the local cluster",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				opts:      []ScopeResolverOption{WithDefaultRemoteNamespace("remote-ns")},
+				gvk:       scopedGVK,
+			},
+			want: want{
+				ns: "remote-ns",
+			},
+		},
+		"RemoteNamespacedWithoutDefault": {
+			reason: "An error should be returned if the kind is cluster-scoped only in the local cluster and there is no default remote namespace",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				gvk:       scopedGVK,
+			},
+			want: want{
+				err: errors.Errorf(errScopeMismatchFmt, scopedGVK.Kind),
+			},
+		},
+		"KindNotServed": {
+			reason: "An error should be returned if the kind is not served by the remote cluster",
+			args: args{
+				discovery: newScopedDiscovery(true),
+				gvk:       scopedGVK.GroupVersion().WithKind("Cache"),
+				local:     "cool-ns",
+			},
+			want: want{
+				err: errors.Errorf(errKindNotServedFmt, "Cache"),
+			},
+		},
+		"DiscoveryFailed": {
+			reason: "An error should be returned if the resources of the group cannot be discovered",
+			args: args{
+				discovery: &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}},
+				gvk:       scopedGVK,
+				local:     "cool-ns",
+			},
+			want: want{
+				err: errors.Wrap(fmt.Errorf("GroupVersion %q not found", scopedGVK.GroupVersion().String()), errDiscoverResources),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := NewScopeResolver(tc.discovery, tc.opts...)
+			ns, err := s.RemoteNamespace(tc.gvk, tc.local)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\ns.RemoteNamespace(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ns, ns); diff != "" {
+				t.Errorf("\nReason: %s\ns.RemoteNamespace(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestScopeResolverConfigure(t *testing.T) {
+	d := newScopedDiscovery(false)
+	s := NewScopeResolver(d)
+	for i := 0; i < 2; i++ {
+		local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		local.SetNamespace("cool-ns")
+		remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		remote.SetNamespace("cool-ns")
+		if err := s.Configure(context.Background(), local, remote); err != nil {
+			t.Fatalf("s.Configure(...): unexpected error: %s", err)
+		}
+		if diff := cmp.Diff("", remote.GetNamespace()); diff != "" {
+			t.Errorf("\nReason: %s\ns.Configure(...): -want, +got:\n%s", "The namespace of the remote instance should be cleared if its kind is cluster-scoped remotely", diff)
+		}
+	}
+	if diff := cmp.Diff(1, len(d.Actions())); diff != "" {
+		t.Errorf("\nReason: %s\ndiscovery calls: -want, +got:\n%s", "The scope of a kind should be discovered only once", diff)
+	}
+}
+
+func TestScopeResolverNamespaceCreation(t *testing.T) {
+	type args struct {
+		getErr    error
+		createErr error
+	}
+	type want struct {
+		created []string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Missing": {
+			reason: "The namespace of the local cluster should be created once if it doesn't exist",
+			args: args{
+				getErr: kerrors.NewNotFound(schema.GroupResource{}, ""),
+			},
+			want: want{
+				created: []string{"cluster-cool-cluster"},
+			},
+		},
+		"Exists": {
+			reason: "The namespace of the local cluster should not be created if it exists",
+		},
+		"CreatedConcurrently": {
+			reason: "A namespace that was created in the meantime should not be an error",
+			args: args{
+				getErr:    kerrors.NewNotFound(schema.GroupResource{}, ""),
+				createErr: kerrors.NewAlreadyExists(schema.GroupResource{}, ""),
+			},
+			want: want{
+				created: []string{"cluster-cool-cluster"},
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the namespace cannot be fetched",
+			args: args{
+				getErr: errBoom,
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetNamespace),
+			},
+		},
+		"CreateFailed": {
+			reason: "An error should be returned if the namespace cannot be created",
+			args: args{
+				getErr:    kerrors.NewNotFound(schema.GroupResource{}, ""),
+				createErr: errBoom,
+			},
+			want: want{
+				created: []string{"cluster-cool-cluster"},
+				err:     errors.Wrap(errBoom, errCreateNamespace),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			c := &test.MockClient{
+				MockGet: test.NewMockGetFn(tc.args.getErr),
+				MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+					created = append(created, obj.(*corev1.Namespace).GetName())
+					return tc.args.createErr
+				},
+			}
+			s := NewScopeResolver(newScopedDiscovery(true), WithClusterNamespace("cool-cluster"), WithNamespaceCreation(c))
+			local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			local.SetNamespace("cool-ns")
+			remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+
+			// Configuring a second instance must not check the namespace again.
+			err := s.Configure(context.Background(), local, remote)
+			if err == nil {
+				err = s.Configure(context.Background(), local, remote)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\ns.Configure(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\nReason: %s\ncreated namespaces: -want, +got:\n%s", tc.reason, diff)
+			}
+			if err == nil {
+				if diff := cmp.Diff("cluster-cool-cluster", remote.GetNamespace()); diff != "" {
+					t.Errorf("\nReason: %s\ns.Configure(...): -want namespace, +got namespace:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestScopeResolverNamespaceCreationPerCluster(t *testing.T) {
+	var created []string
+	remote := func(name string) client.Client {
+		return &test.MockClient{
+			MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+			MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+				created = append(created, name+"/"+obj.(*corev1.Namespace).GetName())
+				return nil
+			},
+		}
+	}
+	c := NewRemoteClientSelector(remote("default"), WithNamespaceRemotes(map[string]client.Client{"team-a": remote("a")}))
+	s := NewScopeResolver(newScopedDiscovery(true), WithClusterNamespace("cool-cluster"), WithNamespaceCreation(c))
+	for _, ns := range []string{"team-a", "team-b", "team-a", "team-b"} {
+		local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		local.SetNamespace(ns)
+		remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		if err := s.Configure(WithLocalNamespace(context.Background(), ns), local, remote); err != nil {
+			t.Fatalf("\nReason: %s\ns.Configure(...): unexpected error: %s", "The namespace should be created", err)
+		}
+	}
+	want := []string{"a/cluster-cool-cluster", "default/cluster-cool-cluster"}
+	if diff := cmp.Diff(want, created); diff != "" {
+		t.Errorf("\nReason: %s\ncreated namespaces: -want, +got:\n%s", "The namespace should be created once in each remote cluster it's used in", diff)
+	}
+}
+
+func TestScopeResolverNamespaceCreationConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	c := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+			if key.Name == "slow-ns" {
+				<-release
+			}
+			return nil
+		},
+	}
+	s := NewScopeResolver(newScopedDiscovery(true), WithNamespaceCreation(c))
+
+	// The first caller waits for a remote cluster that is slow to answer.
+	slow := make(chan error, 1)
+	go func() { slow <- s.ensureNamespace(context.Background(), "slow-ns") }()
+	time.Sleep(10 * time.Millisecond)
+
+	fast := make(chan error, 1)
+	go func() { fast <- s.ensureNamespace(context.Background(), "fast-ns") }()
+	select {
+	case err := <-fast:
+		if err != nil {
+			t.Errorf("\nReason: %s\ns.ensureNamespace(...): %s", "An existing namespace should be ensured", err)
+		}
+	case <-time.After(250 * time.Millisecond):
+		t.Errorf("\nReason: %s\ns.ensureNamespace(...): timed out", "A caller should not wait for another caller that is still waiting for the remote cluster")
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Errorf("\nReason: %s\ns.ensureNamespace(...): %s", "An existing namespace should be ensured", err)
+	}
+}
+
+func TestValidateClusterID(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		id     string
+		err    bool
+	}{
+		"Valid": {
+			reason: "An ID that makes a valid namespace name should be valid",
+			id:     "eu-west-1",
+		},
+		"Uppercase": {
+			reason: "An ID that makes a namespace name with uppercase letters should be rejected",
+			id:     "EU-West-1",
+			err:    true,
+		},
+		"TooLong": {
+			reason: "An ID that makes a namespace name longer than a DNS label should be rejected",
+			id:     strings.Repeat("a", 60),
+			err:    true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateClusterID(tc.id)
+			if diff := cmp.Diff(tc.err, err != nil); diff != "" {
+				t.Errorf("\nReason: %s\nValidateClusterID(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+const (
+	errNewController = "cannot create controller"
+	errWatchSecrets  = "cannot watch remote secrets"
+)
+
+// SetupSecretWatch adds a controller that watches the connection secrets in
+// the remote cluster via the supplied cache, and propagates them to the local
+// cluster as soon as they change, e.g. when a password is rotated, instead of
+// waiting for the next reconciliation of the claim they belong to. A deleted
+// remote secret is propagated too, so that the local claim reports it missing
+// until it's recreated.
+func SetupSecretWatch(mgr manager.Manager, remoteCache cache.Cache, remoteClient client.Client, log logging.Logger, opts ...SecretReconcilerOption) error {
+	return SetupNamedSecretWatch("RemoteConnectionSecrets", mgr, remoteCache, remoteClient, log, opts...)
+}
+
+// SetupNamedSecretWatch is like SetupSecretWatch, but names the controller as
+// supplied, so that the connection secrets of several remote clusters can be
+// watched by their own controllers.
+func SetupNamedSecretWatch(name string, mgr manager.Manager, remoteCache cache.Cache, remoteClient client.Client, log logging.Logger, opts ...SecretReconcilerOption) error {
+	r := NewSecretReconciler(mgr, remoteClient, append([]SecretReconcilerOption{WithSecretReconcilerLogger(log)}, opts...)...)
+	c, err := controller.New(name, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return errors.Wrap(err, errNewController)
+	}
+	return errors.Wrap(c.Watch(source.NewKindWithCache(&v1.Secret{}, remoteCache), &handler.EnqueueRequestForObject{}, predicate.NewPredicateFuncs(IsOwnedByClaim)), errWatchSecrets)
+}
+
+// ClaimOf returns the kind and the key of the remote claim that controls the
+// supplied remote connection secret, and whether there is such a controller at
+// all. The claim is assumed to be in the namespace of its connection secret.
+// See LocalKeyOf for the key of its local claim.
+func ClaimOf(s metav1.Object) (schema.GroupVersionKind, types.NamespacedName, bool) {
+	ref := metav1.GetControllerOf(s)
+	if ref == nil {
+		return schema.GroupVersionKind{}, types.NamespacedName{}, false
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, types.NamespacedName{}, false
+	}
+	return gv.WithKind(ref.Kind), types.NamespacedName{Namespace: s.GetNamespace(), Name: ref.Name}, true
+}
+
+// LocalKeyOf returns the key of the local instance that the supplied remote
+// instance records, which may differ from the key of the remote instance, e.g.
+// with namespace mappings or a cluster namespace. The key of the remote
+// instance is returned for the remote instances that record no local instance.
+func LocalKeyOf(remote metav1.Object) types.NamespacedName {
+	a := remote.GetAnnotations()
+	name, ok := a[AnnotationKeyLocalName]
+	if !ok {
+		return types.NamespacedName{Namespace: remote.GetNamespace(), Name: remote.GetName()}
+	}
+	return types.NamespacedName{Namespace: a[AnnotationKeyLocalNamespace], Name: name}
+}
+
+// IsOwnedByClaim returns true if the supplied remote secret has a controller
+// that could be a claim. It's used to filter out the events of the secrets
+// that are not connection secrets at all.
+func IsOwnedByClaim(m metav1.Object, _ runtime.Object) bool {
+	_, _, ok := ClaimOf(m)
+	return ok
+}
+
+// A SecretReconcilerOption configures a SecretReconciler.
+type SecretReconcilerOption func(*SecretReconciler)
+
+// WithSecretReconcilerLogger specifies how the SecretReconciler should log
+// messages.
+func WithSecretReconcilerLogger(l logging.Logger) SecretReconcilerOption {
+	return func(r *SecretReconciler) {
+		r.log = l
+	}
+}
+
+// WithSecretPropagatorOptions specifies the options of the default
+// ConnectionSecretPropagator of the SecretReconciler.
+func WithSecretPropagatorOptions(opts ...ConnectionSecretPropagatorOption) SecretReconcilerOption {
+	return func(r *SecretReconciler) {
+		r.secretOpts = append(r.secretOpts, opts...)
+	}
+}
+
+// WithSecretPropagator specifies how the SecretReconciler should propagate the
+// connection secrets of a claim.
+func WithSecretPropagator(p Propagator) SecretReconcilerOption {
+	return func(r *SecretReconciler) {
+		r.propagator = p
+	}
+}
+
+// WithSecretReconcilerGVKAliases specifies the kinds the remote instances are
+// served as if they differ from the kinds of the local instances.
+func WithSecretReconcilerGVKAliases(a GVKAliases) SecretReconcilerOption {
+	return func(r *SecretReconciler) {
+		r.aliases = a
+	}
+}
+
+// WithSecretReconcilerMaintenance puts the SecretReconciler in maintenance mode
+// whenever the supplied MaintenanceSwitch is enabled, in which it propagates no
+// connection secrets. The claims propagate them once they're synced again.
+func WithSecretReconcilerMaintenance(s *MaintenanceSwitch) SecretReconcilerOption {
+	return func(r *SecretReconciler) {
+		r.maintenance = s
+	}
+}
+
+// WithSecretReconcilerNamespaces makes the SecretReconciler propagate only
+// the connection secrets of the local claims in the namespaces the supplied
+// function accepts, e.g. the ones whose claims are synced to its remote
+// cluster. The connection secrets of all local claims are propagated by
+// default.
+func WithSecretReconcilerNamespaces(fn func(namespace string) bool) SecretReconcilerOption {
+	return func(r *SecretReconciler) {
+		r.namespaces = fn
+	}
+}
+
+// NewSecretReconciler returns a new *SecretReconciler.
+func NewSecretReconciler(mgr manager.Manager, remoteClient client.Client, opts ...SecretReconcilerOption) *SecretReconciler {
+	lc := unstructured.NewClient(mgr.GetClient())
+	rc := unstructured.NewClient(remoteClient)
+	r := &SecretReconciler{
+		local:  lc,
+		remote: rc,
+		log:    logging.NewNopLogger(),
+		owners: map[types.NamespacedName]secretOwner{},
+	}
+	for _, f := range opts {
+		f(r)
+	}
+	if r.propagator == nil {
+		r.propagator = NewConnectionSecretPropagator(
+			runtimeresource.ClientApplicator{Client: lc, Applicator: runtimeresource.NewAPIPatchingApplicator(lc)},
+			runtimeresource.ClientApplicator{Client: rc, Applicator: runtimeresource.NewAPIPatchingApplicator(rc)},
+			r.secretOpts...,
+		)
+	}
+	return r
+}
+
+// SecretReconciler propagates the connection secrets of the claims whose
+// remote connection secret changed.
+type SecretReconciler struct {
+	local  client.Client
+	remote client.Client
+
+	secretOpts  []ConnectionSecretPropagatorOption
+	propagator  Propagator
+	aliases     GVKAliases
+	namespaces  func(namespace string) bool
+	maintenance *MaintenanceSwitch
+	log         logging.Logger
+
+	// owners are the claims of the remote secrets seen so far, so that the
+	// claim of a deleted secret is known even though the secret is gone.
+	mu     sync.Mutex
+	owners map[types.NamespacedName]secretOwner
+}
+
+// A secretOwner is the claim that controls a remote connection secret.
+type secretOwner struct {
+	gvk schema.GroupVersionKind
+	key types.NamespacedName
+}
+
+// Reconcile propagates the connection secrets of the claim that controls the
+// requested remote secret, if the claim is synced by the agent. If the secret
+// was deleted they're propagated once more, so that the claim reports that its
+// secret is missing, and again once the secret reappears.
+func (r *SecretReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+	log.Debug("Reconciling")
+
+	if r.maintenance.Enabled() {
+		log.Debug("Skipping connection secret in maintenance mode")
+		return reconcile.Result{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s := &v1.Secret{}
+	err := r.remote.Get(ctx, req.NamespacedName, s)
+	if runtimeresource.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, errors.Wrap(err, remotePrefix+errGetSecret)
+	}
+	r.mu.Lock()
+	o, ok := r.owners[req.NamespacedName]
+	if kerrors.IsNotFound(err) {
+		delete(r.owners, req.NamespacedName)
+	}
+	r.mu.Unlock()
+	if kerrors.IsNotFound(err) {
+		if !ok {
+			return reconcile.Result{}, nil
+		}
+		log.Debug("Remote connection secret is missing", "claim", o.key)
+		return r.propagate(ctx, o.gvk, o.key)
+	}
+
+	gvk, key, ok := ClaimOf(s)
+	if !ok {
+		return reconcile.Result{}, nil
+	}
+	r.mu.Lock()
+	r.owners[req.NamespacedName] = secretOwner{gvk: gvk, key: key}
+	r.mu.Unlock()
+	log.Debug("Propagating changed connection secret", "claim", key)
+	return r.propagate(ctx, gvk, key)
+}
+
+// propagate the connection secrets of the claim with the supplied kind and key
+// in the remote cluster, if it's synced by the agent.
+func (r *SecretReconciler) propagate(ctx context.Context, gvk schema.GroupVersionKind, key types.NamespacedName) (reconcile.Result, error) {
+
+	// The secret may be controlled by something other than a claim, or by a
+	// claim that does not exist or is not synced in the local cluster.
+	remote := claim.New(claim.WithGroupVersionKind(gvk))
+	if err := r.remote.Get(ctx, key, remote); err != nil {
+		if kmeta.IsNoMatchError(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrap(runtimeresource.IgnoreNotFound(err), remotePrefix+errGetRequirement)
+	}
+	if !IsManaged(remote) {
+		return reconcile.Result{}, nil
+	}
+	lkey := LocalKeyOf(remote)
+	if r.namespaces != nil && !r.namespaces(lkey.Namespace) {
+		return reconcile.Result{}, nil
+	}
+	local := claim.New(claim.WithGroupVersionKind(r.aliases.Local(gvk)))
+	if err := r.local.Get(ctx, lkey, local); err != nil {
+		if kerrors.IsNotFound(err) || kmeta.IsNoMatchError(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, localPrefix+errGetRequirement)
+	}
+	if meta.WasDeleted(local) || !IsManagedBy(remote, local) {
+		return reconcile.Result{}, nil
+	}
+
+	ready := local.GetCondition(resource.TypeConnectionSecretReady)
+	if err := r.propagator.Propagate(ctx, local, remote); err != nil {
+		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, errPull)
+	}
+
+	// The readiness of the connection secret is the only part of the status
+	// of the local claim that a changed secret affects.
+	if local.GetCondition(resource.TypeConnectionSecretReady).Equal(ready) {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{}, errors.Wrap(r.local.Status().Update(ctx, local), localPrefix+errStatusUpdateClaim)
+}
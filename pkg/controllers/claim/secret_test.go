@@ -0,0 +1,456 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func ownedSecret() *corev1.Secret {
+	s := &corev1.Secret{}
+	s.SetName("cool-secret")
+	s.SetNamespace("cool-ns")
+	controller := true
+	s.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: scopedGVK.GroupVersion().String(),
+		Kind:       scopedGVK.Kind,
+		Name:       "cool-claim",
+		Controller: &controller,
+	}})
+	return s
+}
+
+func TestClaimOf(t *testing.T) {
+	type want struct {
+		gvk schema.GroupVersionKind
+		key types.NamespacedName
+		ok  bool
+	}
+	cases := map[string]struct {
+		reason string
+		secret *corev1.Secret
+		want   want
+	}{
+		"Controlled": {
+			reason: "The controller of the secret should be returned as its claim",
+			secret: ownedSecret(),
+			want: want{
+				gvk: scopedGVK,
+				key: types.NamespacedName{Namespace: "cool-ns", Name: "cool-claim"},
+				ok:  true,
+			},
+		},
+		"NotControlled": {
+			reason: "A secret that is only owned but not controlled should not be mapped to a claim",
+			secret: func() *corev1.Secret {
+				s := ownedSecret()
+				s.OwnerReferences[0].Controller = nil
+				return s
+			}(),
+		},
+		"NoOwner": {
+			reason: "A secret without owners should not be mapped to a claim",
+			secret: &corev1.Secret{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gvk, key, ok := ClaimOf(tc.secret)
+			if diff := cmp.Diff(tc.want, want{gvk: gvk, key: key, ok: ok}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\nReason: %s\nClaimOf(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ok, IsOwnedByClaim(tc.secret, tc.secret)); diff != "" {
+				t.Errorf("\nReason: %s\nIsOwnedByClaim(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretReconciler(t *testing.T) {
+	managedLocal := func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		l.SetUID("local-uid")
+		l.SetFinalizers([]string{DefaultFinalizer})
+		l.DeepCopyInto(obj.(*kunstructured.Unstructured))
+		return nil
+	}
+	remoteGet := func(err error) func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		return func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			switch o := obj.(type) {
+			case *corev1.Secret:
+				ownedSecret().DeepCopyInto(o)
+			case *kunstructured.Unstructured:
+				if diff := cmp.Diff(types.NamespacedName{Namespace: "cool-ns", Name: "cool-claim"}, key); diff != "" {
+					t.Errorf("\nReason: %s\n-want, +got:\n%s", "The remote claim that controls the secret should be fetched", diff)
+				}
+				if err != nil {
+					return err
+				}
+				r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+				r.DeepCopyInto(o)
+			}
+			return nil
+		}
+	}
+	type args struct {
+		local       client.Client
+		remote      client.Client
+		err         error
+		ready       bool
+		maintenance bool
+		namespaces  func(string) bool
+	}
+	type want struct {
+		result     reconcile.Result
+		err        error
+		propagated bool
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Propagated": {
+			reason: "The connection secrets of the managed claim that controls the changed secret should be propagated",
+			args: args{
+				local:  &test.MockClient{MockGet: managedLocal},
+				remote: &test.MockClient{MockGet: remoteGet(nil)},
+			},
+			want: want{
+				propagated: true,
+			},
+		},
+		"NoFinalizer": {
+			reason: "The connection secrets of a managed claim should be propagated even if it's synced without a finalizer",
+			args: args{
+				local: &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+					if err := managedLocal(ctx, key, obj); err != nil {
+						return err
+					}
+					obj.(*kunstructured.Unstructured).SetFinalizers(nil)
+					return nil
+				}},
+				remote: &test.MockClient{MockGet: remoteGet(nil)},
+			},
+			want: want{
+				propagated: true,
+			},
+		},
+		"LocalKeyRecorded": {
+			reason: "The local claim that the remote claim records should be fetched, wherever the remote claim is",
+			args: args{
+				local: &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+					if diff := cmp.Diff(types.NamespacedName{Namespace: "local-ns", Name: "local-claim"}, key); diff != "" {
+						t.Errorf("\nReason: %s\n-want, +got:\n%s", "The local claim that the remote claim records should be fetched", diff)
+					}
+					return managedLocal(ctx, key, obj)
+				}},
+				remote: &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+					if err := remoteGet(nil)(ctx, key, obj); err != nil {
+						return err
+					}
+					if u, ok := obj.(*kunstructured.Unstructured); ok {
+						meta.AddAnnotations(u, map[string]string{AnnotationKeyLocalName: "local-claim", AnnotationKeyLocalNamespace: "local-ns"})
+					}
+					return nil
+				}},
+			},
+			want: want{
+				propagated: true,
+			},
+		},
+		"RemoteClaimNotManaged": {
+			reason: "Nothing should be done if the remote claim is not managed by any local claim",
+			args: args{
+				local: &test.MockClient{},
+				remote: &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+					if err := remoteGet(nil)(ctx, key, obj); err != nil {
+						return err
+					}
+					if u, ok := obj.(*kunstructured.Unstructured); ok {
+						u.SetAnnotations(nil)
+					}
+					return nil
+				}},
+			},
+		},
+		"NamespaceOfAnotherCluster": {
+			reason: "Nothing should be done if the local claim is in a namespace that is synced to another remote cluster",
+			args: args{
+				local:      &test.MockClient{},
+				remote:     &test.MockClient{MockGet: remoteGet(nil)},
+				namespaces: func(string) bool { return false },
+			},
+		},
+		"ReadinessChanged": {
+			reason: "The status of the local claim should be updated if the readiness of its connection secret changed",
+			args: args{
+				local: &test.MockClient{
+					MockGet: managedLocal,
+					MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						got := (&claim.Unstructured{Unstructured: *obj.(*kunstructured.Unstructured)}).GetCondition(resource.TypeConnectionSecretReady)
+						if diff := cmp.Diff(resource.ConnectionSecretAvailable(), got, test.EquateConditions()); diff != "" {
+							t.Errorf("\nReason: %s\n-want, +got:\n%s", "The readiness of the connection secret should be reported", diff)
+						}
+						return nil
+					},
+				},
+				remote: &test.MockClient{MockGet: remoteGet(nil)},
+				ready:  true,
+			},
+			want: want{
+				propagated: true,
+			},
+		},
+		"PropagateFailed": {
+			reason: "An error should be returned if the connection secrets cannot be propagated",
+			args: args{
+				local:  &test.MockClient{MockGet: managedLocal},
+				remote: &test.MockClient{MockGet: remoteGet(nil)},
+				err:    errBoom,
+			},
+			want: want{
+				result:     reconcile.Result{RequeueAfter: shortWait},
+				err:        errors.Wrap(errBoom, errPull),
+				propagated: true,
+			},
+		},
+		"SecretGone": {
+			reason: "Nothing should be done if a remote secret that was never seen is gone",
+			args: args{
+				remote: &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))},
+			},
+		},
+		"LocalClaimGone": {
+			reason: "Nothing should be done if the claim does not exist in the local cluster",
+			args: args{
+				local:  &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))},
+				remote: &test.MockClient{MockGet: remoteGet(nil)},
+			},
+		},
+		"LocalClaimNotSynced": {
+			reason: "Nothing should be done if the local claim is not synced by the agent",
+			args: args{
+				local:  &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+				remote: &test.MockClient{MockGet: remoteGet(nil)},
+			},
+		},
+		"RemoteClaimGone": {
+			reason: "Nothing should be done if the remote claim is gone",
+			args: args{
+				local:  &test.MockClient{MockGet: managedLocal},
+				remote: &test.MockClient{MockGet: remoteGet(kerrors.NewNotFound(schema.GroupResource{}, ""))},
+			},
+		},
+		"Maintenance": {
+			reason: "Nothing should be read or propagated in maintenance mode",
+			args: args{
+				local:       &test.MockClient{},
+				remote:      &test.MockClient{},
+				maintenance: true,
+			},
+		},
+		"LocalGetFailed": {
+			reason: "An error should be returned if the local claim cannot be fetched",
+			args: args{
+				local:  &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+				remote: &test.MockClient{MockGet: remoteGet(nil)},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: shortWait},
+				err:    errors.Wrap(errBoom, localPrefix+errGetRequirement),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			propagated := false
+			p := PropagateFn(func(_ context.Context, local, _ *claim.Unstructured) error {
+				propagated = true
+				if tc.args.ready {
+					local.SetConditions(resource.ConnectionSecretAvailable())
+				}
+				return tc.args.err
+			})
+			r := NewSecretReconciler(&fake.Manager{Client: tc.args.local}, tc.args.remote,
+				WithSecretPropagator(p),
+				WithSecretReconcilerMaintenance(NewMaintenanceSwitch(tc.args.maintenance)),
+				WithSecretReconcilerNamespaces(tc.args.namespaces))
+			got, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cool-ns", Name: "cool-secret"}})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.propagated, propagated); diff != "" {
+				t.Errorf("\nReason: %s\npropagated: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretReconcilerMissingSecret(t *testing.T) {
+	exists := true
+	var ready v1alpha1.Condition
+	var applied []string
+	local := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			switch o := obj.(type) {
+			case *corev1.Secret:
+				return kerrors.NewNotFound(schema.GroupResource{}, "")
+			case *kunstructured.Unstructured:
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-claim")
+				l.SetNamespace("cool-ns")
+				l.SetUID("local-uid")
+				l.SetFinalizers([]string{DefaultFinalizer})
+				l.SetWriteConnectionSecretToReference(&v1alpha1.LocalSecretReference{Name: "local-secret"})
+				l.DeepCopyInto(o)
+			}
+			return nil
+		},
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			applied = append(applied, string(obj.(*corev1.Secret).Data["password"]))
+			return nil
+		},
+		MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+			ready = (&claim.Unstructured{Unstructured: *obj.(*kunstructured.Unstructured)}).GetCondition(resource.TypeConnectionSecretReady)
+			return nil
+		},
+	}
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			switch o := obj.(type) {
+			case *corev1.Secret:
+				if !exists {
+					return kerrors.NewNotFound(schema.GroupResource{}, "")
+				}
+				ownedSecret().DeepCopyInto(o)
+				o.Data = map[string][]byte{"password": []byte("pw")}
+			case *kunstructured.Unstructured:
+				r := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				r.SetName("cool-claim")
+				r.SetNamespace("cool-ns")
+				r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+				r.SetWriteConnectionSecretToReference(&v1alpha1.LocalSecretReference{Name: "cool-secret"})
+				r.DeepCopyInto(o)
+			}
+			return nil
+		},
+	}
+	r := NewSecretReconciler(&fake.Manager{Client: local}, remote, WithSecretPropagatorOptions(WithSecretReadyCondition("password")))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cool-ns", Name: "cool-secret"}}
+
+	steps := []struct {
+		reason  string
+		exists  bool
+		ready   v1alpha1.Condition
+		applied []string
+	}{
+		{
+			reason:  "The remote secret should be propagated while it exists",
+			exists:  true,
+			ready:   resource.ConnectionSecretAvailable(),
+			applied: []string{"pw"},
+		},
+		{
+			reason:  "The local claim should report that its secret is missing once the remote secret is deleted",
+			exists:  false,
+			ready:   resource.ConnectionSecretUnavailable().WithMessage("connection secret cool-secret does not exist in the remote cluster yet"),
+			applied: []string{"pw"},
+		},
+		{
+			reason:  "The remote secret should be propagated again once it's restored",
+			exists:  true,
+			ready:   resource.ConnectionSecretAvailable(),
+			applied: []string{"pw", "pw"},
+		},
+	}
+	for _, s := range steps {
+		exists = s.exists
+		ready = v1alpha1.Condition{}
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", s.reason, err)
+		}
+		if diff := cmp.Diff(s.ready, ready, test.EquateConditions()); diff != "" {
+			t.Errorf("\nReason: %s\nr.Reconcile(...): -want condition, +got condition:\n%s", s.reason, diff)
+		}
+		if diff := cmp.Diff(s.applied, applied); diff != "" {
+			t.Errorf("\nReason: %s\nr.Reconcile(...): -want applied, +got applied:\n%s", s.reason, diff)
+		}
+	}
+
+	exists = false
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(0, len(r.owners)); diff != "" {
+		t.Errorf("\nReason: %s\nowners: -want, +got:\n%s", "The claim of a deleted secret should be forgotten once it reports the secret missing", diff)
+	}
+}
+
+func TestSecretReconcilerGVKAliases(t *testing.T) {
+	reason := "The local claim should be fetched as the kind the remote kind is an alias of"
+	local := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		if diff := cmp.Diff(scopedGVK, obj.GetObjectKind().GroupVersionKind()); diff != "" {
+			t.Errorf("\nReason: %s\n-want, +got:\n%s", reason, diff)
+		}
+		return kerrors.NewNotFound(schema.GroupResource{}, "")
+	}}
+	remote := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *corev1.Secret:
+			s := ownedSecret()
+			ref := s.GetOwnerReferences()[0]
+			ref.Kind = aliasedGVK.Kind
+			s.SetOwnerReferences([]metav1.OwnerReference{ref})
+			s.DeepCopyInto(o)
+		case *kunstructured.Unstructured:
+			r := claim.New(claim.WithGroupVersionKind(aliasedGVK))
+			r.SetAnnotations(map[string]string{AnnotationKeyLocalUID: "local-uid"})
+			r.DeepCopyInto(o)
+		}
+		return nil
+	}}
+	r := NewSecretReconciler(&fake.Manager{Client: local}, remote, WithSecretReconcilerGVKAliases(GVKAliases{scopedGVK: aliasedGVK}))
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cool-ns", Name: "cool-secret"}}); err != nil {
+		t.Errorf("\nReason: %s\nr.Reconcile(...): %s", reason, err)
+	}
+}
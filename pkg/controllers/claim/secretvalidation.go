@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	errInvalidSecretKeyFmt       = "key %s of connection secret %s is invalid"
+	errUnknownSecretKeyFormatFmt = "unknown connection secret key format %q"
+
+	errNotURL   = "value is not an absolute URL"
+	errNotPort  = "value is not a port number"
+	errEmptyKey = "value is empty"
+)
+
+// A SecretKeyValidator validates the value of a key of a connection secret
+// before it's written to the local cluster.
+type SecretKeyValidator interface {
+	Validate(value []byte) error
+}
+
+// A SecretKeyValidateFn is a function that satisfies SecretKeyValidator.
+type SecretKeyValidateFn func(value []byte) error
+
+// Validate the supplied value.
+func (fn SecretKeyValidateFn) Validate(value []byte) error {
+	return fn(value)
+}
+
+// A SecretKeyFormat is a well-known format of the value of a connection secret
+// key.
+type SecretKeyFormat string
+
+// Formats of connection secret keys.
+const (
+	// SecretKeyFormatURL is an absolute URL with a scheme and a host, e.g.
+	// the endpoint of a database.
+	SecretKeyFormatURL SecretKeyFormat = "URL"
+
+	// SecretKeyFormatPort is a TCP or UDP port number.
+	SecretKeyFormatPort SecretKeyFormat = "Port"
+
+	// SecretKeyFormatNonEmpty is any value but an empty one.
+	SecretKeyFormatNonEmpty SecretKeyFormat = "NonEmpty"
+)
+
+// ParseSecretKeyFormat returns the SecretKeyValidator of the supplied format.
+func ParseSecretKeyFormat(f string) (SecretKeyValidator, error) {
+	switch SecretKeyFormat(f) {
+	case SecretKeyFormatURL:
+		return SecretKeyValidateFn(validateURL), nil
+	case SecretKeyFormatPort:
+		return SecretKeyValidateFn(validatePort), nil
+	case SecretKeyFormatNonEmpty:
+		return SecretKeyValidateFn(validateNonEmpty), nil
+	}
+	return nil, errors.Errorf(errUnknownSecretKeyFormatFmt, f)
+}
+
+// WithSecretKeyValidation makes the ConnectionSecretPropagator validate the
+// value of the given key of the remote connection secrets with the given
+// SecretKeyValidator before it writes them to the local cluster, so that the
+// consumers of the local secrets never see a malformed value. A secret with an
+// invalid value is not written and an error is returned instead. A key the
+// secret lacks is not validated; see WithSecretReadyCondition to require it.
+func WithSecretKeyValidation(key string, v SecretKeyValidator) ConnectionSecretPropagatorOption {
+	return func(csp *ConnectionSecretPropagator) {
+		if csp.validators == nil {
+			csp.validators = map[string][]SecretKeyValidator{}
+		}
+		csp.validators[key] = append(csp.validators[key], v)
+	}
+}
+
+// validate the values of the keys of the supplied remote secret that have
+// validators, in the order of the keys.
+func (csp *ConnectionSecretPropagator) validate(s *v1.Secret) error {
+	keys := make([]string, 0, len(csp.validators))
+	for k := range csp.validators {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := s.Data[k]
+		if !ok {
+			continue
+		}
+		for _, sv := range csp.validators[k] {
+			if err := sv.Validate(v); err != nil {
+				return errors.Wrapf(err, errInvalidSecretKeyFmt, k, s.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+func validateURL(value []byte) error {
+	u, err := url.Parse(string(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New(errNotURL)
+	}
+	return nil
+}
+
+func validatePort(value []byte) error {
+	p, err := strconv.Atoi(string(value))
+	if err != nil || p < 1 || p > 65535 {
+		return errors.New(errNotPort)
+	}
+	return nil
+}
+
+func validateNonEmpty(value []byte) error {
+	if len(value) == 0 {
+		return errors.New(errEmptyKey)
+	}
+	return nil
+}
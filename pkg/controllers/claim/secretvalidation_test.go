@@ -0,0 +1,166 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParseSecretKeyFormat(t *testing.T) {
+	type want struct {
+		valid   []string
+		invalid []string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		format string
+		want
+	}{
+		"URL": {
+			reason: "Only absolute URLs should be valid",
+			format: "URL",
+			want: want{
+				valid:   []string{"postgres://db.example.org:5432/cool", "https://example.org"},
+				invalid: []string{"db.example.org:5432", "/cool", ""},
+			},
+		},
+		"Port": {
+			reason: "Only port numbers should be valid",
+			format: "Port",
+			want: want{
+				valid:   []string{"1", "5432", "65535"},
+				invalid: []string{"0", "65536", "http", ""},
+			},
+		},
+		"NonEmpty": {
+			reason: "Any value but an empty one should be valid",
+			format: "NonEmpty",
+			want: want{
+				valid:   []string{"cool"},
+				invalid: []string{""},
+			},
+		},
+		"Unknown": {
+			reason: "An error should be returned if the format is unknown",
+			format: "Cool",
+			want: want{
+				err: errors.Errorf(errUnknownSecretKeyFormatFmt, "Cool"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := ParseSecretKeyFormat(tc.format)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nParseSecretKeyFormat(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			for _, value := range tc.want.valid {
+				if err := v.Validate([]byte(value)); err != nil {
+					t.Errorf("\nReason: %s\nv.Validate(%q): unexpected error: %s", tc.reason, value, err)
+				}
+			}
+			for _, value := range tc.want.invalid {
+				if err := v.Validate([]byte(value)); err == nil {
+					t.Errorf("\nReason: %s\nv.Validate(%q): want error, got nil", tc.reason, value)
+				}
+			}
+		})
+	}
+}
+
+func TestConnectionSecretKeyValidation(t *testing.T) {
+	url, _ := ParseSecretKeyFormat(string(SecretKeyFormatURL))
+	type want struct {
+		written bool
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		data   map[string][]byte
+		opts   []ConnectionSecretPropagatorOption
+		want
+	}{
+		"Valid": {
+			reason: "A secret whose values are valid should be written",
+			data:   map[string][]byte{"endpoint": []byte("postgres://db.example.org:5432")},
+			opts:   []ConnectionSecretPropagatorOption{WithSecretKeyValidation("endpoint", url)},
+			want:   want{written: true},
+		},
+		"Malformed": {
+			reason: "A secret with a malformed value should not be written",
+			data:   map[string][]byte{"endpoint": []byte("db.example.org:5432")},
+			opts:   []ConnectionSecretPropagatorOption{WithSecretKeyValidation("endpoint", url)},
+			want: want{
+				err: errors.Wrapf(errors.New(errNotURL), errInvalidSecretKeyFmt, "endpoint", "remote-s-name"),
+			},
+		},
+		"KeyMissing": {
+			reason: "A key the secret lacks should not be validated",
+			data:   map[string][]byte{"password": []byte("cool")},
+			opts:   []ConnectionSecretPropagatorOption{WithSecretKeyValidation("endpoint", url)},
+			want:   want{written: true},
+		},
+		"Custom": {
+			reason: "The errors of a pluggable validator should be returned",
+			data:   map[string][]byte{"endpoint": []byte("postgres://db.example.org:5432")},
+			opts: []ConnectionSecretPropagatorOption{
+				WithSecretKeyValidation("endpoint", url),
+				WithSecretKeyValidation("endpoint", SecretKeyValidateFn(func(_ []byte) error { return errBoom })),
+			},
+			want: want{
+				err: errors.Wrapf(errBoom, errInvalidSecretKeyFmt, "endpoint", "remote-s-name"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			remote := resource.ClientApplicator{Client: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+					obj.(*corev1.Secret).SetName("remote-s-name")
+					obj.(*corev1.Secret).Data = tc.data
+					return nil
+				}),
+			}}
+			written := false
+			local := resource.ClientApplicator{
+				Applicator: resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+					written = true
+					return nil
+				}),
+			}
+			p := NewConnectionSecretPropagator(local, remote, tc.opts...)
+			err := p.Propagate(context.Background(), &claim.Unstructured{Unstructured: *localClaim.DeepCopy()}, &claim.Unstructured{Unstructured: *remoteClaim.DeepCopy()})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\np.Propagate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.written, written); diff != "" {
+				t.Errorf("\nReason: %s\nwritten: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+// FieldManager is the field manager the agent owns the fields of the remote
+// instances under when it writes them with server-side apply.
+const FieldManager = "crossplane-agent"
+
+const (
+	errMigrateFields = "cannot migrate field managers"
+	errMergeFields   = "cannot merge managed fields"
+)
+
+// A ServerSideApplicatorOption configures a ServerSideApplicator.
+type ServerSideApplicatorOption func(*ServerSideApplicator)
+
+// WithFieldManagerMigration makes the ServerSideApplicator take over the fields
+// that the supplied field managers wrote with updates, e.g. with client-side
+// apply, before it applies an object. See MigrateFieldManagers.
+func WithFieldManagerMigration(from ...string) ServerSideApplicatorOption {
+	return func(a *ServerSideApplicator) {
+		a.from = from
+	}
+}
+
+// NewServerSideApplicator returns a new *ServerSideApplicator that applies
+// objects as the supplied field manager.
+func NewServerSideApplicator(c client.Client, manager string, opts ...ServerSideApplicatorOption) *ServerSideApplicator {
+	a := &ServerSideApplicator{client: c, manager: manager}
+	for _, f := range opts {
+		f(a)
+	}
+	return a
+}
+
+// A ServerSideApplicator applies objects with server-side apply, forcing the
+// ownership of all fields they set.
+type ServerSideApplicator struct {
+	client  client.Client
+	manager string
+	from    []string
+}
+
+// Apply the supplied object. The current object is read only if there are
+// ApplyOptions to run or field managers to migrate.
+func (a *ServerSideApplicator) Apply(ctx context.Context, o runtime.Object, ao ...runtimeresource.ApplyOption) error {
+	m, ok := o.(metav1.Object)
+	if !ok {
+		return errors.New("cannot access object metadata")
+	}
+
+	if len(ao) > 0 || len(a.from) > 0 {
+		current := o.DeepCopyObject()
+		err := a.client.Get(ctx, types.NamespacedName{Name: m.GetName(), Namespace: m.GetNamespace()}, current)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrap(err, "cannot get object")
+		}
+		if err == nil {
+			for _, fn := range ao {
+				if err := fn(ctx, current, o); err != nil {
+					return err
+				}
+			}
+			migrated, err := MigrateFieldManagers(current.(metav1.Object), a.manager, a.from...)
+			if err != nil {
+				return errors.Wrap(err, errMigrateFields)
+			}
+			if migrated {
+				if err := a.client.Update(ctx, current); err != nil {
+					return errors.Wrap(err, errMigrateFields)
+				}
+			}
+		}
+	}
+
+	// The managed fields cannot be applied, and the resource version would
+	// turn the apply into a conditional one.
+	m.SetManagedFields(nil)
+	m.SetResourceVersion("")
+	return errors.Wrap(a.client.Patch(ctx, o, client.Apply, client.FieldOwner(a.manager), client.ForceOwnership), "cannot apply object")
+}
+
+// MigrateFieldManagers moves the fields that the supplied field managers, or
+// the manager itself, wrote with updates to the apply entry of the manager, and
+// removes the last applied configuration annotation of client-side apply. This
+// lets server-side apply remove the fields the manager no longer sets rather
+// than leaving them to their previous owners. It returns true if the object
+// had anything to migrate, so that it's safe to call it on every apply.
+func MigrateFieldManagers(o metav1.Object, manager string, from ...string) (bool, error) {
+	migrated := false
+	if _, ok := o.GetAnnotations()[v1.LastAppliedConfigAnnotation]; ok {
+		meta.RemoveAnnotations(o, v1.LastAppliedConfigAnnotation)
+		migrated = true
+	}
+
+	owners := map[string]bool{manager: true}
+	for _, f := range from {
+		owners[f] = true
+	}
+
+	// The fields are managed per API version, so there is an apply entry for
+	// each version that has fields to migrate.
+	var versions []string
+	applied := map[string]*metav1.ManagedFieldsEntry{}
+	kept := make([]metav1.ManagedFieldsEntry, 0, len(o.GetManagedFields()))
+	for _, e := range o.GetManagedFields() {
+		adopt := e.Manager == manager && e.Operation == metav1.ManagedFieldsOperationApply
+		if owners[e.Manager] && e.Operation == metav1.ManagedFieldsOperationUpdate {
+			adopt = true
+			migrated = true
+		}
+		if !adopt {
+			kept = append(kept, e)
+			continue
+		}
+		a, ok := applied[e.APIVersion]
+		if !ok {
+			versions = append(versions, e.APIVersion)
+			a = &metav1.ManagedFieldsEntry{
+				Manager:    manager,
+				Operation:  metav1.ManagedFieldsOperationApply,
+				APIVersion: e.APIVersion,
+				Time:       e.Time,
+				FieldsType: e.FieldsType,
+			}
+			applied[e.APIVersion] = a
+		}
+		f, err := mergeFields(a.FieldsV1, e.FieldsV1)
+		if err != nil {
+			return false, err
+		}
+		a.FieldsV1 = f
+	}
+	if !migrated {
+		return false, nil
+	}
+	for _, v := range versions {
+		kept = append(kept, *applied[v])
+	}
+	o.SetManagedFields(kept)
+	return true, nil
+}
+
+// mergeFields returns the union of the supplied sets of fields, which are
+// serialized as nested JSON objects keyed by the path elements.
+func mergeFields(a, b *metav1.FieldsV1) (*metav1.FieldsV1, error) {
+	if a == nil {
+		return b, nil
+	}
+	if b == nil {
+		return a, nil
+	}
+	into := map[string]interface{}{}
+	if err := json.Unmarshal(a.Raw, &into); err != nil {
+		return nil, errors.Wrap(err, errMergeFields)
+	}
+	from := map[string]interface{}{}
+	if err := json.Unmarshal(b.Raw, &from); err != nil {
+		return nil, errors.Wrap(err, errMergeFields)
+	}
+	unionFields(into, from)
+	raw, err := json.Marshal(into)
+	return &metav1.FieldsV1{Raw: raw}, errors.Wrap(err, errMergeFields)
+}
+
+func unionFields(into, from map[string]interface{}) {
+	for k, v := range from {
+		fv, ok := v.(map[string]interface{})
+		iv, exists := into[k].(map[string]interface{})
+		if !ok || !exists {
+			if _, set := into[k]; !set {
+				into[k] = v
+			}
+			continue
+		}
+		unionFields(iv, fv)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func fields(raw string) *metav1.FieldsV1 {
+	return &metav1.FieldsV1{Raw: []byte(raw)}
+}
+
+// csaManaged returns a remote claim that was written with client-side apply
+// by the agent before it used server-side apply.
+func csaManaged() *claim.Unstructured {
+	c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+	c.SetName("cool-claim")
+	c.SetNamespace("cool-ns")
+	c.SetResourceVersion("42")
+	c.SetAnnotations(map[string]string{v1.LastAppliedConfigAnnotation: "{}", "cool": "annotation"})
+	c.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "agent", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "example.org/v1", FieldsType: "FieldsV1", FieldsV1: fields(`{"f:spec":{"f:size":{}}}`)},
+		{Manager: "crossplane", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "example.org/v1", FieldsType: "FieldsV1", FieldsV1: fields(`{"f:spec":{"f:resourceRef":{}}}`)},
+	})
+	return c
+}
+
+// ssaMigrated returns csaManaged after the fields of the agent were migrated.
+func ssaMigrated() *claim.Unstructured {
+	c := csaManaged()
+	c.SetAnnotations(map[string]string{"cool": "annotation"})
+	c.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "crossplane", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "example.org/v1", FieldsType: "FieldsV1", FieldsV1: fields(`{"f:spec":{"f:resourceRef":{}}}`)},
+		{Manager: FieldManager, Operation: metav1.ManagedFieldsOperationApply, APIVersion: "example.org/v1", FieldsType: "FieldsV1", FieldsV1: fields(`{"f:spec":{"f:size":{}}}`)},
+	})
+	return c
+}
+
+func TestMigrateFieldManagers(t *testing.T) {
+	type args struct {
+		o    *claim.Unstructured
+		from []string
+	}
+	type want struct {
+		o        *claim.Unstructured
+		migrated bool
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"ClientSideApplied": {
+			reason: "The fields of the given managers should be taken over and the last applied configuration annotation should be removed",
+			args: args{
+				o:    csaManaged(),
+				from: []string{"agent"},
+			},
+			want: want{
+				o:        ssaMigrated(),
+				migrated: true,
+			},
+		},
+		"AlreadyMigrated": {
+			reason: "Nothing should be migrated if the object was migrated before",
+			args: args{
+				o:    ssaMigrated(),
+				from: []string{"agent"},
+			},
+			want: want{
+				o: ssaMigrated(),
+			},
+		},
+		"MergedIntoApplied": {
+			reason: "The migrated fields should be merged into the fields the manager already applied",
+			args: args{
+				o: func() *claim.Unstructured {
+					c := ssaMigrated()
+					c.SetManagedFields(append(c.GetManagedFields(), metav1.ManagedFieldsEntry{
+						Manager: FieldManager, Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "example.org/v1", FieldsType: "FieldsV1", FieldsV1: fields(`{"f:spec":{"f:engine":{}}}`),
+					}))
+					return c
+				}(),
+			},
+			want: want{
+				o: func() *claim.Unstructured {
+					c := ssaMigrated()
+					mf := c.GetManagedFields()
+					mf[1].FieldsV1 = fields(`{"f:spec":{"f:engine":{},"f:size":{}}}`)
+					c.SetManagedFields(mf)
+					return c
+				}(),
+				migrated: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			migrated, err := MigrateFieldManagers(tc.args.o, FieldManager, tc.args.from...)
+			if err != nil {
+				t.Errorf("\nReason: %s\nMigrateFieldManagers(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.migrated, migrated); diff != "" {
+				t.Errorf("\nReason: %s\nMigrateFieldManagers(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, tc.args.o); diff != "" {
+				t.Errorf("\nReason: %s\nMigrateFieldManagers(...): -want object, +got object:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestServerSideApplicator(t *testing.T) {
+	type args struct {
+		current *claim.Unstructured
+		getErr  error
+		from    []string
+	}
+	type want struct {
+		updated *kunstructured.Unstructured
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Migrated": {
+			reason: "The fields written with client-side apply should be migrated before the object is applied",
+			args: args{
+				current: csaManaged(),
+				from:    []string{"agent"},
+			},
+			want: want{
+				updated: &ssaMigrated().Unstructured,
+			},
+		},
+		"AlreadyMigrated": {
+			reason: "The object should only be applied if it was migrated before",
+			args: args{
+				current: ssaMigrated(),
+				from:    []string{"agent"},
+			},
+		},
+		"NoMigration": {
+			reason: "The object should only be applied if no field managers should be migrated",
+			args: args{
+				current: csaManaged(),
+			},
+		},
+		"NotFound": {
+			reason: "An object that does not exist yet should only be applied",
+			args: args{
+				getErr: kerrors.NewNotFound(schema.GroupResource{}, ""),
+				from:   []string{"agent"},
+			},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the current object cannot be fetched",
+			args: args{
+				getErr: errBoom,
+				from:   []string{"agent"},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, "cannot get object"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updated *kunstructured.Unstructured
+			c := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					if tc.args.getErr != nil {
+						return tc.args.getErr
+					}
+					tc.args.current.DeepCopyInto(obj.(*kunstructured.Unstructured))
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+					updated = obj.(*kunstructured.Unstructured)
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, opts ...client.PatchOption) error {
+					if diff := cmp.Diff(types.ApplyPatchType, p.Type()); diff != "" {
+						t.Errorf("\nReason: %s\nPatch type: -want, +got:\n%s", tc.reason, diff)
+					}
+					po := &client.PatchOptions{}
+					po.ApplyOptions(opts)
+					if diff := cmp.Diff(FieldManager, po.FieldManager); diff != "" {
+						t.Errorf("\nReason: %s\nField manager: -want, +got:\n%s", tc.reason, diff)
+					}
+					u := obj.(*claim.Unstructured)
+					if len(u.GetManagedFields()) > 0 || u.GetResourceVersion() != "" {
+						t.Errorf("\nReason: %s\nThe managed fields and resource version should not be applied", tc.reason)
+					}
+					return nil
+				},
+			}
+			desired := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			desired.SetName("cool-claim")
+			desired.SetNamespace("cool-ns")
+			desired.SetResourceVersion("42")
+			desired.SetManagedFields(csaManaged().GetManagedFields())
+			a := NewServerSideApplicator(c, FieldManager, WithFieldManagerMigration(tc.args.from...))
+			err := a.Apply(context.Background(), desired)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\na.Apply(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.updated, updated); diff != "" {
+				t.Errorf("\nReason: %s\nUpdated object: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
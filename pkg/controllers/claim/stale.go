@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+const msgStaleFmt = "Not synced successfully since %s: %s"
+
+// staleTracker records when claims were last synced successfully in memory,
+// so that the ones that haven't been for longer than a window can be reported
+// as stale. A claim that hasn't been synced successfully since the agent
+// started is tracked from when it was first observed.
+type staleTracker struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newStaleTracker(window time.Duration) *staleTracker {
+	return &staleTracker{window: window, now: time.Now, last: map[string]time.Time{}}
+}
+
+// Observe records the outcome of a sync attempt of the claim with given key
+// according to its AgentSynced condition. It returns since when the claim has
+// not been synced successfully and true if that's longer than the window. The
+// claims the agent deliberately doesn't sync for now are never stale.
+func (t *staleTracker) Observe(key string, c v1alpha1.Condition) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	switch c.Reason {
+	case resource.ReasonAgentSyncSuccess,
+		resource.ReasonAgentSyncFilteredOut,
+		resource.ReasonAgentSyncDeletionPending,
+		resource.ReasonAgentSyncMaintenance:
+		t.last[key] = now
+		return time.Time{}, false
+	}
+	since, ok := t.last[key]
+	if !ok {
+		t.last[key] = now
+		return now, false
+	}
+	return since, now.Sub(since) > t.window
+}
+
+// Forget stops tracking the claim with given key, i.e. when it's deleted.
+func (t *staleTracker) Forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.last, key)
+}
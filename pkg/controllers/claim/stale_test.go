@@ -0,0 +1,150 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/agent/pkg/resource"
+)
+
+func TestStaleTracker(t *testing.T) {
+	start := time.Now()
+	steps := []struct {
+		reason string
+		after  time.Duration
+		cond   v1alpha1.Condition
+		since  time.Time
+		stale  bool
+	}{
+		{reason: "A claim that fails when first seen should not be stale", cond: resource.AgentSyncError(errBoom), since: start},
+		{reason: "A claim that keeps failing within the window should not be stale", after: 30 * time.Minute, cond: resource.AgentSyncError(errBoom), since: start},
+		{reason: "A claim that keeps failing beyond the window should be stale", after: 2 * time.Hour, cond: resource.AgentSyncWaiting(), since: start, stale: true},
+		{reason: "A claim that is deliberately not synced should not be stale", after: 3 * time.Hour, cond: resource.AgentSyncFilteredOut()},
+		{reason: "The window should start anew after a claim is synced", after: 4 * time.Hour, cond: resource.AgentSyncError(errBoom), since: start.Add(3 * time.Hour)},
+	}
+	st := newStaleTracker(time.Hour)
+	for _, s := range steps {
+		st.now = func() time.Time { return start.Add(s.after) }
+		since, stale := st.Observe("cool-key", s.cond)
+		if diff := cmp.Diff(s.stale, stale); diff != "" {
+			t.Errorf("\nReason: %s\nst.Observe(...): -want stale, +got stale:\n%s", s.reason, diff)
+		}
+		if diff := cmp.Diff(s.since, since); s.since != (time.Time{}) && diff != "" {
+			t.Errorf("\nReason: %s\nst.Observe(...): -want since, +got since:\n%s", s.reason, diff)
+		}
+	}
+}
+
+func TestReconcileStale(t *testing.T) {
+	start := time.Now()
+	failing := false
+	var synced *claim.Unstructured
+	writes := 0
+	maintenance := NewMaintenanceSwitch(false)
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				l := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				l.SetName("cool-db")
+				l.SetFinalizers([]string{DefaultFinalizer})
+				l.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				l.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				synced = &claim.Unstructured{Unstructured: *obj.(*unstructured.Unstructured).DeepCopy()}
+				writes++
+				return nil
+			},
+		},
+	}
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+			if failing {
+				return errBoom
+			}
+			return kerrors.NewNotFound(schema.GroupResource{}, "")
+		},
+		MockCreate: test.NewMockCreateFn(nil),
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithStaleAfter(time.Hour),
+		WithMaintenanceSwitch(maintenance),
+	)
+	failed := resource.AgentSyncError(errors.Wrap(errBoom, remotePrefix+errGetRequirement))
+	steps := []struct {
+		reason      string
+		after       time.Duration
+		failing     bool
+		maintenance bool
+		want        v1alpha1.Condition
+	}{
+		{reason: "A claim that is synced should not be stale", want: resource.AgentSyncSuccess()},
+		{reason: "A claim that fails within the window should report its failure", after: 30 * time.Minute, failing: true, want: failed},
+		{
+			reason:  "A claim that hasn't been synced for longer than the window should be stale",
+			after:   2 * time.Hour,
+			failing: true,
+			want:    resource.AgentSyncStale().WithMessage(fmt.Sprintf(msgStaleFmt, start.UTC().Format(time.RFC3339), failed.Message)),
+		},
+		{reason: "A stale claim should no longer be stale once it's synced", after: 3 * time.Hour, want: resource.AgentSyncSuccess()},
+		{reason: "A claim that fails again should report its failure", after: 4 * time.Hour, failing: true, want: failed},
+		{
+			reason:      "A claim should not be reported as stale in maintenance mode",
+			after:       6 * time.Hour,
+			failing:     true,
+			maintenance: true,
+			want:        resource.AgentSyncMaintenance().WithMessage("Agent is in maintenance mode"),
+		},
+	}
+	for _, s := range steps {
+		failing = s.failing
+		maintenance.Set(s.maintenance)
+		writes = 0
+		r.stale.now = func() time.Time { return start.Add(s.after) }
+		if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+			t.Fatalf("\nReason: %s\nr.Reconcile(...): unexpected error: %s", s.reason, err)
+		}
+		if diff := cmp.Diff(1, writes); diff != "" {
+			t.Errorf("\nReason: %s\nstatus writes: -want, +got:\n%s", s.reason, diff)
+		}
+		if diff := cmp.Diff(s.want, synced.GetCondition(resource.TypeAgentSync), test.EquateConditions()); diff != "" {
+			t.Errorf("\nReason: %s\nAgentSynced: -want, +got:\n%s", s.reason, diff)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errGetTemplateValues = "cannot get template values"
+	errRenderTemplateFmt = "cannot render template at %s"
+)
+
+// TemplateData is what the templates of a ValueTemplater are executed with.
+type TemplateData struct {
+	// Values are the data of the ConfigMap the values are read from.
+	Values map[string]string
+}
+
+// NewValueTemplater returns a new *ValueTemplater that renders the templates at
+// the given paths of the remote instances with the values of the ConfigMap
+// with the given key.
+func NewValueTemplater(c client.Reader, values types.NamespacedName, paths ...string) *ValueTemplater {
+	return &ValueTemplater{client: c, values: values, paths: paths}
+}
+
+// ValueTemplater injects environment specific values, e.g. the region or the
+// names of the networks, into the remote instances. The string fields at its
+// paths are rendered as Go templates, e.g. {{ .Values.region }}, with the data
+// of a local ConfigMap.
+type ValueTemplater struct {
+	client client.Reader
+	values types.NamespacedName
+	paths  []string
+}
+
+// Configure renders the templates in the fields of the remote instance. Fields
+// that do not exist, are not strings or have no template are skipped. A
+// template that refers to a value the ConfigMap doesn't have is an error.
+func (vt *ValueTemplater) Configure(ctx context.Context, _, remote *claim.Unstructured) error {
+	rp := fieldpath.Pave(remote.GetUnstructured().UnstructuredContent())
+	var data *TemplateData
+	for _, path := range vt.paths {
+		s, err := rp.GetString(path)
+		if err != nil || !strings.Contains(s, "{{") {
+			continue
+		}
+		// The ConfigMap is read only if there is something to render.
+		if data == nil {
+			cm := &v1.ConfigMap{}
+			if err := vt.client.Get(ctx, vt.values, cm); err != nil {
+				return errors.Wrap(err, localPrefix+errGetTemplateValues)
+			}
+			data = &TemplateData{Values: cm.Data}
+			if data.Values == nil {
+				data.Values = map[string]string{}
+			}
+		}
+		t, err := template.New(path).Option("missingkey=error").Parse(s)
+		if err != nil {
+			return errors.Wrapf(err, errRenderTemplateFmt, path)
+		}
+		b := &strings.Builder{}
+		if err := t.Execute(b, data); err != nil {
+			return errors.Wrapf(err, errRenderTemplateFmt, path)
+		}
+		if err := rp.SetValue(path, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestValueTemplater(t *testing.T) {
+	values := types.NamespacedName{Namespace: "agent-system", Name: "env"}
+	withSpec := func(spec map[string]interface{}) *claim.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(scopedGVK))
+		c.Object["spec"] = spec
+		return c
+	}
+	type args struct {
+		paths  []string
+		remote *claim.Unstructured
+		data   map[string]string
+		err    error
+	}
+	type want struct {
+		remote *claim.Unstructured
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Rendered": {
+			reason: "The templates at the paths should be rendered with the values of the ConfigMap",
+			args: args{
+				paths: []string{"spec.region", "spec.network"},
+				remote: withSpec(map[string]interface{}{
+					"region":  "{{ .Values.region }}",
+					"network": "{{ .Values.region }}-{{ .Values.network }}",
+					"other":   "{{ .Values.region }}",
+				}),
+				data: map[string]string{"region": "us-east-1", "network": "default"},
+			},
+			want: want{
+				remote: withSpec(map[string]interface{}{
+					"region":  "us-east-1",
+					"network": "us-east-1-default",
+					"other":   "{{ .Values.region }}",
+				}),
+			},
+		},
+		"NoTemplate": {
+			reason: "Fields without templates should be left as is without reading the ConfigMap",
+			args: args{
+				paths:  []string{"spec.region", "spec.missing"},
+				remote: withSpec(map[string]interface{}{"region": "eu-west-1"}),
+				err:    errBoom,
+			},
+			want: want{
+				remote: withSpec(map[string]interface{}{"region": "eu-west-1"}),
+			},
+		},
+		"MissingValue": {
+			reason: "An error should be returned if a template refers to a value the ConfigMap doesn't have",
+			args: args{
+				paths:  []string{"spec.region"},
+				remote: withSpec(map[string]interface{}{"region": "{{ .Values.region }}"}),
+				data:   map[string]string{"network": "default"},
+			},
+			want: want{
+				remote: withSpec(map[string]interface{}{"region": "{{ .Values.region }}"}),
+				err:    errors.Wrapf(errors.New(`template: spec.region:1:10: executing "spec.region" at <.Values.region>: map has no entry for key "region"`), errRenderTemplateFmt, "spec.region"),
+			},
+		},
+		"InvalidTemplate": {
+			reason: "An error should be returned if a template cannot be parsed",
+			args: args{
+				paths:  []string{"spec.region"},
+				remote: withSpec(map[string]interface{}{"region": "{{ .Values.region "}),
+			},
+			want: want{
+				remote: withSpec(map[string]interface{}{"region": "{{ .Values.region "}),
+				err:    errors.Wrapf(errors.New(`template: spec.region:1: unclosed action`), errRenderTemplateFmt, "spec.region"),
+			},
+		},
+		"GetValuesFailed": {
+			reason: "An error should be returned if the ConfigMap cannot be fetched",
+			args: args{
+				paths:  []string{"spec.region"},
+				remote: withSpec(map[string]interface{}{"region": "{{ .Values.region }}"}),
+				err:    errBoom,
+			},
+			want: want{
+				remote: withSpec(map[string]interface{}{"region": "{{ .Values.region }}"}),
+				err:    errors.Wrap(errBoom, localPrefix+errGetTemplateValues),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				if diff := cmp.Diff(values, key); diff != "" {
+					t.Errorf("\nReason: %s\nGet(...): -want key, +got key:\n%s", tc.reason, diff)
+				}
+				if tc.args.err != nil {
+					return tc.args.err
+				}
+				obj.(*v1.ConfigMap).Data = tc.args.data
+				return nil
+			}}
+			vt := NewValueTemplater(c, values, tc.args.paths...)
+			err := vt.Configure(context.Background(), nil, tc.args.remote)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nvt.Configure(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.remote, tc.args.remote); diff != "" {
+				t.Errorf("\nReason: %s\nvt.Configure(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
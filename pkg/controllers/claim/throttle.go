@@ -0,0 +1,95 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+type write struct {
+	at      time.Time
+	written map[string]interface{}
+}
+
+// NewWriteThrottle returns a new *WriteThrottle that lets a remote instance be
+// written again only after the given cooldown unless what's written changes.
+func NewWriteThrottle(cooldown time.Duration) *WriteThrottle {
+	return &WriteThrottle{cooldown: cooldown, now: time.Now, writes: map[string]write{}}
+}
+
+// WriteThrottle keeps the agent from hot-looping writes to the remote cluster
+// for a single flapping instance, e.g. one whose spec is reverted by another
+// controller right after it's written. It remembers when and what was last
+// written for each claim in memory.
+type WriteThrottle struct {
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	writes map[string]write
+}
+
+// Remaining returns how long the claim with given key has to wait before the
+// supplied desired remote instance can be written. It's zero if the cooldown
+// has elapsed, or if the metadata or spec of the desired instance differ from
+// the ones that were last written.
+func (t *WriteThrottle) Remaining(key string, desired *claim.Unstructured) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.writes[key]
+	if !ok || !equality.Semantic.DeepEqual(w.written, written(desired)) {
+		return 0
+	}
+	if d := w.at.Add(t.cooldown).Sub(t.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Record records that the supplied remote instance was written for the claim
+// with given key.
+func (t *WriteThrottle) Record(key string, desired *claim.Unstructured) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writes[key] = write{at: t.now(), written: written(desired)}
+}
+
+// Forget stops tracking the claim with given key, i.e. when it's deleted.
+func (t *WriteThrottle) Forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.writes, key)
+}
+
+// written returns the parts of the supplied remote instance that determine
+// whether it's the same write as before.
+func written(u *claim.Unstructured) map[string]interface{} {
+	w := map[string]interface{}{
+		"labels":      u.GetLabels(),
+		"annotations": u.GetAnnotations(),
+	}
+	if spec, ok := u.Object["spec"]; ok {
+		w["spec"] = runtime.DeepCopyJSONValue(spec)
+	}
+	return w
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+func TestWriteThrottle(t *testing.T) {
+	withSize := func(size string) *claim.Unstructured {
+		c := claim.New()
+		c.Object["spec"] = map[string]interface{}{"size": size}
+		return c
+	}
+	start := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+	type args struct {
+		written *claim.Unstructured
+		desired *claim.Unstructured
+		elapsed time.Duration
+		forget  bool
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want time.Duration
+	}{
+		"NeverWritten": {
+			reason: "An instance that was never written should be written right away",
+			args: args{
+				desired: withSize("10"),
+			},
+		},
+		"WithinCooldown": {
+			reason: "The same instance should wait for the rest of the cooldown",
+			args: args{
+				written: withSize("10"),
+				desired: withSize("10"),
+				elapsed: 20 * time.Second,
+			},
+			want: 40 * time.Second,
+		},
+		"CooldownElapsed": {
+			reason: "The same instance should be written once the cooldown elapses",
+			args: args{
+				written: withSize("10"),
+				desired: withSize("10"),
+				elapsed: time.Minute,
+			},
+		},
+		"Changed": {
+			reason: "A changed instance should be written right away",
+			args: args{
+				written: withSize("10"),
+				desired: withSize("20"),
+				elapsed: 20 * time.Second,
+			},
+		},
+		"Forgotten": {
+			reason: "A forgotten instance should be written right away",
+			args: args{
+				written: withSize("10"),
+				desired: withSize("10"),
+				elapsed: 20 * time.Second,
+				forget:  true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			th := NewWriteThrottle(time.Minute)
+			th.now = func() time.Time { return start }
+			if tc.args.written != nil {
+				th.Record("key", tc.args.written)
+			}
+			if tc.args.forget {
+				th.Forget("key")
+			}
+			th.now = func() time.Time { return start.Add(tc.args.elapsed) }
+			got := th.Remaining("key", tc.args.desired)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nth.Remaining(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
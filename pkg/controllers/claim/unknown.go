@@ -0,0 +1,161 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+)
+
+const (
+	errFindUnknownFields = "cannot find unknown fields of claim"
+	errUnknownFieldsFmt  = "claim has fields unknown to the remote cluster: %s"
+)
+
+// An UnknownFieldPolicy determines what happens to the fields of the spec of a
+// remote instance that are not known to the remote cluster.
+type UnknownFieldPolicy string
+
+// Policies for unknown fields.
+const (
+	// UnknownFieldPolicyPropagate writes the unknown fields as they are.
+	UnknownFieldPolicyPropagate UnknownFieldPolicy = "Propagate"
+
+	// UnknownFieldPolicyStrip removes the unknown fields before the remote
+	// instance is written.
+	UnknownFieldPolicyStrip UnknownFieldPolicy = "Strip"
+
+	// UnknownFieldPolicyError refuses to write a remote instance that has
+	// unknown fields.
+	UnknownFieldPolicyError UnknownFieldPolicy = "Error"
+)
+
+// An UnknownFieldFinder finds the fields of the spec of a remote instance that
+// are not known to the remote cluster, and removes them if asked to.
+type UnknownFieldFinder interface {
+	UnknownFields(ctx context.Context, remote *claim.Unstructured, remove bool) ([]string, error)
+}
+
+// KnownFields is an UnknownFieldFinder that knows only the fields with the
+// given paths, e.g. spec.forProvider.size, and the fields within them. The
+// paths have no array indices; a path applies to the fields of all elements
+// of the arrays along it.
+type KnownFields []string
+
+// UnknownFields returns the paths of the fields of the spec of the supplied
+// remote instance that are not known, and removes them if asked to.
+func (k KnownFields) UnknownFields(_ context.Context, remote *claim.Unstructured, remove bool) ([]string, error) {
+	val, ok := remote.GetUnstructured().UnstructuredContent()["spec"]
+	if !ok {
+		return nil, nil
+	}
+	return k.unknown(field.NewPath("spec"), "spec", val, remove), nil
+}
+
+// unknown returns the paths of the unknown fields of the supplied value, whose
+// path without array indices is the given one.
+func (k KnownFields) unknown(fp *field.Path, path string, val interface{}, remove bool) []string {
+	var unknown []string
+	switch v := val.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p := path + "." + key
+			switch {
+			case k.knows(p):
+			case k.within(p):
+				unknown = append(unknown, k.unknown(fp.Child(key), p, v[key], remove)...)
+			default:
+				if remove {
+					delete(v, key)
+				}
+				unknown = append(unknown, fp.Child(key).String())
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			unknown = append(unknown, k.unknown(fp.Index(i), path, e, remove)...)
+		}
+	}
+	return unknown
+}
+
+// knows returns whether the field with the supplied path is known, either
+// itself or as part of a known field.
+func (k KnownFields) knows(path string) bool {
+	for _, f := range k {
+		if path == f || strings.HasPrefix(path, f+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// within returns whether the field with the supplied path has known fields.
+func (k KnownFields) within(path string) bool {
+	for _, f := range k {
+		if strings.HasPrefix(f, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// NewUnknownFieldChecker returns a new *UnknownFieldChecker that applies the
+// given policy to the fields the given UnknownFieldFinder finds.
+func NewUnknownFieldChecker(p UnknownFieldPolicy, f UnknownFieldFinder, log logging.Logger) *UnknownFieldChecker {
+	return &UnknownFieldChecker{policy: p, finder: f, log: log}
+}
+
+// An UnknownFieldChecker applies an UnknownFieldPolicy to the remote instances
+// before they're written.
+type UnknownFieldChecker struct {
+	policy UnknownFieldPolicy
+	finder UnknownFieldFinder
+	log    logging.Logger
+}
+
+// Configure applies the policy to the unknown fields of the supplied remote
+// instance. It returns an error listing them if the policy doesn't allow them.
+func (c *UnknownFieldChecker) Configure(ctx context.Context, _, remote *claim.Unstructured) error {
+	if c.policy == UnknownFieldPolicyPropagate || c.policy == "" {
+		return nil
+	}
+	unknown, err := c.finder.UnknownFields(ctx, remote, c.policy == UnknownFieldPolicyStrip)
+	if err != nil {
+		return errors.Wrap(err, errFindUnknownFields)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	if c.policy == UnknownFieldPolicyError {
+		return errors.Errorf(errUnknownFieldsFmt, strings.Join(unknown, ", "))
+	}
+	c.log.Debug("Stripped unknown fields", "name", remote.GetName(), "fields", unknown)
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestUnknownFieldChecker(t *testing.T) {
+	// The CRD preserves unknown fields, so the policy rather than the remote
+	// API server decides what happens to them.
+	schemas := NewSchemaValidator(newScopedDiscovery(true), &test.MockClient{MockGet: newMockCRDGetFn(newSchemaCRD(), nil)})
+	spec := func() map[string]interface{} {
+		return map[string]interface{}{"size": int64(10), "random-field": "random-val"}
+	}
+	type args struct {
+		policy UnknownFieldPolicy
+		finder UnknownFieldFinder
+	}
+	type want struct {
+		spec map[string]interface{}
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"Propagate": {
+			reason: "The unknown fields should be written as they are",
+			args: args{
+				policy: UnknownFieldPolicyPropagate,
+				finder: schemas,
+			},
+			want: want{
+				spec: spec(),
+			},
+		},
+		"StripBySchema": {
+			reason: "The fields the remote schema does not define should be removed",
+			args: args{
+				policy: UnknownFieldPolicyStrip,
+				finder: schemas,
+			},
+			want: want{
+				spec: map[string]interface{}{"size": int64(10)},
+			},
+		},
+		"StripByKnownFields": {
+			reason: "The fields that are not known should be removed",
+			args: args{
+				policy: UnknownFieldPolicyStrip,
+				finder: KnownFields{"spec.size"},
+			},
+			want: want{
+				spec: map[string]interface{}{"size": int64(10)},
+			},
+		},
+		"ErrorBySchema": {
+			reason: "An error listing the fields the remote schema does not define should be returned, leaving the spec alone",
+			args: args{
+				policy: UnknownFieldPolicyError,
+				finder: schemas,
+			},
+			want: want{
+				spec: spec(),
+				err:  errors.Errorf(errUnknownFieldsFmt, "spec.random-field"),
+			},
+		},
+		"ErrorByKnownFields": {
+			reason: "An error listing the fields that are not known should be returned, leaving the spec alone",
+			args: args{
+				policy: UnknownFieldPolicyError,
+				finder: KnownFields{"spec.size"},
+			},
+			want: want{
+				spec: spec(),
+				err:  errors.Errorf(errUnknownFieldsFmt, "spec.random-field"),
+			},
+		},
+		"NoUnknownFields": {
+			reason: "No error should be returned if all fields are known",
+			args: args{
+				policy: UnknownFieldPolicyError,
+				finder: KnownFields{"spec.size", "spec.random-field"},
+			},
+			want: want{
+				spec: spec(),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			remote.Object["spec"] = spec()
+			err := NewUnknownFieldChecker(tc.args.policy, tc.args.finder, logging.NewNopLogger()).Configure(context.Background(), nil, remote)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nc.Configure(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.spec, remote.Object["spec"]); diff != "" {
+				t.Errorf("\nReason: %s\nc.Configure(...): -want spec, +got spec:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestKnownFieldsUnknownFields(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		known  KnownFields
+		spec   map[string]interface{}
+		want   []string
+	}{
+		"WithinKnownField": {
+			reason: "The fields within a known field should be known",
+			known:  KnownFields{"spec.forProvider"},
+			spec:   map[string]interface{}{"forProvider": map[string]interface{}{"size": int64(10), "random-field": "random-val"}},
+		},
+		"NestedUnknownField": {
+			reason: "The fields next to a known field should be unknown",
+			known:  KnownFields{"spec.forProvider.size"},
+			spec:   map[string]interface{}{"forProvider": map[string]interface{}{"size": int64(10), "random-field": "random-val"}},
+			want:   []string{"spec.forProvider.random-field"},
+		},
+		"Arrays": {
+			reason: "A path should apply to the fields of all elements of an array",
+			known:  KnownFields{"spec.disks.size"},
+			spec: map[string]interface{}{"disks": []interface{}{
+				map[string]interface{}{"size": int64(1)},
+				map[string]interface{}{"size": int64(2), "random-field": "random-val"},
+			}},
+			want: []string{"spec.disks[1].random-field"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			remote := claim.New(claim.WithGroupVersionKind(scopedGVK))
+			remote.Object["spec"] = tc.spec
+			got, err := tc.known.UnknownFields(context.Background(), remote, false)
+			if err != nil {
+				t.Fatalf("\nReason: %s\nk.UnknownFields(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nk.UnknownFields(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+const defaultVersionTTL = 5 * time.Minute
+
+// A VersionDiscoverer discovers the API groups of the remote cluster and the
+// resources they serve.
+type VersionDiscoverer interface {
+	discovery.ServerGroupsInterface
+	discovery.ServerResourcesInterface
+}
+
+// A VersionNegotiatorOption configures a VersionNegotiator.
+type VersionNegotiatorOption func(*VersionNegotiator)
+
+// WithVersionTTL specifies how long the negotiated version of a kind is used
+// before it's negotiated again, e.g. to notice a version that the remote CRD
+// stopped serving.
+func WithVersionTTL(d time.Duration) VersionNegotiatorOption {
+	return func(n *VersionNegotiator) {
+		n.ttl = d
+	}
+}
+
+// NewVersionNegotiator returns a new *VersionNegotiator that uses the supplied
+// discovery client of the remote cluster.
+func NewVersionNegotiator(d VersionDiscoverer, opts ...VersionNegotiatorOption) *VersionNegotiator {
+	n := &VersionNegotiator{
+		discovery: d,
+		ttl:       defaultVersionTTL,
+		now:       time.Now,
+		versions:  map[schema.GroupVersionKind]negotiatedVersion{},
+	}
+	for _, f := range opts {
+		f(n)
+	}
+	return n
+}
+
+type negotiatedVersion struct {
+	gvk     schema.GroupVersionKind
+	fetched time.Time
+}
+
+// VersionNegotiator determines the version the remote instances are read and
+// written at, which may differ from the version of the local instances, e.g.
+// while the remote CRD is being upgraded. The remote instances are read and
+// written at the version of the local instances as long as the remote cluster
+// serves it, so that its conversion webhook converts them from and to the
+// version they're stored at rather than the agent bypassing it. Otherwise the
+// version the remote cluster prefers is used, and only the conditions of the
+// status of the remote instances are propagated since their other fields may
+// differ between the versions. See StatusPropagator.
+type VersionNegotiator struct {
+	discovery VersionDiscoverer
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	versions map[schema.GroupVersionKind]negotiatedVersion
+}
+
+// Negotiate returns the kind the remote instances of the supplied kind are read
+// and written as.
+func (n *VersionNegotiator) Negotiate(gvk schema.GroupVersionKind) (schema.GroupVersionKind, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if v, ok := n.versions[gvk]; ok && n.now().Sub(v.fetched) < n.ttl {
+		return v.gvk, nil
+	}
+	gl, err := n.discovery.ServerGroups()
+	if err != nil {
+		return schema.GroupVersionKind{}, errors.Wrap(err, errDiscoverGroups)
+	}
+	// The version of the local instances is tried first, then the preferred one
+	// and then the others in the order of their priority.
+	var candidates []string
+	for _, g := range gl.Groups {
+		if g.Name != gvk.Group {
+			continue
+		}
+		for _, v := range g.Versions {
+			if v.Version == gvk.Version {
+				candidates = append(candidates, v.Version)
+			}
+		}
+		candidates = append(candidates, g.PreferredVersion.Version)
+		for _, v := range g.Versions {
+			candidates = append(candidates, v.Version)
+		}
+	}
+	tried := map[string]bool{}
+	for _, v := range candidates {
+		if tried[v] {
+			continue
+		}
+		tried[v] = true
+		remote := schema.GroupVersionKind{Group: gvk.Group, Version: v, Kind: gvk.Kind}
+		served, err := n.serves(remote)
+		if err != nil {
+			return schema.GroupVersionKind{}, err
+		}
+		if served {
+			n.versions[gvk] = negotiatedVersion{gvk: remote, fetched: n.now()}
+			return remote, nil
+		}
+	}
+	return schema.GroupVersionKind{}, errors.Errorf(errKindNotServedFmt, gvk.Kind)
+}
+
+// serves returns whether the remote cluster serves the supplied kind.
+func (n *VersionNegotiator) serves(gvk schema.GroupVersionKind) (bool, error) {
+	l, err := n.discovery.ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if err != nil {
+		return false, errors.Wrap(err, errDiscoverResources)
+	}
+	for _, r := range l.APIResources {
+		if r.Kind == gvk.Kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	runtimeresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+// newVersionedDiscovery returns a discovery client of a remote cluster whose
+// example.org group serves the supplied versions, in the order of their
+// priority. Each version serves the listed kinds.
+func newVersionedDiscovery(versions ...map[string][]string) *fakediscovery.FakeDiscovery {
+	d := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	for _, vs := range versions {
+		for v, kinds := range vs {
+			l := &metav1.APIResourceList{GroupVersion: schema.GroupVersion{Group: scopedGVK.Group, Version: v}.String()}
+			for _, k := range kinds {
+				l.APIResources = append(l.APIResources, metav1.APIResource{Kind: k, Namespaced: true})
+			}
+			d.Resources = append(d.Resources, l)
+		}
+	}
+	return d
+}
+
+func TestVersionNegotiatorNegotiate(t *testing.T) {
+	type want struct {
+		gvk schema.GroupVersionKind
+		err error
+	}
+	cases := map[string]struct {
+		reason    string
+		discovery *fakediscovery.FakeDiscovery
+		want
+	}{
+		"LocalVersionServed": {
+			reason: "The version of the local instances should be used if the remote cluster serves it, so that its conversion webhook converts them",
+			discovery: newVersionedDiscovery(
+				map[string][]string{"v2": {"Database"}},
+				map[string][]string{"v1": {"Database"}},
+			),
+			want: want{
+				gvk: scopedGVK,
+			},
+		},
+		"PreferredVersion": {
+			reason:    "The preferred version of the remote cluster should be used if it doesn't serve the version of the local instances",
+			discovery: newVersionedDiscovery(map[string][]string{"v2": {"Database"}}),
+			want: want{
+				gvk: scopedGVK.GroupKind().WithVersion("v2"),
+			},
+		},
+		"OtherVersion": {
+			reason: "The first version that serves the kind should be used if the preferred version doesn't",
+			discovery: newVersionedDiscovery(
+				map[string][]string{"v3": {"Cache"}},
+				map[string][]string{"v2": {"Database"}},
+			),
+			want: want{
+				gvk: scopedGVK.GroupKind().WithVersion("v2"),
+			},
+		},
+		"KindNotServed": {
+			reason:    "An error should be returned if no version of the group serves the kind",
+			discovery: newVersionedDiscovery(map[string][]string{"v2": {"Cache"}}),
+			want: want{
+				err: errors.Errorf(errKindNotServedFmt, scopedGVK.Kind),
+			},
+		},
+		"GroupNotServed": {
+			reason:    "An error should be returned if the remote cluster doesn't serve the group",
+			discovery: &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}},
+			want: want{
+				err: errors.Errorf(errKindNotServedFmt, scopedGVK.Kind),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewVersionNegotiator(tc.discovery).Negotiate(scopedGVK)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nn.Negotiate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.gvk, got); diff != "" {
+				t.Errorf("\nReason: %s\nn.Negotiate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVersionNegotiatorCache(t *testing.T) {
+	d := newVersionedDiscovery(map[string][]string{"v2": {"Database"}})
+	n := NewVersionNegotiator(d, WithVersionTTL(time.Minute))
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	reads := func() int {
+		i := 0
+		for _, a := range d.Actions() {
+			if a.GetResource().Resource == "group" {
+				i++
+			}
+		}
+		return i
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := n.Negotiate(scopedGVK); err != nil {
+			t.Fatalf("n.Negotiate(...): unexpected error: %s", err)
+		}
+	}
+	if diff := cmp.Diff(1, reads()); diff != "" {
+		t.Errorf("\nReason: %s\nreads: -want, +got:\n%s", "The version should be negotiated only once within the TTL", diff)
+	}
+
+	// The remote CRD now serves the version of the local instances too.
+	d.Resources = append(d.Resources, &metav1.APIResourceList{
+		GroupVersion: scopedGVK.GroupVersion().String(),
+		APIResources: []metav1.APIResource{{Kind: scopedGVK.Kind, Namespaced: true}},
+	})
+	now = now.Add(2 * time.Minute)
+	got, err := n.Negotiate(scopedGVK)
+	if err != nil {
+		t.Fatalf("n.Negotiate(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(scopedGVK, got); diff != "" {
+		t.Errorf("\nReason: %s\nn.Negotiate(...): -want, +got:\n%s", "The version should be negotiated again once the TTL passes", diff)
+	}
+}
+
+func TestReconcileVersionNegotiation(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				local := claim.New(claim.WithGroupVersionKind(scopedGVK))
+				local.SetName("cool-db")
+				local.Object["spec"] = map[string]interface{}{"size": int64(10)}
+				local.DeepCopyInto(obj.(*unstructured.Unstructured))
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	var read, created string
+	remote := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			read = obj.(*unstructured.Unstructured).GetAPIVersion()
+			return kerrors.NewNotFound(schema.GroupResource{}, "")
+		},
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			created = obj.(*unstructured.Unstructured).GetAPIVersion()
+			return nil
+		},
+	}
+	r := NewReconciler(m, remote, scopedGVK,
+		WithFinalizer(runtimeresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ runtimeresource.Object) error { return nil }}),
+		WithPropagator(PropagateFn(func(_ context.Context, _, _ *claim.Unstructured) error { return nil })),
+		WithVersionNegotiation(NewVersionNegotiator(newVersionedDiscovery(map[string][]string{"v2": {"Database"}}))),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+	if diff := cmp.Diff("example.org/v2", read); diff != "" {
+		t.Errorf("\nReason: %s\nremote.Get(...): -want, +got:\n%s", "The remote instance should be read at the negotiated version", diff)
+	}
+	if diff := cmp.Diff("example.org/v2", created); diff != "" {
+		t.Errorf("\nReason: %s\nremote.Create(...): -want, +got:\n%s", "The remote instance should be written at the negotiated version", diff)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultWarmupTTL is how long the objects that are listed by a WarmupClient
+// are served for by default.
+const DefaultWarmupTTL = 5 * time.Minute
+
+const errWarmup = "cannot list objects to warm up the cache"
+
+// A WarmupClientOption configures a WarmupClient.
+type WarmupClientOption func(*WarmupClient)
+
+// WithWarmupDependents makes the WarmupClient also list the objects of the
+// supplied list types, e.g. the connection secrets of the claims. They're
+// listed only in the namespaces of the objects of the primary list type.
+func WithWarmupDependents(lists ...runtime.Object) WarmupClientOption {
+	return func(c *WarmupClient) {
+		c.dependents = append(c.dependents, lists...)
+	}
+}
+
+// WithWarmupTTL specifies how long the listed objects are served for. The ones
+// that are not read by then are dropped, and read via the underlying client
+// if they're ever read.
+func WithWarmupTTL(d time.Duration) WarmupClientOption {
+	return func(c *WarmupClient) {
+		c.ttl = d
+	}
+}
+
+// NewWarmupClient returns a new *WarmupClient that lists the objects of the
+// supplied list type once to serve the first Get of each of them.
+func NewWarmupClient(c client.Client, list runtime.Object, o ...WarmupClientOption) *WarmupClient {
+	wc := &WarmupClient{Client: c, list: list, ttl: DefaultWarmupTTL, now: time.Now}
+	for _, fn := range o {
+		fn(wc)
+	}
+	return wc
+}
+
+// WarmupClient is a client.Client that warms up a cache with a single List call
+// per type before the first Get, and serves every cached object only once.
+// This cuts the number of Get calls during the initial reconcile of all claims
+// when the agent starts, while the later reconciles still read fresh objects.
+// Objects that are not in the cache, or that were cached longer than its TTL
+// ago, are read via the underlying client.
+type WarmupClient struct {
+	client.Client
+	list       runtime.Object
+	dependents []runtime.Object
+	ttl        time.Duration
+	now        func() time.Time
+
+	once    sync.Once
+	err     error
+	mu      sync.Mutex
+	objects map[warmupKey]runtime.Object
+	expires time.Time
+}
+type warmupKey struct {
+	kind interface{}
+	nn   types.NamespacedName
+}
+
+// kindOf returns a comparable value that identifies the kind of the supplied
+// object; the GroupVersionKind for unstructured objects and the Go type for
+// typed ones, which usually don't have their GroupVersionKind set.
+func kindOf(o runtime.Object) interface{} {
+	if u, ok := o.(runtime.Unstructured); ok {
+		return u.GetObjectKind().GroupVersionKind()
+	}
+	return reflect.TypeOf(o)
+}
+
+// Warm lists the objects of all types and caches them. Nothing is cached if
+// any of them cannot be listed, so that a partial snapshot is never served;
+// all objects are then read via the underlying client. The cache is warmed up
+// only once, so the error of the first call is returned by all of them.
+func (c *WarmupClient) Warm(ctx context.Context) error {
+	c.once.Do(func() {
+		objects := map[warmupKey]runtime.Object{}
+		namespaces, err := c.warm(ctx, c.list, nil, objects)
+		if err != nil {
+			c.err = err
+			return
+		}
+		for _, proto := range c.dependents {
+			for ns := range namespaces {
+				if _, err := c.warm(ctx, proto, client.InNamespace(ns), objects); err != nil {
+					c.err = err
+					return
+				}
+			}
+		}
+		c.mu.Lock()
+		c.objects = objects
+		c.expires = c.now().Add(c.ttl)
+		c.mu.Unlock()
+	})
+	return c.err
+}
+
+// warm lists the objects of the supplied list type into the supplied cache,
+// and returns the namespaces they're in.
+func (c *WarmupClient) warm(ctx context.Context, proto runtime.Object, o client.ListOption, objects map[warmupKey]runtime.Object) (map[string]bool, error) {
+	l := proto.DeepCopyObject()
+	var opts []client.ListOption
+	if o != nil {
+		opts = append(opts, o)
+	}
+	if err := c.Client.List(ctx, l, opts...); err != nil {
+		return nil, errors.Wrap(err, errWarmup)
+	}
+	items, err := apimeta.ExtractList(l)
+	if err != nil {
+		return nil, errors.Wrap(err, errWarmup)
+	}
+	namespaces := map[string]bool{}
+	for _, obj := range items {
+		m, err := apimeta.Accessor(obj)
+		if err != nil {
+			return nil, errors.Wrap(err, errWarmup)
+		}
+		objects[warmupKey{kind: kindOf(obj), nn: types.NamespacedName{Namespace: m.GetNamespace(), Name: m.GetName()}}] = obj
+		if m.GetNamespace() != "" {
+			namespaces[m.GetNamespace()] = true
+		}
+	}
+	return namespaces, nil
+}
+
+// Get serves the object from the cache if it's there, wasn't served before and
+// hasn't expired.
+// Otherwise, it reads the object via the underlying client.
+func (c *WarmupClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	// A failed warmup caches nothing, so the objects are read via the
+	// underlying client, which reports its own errors.
+	_ = c.Warm(ctx)
+	k := warmupKey{kind: kindOf(obj), nn: key}
+	c.mu.Lock()
+	if c.objects != nil && !c.now().Before(c.expires) {
+		c.objects = nil
+	}
+	cached, ok := c.objects[k]
+	delete(c.objects, k)
+	c.mu.Unlock()
+	if !ok {
+		return c.Client.Get(ctx, key, obj)
+	}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		u.SetUnstructuredContent(runtime.DeepCopyJSON(cached.(runtime.Unstructured).UnstructuredContent()))
+		return nil
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(cached.DeepCopyObject()).Elem())
+	return nil
+}
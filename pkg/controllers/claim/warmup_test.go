@@ -0,0 +1,183 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestWarmupClient(t *testing.T) {
+	newClaim := func(name string) unstructured.Unstructured {
+		c := claim.New(claim.WithGroupVersionKind(gvk))
+		c.SetName(name)
+		c.SetNamespace("ns")
+		return c.Unstructured
+	}
+	lists, gets := 0, 0
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, obj runtime.Object, opts ...client.ListOption) error {
+			lists++
+			switch l := obj.(type) {
+			case *unstructured.UnstructuredList:
+				l.Items = []unstructured.Unstructured{newClaim("a"), newClaim("b")}
+			case *corev1.SecretList:
+				if diff := cmp.Diff([]client.ListOption{client.InNamespace("ns")}, opts); diff != "" {
+					t.Errorf("\nReason: %s\nList(...): -want options, +got options:\n%s", "The secrets should be listed only in the namespaces of the claims", diff)
+				}
+				l.Items = []corev1.Secret{{
+					ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "ns"},
+					Data:       map[string][]byte{"k": []byte("v")},
+				}}
+			}
+			return nil
+		},
+		MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+			gets++
+			return nil
+		},
+	}
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	c := NewWarmupClient(kube, l, WithWarmupDependents(&corev1.SecretList{}))
+	ctx := context.Background()
+
+	a := claim.New(claim.WithGroupVersionKind(gvk))
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "a"}, &a.Unstructured); err != nil {
+		t.Fatalf("c.Get(...): %s", err)
+	}
+	if diff := cmp.Diff("a", a.GetName()); diff != "" {
+		t.Errorf("\nReason: %s\nc.Get(...): -want, +got:\n%s", "The claim should be served from the cache", diff)
+	}
+	b := claim.New(claim.WithGroupVersionKind(gvk))
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "b"}, &b.Unstructured); err != nil {
+		t.Fatalf("c.Get(...): %s", err)
+	}
+	s := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "s"}, s); err != nil {
+		t.Fatalf("c.Get(...): %s", err)
+	}
+	if diff := cmp.Diff(map[string][]byte{"k": []byte("v")}, s.Data); diff != "" {
+		t.Errorf("\nReason: %s\nc.Get(...): -want, +got:\n%s", "The secret should be served from the cache", diff)
+	}
+	if diff := cmp.Diff(2, lists); diff != "" {
+		t.Errorf("\nReason: %s\nList calls: -want, +got:\n%s", "Every type should be listed only once", diff)
+	}
+	if diff := cmp.Diff(0, gets); diff != "" {
+		t.Errorf("\nReason: %s\nGet calls: -want, +got:\n%s", "The cached objects should not be read from the API", diff)
+	}
+
+	// The cached objects are served only once, and the ones that are not cached
+	// are read from the API.
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "a"}, &a.Unstructured); err != nil {
+		t.Fatalf("c.Get(...): %s", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "missing"}, &a.Unstructured); err != nil {
+		t.Fatalf("c.Get(...): %s", err)
+	}
+	if diff := cmp.Diff(2, gets); diff != "" {
+		t.Errorf("\nReason: %s\nGet calls: -want, +got:\n%s", "Objects should be read from the API after they are served from the cache", diff)
+	}
+	if diff := cmp.Diff(2, lists); diff != "" {
+		t.Errorf("\nReason: %s\nList calls: -want, +got:\n%s", "The cache should not be warmed up again", diff)
+	}
+}
+
+func TestWarmupClientListFailed(t *testing.T) {
+	gets := 0
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+			switch l := obj.(type) {
+			case *unstructured.UnstructuredList:
+				c := claim.New(claim.WithGroupVersionKind(gvk))
+				c.SetName("a")
+				c.SetNamespace("ns")
+				l.Items = []unstructured.Unstructured{c.Unstructured}
+			case *corev1.SecretList:
+				return errBoom
+			}
+			return nil
+		},
+		MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+			gets++
+			return nil
+		},
+	}
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	c := NewWarmupClient(kube, l, WithWarmupDependents(&corev1.SecretList{}))
+	ctx := context.Background()
+
+	if diff := cmp.Diff(errors.Wrap(errBoom, errWarmup), c.Warm(ctx), test.EquateErrors()); diff != "" {
+		t.Errorf("\nReason: %s\nc.Warm(...): -want error, +got error:\n%s", "The warmup should fail if a type cannot be listed", diff)
+	}
+	a := claim.New(claim.WithGroupVersionKind(gvk))
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "a"}, &a.Unstructured); err != nil {
+		t.Fatalf("c.Get(...): %s", err)
+	}
+	if diff := cmp.Diff(1, gets); diff != "" {
+		t.Errorf("\nReason: %s\nGet calls: -want, +got:\n%s", "Nothing should be served from the cache of a failed warmup", diff)
+	}
+}
+
+func TestWarmupClientExpired(t *testing.T) {
+	gets := 0
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+			c := claim.New(claim.WithGroupVersionKind(gvk))
+			c.SetName("a")
+			c.SetNamespace("ns")
+			obj.(*unstructured.UnstructuredList).Items = []unstructured.Unstructured{c.Unstructured}
+			return nil
+		},
+		MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+			gets++
+			return nil
+		},
+	}
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	c := NewWarmupClient(kube, l, WithWarmupTTL(time.Minute))
+	t0 := time.Now()
+	c.now = func() time.Time { return t0 }
+	ctx := context.Background()
+	if err := c.Warm(ctx); err != nil {
+		t.Fatalf("c.Warm(...): %s", err)
+	}
+
+	c.now = func() time.Time { return t0.Add(2 * time.Minute) }
+	a := claim.New(claim.WithGroupVersionKind(gvk))
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "a"}, &a.Unstructured); err != nil {
+		t.Fatalf("c.Get(...): %s", err)
+	}
+	if diff := cmp.Diff(1, gets); diff != "" {
+		t.Errorf("\nReason: %s\nGet calls: -want, +got:\n%s", "Objects should be read from the API once the cache expired", diff)
+	}
+}
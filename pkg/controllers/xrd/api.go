@@ -22,10 +22,14 @@ import (
 	"github.com/pkg/errors"
 
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
 
+	"github.com/crossplane/agent/pkg/controllers/claim"
 	"github.com/crossplane/agent/pkg/resource"
 )
 
@@ -51,6 +55,15 @@ func (r FetchFn) Fetch(ctx context.Context, ip v1alpha1.CompositeResourceDefinit
 	return r(ctx, ip)
 }
 
+// ConfigLoaderFn is used to provide a single function instead of a full object
+// to satisfy ConfigLoader interface.
+type ConfigLoaderFn func(ctx context.Context) ([]claim.ReconcilerOption, string, error)
+
+// Load calls ConfigLoaderFn it belongs to.
+func (l ConfigLoaderFn) Load(ctx context.Context) ([]claim.ReconcilerOption, string, error) {
+	return l(ctx)
+}
+
 // NewAPIRemoteCRDFetcher returns a new APIRemoteCRDFetcher.
 func NewAPIRemoteCRDFetcher(client client.Client) *APIRemoteCRDFetcher {
 	return &APIRemoteCRDFetcher{
@@ -74,3 +87,45 @@ func (r *APIRemoteCRDFetcher) Fetch(ctx context.Context, xrd v1alpha1.CompositeR
 	}
 	return resource.SanitizedDeepCopyObject(remote).(*v1beta1.CustomResourceDefinition), nil
 }
+
+// KindCheckFn is used to provide a single function instead of a full object to
+// satisfy KindChecker interface.
+type KindCheckFn func(ctx context.Context, gvk schema.GroupVersionKind) (bool, error)
+
+// Served calls KindCheckFn it belongs to.
+func (fn KindCheckFn) Served(ctx context.Context, gvk schema.GroupVersionKind) (bool, error) {
+	return fn(ctx, gvk)
+}
+
+// NewDiscoveryKindChecker returns a new *DiscoveryKindChecker that uses the
+// given discovery client of the remote cluster. The local kinds are checked as
+// the remote kinds they're aliased to, if any.
+func NewDiscoveryKindChecker(d discovery.ServerResourcesInterface, a claim.GVKAliases) *DiscoveryKindChecker {
+	return &DiscoveryKindChecker{discovery: d, aliases: a}
+}
+
+// DiscoveryKindChecker checks whether the remote cluster serves a kind, e.g.
+// because its CRD is installed, with the discovery API.
+type DiscoveryKindChecker struct {
+	discovery discovery.ServerResourcesInterface
+	aliases   claim.GVKAliases
+}
+
+// Served returns true if the remote cluster serves the remote kind of the
+// given local kind in its exact version.
+func (c *DiscoveryKindChecker) Served(_ context.Context, gvk schema.GroupVersionKind) (bool, error) {
+	rgvk := c.aliases.Remote(gvk)
+	l, err := c.discovery.ServerResourcesForGroupVersion(rgvk.GroupVersion().String())
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, errDiscoverKind)
+	}
+	for _, r := range l.APIResources {
+		if r.Kind == rgvk.Kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
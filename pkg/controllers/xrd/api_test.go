@@ -23,12 +23,19 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
+
+	"github.com/crossplane/agent/pkg/controllers/claim"
 )
 
 func TestFetch(t *testing.T) {
@@ -98,3 +105,68 @@ func TestFetch(t *testing.T) {
 		})
 	}
 }
+
+// notFoundDiscovery reports every group version as not found the way the API
+// server does, unlike FakeDiscovery.
+type notFoundDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d *notFoundDiscovery) ServerResourcesForGroupVersion(gv string) (*metav1.APIResourceList, error) {
+	return nil, kerrors.NewNotFound(schema.GroupResource{}, gv)
+}
+
+func TestDiscoveryKindCheckerServed(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Database"}
+	served := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{
+		Resources: []*metav1.APIResourceList{{
+			GroupVersion: "example.org/v1",
+			APIResources: []metav1.APIResource{{Name: "databases", Kind: "Database"}},
+		}},
+	}}
+	type want struct {
+		served bool
+		err    error
+	}
+	cases := map[string]struct {
+		reason    string
+		discovery discovery.ServerResourcesInterface
+		aliases   claim.GVKAliases
+		want
+	}{
+		"Served": {
+			reason:    "A kind that the remote cluster serves should be reported as served",
+			discovery: served,
+			want:      want{served: true},
+		},
+		"AliasNotServed": {
+			reason:    "A kind should be checked as the remote kind it's aliased to",
+			discovery: served,
+			aliases:   claim.GVKAliases{gvk: gvk.GroupVersion().WithKind("DatabaseRequirement")},
+			want:      want{served: false},
+		},
+		"GroupVersionNotServed": {
+			reason:    "A kind should not be reported as served if its group version is not found",
+			discovery: &notFoundDiscovery{FakeDiscovery: served},
+			want:      want{served: false},
+		},
+		"DiscoveryFailed": {
+			reason:    "An error should be returned if the resources of the group version cannot be discovered",
+			discovery: &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}},
+			want: want{
+				err: errors.Wrap(errors.New(`GroupVersion "example.org/v1" not found`), errDiscoverKind),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewDiscoveryKindChecker(tc.discovery, tc.aliases).Served(context.Background(), gvk)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nc.Served(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.served, got); diff != "" {
+				t.Errorf("\nReason: %s\nc.Served(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -27,12 +27,15 @@ import (
 	kmeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	kcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
@@ -44,6 +47,7 @@ import (
 	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1/ccrd"
 	coreclaim "github.com/crossplane/crossplane/pkg/controller/apiextensions/claim"
 
+	agentv1alpha1 "github.com/crossplane/agent/apis/agent/v1alpha1"
 	"github.com/crossplane/agent/pkg/controllers/claim"
 )
 
@@ -68,23 +72,49 @@ const (
 	errDeleteCR        = "cannot delete custom resources of claim type"
 	errDeleteCRD       = "cannot delete crd of claim type"
 	errAddFinalizerXRD = "cannot add finalizer to composite resource definition"
+	errLoadConfig      = "cannot load agent configuration"
+	errDiscoverKind    = "cannot discover whether kind of claim is served"
 )
 
 // Setup adds a controller that will reconcile CompositeResourceDefinitions that
 // offer resource claim in the local cluster and create CRDs & controllers that
 // will reconcile those new types.
-func Setup(mgr manager.Manager, remoteClient client.Client, logger logging.Logger) error {
+func Setup(mgr manager.Manager, remoteClient client.Client, logger logging.Logger, opts ...ReconcilerOption) error {
 	name := "ClaimCustomResourceDefinitions"
-	r := NewReconciler(mgr, remoteClient,
+	r := NewReconciler(mgr, remoteClient, append([]ReconcilerOption{
 		WithCRDFetcher(NewAPIRemoteCRDFetcher(remoteClient)),
 		WithLogger(logger),
-		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))))
-	return ctrl.NewControllerManagedBy(mgr).
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+	}, opts...)...)
+	b := ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		For(&v1alpha1.CompositeResourceDefinition{}).
 		WithEventFilter(resource.NewXRDWithClaim()).
-		Owns(&v1beta1.CustomResourceDefinition{}).
-		Complete(r)
+		Owns(&v1beta1.CustomResourceDefinition{})
+	if r.config != nil {
+		// All claim controllers need to be restarted when the configuration
+		// changes, so we enqueue all CompositeResourceDefinitions.
+		b = b.Watches(&source.Kind{Type: &agentv1alpha1.AgentConfig{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(_ handler.MapObject) []reconcile.Request {
+				return EnqueueAll(mgr.GetClient())
+			}),
+		})
+	}
+	return b.Complete(r)
+}
+
+// EnqueueAll returns a request for every CompositeResourceDefinition in the
+// cluster.
+func EnqueueAll(kube client.Reader) []reconcile.Request {
+	l := &v1alpha1.CompositeResourceDefinitionList{}
+	if err := kube.List(context.Background(), l); err != nil {
+		return nil
+	}
+	reqs := make([]reconcile.Request, len(l.Items))
+	for i := range l.Items {
+		reqs[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: l.Items[i].GetName()}}
+	}
+	return reqs
 }
 
 // WithControllerEngine specifies how the Reconciler should start and stop controllers.
@@ -130,6 +160,69 @@ func WithRecorder(er event.Recorder) ReconcilerOption {
 	}
 }
 
+// WithClaimReconcilerOptions specifies the options of the reconcilers that are
+// started for the claim types.
+func WithClaimReconcilerOptions(opts ...claim.ReconcilerOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.claimOpts = append(r.claimOpts, opts...)
+	}
+}
+
+// WithClaimRemoteClient specifies the client the claim reconcilers should use
+// for the remote cluster. The client that's given to NewReconciler is used by
+// default.
+func WithClaimRemoteClient(c client.Client) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.claimRemote = c
+	}
+}
+
+// WithConfigLoader specifies how the Reconciler should load the options of the
+// claim reconcilers that can change while the agent is running. The claim
+// controllers are restarted whenever the loaded configuration changes.
+func WithConfigLoader(l ConfigLoader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.config = l
+	}
+}
+
+// WithRemoteKindChecker specifies how the Reconciler should check whether the
+// remote cluster serves the kinds of the claims. The claims of a kind that is
+// not served yet cannot be synced, so the kind is polled for and the claim
+// controller is restarted once it's served in order to retry all of its claims
+// at once. Nothing is checked by default.
+func WithRemoteKindChecker(k KindChecker) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.kinds = k
+	}
+}
+
+// WithClaimEventHandler specifies how the claim controllers should enqueue
+// the claims they watch. The claims are enqueued by their name in the order of
+// their events by default.
+func WithClaimEventHandler(h handler.EventHandler) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.claimHandler = h
+	}
+}
+
+// WithMaintenanceSwitch makes the Reconciler write nothing while the supplied
+// MaintenanceSwitch is enabled. The claim controllers that were started keep
+// running, and are expected to share the switch.
+func WithMaintenanceSwitch(s *claim.MaintenanceSwitch) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.maintenance = s
+	}
+}
+
+// WithManagedKinds makes the Reconciler record the kinds of claims that it
+// runs controllers for in the supplied ManagedKinds.
+func WithManagedKinds(k *claim.ManagedKinds) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.managed = k
+	}
+}
+
 // ReconcilerOption is used to configure *Reconciler.
 type ReconcilerOption func(*Reconciler)
 
@@ -141,12 +234,17 @@ func NewReconciler(mgr manager.Manager, remoteClient client.Client, opts ...Reco
 			Client:     mgr.GetClient(),
 			Applicator: runtimeresource.NewAPIUpdatingApplicator(mgr.GetClient()),
 		},
-		remote:    remoteClient,
-		engine:    controller.NewEngine(mgr),
-		crd:       NewNopFetcher(),
-		finalizer: runtimeresource.NewAPIFinalizer(mgr.GetClient(), finalizer),
-		log:       logging.NewNopLogger(),
-		record:    event.NewNopRecorder(),
+		remote:       remoteClient,
+		claimRemote:  remoteClient,
+		claimHandler: &handler.EnqueueRequestForObject{},
+		engine:       controller.NewEngine(mgr),
+		crd:          NewNopFetcher(),
+		finalizer:    runtimeresource.NewAPIFinalizer(mgr.GetClient(), finalizer),
+		log:          logging.NewNopLogger(),
+		record:       event.NewNopRecorder(),
+		versions:     map[string]string{},
+		unserved:     map[string]bool{},
+		managed:      claim.NewManagedKinds(),
 	}
 	for _, f := range opts {
 		f(r)
@@ -160,6 +258,19 @@ type ControllerEngine interface {
 	Stop(name string)
 }
 
+// ConfigLoader can be satisfied with objects that can load the options of the
+// claim reconcilers together with the version of the configuration they were
+// loaded from.
+type ConfigLoader interface {
+	Load(ctx context.Context) ([]claim.ReconcilerOption, string, error)
+}
+
+// KindChecker can be satisfied with objects that can check whether the remote
+// cluster serves the remote kind of a local kind of claim.
+type KindChecker interface {
+	Served(ctx context.Context, gvk schema.GroupVersionKind) (bool, error)
+}
+
 // CRDFetcher can be satisfied with objects that can return a CRD with
 // CompositeResourceDefinition information.
 type CRDFetcher interface {
@@ -175,9 +286,24 @@ type Reconciler struct {
 	local  runtimeresource.ClientApplicator
 	remote client.Client
 
-	crd       CRDFetcher
-	engine    ControllerEngine
-	finalizer runtimeresource.Finalizer
+	crd          CRDFetcher
+	engine       ControllerEngine
+	finalizer    runtimeresource.Finalizer
+	claimOpts    []claim.ReconcilerOption
+	claimRemote  client.Client
+	claimHandler handler.EventHandler
+	config       ConfigLoader
+	kinds        KindChecker
+	maintenance  *claim.MaintenanceSwitch
+	managed      *claim.ManagedKinds
+
+	// versions are the configuration versions that the claim controllers were
+	// started with, keyed by controller name.
+	versions map[string]string
+
+	// unserved are the names of the claim controllers whose remote kind was
+	// not served when last checked.
+	unserved map[string]bool
 
 	log    logging.Logger
 	record event.Recorder
@@ -199,6 +325,22 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, localPrefix+errGetXRD)
 	}
 
+	// The configuration may put the agent in maintenance mode, in which we
+	// write nothing, so we load it before we write anything.
+	var configOpts []claim.ReconcilerOption
+	var version string
+	if r.config != nil {
+		var err error
+		configOpts, version, err = r.config.Load(ctx)
+		if err != nil {
+			return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, localPrefix+errLoadConfig)
+		}
+	}
+	if r.maintenance.Enabled() {
+		log.Debug("Skipping composite resource definition in maintenance mode", "requeue-after", time.Now().Add(longWait))
+		return reconcile.Result{RequeueAfter: longWait}, nil
+	}
+
 	// We will fetch the CRD of the claim that CompositeResourceDefinition offers
 	// and apply it in the local cluster so that we can start the sync controller
 	// targeting that type.
@@ -226,6 +368,9 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 			// previous reconcile, but we try again just in case. This is a
 			// no-op if the controller was already stopped.
 			r.engine.Stop(coreclaim.ControllerName(xrd.GetName()))
+			delete(r.versions, coreclaim.ControllerName(xrd.GetName()))
+			delete(r.unserved, coreclaim.ControllerName(xrd.GetName()))
+			r.managed.Remove(coreclaim.ControllerName(xrd.GetName()))
 
 			if err := r.finalizer.RemoveFinalizer(ctx, xrd); err != nil {
 				return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, localPrefix+errRemoveFinalizer)
@@ -261,6 +406,9 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 		// The controller should be stopped before the deletion of CRD so that
 		// it doesn't crash.
 		r.engine.Stop(coreclaim.ControllerName(xrd.GetName()))
+		delete(r.versions, coreclaim.ControllerName(xrd.GetName()))
+		delete(r.unserved, coreclaim.ControllerName(xrd.GetName()))
+		r.managed.Remove(coreclaim.ControllerName(xrd.GetName()))
 
 		if err := r.local.Delete(ctx, localCRD); runtimeresource.IgnoreNotFound(err) != nil {
 			return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, localPrefix+errDeleteCRD)
@@ -295,13 +443,50 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 		return reconcile.Result{RequeueAfter: tinyWait}, errors.Wrap(r.local.Status().Update(ctx, xrd), localPrefix+errUpdateStatus)
 	}
 
+	claimOpts := append([]claim.ReconcilerOption{
+		claim.WithLogger(log.WithValues("controller", coreclaim.ControllerName(xrd.GetName()))),
+		claim.WithRecorder(r.record.WithAnnotations("controller", coreclaim.ControllerName(xrd.GetName()))),
+	}, r.claimOpts...)
+
+	// The configuration overrides the options we were started with. A running
+	// controller keeps the options it was started with, so we stop it in order
+	// to start it with the new ones if the configuration has changed.
+	if r.config != nil {
+		if v, ok := r.versions[coreclaim.ControllerName(xrd.GetName())]; ok && v != version {
+			log.Debug("Restarting controller with new configuration", "version", version)
+			r.engine.Stop(coreclaim.ControllerName(xrd.GetName()))
+		}
+		r.versions[coreclaim.ControllerName(xrd.GetName())] = version
+		claimOpts = append(claimOpts, configOpts...)
+	}
+
+	// A running controller doesn't notice that the remote kind of its claims
+	// became served; their syncs just keep failing until their backoff is
+	// over. We poll for the kind while it's not served and restart the
+	// controller once it is, which enqueues all of its claims.
+	wait := longWait
+	if r.kinds != nil {
+		served, err := r.kinds.Served(ctx, GroupVersionKindOf(*localCRD))
+		if err != nil {
+			return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, remotePrefix+errDiscoverKind)
+		}
+		switch name := coreclaim.ControllerName(xrd.GetName()); {
+		case !served:
+			r.unserved[name] = true
+			wait = shortWait
+		case r.unserved[name]:
+			log.Debug("Restarting controller since remote kind is served")
+			r.engine.Stop(name)
+			delete(r.unserved, name)
+		}
+	}
+
 	// The new controller for the type is configured with a reconciler and other
 	// parameters that the reconciler requires.
 	o := kcontroller.Options{Reconciler: claim.NewReconciler(r.mgr,
-		r.remote,
+		r.claimRemote,
 		GroupVersionKindOf(*localCRD),
-		claim.WithLogger(log.WithValues("controller", coreclaim.ControllerName(xrd.GetName()))),
-		claim.WithRecorder(r.record.WithAnnotations("controller", coreclaim.ControllerName(xrd.GetName()))),
+		claimOpts...,
 	)}
 
 	// Since we don't have strongly typed structs for the claims, we set the GVK
@@ -312,14 +497,16 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 
 	// We're all set for starting the controller. This assumes that ControllerEngine
 	// Start call is idempotent, hence we don't check whether it was already started
-	// or not.
+	// or not. The claim reconciler reports its result on the status of the claim,
+	// which shouldn't trigger another reconciliation.
 	if err := r.engine.Start(coreclaim.ControllerName(xrd.GetName()), o,
-		controller.For(rq, &handler.EnqueueRequestForObject{}),
+		controller.For(rq, r.claimHandler, resource.NewStatusOnlyUpdateFilter()),
 	); err != nil {
 		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(err, localPrefix+errStartController)
 	}
+	r.managed.Add(coreclaim.ControllerName(xrd.GetName()), GroupVersionKindOf(*localCRD))
 
 	// The reconciliation is completed successfully.
 	xrd.Status.SetConditions(runtimev1alpha1.ReconcileSuccess())
-	return reconcile.Result{RequeueAfter: longWait}, errors.Wrap(r.local.Status().Update(ctx, xrd), localPrefix+errUpdateStatus)
+	return reconcile.Result{RequeueAfter: wait}, errors.Wrap(r.local.Status().Update(ctx, xrd), localPrefix+errUpdateStatus)
 }
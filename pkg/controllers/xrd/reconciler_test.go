@@ -26,6 +26,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	kcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -36,6 +37,8 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
+
+	"github.com/crossplane/agent/pkg/controllers/claim"
 )
 
 var (
@@ -521,6 +524,43 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{RequeueAfter: shortWait},
 			},
 		},
+		"LoadConfigFailed": {
+			reason: "The error should be returned if the configuration cannot be loaded",
+			args: args{
+				m: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+					},
+				},
+				opts: []ReconcilerOption{
+					WithLocalApplicator(resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+						return nil
+					})),
+					WithFinalizer(resource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ resource.Object) error {
+						return nil
+					}}),
+					WithCRDFetcher(FetchFn(func(_ context.Context, _ v1alpha1.CompositeResourceDefinition) (*apiextensions.CustomResourceDefinition, error) {
+						return &apiextensions.CustomResourceDefinition{
+							Status: apiextensions.CustomResourceDefinitionStatus{
+								Conditions: []apiextensions.CustomResourceDefinitionCondition{
+									{
+										Type:   apiextensions.Established,
+										Status: apiextensions.ConditionTrue,
+									},
+								},
+							},
+						}, nil
+					})),
+					WithConfigLoader(ConfigLoaderFn(func(_ context.Context) ([]claim.ReconcilerOption, string, error) {
+						return nil, "", errBoom
+					})),
+				},
+			},
+			want: want{
+				err:    errors.Wrap(errBoom, localPrefix+errLoadConfig),
+				result: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
 		"Successful": {
 			reason: "No error should be returned if all calls go well",
 			args: args{
@@ -574,3 +614,218 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileConfigChanged(t *testing.T) {
+	version := "1"
+	stopped := 0
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet:          test.NewMockGetFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	r := NewReconciler(m, nil,
+		WithLocalApplicator(resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+			return nil
+		})),
+		WithFinalizer(resource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ resource.Object) error {
+			return nil
+		}}),
+		WithCRDFetcher(FetchFn(func(_ context.Context, _ v1alpha1.CompositeResourceDefinition) (*apiextensions.CustomResourceDefinition, error) {
+			return &apiextensions.CustomResourceDefinition{
+				Status: apiextensions.CustomResourceDefinitionStatus{
+					Conditions: []apiextensions.CustomResourceDefinitionCondition{
+						{
+							Type:   apiextensions.Established,
+							Status: apiextensions.ConditionTrue,
+						},
+					},
+				},
+			}, nil
+		})),
+		WithConfigLoader(ConfigLoaderFn(func(_ context.Context) ([]claim.ReconcilerOption, string, error) {
+			return nil, version, nil
+		})),
+		WithControllerEngine(&MockEngine{
+			MockStart: func(_ string, _ kcontroller.Options, _ ...controller.Watch) error { return nil },
+			MockStop:  func(_ string) { stopped++ },
+		}),
+	)
+
+	steps := []struct {
+		reason  string
+		version string
+		stopped int
+	}{
+		{reason: "The controller should not be stopped when it is first started", version: "1", stopped: 0},
+		{reason: "The controller should not be stopped when the configuration is unchanged", version: "1", stopped: 0},
+		{reason: "The controller should be restarted when the configuration changes", version: "2", stopped: 1},
+	}
+	for _, s := range steps {
+		version = s.version
+		if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+			t.Fatalf("\nReason: %s\nr.Reconcile(...): %s", s.reason, err)
+		}
+		if diff := cmp.Diff(s.stopped, stopped); diff != "" {
+			t.Errorf("\nReason: %s\nr.Reconcile(...): -want stops, +got stops:\n%s", s.reason, diff)
+		}
+	}
+}
+
+func TestReconcileManagedKinds(t *testing.T) {
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet:          test.NewMockGetFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	managed := claim.NewManagedKinds()
+	r := NewReconciler(m, nil,
+		WithLocalApplicator(resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+			return nil
+		})),
+		WithFinalizer(resource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ resource.Object) error {
+			return nil
+		}}),
+		WithCRDFetcher(FetchFn(func(_ context.Context, _ v1alpha1.CompositeResourceDefinition) (*apiextensions.CustomResourceDefinition, error) {
+			return &apiextensions.CustomResourceDefinition{
+				Spec: apiextensions.CustomResourceDefinitionSpec{
+					Group:   "example.org",
+					Names:   apiextensions.CustomResourceDefinitionNames{Kind: "Database"},
+					Version: "v1",
+				},
+				Status: apiextensions.CustomResourceDefinitionStatus{
+					Conditions: []apiextensions.CustomResourceDefinitionCondition{
+						{
+							Type:   apiextensions.Established,
+							Status: apiextensions.ConditionTrue,
+						},
+					},
+				},
+			}, nil
+		})),
+		WithControllerEngine(&MockEngine{
+			MockStart: func(_ string, _ kcontroller.Options, _ ...controller.Watch) error { return nil },
+		}),
+		WithManagedKinds(managed),
+	)
+	if _, err := r.Reconcile(reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): %s", err)
+	}
+	gvk := schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Database"}
+	if diff := cmp.Diff(true, managed.Has(gvk)); diff != "" {
+		t.Errorf("\nReason: %s\nmanaged.Has(...): -want, +got:\n%s", "The kind of the claims of a started controller should be managed", diff)
+	}
+}
+
+func TestReconcileRemoteKindServed(t *testing.T) {
+	served := false
+	stopped := 0
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet:          test.NewMockGetFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	r := NewReconciler(m, nil,
+		WithLocalApplicator(resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+			return nil
+		})),
+		WithFinalizer(resource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ resource.Object) error {
+			return nil
+		}}),
+		WithCRDFetcher(FetchFn(func(_ context.Context, _ v1alpha1.CompositeResourceDefinition) (*apiextensions.CustomResourceDefinition, error) {
+			return &apiextensions.CustomResourceDefinition{
+				Status: apiextensions.CustomResourceDefinitionStatus{
+					Conditions: []apiextensions.CustomResourceDefinitionCondition{
+						{
+							Type:   apiextensions.Established,
+							Status: apiextensions.ConditionTrue,
+						},
+					},
+				},
+			}, nil
+		})),
+		WithRemoteKindChecker(KindCheckFn(func(_ context.Context, _ schema.GroupVersionKind) (bool, error) {
+			return served, nil
+		})),
+		WithControllerEngine(&MockEngine{
+			MockStart: func(_ string, _ kcontroller.Options, _ ...controller.Watch) error { return nil },
+			MockStop:  func(_ string) { stopped++ },
+		}),
+	)
+
+	steps := []struct {
+		reason  string
+		served  bool
+		result  reconcile.Result
+		stopped int
+	}{
+		{reason: "The kind should be polled for while it's not served", served: false, result: reconcile.Result{RequeueAfter: shortWait}, stopped: 0},
+		{reason: "The controller should not be restarted while the kind is not served", served: false, result: reconcile.Result{RequeueAfter: shortWait}, stopped: 0},
+		{reason: "The controller should be restarted to enqueue its claims once the kind is served", served: true, result: reconcile.Result{RequeueAfter: longWait}, stopped: 1},
+		{reason: "The controller should not be restarted again while the kind stays served", served: true, result: reconcile.Result{RequeueAfter: longWait}, stopped: 1},
+	}
+	for _, s := range steps {
+		served = s.served
+		got, err := r.Reconcile(reconcile.Request{})
+		if err != nil {
+			t.Fatalf("\nReason: %s\nr.Reconcile(...): %s", s.reason, err)
+		}
+		if diff := cmp.Diff(s.result, got); diff != "" {
+			t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", s.reason, diff)
+		}
+		if diff := cmp.Diff(s.stopped, stopped); diff != "" {
+			t.Errorf("\nReason: %s\nr.Reconcile(...): -want stops, +got stops:\n%s", s.reason, diff)
+		}
+	}
+}
+
+func TestReconcileMaintenance(t *testing.T) {
+	reason := "Nothing should be written while the maintenance switch is enabled, even by the configuration that enabled it"
+	maintenance := claim.NewMaintenanceSwitch(false)
+	var written []string
+	m := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil),
+			MockStatusUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+				written = append(written, "status")
+				return nil
+			},
+		},
+	}
+	r := NewReconciler(m, nil,
+		WithLocalApplicator(resource.ApplyFn(func(_ context.Context, _ runtime.Object, _ ...resource.ApplyOption) error {
+			written = append(written, "crd")
+			return nil
+		})),
+		WithFinalizer(resource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ resource.Object) error {
+			written = append(written, "finalizer")
+			return nil
+		}}),
+		WithCRDFetcher(FetchFn(func(_ context.Context, _ v1alpha1.CompositeResourceDefinition) (*apiextensions.CustomResourceDefinition, error) {
+			return &apiextensions.CustomResourceDefinition{}, nil
+		})),
+		WithConfigLoader(ConfigLoaderFn(func(_ context.Context) ([]claim.ReconcilerOption, string, error) {
+			maintenance.Set(true)
+			return nil, "1", nil
+		})),
+		WithMaintenanceSwitch(maintenance),
+		WithControllerEngine(&MockEngine{
+			MockStart: func(_ string, _ kcontroller.Options, _ ...controller.Watch) error {
+				written = append(written, "controller")
+				return nil
+			},
+		}),
+	)
+	got, err := r.Reconcile(reconcile.Request{})
+	if err != nil {
+		t.Fatalf("\nReason: %s\nr.Reconcile(...): %s", reason, err)
+	}
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: longWait}, got); diff != "" {
+		t.Errorf("\nReason: %s\nr.Reconcile(...): -want, +got:\n%s", reason, diff)
+	}
+	if len(written) > 0 {
+		t.Errorf("\nReason: %s\nunexpected writes: %v", reason, written)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	errPoolTransport = "cannot limit the connections of a custom transport"
+	errPoolLimits    = "maximum number of connections cannot be negative"
+
+	// defaultMaxIdle is the number of idle connections client-go keeps open
+	// per API server by default.
+	defaultMaxIdle = 25
+)
+
+// Pooled returns a copy of the supplied config whose clients share one pool of
+// connections to the API server, so that every client built from it reuses the
+// open connections rather than opening its own. At most maxOpen connections are
+// open at a time, and the requests beyond that wait for one of them to be free.
+// At most maxIdle of them are kept open while idle, and idle ones are closed
+// after idleTimeout. Zero means no limit for maxOpen and the defaults of
+// client-go for maxIdle and idleTimeout. Configs are returned unchanged if no
+// limit is given.
+//
+// The pool is built by wrapping the transport client-go builds for the config,
+// so the TLS options and the exec plugins of the config keep working. Pooled
+// must be applied after Rotating so that the pool uses the reloaded client
+// certificate.
+func Pooled(cfg *rest.Config, maxIdle, maxOpen int, idleTimeout time.Duration) (*rest.Config, error) {
+	if maxIdle < 0 || maxOpen < 0 {
+		return nil, errors.New(errPoolLimits)
+	}
+	if maxIdle == 0 && maxOpen == 0 && idleTimeout == 0 {
+		return cfg, nil
+	}
+	if _, ok := cfg.Transport.(*http.Transport); cfg.Transport != nil && !ok {
+		return nil, errors.New(errPoolTransport)
+	}
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdle
+	}
+	p := &pool{maxIdle: maxIdle, maxOpen: maxOpen, idleTimeout: idleTimeout, tuned: map[*http.Transport]*http.Transport{}}
+	out := rest.CopyConfig(cfg)
+	out.Wrap(p.wrap)
+	return out, nil
+}
+
+// A pool tunes the transports client-go builds for a config. Every client of
+// the config gets the same tuned transport, and thus the same connections.
+type pool struct {
+	maxIdle     int
+	maxOpen     int
+	idleTimeout time.Duration
+
+	mu    sync.Mutex
+	tuned map[*http.Transport]*http.Transport
+}
+
+// wrap returns the tuned copy of the supplied transport. Transports that are
+// not *http.Transport are returned as they are since their connections cannot
+// be limited, unless they only add the token of Rotating to the requests.
+func (p *pool) wrap(rt http.RoundTripper) http.RoundTripper {
+	switch t := rt.(type) {
+	case *tokenRoundTripper:
+		return &tokenRoundTripper{token: t.token, rt: p.wrap(t.rt)}
+	case *http.Transport:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if tt, ok := p.tuned[t]; ok {
+			return tt
+		}
+		tt := rebuild(t, func(t *http.Transport) {
+			t.MaxIdleConns = p.maxIdle
+			t.MaxIdleConnsPerHost = p.maxIdle
+			t.MaxConnsPerHost = p.maxOpen
+			t.IdleConnTimeout = p.idleTimeout
+		})
+		p.tuned[t] = tt
+		return tt
+	}
+	return rt
+}
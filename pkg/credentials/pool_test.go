@@ -0,0 +1,348 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// connCounter counts the connections a test server accepts and closes.
+type connCounter struct {
+	mu     sync.Mutex
+	opened int
+	closed int
+}
+
+func (c *connCounter) track(_ net.Conn, s http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch s {
+	case http.StateNew:
+		c.opened++
+	case http.StateClosed, http.StateHijacked:
+		c.closed++
+	}
+}
+
+func (c *connCounter) counts() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opened, c.closed
+}
+
+// newPoolServer starts a TLS server that serves the supplied handler, and
+// returns a config to reach it and the counter of its connections.
+func newPoolServer(t *testing.T, h http.HandlerFunc) (*httptest.Server, *rest.Config, *connCounter) {
+	t.Helper()
+	cc := &connCounter{}
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.ConnState = cc.track
+	srv.StartTLS()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	return srv, &rest.Config{Host: srv.URL, TLSClientConfig: rest.TLSClientConfig{CAData: ca}}, cc
+}
+
+// get sends a request with a client built from the supplied config, the way
+// every client of the agent builds its own transport.
+func get(t *testing.T, cfg *rest.Config, url string) {
+	t.Helper()
+	rt, err := rest.TransportFor(cfg)
+	if err != nil {
+		t.Errorf("rest.TransportFor(...): %s", err)
+		return
+	}
+	resp, err := (&http.Client{Transport: rt}).Get(url)
+	if err != nil {
+		t.Errorf("c.Get(...): %s", err)
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+// eventually waits for the supplied condition to hold, since the server sees
+// connections being closed only after the client closed them.
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func TestPooledReuse(t *testing.T) {
+	srv, cfg, cc := newPoolServer(t, func(w http.ResponseWriter, _ *http.Request) {})
+	defer srv.Close()
+
+	pooled, err := Pooled(cfg, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Pooled(...): unexpected error: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		get(t, pooled, srv.URL)
+	}
+	if opened, _ := cc.counts(); opened != 1 {
+		t.Errorf("\nReason: %s\nopened connections: -want, +got:\n%s", "The clients built from the config should reuse the same connection", cmp.Diff(1, opened))
+	}
+}
+
+func TestPooledIdleTimeout(t *testing.T) {
+	srv, cfg, cc := newPoolServer(t, func(w http.ResponseWriter, _ *http.Request) {})
+	defer srv.Close()
+
+	pooled, err := Pooled(cfg, 0, 0, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Pooled(...): unexpected error: %s", err)
+	}
+	get(t, pooled, srv.URL)
+	if !eventually(func() bool { _, closed := cc.counts(); return closed == 1 }) {
+		_, closed := cc.counts()
+		t.Errorf("\nReason: %s\nclosed connections: -want, +got:\n%s", "The idle connection should be closed after the idle timeout", cmp.Diff(1, closed))
+	}
+	get(t, pooled, srv.URL)
+	if opened, _ := cc.counts(); opened != 2 {
+		t.Errorf("\nReason: %s\nopened connections: -want, +got:\n%s", "A new connection should be opened once the idle one is closed", cmp.Diff(2, opened))
+	}
+}
+
+func TestPooledLimits(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		active  int
+		maxSeen int
+	)
+	release := make(chan struct{})
+	srv, cfg, cc := newPoolServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		active++
+		if active > maxSeen {
+			maxSeen = active
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		active--
+		mu.Unlock()
+	})
+	defer srv.Close()
+
+	pooled, err := Pooled(cfg, 1, 2, 0)
+	if err != nil {
+		t.Fatalf("Pooled(...): unexpected error: %s", err)
+	}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, pooled, srv.URL)
+		}()
+	}
+	// Give the requests time to queue up behind the open connections.
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	opened, _ := cc.counts()
+	if diff := cmp.Diff(2, opened); diff != "" {
+		t.Errorf("\nReason: %s\nopened connections: -want, +got:\n%s", "No more than the maximum number of connections should be opened", diff)
+	}
+	if diff := cmp.Diff(2, maxSeen); diff != "" {
+		t.Errorf("\nReason: %s\nconcurrent requests: -want, +got:\n%s", "The requests beyond the open connections should wait for one to be free", diff)
+	}
+	if !eventually(func() bool { _, closed := cc.counts(); return closed == 1 }) {
+		_, closed := cc.counts()
+		t.Errorf("\nReason: %s\nclosed connections: -want, +got:\n%s", "The connections beyond the maximum number of idle ones should be closed", cmp.Diff(1, closed))
+	}
+}
+
+func TestPooledValidation(t *testing.T) {
+	type args struct {
+		cfg     *rest.Config
+		maxIdle int
+		maxOpen int
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		err bool
+	}{
+		"Defaults": {
+			reason: "A config without limits should be accepted",
+			args: args{
+				cfg: &rest.Config{Host: "https://10.0.0.1:6443"},
+			},
+		},
+		"RotatingTransport": {
+			reason: "A config whose client certificate is rotated should be pooled",
+			args: args{
+				cfg:     &rest.Config{Host: "https://10.0.0.1:6443", Transport: &http.Transport{}},
+				maxIdle: 5,
+				maxOpen: 10,
+			},
+		},
+		"NegativeLimit": {
+			reason: "A negative limit should be rejected",
+			args: args{
+				cfg:     &rest.Config{Host: "https://10.0.0.1:6443"},
+				maxOpen: -1,
+			},
+			err: true,
+		},
+		"CustomTransport": {
+			reason: "A config with a transport whose connections cannot be limited should be rejected",
+			args: args{
+				cfg:     &rest.Config{Host: "https://10.0.0.1:6443", Transport: &hostRoundTripper{rt: http.DefaultTransport}},
+				maxOpen: 10,
+			},
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Pooled(tc.args.cfg, tc.args.maxIdle, tc.args.maxOpen, 0)
+			if diff := cmp.Diff(tc.err, err != nil); diff != "" {
+				t.Errorf("\nReason: %s\nPooled(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+		})
+	}
+}
+
+func TestPooledUnchanged(t *testing.T) {
+	cfg := &rest.Config{Host: "https://10.0.0.1:6443", TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca")}}
+	got, err := Pooled(cfg, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Pooled(...): unexpected error: %s", err)
+	}
+	if got != cfg {
+		t.Errorf("\nReason: %s\nPooled(...): config should be returned unchanged", "A config should not be pooled if no limit is given")
+	}
+}
+
+func TestPooledCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "agent", time.Now())
+	certData, _ := ioutil.ReadFile(certFile)
+	keyData, _ := ioutil.ReadFile(keyFile)
+	tokenFile := filepath.Join(dir, "token")
+	writeToken(t, tokenFile, "agent", time.Now())
+
+	cases := map[string]struct {
+		reason string
+		cfg    func(base *rest.Config) *rest.Config
+		rotate bool
+	}{
+		"ClientCertificate": {
+			reason: "A config with a client certificate should be pooled",
+			cfg: func(base *rest.Config) *rest.Config {
+				base.CertData, base.KeyData = certData, keyData
+				return base
+			},
+		},
+		"RotatingClientCertificate": {
+			reason: "A config whose client certificate files are reloaded should be pooled",
+			cfg: func(base *rest.Config) *rest.Config {
+				base.CertFile, base.KeyFile = certFile, keyFile
+				return base
+			},
+			rotate: true,
+		},
+		"RotatingToken": {
+			reason: "A config whose token file is reloaded should be pooled",
+			cfg: func(base *rest.Config) *rest.Config {
+				base.BearerTokenFile = tokenFile
+				return base
+			},
+			rotate: true,
+		},
+		"ExecPlugin": {
+			reason: "A config whose credentials come from an exec plugin should be pooled",
+			cfg: func(base *rest.Config) *rest.Config {
+				base.ExecProvider = &clientcmdapi.ExecConfig{
+					APIVersion: "client.authentication.k8s.io/v1beta1",
+					Command:    "sh",
+					Args:       []string{"-c", `echo '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"agent"}}'`},
+				}
+				return base
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cc := &connCounter{}
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(r.TLS.PeerCertificates) == 0 && r.Header.Get("Authorization") != "Bearer agent" {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert} // nolint:gosec
+			srv.Config.ConnState = cc.track
+			srv.StartTLS()
+			defer srv.Close()
+			ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+			cfg := tc.cfg(&rest.Config{Host: srv.URL, TLSClientConfig: rest.TLSClientConfig{CAData: ca}})
+			if tc.rotate {
+				if cfg, err = Rotating(cfg); err != nil {
+					t.Fatalf("Rotating(...): unexpected error: %s", err)
+				}
+			}
+			pooled, err := Pooled(cfg, 1, 2, 0)
+			if err != nil {
+				t.Fatalf("Pooled(...): unexpected error: %s", err)
+			}
+			for i := 0; i < 3; i++ {
+				rt, err := rest.TransportFor(pooled)
+				if err != nil {
+					t.Fatalf("\nReason: %s\nrest.TransportFor(...): unexpected error: %s", tc.reason, err)
+				}
+				resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+				if err != nil {
+					t.Fatalf("c.Get(...): %s", err)
+				}
+				_ = resp.Body.Close()
+				if diff := cmp.Diff(http.StatusOK, resp.StatusCode); diff != "" {
+					t.Errorf("\nReason: %s\nstatus: -want, +got:\n%s", tc.reason, diff)
+				}
+			}
+			if opened, _ := cc.counts(); opened != 1 {
+				t.Errorf("\nReason: %s\nopened connections: -want, +got:\n%s", "The clients built from the config should reuse the same connection", cmp.Diff(1, opened))
+			}
+		})
+	}
+}
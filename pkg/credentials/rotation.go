@@ -0,0 +1,238 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package credentials makes the clients of the agent pick up rotated
+// credentials without a restart, reach remote API servers that sit behind a
+// shared ingress, and share a bounded pool of connections to them.
+package credentials
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	errStatCert    = "cannot stat client certificate files"
+	errLoadKeyPair = "cannot load client certificate and key"
+	errStatToken   = "cannot stat token file"
+	errReadToken   = "cannot read token file"
+)
+
+// Rotating returns a copy of the supplied config whose client certificate and
+// bearer token are re-read from their files whenever they change, so that the
+// requests use the rotated credentials without the clients being rebuilt. The
+// new certificate is presented by the new connections. Exec plugins are re-run
+// by client-go once their credentials expire or are rejected, so configs that
+// use them are returned unchanged, as are configs that do not refer to files.
+//
+// The reloading is done by wrapping the transport client-go builds for the
+// config, so the TLS options of the config keep working.
+func Rotating(cfg *rest.Config) (*rest.Config, error) {
+	certs := cfg.CertFile != "" && cfg.KeyFile != "" && len(cfg.CertData) == 0 && len(cfg.KeyData) == 0
+	token := cfg.BearerTokenFile != "" && cfg.BearerToken == ""
+	if cfg.ExecProvider != nil || (!certs && !token) {
+		return cfg, nil
+	}
+	out := rest.CopyConfig(cfg)
+	if certs {
+		r := &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile, reloaded: map[*http.Transport]*http.Transport{}}
+		out.Wrap(r.wrap)
+	}
+	if token {
+		// client-go re-reads token files only every minute, so we take over.
+		t := &tokenReloader{file: cfg.BearerTokenFile}
+		out.BearerTokenFile = ""
+		out.Wrap(t.wrap)
+	}
+	return out, nil
+}
+
+// rebuild returns a new transport with the options of the supplied one whose
+// TLS config is a copy that the supplied function may change. We build a new
+// transport rather than cloning the supplied one, whose HTTP/2 connections
+// would otherwise still be pooled by it.
+func rebuild(t *http.Transport, tune func(t *http.Transport)) *http.Transport {
+	var tc *tls.Config
+	if t.TLSClientConfig != nil {
+		tc = t.TLSClientConfig.Clone()
+	}
+	out := &http.Transport{
+		Proxy:               t.Proxy,
+		DialContext:         t.DialContext,
+		TLSHandshakeTimeout: t.TLSHandshakeTimeout,
+		TLSClientConfig:     tc,
+		DisableCompression:  t.DisableCompression,
+		MaxIdleConns:        t.MaxIdleConns,
+		MaxIdleConnsPerHost: t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     t.MaxConnsPerHost,
+		IdleConnTimeout:     t.IdleConnTimeout,
+	}
+	tune(out)
+	return utilnet.SetTransportDefaults(out)
+}
+
+// certReloader loads the client certificate from its files and reloads it when
+// any of them is modified.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certMod  time.Time
+	keyMod   time.Time
+	reloaded map[*http.Transport]*http.Transport
+}
+
+// wrap returns the copy of the supplied transport that presents the current
+// client certificate. Every client of the config gets the same copy, so that
+// they share its connections. Transports that are not *http.Transport are
+// returned as they are.
+func (r *certReloader) wrap(rt http.RoundTripper) http.RoundTripper {
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rt, ok := r.reloaded[t]; ok {
+		return rt
+	}
+	out := rebuild(t, func(t *http.Transport) {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{} // nolint:gosec // Only the client certificate is set.
+		}
+		t.TLSClientConfig.Certificates = nil
+		t.TLSClientConfig.GetClientCertificate = r.GetClientCertificate
+	})
+	r.reloaded[t] = out
+	return out
+}
+
+// GetClientCertificate returns the current client certificate.
+func (r *certReloader) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return r.fallback(errors.Wrap(err, errStatCert))
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return r.fallback(errors.Wrap(err, errStatCert))
+	}
+	if r.cert != nil && ci.ModTime().Equal(r.certMod) && ki.ModTime().Equal(r.keyMod) {
+		return r.cert, nil
+	}
+	c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		// The files may be in the middle of being rotated.
+		return r.fallback(errors.Wrap(err, errLoadKeyPair))
+	}
+	r.cert, r.certMod, r.keyMod = &c, ci.ModTime(), ki.ModTime()
+	return r.cert, nil
+}
+
+// fallback returns the previously loaded certificate if there is one, and the
+// supplied error otherwise.
+func (r *certReloader) fallback(err error) (*tls.Certificate, error) {
+	if r.cert != nil {
+		return r.cert, nil
+	}
+	return nil, err
+}
+
+// tokenReloader loads the bearer token from its file and reloads it when the
+// file is modified.
+type tokenReloader struct {
+	file string
+
+	mu    sync.Mutex
+	token string
+	mod   time.Time
+}
+
+// wrap returns a round tripper that authenticates the requests sent through
+// the supplied one with the current token.
+func (r *tokenReloader) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &tokenRoundTripper{token: r, rt: rt}
+}
+
+// Token returns the current token.
+func (r *tokenReloader) Token() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fi, err := os.Stat(r.file)
+	if err != nil {
+		return r.fallback(errors.Wrap(err, errStatToken))
+	}
+	if r.token != "" && fi.ModTime().Equal(r.mod) {
+		return r.token, nil
+	}
+	b, err := ioutil.ReadFile(r.file)
+	if err != nil {
+		return r.fallback(errors.Wrap(err, errReadToken))
+	}
+	t := strings.TrimSpace(string(b))
+	if t == "" {
+		// The file may be in the middle of being rotated.
+		return r.fallback(errors.New(errReadToken))
+	}
+	r.token, r.mod = t, fi.ModTime()
+	return r.token, nil
+}
+
+// fallback returns the previously loaded token if there is one, and the
+// supplied error otherwise.
+func (r *tokenReloader) fallback(err error) (string, error) {
+	if r.token != "" {
+		return r.token, nil
+	}
+	return "", err
+}
+
+// A tokenRoundTripper authenticates the requests with the current token of a
+// tokenReloader unless they're already authenticated.
+type tokenRoundTripper struct {
+	token *tokenReloader
+	rt    http.RoundTripper
+}
+
+// RoundTrip sends the supplied request with the current token.
+func (t *tokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.rt.RoundTrip(req)
+	}
+	token, err := t.token.Token()
+	if err != nil {
+		return nil, err
+	}
+	req = utilnet.CloneRequest(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.rt.RoundTrip(req)
+}
+
+// WrappedRoundTripper returns the round tripper the requests are sent through.
+func (t *tokenRoundTripper) WrappedRoundTripper() http.RoundTripper { return t.rt }
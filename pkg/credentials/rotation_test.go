@@ -0,0 +1,264 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func writeCert(t *testing.T, certFile, keyFile, cn string, mod time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRotating(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert} // nolint:gosec
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	now := time.Now()
+	writeCert(t, certFile, keyFile, "first", now.Add(-time.Minute))
+
+	cfg, err := Rotating(&rest.Config{
+		Host: srv.URL,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: true,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Rotating(...): %s", err)
+	}
+	rt, err := rest.TransportFor(cfg)
+	if err != nil {
+		t.Fatalf("rest.TransportFor(...): %s", err)
+	}
+	c := &http.Client{Transport: rt}
+	get := func() string {
+		t.Helper()
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("c.Get(...): %s", err)
+		}
+		defer resp.Body.Close() // nolint:errcheck
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	if diff := cmp.Diff("first", get()); diff != "" {
+		t.Errorf("\nReason: %s\nc.Get(...): -want, +got:\n%s", "The initial certificate should be used", diff)
+	}
+
+	writeCert(t, certFile, keyFile, "second", now)
+	// New connections are needed for the new certificate to be presented.
+	srv.CloseClientConnections()
+
+	if diff := cmp.Diff("second", get()); diff != "" {
+		t.Errorf("\nReason: %s\nc.Get(...): -want, +got:\n%s", "The rotated certificate should be used without rebuilding the client", diff)
+	}
+}
+
+func TestRotatingUnchanged(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		cfg    *rest.Config
+	}{
+		"ExecPlugin": {
+			reason: "Configs using exec plugins should be returned as is since client-go re-runs them",
+			cfg: &rest.Config{Host: "https://remote", ExecProvider: &clientcmdapi.ExecConfig{
+				APIVersion: "client.authentication.k8s.io/v1beta1",
+				Command:    "get-token",
+			}},
+		},
+		"InlineToken": {
+			reason: "Configs with inline tokens should be returned as is",
+			cfg:    &rest.Config{Host: "https://remote", BearerToken: "token"},
+		},
+		"CertData": {
+			reason: "Configs with inline client certificates should be returned as is",
+			cfg:    &rest.Config{Host: "https://remote", TLSClientConfig: rest.TLSClientConfig{CertData: []byte("c"), KeyData: []byte("k")}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Rotating(tc.cfg)
+			if err != nil {
+				t.Errorf("\nReason: %s\nRotating(...): unexpected error: %s", tc.reason, err)
+			}
+			if got != tc.cfg {
+				t.Errorf("\nReason: %s\nRotating(...): config should be returned unchanged", tc.reason)
+			}
+		})
+	}
+}
+
+// authServer starts a TLS server that accepts the requests authenticated with
+// whatever token is in the supplied file, and echoes the token.
+func authServer(t *testing.T, tokenFile string) (*httptest.Server, *rest.Config) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadFile(tokenFile)
+		if r.Header.Get("Authorization") != "Bearer "+string(b) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	return srv, &rest.Config{Host: srv.URL, TLSClientConfig: rest.TLSClientConfig{CAData: ca}}
+}
+
+func writeToken(t *testing.T, file, token string, mod time.Time) {
+	t.Helper()
+	if err := ioutil.WriteFile(file, []byte(token), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotatingCredentials(t *testing.T) {
+	type want struct {
+		status []int
+	}
+	cases := map[string]struct {
+		reason string
+		cfg    func(cfg *rest.Config, tokenFile string) *rest.Config
+		want
+	}{
+		"TokenFile": {
+			reason: "The rotated token should be read from its file for the next request",
+			cfg: func(cfg *rest.Config, tokenFile string) *rest.Config {
+				cfg.BearerTokenFile = tokenFile
+				return cfg
+			},
+			want: want{status: []int{http.StatusOK, http.StatusOK}},
+		},
+		"ExecPlugin": {
+			reason: "The exec plugin should be re-run once the rotated token is rejected",
+			cfg: func(cfg *rest.Config, tokenFile string) *rest.Config {
+				cfg.ExecProvider = &clientcmdapi.ExecConfig{
+					APIVersion: "client.authentication.k8s.io/v1beta1",
+					Command:    "sh",
+					Args:       []string{"-c", `printf '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"%s"}}' "$(cat ` + tokenFile + `)"`},
+				}
+				return cfg
+			},
+			want: want{status: []int{http.StatusOK, http.StatusUnauthorized, http.StatusOK}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "credentials")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint:errcheck
+			tokenFile := filepath.Join(dir, "token")
+			now := time.Now()
+			writeToken(t, tokenFile, "first", now.Add(-time.Minute))
+
+			srv, base := authServer(t, tokenFile)
+			defer srv.Close()
+			cfg, err := Rotating(tc.cfg(base, tokenFile))
+			if err != nil {
+				t.Fatalf("Rotating(...): %s", err)
+			}
+			if cfg, err = Pooled(cfg, 1, 1, 0); err != nil {
+				t.Fatalf("Pooled(...): %s", err)
+			}
+			rt, err := rest.TransportFor(cfg)
+			if err != nil {
+				t.Fatalf("rest.TransportFor(...): %s", err)
+			}
+			c := &http.Client{Transport: rt}
+			var got []int
+			for i := 0; i < len(tc.want.status); i++ {
+				if i == 1 {
+					writeToken(t, tokenFile, "second", now)
+				}
+				resp, err := c.Get(srv.URL)
+				if err != nil {
+					t.Fatalf("c.Get(...): %s", err)
+				}
+				_ = resp.Body.Close()
+				got = append(got, resp.StatusCode)
+			}
+			if diff := cmp.Diff(tc.want.status, got); diff != "" {
+				t.Errorf("\nReason: %s\nstatus: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	errParseHost        = "cannot parse API server URL"
+	errServerNameFmt    = "invalid TLS server name %q: %s"
+	errServerNameNoTLS  = "TLS server name cannot be used with an API server that is not served over HTTPS"
+	errHostOverrideFmt  = "invalid host override %q: %s"
+	errHostOverridePort = "invalid port"
+)
+
+// OverrideServer returns a copy of the supplied config whose connections
+// present the given TLS server name, both for SNI and for verifying the
+// certificate of the API server, and whose requests carry the given Host
+// header. This lets the agent reach an API server that sits behind a shared
+// ingress routing on either of them. Empty values are not overridden, and the
+// config is returned unchanged if neither is given.
+//
+// The overrides must be applied before Rotating, which replaces the TLS options
+// of the config with a transport built from them.
+func OverrideServer(cfg *rest.Config, serverName, host string) (*rest.Config, error) {
+	if serverName == "" && host == "" {
+		return cfg, nil
+	}
+	if err := validateServerName(cfg, serverName); err != nil {
+		return nil, err
+	}
+	if err := validateHost(host); err != nil {
+		return nil, err
+	}
+	out := rest.CopyConfig(cfg)
+	if serverName != "" {
+		out.ServerName = serverName
+	}
+	if host != "" {
+		out.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &hostRoundTripper{host: host, rt: rt}
+		})
+	}
+	return out, nil
+}
+
+func validateServerName(cfg *rest.Config, serverName string) error {
+	if serverName == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(serverName); len(errs) > 0 {
+		return errors.Errorf(errServerNameFmt, serverName, strings.Join(errs, ", "))
+	}
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return errors.Wrap(err, errParseHost)
+	}
+	if u.Scheme == "http" {
+		return errors.New(errServerNameNoTLS)
+	}
+	return nil
+}
+
+func validateHost(host string) error {
+	if host == "" {
+		return nil
+	}
+	name := host
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || len(validation.IsValidPortNum(n)) > 0 {
+			return errors.Errorf(errHostOverrideFmt, host, errHostOverridePort)
+		}
+		name = h
+	}
+	if net.ParseIP(name) != nil {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return errors.Errorf(errHostOverrideFmt, host, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// hostRoundTripper sets the Host header of every request it sends.
+type hostRoundTripper struct {
+	host string
+	rt   http.RoundTripper
+}
+
+// RoundTrip sends a copy of the supplied request with the configured Host.
+func (h *hostRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Host = h.host
+	return h.rt.RoundTrip(r)
+}
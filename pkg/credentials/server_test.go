@@ -0,0 +1,178 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/client-go/rest"
+)
+
+func TestOverrideServer(t *testing.T) {
+	// The certificate of the test server is valid for example.com.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.ServerName + " " + r.Host))
+	}))
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	type args struct {
+		serverName string
+		host       string
+	}
+	type want struct {
+		body string
+		err  bool
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"ServerNameAndHost": {
+			reason: "The connections should present the server name and the requests should carry the host",
+			args: args{
+				serverName: "example.com",
+				host:       "remote.example.org",
+			},
+			want: want{
+				body: "example.com remote.example.org",
+			},
+		},
+		"ServerNameOnly": {
+			reason: "The requests should carry the host of the API server URL if no host is given",
+			args: args{
+				serverName: "example.com",
+			},
+			want: want{
+				body: "example.com " + srv.Listener.Addr().String(),
+			},
+		},
+		"ServerNameVerified": {
+			reason: "The certificate of the API server should be verified against the server name",
+			args: args{
+				serverName: "elsewhere.example.org",
+			},
+			want: want{
+				err: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := OverrideServer(&rest.Config{Host: srv.URL, TLSClientConfig: rest.TLSClientConfig{CAData: ca}}, tc.args.serverName, tc.args.host)
+			if err != nil {
+				t.Fatalf("\nReason: %s\nOverrideServer(...): unexpected error: %s", tc.reason, err)
+			}
+			rt, err := rest.TransportFor(cfg)
+			if err != nil {
+				t.Fatalf("\nReason: %s\nrest.TransportFor(...): %s", tc.reason, err)
+			}
+			resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Fatalf("\nReason: %s\nc.Get(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close() // nolint:errcheck
+			b, _ := ioutil.ReadAll(resp.Body)
+			if diff := cmp.Diff(tc.want.body, string(b)); diff != "" {
+				t.Errorf("\nReason: %s\nc.Get(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOverrideServerValidation(t *testing.T) {
+	type args struct {
+		cfg        *rest.Config
+		serverName string
+		host       string
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		err bool
+	}{
+		"Unchanged": {
+			reason: "A config without overrides should be valid",
+			args: args{
+				cfg: &rest.Config{Host: "http://remote"},
+			},
+		},
+		"HostWithPort": {
+			reason: "A host override may have a port",
+			args: args{
+				cfg:  &rest.Config{Host: "https://10.0.0.1:6443"},
+				host: "remote.example.org:443",
+			},
+		},
+		"HostIP": {
+			reason: "A host override may be an IP address",
+			args: args{
+				cfg:  &rest.Config{Host: "https://10.0.0.1:6443"},
+				host: "10.0.0.2",
+			},
+		},
+		"InvalidServerName": {
+			reason: "A server name that is not a DNS name should be rejected",
+			args: args{
+				cfg:        &rest.Config{Host: "https://10.0.0.1:6443"},
+				serverName: "not_a_name",
+			},
+			err: true,
+		},
+		"ServerNameWithoutTLS": {
+			reason: "A server name should be rejected if the API server is not served over HTTPS",
+			args: args{
+				cfg:        &rest.Config{Host: "http://10.0.0.1:8080"},
+				serverName: "remote.example.org",
+			},
+			err: true,
+		},
+		"InvalidHost": {
+			reason: "A host override that is not a DNS name should be rejected",
+			args: args{
+				cfg:  &rest.Config{Host: "https://10.0.0.1:6443"},
+				host: "https://remote.example.org",
+			},
+			err: true,
+		},
+		"InvalidPort": {
+			reason: "A host override with an invalid port should be rejected",
+			args: args{
+				cfg:  &rest.Config{Host: "https://10.0.0.1:6443"},
+				host: "remote.example.org:99999",
+			},
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := OverrideServer(tc.args.cfg, tc.args.serverName, tc.args.host)
+			if diff := cmp.Diff(tc.err, err != nil); diff != "" {
+				t.Errorf("\nReason: %s\nOverrideServer(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+		})
+	}
+}
@@ -29,8 +29,24 @@ import (
 const (
 	TypeAgentSync v1alpha1.ConditionType = "AgentSynced"
 
-	ReasonAgentSyncSuccess v1alpha1.ConditionReason = "Success"
-	ReasonAgentSyncError   v1alpha1.ConditionReason = "Error"
+	ReasonAgentSyncSuccess     v1alpha1.ConditionReason = "Success"
+	ReasonAgentSyncError       v1alpha1.ConditionReason = "Error"
+	ReasonAgentSyncFilteredOut v1alpha1.ConditionReason = "FilteredOut"
+
+	ReasonAgentSyncMaxRetriesExceeded v1alpha1.ConditionReason = "MaxRetriesExceeded"
+	ReasonAgentSyncDiverged           v1alpha1.ConditionReason = "Diverged"
+	ReasonAgentSyncWaiting            v1alpha1.ConditionReason = "WaitingForDependencies"
+	ReasonAgentSyncDeletionPending    v1alpha1.ConditionReason = "DeletionPending"
+	ReasonAgentSyncMaintenance        v1alpha1.ConditionReason = "Maintenance"
+	ReasonAgentSyncStale              v1alpha1.ConditionReason = "Stale"
+	ReasonAgentSyncThrottled          v1alpha1.ConditionReason = "Throttled"
+	ReasonAgentSyncConflictSkipped    v1alpha1.ConditionReason = "ConflictSkipped"
+	ReasonAgentSyncKindMismatch       v1alpha1.ConditionReason = "KindMismatch"
+
+	TypeConnectionSecretReady v1alpha1.ConditionType = "ConnectionSecretReady"
+
+	ReasonConnectionSecretAvailable   v1alpha1.ConditionReason = "Available"
+	ReasonConnectionSecretUnavailable v1alpha1.ConditionReason = "Unavailable"
 )
 
 // SanitizedDeepCopyObject removes the metadata that can be specific to a cluster.
@@ -70,3 +86,139 @@ func AgentSyncError(err error) v1alpha1.Condition {
 		Message:            err.Error(),
 	}
 }
+
+// AgentSyncFilteredOut returns a condition indicating that Agent skipped syncing
+// the resource because it did not pass the configured filter.
+func AgentSyncFilteredOut() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncFilteredOut,
+	}
+}
+
+// AgentSyncMaxRetriesExceeded returns a condition indicating that Agent stopped
+// retrying to sync the resource after it failed too many times.
+func AgentSyncMaxRetriesExceeded() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncMaxRetriesExceeded,
+	}
+}
+
+// AgentSyncDiverged returns a condition indicating that Agent synced with the
+// remote cluster, but the remote instance was altered after it was written,
+// e.g. by a mutating admission webhook.
+func AgentSyncDiverged() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncDiverged,
+	}
+}
+
+// AgentSyncWaiting returns a condition indicating that Agent did not sync the
+// resource yet because the resources it depends on are not ready.
+func AgentSyncWaiting() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncWaiting,
+	}
+}
+
+// AgentSyncDeletionPending returns a condition indicating that Agent keeps the
+// remote instance of the deleted resource until its deletion grace period is
+// over.
+func AgentSyncDeletionPending() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncDeletionPending,
+	}
+}
+
+// AgentSyncMaintenance returns a condition indicating that Agent does not sync
+// the resource while it's in maintenance mode.
+func AgentSyncMaintenance() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncMaintenance,
+	}
+}
+
+// AgentSyncStale returns a condition indicating that Agent has not synced the
+// resource successfully for too long, so whether it's in sync is unknown.
+func AgentSyncStale() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionUnknown,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncStale,
+	}
+}
+
+// AgentSyncThrottled returns a condition indicating that Agent did not write
+// the remote instance because it was written moments ago, and something keeps
+// reverting it.
+func AgentSyncThrottled() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncThrottled,
+	}
+}
+
+// AgentSyncConflictSkipped returns a condition indicating that Agent did not
+// write the remote instance because the write conflicted with another writer.
+func AgentSyncConflictSkipped() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncConflictSkipped,
+	}
+}
+
+// AgentSyncKindMismatch returns a condition indicating that Agent did not
+// write the remote instance because the remote cluster serves another kind at
+// its location.
+func AgentSyncKindMismatch() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeAgentSync,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAgentSyncKindMismatch,
+	}
+}
+
+// ConnectionSecretAvailable returns a condition indicating that the connection
+// secrets of the resource are propagated with all of their required keys.
+func ConnectionSecretAvailable() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeConnectionSecretReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonConnectionSecretAvailable,
+	}
+}
+
+// ConnectionSecretUnavailable returns a condition indicating that a connection
+// secret of the resource is not propagated yet or lacks some required keys.
+func ConnectionSecretUnavailable() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeConnectionSecretReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonConnectionSecretUnavailable,
+	}
+}
@@ -17,9 +17,11 @@ limitations under the License.
 package resource
 
 import (
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
@@ -47,3 +49,51 @@ func NewXRDWithClaim() predicate.Funcs {
 		return xrd.Spec.ClaimNames != nil
 	})
 }
+
+// NewStatusOnlyUpdateFilter returns a predicate that ignores the updates that
+// change nothing but the status of an object, such as the ones the agent makes
+// when it reports the result of a reconciliation, so that they don't trigger
+// another one. The updates of the spec or the metadata, e.g. the annotations
+// and finalizers, which don't necessarily change the generation, still pass.
+func NewStatusOnlyUpdateFilter() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.MetaOld == nil || e.MetaNew == nil || e.ObjectOld == nil || e.ObjectNew == nil {
+				return true
+			}
+			if e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() {
+				return true
+			}
+			o, err := withoutStatus(e.ObjectOld)
+			if err != nil {
+				return true
+			}
+			n, err := withoutStatus(e.ObjectNew)
+			if err != nil {
+				return true
+			}
+			return !equality.Semantic.DeepEqual(o, n)
+		},
+	}
+}
+
+// withoutStatus returns the content of the supplied object without the status
+// and the metadata that changes with every write.
+func withoutStatus(obj runtime.Object) (map[string]interface{}, error) {
+	var content map[string]interface{}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		content = runtime.DeepCopyJSON(u.UnstructuredContent())
+	} else {
+		c, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		content = c
+	}
+	delete(content, "status")
+	if m, ok := content["metadata"].(map[string]interface{}); ok {
+		delete(m, "resourceVersion")
+		delete(m, "managedFields")
+	}
+	return content, nil
+}
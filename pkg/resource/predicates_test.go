@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestNewStatusOnlyUpdateFilter(t *testing.T) {
+	claim := func(mod func(u *unstructured.Unstructured)) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.org/v1",
+			"kind":       "Database",
+			"metadata": map[string]interface{}{
+				"name":            "cool",
+				"generation":      int64(1),
+				"resourceVersion": "1",
+			},
+			"spec":   map[string]interface{}{"size": "10"},
+			"status": map[string]interface{}{"phase": "Pending"},
+		}}
+		if mod != nil {
+			mod(u)
+		}
+		return u
+	}
+	cases := map[string]struct {
+		reason string
+		old    runtime.Object
+		new    runtime.Object
+		want   bool
+	}{
+		"StatusOnly": {
+			reason: "An update of only the status should be ignored",
+			old:    claim(nil),
+			new: claim(func(u *unstructured.Unstructured) {
+				u.Object["status"] = map[string]interface{}{"phase": "Ready"}
+				u.SetResourceVersion("2")
+				u.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "agent"}})
+			}),
+			want: false,
+		},
+		"SpecChanged": {
+			reason: "An update of the spec should pass",
+			old:    claim(nil),
+			new: claim(func(u *unstructured.Unstructured) {
+				u.Object["spec"] = map[string]interface{}{"size": "20"}
+				u.SetGeneration(2)
+			}),
+			want: true,
+		},
+		"SpecChangedWithoutGeneration": {
+			reason: "An update of the spec should pass even if the generation is not bumped",
+			old:    claim(nil),
+			new: claim(func(u *unstructured.Unstructured) {
+				u.Object["spec"] = map[string]interface{}{"size": "20"}
+			}),
+			want: true,
+		},
+		"AnnotationsChanged": {
+			reason: "An update of the annotations should pass even though it doesn't bump the generation",
+			old:    claim(nil),
+			new: claim(func(u *unstructured.Unstructured) {
+				u.SetAnnotations(map[string]string{"agent.crossplane.io/sync-now": "1"})
+				u.Object["status"] = map[string]interface{}{"phase": "Ready"}
+			}),
+			want: true,
+		},
+		"Deleted": {
+			reason: "An update that marks the object as deleted should pass",
+			old:    claim(nil),
+			new: claim(func(u *unstructured.Unstructured) {
+				now := metav1.Now()
+				u.SetDeletionTimestamp(&now)
+			}),
+			want: true,
+		},
+		"TypedStatusOnly": {
+			reason: "An update of only the status of a typed object should be ignored",
+			old:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cool", ResourceVersion: "1"}},
+			new:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cool", ResourceVersion: "2"}, Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := event.UpdateEvent{
+				MetaOld:   tc.old.(metav1.Object),
+				ObjectOld: tc.old,
+				MetaNew:   tc.new.(metav1.Object),
+				ObjectNew: tc.new,
+			}
+			got := NewStatusOnlyUpdateFilter().Update(e)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nUpdate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
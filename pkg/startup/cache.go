@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package startup
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	errNewMapper = "cannot create REST mapper"
+	errNewCache  = "cannot create cache"
+	errNewClient = "cannot create client"
+)
+
+// newCache is swapped in tests.
+var newCache = cache.New
+
+// NewCacheFunc returns a cache.NewCacheFunc that restricts the cache to the
+// given namespaces. The cache is cluster-wide if no namespace is given.
+func NewCacheFunc(namespaces []string) cache.NewCacheFunc {
+	switch len(namespaces) {
+	case 0:
+		return newCache
+	case 1:
+		return func(cfg *rest.Config, o cache.Options) (cache.Cache, error) {
+			o.Namespace = namespaces[0]
+			return newCache(cfg, o)
+		}
+	default:
+		return cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+}
+
+// NewNamespacedClient returns a client whose typed reads, such as the ones of
+// connection secrets, are served from a cache that watches only the given
+// namespaces. Unstructured reads and all writes go to the API server directly.
+// The returned cache has to be started, e.g. by adding it to a manager.
+func NewNamespacedClient(cfg *rest.Config, s *runtime.Scheme, namespaces []string) (client.Client, cache.Cache, error) {
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errNewMapper)
+	}
+	c, err := NewCacheFunc(namespaces)(cfg, cache.Options{Scheme: s, Mapper: mapper})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errNewCache)
+	}
+	direct, err := client.New(cfg, client.Options{Scheme: s, Mapper: mapper})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errNewClient)
+	}
+	return &client.DelegatingClient{
+		Reader:       &client.DelegatingReader{CacheReader: c, ClientReader: direct},
+		Writer:       direct,
+		StatusClient: direct,
+	}, c, nil
+}
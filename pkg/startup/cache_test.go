@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package startup
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNewCacheFunc(t *testing.T) {
+	cfg := &rest.Config{Host: "https://remote.invalid"}
+	o := cache.Options{Scheme: scheme.Scheme, Mapper: meta.NewDefaultRESTMapper(nil)}
+
+	cases := map[string]struct {
+		reason     string
+		namespaces []string
+		want       string
+	}{
+		"ClusterWide": {
+			reason: "The cache should watch all namespaces if none is configured",
+			want:   "",
+		},
+		"SingleNamespace": {
+			reason:     "The cache should watch only the configured namespace",
+			namespaces: []string{"requirements"},
+			want:       "requirements",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := "unset"
+			newCache = func(cfg *rest.Config, o cache.Options) (cache.Cache, error) {
+				got = o.Namespace
+				return cache.New(cfg, o)
+			}
+			defer func() { newCache = cache.New }()
+
+			if _, err := NewCacheFunc(tc.namespaces)(cfg, o); err != nil {
+				t.Fatalf("NewCacheFunc(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\nReason: %s\nNewCacheFunc(...): -want namespace, +got namespace:\n%s", tc.reason, diff)
+			}
+		})
+	}
+
+	t.Run("MultipleNamespaces", func(t *testing.T) {
+		c, err := NewCacheFunc([]string{"requirements", "secrets"})(cfg, o)
+		if err != nil {
+			t.Fatalf("NewCacheFunc(...): %s", err)
+		}
+		// A namespace that is not in the configured set should be refused
+		// without reaching the API server.
+		err = c.List(context.Background(), &corev1.SecretList{}, client.InNamespace("other"))
+		if err == nil || !strings.Contains(err.Error(), "unknown namespace") {
+			t.Errorf("\nReason: %s\nc.List(...): want unknown namespace error, got: %v", "The cache should be restricted to the configured namespaces", err)
+		}
+	})
+}
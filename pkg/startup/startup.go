@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package startup contains the utilities used while starting the agent.
+package startup
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+const (
+	errCacheSyncTimeoutFmt = "caches did not sync within %s, check the connectivity to the cluster"
+	errCacheSync           = "caches could not be synced"
+	errStartManager        = "cannot start controller manager"
+	errCacheNotSynced      = "caches are not synced yet"
+)
+
+// A Manager can be started and has a cache that needs to be synced.
+type Manager interface {
+	Start(stop <-chan struct{}) error
+	GetCache() cache.Cache
+}
+
+// Start starts the supplied manager and stops it with an error if its caches
+// do not sync within the given timeout. A zero timeout means no timeout. It
+// blocks until the manager stops, which happens when stop is closed.
+func Start(mgr Manager, stop <-chan struct{}, timeout time.Duration) error {
+	stopMgr := make(chan struct{})
+	started := make(chan error, 1)
+	go func() {
+		started <- mgr.Start(stopMgr)
+	}()
+
+	synced := make(chan error, 1)
+	if timeout > 0 {
+		go func() {
+			synced <- WaitForCacheSync(mgr.GetCache(), timeout)
+		}()
+	}
+
+	select {
+	case err := <-synced:
+		if err != nil {
+			close(stopMgr)
+			<-started
+			return err
+		}
+	case err := <-started:
+		return errors.Wrap(err, errStartManager)
+	case <-stop:
+		close(stopMgr)
+		return errors.Wrap(<-started, errStartManager)
+	}
+
+	select {
+	case err := <-started:
+		return errors.Wrap(err, errStartManager)
+	case <-stop:
+		close(stopMgr)
+		return errors.Wrap(<-started, errStartManager)
+	}
+}
+
+// WaitForCacheSync waits for the supplied cache to sync and returns an error if
+// that takes longer than the given timeout.
+func WaitForCacheSync(c cache.Informers, timeout time.Duration) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	done := make(chan bool, 1)
+	go func() {
+		done <- c.WaitForCacheSync(stop)
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			return errors.New(errCacheSync)
+		}
+		return nil
+	case <-time.After(timeout):
+		return errors.Errorf(errCacheSyncTimeoutFmt, timeout)
+	}
+}
+
+// NewCacheSyncChecker returns a *CacheSyncChecker that waits at most the given
+// time for the supplied cache to sync whenever it's asked whether it did.
+func NewCacheSyncChecker(c cache.Informers, wait time.Duration) *CacheSyncChecker {
+	return &CacheSyncChecker{cache: c, wait: wait}
+}
+
+// A CacheSyncChecker reports whether a cache is synced, e.g. to keep from
+// acting on a stale view of a cluster. The informers of a cache stay synced
+// once they are, so it stops checking once the cache synced.
+type CacheSyncChecker struct {
+	cache cache.Informers
+	wait  time.Duration
+
+	// synced is 1 once the cache synced. It's read without waiting for the
+	// callers that are still waiting for the cache to sync.
+	synced int32
+}
+
+// Synced returns whether the cache is synced.
+func (c *CacheSyncChecker) Synced() bool {
+	if atomic.LoadInt32(&c.synced) == 1 {
+		return true
+	}
+	if WaitForCacheSync(c.cache, c.wait) != nil {
+		return false
+	}
+	atomic.StoreInt32(&c.synced, 1)
+	return true
+}
+
+// Check returns an error unless the cache is synced. It's a healthz.Checker,
+// so that the agent isn't reported ready until its cache is synced.
+func (c *CacheSyncChecker) Check(_ *http.Request) error {
+	if !c.Synced() {
+		return errors.New(errCacheNotSynced)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package startup
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+type fakeCache struct {
+	cache.Cache
+
+	mu     sync.Mutex
+	synced bool
+}
+
+func (c *fakeCache) setSynced(synced bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.synced = synced
+}
+
+// WaitForCacheSync never returns true unless the cache is synced, like an
+// informer that can't reach its API server.
+func (c *fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	c.mu.Lock()
+	synced := c.synced
+	c.mu.Unlock()
+	if synced {
+		return true
+	}
+	<-stop
+	return false
+}
+
+type fakeManager struct {
+	cache   *fakeCache
+	stopped bool
+}
+
+func (m *fakeManager) Start(stop <-chan struct{}) error {
+	<-stop
+	m.stopped = true
+	return nil
+}
+
+func (m *fakeManager) GetCache() cache.Cache { return m.cache }
+
+func TestStart(t *testing.T) {
+	type want struct {
+		err     error
+		stopped bool
+	}
+	cases := map[string]struct {
+		reason string
+		synced bool
+		want   want
+	}{
+		"NeverSynced": {
+			reason: "The manager should be stopped with an error if its caches never sync",
+			synced: false,
+			want: want{
+				err:     errors.Errorf(errCacheSyncTimeoutFmt, 10*time.Millisecond),
+				stopped: true,
+			},
+		},
+		"Synced": {
+			reason: "The manager should keep running until stopped if its caches sync",
+			synced: true,
+			want: want{
+				stopped: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mgr := &fakeManager{cache: &fakeCache{synced: tc.synced}}
+			stop := make(chan struct{})
+			if tc.synced {
+				time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+			}
+			err := Start(mgr, stop, 10*time.Millisecond)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nStart(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.stopped, mgr.stopped); diff != "" {
+				t.Errorf("\nReason: %s\nStart(...): -want stopped, +got stopped:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCacheSyncChecker(t *testing.T) {
+	c := &fakeCache{}
+	sc := NewCacheSyncChecker(c, 10*time.Millisecond)
+
+	if sc.Synced() {
+		t.Errorf("\nReason: %s\nsc.Synced(): want false, got true", "A cache that did not sync should not be reported synced")
+	}
+	if diff := cmp.Diff(errors.New(errCacheNotSynced), sc.Check(nil), test.EquateErrors()); diff != "" {
+		t.Errorf("\nReason: %s\nsc.Check(...): -want error, +got error:\n%s", "The agent should not be ready while its cache is not synced", diff)
+	}
+
+	c.setSynced(true)
+	if !sc.Synced() {
+		t.Errorf("\nReason: %s\nsc.Synced(): want true, got false", "A cache that synced should be reported synced")
+	}
+	if diff := cmp.Diff(nil, sc.Check(nil), test.EquateErrors()); diff != "" {
+		t.Errorf("\nReason: %s\nsc.Check(...): -want error, +got error:\n%s", "The agent should be ready once its cache is synced", diff)
+	}
+
+	// Informers don't go out of sync once they synced.
+	c.setSynced(false)
+	if !sc.Synced() {
+		t.Errorf("\nReason: %s\nsc.Synced(): want true, got false", "A cache should stay synced once it synced")
+	}
+}
+
+func TestCacheSyncCheckerConcurrent(t *testing.T) {
+	c := &fakeCache{}
+	sc := NewCacheSyncChecker(c, 500*time.Millisecond)
+
+	// The first caller waits for a cache that doesn't sync in time.
+	waiting := make(chan bool)
+	go func() { waiting <- sc.Synced() }()
+	time.Sleep(10 * time.Millisecond)
+
+	c.setSynced(true)
+	start := time.Now()
+	if !sc.Synced() {
+		t.Errorf("\nReason: %s\nsc.Synced(): want true, got false", "A cache that synced should be reported synced")
+	}
+	if d := time.Since(start); d > 250*time.Millisecond {
+		t.Errorf("\nReason: %s\nsc.Synced(): took %s", "A caller should not wait for another caller that is still waiting for the cache", d)
+	}
+	<-waiting
+	if !sc.Synced() {
+		t.Errorf("\nReason: %s\nsc.Synced(): want true, got false", "A cache should stay synced once it synced")
+	}
+}